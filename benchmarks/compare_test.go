@@ -0,0 +1,164 @@
+package benchmarks
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// compare enables TestCompareBackends, which runs every workload against every backend and prints a table
+var compare = flag.Bool("compare", false, "run TestCompareBackends and print a backend comparison table")
+
+// comparisonKeys is the number of keys in the spread and batch workloads
+const comparisonKeys = 1000
+
+// comparisonWorkload is a workload run unchanged against every backend in workloadBackends
+// run receives a backend with a high limit and keys unique to the run, and must call b.ResetTimer after setup
+type comparisonWorkload struct {
+	name string
+	run  func(b *testing.B, store backend.Backend, keys []string)
+}
+
+// comparisonWorkloads lists the workloads compared across backends
+var comparisonWorkloads = []comparisonWorkload{
+	{
+		// Every request contends on one bucket
+		name: "take_hot_key",
+		run: func(b *testing.B, store backend.Backend, keys []string) {
+			ctx := context.Background()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := store.Take(ctx, keys[0], 1); err != nil {
+						b.Errorf("unexpected error: %v", err)
+						return
+					}
+				}
+			})
+		},
+	},
+	{
+		// Requests are spread over many buckets, so contention comes from the backend rather than a key
+		name: "take_spread_keys",
+		run: func(b *testing.B, store backend.Backend, keys []string) {
+			ctx := context.Background()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					if _, err := store.Take(ctx, keys[i%len(keys)], 1); err != nil {
+						b.Errorf("unexpected error: %v", err)
+						return
+					}
+				}
+			})
+		},
+	},
+	{
+		// A request limited per user, per tenant and globally at once
+		name: "take_all_8_keys",
+		run: func(b *testing.B, store backend.Backend, keys []string) {
+			ctx := context.Background()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					start := (i * 8) % (len(keys) - 8)
+					if _, err := store.TakeAll(ctx, keys[start:start+8], 1); err != nil {
+						b.Errorf("unexpected error: %v", err)
+						return
+					}
+				}
+			})
+		},
+	},
+	{
+		// Reads of bucket state, as done for rate limit headers
+		name: "get_info",
+		run: func(b *testing.B, store backend.Backend, keys []string) {
+			ctx := context.Background()
+			for _, key := range keys {
+				store.Take(ctx, key, 1)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					if _, err := store.GetInfo(ctx, keys[i%len(keys)]); err != nil {
+						b.Errorf("unexpected error: %v", err)
+						return
+					}
+				}
+			})
+		},
+	},
+}
+
+// runComparison runs a workload against a new backend with its own keys
+func runComparison(b *testing.B, bk workloadBackend, workload comparisonWorkload) {
+	store := bk.new(b, highLimit())
+
+	keys := keyNames(comparisonKeys)
+	prefix := runPrefix()
+	for i := range keys {
+		keys[i] = prefix + keys[i]
+	}
+
+	b.ReportAllocs()
+	workload.run(b, store, keys)
+}
+
+// BenchmarkBackends runs the same workloads against every backend, so results can be compared side by side
+func BenchmarkBackends(b *testing.B) {
+	for _, workload := range comparisonWorkloads {
+		for _, bk := range workloadBackends {
+			b.Run(fmt.Sprintf("workload=%s/backend=%s", workload.name, bk.name), func(b *testing.B) {
+				runComparison(b, bk, workload)
+			})
+		}
+	}
+}
+
+// TestCompareBackends prints a markdown table comparing the backends on every workload
+// It only runs with -compare, since each cell is a full benchmark run
+func TestCompareBackends(t *testing.T) {
+	if !*compare {
+		t.Skip("run with -compare to print the backend comparison table")
+	}
+
+	results := make([][]testing.BenchmarkResult, len(comparisonWorkloads))
+	for i, workload := range comparisonWorkloads {
+		results[i] = make([]testing.BenchmarkResult, len(workloadBackends))
+		for j, bk := range workloadBackends {
+			results[i][j] = testing.Benchmark(func(b *testing.B) {
+				runComparison(b, bk, workload)
+			})
+		}
+	}
+
+	writeComparison(os.Stdout, results)
+}
+
+// writeComparison writes the results of every workload on every backend as a markdown table
+// A backend that was skipped or failed has no iterations and is shown as skipped
+func writeComparison(w io.Writer, results [][]testing.BenchmarkResult) {
+	fmt.Fprintln(w, "| workload | backend | ops/s | ns/op | B/op | allocs/op |")
+	fmt.Fprintln(w, "|---|---|---:|---:|---:|---:|")
+
+	for i, workload := range comparisonWorkloads {
+		for j, bk := range workloadBackends {
+			r := results[i][j]
+			if r.N == 0 {
+				fmt.Fprintf(w, "| %s | %s | skipped | | | |\n", workload.name, bk.name)
+				continue
+			}
+
+			opsPerSec := float64(r.N) / r.T.Seconds()
+			fmt.Fprintf(w, "| %s | %s | %.0f | %d | %d | %d |\n",
+				workload.name, bk.name, opsPerSec, r.NsPerOp(), r.AllocedBytesPerOp(), r.AllocsPerOp())
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// Package benchmarks contains contention and mixed-workload benchmarks for the rate limiter
+//
+// Run them with allocation counts using:
+//
+//	go test -run=^$ -bench=. -benchmem ./benchmarks/
+//
+// The mixed workloads run against every backend with hot and uniform key distributions
+// BenchmarkBackends runs identical workloads against every backend, and TestCompareBackends
+// prints them as a comparison table when run with -compare:
+//
+//	go test -run=CompareBackends ./benchmarks/ -compare
+//
+// miniredis runs the Redis scripts in process, so it measures script cost without network round trips
+// Redis benchmarks are skipped unless RATELIMITER_REDIS_URL points to a Redis server
+// TestTakeAllocations asserts that Take on the in-memory backend does not allocate
+package benchmarks
@@ -0,0 +1,176 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/limiter"
+)
+
+// newLimiter creates a rate limiter over an in-memory backend with logging disabled
+func newLimiter(b testing.TB, options *backend.Options) *limiter.RateLimiter {
+	b.Helper()
+
+	store, err := backend.NewInMemoryBackend(options)
+	if err != nil {
+		b.Fatalf("failed to create backend: %v", err)
+	}
+
+	return newLimiterOver(b, store)
+}
+
+// newLimiterOver creates a rate limiter with logging disabled over an existing backend
+func newLimiterOver(b testing.TB, store backend.Backend) *limiter.RateLimiter {
+	b.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.EnableLogging = false
+
+	rl, err := limiter.New(store, cfg)
+	if err != nil {
+		b.Fatalf("failed to create limiter: %v", err)
+	}
+	b.Cleanup(func() { rl.Close(context.Background()) })
+
+	return rl
+}
+
+// highLimit returns backend options that effectively never deny
+func highLimit() *backend.Options {
+	return backend.DefaultOptions().WithLimit(1000000).WithBurst(1000000)
+}
+
+// keyNames returns n distinct key names
+func keyNames(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key_%d", i)
+	}
+	return keys
+}
+
+// TestTakeAllocations guards the hot path against per-request heap allocations
+func TestTakeAllocations(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		limit int64
+	}{
+		{name: "allowed", limit: 1000000},
+		{name: "denied", limit: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := newLimiter(t, backend.DefaultOptions().WithLimit(tt.limit).WithBurst(tt.limit).WithRefill(time.Hour))
+			rl.Take(ctx, "alloc_key", 1)
+
+			allocs := testing.AllocsPerRun(1000, func() {
+				rl.Take(ctx, "alloc_key", 1)
+			})
+			if allocs != 0 {
+				t.Errorf("expected 0 allocations per Take, got %v", allocs)
+			}
+		})
+	}
+}
+
+func BenchmarkTakeParallelHotKey(b *testing.B) {
+	rl := newLimiter(b, highLimit())
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := rl.Take(ctx, "hot_key", 1); err != nil {
+				b.Errorf("unexpected error: %v", err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkTakeParallelManyKeys(b *testing.B) {
+	for _, n := range []int{16, 1024} {
+		b.Run(fmt.Sprintf("keys=%d", n), func(b *testing.B) {
+			rl := newLimiter(b, highLimit())
+			ctx := context.Background()
+			keys := keyNames(n)
+
+			var next atomic.Uint64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					key := keys[next.Add(1)%uint64(len(keys))]
+					if _, err := rl.Take(ctx, key, 1); err != nil {
+						b.Errorf("unexpected error: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkTakeParallelDenied(b *testing.B) {
+	rl := newLimiter(b, backend.DefaultOptions().WithLimit(1).WithBurst(1).WithRefill(time.Hour))
+	ctx := context.Background()
+	rl.Take(ctx, "hot_key", 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := rl.Take(ctx, "hot_key", 1); err != nil {
+				b.Errorf("unexpected error: %v", err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkGetInfoParallelHotKey(b *testing.B) {
+	for _, optimistic := range []bool{false, true} {
+		b.Run(fmt.Sprintf("optimistic=%t", optimistic), func(b *testing.B) {
+			rl := newLimiter(b, highLimit().WithOptimisticBuckets(optimistic))
+			ctx := context.Background()
+			rl.Take(ctx, "hot_key", 1)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := rl.GetInfo(ctx, "hot_key"); err != nil {
+						b.Errorf("unexpected error: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkWaitSaturated(b *testing.B) {
+	rl := newLimiter(b, backend.DefaultOptions().WithLimit(1).WithBurst(1).WithRefill(time.Millisecond))
+	ctx := context.Background()
+
+	// Waiters queue on one key and are admitted one at a time in queue order
+	b.SetParallelism(8)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+			rl.Wait(waitCtx, "hot_key", 1)
+			cancel()
+		}
+	})
+}
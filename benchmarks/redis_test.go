@@ -0,0 +1,95 @@
+package benchmarks
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// newRedisBackend connects to the Redis server from RATELIMITER_REDIS_URL or skips the benchmark
+func newRedisBackend(b *testing.B, options *backend.Options) backend.Backend {
+	b.Helper()
+
+	url := os.Getenv("RATELIMITER_REDIS_URL")
+	if url == "" {
+		b.Skip("RATELIMITER_REDIS_URL is not set")
+	}
+
+	store, err := backend.NewRedisBackend(url, options)
+	if err != nil {
+		b.Fatalf("failed to connect to Redis: %v", err)
+	}
+	b.Cleanup(func() { store.Close(context.Background()) })
+
+	return store
+}
+
+// newMiniredisBackend starts an in-process Redis server for the benchmark
+func newMiniredisBackend(b *testing.B, options *backend.Options) backend.Backend {
+	b.Helper()
+
+	server := miniredis.NewMiniRedis()
+	if err := server.Start(); err != nil {
+		b.Fatalf("failed to start miniredis: %v", err)
+	}
+	b.Cleanup(server.Close)
+
+	store, err := backend.NewRedisBackend("redis://"+server.Addr(), options)
+	if err != nil {
+		b.Fatalf("failed to connect to miniredis: %v", err)
+	}
+	b.Cleanup(func() { store.Close(context.Background()) })
+
+	return store
+}
+
+// BenchmarkRedisUnpipelined takes from each key with its own round trip
+func BenchmarkRedisUnpipelined(b *testing.B) {
+	store := newRedisBackend(b, highLimit())
+	ctx := context.Background()
+	keys := keyNames(8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			if _, err := store.Take(ctx, key, 1); err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+		}
+	}
+}
+
+// BenchmarkRedisPipelined takes from every key in a single round trip
+func BenchmarkRedisPipelined(b *testing.B) {
+	store := newRedisBackend(b, highLimit())
+	ctx := context.Background()
+	keys := keyNames(8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.TakeAll(ctx, keys, 1); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkRedisTakeParallelHotKey(b *testing.B) {
+	store := newRedisBackend(b, highLimit())
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := store.Take(ctx, "hot_key", 1); err != nil {
+				b.Errorf("unexpected error: %v", err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// workloadKeys is the number of distinct keys in the mixed workloads
+const workloadKeys = 10000
+
+// workloadBackend creates a backend for the mixed workloads
+type workloadBackend struct {
+	name string
+	new  func(b *testing.B, options *backend.Options) backend.Backend
+}
+
+// workloadBackends lists every backend the mixed workloads and backend comparison run against
+// Each entry closes its backend when the benchmark ends
+var workloadBackends = []workloadBackend{
+	{
+		name: "memory",
+		new: func(b *testing.B, options *backend.Options) backend.Backend {
+			store, err := backend.NewInMemoryBackend(options)
+			if err != nil {
+				b.Fatalf("failed to create backend: %v", err)
+			}
+			b.Cleanup(func() { store.Close(context.Background()) })
+			return store
+		},
+	},
+	{
+		// An in-process Redis running the same Lua scripts, without network round trips
+		name: "miniredis",
+		new:  newMiniredisBackend,
+	},
+	{
+		name: "redis",
+		new:  newRedisBackend,
+	},
+}
+
+// keyDistributions lists how requests are spread across n keys
+// Each goroutine builds its own picker from its own random source
+var keyDistributions = []struct {
+	name      string
+	newPicker func(rng *rand.Rand, n int) func() int
+}{
+	{
+		// A few keys take most of the traffic, as with popular API clients
+		name: "hot",
+		newPicker: func(rng *rand.Rand, n int) func() int {
+			zipf := rand.NewZipf(rng, 1.1, 1, uint64(n-1))
+			return func() int { return int(zipf.Uint64()) }
+		},
+	},
+	{
+		name: "uniform",
+		newPicker: func(rng *rand.Rand, n int) func() int {
+			return func() int { return rng.Intn(n) }
+		},
+	},
+}
+
+// runPrefix returns a key prefix unique to one benchmark run, so Redis state from earlier runs is not reused
+func runPrefix() string {
+	return fmt.Sprintf("bench:%d:", time.Now().UnixNano())
+}
+
+// BenchmarkMixedAllowDeny sends 90% of requests to keys with room and 10% to exhausted keys
+func BenchmarkMixedAllowDeny(b *testing.B) {
+	for _, bk := range workloadBackends {
+		for _, dist := range keyDistributions {
+			b.Run(fmt.Sprintf("backend=%s/keys=%s", bk.name, dist.name), func(b *testing.B) {
+				store := bk.new(b, highLimit())
+				rl := newLimiterOver(b, store)
+				ctx := context.Background()
+
+				prefix := runPrefix()
+				allowedKeys := make([]string, workloadKeys)
+				for i := range allowedKeys {
+					allowedKeys[i] = fmt.Sprintf("%sallow_%d", prefix, i)
+				}
+
+				deniedKeys := make([]string, workloadKeys/10)
+				for i := range deniedKeys {
+					deniedKeys[i] = fmt.Sprintf("%sdeny_%d", prefix, i)
+					if err := store.SetLimit(ctx, deniedKeys[i], 1, time.Hour); err != nil {
+						b.Fatalf("failed to set limit: %v", err)
+					}
+					store.Take(ctx, deniedKeys[i], 1)
+				}
+
+				var seed atomic.Int64
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rng := rand.New(rand.NewSource(seed.Add(1)))
+					pickAllowed := dist.newPicker(rng, len(allowedKeys))
+					pickDenied := dist.newPicker(rng, len(deniedKeys))
+					for i := 0; pb.Next(); i++ {
+						key := allowedKeys[pickAllowed()]
+						if i%10 == 9 {
+							key = deniedKeys[pickDenied()]
+						}
+
+						if _, err := rl.Take(ctx, key, 1); err != nil {
+							b.Errorf("unexpected error: %v", err)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkWaitHeavy has every request Wait on keys that refill quickly but run out often
+func BenchmarkWaitHeavy(b *testing.B) {
+	options := func() *backend.Options {
+		return backend.DefaultOptions().WithLimit(10).WithBurst(10).WithRefill(time.Millisecond)
+	}
+
+	for _, bk := range workloadBackends {
+		for _, dist := range keyDistributions {
+			b.Run(fmt.Sprintf("backend=%s/keys=%s", bk.name, dist.name), func(b *testing.B) {
+				rl := newLimiterOver(b, bk.new(b, options()))
+				ctx := context.Background()
+
+				keys := keyNames(64)
+				prefix := runPrefix()
+				for i := range keys {
+					keys[i] = prefix + keys[i]
+				}
+
+				var seed atomic.Int64
+				b.SetParallelism(8)
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rng := rand.New(rand.NewSource(seed.Add(1)))
+					pick := dist.newPicker(rng, len(keys))
+					for pb.Next() {
+						waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+						rl.Wait(waitCtx, keys[pick()], 1)
+						cancel()
+					}
+				})
+			})
+		}
+	}
+}
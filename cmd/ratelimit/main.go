@@ -0,0 +1,397 @@
+// Command ratelimit inspects and adjusts rate limits stored in a backend
+//
+// Usage:
+//
+//	ratelimit [flags] <command> [arguments]
+//
+// Commands:
+//
+//	info <key>                        show the bucket state of a key
+//	reset <key>                       clear the bucket of a key
+//	set-limit <key> <limit> <refill>  set a custom limit, e.g. set-limit user:1 100 1s
+//	list [pattern]                    list tracked keys matching a glob pattern
+//	dump <file> [pattern]             write the buckets and blocks of keys matching a glob pattern to a file, - for stdout
+//	restore <file>                    write the buckets and blocks of a dump back, - for stdin
+//	bench [flags]                     measure Take throughput and latency
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// defaultRedisURL is used when neither -redis-url nor RATELIMITER_REDIS_URL is set
+const defaultRedisURL = "redis://localhost:6379"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// cli holds the global flags and output streams of one invocation
+type cli struct {
+	backendType string
+	redisURL    string
+	keyPrefix   string
+	limit       int64
+	refill      time.Duration
+	burst       int64
+	timeout     time.Duration
+
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// run parses the arguments, runs one command and returns the process exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout, stderr: stderr}
+	defaults := backend.DefaultOptions()
+
+	redisURL := os.Getenv("RATELIMITER_REDIS_URL")
+	if redisURL == "" {
+		redisURL = defaultRedisURL
+	}
+
+	flags := flag.NewFlagSet("ratelimit", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&c.backendType, "backend", "redis", "backend type: redis or memory")
+	flags.StringVar(&c.redisURL, "redis-url", redisURL, "Redis URL, defaults to $RATELIMITER_REDIS_URL")
+	flags.StringVar(&c.keyPrefix, "key-prefix", defaults.KeyPrefix, "prefix of the Redis keys of the limiter")
+	flags.Int64Var(&c.limit, "limit", defaults.DefaultLimit, "default limit for new buckets")
+	flags.DurationVar(&c.refill, "refill", defaults.DefaultRefill, "default refill interval for new buckets")
+	flags.Int64Var(&c.burst, "burst", defaults.DefaultBurst, "default burst for new buckets")
+	flags.DurationVar(&c.timeout, "timeout", 5*time.Second, "timeout for a single command")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ratelimit [flags] <command> [arguments]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Commands:")
+		fmt.Fprintln(stderr, "  info <key>                        show the bucket state of a key")
+		fmt.Fprintln(stderr, "  reset <key>                       clear the bucket of a key")
+		fmt.Fprintln(stderr, "  set-limit <key> <limit> <refill>  set a custom limit, e.g. set-limit user:1 100 1s")
+		fmt.Fprintln(stderr, "  list [pattern]                    list tracked keys matching a glob pattern")
+		fmt.Fprintln(stderr, "  dump <file> [pattern]             write the buckets and blocks of keys matching a glob pattern to a file, - for stdout")
+		fmt.Fprintln(stderr, "  restore <file>                    write the buckets and blocks of a dump back, - for stdin")
+		fmt.Fprintln(stderr, "  bench [flags]                     measure Take throughput and latency")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Flags:")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+
+	var handler func(ctx context.Context, b backend.Backend, args []string) error
+	switch command {
+	case "info":
+		handler = c.info
+	case "reset":
+		handler = c.reset
+	case "set-limit":
+		handler = c.setLimit
+	case "list":
+		handler = c.list
+	case "dump":
+		handler = c.dump
+	case "restore":
+		handler = c.restore
+	case "bench":
+		handler = c.bench
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", command)
+		flags.Usage()
+		return 2
+	}
+
+	b, err := c.newBackend()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	defer b.Close(context.Background())
+
+	if err := handler(ctx, b, commandArgs); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// newBackend connects to the backend selected by the flags
+func (c *cli) newBackend() (backend.Backend, error) {
+	options := backend.DefaultOptions().WithLimit(c.limit).WithRefill(c.refill).WithBurst(c.burst).WithKeyPrefix(c.keyPrefix)
+
+	switch c.backendType {
+	case "redis":
+		return backend.NewRedisBackend(c.redisURL, options)
+	case "memory":
+		return backend.NewInMemoryBackend(options)
+	default:
+		return nil, fmt.Errorf("unknown backend %q, expected redis or memory", c.backendType)
+	}
+}
+
+// info prints the bucket state of a key as JSON
+func (c *cli) info(ctx context.Context, b backend.Backend, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: info <key>")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	info, err := b.GetInfo(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(info)
+}
+
+// reset clears the bucket of a key
+func (c *cli) reset(ctx context.Context, b backend.Backend, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: reset <key>")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if err := b.Reset(ctx, args[0]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "reset %s\n", args[0])
+	return nil
+}
+
+// setLimit sets a custom limit and refill interval for a key
+func (c *cli) setLimit(ctx context.Context, b backend.Backend, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: set-limit <key> <limit> <refill>")
+	}
+
+	limit, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid limit %q: %w", args[1], err)
+	}
+
+	refill, err := time.ParseDuration(args[2])
+	if err != nil {
+		return fmt.Errorf("invalid refill %q: %w", args[2], err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if err := b.SetLimit(ctx, args[0], limit, refill); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "set limit of %s to %d per %s\n", args[0], limit, refill)
+	return nil
+}
+
+// list prints the tracked keys matching an optional glob pattern, one per line
+func (c *cli) list(ctx context.Context, b backend.Backend, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: list [pattern]")
+	}
+
+	lister, ok := b.(backend.KeyLister)
+	if !ok {
+		return fmt.Errorf("backend %s cannot list keys", c.backendType)
+	}
+
+	pattern := ""
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	keys, err := lister.Keys(ctx, pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		fmt.Fprintln(c.stdout, key)
+	}
+
+	return nil
+}
+
+// dump writes the buckets and blocks of the keys matching an optional glob pattern to a file
+// It is not bound by -timeout, since it scans the whole keyspace, interrupting it stops it
+func (c *cli) dump(ctx context.Context, b backend.Backend, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: dump <file> [pattern]")
+	}
+
+	dumper, ok := b.(backend.Dumper)
+	if !ok {
+		return fmt.Errorf("backend %s cannot dump keys", c.backendType)
+	}
+
+	pattern := ""
+	if len(args) == 2 {
+		pattern = args[1]
+	}
+
+	// A failed dump must not leave a partial file where a good one is expected
+	var buf bytes.Buffer
+	n, err := dumper.Dump(ctx, &buf, pattern)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "-" {
+		_, err = c.stdout.Write(buf.Bytes())
+		return err
+	}
+
+	if err := os.WriteFile(args[0], buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "dumped %d buckets to %s\n", n, args[0])
+	return nil
+}
+
+// restore writes the buckets and blocks of a dump file back to the backend
+func (c *cli) restore(ctx context.Context, b backend.Backend, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: restore <file>")
+	}
+
+	dumper, ok := b.(backend.Dumper)
+	if !ok {
+		return fmt.Errorf("backend %s cannot restore keys", c.backendType)
+	}
+
+	var in io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	n, err := dumper.Restore(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "restored %d buckets\n", n)
+	return nil
+}
+
+// bench sends Take requests for one key and reports throughput and latency
+func (c *cli) bench(ctx context.Context, b backend.Backend, args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	requests := flags.Int("n", 10000, "total number of requests")
+	concurrency := flags.Int("c", 10, "number of concurrent workers")
+	key := flags.String("key", "ratelimit:bench", "key to take tokens from, reset when the benchmark ends")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *requests <= 0 || *concurrency <= 0 {
+		return fmt.Errorf("-n and -c must be positive")
+	}
+
+	var allowed, denied, failed atomic.Int64
+	var next atomic.Int64
+	latencies := make([]time.Duration, *requests)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if ctx.Err() != nil {
+					return
+				}
+
+				n := next.Add(1) - 1
+				if n >= int64(*requests) {
+					return
+				}
+
+				begin := time.Now()
+				ok, err := b.Take(ctx, *key, 1)
+				latencies[n] = time.Since(begin)
+
+				switch {
+				case err != nil:
+					failed.Add(1)
+				case ok:
+					allowed.Add(1)
+				default:
+					denied.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	resetCtx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	b.Reset(resetCtx, *key)
+
+	completed := int(allowed.Load() + denied.Load() + failed.Load())
+	latencies = latencies[:completed]
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Fprintf(c.stdout, "requests:   %d\n", completed)
+	fmt.Fprintf(c.stdout, "allowed:    %d\n", allowed.Load())
+	fmt.Fprintf(c.stdout, "denied:     %d\n", denied.Load())
+	fmt.Fprintf(c.stdout, "errors:     %d\n", failed.Load())
+	fmt.Fprintf(c.stdout, "duration:   %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(c.stdout, "throughput: %.0f req/s\n", float64(completed)/elapsed.Seconds())
+	if completed > 0 {
+		fmt.Fprintf(c.stdout, "latency:    p50=%s p99=%s max=%s\n",
+			percentile(latencies, 0.50), percentile(latencies, 0.99), latencies[completed-1])
+	}
+
+	return ctx.Err()
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(float64(len(sorted)-1) * p)
+	return sorted[index]
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRunUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{name: "no command", args: nil, expected: 2},
+		{name: "unknown command", args: []string{"frobnicate"}, expected: 2},
+		{name: "unknown backend", args: []string{"-backend", "etcd", "info", "key"}, expected: 1},
+		{name: "missing key", args: []string{"-backend", "memory", "info"}, expected: 1},
+		{name: "invalid limit", args: []string{"-backend", "memory", "set-limit", "key", "many", "1s"}, expected: 1},
+		{name: "invalid refill", args: []string{"-backend", "memory", "set-limit", "key", "10", "soon"}, expected: 1},
+		{name: "dump unsupported", args: []string{"-backend", "memory", "dump", "-"}, expected: 1},
+		{name: "restore missing file", args: []string{"-backend", "memory", "restore"}, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(context.Background(), tt.args, &stdout, &stderr); code != tt.expected {
+				t.Errorf("expected exit code %d, got %d (stderr: %s)", tt.expected, code, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		contains string
+	}{
+		{name: "info", args: []string{"info", "user:1"}, contains: `"key": "user:1"`},
+		{name: "reset", args: []string{"reset", "user:1"}, contains: "reset user:1"},
+		{name: "set-limit", args: []string{"set-limit", "user:1", "50", "2s"}, contains: "set limit of user:1 to 50 per 2s"},
+		{name: "list", args: []string{"list", "user:*"}, contains: ""},
+		{name: "bench", args: []string{"bench", "-n", "100", "-c", "4"}, contains: "requests:   100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-backend", "memory"}, tt.args...)
+			if code := run(context.Background(), args, &stdout, &stderr); code != 0 {
+				t.Fatalf("expected exit code 0, got %d (stderr: %s)", code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.contains) {
+				t.Errorf("expected output to contain %q, got %q", tt.contains, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunDumpRestore(t *testing.T) {
+	source := miniredis.RunT(t)
+	target := miniredis.RunT(t)
+	path := filepath.Join(t.TempDir(), "dump.json")
+
+	steps := []struct {
+		server   *miniredis.Miniredis
+		args     []string
+		contains string
+	}{
+		{server: source, args: []string{"set-limit", "user:1", "50", "2s"}, contains: "set limit"},
+		{server: source, args: []string{"dump", path, "user:*"}, contains: "dumped 1 buckets"},
+		{server: target, args: []string{"restore", path}, contains: "restored 1 buckets"},
+		{server: target, args: []string{"info", "user:1"}, contains: `"max_tokens": 50`},
+	}
+
+	for _, step := range steps {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"-redis-url", "redis://" + step.server.Addr()}, step.args...)
+		if code := run(context.Background(), args, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: expected exit code 0, got %d (stderr: %s)", step.args[0], code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), step.contains) {
+			t.Errorf("%s: expected output to contain %q, got %q", step.args[0], step.contains, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,342 @@
+// Command soak runs a long workload against a backend to validate refill and cleanup over hours
+//
+// Usage:
+//
+//	soak [flags]
+//
+// Hot keys are taken from several times per refill interval, so each should admit its limit
+// plus one token per interval since its first take. Every report compares the admitted
+// tokens with that expectation, and a key admitting more than its bucket allows is counted
+// as a violation. Churn keys are taken from once and never again, so the key count and heap
+// only stay flat when cleanup drops them.
+//
+// soak exits with 1 when a violation was seen or admissions drifted below the expectation
+// by more than -max-drift, and with 2 on invalid flags.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// defaultRedisURL is used when neither -redis-url nor RATELIMITER_REDIS_URL is set
+const defaultRedisURL = "redis://localhost:6379"
+
+// attemptsPerRefill is how many times per refill interval each hot key is taken from, keeping it saturated
+const attemptsPerRefill = 4
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// soak holds the flags and counters of one run
+type soak struct {
+	backendType string
+	redisURL    string
+	duration    time.Duration
+	report      time.Duration
+	hotKeys     int
+	workers     int
+	churn       int
+	limit       int64
+	refill      time.Duration
+	cleanup     time.Duration
+	maxDrift    float64
+	timeout     time.Duration
+
+	prefix string
+	keys   []*hotKey
+	start  time.Time
+
+	violations atomic.Int64
+	errors     atomic.Int64
+	churned    atomic.Int64
+
+	// firstHeap is the heap after the first report, growth is measured from there once the workload settled
+	firstHeap uint64
+
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// hotKey is a key taken from faster than it refills, owned by a single worker
+// first is the time in nanoseconds its worker first took from it, 0 until then
+type hotKey struct {
+	name     string
+	first    atomic.Int64
+	admitted atomic.Int64
+}
+
+// run parses the flags, runs the workload until the duration ends or ctx is cancelled and returns the exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	s := &soak{stdout: stdout, stderr: stderr}
+
+	redisURL := os.Getenv("RATELIMITER_REDIS_URL")
+	if redisURL == "" {
+		redisURL = defaultRedisURL
+	}
+
+	flags := flag.NewFlagSet("soak", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&s.backendType, "backend", "memory", "backend type: redis or memory")
+	flags.StringVar(&s.redisURL, "redis-url", redisURL, "Redis URL, defaults to $RATELIMITER_REDIS_URL")
+	flags.DurationVar(&s.duration, "duration", 2*time.Hour, "how long to run the workload")
+	flags.DurationVar(&s.report, "report", time.Minute, "interval between reports")
+	flags.IntVar(&s.hotKeys, "keys", 100, "number of hot keys checked for drift")
+	flags.IntVar(&s.workers, "workers", 8, "number of workers taking from hot keys")
+	flags.IntVar(&s.churn, "churn", 100, "new keys taken from once per second, 0 disables churn")
+	flags.Int64Var(&s.limit, "limit", 10, "limit of every key")
+	flags.DurationVar(&s.refill, "refill", 100*time.Millisecond, "refill interval of every key")
+	flags.DurationVar(&s.cleanup, "cleanup", time.Minute, "cleanup interval of the backend")
+	flags.Float64Var(&s.maxDrift, "max-drift", 0.01, "largest fraction admissions may fall below the expectation")
+	flags.DurationVar(&s.timeout, "timeout", 5*time.Second, "timeout for a single backend call")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 0 {
+		fmt.Fprintf(stderr, "unexpected arguments %v\n", flags.Args())
+		return 2
+	}
+
+	if err := s.validate(); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+
+	b, err := s.newBackend()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	defer b.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(ctx, s.duration)
+	defer cancel()
+
+	s.prefix = fmt.Sprintf("soak:%d:", time.Now().UnixNano())
+	s.keys = make([]*hotKey, s.hotKeys)
+	for i := range s.keys {
+		s.keys[i] = &hotKey{name: fmt.Sprintf("%shot:%d", s.prefix, i)}
+	}
+
+	s.start = time.Now()
+
+	var wg sync.WaitGroup
+	for w := 0; w < s.workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			s.work(ctx, b, w)
+		}(w)
+	}
+
+	if s.churn > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.churnKeys(ctx, b)
+		}()
+	}
+
+	ticker := time.NewTicker(s.report)
+	defer ticker.Stop()
+
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-ticker.C:
+			s.print(b)
+		}
+	}
+	wg.Wait()
+
+	drift := s.print(b)
+	s.resetKeys(b)
+
+	switch {
+	case s.violations.Load() > 0:
+		fmt.Fprintf(stdout, "FAIL: %d takes admitted more than the bucket allows\n", s.violations.Load())
+		return 1
+	case drift < -s.maxDrift:
+		fmt.Fprintf(stdout, "FAIL: admissions drifted %.3f%% below the expectation, more than %.3f%%\n", -drift*100, s.maxDrift*100)
+		return 1
+	}
+
+	fmt.Fprintln(stdout, "PASS")
+	return 0
+}
+
+// validate checks the flags
+func (s *soak) validate() error {
+	switch {
+	case s.duration <= 0 || s.report <= 0:
+		return fmt.Errorf("-duration and -report must be positive")
+	case s.hotKeys <= 0 || s.workers <= 0:
+		return fmt.Errorf("-keys and -workers must be positive")
+	case s.churn < 0:
+		return fmt.Errorf("-churn must not be negative")
+	case s.limit <= 0:
+		return fmt.Errorf("-limit must be positive")
+	case s.refill < attemptsPerRefill*time.Millisecond:
+		return fmt.Errorf("-refill must be at least %dms, got %s", attemptsPerRefill, s.refill)
+	case s.cleanup <= 0 || s.timeout <= 0:
+		return fmt.Errorf("-cleanup and -timeout must be positive")
+	case s.maxDrift < 0:
+		return fmt.Errorf("-max-drift must not be negative")
+	}
+
+	return nil
+}
+
+// newBackend connects to the backend selected by the flags
+func (s *soak) newBackend() (backend.Backend, error) {
+	options := backend.DefaultOptions().WithLimit(s.limit).WithRefill(s.refill)
+	options.CleanupInterval = s.cleanup
+
+	switch s.backendType {
+	case "redis":
+		return backend.NewRedisBackend(s.redisURL, options)
+	case "memory":
+		return backend.NewInMemoryBackend(options)
+	default:
+		return nil, fmt.Errorf("unknown backend %q, expected redis or memory", s.backendType)
+	}
+}
+
+// work takes from the hot keys of worker w every refill interval divided by attemptsPerRefill
+// A key is only ever taken from by its worker, so checking its admissions right after a take is race free
+func (s *soak) work(ctx context.Context, b backend.Backend, w int) {
+	ticker := time.NewTicker(s.refill / attemptsPerRefill)
+	defer ticker.Stop()
+
+	for {
+		for i := w; i < len(s.keys); i += s.workers {
+			if ctx.Err() != nil {
+				return
+			}
+
+			key := s.keys[i]
+			if key.first.Load() == 0 {
+				key.first.Store(time.Now().UnixNano())
+			}
+
+			allowed, err := s.take(ctx, b, key.name)
+			switch {
+			case err != nil:
+				if ctx.Err() == nil {
+					s.errors.Add(1)
+				}
+			case allowed:
+				// The bucket starts full at the first take and gains a token per interval since
+				if key.admitted.Add(1) > s.allowance(key.first.Load(), time.Now()) {
+					s.violations.Add(1)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// churnKeys takes once from new keys at the churn rate, leaving them for cleanup
+func (s *soak) churnKeys(ctx context.Context, b backend.Backend) {
+	ticker := time.NewTicker(time.Second / time.Duration(s.churn))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n := s.churned.Add(1)
+		if _, err := s.take(ctx, b, fmt.Sprintf("%schurn:%d", s.prefix, n)); err != nil && ctx.Err() == nil {
+			s.errors.Add(1)
+		}
+	}
+}
+
+// take takes one token from the key within the timeout of a single call
+func (s *soak) take(ctx context.Context, b backend.Backend, key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return b.Take(ctx, key, 1)
+}
+
+// allowance returns how many tokens a bucket first taken from at first, in nanoseconds, may have admitted by now
+func (s *soak) allowance(first int64, now time.Time) int64 {
+	return s.limit + (now.UnixNano()-first)/int64(s.refill)
+}
+
+// print reports admissions against the expectation, the key count and the heap, and returns the drift
+// The drift is the fraction by which admissions differ from the expectation, negative when fewer were admitted
+func (s *soak) print(b backend.Backend) float64 {
+	now := time.Now()
+
+	var admitted, expected int64
+	for _, key := range s.keys {
+		first := key.first.Load()
+		if first == 0 {
+			continue
+		}
+		admitted += key.admitted.Load()
+		expected += s.allowance(first, now)
+	}
+
+	drift := 0.0
+	if expected > 0 {
+		drift = float64(admitted-expected) / float64(expected)
+	}
+
+	runtime.GC()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	if s.firstHeap == 0 {
+		s.firstHeap = mem.HeapAlloc
+	}
+
+	keys := "n/a"
+	if reporter, ok := b.(backend.StatsReporter); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		if stats, err := reporter.Stats(ctx); err == nil {
+			keys = fmt.Sprint(stats.Keys)
+		}
+		cancel()
+	}
+
+	fmt.Fprintf(s.stdout, "elapsed=%s admitted=%d expected=%d drift=%+.3f%% violations=%d errors=%d churned=%d keys=%s heap=%.1fMB heap_growth=%+.1fMB\n",
+		now.Sub(s.start).Round(time.Second), admitted, expected, drift*100, s.violations.Load(), s.errors.Load(),
+		s.churned.Load(), keys, float64(mem.HeapAlloc)/(1<<20), (float64(mem.HeapAlloc)-float64(s.firstHeap))/(1<<20))
+
+	return drift
+}
+
+// resetKeys clears the hot keys, churn keys are left to expire
+func (s *soak) resetKeys(b backend.Backend) {
+	for _, key := range s.keys {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		b.Reset(ctx, key.name)
+		cancel()
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "unknown flag", args: []string{"-frobnicate"}},
+		{name: "extra argument", args: []string{"now"}},
+		{name: "zero duration", args: []string{"-duration", "0"}},
+		{name: "negative churn", args: []string{"-churn", "-1"}},
+		{name: "refill too short", args: []string{"-refill", "1ms"}},
+		{name: "negative drift", args: []string{"-max-drift", "-0.5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(context.Background(), tt.args, &stdout, &stderr); code != 2 {
+				t.Errorf("expected exit code 2, got %d (stderr: %s)", code, stderr.String())
+			}
+		})
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run(context.Background(), []string{"-backend", "etcd"}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 for an unknown backend, got %d", code)
+	}
+}
+
+func TestRunSoak(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{
+		"-duration", "500ms", "-report", "200ms", "-keys", "20", "-workers", "4", "-churn", "200",
+		"-limit", "3", "-refill", "20ms", "-cleanup", "100ms", "-max-drift", "0.5",
+	}
+	if code := run(context.Background(), args, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stdout: %s, stderr: %s)", code, stdout.String(), stderr.String())
+	}
+
+	output := stdout.String()
+	for _, expected := range []string{"violations=0", "errors=0", "PASS"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected output to contain %q, got %q", expected, output)
+		}
+	}
+	if lines := strings.Count(output, "elapsed="); lines < 2 {
+		t.Errorf("expected periodic reports and a final one, got %d", lines)
+	}
+}
@@ -0,0 +1,107 @@
+package backend
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// keyActivityRetention is how long the activity of a key is kept after its last decision
+const keyActivityRetention = 15 * time.Minute
+
+// KeyActivity is the recent decisions on a key, counted over a sliding window as Stats counts tokens
+type KeyActivity struct {
+	// Requests is the decisions made on the key over the window, allowed or denied
+	Requests int64 `json:"requests"`
+
+	// Denied is the requests denied over the window
+	Denied int64 `json:"denied"`
+
+	// LastDenied is when a request on the key was last denied, zero if none was within the retention
+	LastDenied time.Time `json:"last_denied,omitempty"`
+
+	// Window is the sliding window Requests and Denied cover
+	Window time.Duration `json:"window"`
+}
+
+// activityCounter counts the decisions on one key, requests and denials, over the statsWindow
+type activityCounter struct {
+	counts     WindowCounter
+	lastDenied atomic.Int64
+	lastSeen   atomic.Int64
+}
+
+// record counts a decision at now
+func (c *activityCounter) record(now time.Time, allowed bool) {
+	c.lastSeen.Store(now.UnixNano())
+
+	var denied int64
+	if !allowed {
+		c.lastDenied.Store(now.UnixNano())
+		denied = 1
+	}
+
+	c.counts.Add(now, 1, denied)
+}
+
+// newActivityCounter returns an activityCounter with no decisions
+func newActivityCounter() *activityCounter {
+	return &activityCounter{counts: WindowCounter{Window: statsWindow}}
+}
+
+// activity reports the decisions over the statsWindow before now
+func (c *activityCounter) activity(now time.Time) *KeyActivity {
+	requests, denied := c.counts.Counts(now)
+	activity := &KeyActivity{Requests: requests, Denied: denied, Window: statsWindow}
+	if last := c.lastDenied.Load(); last != 0 {
+		activity.LastDenied = time.Unix(0, last)
+	}
+
+	return activity
+}
+
+// activityLog holds the counters of the keys with recent decisions
+// Counters live apart from buckets, so they survive resets and count decisions on blocked keys without a bucket
+type activityLog struct {
+	counters sync.Map
+}
+
+// record counts a decision on each key at now
+func (l *activityLog) record(now time.Time, allowed bool, keys ...string) {
+	for _, key := range keys {
+		counter, ok := l.counters.Load(key)
+		if !ok {
+			counter, _ = l.counters.LoadOrStore(key, newActivityCounter())
+		}
+		counter.(*activityCounter).record(now, allowed)
+	}
+}
+
+// activity reports the recent decisions on a key, with zero counts for a key without any
+func (l *activityLog) activity(key string, now time.Time) *KeyActivity {
+	counter, ok := l.counters.Load(key)
+	if !ok {
+		return &KeyActivity{Window: statsWindow}
+	}
+
+	return counter.(*activityCounter).activity(now)
+}
+
+// cleanup forgets the keys without a decision within the keyActivityRetention before now
+func (l *activityLog) cleanup(now time.Time) {
+	cutoff := now.Add(-keyActivityRetention).UnixNano()
+	l.counters.Range(func(key, value interface{}) bool {
+		if value.(*activityCounter).lastSeen.Load() < cutoff {
+			l.counters.CompareAndDelete(key, value)
+		}
+
+		return true
+	})
+}
+
+// recordActivity counts a decision on the keys when the options enable key activity
+func (b *inMemoryBackend) recordActivity(now time.Time, allowed bool, keys ...string) {
+	if b.options.KeyActivity {
+		b.activity.record(now, allowed, keys...)
+	}
+}
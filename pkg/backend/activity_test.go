@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestActivityCounterWindows(t *testing.T) {
+	c := newActivityCounter()
+	start := time.Unix(0, 0).Add(100 * statsWindow)
+
+	c.record(start, true)
+	c.record(start.Add(time.Second), false)
+	c.record(start.Add(2*time.Second), true)
+
+	activity := c.activity(start.Add(2 * time.Second))
+	if activity.Requests != 3 || activity.Denied != 1 {
+		t.Errorf("expected 3 requests and 1 denied, got %d and %d", activity.Requests, activity.Denied)
+	}
+	if !activity.LastDenied.Equal(start.Add(time.Second)) {
+		t.Errorf("expected last denial at %v, got %v", start.Add(time.Second), activity.LastDenied)
+	}
+
+	// Halfway through the next window half of the previous one still counts
+	next := start.Add(statsWindow + statsWindow/2)
+	c.record(next, false)
+	activity = c.activity(next)
+	if activity.Requests != 2 || activity.Denied != 1 {
+		t.Errorf("expected 2 requests and 1 denied, got %d and %d", activity.Requests, activity.Denied)
+	}
+
+	// Windows older than the previous one are dropped, the last denial is kept
+	activity = c.activity(start.Add(3 * statsWindow))
+	if activity.Requests != 0 || activity.Denied != 0 {
+		t.Errorf("expected no requests, got %d and %d", activity.Requests, activity.Denied)
+	}
+	if !activity.LastDenied.Equal(next) {
+		t.Errorf("expected last denial at %v, got %v", next, activity.LastDenied)
+	}
+}
+
+func TestActivityLogCleanup(t *testing.T) {
+	var l activityLog
+	now := time.Now()
+
+	l.record(now.Add(-keyActivityRetention-time.Second), false, "idle")
+	l.record(now, true, "active")
+	l.cleanup(now)
+
+	if _, ok := l.counters.Load("idle"); ok {
+		t.Error("expected idle key to be forgotten")
+	}
+	if activity := l.activity("active", now); activity.Requests != 1 {
+		t.Errorf("expected 1 request on active key, got %d", activity.Requests)
+	}
+}
+
+func TestKeyActivity(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithLimit(2).WithRefill(time.Hour).WithKeyActivity(true)
+
+	inMemory, err := NewInMemoryBackend(options)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer inMemory.Close(ctx)
+	redis, _ := newTestRedisBackend(t, options)
+
+	for name, b := range map[string]Backend{"memory": inMemory, "redis": redis} {
+		t.Run(name, func(t *testing.T) {
+			before := time.Now().Add(-time.Second)
+
+			b.Take(ctx, "key", 1)
+			b.Take(ctx, "key", 1)
+			b.Take(ctx, "key", 1)
+			b.TakeAll(ctx, []string{"key", "other"}, 1)
+
+			info, err := b.GetInfo(ctx, "key")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Activity == nil {
+				t.Fatal("expected activity to be reported")
+			}
+			if info.Activity.Requests != 4 || info.Activity.Denied != 2 {
+				t.Errorf("expected 4 requests and 2 denied, got %d and %d", info.Activity.Requests, info.Activity.Denied)
+			}
+			if info.Activity.LastDenied.Before(before) {
+				t.Errorf("expected a recent last denial, got %v", info.Activity.LastDenied)
+			}
+			if info.Activity.Window != statsWindow {
+				t.Errorf("expected window %v, got %v", statsWindow, info.Activity.Window)
+			}
+
+			// Activity outlives a reset, and decisions on blocked keys count as denials
+			if err := b.Reset(ctx, "key"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := b.Block(ctx, "key", time.Minute); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			b.Take(ctx, "key", 1)
+
+			info, err = b.GetInfo(ctx, "key")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Activity.Requests != 5 || info.Activity.Denied != 3 {
+				t.Errorf("expected 5 requests and 3 denied, got %d and %d", info.Activity.Requests, info.Activity.Denied)
+			}
+
+			// Keys without decisions report none
+			info, err = b.GetInfo(ctx, "unseen")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Activity == nil || info.Activity.Requests != 0 || !info.Activity.LastDenied.IsZero() {
+				t.Errorf("expected empty activity, got %+v", info.Activity)
+			}
+		})
+	}
+}
+
+func TestKeyActivityDisabled(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithLimit(1)
+
+	inMemory, err := NewInMemoryBackend(options)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer inMemory.Close(ctx)
+	redis, server := newTestRedisBackend(t, options)
+
+	for name, b := range map[string]Backend{"memory": inMemory, "redis": redis} {
+		t.Run(name, func(t *testing.T) {
+			b.Take(ctx, "key", 1)
+			b.Take(ctx, "key", 1)
+
+			info, err := b.GetInfo(ctx, "key")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Activity != nil {
+				t.Errorf("expected no activity, got %+v", info.Activity)
+			}
+		})
+	}
+
+	if server.Exists(redis.keys.activity(redis.keys.bucket("key"))) {
+		t.Error("expected no activity to be stored in Redis")
+	}
+}
@@ -0,0 +1,557 @@
+package backend
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// ApproximateOptions configures the write-behind approximate backend
+type ApproximateOptions struct {
+	// SyncInterval is how often local counts are reconciled with the shared backend
+	SyncInterval time.Duration `json:"sync_interval"`
+
+	// SyncTokens triggers an early sync of a key once this many tokens were taken locally, 0 disables it
+	SyncTokens int64 `json:"sync_tokens"`
+
+	// Nodes is the number of instances sharing each limit, each one may spend its share between syncs
+	Nodes int `json:"nodes"`
+
+	// PrewarmKeys are fetched from the shared backend when the backend is created, so their first Takes find a share
+	// Pass the keys of a stored HotKeysReport to avoid a stampede of syncs when a busy instance starts
+	PrewarmKeys []string `json:"prewarm_keys,omitempty"`
+
+	// OnSyncError is called with the key and error of every sync, flush or prewarm that fails outside a Take, nil ignores them
+	// Failed counts are kept and written on the next sync, but callers should log or count failures to notice an outage
+	OnSyncError func(key string, err error) `json:"-"`
+}
+
+// prewarmRetention is how long a prewarmed key is kept and synced before its first Take
+const prewarmRetention = time.Minute
+
+// prewarmTimeout bounds how long NewApproximateBackend spends fetching PrewarmKeys
+const prewarmTimeout = 5 * time.Second
+
+// DefaultApproximateOptions returns default options for the approximate backend
+func DefaultApproximateOptions() *ApproximateOptions {
+	return &ApproximateOptions{
+		SyncInterval: 100 * time.Millisecond,
+		SyncTokens:   100,
+		Nodes:        1,
+	}
+}
+
+// Validate validates the approximate options
+func (o *ApproximateOptions) Validate() error {
+	if o.SyncInterval <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "sync_interval must be positive")
+	}
+
+	if o.SyncTokens < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "sync_tokens must not be negative")
+	}
+
+	if o.Nodes <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "nodes must be positive")
+	}
+
+	for _, key := range o.PrewarmKeys {
+		if err := validateKey(key); err != nil {
+			return errors.Wrap(err, "invalid prewarm key")
+		}
+	}
+
+	return nil
+}
+
+// approximateBackend counts Takes locally against this node's share of each limit
+// and writes them behind to a shared backend such as Redis
+// Between syncs a node can overspend by at most its share, in exchange for far fewer backend calls
+type approximateBackend struct {
+	remote  Backend
+	options *ApproximateOptions
+	keys    sync.Map
+	stop    chan struct{}
+	done    chan struct{}
+	mu      sync.Mutex
+	closed  atomic.Bool
+
+	// syncs tracks the early syncs started by Take, which Close waits for before flushing
+	syncs sync.WaitGroup
+}
+
+// localShare is the local view of one key
+type localShare struct {
+	mu        sync.Mutex
+	allowance int64
+	pending   int64
+	synced    bool
+	syncing   bool
+	touched   bool
+
+	// syncDone is closed when the sync in flight finishes
+	syncDone chan struct{}
+
+	// warmUntil keeps a prewarmed share that has not been taken from yet
+	warmUntil time.Time
+}
+
+// NewApproximateBackend wraps a shared backend with local counting and periodic sync
+func NewApproximateBackend(remote Backend, options *ApproximateOptions) (Backend, error) {
+	if remote == nil {
+		return nil, errors.Wrap(errors.ErrBackendUnavailable, "remote backend cannot be nil")
+	}
+
+	if options == nil {
+		options = DefaultApproximateOptions()
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+
+	backend := &approximateBackend{
+		remote:  remote,
+		options: options,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	// Keys that cannot be fetched now are fetched on their first Take as usual
+	ctx, cancel := context.WithTimeout(context.Background(), prewarmTimeout)
+	for _, key := range options.PrewarmKeys {
+		if err := backend.Prewarm(ctx, []string{key}); err != nil {
+			backend.reportSyncError(key, err)
+			break
+		}
+	}
+	cancel()
+
+	go backend.syncRoutine()
+
+	return backend, nil
+}
+
+// Prewarm fetches the shares of keys from the shared backend ahead of their first Take
+// Keys already held locally are left alone, and a prewarmed key is dropped when not taken from within a minute
+// It stops at the first key the shared backend fails to return
+func (a *approximateBackend) Prewarm(ctx context.Context, keys []string) error {
+	if a.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	for _, key := range keys {
+		if err := validateKey(key); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range keys {
+		// Check if context is cancelled
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "context cancelled")
+		default:
+		}
+
+		val, loaded := a.keys.LoadOrStore(key, &localShare{warmUntil: time.Now().Add(prewarmRetention)})
+		if loaded {
+			continue
+		}
+
+		if err := a.syncKey(ctx, key, val.(*localShare)); err != nil {
+			return errors.Wrapf(err, "failed to prewarm key %s", key)
+		}
+	}
+
+	return nil
+}
+
+// Take consumes tokens from this node's share, syncing first if the key has not been seen yet
+func (a *approximateBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	if a.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	val, _ := a.keys.LoadOrStore(key, &localShare{})
+	share := val.(*localShare)
+
+	share.mu.Lock()
+	for !share.synced {
+		share.mu.Unlock()
+		if err := a.awaitSync(ctx, key, share); err != nil {
+			return false, err
+		}
+		share.mu.Lock()
+	}
+
+	share.touched = true
+	if share.allowance < tokens {
+		share.mu.Unlock()
+		return false, nil
+	}
+
+	share.allowance -= tokens
+	share.pending += tokens
+	flush := a.options.SyncTokens > 0 && share.pending >= a.options.SyncTokens && !share.syncing
+	share.mu.Unlock()
+
+	if flush {
+		a.startSync(key, share)
+	}
+
+	return true, nil
+}
+
+// startSync syncs a key in the background, unless the backend is closing and flushes the pending count itself
+func (a *approximateBackend) startSync(key string, share *localShare) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed.Load() {
+		return
+	}
+
+	a.syncs.Add(1)
+	go func() {
+		defer a.syncs.Done()
+		if err := a.syncKey(context.Background(), key, share); err != nil {
+			a.reportSyncError(key, err)
+		}
+	}()
+}
+
+// TakeAll is passed straight to the shared backend so multi-key Takes stay exact
+func (a *approximateBackend) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	if a.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	return a.remote.TakeAll(ctx, keys, tokens)
+}
+
+// Reset clears the rate limit for a specific key and drops its local share
+func (a *approximateBackend) Reset(ctx context.Context, key string) error {
+	if a.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	a.keys.Delete(key)
+	return a.remote.Reset(ctx, key)
+}
+
+// GetInfo returns the state of a key from the shared backend
+// Tokens taken locally since the last sync are not yet reflected
+func (a *approximateBackend) GetInfo(ctx context.Context, key string) (*TokenInfo, error) {
+	if a.closed.Load() {
+		return nil, errors.ErrBackendClosed
+	}
+
+	return a.remote.GetInfo(ctx, key)
+}
+
+// SetLimit sets a custom limit for a specific key and drops its local share so it is recomputed
+func (a *approximateBackend) SetLimit(ctx context.Context, key string, limit int64, refill time.Duration) error {
+	if a.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := a.remote.SetLimit(ctx, key, limit, refill); err != nil {
+		return err
+	}
+
+	a.invalidate(key)
+	return nil
+}
+
+// Drain removes up to tokens from the balance of a key on the shared backend, leaving at least floor,
+// and drops its local share so it is recomputed. It fails unless the shared backend implements Drainer
+func (a *approximateBackend) Drain(ctx context.Context, key string, tokens int64, floor int64) error {
+	if a.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	drainer, ok := a.remote.(Drainer)
+	if !ok {
+		return errors.Wrap(errors.ErrBackendUnavailable, "remote backend does not support draining")
+	}
+
+	if err := drainer.Drain(ctx, key, tokens, floor); err != nil {
+		return err
+	}
+
+	a.invalidate(key)
+	return nil
+}
+
+// Block denies all Takes for a specific key until the duration expires
+// Other nodes observe the block on their next sync
+func (a *approximateBackend) Block(ctx context.Context, key string, duration time.Duration) error {
+	if a.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := a.remote.Block(ctx, key, duration); err != nil {
+		return err
+	}
+
+	a.invalidate(key)
+	return nil
+}
+
+// Unblock lifts a block on a specific key before it expires
+func (a *approximateBackend) Unblock(ctx context.Context, key string) error {
+	if a.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := a.remote.Unblock(ctx, key); err != nil {
+		return err
+	}
+
+	a.invalidate(key)
+	return nil
+}
+
+// Close flushes pending counts and closes the shared backend
+func (a *approximateBackend) Close(ctx context.Context) error {
+	return shutdownOnce(&a.mu, &a.closed, func() error {
+		close(a.stop)
+		<-a.done
+
+		// Early syncs have already moved their counts out of pending, so they must reach the shared backend first
+		a.syncs.Wait()
+		flushErr := a.syncAll(ctx, true)
+
+		return stderrors.Join(flushErr, a.remote.Close(ctx))
+	})
+}
+
+// HealthCheck performs a health check on the shared backend
+func (a *approximateBackend) HealthCheck(ctx context.Context) error {
+	if a.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	return a.remote.HealthCheck(ctx)
+}
+
+// SubscribeWakeups passes on the wakeups of the shared backend, nil when it does not announce any
+func (a *approximateBackend) SubscribeWakeups(key string) (<-chan struct{}, func()) {
+	notifier, ok := a.remote.(WakeupNotifier)
+	if !ok || a.closed.Load() {
+		return nil, func() {}
+	}
+
+	return notifier.SubscribeWakeups(key)
+}
+
+// invalidate forces the next Take on the key to sync with the shared backend
+func (a *approximateBackend) invalidate(key string) {
+	val, ok := a.keys.Load(key)
+	if !ok {
+		return
+	}
+
+	share := val.(*localShare)
+	share.mu.Lock()
+	share.synced = false
+	share.mu.Unlock()
+}
+
+// syncRoutine reconciles every key once per sync interval until the backend is closed
+func (a *approximateBackend) syncRoutine() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.options.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), a.options.SyncInterval)
+			a.syncAll(ctx, false)
+			cancel()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// syncAll reconciles every key and forgets keys that were not used since the previous sync
+// When flushOnly is set pending counts are written without refreshing allowances
+// Failures are reported to OnSyncError and returned together
+func (a *approximateBackend) syncAll(ctx context.Context, flushOnly bool) error {
+	var errs []error
+	a.keys.Range(func(k, v interface{}) bool {
+		key, share := k.(string), v.(*localShare)
+
+		share.mu.Lock()
+		idle := !share.touched && share.pending == 0 && !share.syncing && !time.Now().Before(share.warmUntil)
+		share.touched = false
+		share.mu.Unlock()
+
+		if idle {
+			a.keys.CompareAndDelete(key, share)
+			return true
+		}
+
+		var err error
+		if flushOnly {
+			err = a.flushKey(ctx, key, share)
+		} else {
+			err = a.syncKey(ctx, key, share)
+		}
+
+		if err != nil {
+			a.reportSyncError(key, err)
+			errs = append(errs, err)
+		}
+
+		return true
+	})
+
+	return stderrors.Join(errs...)
+}
+
+// reportSyncError passes a failed sync or flush of a key to OnSyncError
+func (a *approximateBackend) reportSyncError(key string, err error) {
+	if a.options.OnSyncError != nil {
+		a.options.OnSyncError(key, err)
+	}
+}
+
+// flushKey writes the pending count of a key to the shared backend
+// A count the shared backend fails or refuses to take is kept, so a later flush can write it again
+func (a *approximateBackend) flushKey(ctx context.Context, key string, share *localShare) error {
+	share.mu.Lock()
+	pending := share.pending
+	share.pending = 0
+	share.mu.Unlock()
+
+	if pending == 0 {
+		return nil
+	}
+
+	allowed, err := a.remote.Take(ctx, key, pending)
+	if err == nil && !allowed {
+		err = errors.Wrapf(errors.ErrRateLimitExceeded, "shared backend refused %d tokens taken locally", pending)
+	}
+	if err != nil {
+		share.mu.Lock()
+		share.pending += pending
+		share.mu.Unlock()
+		return errors.Wrapf(err, "failed to flush key %s", key)
+	}
+
+	return nil
+}
+
+// awaitSync syncs a key, or waits for the sync another caller has in flight to finish
+func (a *approximateBackend) awaitSync(ctx context.Context, key string, share *localShare) error {
+	share.mu.Lock()
+	syncing, done := share.syncing, share.syncDone
+	share.mu.Unlock()
+
+	if !syncing {
+		return a.syncKey(ctx, key, share)
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "context cancelled")
+	}
+}
+
+// syncKey writes the pending count of a key to the shared backend and refreshes this node's share
+func (a *approximateBackend) syncKey(ctx context.Context, key string, share *localShare) error {
+	share.mu.Lock()
+	if share.syncing {
+		share.mu.Unlock()
+		return nil
+	}
+	share.syncing = true
+	share.syncDone = make(chan struct{})
+	pending := share.pending
+	share.pending = 0
+	share.mu.Unlock()
+
+	defer func() {
+		share.mu.Lock()
+		share.syncing = false
+		close(share.syncDone)
+		share.mu.Unlock()
+	}()
+
+	exhausted := false
+	if pending > 0 {
+		allowed, err := a.remote.Take(ctx, key, pending)
+		if err != nil {
+			share.mu.Lock()
+			share.pending += pending
+			share.mu.Unlock()
+			return err
+		}
+
+		// The shared bucket could not cover what this node spent, stop until it refills
+		// The tokens were still spent, so they are kept and taken again on the next sync
+		exhausted = !allowed
+		if exhausted {
+			share.mu.Lock()
+			share.pending += pending
+			share.mu.Unlock()
+		}
+	}
+
+	info, err := a.remote.GetInfo(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	allowance := info.Tokens / int64(a.options.Nodes)
+	if exhausted || info.BlockedUntil.After(time.Now()) {
+		allowance = 0
+	}
+
+	share.mu.Lock()
+	defer share.mu.Unlock()
+
+	// No more than a full bucket is kept owing, so a lowered limit cannot keep the key exhausted for good
+	if exhausted {
+		share.pending = min(share.pending, info.MaxTokens)
+	}
+
+	// Tokens taken while the sync was in flight count against the new share
+	share.allowance = max(allowance-share.pending, 0)
+	share.synced = true
+
+	return nil
+}
+
+// String returns a string representation of the backend
+func (a *approximateBackend) String() string {
+	return fmt.Sprintf("ApproximateBackend{nodes=%d, sync_interval=%v, sync_tokens=%d}",
+		a.options.Nodes, a.options.SyncInterval, a.options.SyncTokens)
+}
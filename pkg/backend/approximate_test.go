@@ -0,0 +1,500 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// countingBackend records the Takes and Drains forwarded to the wrapped backend
+type countingBackend struct {
+	Backend
+
+	mu     sync.Mutex
+	takes  []int64
+	drains []int64
+}
+
+func (c *countingBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	c.mu.Lock()
+	c.takes = append(c.takes, tokens)
+	c.mu.Unlock()
+
+	return c.Backend.Take(ctx, key, tokens)
+}
+
+func (c *countingBackend) Drain(ctx context.Context, key string, tokens int64, floor int64) error {
+	c.mu.Lock()
+	c.drains = append(c.drains, tokens)
+	c.mu.Unlock()
+
+	return c.Backend.(Drainer).Drain(ctx, key, tokens, floor)
+}
+
+func (c *countingBackend) taken() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]int64(nil), c.takes...)
+}
+
+func (c *countingBackend) drained() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]int64(nil), c.drains...)
+}
+
+// newTestApproximateBackend wraps an in-memory backend with the given limit
+func newTestApproximateBackend(t *testing.T, limit int64, options *ApproximateOptions) (Backend, *countingBackend) {
+	t.Helper()
+
+	remote, err := NewInMemoryBackend(DefaultOptions().WithLimit(limit).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create remote backend: %v", err)
+	}
+
+	counting := &countingBackend{Backend: remote}
+	backend, err := NewApproximateBackend(counting, options)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close(context.Background()) })
+
+	return backend, counting
+}
+
+func TestNewApproximateBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		noRemote bool
+		options  *ApproximateOptions
+		wantErr  bool
+	}{
+		{
+			name:     "default options",
+			noRemote: false,
+			options:  nil,
+			wantErr:  false,
+		},
+		{
+			name:     "nil remote",
+			noRemote: true,
+			options:  nil,
+			wantErr:  true,
+		},
+		{
+			name:     "zero sync interval",
+			noRemote: false,
+			options:  &ApproximateOptions{SyncInterval: 0, Nodes: 1},
+			wantErr:  true,
+		},
+		{
+			name:     "negative sync tokens",
+			noRemote: false,
+			options:  &ApproximateOptions{SyncInterval: time.Second, SyncTokens: -1, Nodes: 1},
+			wantErr:  true,
+		},
+		{
+			name:     "zero nodes",
+			noRemote: false,
+			options:  &ApproximateOptions{SyncInterval: time.Second, Nodes: 0},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remote Backend
+			if !tt.noRemote {
+				remote, _ = NewInMemoryBackend(nil)
+			}
+
+			backend, err := NewApproximateBackend(remote, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if backend != nil {
+				backend.Close(context.Background())
+			}
+		})
+	}
+}
+
+func TestApproximateBackendTakeLocally(t *testing.T) {
+	ctx := context.Background()
+	backend, counting := newTestApproximateBackend(t, 10, &ApproximateOptions{
+		SyncInterval: time.Hour,
+		Nodes:        2,
+	})
+
+	for i := 0; i < 5; i++ {
+		allowed, err := backend.Take(ctx, "test_key", 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !allowed {
+			t.Errorf("expected take %d to be allowed within the node's share", i)
+		}
+	}
+
+	allowed, err := backend.Take(ctx, "test_key", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected take beyond the node's share to be denied")
+	}
+
+	if takes := counting.taken(); len(takes) != 0 {
+		t.Errorf("expected no takes forwarded before a sync, got %v", takes)
+	}
+}
+
+func TestApproximateBackendSyncTokens(t *testing.T) {
+	ctx := context.Background()
+	backend, counting := newTestApproximateBackend(t, 10, &ApproximateOptions{
+		SyncInterval: time.Hour,
+		SyncTokens:   3,
+		Nodes:        1,
+	})
+
+	for i := 0; i < 3; i++ {
+		backend.Take(ctx, "test_key", 1)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(counting.taken()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	takes := counting.taken()
+	if len(takes) != 1 || takes[0] != 3 {
+		t.Fatalf("expected one forwarded take of 3 tokens, got %v", takes)
+	}
+
+	info, err := counting.GetInfo(ctx, "test_key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 7 {
+		t.Errorf("expected 7 tokens in the shared backend, got %d", info.Tokens)
+	}
+}
+
+func TestApproximateBackendSyncInterval(t *testing.T) {
+	ctx := context.Background()
+	backend, counting := newTestApproximateBackend(t, 10, &ApproximateOptions{
+		SyncInterval: 10 * time.Millisecond,
+		Nodes:        1,
+	})
+
+	backend.Take(ctx, "test_key", 4)
+
+	// Another node spends the rest of the shared bucket
+	counting.Backend.Take(ctx, "test_key", 6)
+
+	deadline := time.Now().Add(time.Second)
+	for len(counting.taken()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	allowed, err := backend.Take(ctx, "test_key", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected take to be denied once the shared bucket is empty")
+	}
+}
+
+func TestApproximateBackendBlock(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newTestApproximateBackend(t, 10, &ApproximateOptions{
+		SyncInterval: time.Hour,
+		Nodes:        1,
+	})
+
+	backend.Take(ctx, "test_key", 1)
+
+	if err := backend.Block(ctx, "test_key", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	allowed, _ := backend.Take(ctx, "test_key", 1)
+	if allowed {
+		t.Error("expected blocked key to be denied")
+	}
+
+	if err := backend.Unblock(ctx, "test_key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	allowed, _ = backend.Take(ctx, "test_key", 1)
+	if !allowed {
+		t.Error("expected unblocked key to be allowed")
+	}
+}
+
+func TestApproximateBackendCloseFlushes(t *testing.T) {
+	ctx := context.Background()
+	backend, counting := newTestApproximateBackend(t, 10, &ApproximateOptions{
+		SyncInterval: time.Hour,
+		Nodes:        1,
+	})
+
+	backend.Take(ctx, "test_key", 2)
+	backend.Take(ctx, "test_key", 3)
+
+	if err := backend.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	takes := counting.taken()
+	if len(takes) != 1 || takes[0] != 5 {
+		t.Errorf("expected one forwarded take of 5 tokens, got %v", takes)
+	}
+
+	if _, err := backend.Take(ctx, "test_key", 1); err == nil {
+		t.Error("expected error after close")
+	}
+}
+
+func TestApproximateBackendPrewarm(t *testing.T) {
+	ctx := context.Background()
+	remote, err := NewInMemoryBackend(DefaultOptions().WithLimit(10).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create remote backend: %v", err)
+	}
+
+	backend, err := NewApproximateBackend(remote, &ApproximateOptions{
+		SyncInterval: time.Hour,
+		Nodes:        1,
+		PrewarmKeys:  []string{"warm"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(ctx)
+
+	// Spend on the shared backend behind the local cache
+	remote.Take(ctx, "warm", 4)
+	remote.Take(ctx, "cold", 4)
+
+	// The prewarmed share was fetched before the spend, a cold key syncs on its first Take and sees it
+	if allowed, _ := backend.Take(ctx, "warm", 10); !allowed {
+		t.Error("expected take from the prewarmed share to be allowed")
+	}
+	if allowed, _ := backend.Take(ctx, "cold", 10); allowed {
+		t.Error("expected take from the cold key to be denied")
+	}
+
+	// Prewarmed keys outlive syncs until their retention ends
+	approximate := backend.(*approximateBackend)
+	if err := approximate.Prewarm(ctx, []string{"later"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	approximate.syncAll(ctx, false)
+	approximate.syncAll(ctx, false)
+	if _, ok := approximate.keys.Load("later"); !ok {
+		t.Error("expected prewarmed key to be kept")
+	}
+
+	if err := approximate.Prewarm(ctx, []string{""}); err == nil {
+		t.Error("expected error for an empty key")
+	}
+
+	if _, err := NewApproximateBackend(remote, &ApproximateOptions{SyncInterval: time.Second, Nodes: 1, PrewarmKeys: []string{""}}); err == nil {
+		t.Error("expected error for an empty prewarm key")
+	}
+}
+
+// slowInfoBackend delays GetInfo so syncs stay in flight
+type slowInfoBackend struct {
+	Backend
+	delay time.Duration
+}
+
+func (s *slowInfoBackend) GetInfo(ctx context.Context, key string) (*TokenInfo, error) {
+	time.Sleep(s.delay)
+	return s.Backend.GetInfo(ctx, key)
+}
+
+func TestApproximateBackendConcurrentFirstTakes(t *testing.T) {
+	ctx := context.Background()
+	remote, err := NewInMemoryBackend(DefaultOptions().WithLimit(10).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create remote backend: %v", err)
+	}
+
+	backend, err := NewApproximateBackend(&slowInfoBackend{Backend: remote, delay: 50 * time.Millisecond}, &ApproximateOptions{
+		SyncInterval: time.Hour,
+		Nodes:        1,
+	})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(ctx)
+
+	// Takes arriving while the first one fetches the share wait for it instead of finding none
+	var wg sync.WaitGroup
+	results := make(chan bool, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allowed, err := backend.Take(ctx, "test_key", 1)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			results <- allowed
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	for allowed := range results {
+		if !allowed {
+			t.Error("expected every take within the share to be allowed")
+		}
+	}
+}
+
+func TestApproximateBackendRefusedCountsAreKept(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var reported []string
+	backend, counting := newTestApproximateBackend(t, 10, &ApproximateOptions{
+		SyncInterval: time.Hour,
+		Nodes:        1,
+		OnSyncError: func(key string, err error) {
+			mu.Lock()
+			reported = append(reported, key)
+			mu.Unlock()
+		},
+	})
+	approximate := backend.(*approximateBackend)
+
+	backend.Take(ctx, "test_key", 4)
+
+	// Another node spends the shared bucket, so the count of this one is refused
+	counting.Backend.Take(ctx, "test_key", 8)
+
+	approximate.syncAll(ctx, false)
+	approximate.syncAll(ctx, false)
+
+	takes := counting.taken()
+	if len(takes) != 2 || takes[0] != 4 || takes[1] != 4 {
+		t.Errorf("expected the refused count to be taken again on the next sync, got %v", takes)
+	}
+
+	if allowed, _ := backend.Take(ctx, "test_key", 1); allowed {
+		t.Error("expected take to be denied while the shared bucket owes the count")
+	}
+
+	if err := backend.Close(ctx); err == nil {
+		t.Error("expected close to report the refused flush")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 1 || reported[0] != "test_key" {
+		t.Errorf("expected the refused flush of test_key to be reported, got %v", reported)
+	}
+}
+
+// slowTakeBackend delays Take and records the tokens of the Takes the wrapped backend allowed
+type slowTakeBackend struct {
+	Backend
+	delay time.Duration
+
+	mu      sync.Mutex
+	allowed []int64
+}
+
+func (s *slowTakeBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	time.Sleep(s.delay)
+
+	allowed, err := s.Backend.Take(ctx, key, tokens)
+	if err == nil && allowed {
+		s.mu.Lock()
+		s.allowed = append(s.allowed, tokens)
+		s.mu.Unlock()
+	}
+	return allowed, err
+}
+
+func TestApproximateBackendCloseWaitsForEarlySyncs(t *testing.T) {
+	ctx := context.Background()
+	remote, err := NewInMemoryBackend(DefaultOptions().WithLimit(10).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create remote backend: %v", err)
+	}
+
+	slow := &slowTakeBackend{Backend: remote, delay: 50 * time.Millisecond}
+	backend, err := NewApproximateBackend(slow, &ApproximateOptions{
+		SyncInterval: time.Hour,
+		SyncTokens:   3,
+		Nodes:        1,
+	})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	// The third take starts an early sync, which is still writing when Close runs
+	for i := 0; i < 3; i++ {
+		backend.Take(ctx, "test_key", 1)
+	}
+	if err := backend.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	if len(slow.allowed) != 1 || slow.allowed[0] != 3 {
+		t.Errorf("expected the early sync of 3 tokens to reach the shared backend before it closed, got %v", slow.allowed)
+	}
+}
+
+// failingInfoBackend fails GetInfo, recording whether the context carried a deadline
+type failingInfoBackend struct {
+	Backend
+	deadline bool
+}
+
+func (f *failingInfoBackend) GetInfo(ctx context.Context, key string) (*TokenInfo, error) {
+	_, f.deadline = ctx.Deadline()
+	return nil, errors.ErrBackendUnavailable
+}
+
+func TestApproximateBackendPrewarmFailure(t *testing.T) {
+	remote, err := NewInMemoryBackend(DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create remote backend: %v", err)
+	}
+
+	var reported []string
+	failing := &failingInfoBackend{Backend: remote}
+	backend, err := NewApproximateBackend(failing, &ApproximateOptions{
+		SyncInterval: time.Hour,
+		Nodes:        1,
+		PrewarmKeys:  []string{"a", "b"},
+		OnSyncError:  func(key string, err error) { reported = append(reported, key) },
+	})
+	if err != nil {
+		t.Fatalf("expected a failed prewarm not to fail the backend, got %v", err)
+	}
+	defer backend.Close(context.Background())
+
+	if !failing.deadline {
+		t.Error("expected the prewarm to be bounded by a deadline")
+	}
+	if len(reported) != 1 || reported[0] != "a" {
+		t.Errorf("expected the failed prewarm of a to be reported once, got %v", reported)
+	}
+}
@@ -2,6 +2,8 @@ package backend
 
 import (
 	"context"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/devrob-go/go-rate-limiter/pkg/errors"
@@ -12,7 +14,11 @@ type Backend interface {
 	// Take attempts to consume the specified number of tokens from the bucket
 	// Returns true if tokens were successfully consumed, false if rate limit exceeded
 	// The error is returned if there's a backend failure
-	Take(ctx context.Context, key string, tokens int) (bool, error)
+	Take(ctx context.Context, key string, tokens int64) (bool, error)
+
+	// TakeAll atomically consumes the specified number of tokens from every listed bucket
+	// Tokens are only consumed if all buckets have enough, otherwise none are consumed
+	TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error)
 
 	// Reset clears the rate limit for a specific key
 	Reset(ctx context.Context, key string) error
@@ -20,8 +26,18 @@ type Backend interface {
 	// GetInfo returns information about the current state of a key
 	GetInfo(ctx context.Context, key string) (*TokenInfo, error)
 
-	// SetLimit sets a custom limit for a specific key
-	SetLimit(ctx context.Context, key string, limit int, refill time.Duration) error
+	// SetLimit sets a custom limit for a specific key, together with MaxDebt at most MaxLimit
+	// The in-memory backend cannot raise a key it tracks in a packed bucket above 16,777,215 tokens unless
+	// OptimisticBuckets is set, a new key gets an optimistic bucket when its limit needs one
+	// The current balance is kept after any refill owed under the old rate, clamped to the new limit
+	// Setting the limit a key already has leaves its bucket untouched
+	SetLimit(ctx context.Context, key string, limit int64, refill time.Duration) error
+
+	// Block denies all Takes for a specific key until the duration expires
+	Block(ctx context.Context, key string, duration time.Duration) error
+
+	// Unblock lifts a block on a specific key before it expires
+	Unblock(ctx context.Context, key string) error
 
 	// Close gracefully shuts down the backend
 	Close(ctx context.Context) error
@@ -30,24 +46,281 @@ type Backend interface {
 	HealthCheck(ctx context.Context) error
 }
 
+// KeyCounter is implemented by backends that can report how many keys they track
+type KeyCounter interface {
+	// KeyCount returns the number of keys currently tracked by the backend
+	KeyCount(ctx context.Context) (int, error)
+}
+
+// KeyLister is implemented by backends that can enumerate the keys they track
+type KeyLister interface {
+	// Keys returns the tracked keys matching a glob pattern, an empty pattern matches every key
+	Keys(ctx context.Context, pattern string) ([]string, error)
+}
+
+// Dumper is implemented by backends that can export their bucket state to a portable file and import it back
+type Dumper interface {
+	// Dump writes the buckets and blocks of the keys matching a glob pattern to w, returning how many buckets it wrote
+	Dump(ctx context.Context, w io.Writer, pattern string) (int, error)
+
+	// Restore writes the buckets and blocks read from a dump, replacing keys already present, and returns how many buckets it wrote
+	Restore(ctx context.Context, r io.Reader) (int, error)
+}
+
+// LimitTaker is implemented by backends that can set a custom limit and take tokens in one call
+type LimitTaker interface {
+	// TakeWithLimit stores limit and refill as the key's limit if it differs, then consumes tokens from it
+	TakeWithLimit(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration) (bool, error)
+}
+
+// IdempotentTaker is implemented by backends that can recognise retries of a take by its request ID
+type IdempotentTaker interface {
+	// TakeOnce takes tokens as Take does, unless a take with the same request ID on the key was admitted within the
+	// idempotency window, which is admitted again without consuming tokens. Retries of a denied take are decided afresh
+	TakeOnce(ctx context.Context, key string, requestID string, tokens int64) (bool, error)
+}
+
+// Prewarmer is implemented by backends caching shared state locally, which can fetch keys ahead of their first Take
+type Prewarmer interface {
+	// Prewarm fetches the state of keys so their first Takes are served from the local cache
+	Prewarm(ctx context.Context, keys []string) error
+}
+
+// RefillStrategySetter is implemented by backends that can refill buckets other than linearly
+type RefillStrategySetter interface {
+	// SetRefillStrategy sets how the bucket of a key refills, nil restores linear refills
+	// Refill owed under the old strategy is applied first, and the strategy lasts as long as the bucket
+	SetRefillStrategy(ctx context.Context, key string, strategy RefillStrategy) error
+}
+
+// Scheduler is implemented by backends that can reserve tokens from future refills
+type Scheduler interface {
+	// Schedule consumes tokens even when the balance cannot cover them yet, returning when the caller may proceed
+	// Tokens the balance lacks are reserved from the next refills, so later takes and schedules queue up behind them
+	// It fails with ErrInvalidTokens when tokens exceed the limit plus the debt, and with a RateLimitError while the key is blocked
+	Schedule(ctx context.Context, key string, tokens int64) (time.Time, error)
+}
+
+// Drainer is implemented by backends that can lower a balance without taking from it
+// Backends wrapping others use it to settle tokens already granted elsewhere
+type Drainer interface {
+	// Drain removes up to tokens from the balance of a key in one atomic step, leaving at least floor
+	// Unlike Take it spends no grace tokens or burst pool, ignores blocks and is not counted as a request
+	Drain(ctx context.Context, key string, tokens int64, floor int64) error
+}
+
+// WakeupNotifier is implemented by backends that announce when tokens may become available early
+// Wait uses it to sleep until the next refill instead of polling the backend
+type WakeupNotifier interface {
+	// SubscribeWakeups returns a channel signalled when tokens for the key may be available, and a function to unsubscribe
+	// A nil channel means wakeups are unavailable
+	SubscribeWakeups(key string) (<-chan struct{}, func())
+}
+
+// EvictionNotifier is implemented by backends whose cleanup drops idle keys, to let dependent caches follow
+type EvictionNotifier interface {
+	// SubscribeEvictions calls fn with every key the cleanup drops, and returns a function to unsubscribe
+	// fn runs on the cleanup goroutine, or within the call running an inline cleanup, so it should return quickly
+	SubscribeEvictions(fn func(key string)) func()
+}
+
+// StatsReporter is implemented by backends that aggregate the usage of all their keys
+type StatsReporter interface {
+	// Stats returns totals across every key, computed without enumerating them
+	Stats(ctx context.Context) (Stats, error)
+}
+
+// Stats describes the usage of a backend across all its keys
+type Stats struct {
+	// Keys is the number of keys tracked
+	Keys int64 `json:"keys"`
+
+	// Allowed and Denied count the takes decided since the counts started
+	Allowed uint64 `json:"allowed"`
+	Denied  uint64 `json:"denied"`
+
+	// TokensConsumed estimates the tokens handed out over the sliding Window before now
+	TokensConsumed int64         `json:"tokens_consumed"`
+	Window         time.Duration `json:"window"`
+
+	// Evictions counts the buckets dropped to stay within a memory cap
+	Evictions uint64 `json:"evictions"`
+}
+
+// Leaser is implemented by backends that share concurrency slots between instances as expiring leases
+// A lease that is not renewed expires on its own, so slots held by crashed processes are reclaimed
+type Leaser interface {
+	// AcquireLease takes one of limit slots of the key for ttl, returning an empty ID when none is free
+	AcquireLease(ctx context.Context, key string, limit int, ttl time.Duration) (string, error)
+
+	// RenewLease extends a lease to ttl from now, returning false once it has expired or was released
+	RenewLease(ctx context.Context, key string, id string, ttl time.Duration) (bool, error)
+
+	// ReleaseLease frees a lease before it expires
+	ReleaseLease(ctx context.Context, key string, id string) error
+}
+
+// Lease is a concurrency slot held on a key
+type Lease struct {
+	ID string `json:"id"`
+
+	// Holder identifies the backend instance that acquired the lease
+	Holder string `json:"holder"`
+
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MemoryReporter is implemented by backends that account for the memory their buckets use
+type MemoryReporter interface {
+	// MemoryStats returns the approximate memory used by the backend and how many buckets it evicted
+	MemoryStats(ctx context.Context) (MemoryStats, error)
+}
+
+// MemoryStats describes the approximate memory used by a backend
+type MemoryStats struct {
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+	Evictions uint64 `json:"evictions"`
+}
+
 // TokenInfo contains information about the current state of a token bucket
+// Tokens is negative while the bucket is in debt
 type TokenInfo struct {
 	Key        string        `json:"key"`
-	Tokens     int           `json:"tokens"`
-	MaxTokens  int           `json:"max_tokens"`
+	Tokens     int64         `json:"tokens"`
+	MaxTokens  int64         `json:"max_tokens"`
 	RefillRate time.Duration `json:"refill_rate"`
 	LastRefill time.Time     `json:"last_refill"`
 	NextRefill time.Time     `json:"next_refill"`
 	ResetTime  time.Time     `json:"reset_time"`
+
+	// Strategy is how the bucket refills, nil when it refills linearly
+	Strategy RefillStrategy `json:"refill_strategy,omitempty"`
+
+	// BurstCredits is what the burst pool of the key holds, zero unless Options.BurstPoolTokens is set
+	BurstCredits int64 `json:"burst_credits,omitempty"`
+
+	// BlockedUntil is the time at which an active block expires, zero if not blocked
+	BlockedUntil time.Time `json:"blocked_until,omitempty"`
+
+	// Leases are the concurrency slots held on the key, on backends implementing Leaser
+	Leases []Lease `json:"leases,omitempty"`
+
+	// Activity is the recent decisions on the key, nil unless Options.KeyActivity is set
+	Activity *KeyActivity `json:"activity,omitempty"`
 }
 
+// MaxLimit is the largest limit plus MaxDebt a bucket can hold
+// In-memory buckets pack up to 16,777,215 tokens into one word, larger ones are kept as optimistic buckets,
+// and Redis keeps counts exact up to 10^14 tokens
+const MaxLimit = maxOptimisticTokens
+
 // Options contains configuration options for backends
 type Options struct {
-	DefaultLimit    int           `json:"default_limit"`
+	// DefaultLimit is the limit of keys without their own, together with MaxDebt at most MaxLimit
+	DefaultLimit    int64         `json:"default_limit"`
 	DefaultRefill   time.Duration `json:"default_refill"`
-	DefaultBurst    int           `json:"default_burst"`
+	DefaultBurst    int64         `json:"default_burst"`
 	MaxKeys         int           `json:"max_keys"`
 	CleanupInterval time.Duration `json:"cleanup_interval"`
+
+	// ShardCount is the number of shards the in-memory backend splits its keys across, 0 uses the default of 32
+	ShardCount int `json:"shard_count,omitempty"`
+
+	// SharedCleanup runs a leader-elected cleanup every CleanupInterval on shared backends such as Redis
+	// It prunes buckets and block markers under KeyPrefix that lost their expiry, one instance per interval
+	// It requires a KeyPrefix, so keys of other applications sharing the database are never deleted
+	SharedCleanup bool `json:"shared_cleanup,omitempty"`
+
+	// SnapshotPath is the file the in-memory backend loads on start and saves on Close, empty disables snapshots
+	SnapshotPath string `json:"snapshot_path,omitempty"`
+
+	// InlineCleanup makes the in-memory backend clean up from within its calls instead of a goroutine and ticker
+	// Once CleanupInterval has passed, each call sweeps one shard until all are swept, for WASM and serverless runtimes
+	// It cannot be combined with RefillWheelTick, whose wheel runs on its own goroutine
+	InlineCleanup bool `json:"inline_cleanup,omitempty"`
+
+	// RefillWheelTick enables a timer wheel in the in-memory backend with the given resolution, 0 disables it
+	// The wheel refills buckets with waiters at their refill instants and wakes the waiters right away
+	RefillWheelTick time.Duration `json:"refill_wheel_tick,omitempty"`
+
+	// OptimisticBuckets makes the in-memory backend keep each bucket as immutable versioned values swapped by CAS
+	// Reads such as GetInfo never write and see the balance and limits of one version, at the cost of an allocation per take
+	// Every bucket can then be raised up to MaxLimit, packed buckets hold up to 16,777,215 tokens
+	OptimisticBuckets bool `json:"optimistic_buckets,omitempty"`
+
+	// MaxMemoryBytes caps the approximate memory used by in-memory buckets, 0 means unlimited
+	// Least recently refilled buckets are evicted once the cap is exceeded
+	MaxMemoryBytes int64 `json:"max_memory_bytes,omitempty"`
+
+	// PoolSize is the maximum number of Redis connections, 0 keeps the client default of 10 per CPU
+	PoolSize int `json:"pool_size,omitempty"`
+
+	// MinIdleConns is the number of idle Redis connections kept open for bursts
+	MinIdleConns int `json:"min_idle_conns,omitempty"`
+
+	// MaxRetries is how often a failed Redis command is retried, 0 keeps the client default of 3 and -1 disables retries
+	MaxRetries int `json:"max_retries,omitempty"`
+
+	// MinRetryBackoff and MaxRetryBackoff bound the jittered exponential backoff between Redis retries
+	// 0 keeps the client defaults of 8ms and 512ms, -1 retries without waiting
+	MinRetryBackoff time.Duration `json:"min_retry_backoff,omitempty"`
+	MaxRetryBackoff time.Duration `json:"max_retry_backoff,omitempty"`
+
+	// DialTimeout bounds opening a Redis connection, 0 keeps the client default of 5 seconds
+	DialTimeout time.Duration `json:"dial_timeout,omitempty"`
+
+	// OperationTimeout bounds each Redis operation including its retries, 0 leaves it to the caller's context
+	// It also sets the read and write timeout of every connection
+	OperationTimeout time.Duration `json:"operation_timeout,omitempty"`
+
+	// KeyHashSecret makes the Redis backend store every key as its HMAC-SHA256 under the secret, empty stores keys as given
+	// Callers keep passing the original keys, but Keys lists the hashes
+	KeyHashSecret []byte `json:"-"`
+
+	// KeyPrefix is put before every key the Redis backend stores, so it shares a database with other data
+	// Keys, Dump, Restore and the shared cleanup only touch keys under it
+	// Empty keeps the legacy layout of earlier versions, with buckets under their bare keys, see redisKeys
+	KeyPrefix string `json:"key_prefix,omitempty"`
+
+	// TLS enables TLS for Redis connections, nil keeps the scheme of the URL, where rediss:// means TLS with defaults
+	TLS *TLSOptions `json:"tls,omitempty"`
+
+	// MaxDebt lets a Take that finds too few tokens succeed as long as the balance stays within MaxDebt below zero
+	// Refills pay off the debt before new tokens accrue, 0 disables borrowing
+	MaxDebt int64 `json:"max_debt,omitempty"`
+
+	// GraceTokens is a budget new keys spend before their balance during the GracePeriod after they are first seen
+	// It lets expected first bursts, such as onboarding traffic, through unthrottled, 0 disables it
+	// Single-key Takes use it, TakeAll does not
+	GraceTokens int64         `json:"grace_tokens,omitempty"`
+	GracePeriod time.Duration `json:"grace_period,omitempty"`
+
+	// BurstPoolTokens caps a burst pool kept per key apart from its bucket, which Takes the bucket denies are spent from
+	// The pool regains one credit every BurstPoolRefill, usually far slower than the bucket refills, so credits build up
+	// while a key stays under its limit. Single-key Takes use it, TakeAll and Schedule do not, 0 disables it
+	BurstPoolTokens int64         `json:"burst_pool_tokens,omitempty"`
+	BurstPoolRefill time.Duration `json:"burst_pool_refill,omitempty"`
+
+	// IdempotencyWindow is how long backends remember the request IDs of admitted TakeOnce calls, 0 uses a minute
+	IdempotencyWindow time.Duration `json:"idempotency_window,omitempty"`
+
+	// KeyActivity makes backends count the requests and denials of each key, which GetInfo then reports
+	// It costs a counter per active key, and on Redis a write per decision
+	KeyActivity bool `json:"key_activity,omitempty"`
+
+	// Username and Password authenticate to Redis, as an ACL user when Username is set
+	// Empty keeps any credentials in the URL
+	Username string `json:"username,omitempty"`
+	Password string `json:"-"`
+
+	// ReadReplicas are URLs of Redis replicas of the primary, which hedged GetInfo reads are sent to in turn
+	// They share the pool, timeout, auth and TLS settings of the primary
+	ReadReplicas []string `json:"read_replicas,omitempty"`
+
+	// HedgeDelay is how long GetInfo waits on the primary before asking a replica too and taking the first answer
+	// A failed primary read is hedged at once, 0 disables hedging
+	HedgeDelay time.Duration `json:"hedge_delay,omitempty"`
 }
 
 // DefaultOptions returns default options for backends
@@ -58,6 +331,7 @@ func DefaultOptions() *Options {
 		DefaultBurst:    10,
 		MaxKeys:         10000,
 		CleanupInterval: 5 * time.Minute,
+		ShardCount:      defaultShardCount,
 	}
 }
 
@@ -83,11 +357,127 @@ func (o *Options) Validate() error {
 		return errors.Wrap(errors.ErrInvalidTokens, "cleanup_interval must be positive")
 	}
 
+	if o.ShardCount < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "shard_count must not be negative")
+	}
+
+	if o.RefillWheelTick < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "refill_wheel_tick must not be negative")
+	}
+
+	if o.InlineCleanup && o.RefillWheelTick > 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "inline_cleanup cannot be combined with refill_wheel_tick")
+	}
+
+	if o.MaxMemoryBytes < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "max_memory_bytes must not be negative")
+	}
+
+	if o.PoolSize < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "pool_size must not be negative")
+	}
+
+	if o.MinIdleConns < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "min_idle_conns must not be negative")
+	}
+
+	if o.MaxRetries < -1 {
+		return errors.Wrap(errors.ErrInvalidTokens, "max_retries must be -1 or more")
+	}
+
+	if o.MinRetryBackoff < -1 || o.MaxRetryBackoff < -1 {
+		return errors.Wrap(errors.ErrInvalidTokens, "retry backoffs must be -1 or more")
+	}
+
+	if o.MinRetryBackoff > 0 && o.MaxRetryBackoff > 0 && o.MinRetryBackoff > o.MaxRetryBackoff {
+		return errors.Wrap(errors.ErrInvalidTokens, "min_retry_backoff must not exceed max_retry_backoff")
+	}
+
+	if o.DialTimeout < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "dial_timeout must not be negative")
+	}
+
+	if o.OperationTimeout < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "operation_timeout must not be negative")
+	}
+
+	if len(o.KeyHashSecret) > 0 && len(o.KeyHashSecret) < MinKeyHashSecret {
+		return errors.Wrapf(errors.ErrInvalidTokens, "key_hash_secret must be at least %d bytes", MinKeyHashSecret)
+	}
+
+	if strings.ContainsAny(o.KeyPrefix, "{}") {
+		return errors.Wrap(errors.ErrInvalidTokens, "key_prefix must not contain braces")
+	}
+
+	if o.SharedCleanup && o.KeyPrefix == "" {
+		return errors.Wrap(errors.ErrInvalidTokens, "shared_cleanup requires key_prefix")
+	}
+
+	if o.TLS != nil {
+		if err := o.TLS.validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.MaxDebt < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "max_debt must not be negative")
+	}
+
+	if o.DefaultLimit > MaxLimit-o.MaxDebt {
+		return errors.Wrapf(errors.ErrInvalidTokens, "default_limit plus max_debt must not exceed %d", int64(MaxLimit))
+	}
+
+	if o.GraceTokens < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "grace_tokens must not be negative")
+	}
+
+	if o.GracePeriod < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "grace_period must not be negative")
+	}
+
+	if (o.GraceTokens == 0) != (o.GracePeriod == 0) {
+		return errors.Wrap(errors.ErrInvalidTokens, "grace_tokens and grace_period must be set together")
+	}
+
+	if o.BurstPoolTokens < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "burst_pool_tokens must not be negative")
+	}
+
+	if o.BurstPoolRefill < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "burst_pool_refill must not be negative")
+	}
+
+	if (o.BurstPoolTokens == 0) != (o.BurstPoolRefill == 0) {
+		return errors.Wrap(errors.ErrInvalidTokens, "burst_pool_tokens and burst_pool_refill must be set together")
+	}
+
+	if o.IdempotencyWindow < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "idempotency_window must not be negative")
+	}
+
+	if o.Username != "" && o.Password == "" {
+		return errors.Wrap(errors.ErrInvalidTokens, "username requires a password")
+	}
+
+	if o.HedgeDelay < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "hedge_delay must not be negative")
+	}
+
+	if o.HedgeDelay > 0 && len(o.ReadReplicas) == 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "hedge_delay requires read_replicas")
+	}
+
+	for _, replica := range o.ReadReplicas {
+		if replica == "" {
+			return errors.Wrap(errors.ErrInvalidTokens, "read_replicas must not contain empty URLs")
+		}
+	}
+
 	return nil
 }
 
 // WithLimit returns new options with custom limit
-func (o *Options) WithLimit(limit int) *Options {
+func (o *Options) WithLimit(limit int64) *Options {
 	newOpts := *o
 	newOpts.DefaultLimit = limit
 	return &newOpts
@@ -101,8 +491,168 @@ func (o *Options) WithRefill(refill time.Duration) *Options {
 }
 
 // WithBurst returns new options with custom burst
-func (o *Options) WithBurst(burst int) *Options {
+func (o *Options) WithBurst(burst int64) *Options {
 	newOpts := *o
 	newOpts.DefaultBurst = burst
 	return &newOpts
 }
+
+// WithShardCount returns new options with a custom in-memory shard count
+func (o *Options) WithShardCount(count int) *Options {
+	newOpts := *o
+	newOpts.ShardCount = count
+	return &newOpts
+}
+
+// WithSharedCleanup returns new options with the shared cleanup job enabled or disabled
+func (o *Options) WithSharedCleanup(enabled bool) *Options {
+	newOpts := *o
+	newOpts.SharedCleanup = enabled
+	return &newOpts
+}
+
+// WithSnapshotPath returns new options persisting in-memory state to the given file
+func (o *Options) WithSnapshotPath(path string) *Options {
+	newOpts := *o
+	newOpts.SnapshotPath = path
+	return &newOpts
+}
+
+// WithInlineCleanup returns new options with the in-memory cleanup run inline in calls instead of a goroutine
+func (o *Options) WithInlineCleanup(enabled bool) *Options {
+	newOpts := *o
+	newOpts.InlineCleanup = enabled
+	return &newOpts
+}
+
+// WithRefillWheel returns new options enabling the in-memory refill timer wheel with the given resolution
+func (o *Options) WithRefillWheel(tick time.Duration) *Options {
+	newOpts := *o
+	newOpts.RefillWheelTick = tick
+	return &newOpts
+}
+
+// WithOptimisticBuckets returns new options with optimistic in-memory buckets enabled or disabled
+func (o *Options) WithOptimisticBuckets(enabled bool) *Options {
+	newOpts := *o
+	newOpts.OptimisticBuckets = enabled
+	return &newOpts
+}
+
+// WithMaxMemory returns new options capping the approximate memory used by in-memory buckets
+func (o *Options) WithMaxMemory(bytes int64) *Options {
+	newOpts := *o
+	newOpts.MaxMemoryBytes = bytes
+	return &newOpts
+}
+
+// WithPool returns new options with a custom Redis connection pool size and number of idle connections
+func (o *Options) WithPool(size, minIdle int) *Options {
+	newOpts := *o
+	newOpts.PoolSize = size
+	newOpts.MinIdleConns = minIdle
+	return &newOpts
+}
+
+// WithMaxRetries returns new options with a custom number of Redis command retries
+func (o *Options) WithMaxRetries(retries int) *Options {
+	newOpts := *o
+	newOpts.MaxRetries = retries
+	return &newOpts
+}
+
+// WithRetryBackoff returns new options with custom bounds on the backoff between Redis command retries
+func (o *Options) WithRetryBackoff(min, max time.Duration) *Options {
+	newOpts := *o
+	newOpts.MinRetryBackoff = min
+	newOpts.MaxRetryBackoff = max
+	return &newOpts
+}
+
+// WithDialTimeout returns new options with a custom Redis dial timeout
+func (o *Options) WithDialTimeout(timeout time.Duration) *Options {
+	newOpts := *o
+	newOpts.DialTimeout = timeout
+	return &newOpts
+}
+
+// WithOperationTimeout returns new options bounding each Redis operation by the timeout
+func (o *Options) WithOperationTimeout(timeout time.Duration) *Options {
+	newOpts := *o
+	newOpts.OperationTimeout = timeout
+	return &newOpts
+}
+
+// WithKeyHashing returns new options storing keys in Redis as their HMAC-SHA256 under the secret
+func (o *Options) WithKeyHashing(secret []byte) *Options {
+	newOpts := *o
+	newOpts.KeyHashSecret = secret
+	return &newOpts
+}
+
+// WithKeyPrefix returns new options storing every Redis key under the prefix
+func (o *Options) WithKeyPrefix(prefix string) *Options {
+	newOpts := *o
+	newOpts.KeyPrefix = prefix
+	return &newOpts
+}
+
+// WithTLS returns new options connecting to Redis over TLS
+func (o *Options) WithTLS(tls *TLSOptions) *Options {
+	newOpts := *o
+	newOpts.TLS = tls
+	return &newOpts
+}
+
+// WithMaxDebt returns new options letting buckets borrow up to debt tokens
+func (o *Options) WithMaxDebt(debt int64) *Options {
+	newOpts := *o
+	newOpts.MaxDebt = debt
+	return &newOpts
+}
+
+// WithGrace returns new options letting new keys take up to tokens for the period after they are first seen
+func (o *Options) WithGrace(tokens int64, period time.Duration) *Options {
+	newOpts := *o
+	newOpts.GraceTokens = tokens
+	newOpts.GracePeriod = period
+	return &newOpts
+}
+
+// WithBurstPool returns new options giving every key a burst pool of up to tokens credits, regaining one every refill
+func (o *Options) WithBurstPool(tokens int64, refill time.Duration) *Options {
+	newOpts := *o
+	newOpts.BurstPoolTokens = tokens
+	newOpts.BurstPoolRefill = refill
+	return &newOpts
+}
+
+// WithIdempotencyWindow returns new options remembering admitted request IDs for the window
+func (o *Options) WithIdempotencyWindow(window time.Duration) *Options {
+	newOpts := *o
+	newOpts.IdempotencyWindow = window
+	return &newOpts
+}
+
+// WithKeyActivity returns new options with per-key activity counting enabled or disabled
+func (o *Options) WithKeyActivity(enabled bool) *Options {
+	newOpts := *o
+	newOpts.KeyActivity = enabled
+	return &newOpts
+}
+
+// WithHedgedReads returns new options hedging GetInfo reads to the replicas after delay
+func (o *Options) WithHedgedReads(delay time.Duration, replicas ...string) *Options {
+	newOpts := *o
+	newOpts.HedgeDelay = delay
+	newOpts.ReadReplicas = replicas
+	return &newOpts
+}
+
+// WithAuth returns new options authenticating to Redis as the ACL user, an empty username uses the default user
+func (o *Options) WithAuth(username, password string) *Options {
+	newOpts := *o
+	newOpts.Username = username
+	newOpts.Password = password
+	return &newOpts
+}
@@ -1,6 +1,8 @@
 package backend
 
 import (
+	"context"
+	"sync"
 	"testing"
 	"time"
 )
@@ -95,6 +97,58 @@ func TestOptionsValidation(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "negative shard count",
+			options: &Options{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				MaxKeys:         10000,
+				CleanupInterval: 5 * time.Minute,
+				ShardCount:      -1,
+			},
+			expectError: true,
+		},
+		{
+			name:        "inline cleanup with refill wheel",
+			options:     DefaultOptions().WithInlineCleanup(true).WithRefillWheel(time.Millisecond),
+			expectError: true,
+		},
+		{
+			name:        "min retry backoff above max",
+			options:     DefaultOptions().WithRetryBackoff(time.Second, time.Millisecond),
+			expectError: true,
+		},
+		{
+			name:        "retry backoff disabled",
+			options:     DefaultOptions().WithRetryBackoff(-1, -1),
+			expectError: false,
+		},
+		{
+			name:        "short key hash secret",
+			options:     DefaultOptions().WithKeyHashing([]byte("too short")),
+			expectError: true,
+		},
+		{
+			name:        "key hash secret",
+			options:     DefaultOptions().WithKeyHashing([]byte("0123456789abcdef")),
+			expectError: false,
+		},
+		{
+			name:        "TLS certificate without key",
+			options:     DefaultOptions().WithTLS(&TLSOptions{CertFile: "client.crt"}),
+			expectError: true,
+		},
+		{
+			name:        "username without password",
+			options:     DefaultOptions().WithAuth("limiter", ""),
+			expectError: true,
+		},
+		{
+			name:        "TLS",
+			options:     DefaultOptions().WithTLS(&TLSOptions{CAFile: "ca.crt", CertFile: "client.crt", KeyFile: "client.key"}),
+			expectError: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -186,3 +240,385 @@ func TestTokenInfo(t *testing.T) {
 		t.Errorf("expected RefillRate to be 1s, got %v", info.RefillRate)
 	}
 }
+
+func TestSetLimitBalance(t *testing.T) {
+	ctx := context.Background()
+
+	backends := []struct {
+		name string
+		new  func(t *testing.T) Backend
+	}{
+		{
+			name: "memory",
+			new: func(t *testing.T) Backend {
+				backend, err := NewInMemoryBackend(DefaultOptions().WithRefill(time.Hour))
+				if err != nil {
+					t.Fatalf("failed to create backend: %v", err)
+				}
+				t.Cleanup(func() { backend.Close(ctx) })
+				return backend
+			},
+		},
+		{
+			name: "redis",
+			new: func(t *testing.T) Backend {
+				backend, _ := newTestRedisBackend(t, DefaultOptions().WithRefill(time.Hour))
+				return backend
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		spend    int64
+		limit    int64
+		expected int64
+	}{
+		{name: "lowering clamps the balance", spend: 20, limit: 50, expected: 50},
+		{name: "raising keeps the balance", spend: 20, limit: 200, expected: 80},
+		{name: "lowering above the balance keeps it", spend: 20, limit: 90, expected: 80},
+		{name: "new keys start full", limit: 200, expected: 200},
+	}
+
+	for _, bk := range backends {
+		for _, tt := range tests {
+			t.Run(bk.name+"/"+tt.name, func(t *testing.T) {
+				backend := bk.new(t)
+
+				// Spend some of the default 100 tokens
+				if tt.spend > 0 {
+					if _, err := backend.Take(ctx, "test_key", tt.spend); err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+				}
+
+				if err := backend.SetLimit(ctx, "test_key", tt.limit, time.Hour); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				info, err := backend.GetInfo(ctx, "test_key")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if info.Tokens != tt.expected {
+					t.Errorf("expected %d tokens, got %d", tt.expected, info.Tokens)
+				}
+				if info.MaxTokens != tt.limit {
+					t.Errorf("expected max tokens %d, got %d", tt.limit, info.MaxTokens)
+				}
+			})
+		}
+	}
+}
+
+func TestMaxDebt(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithLimit(5).WithRefill(time.Hour).WithMaxDebt(3)
+
+	backends := []struct {
+		name string
+		new  func(t *testing.T) Backend
+	}{
+		{
+			name: "memory",
+			new: func(t *testing.T) Backend {
+				backend, err := NewInMemoryBackend(options)
+				if err != nil {
+					t.Fatalf("failed to create backend: %v", err)
+				}
+				t.Cleanup(func() { backend.Close(ctx) })
+				return backend
+			},
+		},
+		{
+			name: "redis",
+			new: func(t *testing.T) Backend {
+				backend, _ := newTestRedisBackend(t, options)
+				return backend
+			},
+		},
+	}
+
+	for _, bk := range backends {
+		t.Run(bk.name, func(t *testing.T) {
+			backend := bk.new(t)
+
+			steps := []struct {
+				tokens  int64
+				allowed bool
+				balance int64
+			}{
+				{tokens: 5, allowed: true, balance: 0},
+				{tokens: 2, allowed: true, balance: -2},
+				{tokens: 2, allowed: false, balance: -2},
+				{tokens: 1, allowed: true, balance: -3},
+				{tokens: 1, allowed: false, balance: -3},
+			}
+
+			for i, step := range steps {
+				allowed, err := backend.Take(ctx, "test_key", step.tokens)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if allowed != step.allowed {
+					t.Errorf("step %d: expected allowed %v, got %v", i, step.allowed, allowed)
+				}
+
+				info, err := backend.GetInfo(ctx, "test_key")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if info.Tokens != step.balance {
+					t.Errorf("step %d: expected balance %d, got %d", i, step.balance, info.Tokens)
+				}
+			}
+
+			// Multi-bucket takes borrow within the same debt
+			allowed, err := backend.TakeAll(ctx, []string{"other_key", "third_key"}, 7)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !allowed {
+				t.Error("expected TakeAll to borrow up to the debt")
+			}
+
+			allowed, err = backend.TakeAll(ctx, []string{"other_key", "test_key"}, 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed {
+				t.Error("expected TakeAll to deny when a bucket is at its debt")
+			}
+		})
+	}
+
+	if err := DefaultOptions().WithMaxDebt(-1).Validate(); err == nil {
+		t.Error("expected error for negative debt, got nil")
+	}
+}
+
+func TestGraceTokens(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithLimit(2).WithRefill(time.Hour).WithGrace(3, time.Minute)
+
+	backends := []struct {
+		name string
+		new  func(t *testing.T) Backend
+	}{
+		{
+			name: "memory",
+			new: func(t *testing.T) Backend {
+				backend, err := NewInMemoryBackend(options)
+				if err != nil {
+					t.Fatalf("failed to create backend: %v", err)
+				}
+				t.Cleanup(func() { backend.Close(ctx) })
+				return backend
+			},
+		},
+		{
+			name: "redis",
+			new: func(t *testing.T) Backend {
+				backend, _ := newTestRedisBackend(t, options)
+				return backend
+			},
+		},
+	}
+
+	for _, bk := range backends {
+		t.Run(bk.name, func(t *testing.T) {
+			backend := bk.new(t)
+
+			// The grace budget is spent first and leaves the bucket full
+			for i := 0; i < 3; i++ {
+				allowed, err := backend.Take(ctx, "test_key", 1)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !allowed {
+					t.Fatalf("expected take %d to be allowed from the grace budget", i)
+				}
+			}
+
+			info, err := backend.GetInfo(ctx, "test_key")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Tokens != 2 {
+				t.Errorf("expected a full bucket after the grace budget, got %d", info.Tokens)
+			}
+
+			for i := 0; i < 2; i++ {
+				if allowed, _ := backend.Take(ctx, "test_key", 1); !allowed {
+					t.Errorf("expected take %d to be allowed from the bucket", i)
+				}
+			}
+			if allowed, _ := backend.Take(ctx, "test_key", 1); allowed {
+				t.Error("expected take beyond the grace budget and the limit to be denied")
+			}
+		})
+	}
+
+	tests := []struct {
+		name    string
+		options *Options
+	}{
+		{name: "negative tokens", options: DefaultOptions().WithGrace(-1, time.Minute)},
+		{name: "negative period", options: DefaultOptions().WithGrace(1, -time.Minute)},
+		{name: "tokens without period", options: DefaultOptions().WithGrace(1, 0)},
+	}
+
+	for _, tt := range tests {
+		if err := tt.options.Validate(); err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
+
+func TestBurstPool(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithLimit(2).WithRefill(time.Hour).WithBurstPool(3, time.Hour)
+
+	backends := []struct {
+		name string
+		new  func(t *testing.T) Backend
+	}{
+		{
+			name: "memory",
+			new: func(t *testing.T) Backend {
+				backend, err := NewInMemoryBackend(options)
+				if err != nil {
+					t.Fatalf("failed to create backend: %v", err)
+				}
+				t.Cleanup(func() { backend.Close(ctx) })
+				return backend
+			},
+		},
+		{
+			name: "redis",
+			new: func(t *testing.T) Backend {
+				backend, _ := newTestRedisBackend(t, options)
+				return backend
+			},
+		},
+	}
+
+	for _, bk := range backends {
+		t.Run(bk.name, func(t *testing.T) {
+			backend := bk.new(t)
+
+			// The bucket is spent first, then the pool covers what it cannot
+			takes := []struct {
+				tokens  int64
+				allowed bool
+			}{
+				{tokens: 2, allowed: true},
+				{tokens: 2, allowed: true},
+				{tokens: 2, allowed: false},
+				{tokens: 1, allowed: true},
+				{tokens: 1, allowed: false},
+			}
+
+			for i, take := range takes {
+				allowed, err := backend.Take(ctx, "test_key", take.tokens)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if allowed != take.allowed {
+					t.Errorf("take %d of %d tokens: expected allowed %v, got %v", i, take.tokens, take.allowed, allowed)
+				}
+			}
+
+			info, err := backend.GetInfo(ctx, "test_key")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Tokens != 0 || info.BurstCredits != 0 {
+				t.Errorf("expected an empty bucket and pool, got %d tokens and %d credits", info.Tokens, info.BurstCredits)
+			}
+
+			info, err = backend.GetInfo(ctx, "other_key")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.BurstCredits != 3 {
+				t.Errorf("expected a new key to start with a full pool, got %d credits", info.BurstCredits)
+			}
+		})
+	}
+
+	tests := []struct {
+		name    string
+		options *Options
+	}{
+		{name: "negative tokens", options: DefaultOptions().WithBurstPool(-1, time.Minute)},
+		{name: "negative refill", options: DefaultOptions().WithBurstPool(1, -time.Minute)},
+		{name: "tokens without refill", options: DefaultOptions().WithBurstPool(1, 0)},
+	}
+
+	for _, tt := range tests {
+		if err := tt.options.Validate(); err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	ctx := context.Background()
+
+	backends := []struct {
+		name string
+		new  func(t *testing.T) Backend
+	}{
+		{
+			name: "memory",
+			new: func(t *testing.T) Backend {
+				backend, err := NewInMemoryBackend(DefaultOptions())
+				if err != nil {
+					t.Fatalf("failed to create backend: %v", err)
+				}
+				return backend
+			},
+		},
+		{
+			name: "redis",
+			new: func(t *testing.T) Backend {
+				backend, _ := newTestRedisBackend(t, DefaultOptions())
+				return backend
+			},
+		},
+		{
+			name: "approximate",
+			new: func(t *testing.T) Backend {
+				backend, _ := newTestApproximateBackend(t, 100, &ApproximateOptions{SyncInterval: time.Hour, Nodes: 1})
+				return backend
+			},
+		},
+	}
+
+	for _, bk := range backends {
+		t.Run(bk.name, func(t *testing.T) {
+			backend := bk.new(t)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					backend.Take(ctx, "test_key", 1)
+				}()
+				go func() {
+					defer wg.Done()
+					if err := backend.Close(ctx); err != nil {
+						t.Errorf("unexpected error: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if _, err := backend.Take(ctx, "test_key", 1); err == nil {
+				t.Error("expected error after close")
+			}
+		})
+	}
+}
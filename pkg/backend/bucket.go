@@ -0,0 +1,446 @@
+package backend
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Bucket state is packed into one uint64 so Take can update it with a single CAS
+// The upper 24 bits hold the token count plus the debt the bucket may run up, so the stored count
+// is never negative, and the lower 40 bits the held flag and, below it, the last refill time in
+// milliseconds since the bucket epoch, which covers more than 17 years
+const (
+	tickBits = 40
+	heldBit  = 1 << (tickBits - 1)
+	tickMask = heldBit - 1
+
+	// maxBucketTokens is the largest token count plus debt an in-memory bucket can hold
+	maxBucketTokens = 1<<(64-tickBits) - 1
+)
+
+// bucket represents a token bucket for rate limiting
+// Every field is read and written atomically so the hot path never takes a lock
+// Optimistic buckets keep their state in value instead of state, maxTokens and refillRate
+type bucket struct {
+	Key   string
+	epoch time.Time
+	debt  int64
+
+	state      atomic.Uint64
+	maxTokens  atomic.Int64
+	refillRate atomic.Int64
+
+	// grace is the budget a new bucket spends before its balance until its grace period ends
+	grace atomic.Int64
+
+	// burst is what the bucket spent of its burst pool, nil while the pool is untouched
+	burst atomic.Pointer[burstState]
+
+	// strategy is how a packed bucket refills, nil while it refills linearly
+	strategy atomic.Pointer[RefillStrategy]
+
+	// limitsGen is odd while setLimit or setStrategy changes the limits of a packed bucket and counts up with every change,
+	// so view can tell when the limits it read do not belong to the state it read
+	limitsGen atomic.Uint64
+
+	// value is the current version of an optimistic bucket, nil for packed buckets
+	value atomic.Pointer[bucketValue]
+}
+
+// bucketHold is the state of a bucket held by a multi-bucket take, refilled up to the time it was held
+type bucketHold struct {
+	tokens int64
+	ticks  uint64
+	value  *bucketValue
+}
+
+// newBucket creates a bucket whose last refill happened at lastRefill, which may run up to debt tokens below zero
+func newBucket(key string, tokens, maxTokens int64, refill time.Duration, lastRefill time.Time, debt int64) *bucket {
+	// Times read back from a snapshot carry no monotonic reading, so rebase them on the monotonic clock
+	// Every elapsed time is then measured from the epoch and immune to NTP steps and DST changes
+	if lastRefill == lastRefill.Round(0) {
+		now := time.Now()
+		lastRefill = now.Add(-max(now.Sub(lastRefill), 0))
+	}
+
+	bkt := &bucket{
+		Key:   key,
+		epoch: lastRefill,
+		debt:  debt,
+	}
+	bkt.maxTokens.Store(int64(maxTokens))
+	bkt.refillRate.Store(int64(refill))
+	bkt.state.Store(packState(tokens+debt, 0))
+
+	return bkt
+}
+
+// packState packs a stored token count, the balance plus the debt, and a tick count into one state word
+func packState(tokens int64, ticks uint64) uint64 {
+	if tokens < 0 {
+		tokens = 0
+	}
+	if tokens > maxBucketTokens {
+		tokens = maxBucketTokens
+	}
+
+	return uint64(tokens)<<tickBits | ticks&tickMask
+}
+
+// unpackState splits a state word into its stored token count and tick count
+func unpackState(state uint64) (int64, uint64) {
+	return int64(state >> tickBits), state & tickMask
+}
+
+// ticks converts a time into milliseconds since the bucket epoch
+func (bkt *bucket) ticks(t time.Time) uint64 {
+	elapsed := t.Sub(bkt.epoch)
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return uint64(elapsed/time.Millisecond) & tickMask
+}
+
+// timeAt converts a tick count back into a time
+func (bkt *bucket) timeAt(ticks uint64) time.Time {
+	return bkt.epoch.Add(time.Duration(ticks) * time.Millisecond)
+}
+
+// refilled returns the state with tokens added for the time elapsed since the last refill
+// Refills pay off any debt before the balance rises above zero
+func (bkt *bucket) refilled(state uint64, now time.Time) uint64 {
+	tokens, last := unpackState(state)
+
+	// A concurrent take may have stored a later refill than this now
+	elapsed := now.Sub(bkt.timeAt(last))
+	if elapsed <= 0 {
+		return state
+	}
+
+	refill := time.Duration(bkt.refillRate.Load())
+	intervals := int64(elapsed / refill)
+
+	if intervals <= 0 {
+		return state
+	}
+
+	// Add tokens, but don't exceed max, a full bucket starts its next refill interval now
+	ceiling := bkt.maxTokens.Load() + bkt.debt
+	tokensToAdd := intervals
+	if strategy := bkt.strategy.Load(); strategy != nil && tokens < ceiling {
+		tokensToAdd = RefillTokens(*strategy, ceiling-tokens, intervals)
+	}
+	if tokensToAdd >= ceiling-tokens {
+		return packState(ceiling, bkt.ticks(now))
+	}
+
+	// Keep the partial interval so refills do not drift, which ticks can only hold for whole milliseconds
+	if refill%time.Millisecond == 0 {
+		return packState(tokens+tokensToAdd, last+uint64(intervals*int64(refill/time.Millisecond))&tickMask)
+	}
+
+	return packState(tokens+tokensToAdd, bkt.ticks(now))
+}
+
+// take consumes tokens if the balance after refilling stays within the debt
+func (bkt *bucket) take(tokens int64, now time.Time) bool {
+	if bkt.value.Load() != nil {
+		return bkt.takeOptimistic(tokens, now)
+	}
+
+	for {
+		old := bkt.load()
+		state := bkt.refilled(old, now)
+
+		available, ticks := unpackState(state)
+		if available < tokens {
+			// Keep the refill so readers see the current count
+			if state != old {
+				bkt.state.CompareAndSwap(old, state)
+			}
+			return false
+		}
+
+		if bkt.state.CompareAndSwap(old, packState(available-tokens, ticks)) {
+			return true
+		}
+	}
+}
+
+// schedule consumes tokens whether or not the balance covers them, returning when a take of them would have been allowed
+// Tokens the balance lacks are reserved from future refills by moving the last refill past now,
+// so takes are denied until those refills have passed. It fails when tokens exceed the limit plus the debt
+func (bkt *bucket) schedule(tokens int64, now time.Time) (time.Time, bool) {
+	if bkt.value.Load() != nil {
+		return bkt.scheduleOptimistic(tokens, now)
+	}
+
+	for {
+		old := bkt.load()
+		state := bkt.refilled(old, now)
+
+		available, ticks := unpackState(state)
+		if available >= tokens {
+			if bkt.state.CompareAndSwap(old, packState(available-tokens, ticks)) {
+				return now, true
+			}
+			continue
+		}
+
+		ceiling := bkt.maxTokens.Load() + bkt.debt
+		if tokens > ceiling {
+			return time.Time{}, false
+		}
+
+		// Whole milliseconds only, so the reservation never ends before the refills that cover it
+		wait := reservationWait(bkt.refillStrategy(), ceiling, tokens-available, time.Duration(bkt.refillRate.Load()))
+		wait = (wait + time.Millisecond - 1) / time.Millisecond * time.Millisecond
+		at := bkt.timeAt(ticks).Add(wait)
+		if at.Sub(bkt.epoch) > tickMask*time.Millisecond {
+			return time.Time{}, false
+		}
+
+		if bkt.state.CompareAndSwap(old, packState(0, bkt.ticks(at))) {
+			return at, true
+		}
+	}
+}
+
+// reservationWait returns how long an empty bucket refilling up to ceiling tokens takes to regain missing of them
+// Strategies regaining more than missing over the last interval lose the excess to the reservation
+func reservationWait(strategy RefillStrategy, ceiling, missing int64, refill time.Duration) time.Duration {
+	return time.Duration(RefillIntervals(strategy, ceiling, missing)) * refill
+}
+
+// unreserved returns the balance and last refill of a bucket as if its reservations were debt
+// A reservation moves the last refill past now, which stands for one token for every interval still to pass,
+// so readers see a negative balance refilling since before now instead
+func unreserved(tokens int64, lastRefill, now time.Time, refill time.Duration) (int64, time.Time) {
+	if !lastRefill.After(now) {
+		return tokens, lastRefill
+	}
+
+	reserved := int64((lastRefill.Sub(now) + refill - 1) / refill)
+	return tokens - reserved, lastRefill.Add(-time.Duration(reserved) * refill)
+}
+
+// takeGrace consumes tokens from the grace budget if it covers them and now is before until
+func (bkt *bucket) takeGrace(tokens int64, until time.Time, now time.Time) bool {
+	if !now.Before(until) {
+		return false
+	}
+
+	for {
+		left := bkt.grace.Load()
+		if left < tokens {
+			return false
+		}
+
+		if bkt.grace.CompareAndSwap(left, left-tokens) {
+			return true
+		}
+	}
+}
+
+// load returns the state of a packed bucket once no multi-bucket take holds it
+// Writers CAS from the state it returns, which fails if a multi-bucket take holds the bucket meanwhile
+func (bkt *bucket) load() uint64 {
+	for {
+		state := bkt.state.Load()
+		if state&heldBit == 0 {
+			return state
+		}
+		runtime.Gosched()
+	}
+}
+
+// hold refills the bucket and holds it for a multi-bucket take, waiting for another one holding it first
+// Takes, limit changes and readers wait until release, so the bucket changes only if the whole take goes through
+func (bkt *bucket) hold(now time.Time) bucketHold {
+	if bkt.value.Load() != nil {
+		return bkt.holdOptimistic(now)
+	}
+
+	for {
+		old := bkt.load()
+		state := bkt.refilled(old, now)
+		if bkt.state.CompareAndSwap(old, state|heldBit) {
+			tokens, ticks := unpackState(state)
+			return bucketHold{tokens: tokens, ticks: ticks}
+		}
+	}
+}
+
+// release ends a hold, consuming tokens from the balance it held
+func (bkt *bucket) release(h bucketHold, tokens int64) {
+	if h.value != nil {
+		bkt.value.Store(h.value.next(h.value.tokens-tokens, h.value.lastRefill, h.value.maxTokens, h.value.refill))
+		return
+	}
+
+	bkt.state.Store(packState(h.tokens-tokens, h.ticks))
+}
+
+// refresh applies any pending refill and returns the balance, negative while in debt, and last refill time
+// Optimistic buckets compute the refill without storing it, so readers never contend with takes
+func (bkt *bucket) refresh(now time.Time) (int64, time.Time) {
+	if v := bkt.value.Load(); v != nil {
+		tokens, lastRefill := v.at(now, bkt.debt)
+		return tokens - bkt.debt, lastRefill
+	}
+
+	for {
+		old := bkt.load()
+		state := bkt.refilled(old, now)
+
+		if state == old || bkt.state.CompareAndSwap(old, state) {
+			tokens, ticks := unpackState(state)
+			return tokens - bkt.debt, bkt.timeAt(ticks)
+		}
+	}
+}
+
+// setLimit changes the maximum token count and refill rate, reporting whether either changed
+// Refill owed under the old rate is applied first, then the balance is clamped to the new limit
+func (bkt *bucket) setLimit(limit int64, refill time.Duration, now time.Time) bool {
+	if bkt.value.Load() != nil {
+		return bkt.setLimitOptimistic(limit, refill, now)
+	}
+
+	if bkt.maxTokens.Load() == limit && bkt.refillRate.Load() == int64(refill) {
+		return false
+	}
+
+	bkt.lockLimits()
+	defer bkt.unlockLimits()
+
+	bkt.refresh(now)
+	bkt.maxTokens.Store(limit)
+	bkt.refillRate.Store(int64(refill))
+
+	for {
+		old := bkt.load()
+		tokens, ticks := unpackState(old)
+		if tokens <= limit+bkt.debt || bkt.state.CompareAndSwap(old, packState(limit+bkt.debt, ticks)) {
+			return true
+		}
+	}
+}
+
+// setStrategy changes how the bucket refills, nil or linear refilling linearly
+// Refill owed under the old strategy is applied first, reporting whether the strategy changed
+func (bkt *bucket) setStrategy(strategy RefillStrategy, now time.Time) bool {
+	if isLinear(strategy) {
+		strategy = nil
+	}
+
+	if bkt.value.Load() != nil {
+		return bkt.setStrategyOptimistic(strategy, now)
+	}
+
+	if sameStrategy(bkt.refillStrategy(), strategy) {
+		return false
+	}
+
+	bkt.lockLimits()
+	defer bkt.unlockLimits()
+
+	bkt.refresh(now)
+	if strategy == nil {
+		bkt.strategy.Store(nil)
+	} else {
+		bkt.strategy.Store(&strategy)
+	}
+	return true
+}
+
+// lockLimits makes limitsGen odd, waiting for a change of the limits in progress to finish first
+func (bkt *bucket) lockLimits() {
+	for {
+		gen := bkt.limitsGen.Load()
+		if gen%2 == 0 && bkt.limitsGen.CompareAndSwap(gen, gen+1) {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+// unlockLimits makes limitsGen even again, publishing the changed limits
+func (bkt *bucket) unlockLimits() {
+	bkt.limitsGen.Add(1)
+}
+
+// refillStrategy returns how the bucket refills, nil while it refills linearly
+func (bkt *bucket) refillStrategy() RefillStrategy {
+	if v := bkt.value.Load(); v != nil {
+		return v.strategy
+	}
+
+	if strategy := bkt.strategy.Load(); strategy != nil {
+		return *strategy
+	}
+	return nil
+}
+
+// lastRefill returns the time of the last refill without applying a pending one
+func (bkt *bucket) lastRefill() time.Time {
+	if v := bkt.value.Load(); v != nil {
+		return v.lastRefill
+	}
+
+	_, ticks := unpackState(bkt.state.Load())
+	return bkt.timeAt(ticks)
+}
+
+// bucketView is the state of a bucket at one point in time
+type bucketView struct {
+	tokens     int64
+	lastRefill time.Time
+	maxTokens  int64
+	refill     time.Duration
+	strategy   RefillStrategy
+}
+
+// view refreshes the bucket and returns its balance, last refill time, limits and refill strategy as of one instant
+// An optimistic bucket returns them from one version, a packed bucket reads again when its limits changed meanwhile
+func (bkt *bucket) view(now time.Time) bucketView {
+	if v := bkt.value.Load(); v != nil {
+		tokens, lastRefill := v.at(now, bkt.debt)
+		return bucketView{
+			tokens:     tokens - bkt.debt,
+			lastRefill: lastRefill,
+			maxTokens:  v.maxTokens,
+			refill:     v.refill,
+			strategy:   v.strategy,
+		}
+	}
+
+	for {
+		gen := bkt.limitsGen.Load()
+		if gen%2 == 1 {
+			runtime.Gosched()
+			continue
+		}
+
+		tokens, lastRefill := bkt.refresh(now)
+		view := bucketView{
+			tokens:     tokens,
+			lastRefill: lastRefill,
+			maxTokens:  bkt.maxTokens.Load(),
+			refill:     time.Duration(bkt.refillRate.Load()),
+			strategy:   bkt.refillStrategy(),
+		}
+
+		if bkt.limitsGen.Load() == gen {
+			return view
+		}
+	}
+}
+
+// read refreshes the bucket and returns its balance, last refill time, limit and refill rate as view does
+func (bkt *bucket) read(now time.Time) (int64, time.Time, int64, time.Duration) {
+	view := bkt.view(now)
+	return view.tokens, view.lastRefill, view.maxTokens, view.refill
+}
@@ -0,0 +1,182 @@
+package backend
+
+import (
+	"math"
+	"runtime"
+	"time"
+)
+
+// maxOptimisticTokens is the largest token count plus debt an optimistic bucket can hold
+// Half the int64 range leaves room to add a refill without overflowing
+const maxOptimisticTokens = math.MaxInt64 / 2
+
+// bucketValue is one version of the state of an optimistic bucket
+// Values are never modified once stored, writers CAS a new version in their place
+type bucketValue struct {
+	// version counts the writes to the bucket, a reader seeing the same version saw the same state
+	version uint64
+
+	// tokens is the balance plus the debt the bucket may run up, so it is never negative
+	tokens     int64
+	lastRefill time.Time
+	maxTokens  int64
+	refill     time.Duration
+
+	// strategy is how the bucket refills, nil while it refills linearly
+	strategy RefillStrategy
+
+	// held is set while a multi-bucket take holds the bucket, writers wait for the next version
+	held bool
+}
+
+// newOptimisticBucket creates a bucket holding its state as versioned values swapped by CAS
+// Limits change atomically with the balance, and reads never write to the bucket
+func newOptimisticBucket(key string, tokens, maxTokens int64, refill time.Duration, lastRefill time.Time, debt int64) *bucket {
+	bkt := newBucket(key, 0, maxTokens, refill, lastRefill, debt)
+	bkt.value.Store(&bucketValue{
+		tokens:     min(tokens+debt, maxOptimisticTokens),
+		lastRefill: bkt.epoch,
+		maxTokens:  maxTokens,
+		refill:     refill,
+	})
+
+	return bkt
+}
+
+// at returns the stored token count and last refill time the value has once refilled up to now
+// Refills pay off any debt before the balance rises above zero
+func (v *bucketValue) at(now time.Time, debt int64) (int64, time.Time) {
+	elapsed := now.Sub(v.lastRefill)
+	if elapsed <= 0 {
+		return v.tokens, v.lastRefill
+	}
+
+	intervals := int64(elapsed / v.refill)
+	if intervals <= 0 {
+		return v.tokens, v.lastRefill
+	}
+
+	// Add tokens, but don't exceed max, a full bucket starts its next refill interval now
+	ceiling := v.maxTokens + debt
+	tokensToAdd := intervals
+	if v.strategy != nil && v.tokens < ceiling {
+		tokensToAdd = RefillTokens(v.strategy, ceiling-v.tokens, intervals)
+	}
+	if tokensToAdd >= ceiling-v.tokens {
+		return ceiling, now
+	}
+
+	// Keep the partial interval so refills do not drift
+	return v.tokens + tokensToAdd, v.lastRefill.Add(time.Duration(intervals) * v.refill)
+}
+
+// next returns the version following v with the given state
+func (v *bucketValue) next(tokens int64, lastRefill time.Time, maxTokens int64, refill time.Duration) *bucketValue {
+	return &bucketValue{
+		version:    v.version + 1,
+		tokens:     tokens,
+		lastRefill: lastRefill,
+		maxTokens:  maxTokens,
+		refill:     refill,
+		strategy:   v.strategy,
+	}
+}
+
+// takeOptimistic consumes tokens if the balance after refilling stays within the debt
+// A denied take stores nothing, the refill it computed is recomputed by the next reader
+func (bkt *bucket) takeOptimistic(tokens int64, now time.Time) bool {
+	for {
+		old := bkt.loadValue()
+		available, lastRefill := old.at(now, bkt.debt)
+		if available < tokens {
+			return false
+		}
+
+		if bkt.value.CompareAndSwap(old, old.next(available-tokens, lastRefill, old.maxTokens, old.refill)) {
+			return true
+		}
+	}
+}
+
+// scheduleOptimistic consumes tokens whether or not the balance covers them, as schedule does
+func (bkt *bucket) scheduleOptimistic(tokens int64, now time.Time) (time.Time, bool) {
+	for {
+		old := bkt.loadValue()
+		available, lastRefill := old.at(now, bkt.debt)
+
+		var v *bucketValue
+		at := now
+		if available >= tokens {
+			v = old.next(available-tokens, lastRefill, old.maxTokens, old.refill)
+		} else {
+			ceiling := old.maxTokens + bkt.debt
+			if tokens > ceiling {
+				return time.Time{}, false
+			}
+
+			at = lastRefill.Add(reservationWait(old.strategy, ceiling, tokens-available, old.refill))
+			v = old.next(0, at, old.maxTokens, old.refill)
+		}
+
+		if bkt.value.CompareAndSwap(old, v) {
+			return at, true
+		}
+	}
+}
+
+// loadValue returns the version of an optimistic bucket once no multi-bucket take holds it
+func (bkt *bucket) loadValue() *bucketValue {
+	for {
+		v := bkt.value.Load()
+		if !v.held {
+			return v
+		}
+		runtime.Gosched()
+	}
+}
+
+// holdOptimistic refills the bucket and holds it for a multi-bucket take, as hold does
+func (bkt *bucket) holdOptimistic(now time.Time) bucketHold {
+	for {
+		old := bkt.loadValue()
+		tokens, lastRefill := old.at(now, bkt.debt)
+		v := old.next(tokens, lastRefill, old.maxTokens, old.refill)
+		v.held = true
+		if bkt.value.CompareAndSwap(old, v) {
+			return bucketHold{tokens: tokens, value: v}
+		}
+	}
+}
+
+// setLimitOptimistic swaps in the limit and refill rate together with the balance they leave
+func (bkt *bucket) setLimitOptimistic(limit int64, refill time.Duration, now time.Time) bool {
+	for {
+		old := bkt.loadValue()
+		if old.maxTokens == limit && old.refill == refill {
+			return false
+		}
+
+		// Refill owed under the old rate is applied first, then the balance is clamped to the new limit
+		tokens, lastRefill := old.at(now, bkt.debt)
+		if bkt.value.CompareAndSwap(old, old.next(min(tokens, limit+bkt.debt), lastRefill, limit, refill)) {
+			return true
+		}
+	}
+}
+
+// setStrategyOptimistic swaps in the strategy together with the balance refilled under the old one
+func (bkt *bucket) setStrategyOptimistic(strategy RefillStrategy, now time.Time) bool {
+	for {
+		old := bkt.loadValue()
+		if sameStrategy(old.strategy, strategy) {
+			return false
+		}
+
+		tokens, lastRefill := old.at(now, bkt.debt)
+		v := old.next(tokens, lastRefill, old.maxTokens, old.refill)
+		v.strategy = strategy
+		if bkt.value.CompareAndSwap(old, v) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOptimisticBucketTakeAndRefill(t *testing.T) {
+	start := time.Now()
+	bkt := newOptimisticBucket("key", 3, 3, 100*time.Millisecond, start, 0)
+
+	for i := 0; i < 3; i++ {
+		if !bkt.take(1, start) {
+			t.Fatalf("expected take %d to succeed", i+1)
+		}
+	}
+	if bkt.take(1, start) {
+		t.Error("expected take to fail on an empty bucket")
+	}
+
+	// Denied takes and reads store nothing
+	version := bkt.value.Load().version
+	if version != 3 {
+		t.Errorf("expected version 3 after three takes, got %d", version)
+	}
+
+	later := start.Add(250 * time.Millisecond)
+	tokens, lastRefill := bkt.refresh(later)
+	if tokens != 2 {
+		t.Errorf("expected 2 tokens after refill, got %d", tokens)
+	}
+	if refilled := start.Add(200 * time.Millisecond); !lastRefill.Equal(refilled) {
+		t.Errorf("expected last refill at %v, got %v", refilled, lastRefill)
+	}
+	if got := bkt.value.Load().version; got != version {
+		t.Errorf("expected reads to leave version %d, got %d", version, got)
+	}
+
+	// Refills never exceed the maximum
+	if tokens, _ := bkt.refresh(later.Add(time.Hour)); tokens != 3 {
+		t.Errorf("expected refill to cap at 3 tokens, got %d", tokens)
+	}
+}
+
+func TestOptimisticBucketDebt(t *testing.T) {
+	now := time.Now()
+	bkt := newOptimisticBucket("key", 2, 2, 10*time.Millisecond, now, 3)
+
+	if !bkt.take(5, now) {
+		t.Fatal("expected take to borrow up to the debt")
+	}
+	if bkt.take(1, now) {
+		t.Error("expected take beyond the debt to be denied")
+	}
+	if tokens, _ := bkt.refresh(now); tokens != -3 {
+		t.Errorf("expected a balance of -3, got %d", tokens)
+	}
+
+	// Refills pay off the debt before new tokens accrue
+	if tokens, _ := bkt.refresh(now.Add(20 * time.Millisecond)); tokens != -1 {
+		t.Errorf("expected a balance of -1, got %d", tokens)
+	}
+}
+
+func TestOptimisticBucketSetLimit(t *testing.T) {
+	now := time.Now()
+	bkt := newOptimisticBucket("key", 10, 10, time.Second, now, 0)
+
+	if bkt.setLimit(10, time.Second, now) {
+		t.Error("expected an unchanged limit to leave the bucket alone")
+	}
+
+	// The balance is clamped in the same version as the new limit
+	if !bkt.setLimit(4, time.Minute, now) {
+		t.Fatal("expected the limit to change")
+	}
+	tokens, _, limit, refill := bkt.read(now)
+	if tokens != 4 || limit != 4 || refill != time.Minute {
+		t.Errorf("expected 4 of 4 tokens every minute, got %d of %d every %v", tokens, limit, refill)
+	}
+
+	// Limits beyond what a packed bucket holds
+	huge := int64(1) << 40
+	bkt.setLimit(huge, time.Nanosecond, now)
+	if tokens, _ := bkt.refresh(now.Add(time.Hour)); tokens != huge {
+		t.Errorf("expected %d tokens, got %d", huge, tokens)
+	}
+}
+
+func TestOptimisticBucketConcurrentTakeAndRead(t *testing.T) {
+	now := time.Now()
+	bkt := newOptimisticBucket("key", 1000, 1000, time.Hour, now, 0)
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if bkt.take(1, now) {
+					allowed.Add(1)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if tokens, _, limit, _ := bkt.read(now); tokens < 0 || tokens > limit {
+					t.Errorf("expected a balance within the limit, got %d", tokens)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed.Load() != 1000 {
+		t.Errorf("expected exactly 1000 takes to succeed, got %d", allowed.Load())
+	}
+}
+
+func TestInMemoryBackendOptimisticBuckets(t *testing.T) {
+	ctx := context.Background()
+
+	huge := int64(1) << 40
+
+	// Limits beyond packed buckets get optimistic buckets even when packed buckets are selected
+	packed, err := NewInMemoryBackend(DefaultOptions().WithLimit(huge))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer packed.Close(ctx)
+
+	if allowed, err := packed.Take(ctx, "key", huge-1); err != nil || !allowed {
+		t.Errorf("expected take beyond packed buckets to be allowed, got %v, %v", allowed, err)
+	}
+	if err := packed.SetLimit(ctx, "new", huge, time.Hour); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	small, err := NewInMemoryBackend(DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer small.Close(ctx)
+
+	small.Take(ctx, "key", 1)
+	if err := small.SetLimit(ctx, "key", huge, time.Hour); err == nil {
+		t.Error("expected error raising a packed bucket beyond its capacity, got nil")
+	}
+	if err := small.SetLimit(ctx, "new", huge, time.Hour); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewInMemoryBackend(DefaultOptions().WithLimit(MaxLimit).WithMaxDebt(1)); err == nil {
+		t.Error("expected error for a limit plus debt beyond MaxLimit, got nil")
+	}
+
+	b, err := NewInMemoryBackend(DefaultOptions().WithLimit(huge).WithOptimisticBuckets(true))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer b.Close(ctx)
+
+	if allowed, err := b.Take(ctx, "key", huge-1); err != nil || !allowed {
+		t.Fatalf("expected take to be allowed, got %v, %v", allowed, err)
+	}
+
+	if err := b.SetLimit(ctx, "key", 5, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := b.GetInfo(ctx, "key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 1 || info.MaxTokens != 5 || info.RefillRate != time.Hour {
+		t.Errorf("expected 1 of 5 tokens every hour, got %d of %d every %v", info.Tokens, info.MaxTokens, info.RefillRate)
+	}
+}
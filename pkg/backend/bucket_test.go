@@ -0,0 +1,400 @@
+package backend
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPackState(t *testing.T) {
+	tests := []struct {
+		name           string
+		tokens         int64
+		ticks          uint64
+		expectedTokens int64
+		expectedTicks  uint64
+	}{
+		{name: "zero", tokens: 0, ticks: 0, expectedTokens: 0, expectedTicks: 0},
+		{name: "typical", tokens: 100, ticks: 123456, expectedTokens: 100, expectedTicks: 123456},
+		{name: "max values", tokens: maxBucketTokens, ticks: tickMask, expectedTokens: maxBucketTokens, expectedTicks: tickMask},
+		{name: "negative tokens clamp to zero", tokens: -5, ticks: 1, expectedTokens: 0, expectedTicks: 1},
+		{name: "too many tokens clamp to max", tokens: maxBucketTokens + 10, ticks: 1, expectedTokens: maxBucketTokens, expectedTicks: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, ticks := unpackState(packState(tt.tokens, tt.ticks))
+			if tokens != tt.expectedTokens || ticks != tt.expectedTicks {
+				t.Errorf("expected %d tokens at %d ticks, got %d at %d", tt.expectedTokens, tt.expectedTicks, tokens, ticks)
+			}
+		})
+	}
+}
+
+func TestBucketTakeAndRefill(t *testing.T) {
+	start := time.Now()
+	bkt := newBucket("key", 3, 3, 100*time.Millisecond, start, 0)
+
+	for i := 0; i < 3; i++ {
+		if !bkt.take(1, start) {
+			t.Fatalf("expected take %d to succeed", i+1)
+		}
+	}
+	if bkt.take(1, start) {
+		t.Error("expected take to fail on an empty bucket")
+	}
+
+	// Two refill intervals later two tokens are available
+	later := start.Add(250 * time.Millisecond)
+	tokens, lastRefill := bkt.refresh(later)
+	if tokens != 2 {
+		t.Errorf("expected 2 tokens after refill, got %d", tokens)
+	}
+	// The 50ms into the next interval are kept
+	if refilled := start.Add(200 * time.Millisecond); lastRefill.Sub(refilled) > time.Millisecond || refilled.Sub(lastRefill) > time.Millisecond {
+		t.Errorf("expected last refill near %v, got %v", refilled, lastRefill)
+	}
+
+	// Refills never exceed the maximum
+	tokens, _ = bkt.refresh(later.Add(time.Hour))
+	if tokens != 3 {
+		t.Errorf("expected refill to cap at 3 tokens, got %d", tokens)
+	}
+}
+
+func TestBucketWallClockSteps(t *testing.T) {
+	tests := []struct {
+		name       string
+		lastRefill time.Time
+	}{
+		// Round(0) strips the monotonic reading, as for a time loaded from a snapshot
+		{name: "clock stepped forward", lastRefill: time.Now().Round(0).Add(-time.Hour)},
+		{name: "clock stepped back", lastRefill: time.Now().Round(0).Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bkt := newBucket("key", 0, 5, 10*time.Millisecond, tt.lastRefill, 0)
+
+			if lastRefill := bkt.lastRefill(); lastRefill.After(time.Now()) {
+				t.Errorf("expected the last refill to be clamped to now, got %v", lastRefill)
+			}
+
+			tokens, _ := bkt.refresh(time.Now().Add(50 * time.Millisecond))
+			if tokens == 0 {
+				t.Error("expected the bucket to refill after the refill interval")
+			}
+		})
+	}
+}
+
+func TestBucketRefillBeforeLastRefill(t *testing.T) {
+	now := time.Now()
+	bkt := newBucket("key", 0, 5, 10*time.Millisecond, now, 0)
+
+	// A take holding an earlier reading than the stored refill must not move it back
+	bkt.refresh(now.Add(100 * time.Millisecond))
+	tokens, lastRefill := bkt.refresh(now)
+	if tokens != 5 {
+		t.Errorf("expected 5 tokens, got %d", tokens)
+	}
+	if lastRefill.Before(now.Add(99 * time.Millisecond)) {
+		t.Errorf("expected the last refill to stay at the later reading, got %v", lastRefill.Sub(now))
+	}
+}
+
+func TestBucketHold(t *testing.T) {
+	tests := []struct {
+		name      string
+		newBucket func(key string, tokens, maxTokens int64, refill time.Duration, lastRefill time.Time, debt int64) *bucket
+	}{
+		{name: "packed", newBucket: newBucket},
+		{name: "optimistic", newBucket: newOptimisticBucket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			bkt := tt.newBucket("key", 5, 5, time.Hour, now, 0)
+
+			// Releasing without taking leaves the balance alone
+			if h := bkt.hold(now); h.tokens != 5 {
+				t.Errorf("expected to hold 5 tokens, got %d", h.tokens)
+			} else {
+				bkt.release(h, 0)
+			}
+			if tokens, _ := bkt.refresh(now); tokens != 5 {
+				t.Errorf("expected 5 tokens after a release, got %d", tokens)
+			}
+
+			// A take waits for the hold to end and sees what the release left
+			h := bkt.hold(now)
+			taken := make(chan bool)
+			go func() { taken <- bkt.take(3, now) }()
+
+			select {
+			case <-taken:
+				t.Fatal("expected the take to wait while the bucket is held")
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			bkt.release(h, 3)
+			if <-taken {
+				t.Error("expected the take to be denied once the release took 3 of 5 tokens")
+			}
+			if tokens, _ := bkt.refresh(now); tokens != 2 {
+				t.Errorf("expected 2 tokens, got %d", tokens)
+			}
+		})
+	}
+}
+
+func TestBucketDebt(t *testing.T) {
+	now := time.Now()
+	bkt := newBucket("key", 2, 2, 10*time.Millisecond, now, 3)
+
+	if !bkt.take(5, now) {
+		t.Fatal("expected take to borrow up to the debt")
+	}
+	if bkt.take(1, now) {
+		t.Error("expected take beyond the debt to be denied")
+	}
+	if tokens, _ := bkt.refresh(now); tokens != -3 {
+		t.Errorf("expected a balance of -3, got %d", tokens)
+	}
+
+	// Refills pay off the debt before new tokens accrue
+	if tokens, _ := bkt.refresh(now.Add(20 * time.Millisecond)); tokens != -1 {
+		t.Errorf("expected a balance of -1, got %d", tokens)
+	}
+	if tokens, _ := bkt.refresh(now.Add(time.Second)); tokens != 2 {
+		t.Errorf("expected refills to stop at the limit, got %d", tokens)
+	}
+
+	// Lowering the limit clamps the balance, not the debt
+	bkt.setLimit(1, 10*time.Millisecond, now.Add(time.Second))
+	if !bkt.take(4, now.Add(time.Second)) {
+		t.Error("expected take to borrow up to the debt after lowering the limit")
+	}
+	if tokens, _ := bkt.refresh(now.Add(time.Second)); tokens != -3 {
+		t.Errorf("expected a balance of -3, got %d", tokens)
+	}
+}
+
+func TestBucketSchedule(t *testing.T) {
+	for _, optimistic := range []bool{false, true} {
+		start := time.Now()
+		bkt := newBucket("key", 5, 5, 100*time.Millisecond, start, 0)
+		if optimistic {
+			bkt = newOptimisticBucket("key", 5, 5, 100*time.Millisecond, start, 0)
+		}
+
+		steps := []struct {
+			at       time.Duration
+			tokens   int64
+			expected time.Duration
+		}{
+			// Covered by the balance, so the caller proceeds at once
+			{at: 0, tokens: 3, expected: 0},
+			// Two tokens short, reserved from the next two refills
+			{at: 0, tokens: 4, expected: 200 * time.Millisecond},
+			// Queued behind the reservation before it
+			{at: 50 * time.Millisecond, tokens: 1, expected: 300 * time.Millisecond},
+			// Refills after the reservations are available again
+			{at: 500 * time.Millisecond, tokens: 2, expected: 500 * time.Millisecond},
+		}
+
+		for i, step := range steps {
+			at, ok := bkt.schedule(step.tokens, start.Add(step.at))
+			if !ok {
+				t.Fatalf("optimistic %v, step %d: expected tokens to be scheduled", optimistic, i)
+			}
+			if got := at.Sub(start); got != step.expected {
+				t.Errorf("optimistic %v, step %d: expected to proceed at %v, got %v", optimistic, i, step.expected, got)
+			}
+		}
+
+		// Takes are denied while the refills are reserved
+		if bkt.take(1, start.Add(250*time.Millisecond)) {
+			t.Errorf("optimistic %v: expected take during a reservation to be denied", optimistic)
+		}
+
+		// More than the bucket can ever hold is never covered
+		if _, ok := bkt.schedule(6, start.Add(time.Hour)); ok {
+			t.Errorf("optimistic %v: expected tokens over the limit not to be scheduled", optimistic)
+		}
+	}
+}
+
+func TestBucketScheduleStrategy(t *testing.T) {
+	start := time.Now()
+	bkt := newBucket("key", 8, 8, 100*time.Millisecond, start, 0)
+	bkt.setStrategy(ExponentialRefill{}, start)
+
+	bkt.schedule(8, start)
+
+	// An empty bucket regains 4 then 2 tokens, so 6 need two intervals
+	at, _ := bkt.schedule(6, start)
+	if got := at.Sub(start); got != 200*time.Millisecond {
+		t.Errorf("expected to proceed at 200ms, got %v", got)
+	}
+}
+
+func TestUnreserved(t *testing.T) {
+	now := time.Now()
+	refill := 100 * time.Millisecond
+
+	tests := []struct {
+		name           string
+		tokens         int64
+		lastRefill     time.Time
+		expectedTokens int64
+		expectedRefill time.Time
+	}{
+		{name: "no reservation", tokens: 3, lastRefill: now.Add(-50 * time.Millisecond), expectedTokens: 3, expectedRefill: now.Add(-50 * time.Millisecond)},
+		{name: "whole intervals", tokens: 0, lastRefill: now.Add(200 * time.Millisecond), expectedTokens: -2, expectedRefill: now},
+		{name: "partial interval", tokens: -1, lastRefill: now.Add(150 * time.Millisecond), expectedTokens: -3, expectedRefill: now.Add(-50 * time.Millisecond)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, lastRefill := unreserved(tt.tokens, tt.lastRefill, now, refill)
+			if tokens != tt.expectedTokens || !lastRefill.Equal(tt.expectedRefill) {
+				t.Errorf("expected %d tokens refilled at %v, got %d at %v", tt.expectedTokens, tt.expectedRefill.Sub(now), tokens, lastRefill.Sub(now))
+			}
+		})
+	}
+}
+
+func TestBucketTakeGrace(t *testing.T) {
+	now := time.Now()
+	until := now.Add(time.Minute)
+	bkt := newBucket("key", 2, 2, time.Hour, now, 0)
+	bkt.grace.Store(3)
+
+	if !bkt.takeGrace(2, until, now) {
+		t.Fatal("expected take within the grace budget to be allowed")
+	}
+	if bkt.takeGrace(2, until, now) {
+		t.Error("expected take beyond the grace budget to be denied")
+	}
+	if bkt.takeGrace(1, until, until) {
+		t.Error("expected the grace budget to end with its period")
+	}
+	if !bkt.takeGrace(1, until, until.Add(-time.Millisecond)) {
+		t.Error("expected the rest of the grace budget to be usable within its period")
+	}
+}
+
+func TestBucketTakeBurst(t *testing.T) {
+	now := time.Now()
+	bkt := newBucket("key", 2, 2, time.Hour, now, 0)
+
+	tests := []struct {
+		name    string
+		at      time.Duration
+		tokens  int64
+		allowed bool
+		credits int64
+	}{
+		{name: "full pool", tokens: 2, allowed: true, credits: 1},
+		{name: "beyond the credits", tokens: 2, allowed: false, credits: 1},
+		{name: "partial interval", at: 90 * time.Second, tokens: 2, allowed: true, credits: 0},
+		{name: "partial interval kept", at: 110 * time.Second, tokens: 1, allowed: false, credits: 0},
+		{name: "one credit regained", at: 2 * time.Minute, tokens: 1, allowed: true, credits: 0},
+		{name: "pool full again", at: time.Hour, tokens: 3, allowed: true, credits: 0},
+	}
+
+	for _, tt := range tests {
+		at := now.Add(tt.at)
+		if allowed := bkt.takeBurst(tt.tokens, 3, time.Minute, at); allowed != tt.allowed {
+			t.Errorf("%s: expected allowed %v, got %v", tt.name, tt.allowed, allowed)
+		}
+		if credits := bkt.burstCredits(3, time.Minute, at); credits != tt.credits {
+			t.Errorf("%s: expected %d credits left, got %d", tt.name, tt.credits, credits)
+		}
+	}
+
+	// The pool is kept apart from the balance
+	if tokens, _ := bkt.refresh(now.Add(time.Hour)); tokens != 2 {
+		t.Errorf("expected the bucket to be untouched, got %d tokens", tokens)
+	}
+}
+
+func TestBucketConcurrentTake(t *testing.T) {
+	now := time.Now()
+	bkt := newBucket("key", 1000, 1000, time.Hour, now, 0)
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if bkt.take(1, now) {
+					allowed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed.Load() != 1000 {
+		t.Errorf("expected exactly 1000 takes to succeed, got %d", allowed.Load())
+	}
+}
+
+func TestBucketViewConsistent(t *testing.T) {
+	now := time.Now()
+	bkt := newBucket("key", 10, 10, time.Second, now, 0)
+	stepped := SteppedRefill{Tokens: 5}
+
+	// Each limit comes with its own refill rate and strategy, a view mixing two of them is torn
+	limits := []struct {
+		limit    int64
+		refill   time.Duration
+		strategy RefillStrategy
+	}{
+		{limit: 10, refill: time.Second, strategy: nil},
+		{limit: 1000, refill: time.Hour, strategy: stepped},
+	}
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; !stop.Load(); i++ {
+			l := limits[i%2]
+			bkt.setLimit(l.limit, l.refill, now)
+			bkt.setStrategy(l.strategy, now)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for !stop.Load() {
+			bkt.take(1, now)
+		}
+	}()
+
+	for i := 0; i < 10000; i++ {
+		view := bkt.view(now)
+		if view.tokens > view.maxTokens {
+			t.Fatalf("expected at most %d tokens, got %d", view.maxTokens, view.tokens)
+		}
+		if view.maxTokens == 10 && view.refill != time.Second || view.maxTokens == 1000 && view.refill != time.Hour {
+			t.Fatalf("expected the refill rate of limit %d, got %v", view.maxTokens, view.refill)
+		}
+	}
+
+	stop.Store(true)
+	wg.Wait()
+
+	// Limits and strategy are set apart, so only a view between changes needs them to match
+	view := bkt.view(now)
+	if _, isStepped := view.strategy.(SteppedRefill); isStepped != (view.maxTokens == 1000) {
+		t.Errorf("expected the strategy of limit %d, got %v", view.maxTokens, view.strategy)
+	}
+}
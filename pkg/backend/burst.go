@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"time"
+)
+
+// burstState is how much of its burst pool a bucket has spent and when the pool last regained a credit
+// Buckets keep none until they first spend from the pool, a bucket without one has a full pool
+type burstState struct {
+	spent      int64
+	lastRefill time.Time
+}
+
+// burstRefilled returns the credits still spent after regaining one every refill since last, and the new last refill
+// The partial interval is kept so the pool does not drift, a pool that regained everything starts over from now
+func burstRefilled(spent int64, last, now time.Time, refill time.Duration) (int64, time.Time) {
+	elapsed := now.Sub(last)
+	if spent <= 0 || elapsed < refill {
+		return max(spent, 0), last
+	}
+
+	intervals := int64(elapsed / refill)
+	if intervals >= spent {
+		return 0, now
+	}
+
+	return spent - intervals, last.Add(time.Duration(intervals) * refill)
+}
+
+// takeBurst consumes tokens from the burst pool of the bucket if its credits cover them
+func (bkt *bucket) takeBurst(tokens, capacity int64, refill time.Duration, now time.Time) bool {
+	for {
+		old := bkt.burst.Load()
+		spent, last := bkt.burstSpent(old, refill, now)
+		if capacity-spent < tokens {
+			return false
+		}
+
+		// A full pool starts its next refill interval now
+		if spent == 0 {
+			last = now
+		}
+
+		if bkt.burst.CompareAndSwap(old, &burstState{spent: spent + tokens, lastRefill: last}) {
+			return true
+		}
+	}
+}
+
+// burstCredits returns the credits left in the burst pool of the bucket at now
+func (bkt *bucket) burstCredits(capacity int64, refill time.Duration, now time.Time) int64 {
+	spent, _ := bkt.burstSpent(bkt.burst.Load(), refill, now)
+	return max(0, capacity-spent)
+}
+
+// burstSpent returns the credits a burst state still has spent at now and its last refill
+func (bkt *bucket) burstSpent(state *burstState, refill time.Duration, now time.Time) (int64, time.Time) {
+	if state == nil {
+		return 0, now
+	}
+
+	return burstRefilled(state.spent, state.lastRefill, now, refill)
+}
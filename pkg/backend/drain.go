@@ -0,0 +1,89 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// validateDrain checks the tokens and floor passed to Drain
+func validateDrain(tokens, floor int64) error {
+	if err := validateTokens(tokens); err != nil {
+		return err
+	}
+
+	if floor < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "floor must not be negative")
+	}
+
+	return nil
+}
+
+// drain removes up to tokens from the balance after refilling it, leaving at least floor
+func (bkt *bucket) drain(tokens, floor int64, now time.Time) {
+	if bkt.value.Load() != nil {
+		bkt.drainOptimistic(tokens, floor, now)
+		return
+	}
+
+	for {
+		old := bkt.load()
+		state := bkt.refilled(old, now)
+
+		// The packed balance is offset by the debt allowed
+		available, ticks := unpackState(state)
+		drained := min(tokens, available-bkt.debt-floor)
+		if drained <= 0 {
+			if state == old || bkt.state.CompareAndSwap(old, state) {
+				return
+			}
+			continue
+		}
+
+		if bkt.state.CompareAndSwap(old, packState(available-drained, ticks)) {
+			return
+		}
+	}
+}
+
+// drainOptimistic removes up to tokens from the balance of an optimistic bucket, as drain does
+func (bkt *bucket) drainOptimistic(tokens, floor int64, now time.Time) {
+	for {
+		old := bkt.loadValue()
+		available, lastRefill := old.at(now, bkt.debt)
+		drained := min(tokens, available-bkt.debt-floor)
+		if drained <= 0 {
+			return
+		}
+
+		if bkt.value.CompareAndSwap(old, old.next(available-drained, lastRefill, old.maxTokens, old.refill)) {
+			return
+		}
+	}
+}
+
+// Drain removes up to tokens from the balance of a key, leaving at least floor, without counting a request
+func (b *inMemoryBackend) Drain(ctx context.Context, key string, tokens int64, floor int64) error {
+	if b.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	if err := validateDrain(tokens, floor); err != nil {
+		return err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	b.getOrCreateBucket(key).drain(tokens, floor, time.Now())
+	return nil
+}
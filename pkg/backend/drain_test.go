@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// drainTestOptions give keys 10 tokens, a grace budget and a burst pool, none of which refill during a test
+func drainTestOptions() *Options {
+	return DefaultOptions().WithLimit(10).WithRefill(time.Hour).WithGrace(3, time.Hour).WithBurstPool(5, time.Hour)
+}
+
+// testBackendDrain checks that Drain lowers balances without spending grace or burst credits or counting requests
+func testBackendDrain(t *testing.T, b Backend) {
+	t.Helper()
+	ctx := context.Background()
+	drainer := b.(Drainer)
+
+	// The grace budget pays for the first take, the balance stays full
+	if allowed, err := b.Take(ctx, "key", 1); err != nil || !allowed {
+		t.Fatalf("expected take to be allowed, got %v, %v", allowed, err)
+	}
+
+	steps := []struct {
+		tokens int64
+		floor  int64
+		want   int64
+	}{
+		{tokens: 4, floor: 0, want: 6},
+		{tokens: 100, floor: 2, want: 2},
+		{tokens: 1, floor: 5, want: 2},
+		{tokens: 100, floor: 0, want: 0},
+	}
+	for _, step := range steps {
+		if err := drainer.Drain(ctx, "key", step.tokens, step.floor); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		info, err := b.GetInfo(ctx, "key")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Tokens != step.want {
+			t.Errorf("expected %d tokens after draining %d to %d, got %d", step.want, step.tokens, step.floor, info.Tokens)
+		}
+	}
+
+	// Draining left the rest of the grace budget and the burst pool alone
+	if allowed, _ := b.Take(ctx, "key", 2); !allowed {
+		t.Error("expected the grace budget to be left after draining")
+	}
+	if allowed, _ := b.Take(ctx, "key", 5); !allowed {
+		t.Error("expected the burst pool to be left after draining")
+	}
+
+	// Only the takes are counted
+	if reporter, ok := b.(StatsReporter); ok {
+		stats, err := reporter.Stats(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stats.Allowed != 3 || stats.Denied != 0 {
+			t.Errorf("expected 3 allowed and no denied takes, got %d and %d", stats.Allowed, stats.Denied)
+		}
+	}
+
+	// Blocks stop takes, not drains
+	if err := b.Block(ctx, "blocked", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := drainer.Drain(ctx, "blocked", 4, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, _ := b.GetInfo(ctx, "blocked"); info.Tokens != 6 {
+		t.Errorf("expected 6 tokens on the blocked key, got %d", info.Tokens)
+	}
+
+	if err := drainer.Drain(ctx, "key", 0, 0); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error for zero tokens, got %v", err)
+	}
+	if err := drainer.Drain(ctx, "key", 1, -1); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error for a negative floor, got %v", err)
+	}
+	if err := drainer.Drain(ctx, "", 1, 0); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error, got %v", err)
+	}
+}
+
+func TestInMemoryBackendDrain(t *testing.T) {
+	for _, optimistic := range []bool{false, true} {
+		b, err := NewInMemoryBackend(drainTestOptions().WithOptimisticBuckets(optimistic))
+		if err != nil {
+			t.Fatalf("failed to create backend: %v", err)
+		}
+		testBackendDrain(t, b)
+		b.Close(context.Background())
+	}
+}
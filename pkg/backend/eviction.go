@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"sync"
+	"time"
+)
+
+// evictionSubscriber is one function subscribed to evictions
+type evictionSubscriber struct {
+	fn func(key string)
+}
+
+// evictionSubscribers tracks the functions called when cleanup evicts a key
+type evictionSubscribers struct {
+	mu   sync.Mutex
+	subs map[*evictionSubscriber]struct{}
+}
+
+// add subscribes fn and returns a function to unsubscribe it
+func (e *evictionSubscribers) add(fn func(key string)) func() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.subs == nil {
+		e.subs = make(map[*evictionSubscriber]struct{})
+	}
+
+	sub := &evictionSubscriber{fn: fn}
+	e.subs[sub] = struct{}{}
+
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		delete(e.subs, sub)
+	}
+}
+
+// has reports whether any function is subscribed
+func (e *evictionSubscribers) has() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.subs) > 0
+}
+
+// notify calls every subscribed function with each evicted key, outside the lock so they may call the backend
+func (e *evictionSubscribers) notify(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	e.mu.Lock()
+	fns := make([]func(key string), 0, len(e.subs))
+	for sub := range e.subs {
+		fns = append(fns, sub.fn)
+	}
+	e.mu.Unlock()
+
+	for _, key := range keys {
+		for _, fn := range fns {
+			fn(key)
+		}
+	}
+}
+
+// SubscribeEvictions calls fn with every key whose bucket the cleanup drops, and returns a function to unsubscribe
+// Buckets evicted to stay under MaxMemoryBytes are not reported
+func (b *inMemoryBackend) SubscribeEvictions(fn func(key string)) func() {
+	return b.evictions.add(fn)
+}
+
+// expireBuckets drops the buckets that were not refilled since cutoff from the i-th shard, or every shard when i is negative,
+// and reports them to the eviction subscribers
+func (b *inMemoryBackend) expireBuckets(i int, cutoff time.Time) {
+	var evicted []string
+	notify := b.evictions.has()
+
+	expired := func(bkt *bucket) bool {
+		if !bkt.lastRefill().Before(cutoff) {
+			return false
+		}
+		if notify {
+			evicted = append(evicted, bkt.Key)
+		}
+		return true
+	}
+
+	if i < 0 {
+		b.store.deleteIf(expired)
+	} else {
+		b.store.deleteIfInShard(i, expired)
+	}
+
+	b.evictions.notify(evicted)
+}
@@ -0,0 +1,636 @@
+package backend
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// fallbackRetention is how long the last-known state of a key is kept after its last Take
+const fallbackRetention = 10 * time.Minute
+
+// FallbackOptions configures the fallback backend
+type FallbackOptions struct {
+	// Nodes is the number of instances sharing each limit, each one enforces its share locally while the shared backend is unreachable
+	Nodes int `json:"nodes"`
+
+	// SyncInterval is how often the last-known state of recently used keys is refreshed,
+	// and how often the shared backend is probed while it is unreachable
+	SyncInterval time.Duration `json:"sync_interval"`
+
+	// Limits are the options of the shared backend, whose default limit and refill apply to keys without a last-known state
+	// nil uses DefaultOptions
+	Limits *Options `json:"limits,omitempty"`
+}
+
+// DefaultFallbackOptions returns default options for the fallback backend
+func DefaultFallbackOptions() *FallbackOptions {
+	return &FallbackOptions{
+		Nodes:        1,
+		SyncInterval: time.Second,
+	}
+}
+
+// Validate validates the fallback options
+func (o *FallbackOptions) Validate() error {
+	if o.Nodes <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "nodes must be positive")
+	}
+
+	if o.SyncInterval <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "sync_interval must be positive")
+	}
+
+	if o.Limits != nil {
+		if err := o.Limits.Validate(); err != nil {
+			return errors.Wrap(err, "invalid limits")
+		}
+	}
+
+	return nil
+}
+
+// fallbackBackend passes calls to a shared backend and keeps enforcing limits locally while it is unreachable
+// Locally each key starts from this node's share of its last-known state, and the tokens granted are taken
+// from the shared backend once it is reachable again
+type fallbackBackend struct {
+	remote  Backend
+	drainer Drainer
+	options *FallbackOptions
+	keys    sync.Map
+	stop    chan struct{}
+	done    chan struct{}
+	closeMu sync.Mutex
+	closed  atomic.Bool
+
+	// mu is held for reading by local Takes and for writing to switch between the shared and the local backend
+	mu          sync.RWMutex
+	partitioned atomic.Bool
+	local       *inMemoryBackend
+}
+
+// fallbackKey is the last-known state of one key and what was granted for it locally
+type fallbackKey struct {
+	mu       sync.Mutex
+	touched  bool
+	lastUsed time.Time
+
+	// known is set once the state below was read from the shared backend
+	known        bool
+	tokens       int64
+	maxTokens    int64
+	refill       time.Duration
+	lastRefill   time.Time
+	strategy     RefillStrategy
+	blockedUntil time.Time
+
+	// seeded is set once the local bucket was created, consumed counts the tokens it granted
+	seeded   bool
+	consumed int64
+}
+
+// NewFallbackBackend wraps a shared backend, usually Redis, with a local fallback for network partitions
+// Timeouts and connection failures switch every node to its local buckets until the shared backend answers a health check again
+func NewFallbackBackend(remote Backend, options *FallbackOptions) (Backend, error) {
+	if remote == nil {
+		return nil, errors.Wrap(errors.ErrBackendUnavailable, "remote backend cannot be nil")
+	}
+
+	// Tokens granted locally are settled by draining the shared buckets
+	drainer, ok := remote.(Drainer)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrBackendUnavailable, "remote backend must implement Drainer")
+	}
+
+	if options == nil {
+		options = DefaultFallbackOptions()
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+
+	backend := &fallbackBackend{
+		remote:  remote,
+		drainer: drainer,
+		options: options,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	local, err := backend.newLocal()
+	if err != nil {
+		return nil, err
+	}
+	backend.local = local
+
+	go backend.syncRoutine()
+
+	return backend, nil
+}
+
+// Take consumes tokens from the shared backend, or from the local bucket of the key while it is unreachable
+func (f *fallbackBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	if f.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	take := func(b Backend) (bool, error) { return b.Take(ctx, key, tokens) }
+	return f.take(ctx, []string{key}, tokens, take)
+}
+
+// TakeAll consumes tokens from every listed bucket of the shared backend, or from the local buckets while it is unreachable
+func (f *fallbackBackend) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	if f.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	for _, key := range keys {
+		if err := validateKey(key); err != nil {
+			return false, err
+		}
+	}
+
+	take := func(b Backend) (bool, error) { return b.TakeAll(ctx, keys, tokens) }
+	return f.take(ctx, keys, tokens, take)
+}
+
+// take runs a take on the shared backend, switching to the local backend when it cannot be reached
+func (f *fallbackBackend) take(ctx context.Context, keys []string, tokens int64, take func(Backend) (bool, error)) (bool, error) {
+	if !f.partitioned.Load() {
+		allowed, err := take(f.remote)
+		if !f.unreachable(ctx, err) {
+			if err == nil {
+				f.touch(keys)
+			}
+			return allowed, err
+		}
+
+		f.partition()
+	}
+
+	f.mu.RLock()
+	if !f.partitioned.Load() {
+		// The shared backend came back in the meantime
+		f.mu.RUnlock()
+		return take(f.remote)
+	}
+	defer f.mu.RUnlock()
+
+	for _, key := range keys {
+		f.seed(key)
+	}
+
+	allowed, err := take(f.local)
+	if err != nil || !allowed {
+		return allowed, err
+	}
+
+	for _, key := range keys {
+		k := f.key(key)
+		k.mu.Lock()
+		k.consumed += tokens
+		k.mu.Unlock()
+	}
+
+	return true, nil
+}
+
+// Reset clears the rate limit for a specific key on the shared backend
+// Like SetLimit, Block and Unblock it needs the shared backend, and fails while it is unreachable
+func (f *fallbackBackend) Reset(ctx context.Context, key string) error {
+	if f.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := f.remote.Reset(ctx, key); err != nil {
+		return err
+	}
+
+	f.invalidate(key)
+	return nil
+}
+
+// GetInfo returns the state of a key on the shared backend, or of its local bucket while the shared backend is unreachable
+// A local bucket holds this node's share of the key
+func (f *fallbackBackend) GetInfo(ctx context.Context, key string) (*TokenInfo, error) {
+	if f.closed.Load() {
+		return nil, errors.ErrBackendClosed
+	}
+
+	if !f.partitioned.Load() {
+		info, err := f.remote.GetInfo(ctx, key)
+		if !f.unreachable(ctx, err) {
+			if err == nil {
+				f.remember(key, info)
+			}
+			return info, err
+		}
+
+		f.partition()
+	}
+
+	f.mu.RLock()
+	if !f.partitioned.Load() {
+		f.mu.RUnlock()
+		return f.remote.GetInfo(ctx, key)
+	}
+	defer f.mu.RUnlock()
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	f.seed(key)
+	return f.local.GetInfo(ctx, key)
+}
+
+// SetLimit sets a custom limit for a specific key on the shared backend
+func (f *fallbackBackend) SetLimit(ctx context.Context, key string, limit int64, refill time.Duration) error {
+	if f.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := f.remote.SetLimit(ctx, key, limit, refill); err != nil {
+		return err
+	}
+
+	f.invalidate(key)
+	return nil
+}
+
+// Drain removes up to tokens from the balance of a key on the shared backend, leaving at least floor
+func (f *fallbackBackend) Drain(ctx context.Context, key string, tokens int64, floor int64) error {
+	if f.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := f.drainer.Drain(ctx, key, tokens, floor); err != nil {
+		return err
+	}
+
+	f.invalidate(key)
+	return nil
+}
+
+// Block denies all Takes for a specific key on the shared backend until the duration expires
+func (f *fallbackBackend) Block(ctx context.Context, key string, duration time.Duration) error {
+	if f.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := f.remote.Block(ctx, key, duration); err != nil {
+		return err
+	}
+
+	f.invalidate(key)
+	return nil
+}
+
+// Unblock lifts a block on a specific key on the shared backend before it expires
+func (f *fallbackBackend) Unblock(ctx context.Context, key string) error {
+	if f.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := f.remote.Unblock(ctx, key); err != nil {
+		return err
+	}
+
+	f.invalidate(key)
+	return nil
+}
+
+// Close reconciles the tokens granted locally if the shared backend is reachable, then closes both backends
+// Tokens that cannot be reconciled are lost with the instance
+func (f *fallbackBackend) Close(ctx context.Context) error {
+	return shutdownOnce(&f.closeMu, &f.closed, func() error {
+		close(f.stop)
+		<-f.done
+
+		if f.partitioned.Load() && f.remote.HealthCheck(ctx) == nil {
+			f.reconcile(ctx)
+		}
+
+		f.mu.Lock()
+		f.local.Close(ctx)
+		f.mu.Unlock()
+
+		return f.remote.Close(ctx)
+	})
+}
+
+// HealthCheck performs a health check on the shared backend
+// It fails while the shared backend is unreachable, even though Takes are still served locally
+func (f *fallbackBackend) HealthCheck(ctx context.Context) error {
+	if f.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	return f.remote.HealthCheck(ctx)
+}
+
+// SubscribeWakeups passes on the wakeups of the shared backend, nil when it does not announce any
+func (f *fallbackBackend) SubscribeWakeups(key string) (<-chan struct{}, func()) {
+	notifier, ok := f.remote.(WakeupNotifier)
+	if !ok || f.closed.Load() {
+		return nil, func() {}
+	}
+
+	return notifier.SubscribeWakeups(key)
+}
+
+// unreachable reports whether err means the shared backend cannot be reached, rather than a failure of the call or its context
+func (f *fallbackBackend) unreachable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	// Failures the backend raises itself, such as being closed or reading malformed data, are not partitions
+	if stderrors.Is(err, errors.ErrBackendUnavailable) {
+		return false
+	}
+
+	switch errors.Classify(err) {
+	case errors.ClassTimeout, errors.ClassConnection:
+		return true
+	default:
+		return false
+	}
+}
+
+// partition switches to the local backend until the shared backend answers a health check again
+func (f *fallbackBackend) partition() {
+	f.mu.Lock()
+	f.partitioned.Store(true)
+	f.mu.Unlock()
+}
+
+// key returns the state of a key, creating it on first use
+func (f *fallbackBackend) key(key string) *fallbackKey {
+	if val, ok := f.keys.Load(key); ok {
+		return val.(*fallbackKey)
+	}
+
+	val, _ := f.keys.LoadOrStore(key, &fallbackKey{lastUsed: time.Now()})
+	return val.(*fallbackKey)
+}
+
+// touch marks keys as used, so their state is refreshed on the next sync
+func (f *fallbackBackend) touch(keys []string) {
+	now := time.Now()
+	for _, key := range keys {
+		k := f.key(key)
+		k.mu.Lock()
+		k.touched = true
+		k.lastUsed = now
+		k.mu.Unlock()
+	}
+}
+
+// remember stores the state of a key as read from the shared backend
+func (f *fallbackBackend) remember(key string, info *TokenInfo) {
+	k := f.key(key)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.known = true
+	k.tokens = info.Tokens
+	k.maxTokens = info.MaxTokens
+	k.refill = info.RefillRate
+	k.lastRefill = info.LastRefill
+	k.strategy = info.Strategy
+	k.blockedUntil = info.BlockedUntil
+}
+
+// invalidate forgets the last-known state of a key after it was changed, so the next sync reads it again
+func (f *fallbackBackend) invalidate(key string) {
+	val, ok := f.keys.Load(key)
+	if !ok {
+		return
+	}
+
+	k := val.(*fallbackKey)
+	k.mu.Lock()
+	k.known = false
+	k.touched = true
+	k.mu.Unlock()
+}
+
+// seed creates the local bucket of a key from this node's share of its last-known state, once per partition
+// Keys without a last-known state get a local bucket from the default limits on their first Take
+// Called with f.mu held for reading
+func (f *fallbackBackend) seed(key string) {
+	k := f.key(key)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.seeded {
+		return
+	}
+	k.seeded = true
+
+	if !k.known || k.maxTokens <= 0 || k.refill <= 0 {
+		return
+	}
+
+	maxTokens := min(fallbackShare(k.maxTokens, f.options.Nodes), MaxLimit-f.local.options.MaxDebt)
+	tokens := min(fallbackShare(k.tokens, f.options.Nodes), maxTokens)
+	f.local.seed(key, tokens, maxTokens, fallbackRefill(k.refill, f.options.Nodes), k.lastRefill, k.strategy)
+
+	if until := k.blockedUntil; until.After(time.Now()) {
+		f.local.Block(context.Background(), key, time.Until(until))
+	}
+}
+
+// newLocal creates the local backend, whose default limits are this node's share of the shared defaults
+func (f *fallbackBackend) newLocal() (*inMemoryBackend, error) {
+	limits := f.options.Limits
+	if limits == nil {
+		limits = DefaultOptions()
+	}
+
+	options := DefaultOptions().
+		WithLimit(fallbackShare(limits.DefaultLimit, f.options.Nodes)).
+		WithRefill(fallbackRefill(limits.DefaultRefill, f.options.Nodes)).
+		WithMaxDebt(limits.MaxDebt)
+	options.MaxKeys = limits.MaxKeys
+
+	local, err := NewInMemoryBackend(options)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create local backend")
+	}
+
+	return local.(*inMemoryBackend), nil
+}
+
+// syncRoutine refreshes the state of recently used keys once per interval, or probes the shared backend while it is unreachable
+func (f *fallbackBackend) syncRoutine() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.options.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), f.options.SyncInterval)
+			if !f.partitioned.Load() {
+				f.refreshAll(ctx)
+			} else if f.remote.HealthCheck(ctx) == nil {
+				f.reconcile(ctx)
+			}
+			cancel()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// refreshAll reads the state of the keys used since the previous sync and forgets keys idle for longer than fallbackRetention
+func (f *fallbackBackend) refreshAll(ctx context.Context) {
+	now := time.Now()
+	f.keys.Range(func(k, v interface{}) bool {
+		key, state := k.(string), v.(*fallbackKey)
+
+		state.mu.Lock()
+		touched := state.touched
+		state.touched = false
+		idle := !touched && state.consumed == 0 && now.Sub(state.lastUsed) > fallbackRetention
+		state.mu.Unlock()
+
+		if idle {
+			f.keys.CompareAndDelete(key, state)
+			return true
+		}
+
+		if !touched {
+			return true
+		}
+
+		info, err := f.remote.GetInfo(ctx, key)
+		if err != nil {
+			if f.unreachable(ctx, err) {
+				f.partition()
+				return false
+			}
+			return true
+		}
+
+		f.remember(key, info)
+		return true
+	})
+}
+
+// reconcile switches back to the shared backend and takes the tokens granted locally from it
+// If the shared backend fails again, the tokens not yet taken are kept for the next reconciliation
+func (f *fallbackBackend) reconcile(ctx context.Context) {
+	f.mu.Lock()
+	if !f.partitioned.Load() {
+		f.mu.Unlock()
+		return
+	}
+
+	owed := make(map[string]int64)
+	f.keys.Range(func(k, v interface{}) bool {
+		key, state := k.(string), v.(*fallbackKey)
+
+		state.mu.Lock()
+		if state.consumed > 0 {
+			owed[key] = state.consumed
+		}
+		state.consumed = 0
+		state.seeded = false
+		state.touched = true
+		state.mu.Unlock()
+
+		return true
+	})
+
+	// Local buckets start over from the last-known state on the next partition
+	old := f.local
+	if local, err := f.newLocal(); err == nil {
+		f.local = local
+	}
+	f.partitioned.Store(false)
+	f.mu.Unlock()
+
+	if old != f.local {
+		old.Close(ctx)
+	}
+
+	for key, tokens := range owed {
+		if err := f.charge(ctx, key, tokens); err != nil {
+			f.mu.Lock()
+			for rest, unpaid := range owed {
+				k := f.key(rest)
+				k.mu.Lock()
+				k.consumed += unpaid
+				k.mu.Unlock()
+			}
+			f.partitioned.Store(true)
+			f.mu.Unlock()
+			return
+		}
+
+		delete(owed, key)
+	}
+}
+
+// charge drains tokens granted locally from the shared bucket of a key
+// A bucket holding fewer tokens than owed is emptied, since the tokens were already granted
+func (f *fallbackBackend) charge(ctx context.Context, key string, tokens int64) error {
+	return f.drainer.Drain(ctx, key, tokens, 0)
+}
+
+// fallbackShare returns one node's share of a token count, rounded up so small limits still admit a request per node
+func fallbackShare(tokens int64, nodes int) int64 {
+	if tokens <= 0 {
+		return tokens / int64(nodes)
+	}
+
+	return (tokens + int64(nodes) - 1) / int64(nodes)
+}
+
+// fallbackRefill returns the refill interval of one node's share, nodes times the shared one
+func fallbackRefill(refill time.Duration, nodes int) time.Duration {
+	if refill > math.MaxInt64/time.Duration(nodes) {
+		return math.MaxInt64
+	}
+
+	return refill * time.Duration(nodes)
+}
+
+// seed stores a bucket for a key with the given state, replacing any bucket it has
+func (b *inMemoryBackend) seed(key string, tokens, maxTokens int64, refill time.Duration, lastRefill time.Time, strategy RefillStrategy) {
+	bkt := b.newBucket(key, tokens, maxTokens, refill, lastRefill)
+	bkt.setStrategy(strategy, bkt.epoch)
+	b.store.store(key, bkt)
+}
+
+// String returns a string representation of the backend
+func (f *fallbackBackend) String() string {
+	return fmt.Sprintf("FallbackBackend{nodes=%d, sync_interval=%v, partitioned=%t}",
+		f.options.Nodes, f.options.SyncInterval, f.partitioned.Load())
+}
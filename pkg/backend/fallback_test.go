@@ -0,0 +1,267 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// partitionedBackend fails every call with a connection error while down
+type partitionedBackend struct {
+	Backend
+	down atomic.Bool
+}
+
+func (p *partitionedBackend) err() error {
+	if p.down.Load() {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+	return nil
+}
+
+func (p *partitionedBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	if err := p.err(); err != nil {
+		return false, err
+	}
+	return p.Backend.Take(ctx, key, tokens)
+}
+
+func (p *partitionedBackend) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	if err := p.err(); err != nil {
+		return false, err
+	}
+	return p.Backend.TakeAll(ctx, keys, tokens)
+}
+
+func (p *partitionedBackend) GetInfo(ctx context.Context, key string) (*TokenInfo, error) {
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return p.Backend.GetInfo(ctx, key)
+}
+
+func (p *partitionedBackend) Drain(ctx context.Context, key string, tokens int64, floor int64) error {
+	if err := p.err(); err != nil {
+		return err
+	}
+	return p.Backend.(Drainer).Drain(ctx, key, tokens, floor)
+}
+
+func (p *partitionedBackend) HealthCheck(ctx context.Context) error {
+	if err := p.err(); err != nil {
+		return err
+	}
+	return p.Backend.HealthCheck(ctx)
+}
+
+// newTestFallbackBackend wraps an in-memory backend with the given limit, syncing only when the test asks
+func newTestFallbackBackend(t *testing.T, limit int64, nodes int) (*fallbackBackend, *partitionedBackend) {
+	t.Helper()
+
+	limits := DefaultOptions().WithLimit(limit).WithRefill(time.Hour)
+	inner, err := NewInMemoryBackend(limits)
+	if err != nil {
+		t.Fatalf("failed to create remote backend: %v", err)
+	}
+
+	remote := &partitionedBackend{Backend: inner}
+	backend, err := NewFallbackBackend(remote, &FallbackOptions{Nodes: nodes, SyncInterval: time.Hour, Limits: limits})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close(context.Background()) })
+
+	return backend.(*fallbackBackend), remote
+}
+
+func TestNewFallbackBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		noRemote bool
+		options  *FallbackOptions
+		wantErr  bool
+	}{
+		{name: "default options"},
+		{name: "nil remote", noRemote: true, wantErr: true},
+		{name: "zero nodes", options: &FallbackOptions{SyncInterval: time.Second}, wantErr: true},
+		{name: "zero sync interval", options: &FallbackOptions{Nodes: 1}, wantErr: true},
+		{name: "invalid limits", options: &FallbackOptions{Nodes: 1, SyncInterval: time.Second, Limits: &Options{}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remote Backend
+			if !tt.noRemote {
+				remote, _ = NewInMemoryBackend(nil)
+			}
+
+			backend, err := NewFallbackBackend(remote, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if backend != nil {
+				backend.Close(context.Background())
+			}
+		})
+	}
+}
+
+func TestFallbackBackendPartition(t *testing.T) {
+	ctx := context.Background()
+	backend, remote := newTestFallbackBackend(t, 10, 2)
+
+	for i := 0; i < 4; i++ {
+		if allowed, err := backend.Take(ctx, "key", 1); err != nil || !allowed {
+			t.Fatalf("expected take to be allowed, got %v, %v", allowed, err)
+		}
+	}
+	backend.refreshAll(ctx)
+
+	// Cut off, this node enforces its half of the 6 tokens left
+	remote.down.Store(true)
+	allowed := 0
+	for i := 0; i < 6; i++ {
+		ok, err := backend.Take(ctx, "key", 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ok {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("expected 3 takes allowed locally, got %d", allowed)
+	}
+	if !strings.Contains(backend.String(), "partitioned=true") {
+		t.Errorf("expected backend to report the partition, got %s", backend.String())
+	}
+
+	// Keys never seen get their share of the default limit
+	allowed = 0
+	for i := 0; i < 10; i++ {
+		if ok, _ := backend.Take(ctx, "unseen", 1); ok {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("expected 5 takes allowed on an unseen key, got %d", allowed)
+	}
+
+	// Reconciling fails while the shared backend is still down
+	backend.reconcile(ctx)
+	if !backend.partitioned.Load() {
+		t.Fatal("expected backend to stay partitioned")
+	}
+
+	remote.down.Store(false)
+	backend.reconcile(ctx)
+	if backend.partitioned.Load() {
+		t.Fatal("expected backend to leave the partition")
+	}
+
+	tests := []struct {
+		key    string
+		tokens int64
+	}{
+		{key: "key", tokens: 3},
+		{key: "unseen", tokens: 5},
+	}
+
+	for _, tt := range tests {
+		info, err := remote.GetInfo(ctx, tt.key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Tokens != tt.tokens {
+			t.Errorf("expected %s to have %d tokens after reconciling, got %d", tt.key, tt.tokens, info.Tokens)
+		}
+	}
+}
+
+func TestFallbackBackendReconcileFailure(t *testing.T) {
+	ctx := context.Background()
+	backend, remote := newTestFallbackBackend(t, 10, 1)
+
+	remote.down.Store(true)
+	backend.Take(ctx, "key", 2)
+
+	// The shared backend fails again while reconciling, the tokens are kept for the next attempt
+	backend.reconcile(ctx)
+	if !backend.partitioned.Load() {
+		t.Fatal("expected backend to stay partitioned")
+	}
+
+	remote.down.Store(false)
+	backend.reconcile(ctx)
+
+	info, err := remote.GetInfo(ctx, "key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 8 {
+		t.Errorf("expected the tokens to be reconciled once, got %d left", info.Tokens)
+	}
+}
+
+func TestFallbackBackendReconcileBlockedKey(t *testing.T) {
+	ctx := context.Background()
+	backend, remote := newTestFallbackBackend(t, 10, 1)
+
+	remote.down.Store(true)
+	backend.Take(ctx, "key", 2)
+
+	// A key blocked on the shared backend meanwhile still owes the tokens granted locally
+	if err := remote.Backend.Block(ctx, "key", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	remote.down.Store(false)
+	backend.reconcile(ctx)
+	if backend.partitioned.Load() {
+		t.Fatal("expected backend to leave the partition")
+	}
+
+	if err := remote.Backend.Unblock(ctx, "key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, _ := remote.GetInfo(ctx, "key"); info.Tokens != 8 {
+		t.Errorf("expected 8 tokens after reconciling, got %d", info.Tokens)
+	}
+}
+
+func TestFallbackBackendKeepsBlocks(t *testing.T) {
+	ctx := context.Background()
+	backend, remote := newTestFallbackBackend(t, 10, 1)
+
+	if err := backend.Block(ctx, "key", time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := backend.GetInfo(ctx, "key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	remote.down.Store(true)
+	allowed, err := backend.Take(ctx, "key", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected the last-known block to deny takes locally")
+	}
+}
+
+func TestFallbackBackendErrorsPassThrough(t *testing.T) {
+	ctx := context.Background()
+	backend, remote := newTestFallbackBackend(t, 10, 1)
+
+	remote.Backend.Close(ctx)
+	if _, err := backend.Take(ctx, "key", 1); err == nil {
+		t.Error("expected the error of a closed shared backend")
+	}
+	if backend.partitioned.Load() {
+		t.Error("expected failures other than timeouts and connection errors not to partition")
+	}
+}
@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// defaultIdempotencyWindow is how long admitted request IDs are remembered when Options.IdempotencyWindow is 0
+const defaultIdempotencyWindow = time.Minute
+
+// maxRequestIDLength bounds request IDs, which are stored next to the key they were taken from
+const maxRequestIDLength = 256
+
+// idempotencyWindow returns the window of the options, or the default when unset
+func idempotencyWindow(options *Options) time.Duration {
+	if options.IdempotencyWindow > 0 {
+		return options.IdempotencyWindow
+	}
+	return defaultIdempotencyWindow
+}
+
+// validateRequestID checks a request ID passed to TakeOnce
+func validateRequestID(requestID string) error {
+	if requestID == "" {
+		return errors.Wrap(errors.ErrInvalidKey, "request ID cannot be empty")
+	}
+
+	if len(requestID) > maxRequestIDLength {
+		return errors.Wrapf(errors.ErrInvalidKey, "request ID too long (max %d characters)", maxRequestIDLength)
+	}
+
+	return nil
+}
+
+// requestKey identifies a request ID on a key
+type requestKey struct {
+	key       string
+	requestID string
+}
+
+// takenRequest is the take of one request ID, done is closed once allowed is decided
+// Retries arriving while the first take is in flight wait for it instead of taking again
+type takenRequest struct {
+	done    chan struct{}
+	allowed bool
+	expires time.Time
+}
+
+// requestLog remembers the request IDs of admitted takes for the idempotency window
+type requestLog struct {
+	requests sync.Map
+}
+
+// takeOnce runs take unless the request ID was admitted within the window, in which case it is admitted again
+func (l *requestLog) takeOnce(ctx context.Context, id requestKey, window time.Duration, take func() (bool, error)) (bool, error) {
+	for {
+		pending := &takenRequest{done: make(chan struct{})}
+		value, loaded := l.requests.LoadOrStore(id, pending)
+		if !loaded {
+			allowed, err := take()
+
+			// Only admitted takes are remembered, a denied or failed one is decided afresh on retry
+			if err != nil || !allowed {
+				l.requests.Delete(id)
+			} else {
+				pending.expires = time.Now().Add(window)
+			}
+			pending.allowed = allowed
+			close(pending.done)
+
+			return allowed, err
+		}
+
+		previous := value.(*takenRequest)
+		select {
+		case <-previous.done:
+		case <-ctx.Done():
+			return false, errors.Wrap(ctx.Err(), "context cancelled")
+		}
+
+		// A take that overlapped this one counts as its outcome, an expired one is taken over
+		if !previous.allowed || time.Now().Before(previous.expires) {
+			return previous.allowed, nil
+		}
+		l.requests.CompareAndDelete(id, previous)
+	}
+}
+
+// cleanup forgets the request IDs whose window ended before now
+func (l *requestLog) cleanup(now time.Time) {
+	l.requests.Range(func(id, value interface{}) bool {
+		request := value.(*takenRequest)
+		select {
+		case <-request.done:
+			if request.allowed && !request.expires.After(now) {
+				l.requests.CompareAndDelete(id, request)
+			}
+		default:
+		}
+
+		return true
+	})
+}
+
+// TakeOnce takes tokens as Take does, admitting retries of an admitted take with the same request ID without taking again
+func (b *inMemoryBackend) TakeOnce(ctx context.Context, key string, requestID string, tokens int64) (bool, error) {
+	if err := validateRequestID(requestID); err != nil {
+		return false, err
+	}
+
+	return b.requests.takeOnce(ctx, requestKey{key: key, requestID: requestID}, idempotencyWindow(b.options), func() (bool, error) {
+		return b.Take(ctx, key, tokens)
+	})
+}
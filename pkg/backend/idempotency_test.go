@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestTakeOnce(t *testing.T) {
+	ctx := context.Background()
+
+	inMemory, err := NewInMemoryBackend(DefaultOptions().WithLimit(3).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer inMemory.Close(ctx)
+	redis, _ := newTestRedisBackend(t, DefaultOptions().WithLimit(3).WithRefill(time.Hour))
+
+	for name, b := range map[string]Backend{"memory": inMemory, "redis": redis} {
+		t.Run(name, func(t *testing.T) {
+			taker := b.(IdempotentTaker)
+
+			steps := []struct {
+				requestID string
+				tokens    int64
+				allowed   bool
+				left      int64
+			}{
+				{requestID: "a", tokens: 2, allowed: true, left: 1},
+				// Retries of an admitted take are admitted without consuming tokens
+				{requestID: "a", tokens: 2, allowed: true, left: 1},
+				{requestID: "a", tokens: 2, allowed: true, left: 1},
+				// A denied take is not remembered, so its retry is decided afresh
+				{requestID: "b", tokens: 2, allowed: false, left: 1},
+				{requestID: "b", tokens: 1, allowed: true, left: 0},
+				{requestID: "b", tokens: 1, allowed: true, left: 0},
+			}
+
+			for i, step := range steps {
+				allowed, err := taker.TakeOnce(ctx, "key", step.requestID, step.tokens)
+				if err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i, err)
+				}
+				if allowed != step.allowed {
+					t.Errorf("step %d: expected allowed %v, got %v", i, step.allowed, allowed)
+				}
+
+				info, err := b.GetInfo(ctx, "key")
+				if err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i, err)
+				}
+				if info.Tokens != step.left {
+					t.Errorf("step %d: expected %d tokens left, got %d", i, step.left, info.Tokens)
+				}
+			}
+
+			// Request IDs are scoped to their key
+			b.Reset(ctx, "other")
+			if allowed, _ := taker.TakeOnce(ctx, "other", "a", 1); !allowed {
+				t.Error("expected the same request ID on another key to be taken")
+			}
+			if info, _ := b.GetInfo(ctx, "other"); info.Tokens != 2 {
+				t.Errorf("expected 2 tokens left on the other key, got %d", info.Tokens)
+			}
+
+			if _, err := taker.TakeOnce(ctx, "key", "", 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+				t.Errorf("expected invalid key error for an empty request ID, got %v", err)
+			}
+			if _, err := taker.TakeOnce(ctx, "key", strings.Repeat("x", 257), 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+				t.Errorf("expected invalid key error for a long request ID, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTakeOnceWindow(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithLimit(3).WithRefill(time.Hour).WithIdempotencyWindow(time.Minute)
+
+	inMemory, err := NewInMemoryBackend(options.WithIdempotencyWindow(50 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer inMemory.Close(ctx)
+
+	inMemory.(IdempotentTaker).TakeOnce(ctx, "key", "a", 1)
+	time.Sleep(100 * time.Millisecond)
+	inMemory.(IdempotentTaker).TakeOnce(ctx, "key", "a", 1)
+	if info, _ := inMemory.GetInfo(ctx, "key"); info.Tokens != 1 {
+		t.Errorf("expected a request ID to be taken again after the window, got %d tokens left", info.Tokens)
+	}
+
+	redis, server := newTestRedisBackend(t, options)
+	redis.TakeOnce(ctx, "key", "a", 1)
+	if ttl := server.TTL(redis.keys.requestID(redis.keys.bucket("key"), "a")); ttl != time.Minute {
+		t.Errorf("expected the request ID to be kept for a minute, got %v", ttl)
+	}
+
+	server.FastForward(time.Minute)
+	redis.TakeOnce(ctx, "key", "a", 1)
+	if info, _ := redis.GetInfo(ctx, "key"); info.Tokens != 1 {
+		t.Errorf("expected a request ID to be taken again after the window, got %d tokens left", info.Tokens)
+	}
+}
+
+func TestTakeOnceConcurrentRetries(t *testing.T) {
+	ctx := context.Background()
+	b, err := NewInMemoryBackend(DefaultOptions().WithLimit(100).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer b.Close(ctx)
+
+	var admitted atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if allowed, err := b.(IdempotentTaker).TakeOnce(ctx, "key", "retried", 1); err == nil && allowed {
+				admitted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every retry is admitted, but only one consumed a token
+	if admitted.Load() != 50 {
+		t.Errorf("expected every retry to be admitted, got %d", admitted.Load())
+	}
+	if info, _ := b.GetInfo(ctx, "key"); info.Tokens != 99 {
+		t.Errorf("expected 99 tokens left, got %d", info.Tokens)
+	}
+}
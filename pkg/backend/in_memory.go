@@ -3,7 +3,10 @@ package backend
 import (
 	"context"
 	"fmt"
+	"path"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/devrob-go/go-rate-limiter/pkg/errors"
@@ -12,24 +15,26 @@ import (
 // inMemoryBackend provides an in-memory implementation of the Backend interface
 // It uses a token bucket algorithm with configurable limits and refill rates
 type inMemoryBackend struct {
-	store         sync.Map
+	store         *shardedStore
+	blocks        sync.Map
+	requests      requestLog
+	activity      activityLog
 	options       *Options
 	cleanupTicker *time.Ticker
 	stopCleanup   chan struct{}
 	mu            sync.RWMutex
-	closed        bool
-}
+	closed        atomic.Bool
+
+	wheel      *timerWheel
+	wakeups    wakeupSubscribers
+	evictions  evictionSubscribers
+	wheelArmed sync.Map
+
+	usage *usageCounter
 
-// bucket represents a token bucket for rate limiting
-type bucket struct {
-	Key        string        `json:"key"`
-	Tokens     int           `json:"tokens"`
-	MaxTokens  int           `json:"max_tokens"`
-	RefillRate time.Duration `json:"refill_rate"`
-	LastRefill time.Time     `json:"last_refill"`
-	NextRefill time.Time     `json:"next_refill"`
-	ResetTime  time.Time     `json:"reset_time"`
-	mu         sync.RWMutex
+	// nextCleanup is when the next inline cleanup is due in Unix nanoseconds, and sweepLeft the shards it has left
+	nextCleanup atomic.Int64
+	sweepLeft   atomic.Int64
 }
 
 // NewInMemoryBackend creates a new in-memory backend with the given options
@@ -43,21 +48,38 @@ func NewInMemoryBackend(options *Options) (Backend, error) {
 	}
 
 	backend := &inMemoryBackend{
-		options:       options,
-		cleanupTicker: time.NewTicker(options.CleanupInterval),
-		stopCleanup:   make(chan struct{}),
+		store:       newShardedStore(options.ShardCount, options.MaxMemoryBytes),
+		usage:       newUsageCounter(),
+		options:     options,
+		stopCleanup: make(chan struct{}),
+	}
+
+	if options.SnapshotPath != "" {
+		if err := backend.loadSnapshot(options.SnapshotPath); err != nil {
+			return nil, errors.Wrap(err, "failed to load snapshot")
+		}
+	}
+
+	if options.RefillWheelTick > 0 {
+		backend.wheel = newTimerWheel(options.RefillWheelTick)
+	}
+
+	if options.InlineCleanup {
+		backend.nextCleanup.Store(time.Now().Add(options.CleanupInterval).UnixNano())
+		return backend, nil
 	}
 
 	// Start cleanup goroutine
+	backend.cleanupTicker = time.NewTicker(options.CleanupInterval)
 	go backend.cleanupRoutine()
 
 	return backend, nil
 }
 
 // Take attempts to consume tokens from the bucket
-func (b *inMemoryBackend) Take(ctx context.Context, key string, tokens int) (bool, error) {
-	if b.closed {
-		return false, errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+func (b *inMemoryBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	if b.closed.Load() {
+		return false, errors.ErrBackendClosed
 	}
 
 	if err := validateKey(key); err != nil {
@@ -75,42 +97,161 @@ func (b *inMemoryBackend) Take(ctx context.Context, key string, tokens int) (boo
 	default:
 	}
 
-	// Get or create bucket
+	// Deny immediately while the key is blocked
+	if b.blockedUntil(key).After(time.Now()) {
+		b.usage.record(time.Now(), false, tokens)
+		b.recordActivity(time.Now(), false, key)
+		return false, nil
+	}
+
 	bkt := b.getOrCreateBucket(key)
+	now := time.Now()
 
-	// Refill tokens based on time elapsed
-	bkt.refillTokens()
+	// New buckets spend their grace budget first, the epoch of a new bucket is its creation
+	if b.options.GracePeriod > 0 && bkt.takeGrace(tokens, bkt.epoch.Add(b.options.GracePeriod), now) {
+		b.usage.record(now, true, tokens)
+		b.recordActivity(now, true, key)
+		return true, nil
+	}
 
-	// Check if we have enough tokens
-	bkt.mu.Lock()
-	defer bkt.mu.Unlock()
+	// Refill and consume in one atomic step, falling back to the burst pool when the bucket is short
+	allowed := bkt.take(tokens, now)
+	if !allowed && b.options.BurstPoolTokens > 0 {
+		allowed = bkt.takeBurst(tokens, b.options.BurstPoolTokens, b.options.BurstPoolRefill, now)
+	}
+	b.usage.record(now, allowed, tokens)
+	b.recordActivity(now, allowed, key)
+	return allowed, nil
+}
 
-	if bkt.Tokens >= tokens {
-		bkt.Tokens -= tokens
-		return true, nil
+// Schedule consumes tokens, reserving any the balance lacks from future refills, and returns when the caller may proceed
+// The grace budget of new keys is not used
+func (b *inMemoryBackend) Schedule(ctx context.Context, key string, tokens int64) (time.Time, error) {
+	if b.closed.Load() {
+		return time.Time{}, errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return time.Time{}, err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return time.Time{}, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	// Blocked keys reserve nothing
+	if until := b.blockedUntil(key); until.After(time.Now()) {
+		b.usage.record(time.Now(), false, tokens)
+		b.recordActivity(time.Now(), false, key)
+		return time.Time{}, &errors.RateLimitError{Message: "key is blocked", Key: key, Reset: until}
+	}
+
+	bkt := b.getOrCreateBucket(key)
+	now := time.Now()
+
+	at, ok := bkt.schedule(tokens, now)
+	if !ok {
+		return time.Time{}, errors.Wrapf(errors.ErrInvalidTokens, "cannot schedule %d tokens, more than key %s can ever hold", tokens, key)
+	}
+
+	b.usage.record(now, true, tokens)
+	b.recordActivity(now, true, key)
+	return at, nil
+}
+
+// TakeAll atomically consumes tokens from every listed bucket
+func (b *inMemoryBackend) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	if b.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	if len(keys) == 0 {
+		return false, errors.Wrap(errors.ErrInvalidKey, "keys cannot be empty")
+	}
+
+	for _, key := range keys {
+		if err := validateKey(key); err != nil {
+			return false, err
+		}
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	sorted := uniqueSortedKeys(keys)
+	now := time.Now()
+	buckets := make([]*bucket, 0, len(sorted))
+	for _, key := range sorted {
+		if b.blockedUntil(key).After(now) {
+			b.usage.record(now, false, tokens)
+			b.recordActivity(now, false, sorted...)
+			return false, nil
+		}
+		buckets = append(buckets, b.getOrCreateBucket(key))
+	}
+
+	// Hold every bucket in key order, so concurrent multi-bucket takes cannot deadlock, and only take once all cover it
+	// Nothing is taken and given back, so other takes and readers never see tokens a denied take held
+	holds := make([]bucketHold, len(buckets))
+	allowed := true
+	for i, bkt := range buckets {
+		holds[i] = bkt.hold(now)
+		allowed = allowed && holds[i].tokens >= tokens
+	}
+
+	taken := int64(0)
+	if allowed {
+		taken = tokens
+	}
+	for i, bkt := range buckets {
+		bkt.release(holds[i], taken)
+	}
+
+	if !allowed {
+		b.usage.record(now, false, tokens)
+		b.recordActivity(now, false, sorted...)
+		return false, nil
 	}
 
-	return false, nil
+	b.usage.record(now, true, tokens*int64(len(buckets)))
+	b.recordActivity(now, true, sorted...)
+	return true, nil
 }
 
 // Reset clears the rate limit for a specific key
 func (b *inMemoryBackend) Reset(ctx context.Context, key string) error {
-	if b.closed {
-		return errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+	if b.closed.Load() {
+		return errors.ErrBackendClosed
 	}
 
 	if err := validateKey(key); err != nil {
 		return err
 	}
 
-	b.store.Delete(key)
+	b.store.delete(key)
+	b.wakeups.notify(key)
 	return nil
 }
 
 // GetInfo returns information about the current state of a key
 func (b *inMemoryBackend) GetInfo(ctx context.Context, key string) (*TokenInfo, error) {
-	if b.closed {
-		return nil, errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+	if b.closed.Load() {
+		return nil, errors.ErrBackendClosed
 	}
 
 	if err := validateKey(key); err != nil {
@@ -124,27 +265,42 @@ func (b *inMemoryBackend) GetInfo(ctx context.Context, key string) (*TokenInfo,
 	default:
 	}
 
+	// Every bucket field comes from one view, so concurrent takes and limit changes cannot tear it
 	bkt := b.getOrCreateBucket(key)
-	bkt.refillTokens()
+	now := time.Now()
+	view := bkt.view(now)
+	tokens, lastRefill := unreserved(view.tokens, view.lastRefill, now, view.refill)
 
-	bkt.mu.RLock()
-	defer bkt.mu.RUnlock()
+	var activity *KeyActivity
+	if b.options.KeyActivity {
+		activity = b.activity.activity(key, now)
+	}
+
+	var burstCredits int64
+	if b.options.BurstPoolTokens > 0 {
+		burstCredits = bkt.burstCredits(b.options.BurstPoolTokens, b.options.BurstPoolRefill, now)
+	}
 
 	return &TokenInfo{
 		Key:        bkt.Key,
-		Tokens:     bkt.Tokens,
-		MaxTokens:  bkt.MaxTokens,
-		RefillRate: bkt.RefillRate,
-		LastRefill: bkt.LastRefill,
-		NextRefill: bkt.NextRefill,
-		ResetTime:  bkt.ResetTime,
+		Tokens:     tokens,
+		MaxTokens:  view.maxTokens,
+		RefillRate: view.refill,
+		LastRefill: lastRefill,
+		NextRefill: lastRefill.Add(view.refill),
+		ResetTime:  lastRefill.Add(view.refill),
+		Strategy:   view.strategy,
+
+		BurstCredits: burstCredits,
+		BlockedUntil: b.blockedUntil(key),
+		Activity:     activity,
 	}, nil
 }
 
 // SetLimit sets a custom limit for a specific key
-func (b *inMemoryBackend) SetLimit(ctx context.Context, key string, limit int, refill time.Duration) error {
-	if b.closed {
-		return errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+func (b *inMemoryBackend) SetLimit(ctx context.Context, key string, limit int64, refill time.Duration) error {
+	if b.closed.Load() {
+		return errors.ErrBackendClosed
 	}
 
 	if err := validateKey(key); err != nil {
@@ -155,46 +311,183 @@ func (b *inMemoryBackend) SetLimit(ctx context.Context, key string, limit int, r
 		return errors.Wrap(errors.ErrInvalidTokens, "limit must be positive")
 	}
 
+	if capacity := MaxLimit - b.options.MaxDebt; limit > capacity {
+		return errors.Wrapf(errors.ErrInvalidTokens, "limit must not exceed %d", capacity)
+	}
+
 	if refill <= 0 {
 		return errors.Wrap(errors.ErrInvalidTokens, "refill rate must be positive")
 	}
 
-	bkt := b.getOrCreateBucket(key)
+	// A packed bucket cannot be turned into an optimistic one while takes may be running on it
+	bkt := b.getOrCreateBucketWithLimit(key, limit, refill)
+	if capacity := maxBucketTokens - b.options.MaxDebt; bkt.value.Load() == nil && limit > capacity {
+		return errors.Wrapf(errors.ErrInvalidTokens, "limit of a key already tracked with a packed bucket must not exceed %d", capacity)
+	}
 
-	bkt.mu.Lock()
-	defer bkt.mu.Unlock()
+	// Waiters only need waking when the limit actually changed, new buckets start full at the limit
+	if bkt.setLimit(limit, refill, time.Now()) {
+		b.wakeups.notify(key)
+	}
+	return nil
+}
 
-	bkt.MaxTokens = limit
-	bkt.RefillRate = refill
-	bkt.ResetTime = time.Now().Add(refill)
+// TakeWithLimit sets a custom limit for a key if it differs and consumes tokens from it
+func (b *inMemoryBackend) TakeWithLimit(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration) (bool, error) {
+	if err := validateTokens(tokens); err != nil {
+		return false, err
+	}
 
+	if err := b.SetLimit(ctx, key, limit, refill); err != nil {
+		return false, err
+	}
+
+	return b.Take(ctx, key, tokens)
+}
+
+// SetRefillStrategy sets how the bucket of a key refills, nil restores linear refills
+func (b *inMemoryBackend) SetRefillStrategy(ctx context.Context, key string, strategy RefillStrategy) error {
+	if b.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	if err := validateRefillStrategy(strategy); err != nil {
+		return err
+	}
+
+	// A faster refill may let waiters through sooner
+	if b.getOrCreateBucket(key).setStrategy(strategy, time.Now()) {
+		b.wakeups.notify(key)
+	}
 	return nil
 }
 
-// Close gracefully shuts down the backend
-func (b *inMemoryBackend) Close(ctx context.Context) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// Block denies all Takes for a specific key until the duration expires
+func (b *inMemoryBackend) Block(ctx context.Context, key string, duration time.Duration) error {
+	if b.closed.Load() {
+		return errors.ErrBackendClosed
+	}
 
-	if b.closed {
-		return nil
+	if err := validateKey(key); err != nil {
+		return err
 	}
 
-	b.closed = true
+	if duration <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "block duration must be positive")
+	}
+
+	b.blocks.Store(key, time.Now().Add(duration))
+	return nil
+}
+
+// Unblock lifts a block on a specific key before it expires
+func (b *inMemoryBackend) Unblock(ctx context.Context, key string) error {
+	if b.closed.Load() {
+		return errors.ErrBackendClosed
+	}
 
-	// Stop cleanup goroutine
-	close(b.stopCleanup)
-	if b.cleanupTicker != nil {
-		b.cleanupTicker.Stop()
+	if err := validateKey(key); err != nil {
+		return err
 	}
 
+	b.blocks.Delete(key)
+	b.wakeups.notify(key)
 	return nil
 }
 
+// KeyCount returns the number of buckets currently held in memory
+func (b *inMemoryBackend) KeyCount(ctx context.Context) (int, error) {
+	if b.closed.Load() {
+		return 0, errors.ErrBackendClosed
+	}
+
+	return b.store.len(), nil
+}
+
+// MemoryStats returns the approximate memory used by the buckets held in memory
+func (b *inMemoryBackend) MemoryStats(ctx context.Context) (MemoryStats, error) {
+	if b.closed.Load() {
+		return MemoryStats{}, errors.ErrBackendClosed
+	}
+
+	return MemoryStats{
+		Bytes:     b.store.bytes.Load(),
+		MaxBytes:  b.store.maxBytes,
+		Evictions: b.store.evictions.Load(),
+	}, nil
+}
+
+// Stats returns the decisions of the backend since it was created and the tokens it handed out in the last minute
+func (b *inMemoryBackend) Stats(ctx context.Context) (Stats, error) {
+	if b.closed.Load() {
+		return Stats{}, errors.ErrBackendClosed
+	}
+
+	return Stats{
+		Keys:           int64(b.store.len()),
+		Allowed:        b.usage.allowed.Load(),
+		Denied:         b.usage.denied.Load(),
+		TokensConsumed: b.usage.consumed(time.Now()),
+		Window:         statsWindow,
+		Evictions:      b.store.evictions.Load(),
+	}, nil
+}
+
+// Keys returns the keys of the buckets held in memory that match the glob pattern
+func (b *inMemoryBackend) Keys(ctx context.Context, pattern string) ([]string, error) {
+	if b.closed.Load() {
+		return nil, errors.ErrBackendClosed
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, errors.Wrap(errors.ErrInvalidKey, "invalid key pattern")
+	}
+
+	var keys []string
+	b.store.rangeBuckets(func(name string, bkt *bucket) bool {
+		if pattern == "" {
+			keys = append(keys, name)
+		} else if ok, _ := path.Match(pattern, name); ok {
+			keys = append(keys, name)
+		}
+		return true
+	})
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Close gracefully shuts down the backend
+func (b *inMemoryBackend) Close(ctx context.Context) error {
+	return shutdownOnce(&b.mu, &b.closed, func() error {
+		// Stop cleanup goroutine
+		close(b.stopCleanup)
+		if b.cleanupTicker != nil {
+			b.cleanupTicker.Stop()
+		}
+
+		if b.wheel != nil {
+			b.wheel.close()
+		}
+
+		if b.options.SnapshotPath != "" {
+			if err := b.saveSnapshot(b.options.SnapshotPath); err != nil {
+				return errors.Wrap(err, "failed to save snapshot")
+			}
+		}
+
+		return nil
+	})
+}
+
 // HealthCheck performs a health check on the backend
 func (b *inMemoryBackend) HealthCheck(ctx context.Context) error {
-	if b.closed {
-		return errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+	if b.closed.Load() {
+		return errors.ErrBackendClosed
 	}
 
 	// Check if context is cancelled
@@ -205,7 +498,7 @@ func (b *inMemoryBackend) HealthCheck(ctx context.Context) error {
 	}
 
 	// Simple health check - try to access the store
-	b.store.Range(func(key, value interface{}) bool {
+	b.store.rangeBuckets(func(key string, bkt *bucket) bool {
 		return false // Stop after first iteration
 	})
 
@@ -214,46 +507,101 @@ func (b *inMemoryBackend) HealthCheck(ctx context.Context) error {
 
 // getOrCreateBucket gets an existing bucket or creates a new one
 func (b *inMemoryBackend) getOrCreateBucket(key string) *bucket {
-	val, loaded := b.store.Load(key)
-	if loaded {
-		return val.(*bucket)
+	return b.getOrCreateBucketWithLimit(key, b.options.DefaultLimit, b.options.DefaultRefill)
+}
+
+// getOrCreateBucketWithLimit gets an existing bucket or creates a full one with the limit and refill rate
+func (b *inMemoryBackend) getOrCreateBucketWithLimit(key string, limit int64, refill time.Duration) *bucket {
+	if b.options.InlineCleanup {
+		b.cleanupInline(time.Now())
 	}
 
-	// Create new bucket
-	now := time.Now()
-	newBucket := &bucket{
-		Key:        key,
-		Tokens:     b.options.DefaultLimit,
-		MaxTokens:  b.options.DefaultLimit,
-		RefillRate: b.options.DefaultRefill,
-		LastRefill: now,
-		NextRefill: now.Add(b.options.DefaultRefill),
-		ResetTime:  now.Add(b.options.DefaultRefill),
+	return b.store.loadOrCreate(key, func() *bucket {
+		bkt := b.newBucket(key, limit, limit, refill, time.Now())
+		bkt.grace.Store(b.options.GraceTokens)
+		return bkt
+	})
+}
+
+// newBucket creates a packed or optimistic bucket as the options select
+// Buckets whose limit plus debt does not fit the packed state are always optimistic
+func (b *inMemoryBackend) newBucket(key string, tokens, maxTokens int64, refill time.Duration, lastRefill time.Time) *bucket {
+	if b.options.OptimisticBuckets || maxTokens > maxBucketTokens-b.options.MaxDebt {
+		return newOptimisticBucket(key, tokens, maxTokens, refill, lastRefill, b.options.MaxDebt)
 	}
 
-	// Store the bucket
-	b.store.Store(key, newBucket)
-	return newBucket
+	return newBucket(key, tokens, maxTokens, refill, lastRefill, b.options.MaxDebt)
 }
 
-// refillTokens refills tokens based on time elapsed since last refill
-func (bkt *bucket) refillTokens() {
-	bkt.mu.Lock()
-	defer bkt.mu.Unlock()
+// blockedUntil returns the expiry of an active block on the key, or the zero time
+func (b *inMemoryBackend) blockedUntil(key string) time.Time {
+	val, ok := b.blocks.Load(key)
+	if !ok {
+		return time.Time{}
+	}
 
-	now := time.Now()
-	elapsed := now.Sub(bkt.LastRefill)
+	until := val.(time.Time)
+	if !until.After(time.Now()) {
+		b.blocks.CompareAndDelete(key, val)
+		return time.Time{}
+	}
+
+	return until
+}
+
+// SubscribeWakeups returns a channel signalled at each refill of the key and when Reset, SetLimit or Unblock runs
+// The channel is nil unless the refill timer wheel is enabled with RefillWheelTick
+func (b *inMemoryBackend) SubscribeWakeups(key string) (<-chan struct{}, func()) {
+	if b.wheel == nil || b.closed.Load() {
+		return nil, func() {}
+	}
+
+	ch, unsubscribe := b.wakeups.add(key)
+	if _, armed := b.wheelArmed.LoadOrStore(key, true); !armed {
+		b.scheduleRefill(key)
+	}
+
+	return ch, unsubscribe
+}
 
-	// Calculate how many tokens to add
-	tokensToAdd := int(elapsed / bkt.RefillRate)
+// scheduleRefill arms the wheel for the next refill of the key, or for the end of its block
+func (b *inMemoryBackend) scheduleRefill(key string) {
+	at := b.blockedUntil(key)
+	if at.IsZero() {
+		now := time.Now()
+		_, lastRefill, _, refill := b.getOrCreateBucket(key).read(now)
+		at = lastRefill.Add(refill)
+
+		// A full bucket does not move lastRefill, so count the next refill from now
+		if !at.After(now) {
+			at = now.Add(refill)
+		}
+	}
+
+	b.wheel.schedule(at, func() { b.refillAndWake(key) })
+}
+
+// refillAndWake refills the bucket of the key on time and wakes its waiters, then arms the next refill
+// The chain stops once the key has no subscriber left
+func (b *inMemoryBackend) refillAndWake(key string) {
+	if !b.wakeups.has(key) {
+		b.wheelArmed.Delete(key)
+
+		// A subscriber may have arrived after the check and found the key still armed
+		if !b.wakeups.has(key) {
+			return
+		}
+		if _, armed := b.wheelArmed.LoadOrStore(key, true); armed {
+			return
+		}
+	}
 
-	if tokensToAdd > 0 {
-		// Add tokens, but don't exceed max
-		bkt.Tokens = min(bkt.MaxTokens, bkt.Tokens+tokensToAdd)
-		bkt.LastRefill = now
-		bkt.NextRefill = now.Add(bkt.RefillRate)
-		bkt.ResetTime = now.Add(bkt.RefillRate)
+	if bkt, ok := b.store.load(key); ok {
+		bkt.refresh(time.Now())
 	}
+
+	b.wakeups.notify(key)
+	b.scheduleRefill(key)
 }
 
 // cleanupRoutine periodically cleans up expired buckets
@@ -270,51 +618,88 @@ func (b *inMemoryBackend) cleanupRoutine() {
 
 // cleanupExpiredBuckets removes buckets that haven't been used recently
 func (b *inMemoryBackend) cleanupExpiredBuckets() {
-	cutoff := time.Now().Add(-b.options.CleanupInterval * 2)
+	b.expireBuckets(-1, time.Now().Add(-b.options.CleanupInterval*2))
 
-	b.store.Range(func(key, value interface{}) bool {
-		bkt := value.(*bucket)
+	b.cleanupExpiredBlocks(time.Now())
+	b.requests.cleanup(time.Now())
+	b.activity.cleanup(time.Now())
+}
+
+// cleanupInline runs the cleanup from within a call once it is due, sweeping one shard per call
+// The call that finds the cleanup due also drops expired blocks and request IDs, which are few and dropped on read as well
+func (b *inMemoryBackend) cleanupInline(now time.Time) {
+	if due := b.nextCleanup.Load(); now.UnixNano() >= due &&
+		b.nextCleanup.CompareAndSwap(due, now.Add(b.options.CleanupInterval).UnixNano()) {
+		b.sweepLeft.Store(int64(len(b.store.shards)))
+		b.cleanupExpiredBlocks(now)
+		b.requests.cleanup(now)
+		b.activity.cleanup(now)
+	}
 
-		bkt.mu.RLock()
-		lastUsed := bkt.LastRefill
-		bkt.mu.RUnlock()
+	// Only calls during a sweep write to the counter, so the hot path stays a read
+	if b.sweepLeft.Load() <= 0 {
+		return
+	}
 
-		if lastUsed.Before(cutoff) {
-			b.store.Delete(key)
+	if shard := b.sweepLeft.Add(-1); shard >= 0 {
+		b.expireBuckets(int(shard), now.Add(-b.options.CleanupInterval*2))
+	}
+}
+
+// cleanupExpiredBlocks removes blocks that expired before now
+func (b *inMemoryBackend) cleanupExpiredBlocks(now time.Time) {
+	b.blocks.Range(func(key, value interface{}) bool {
+		if !value.(time.Time).After(now) {
+			b.blocks.CompareAndDelete(key, value)
 		}
 
 		return true
 	})
 }
 
+// Validation errors are wrapped once so that checking arguments never allocates
+var (
+	errEmptyKey          = errors.Wrap(errors.ErrInvalidKey, "key cannot be empty")
+	errKeyTooLong        = errors.Wrap(errors.ErrInvalidKey, "key too long (max 256 characters)")
+	errTokensNotPositive = errors.Wrap(errors.ErrInvalidTokens, "tokens must be positive")
+)
+
 // validateKey validates the key parameter
 func validateKey(key string) error {
 	if key == "" {
-		return errors.Wrap(errors.ErrInvalidKey, "key cannot be empty")
+		return errEmptyKey
 	}
 
 	if len(key) > 256 {
-		return errors.Wrap(errors.ErrInvalidKey, "key too long (max 256 characters)")
+		return errKeyTooLong
 	}
 
 	return nil
 }
 
 // validateTokens validates the tokens parameter
-func validateTokens(tokens int) error {
+func validateTokens(tokens int64) error {
 	if tokens <= 0 {
-		return errors.Wrap(errors.ErrInvalidTokens, "tokens must be positive")
+		return errTokensNotPositive
 	}
 
 	return nil
 }
 
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+// uniqueSortedKeys returns the distinct keys in sorted order
+func uniqueSortedKeys(keys []string) []string {
+	sorted := make([]string, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		sorted = append(sorted, key)
 	}
-	return b
+
+	sort.Strings(sorted)
+	return sorted
 }
 
 // String returns a string representation of the backend
@@ -322,7 +707,7 @@ func (b *inMemoryBackend) String() string {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	if b.closed {
+	if b.closed.Load() {
 		return "InMemoryBackend{closed=true}"
 	}
 
@@ -2,8 +2,13 @@ package backend
 
 import (
 	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
 )
 
 func TestNewInMemoryBackend(t *testing.T) {
@@ -105,6 +110,173 @@ func TestInMemoryBackendTake(t *testing.T) {
 	}
 }
 
+func TestInMemoryBackendTakeAllocations(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewInMemoryBackend(DefaultOptions().WithLimit(1000000).WithBurst(1000000).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(ctx)
+
+	// A block on another key must not slow down the lookup for this one
+	backend.Block(ctx, "blocked_key", time.Hour)
+	backend.Take(ctx, "alloc_key", 1)
+
+	tests := []struct {
+		name   string
+		key    string
+		tokens int64
+	}{
+		{name: "allowed", key: "alloc_key", tokens: 1},
+		{name: "blocked", key: "blocked_key", tokens: 1},
+		{name: "empty key", key: "", tokens: 1},
+		{name: "zero tokens", key: "alloc_key", tokens: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocs := testing.AllocsPerRun(1000, func() {
+				backend.Take(ctx, tt.key, tt.tokens)
+			})
+			if allocs != 0 {
+				t.Errorf("expected 0 allocations per Take, got %v", allocs)
+			}
+		})
+	}
+}
+
+func TestInMemoryBackendTakeAll(t *testing.T) {
+	backend, err := NewInMemoryBackend(DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(context.Background())
+
+	ctx := context.Background()
+
+	// Shared bucket smaller than the individual buckets
+	err = backend.SetLimit(ctx, "shared", 100, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	backend.Take(ctx, "shared", 90)
+
+	// Test consuming from both buckets
+	allowed, err := backend.TakeAll(ctx, []string{"user_1", "shared"}, 5)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Error("expected request to be allowed")
+	}
+
+	// Test denial by the shared bucket leaves the individual bucket untouched
+	allowed, err = backend.TakeAll(ctx, []string{"user_2", "shared"}, 10)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected request to be denied by shared bucket")
+	}
+
+	info, err := backend.GetInfo(ctx, "user_2")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if info.Tokens != 100 {
+		t.Errorf("expected user_2 tokens 100, got %d", info.Tokens)
+	}
+
+	info, err = backend.GetInfo(ctx, "shared")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if info.Tokens != 5 {
+		t.Errorf("expected shared tokens 5, got %d", info.Tokens)
+	}
+
+	// Test duplicate keys are charged once
+	allowed, err = backend.TakeAll(ctx, []string{"user_3", "user_3"}, 60)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Error("expected request with duplicate keys to be allowed")
+	}
+
+	// Test invalid input
+	_, err = backend.TakeAll(ctx, nil, 1)
+	if err == nil {
+		t.Error("expected error for empty keys")
+	}
+	_, err = backend.TakeAll(ctx, []string{"user_1", ""}, 1)
+	if err == nil {
+		t.Error("expected error for empty key")
+	}
+}
+
+func TestInMemoryBackendTakeAllContention(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *Options
+	}{
+		{name: "packed", options: DefaultOptions().WithLimit(1).WithRefill(time.Hour)},
+		{name: "optimistic", options: DefaultOptions().WithLimit(1).WithRefill(time.Hour).WithOptimisticBuckets(true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend, err := NewInMemoryBackend(tt.options)
+			if err != nil {
+				t.Fatalf("failed to create backend: %v", err)
+			}
+			defer backend.Close(ctx)
+
+			// "shared" denies every multi-key take, so "full" must keep its token throughout
+			backend.Take(ctx, "shared", 1)
+
+			var wg sync.WaitGroup
+			stop := make(chan struct{})
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						if allowed, _ := backend.TakeAll(ctx, []string{"full", "shared"}, 1); allowed {
+							t.Error("expected the multi-key take to be denied by the empty shared bucket")
+							return
+						}
+					}
+				}()
+			}
+
+			// Run long enough for the multi-key takes to be preempted mid-way on a single CPU too
+			for deadline := time.Now().Add(300 * time.Millisecond); time.Now().Before(deadline); {
+				info, err := backend.GetInfo(ctx, "full")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if info.Tokens != 1 {
+					t.Errorf("expected a denied multi-key take never to hold the token of full, read %d tokens", info.Tokens)
+					break
+				}
+			}
+			close(stop)
+			wg.Wait()
+
+			if allowed, _ := backend.Take(ctx, "full", 1); !allowed {
+				t.Error("expected a single take to get the token of full")
+			}
+		})
+	}
+}
+
 func TestInMemoryBackendReset(t *testing.T) {
 	backend, err := NewInMemoryBackend(DefaultOptions())
 	if err != nil {
@@ -238,6 +410,289 @@ func TestInMemoryBackendSetLimit(t *testing.T) {
 	}
 }
 
+func TestInMemoryBackendTakeWithLimit(t *testing.T) {
+	backend, err := NewInMemoryBackend(DefaultOptions().WithLimit(100).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(context.Background())
+
+	ctx := context.Background()
+	taker := backend.(LimitTaker)
+
+	// Repeating the same custom limit must not refill the bucket
+	for i := 0; i < 3; i++ {
+		allowed, err := taker.TakeWithLimit(ctx, "test_key", 1, 3, time.Hour)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !allowed {
+			t.Errorf("expected take %d to be allowed", i)
+		}
+	}
+
+	allowed, err := taker.TakeWithLimit(ctx, "test_key", 1, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected take beyond the custom limit to be denied")
+	}
+
+	if _, err := taker.TakeWithLimit(ctx, "test_key", 1, 0, time.Hour); err == nil {
+		t.Error("expected error for zero limit")
+	}
+
+	if _, err := taker.TakeWithLimit(ctx, "test_key", 0, 3, time.Hour); err == nil {
+		t.Error("expected error for zero tokens")
+	}
+}
+
+func TestInMemoryBackendBlock(t *testing.T) {
+	backend, err := NewInMemoryBackend(DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(context.Background())
+
+	ctx := context.Background()
+
+	// Test blocking a key denies Takes
+	err = backend.Block(ctx, "test_key", time.Minute)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	allowed, err := backend.Take(ctx, "test_key", 1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected request to be denied while blocked")
+	}
+
+	info, err := backend.GetInfo(ctx, "test_key")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if info.BlockedUntil.IsZero() {
+		t.Error("expected BlockedUntil to be set")
+	}
+	if info.Tokens != 100 {
+		t.Errorf("expected blocked Takes not to consume tokens, got %d", info.Tokens)
+	}
+
+	// Test other keys are unaffected
+	allowed, err = backend.Take(ctx, "other_key", 1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Error("expected request for other key to be allowed")
+	}
+
+	// Test unblocking restores access
+	err = backend.Unblock(ctx, "test_key")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	allowed, err = backend.Take(ctx, "test_key", 1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Error("expected request to be allowed after unblock")
+	}
+
+	// Test block expiry
+	err = backend.Block(ctx, "expiring_key", 50*time.Millisecond)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	allowed, err = backend.Take(ctx, "expiring_key", 1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Error("expected request to be allowed after block expiry")
+	}
+
+	// Test invalid duration
+	err = backend.Block(ctx, "test_key", 0)
+	if err == nil {
+		t.Error("expected error for zero duration")
+	}
+
+	// Test invalid key
+	err = backend.Block(ctx, "", time.Minute)
+	if err == nil {
+		t.Error("expected error for empty key")
+	}
+}
+
+func TestInMemoryBackendSchedule(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewInMemoryBackend(DefaultOptions().WithLimit(5).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(ctx)
+	scheduler := backend.(Scheduler)
+
+	at, err := scheduler.Schedule(ctx, "key", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if at.After(time.Now()) {
+		t.Errorf("expected tokens the balance covers to proceed at once, got %v from now", time.Until(at))
+	}
+
+	// Two tokens short, reserved from the next two hourly refills
+	at, err = scheduler.Schedule(ctx, "key", 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wait := time.Until(at); wait <= time.Hour || wait > 2*time.Hour {
+		t.Errorf("expected to wait two refills, got %v", wait)
+	}
+
+	// The reservation is reported as debt
+	info, err := backend.GetInfo(ctx, "key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != -2 {
+		t.Errorf("expected -2 tokens, got %d", info.Tokens)
+	}
+	if info.LastRefill.After(time.Now()) || !info.NextRefill.After(time.Now()) {
+		t.Errorf("expected the last refill before now and the next after it, got %v and %v", info.LastRefill, info.NextRefill)
+	}
+
+	if allowed, _ := backend.Take(ctx, "key", 1); allowed {
+		t.Error("expected take during a reservation to be denied")
+	}
+
+	if _, err := scheduler.Schedule(ctx, "key", 6); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error, got %v", err)
+	}
+
+	backend.Block(ctx, "blocked", time.Minute)
+	if _, err := scheduler.Schedule(ctx, "blocked", 1); !errors.IsRateLimitError(err) {
+		t.Errorf("expected rate limit error for a blocked key, got %v", err)
+	}
+}
+
+func TestInMemoryBackendKeyCount(t *testing.T) {
+	backend, err := NewInMemoryBackend(DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(context.Background())
+
+	ctx := context.Background()
+	counter, ok := backend.(KeyCounter)
+	if !ok {
+		t.Fatal("expected in-memory backend to implement KeyCounter")
+	}
+
+	backend.Take(ctx, "key1", 1)
+	backend.Take(ctx, "key2", 1)
+	backend.Take(ctx, "key2", 1)
+
+	count, err := counter.KeyCount(ctx)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 keys, got %d", count)
+	}
+}
+
+func TestInMemoryBackendMemoryStats(t *testing.T) {
+	maxBytes := 2 * entrySize("key1")
+	backend, err := NewInMemoryBackend(DefaultOptions().WithShardCount(1).WithMaxMemory(maxBytes))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(context.Background())
+
+	ctx := context.Background()
+	reporter, ok := backend.(MemoryReporter)
+	if !ok {
+		t.Fatal("expected in-memory backend to implement MemoryReporter")
+	}
+
+	backend.Take(ctx, "key1", 1)
+	backend.Take(ctx, "key2", 1)
+	backend.Take(ctx, "key3", 1)
+
+	stats, err := reporter.MemoryStats(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Bytes != maxBytes {
+		t.Errorf("expected %d bytes, got %d", maxBytes, stats.Bytes)
+	}
+	if stats.MaxBytes != maxBytes {
+		t.Errorf("expected max bytes %d, got %d", maxBytes, stats.MaxBytes)
+	}
+	if stats.Evictions != 1 {
+		t.Errorf("expected 1 eviction, got %d", stats.Evictions)
+	}
+
+	if _, err := NewInMemoryBackend(DefaultOptions().WithMaxMemory(-1)); err == nil {
+		t.Error("expected error for negative max memory")
+	}
+}
+
+func TestInMemoryBackendKeys(t *testing.T) {
+	backend, err := NewInMemoryBackend(DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(context.Background())
+
+	ctx := context.Background()
+	lister, ok := backend.(KeyLister)
+	if !ok {
+		t.Fatal("expected in-memory backend to implement KeyLister")
+	}
+
+	backend.Take(ctx, "user:2", 1)
+	backend.Take(ctx, "user:1", 1)
+	backend.Take(ctx, "org:1", 1)
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected []string
+	}{
+		{name: "all keys", pattern: "", expected: []string{"org:1", "user:1", "user:2"}},
+		{name: "glob pattern", pattern: "user:*", expected: []string{"user:1", "user:2"}},
+		{name: "no match", pattern: "team:*", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := lister.Keys(ctx, tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(keys) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, keys)
+			}
+		})
+	}
+
+	if _, err := lister.Keys(ctx, "["); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
+
 func TestInMemoryBackendClose(t *testing.T) {
 	backend, err := NewInMemoryBackend(DefaultOptions())
 	if err != nil {
@@ -392,3 +847,80 @@ func TestInMemoryBackendCleanup(t *testing.T) {
 	// Close backend to stop cleanup goroutine
 	backend.Close(ctx)
 }
+
+func TestInMemoryBackendInlineCleanup(t *testing.T) {
+	ctx := context.Background()
+	opts := DefaultOptions().WithInlineCleanup(true).WithShardCount(2)
+	opts.CleanupInterval = 50 * time.Millisecond
+
+	b, err := NewInMemoryBackend(opts)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer b.Close(ctx)
+
+	backend := b.(*inMemoryBackend)
+	if backend.cleanupTicker != nil {
+		t.Error("expected no cleanup ticker")
+	}
+
+	backend.Take(ctx, "key1", 1)
+	backend.Take(ctx, "key2", 1)
+	backend.Block(ctx, "key3", 10*time.Millisecond)
+
+	// Nothing is cleaned up before a call finds the cleanup due
+	time.Sleep(150 * time.Millisecond)
+	if count, _ := backend.KeyCount(ctx); count != 2 {
+		t.Errorf("expected 2 keys before the cleanup, got %d", count)
+	}
+
+	// Each call sweeps one of the two shards, the first also drops expired blocks
+	backend.Take(ctx, "fresh", 1)
+	if _, ok := backend.blocks.Load("key3"); ok {
+		t.Error("expected the expired block to be dropped")
+	}
+	backend.Take(ctx, "fresh", 1)
+
+	keys, _ := backend.Keys(ctx, "")
+	if len(keys) != 1 || keys[0] != "fresh" {
+		t.Errorf("expected only the fresh key to be left, got %v", keys)
+	}
+}
+
+func TestInMemoryBackendSubscribeEvictions(t *testing.T) {
+	ctx := context.Background()
+	b, err := NewInMemoryBackend(DefaultOptions().WithShardCount(2))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer b.Close(ctx)
+
+	backend := b.(*inMemoryBackend)
+	var evicted []string
+	unsubscribe := backend.SubscribeEvictions(func(key string) {
+		evicted = append(evicted, key)
+
+		// Subscribers may call back into the backend
+		backend.GetInfo(ctx, "other")
+	})
+
+	backend.Take(ctx, "key1", 1)
+	backend.expireBuckets(-1, time.Now().Add(time.Second))
+	if len(evicted) != 1 || evicted[0] != "key1" {
+		t.Errorf("expected [key1] evicted, got %v", evicted)
+	}
+
+	// Fresh buckets and unsubscribed functions are left alone
+	evicted = nil
+	backend.Take(ctx, "key2", 1)
+	backend.expireBuckets(-1, time.Now().Add(-time.Hour))
+	if len(evicted) != 0 {
+		t.Errorf("expected nothing evicted, got %v", evicted)
+	}
+
+	unsubscribe()
+	backend.expireBuckets(-1, time.Now().Add(time.Second))
+	if len(evicted) != 0 {
+		t.Errorf("expected no evictions after unsubscribing, got %v", evicted)
+	}
+}
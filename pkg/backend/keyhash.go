@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"sync"
+)
+
+// MinKeyHashSecret is the length in bytes of the shortest secret accepted for key hashing
+const MinKeyHashSecret = 16
+
+// keyHasher maps keys to the hex HMAC-SHA256 of a secret so shared backends never store them in plaintext
+// A nil keyHasher leaves keys unchanged
+type keyHasher struct {
+	macs sync.Pool
+}
+
+// newKeyHasher returns a hasher for the secret, or nil when the secret is empty
+func newKeyHasher(secret []byte) *keyHasher {
+	if len(secret) == 0 {
+		return nil
+	}
+
+	// Copy the secret so later changes by the caller do not change the stored keys
+	secret = append([]byte(nil), secret...)
+
+	return &keyHasher{
+		macs: sync.Pool{
+			New: func() any { return hmac.New(sha256.New, secret) },
+		},
+	}
+}
+
+// hash returns the stored form of a key
+func (h *keyHasher) hash(key string) string {
+	if h == nil {
+		return key
+	}
+
+	mac := h.macs.Get().(hash.Hash)
+	defer h.macs.Put(mac)
+
+	mac.Reset()
+	mac.Write([]byte(key))
+
+	var sum [sha256.Size]byte
+	return hex.EncodeToString(mac.Sum(sum[:0]))
+}
@@ -0,0 +1,92 @@
+package backend
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyHasher(t *testing.T) {
+	if got := newKeyHasher(nil).hash("user@example.com"); got != "user@example.com" {
+		t.Errorf("expected keys to be kept without a secret, got %s", got)
+	}
+
+	secret := []byte("0123456789abcdef")
+	h := newKeyHasher(secret)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("user@example.com"))
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if got := h.hash("user@example.com"); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	// Changing the caller's secret must not change the stored keys
+	secret[0] = 'x'
+	if got := h.hash("user@example.com"); got != expected {
+		t.Errorf("expected %s after the secret changed, got %s", expected, got)
+	}
+}
+
+func TestRedisBackendKeyHashing(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("0123456789abcdef")
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(10).WithRefill(time.Hour).WithKeyHashing(secret))
+
+	const key = "user@example.com"
+	stored := backend.keys.stored(newKeyHasher(secret).hash(key))
+
+	if _, err := backend.Take(ctx, key, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := backend.Block(ctx, key, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	keys := server.Keys()
+	if slices.ContainsFunc(keys, func(k string) bool { return strings.Contains(k, key) }) {
+		t.Errorf("expected no plaintext keys in Redis, got %v", keys)
+	}
+	if !slices.Contains(keys, stored) || !slices.Contains(keys, backend.keys.block(stored)) {
+		t.Errorf("expected hashed keys in Redis, got %v", keys)
+	}
+
+	info, err := backend.GetInfo(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Key != key {
+		t.Errorf("expected key %s, got %s", key, info.Key)
+	}
+	if info.Tokens != 7 {
+		t.Errorf("expected 7 tokens, got %d", info.Tokens)
+	}
+	if info.BlockedUntil.IsZero() {
+		t.Error("expected the key to be blocked")
+	}
+
+	wakeups, unsubscribe := backend.SubscribeWakeups(key)
+	defer unsubscribe()
+
+	if err := backend.Unblock(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectWakeup(t, wakeups, "Unblock")
+
+	if allowed, err := backend.TakeAll(ctx, []string{key, "other"}, 1); err != nil || !allowed {
+		t.Errorf("expected TakeAll to be allowed, got %v, %v", allowed, err)
+	}
+
+	if err := backend.Reset(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.Exists(stored) {
+		t.Error("expected Reset to delete the hashed key")
+	}
+}
@@ -0,0 +1,535 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// MultiRegionOptions configures the multi-region backend
+type MultiRegionOptions struct {
+	// Region names the local region, it must not be one of the peers
+	Region string `json:"region"`
+
+	// Share is the fraction of each key's remaining tokens the local region may grant between reconciliations
+	// 0 splits the tokens evenly between the local region and its peers
+	Share float64 `json:"share"`
+
+	// ReconcileInterval is how often local usage is copied to the other regions and the local share is recomputed
+	ReconcileInterval time.Duration `json:"reconcile_interval"`
+}
+
+// DefaultMultiRegionOptions returns default options for the multi-region backend
+func DefaultMultiRegionOptions() *MultiRegionOptions {
+	return &MultiRegionOptions{
+		Region:            "local",
+		ReconcileInterval: time.Second,
+	}
+}
+
+// Validate validates the multi-region options
+func (o *MultiRegionOptions) Validate() error {
+	if o.Region == "" {
+		return errors.Wrap(errors.ErrInvalidKey, "region cannot be empty")
+	}
+
+	if o.Share < 0 || o.Share > 1 {
+		return errors.Wrap(errors.ErrInvalidTokens, "share must be between 0 and 1")
+	}
+
+	if o.ReconcileInterval <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "reconcile_interval must be positive")
+	}
+
+	return nil
+}
+
+// multiRegionBackend serves Takes from the local region's backend and copies usage to the other regions behind them
+// Each region grants at most its share of a key's remaining tokens between reconciliations,
+// so regions whose shares add up to at most 1 grant little more than the key has between them
+type multiRegionBackend struct {
+	local   Backend
+	peers   map[string]Backend
+	options *MultiRegionOptions
+	share   float64
+	keys    sync.Map
+	stop    chan struct{}
+	done    chan struct{}
+	mu      sync.Mutex
+	closed  atomic.Bool
+}
+
+// regionUsage is the local view of one key
+type regionUsage struct {
+	mu        sync.Mutex
+	allowance int64
+	synced    bool
+	touched   bool
+
+	// owed is what was granted locally and not yet taken from each peer
+	owed map[string]int64
+
+	// limit and refill are the custom limit of the key, copied to the peers on the next reconciliation while limitOwed is set
+	limit     int64
+	refill    time.Duration
+	limitOwed bool
+}
+
+// NewMultiRegionBackend wraps the backend of the local region, usually its own Redis, for active-active deployments
+// Peers are the plain backends of the other regions by name, not their multi-region wrappers
+// They are only called by reconciliation and by Reset, SetLimit, Block and Unblock
+func NewMultiRegionBackend(local Backend, peers map[string]Backend, options *MultiRegionOptions) (Backend, error) {
+	if local == nil {
+		return nil, errors.Wrap(errors.ErrBackendUnavailable, "local backend cannot be nil")
+	}
+
+	if _, ok := local.(Drainer); !ok {
+		return nil, errors.Wrap(errors.ErrBackendUnavailable, "local backend must implement Drainer")
+	}
+
+	if options == nil {
+		options = DefaultMultiRegionOptions()
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+
+	for name, peer := range peers {
+		if peer == nil {
+			return nil, errors.Wrapf(errors.ErrBackendUnavailable, "backend of region %s cannot be nil", name)
+		}
+
+		// Tokens granted locally are settled by draining the buckets of the peers
+		if _, ok := peer.(Drainer); !ok {
+			return nil, errors.Wrapf(errors.ErrBackendUnavailable, "backend of region %s must implement Drainer", name)
+		}
+
+		if name == options.Region {
+			return nil, errors.Wrapf(errors.ErrInvalidKey, "region %s cannot be its own peer", name)
+		}
+	}
+
+	share := options.Share
+	if share == 0 {
+		share = 1 / float64(len(peers)+1)
+	}
+
+	backend := &multiRegionBackend{
+		local:   local,
+		peers:   peers,
+		options: options,
+		share:   share,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go backend.reconcileRoutine()
+
+	return backend, nil
+}
+
+// Take consumes tokens from the local region within its share of the key
+func (m *multiRegionBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	if m.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	return m.take(ctx, key, m.usage(key), tokens)
+}
+
+// TakeWithLimit consumes tokens from the local region under a custom limit, which reaches the peers on the next reconciliation
+func (m *multiRegionBackend) TakeWithLimit(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration) (bool, error) {
+	if m.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	if limit <= 0 {
+		return false, errors.Wrap(errors.ErrInvalidTokens, "limit must be positive")
+	}
+
+	if refill <= 0 {
+		return false, errors.Wrap(errors.ErrInvalidTokens, "refill rate must be positive")
+	}
+
+	usage := m.usage(key)
+
+	usage.mu.Lock()
+	changed := usage.limit != limit || usage.refill != refill
+	usage.mu.Unlock()
+
+	// The share is recomputed under a new limit, an unchanged one costs no extra call
+	if changed {
+		if err := m.local.SetLimit(ctx, key, limit, refill); err != nil {
+			return false, err
+		}
+
+		usage.mu.Lock()
+		usage.limit, usage.refill = limit, refill
+		usage.limitOwed = len(m.peers) > 0
+		usage.synced = false
+		usage.mu.Unlock()
+	}
+
+	return m.take(ctx, key, usage, tokens)
+}
+
+// take consumes tokens from the local backend once the local share covers them, and records them for the peers
+func (m *multiRegionBackend) take(ctx context.Context, key string, usage *regionUsage, tokens int64) (bool, error) {
+	usage.mu.Lock()
+	if !usage.synced {
+		usage.mu.Unlock()
+		if err := m.refresh(ctx, key, usage); err != nil {
+			return false, err
+		}
+		usage.mu.Lock()
+	}
+
+	usage.touched = true
+	if usage.allowance < tokens {
+		usage.mu.Unlock()
+		return false, nil
+	}
+	usage.allowance -= tokens
+	usage.mu.Unlock()
+
+	allowed, err := m.local.Take(ctx, key, tokens)
+
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+
+	switch {
+	case err != nil:
+		usage.allowance += tokens
+		return false, err
+	case !allowed:
+		// The local bucket is empty or blocked, stop granting until the next reconciliation
+		usage.allowance = 0
+		return false, nil
+	}
+
+	for name := range m.peers {
+		usage.owed[name] += tokens
+	}
+
+	return true, nil
+}
+
+// TakeAll consumes tokens from every listed bucket of the local region, outside the local share
+// The tokens are copied to the peers like those of Take
+func (m *multiRegionBackend) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	if m.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	allowed, err := m.local.TakeAll(ctx, keys, tokens)
+	if err != nil || !allowed {
+		return allowed, err
+	}
+
+	for _, key := range keys {
+		usage := m.usage(key)
+
+		usage.mu.Lock()
+		usage.touched = true
+		usage.allowance = max(usage.allowance-tokens, 0)
+		for name := range m.peers {
+			usage.owed[name] += tokens
+		}
+		usage.mu.Unlock()
+	}
+
+	return true, nil
+}
+
+// Reset clears the rate limit for a specific key in every region
+func (m *multiRegionBackend) Reset(ctx context.Context, key string) error {
+	if m.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	m.keys.Delete(key)
+	return m.everyRegion(func(b Backend) error { return b.Reset(ctx, key) })
+}
+
+// GetInfo returns the state of a key in the local region
+// Tokens granted by other regions since their last reconciliation are not yet reflected
+func (m *multiRegionBackend) GetInfo(ctx context.Context, key string) (*TokenInfo, error) {
+	if m.closed.Load() {
+		return nil, errors.ErrBackendClosed
+	}
+
+	return m.local.GetInfo(ctx, key)
+}
+
+// SetLimit sets a custom limit for a specific key in every region
+func (m *multiRegionBackend) SetLimit(ctx context.Context, key string, limit int64, refill time.Duration) error {
+	if m.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := m.everyRegion(func(b Backend) error { return b.SetLimit(ctx, key, limit, refill) }); err != nil {
+		return err
+	}
+
+	usage := m.usage(key)
+	usage.mu.Lock()
+	usage.limit, usage.refill = limit, refill
+	usage.limitOwed = false
+	usage.synced = false
+	usage.mu.Unlock()
+
+	return nil
+}
+
+// Drain removes up to tokens from the balance of a key in the local region, leaving at least floor
+// Unlike takes it is not copied to the peers
+func (m *multiRegionBackend) Drain(ctx context.Context, key string, tokens int64, floor int64) error {
+	if m.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := m.local.(Drainer).Drain(ctx, key, tokens, floor); err != nil {
+		return err
+	}
+
+	m.invalidate(key)
+	return nil
+}
+
+// Block denies all Takes for a specific key in every region until the duration expires
+func (m *multiRegionBackend) Block(ctx context.Context, key string, duration time.Duration) error {
+	if m.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := m.everyRegion(func(b Backend) error { return b.Block(ctx, key, duration) }); err != nil {
+		return err
+	}
+
+	m.invalidate(key)
+	return nil
+}
+
+// Unblock lifts a block on a specific key in every region before it expires
+func (m *multiRegionBackend) Unblock(ctx context.Context, key string) error {
+	if m.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := m.everyRegion(func(b Backend) error { return b.Unblock(ctx, key) }); err != nil {
+		return err
+	}
+
+	m.invalidate(key)
+	return nil
+}
+
+// Close copies the remaining usage to the peers and closes every backend
+func (m *multiRegionBackend) Close(ctx context.Context) error {
+	return shutdownOnce(&m.mu, &m.closed, func() error {
+		close(m.stop)
+		<-m.done
+
+		m.reconcileAll(ctx, true)
+
+		return m.everyRegion(func(b Backend) error { return b.Close(ctx) })
+	})
+}
+
+// HealthCheck performs a health check on the local backend, unreachable peers only delay reconciliation
+func (m *multiRegionBackend) HealthCheck(ctx context.Context) error {
+	if m.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	return m.local.HealthCheck(ctx)
+}
+
+// SubscribeWakeups passes on the wakeups of the local backend, nil when it does not announce any
+// Reset, SetLimit and Unblock write to every region, so waiters in all of them wake up
+func (m *multiRegionBackend) SubscribeWakeups(key string) (<-chan struct{}, func()) {
+	notifier, ok := m.local.(WakeupNotifier)
+	if !ok || m.closed.Load() {
+		return nil, func() {}
+	}
+
+	return notifier.SubscribeWakeups(key)
+}
+
+// usage returns the local view of a key, creating it on first use
+func (m *multiRegionBackend) usage(key string) *regionUsage {
+	if val, ok := m.keys.Load(key); ok {
+		return val.(*regionUsage)
+	}
+
+	val, _ := m.keys.LoadOrStore(key, &regionUsage{owed: make(map[string]int64, len(m.peers))})
+	return val.(*regionUsage)
+}
+
+// invalidate forces the next Take on the key to recompute the local share
+func (m *multiRegionBackend) invalidate(key string) {
+	val, ok := m.keys.Load(key)
+	if !ok {
+		return
+	}
+
+	usage := val.(*regionUsage)
+	usage.mu.Lock()
+	usage.synced = false
+	usage.mu.Unlock()
+}
+
+// everyRegion calls fn on the local backend and then on every peer, in name order
+// Every region is called even after a failure, the first error is returned
+func (m *multiRegionBackend) everyRegion(fn func(Backend) error) error {
+	firstErr := fn(m.local)
+	if firstErr != nil {
+		firstErr = errors.Wrapf(firstErr, "region %s", m.options.Region)
+	}
+
+	names := make([]string, 0, len(m.peers))
+	for name := range m.peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := fn(m.peers[name]); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "region %s", name)
+		}
+	}
+
+	return firstErr
+}
+
+// refresh recomputes the local share of a key from the balance of the local bucket
+// Shares are rounded up so keys with a handful of tokens still get through, at the cost of one token per region
+func (m *multiRegionBackend) refresh(ctx context.Context, key string, usage *regionUsage) error {
+	info, err := m.local.GetInfo(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	allowance := int64(math.Ceil(float64(max(info.Tokens, 0)) * m.share))
+	if info.BlockedUntil.After(time.Now()) {
+		allowance = 0
+	}
+
+	usage.mu.Lock()
+	usage.allowance = allowance
+	usage.synced = true
+	usage.mu.Unlock()
+
+	return nil
+}
+
+// reconcileRoutine reconciles every key once per interval until the backend is closed
+func (m *multiRegionBackend) reconcileRoutine() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.options.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), m.options.ReconcileInterval)
+			m.reconcileAll(ctx, false)
+			cancel()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// reconcileAll copies local usage and custom limits to the peers, then recomputes the local share of every key
+// Keys that were not used since the previous reconciliation and owe nothing are forgotten
+// When flushOnly is set usage is copied without recomputing shares
+func (m *multiRegionBackend) reconcileAll(ctx context.Context, flushOnly bool) {
+	m.keys.Range(func(k, v interface{}) bool {
+		key, usage := k.(string), v.(*regionUsage)
+
+		usage.mu.Lock()
+		owed := usage.owed
+		usage.owed = make(map[string]int64, len(m.peers))
+		limitOwed := usage.limitOwed
+		usage.limitOwed = false
+		limit, refill := usage.limit, usage.refill
+		idle := !usage.touched && len(owed) == 0 && !limitOwed
+		usage.touched = false
+		usage.mu.Unlock()
+
+		if idle {
+			m.keys.CompareAndDelete(key, usage)
+			return true
+		}
+
+		for name, peer := range m.peers {
+			if err := m.reconcilePeer(ctx, peer, key, owed[name], limitOwed, limit, refill); err != nil {
+				// Whatever the peer missed is retried on the next reconciliation
+				usage.mu.Lock()
+				usage.owed[name] += owed[name]
+				usage.limitOwed = usage.limitOwed || limitOwed
+				usage.mu.Unlock()
+			}
+		}
+
+		if !flushOnly {
+			m.refresh(ctx, key, usage)
+		}
+
+		return true
+	})
+}
+
+// reconcilePeer copies a custom limit to a peer and drains the tokens granted locally from its bucket
+// A peer holding fewer tokens than owed is emptied, since the region already granted them
+func (m *multiRegionBackend) reconcilePeer(ctx context.Context, peer Backend, key string, owed int64, limitOwed bool, limit int64, refill time.Duration) error {
+	if limitOwed {
+		if err := peer.SetLimit(ctx, key, limit, refill); err != nil {
+			return err
+		}
+	}
+
+	if owed <= 0 {
+		return nil
+	}
+
+	return peer.(Drainer).Drain(ctx, key, owed, 0)
+}
+
+// String returns a string representation of the backend
+func (m *multiRegionBackend) String() string {
+	return fmt.Sprintf("MultiRegionBackend{region=%s, peers=%d, share=%.2f, reconcile_interval=%v}",
+		m.options.Region, len(m.peers), m.share, m.options.ReconcileInterval)
+}
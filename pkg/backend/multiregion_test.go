@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestRegions creates two regions, each with its own in-memory backend and the other as its peer
+func newTestRegions(t *testing.T, limit int64) (*multiRegionBackend, *multiRegionBackend) {
+	t.Helper()
+
+	eu, err := NewInMemoryBackend(DefaultOptions().WithLimit(limit).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	us, err := NewInMemoryBackend(DefaultOptions().WithLimit(limit).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	// Reconciliation is run by hand
+	euRegion, err := NewMultiRegionBackend(eu, map[string]Backend{"us": us}, &MultiRegionOptions{Region: "eu", ReconcileInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	usRegion, err := NewMultiRegionBackend(us, map[string]Backend{"eu": eu}, &MultiRegionOptions{Region: "us", ReconcileInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	t.Cleanup(func() {
+		euRegion.Close(context.Background())
+		usRegion.Close(context.Background())
+	})
+
+	return euRegion.(*multiRegionBackend), usRegion.(*multiRegionBackend)
+}
+
+func TestNewMultiRegionBackend(t *testing.T) {
+	local, err := NewInMemoryBackend(DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		peers   map[string]Backend
+		options *MultiRegionOptions
+		wantErr bool
+	}{
+		{name: "default options", options: nil},
+		{name: "empty region", options: &MultiRegionOptions{ReconcileInterval: time.Second}, wantErr: true},
+		{name: "share above 1", options: &MultiRegionOptions{Region: "eu", Share: 1.5, ReconcileInterval: time.Second}, wantErr: true},
+		{name: "zero reconcile interval", options: &MultiRegionOptions{Region: "eu"}, wantErr: true},
+		{name: "nil peer", peers: map[string]Backend{"us": nil}, wantErr: true},
+		{name: "own peer", peers: map[string]Backend{"local": local}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := NewMultiRegionBackend(local, tt.peers, tt.options)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			backend.Close(context.Background())
+		})
+	}
+}
+
+func TestMultiRegionBackendShare(t *testing.T) {
+	ctx := context.Background()
+	eu, us := newTestRegions(t, 10)
+
+	// Each region grants half of the remaining tokens on its own
+	if allowed, err := eu.Take(ctx, "test_key", 5); err != nil || !allowed {
+		t.Fatalf("expected take within the share to be allowed, got %v, %v", allowed, err)
+	}
+	if allowed, _ := eu.Take(ctx, "test_key", 1); allowed {
+		t.Error("expected take beyond the share to be denied")
+	}
+	if allowed, _ := us.Take(ctx, "test_key", 4); !allowed {
+		t.Error("expected take within the other region's share to be allowed")
+	}
+
+	// Reconciliation copies usage across, so both regions see 1 token left
+	eu.reconcileAll(ctx, false)
+	us.reconcileAll(ctx, false)
+
+	for name, region := range map[string]*multiRegionBackend{"eu": eu, "us": us} {
+		info, err := region.GetInfo(ctx, "test_key")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Tokens != 1 {
+			t.Errorf("expected 1 token left in %s, got %d", name, info.Tokens)
+		}
+	}
+
+	// Shares are rounded up, so the last token can still be taken
+	if allowed, _ := us.Take(ctx, "test_key", 1); !allowed {
+		t.Error("expected the last token to be allowed")
+	}
+	if allowed, _ := us.Take(ctx, "test_key", 1); allowed {
+		t.Error("expected take on an empty key to be denied")
+	}
+}
+
+func TestMultiRegionBackendAdminOperations(t *testing.T) {
+	ctx := context.Background()
+	eu, us := newTestRegions(t, 10)
+
+	// Blocks apply to every region at once
+	if err := eu.Block(ctx, "test_key", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed, _ := us.Take(ctx, "test_key", 1); allowed {
+		t.Error("expected a block in one region to reach the other")
+	}
+	if err := us.Unblock(ctx, "test_key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed, _ := eu.Take(ctx, "test_key", 1); !allowed {
+		t.Error("expected an unblock in one region to reach the other")
+	}
+
+	// Limits set directly apply everywhere, those of TakeWithLimit reach the peers on reconciliation
+	if err := eu.SetLimit(ctx, "limited", 4, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, _ := us.GetInfo(ctx, "limited"); info.MaxTokens != 4 {
+		t.Errorf("expected limit 4 in the other region, got %d", info.MaxTokens)
+	}
+
+	if allowed, _ := eu.TakeWithLimit(ctx, "custom", 1, 20, time.Hour); !allowed {
+		t.Fatal("expected take under a custom limit to be allowed")
+	}
+	eu.reconcileAll(ctx, false)
+	if info, _ := us.GetInfo(ctx, "custom"); info.MaxTokens != 20 || info.Tokens != 19 {
+		t.Errorf("expected 19 of 20 tokens in the other region, got %d of %d", info.Tokens, info.MaxTokens)
+	}
+}
+
+func TestMultiRegionBackendCloseFlushes(t *testing.T) {
+	ctx := context.Background()
+
+	eu, err := NewInMemoryBackend(DefaultOptions().WithLimit(10).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	remote, err := NewInMemoryBackend(DefaultOptions().WithLimit(10).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	us := &countingBackend{Backend: remote}
+
+	region, err := NewMultiRegionBackend(eu, map[string]Backend{"us": us}, &MultiRegionOptions{Region: "eu", ReconcileInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	region.Take(ctx, "test_key", 3)
+	if err := region.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if drained := us.drained(); len(drained) != 1 || drained[0] != 3 {
+		t.Errorf("expected the usage to be copied on close, got %v", drained)
+	}
+	if taken := us.taken(); len(taken) != 0 {
+		t.Errorf("expected the usage to be drained rather than taken, got takes %v", taken)
+	}
+}
@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// bucketScenario is a random bucket and a sequence of takes separated by clock steps
+type bucketScenario struct {
+	Limit      int64
+	Debt       int64
+	Refill     time.Duration
+	Optimistic bool
+	Steps      []bucketStep
+}
+
+// bucketStep advances the clock, then takes tokens
+type bucketStep struct {
+	Advance time.Duration
+	Tokens  int64
+}
+
+// Generate returns a scenario whose clock steps range from a fraction of the refill interval to several of them
+func (bucketScenario) Generate(r *rand.Rand, size int) reflect.Value {
+	s := bucketScenario{
+		Limit:      1 + r.Int63n(20),
+		Debt:       r.Int63n(5),
+		Refill:     time.Duration(1+r.Intn(200)) * time.Millisecond,
+		Optimistic: r.Intn(2) == 0,
+		Steps:      make([]bucketStep, 1+r.Intn(size)),
+	}
+
+	for i := range s.Steps {
+		s.Steps[i] = bucketStep{
+			Advance: time.Duration(r.Int63n(int64(3 * s.Refill))),
+			Tokens:  1 + r.Int63n(s.Limit+s.Debt),
+		}
+	}
+
+	return reflect.ValueOf(s)
+}
+
+// newBucket creates the bucket of the scenario, full at start
+func (s bucketScenario) newBucket(start time.Time) *bucket {
+	if s.Optimistic {
+		return newOptimisticBucket("key", s.Limit, s.Limit, s.Refill, start, s.Debt)
+	}
+	return newBucket("key", s.Limit, s.Limit, s.Refill, start, s.Debt)
+}
+
+// propertyConfig runs enough scenarios to cover small and large limits, debts and clock steps
+var propertyConfig = &quick.Config{MaxCount: 500}
+
+func TestPropertyBalanceWithinLimits(t *testing.T) {
+	property := func(s bucketScenario) bool {
+		now := time.Now()
+		bkt := s.newBucket(now)
+
+		for _, step := range s.Steps {
+			now = now.Add(step.Advance)
+			bkt.take(step.Tokens, now)
+
+			tokens, _, limit, _ := bkt.read(now)
+			if tokens > limit || tokens < -s.Debt {
+				t.Logf("balance %d outside [%d, %d] in %+v", tokens, -s.Debt, limit, s)
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyAdmittedWithinWindowAllowance(t *testing.T) {
+	type admission struct {
+		at     time.Time
+		tokens int64
+	}
+
+	property := func(s bucketScenario) bool {
+		now := time.Now()
+		bkt := s.newBucket(now)
+
+		var admitted []admission
+		for _, step := range s.Steps {
+			now = now.Add(step.Advance)
+			if bkt.take(step.Tokens, now) {
+				admitted = append(admitted, admission{at: now, tokens: step.Tokens})
+			}
+		}
+
+		// Any window admits at most a full bucket, the debt and every refill that can land in it
+		for i := range admitted {
+			var sum int64
+			for j := i; j < len(admitted); j++ {
+				sum += admitted[j].tokens
+
+				window := admitted[j].at.Sub(admitted[i].at)
+				if allowance := s.Limit + s.Debt + int64(window/s.Refill) + 1; sum > allowance {
+					t.Logf("%d tokens admitted in %v, allowance %d in %+v", sum, window, allowance, s)
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyRefillsDoNotDrift(t *testing.T) {
+	property := func(s bucketScenario) bool {
+		// A bucket that never fills up keeps every partial interval, so drained often enough it admits exactly
+		// a full bucket plus one token per interval, however the takes fall between refills
+		s.Limit = max(s.Limit, 2)
+
+		start := time.Now()
+		now := start
+		bkt := s.newBucket(start)
+
+		var admitted int64
+		for _, step := range s.Steps {
+			now = now.Add(step.Advance % s.Refill)
+			for bkt.take(1, now) {
+				admitted++
+			}
+		}
+
+		if expected := s.Limit + s.Debt + int64(now.Sub(start)/s.Refill); admitted != expected {
+			t.Logf("admitted %d tokens over %v, expected %d in %+v", admitted, now.Sub(start), expected, s)
+			return false
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyResetRestoresFullCapacity(t *testing.T) {
+	ctx := context.Background()
+
+	property := func(limit uint8, takes []uint8) bool {
+		options := DefaultOptions().WithLimit(int64(limit) + 1).WithRefill(time.Hour)
+
+		inMemory, err := NewInMemoryBackend(options)
+		if err != nil {
+			t.Fatalf("failed to create backend: %v", err)
+		}
+		defer inMemory.Close(ctx)
+
+		redis, _ := newTestRedisBackend(t, options)
+
+		for _, b := range []Backend{inMemory, redis} {
+			for i, tokens := range takes {
+				b.Take(ctx, fmt.Sprintf("key:%d", i%3), int64(tokens)+1)
+			}
+
+			for i := 0; i < 3; i++ {
+				key := fmt.Sprintf("key:%d", i)
+				if err := b.Reset(ctx, key); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				info, err := b.GetInfo(ctx, key)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if info.Tokens != options.DefaultLimit || info.MaxTokens != options.DefaultLimit {
+					t.Logf("expected %d of %d tokens after reset, got %d of %d", options.DefaultLimit, options.DefaultLimit, info.Tokens, info.MaxTokens)
+					return false
+				}
+
+				if allowed, err := b.Take(ctx, key, options.DefaultLimit); err != nil || !allowed {
+					t.Logf("expected a take of the full limit after reset, got %v, %v", allowed, err)
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 50}); err != nil {
+		t.Error(err)
+	}
+}
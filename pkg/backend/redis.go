@@ -3,18 +3,378 @@ package backend
 import (
 	"context"
 	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/devrob-go/go-rate-limiter/pkg/errors"
 	"github.com/go-redis/redis/v8"
 )
 
+// redisNow is the Lua snippet reading the current time in milliseconds from the Redis clock
+// Every instance then refills against the same clock, and effect replication keeps replicas deterministic
+const redisNow = `
+	redis.replicate_commands()
+	local now = redis.call('TIME')
+	local current_time = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+`
+
+// redisBucketTTL is the Lua snippet computing in milliseconds how long an idle bucket is kept
+// A bucket on the default limit is dropped a few times its time to full after its last write, by then a new bucket would be no different
+// A bucket carrying its own limit or refill strategy is kept for bucketTTL, so they outlive short idle spells
+var redisBucketTTL = fmt.Sprintf(`
+	local function bucket_ttl(limit, refill_rate, max_debt, default_limit, default_refill_rate, strategy)
+		if strategy or limit ~= default_limit or refill_rate ~= default_refill_rate then
+			return %[1]d
+		end
+		return math.max(%[2]d, math.min(%[1]d, %[3]d * (limit + max_debt) * refill_rate))
+	end
+`, bucketTTL.Milliseconds(), minBucketTTL.Milliseconds(), bucketTTLRefills)
+
+// redisRefill is the Lua snippet refilling a bucket under its refill strategy, as RefillTokens does
+// It returns the balance and last refill, which keeps the partial interval unless the bucket filled up
+// A strategy read from a missing hash field is false and refills linearly
+const redisRefill = `
+	local function refill(tokens, max_tokens, refill_rate, last_refill, strategy)
+		local intervals = math.floor((current_time - last_refill) / refill_rate)
+		if intervals <= 0 then
+			return tokens, last_refill
+		end
+		
+		local missing = max_tokens - tokens
+		local added = intervals
+		local step = string.match(strategy or '', '^stepped:(%d+)$')
+		if step then
+			added = intervals * tonumber(step)
+		elseif strategy == 'reset' then
+			added = missing
+		elseif strategy == 'exponential' then
+			-- Each interval regains half the missing tokens, at least one
+			local left = missing
+			for i = 1, intervals do
+				if left <= 0 then
+					break
+				end
+				left = left - math.max(1, math.floor(left / 2))
+			end
+			added = missing - left
+		end
+		
+		-- Keep the partial interval so refills do not drift, a full bucket starts over
+		tokens = math.min(max_tokens, tokens + added)
+		if tokens == max_tokens then
+			return tokens, current_time
+		end
+		return tokens, last_refill + intervals * refill_rate
+	end
+`
+
+// takeScript consumes tokens from one bucket, denying while the key is blocked
+// When ARGV[4] is 1 the given limit replaces the stored one, as SetLimit would, but only when it differs
+// ARGV[5] is how far below zero the balance may go, refills pay the debt off first
+// ARGV[6] and ARGV[7] are the grace budget and period in milliseconds given to keys that do not exist yet
+// ARGV[8] and ARGV[9] are the default limit and refill rate, which decide how long the bucket is kept
+// ARGV[10] and ARGV[11] are the burst pool capacity and the milliseconds it takes to regain a credit, spent when the bucket is short
+var takeScript = redis.NewScript(redisNow + redisBucketTTL + redisRefill + redisTake)
+
+// redisTake is the body of takeScript, which takeOnceScript wraps in a function
+const redisTake = `
+	local key = KEYS[1]
+	local block_key = KEYS[2]
+	local tokens_to_consume = tonumber(ARGV[1])
+	local max_tokens = tonumber(ARGV[2])
+	local refill_rate = tonumber(ARGV[3])
+	local set_limit = ARGV[4] == '1'
+	local max_debt = tonumber(ARGV[5])
+	local grace_tokens = tonumber(ARGV[6])
+	local grace_period = tonumber(ARGV[7])
+	local default_limit = tonumber(ARGV[8])
+	local default_refill_rate = tonumber(ARGV[9])
+	local burst_tokens = tonumber(ARGV[10])
+	local burst_refill_rate = tonumber(ARGV[11])
+	
+	-- Deny immediately while the key is blocked
+	if redis.call('EXISTS', block_key) == 1 then
+		return 0
+	end
+	
+	-- Get current bucket state
+	local bucket_data = redis.call('HMGET', key, 'tokens', 'max_tokens', 'refill_rate', 'last_refill', 'grace', 'grace_until', 'refill_strategy', 'burst_spent', 'burst_at')
+	local bucket_max_tokens = tonumber(bucket_data[2]) or max_tokens
+	local current_tokens = tonumber(bucket_data[1]) or bucket_max_tokens
+	local bucket_refill_rate = tonumber(bucket_data[3]) or refill_rate
+	local last_refill = tonumber(bucket_data[4]) or current_time
+	
+	-- Keys seen for the first time get a grace budget
+	local grace = tonumber(bucket_data[5]) or 0
+	local grace_until = tonumber(bucket_data[6]) or 0
+	if grace_tokens > 0 and redis.call('EXISTS', key) == 0 then
+		grace = grace_tokens
+		grace_until = current_time + grace_period
+	end
+	
+	-- Calculate refill
+	current_tokens, last_refill = refill(current_tokens, bucket_max_tokens, bucket_refill_rate, last_refill, bucket_data[7])
+	
+	-- Store the custom limit only when it differs, an unchanged one leaves the refill running
+	local limit_changed = set_limit and (tonumber(bucket_data[2]) ~= max_tokens or tonumber(bucket_data[3]) ~= refill_rate)
+	if limit_changed then
+		bucket_max_tokens = max_tokens
+		bucket_refill_rate = refill_rate
+		current_tokens = math.min(current_tokens, max_tokens)
+	end
+	
+	-- Regain one burst credit per interval, keeping the partial one, a pool that regained everything starts over
+	local burst_spent = tonumber(bucket_data[8]) or 0
+	local burst_at = tonumber(bucket_data[9]) or current_time
+	if burst_tokens > 0 and burst_spent > 0 then
+		local burst_intervals = math.floor((current_time - burst_at) / burst_refill_rate)
+		if burst_intervals >= burst_spent then
+			burst_spent = 0
+		elseif burst_intervals > 0 then
+			burst_spent = burst_spent - burst_intervals
+			burst_at = burst_at + burst_intervals * burst_refill_rate
+		end
+	end
+	if burst_spent == 0 then
+		burst_at = current_time
+	end
+	
+	-- Keep the bucket at least until its grace period is over and its burst pool regained every credit,
+	-- so expiring does not hand out a new budget
+	local ttl = math.max(bucket_ttl(bucket_max_tokens, bucket_refill_rate, max_debt, default_limit, default_refill_rate, bucket_data[7]), grace_until - current_time)
+	if burst_tokens > 0 then
+		ttl = math.max(ttl, burst_spent * burst_refill_rate)
+	end
+	
+	-- Spend the grace budget before the balance while it lasts
+	if grace >= tokens_to_consume and current_time < grace_until then
+		local fields = {
+			'grace', grace - tokens_to_consume,
+			'grace_until', grace_until,
+			'updated_at', current_time
+		}
+		if limit_changed then
+			table.insert(fields, 'max_tokens')
+			table.insert(fields, bucket_max_tokens)
+			table.insert(fields, 'refill_rate')
+			table.insert(fields, bucket_refill_rate)
+			table.insert(fields, 'tokens')
+			table.insert(fields, current_tokens)
+			table.insert(fields, 'last_refill')
+			table.insert(fields, last_refill)
+		end
+		
+		redis.call('HMSET', key, unpack(fields))
+		redis.call('PEXPIRE', key, ttl)
+		
+		return 1
+	end
+	
+	-- Check if we can consume tokens, borrowing up to the debt allowed
+	if current_tokens - tokens_to_consume >= -max_debt then
+		current_tokens = current_tokens - tokens_to_consume
+		
+		-- Update bucket state
+		redis.call('HMSET', key, 
+			'tokens', current_tokens,
+			'max_tokens', bucket_max_tokens,
+			'refill_rate', bucket_refill_rate,
+			'last_refill', last_refill,
+			'updated_at', current_time
+		)
+		
+		redis.call('PEXPIRE', key, ttl)
+		
+		return 1
+	elseif burst_tokens > 0 and burst_tokens - burst_spent >= tokens_to_consume then
+		-- Spend the burst pool when the bucket is short
+		burst_spent = burst_spent + tokens_to_consume
+		
+		redis.call('HMSET', key,
+			'tokens', current_tokens,
+			'max_tokens', bucket_max_tokens,
+			'refill_rate', bucket_refill_rate,
+			'last_refill', last_refill,
+			'burst_spent', burst_spent,
+			'burst_at', burst_at,
+			'updated_at', current_time
+		)
+		
+		redis.call('PEXPIRE', key, math.max(ttl, burst_spent * burst_refill_rate))
+		
+		return 1
+	else
+		-- Keep a new custom limit even when the request is denied, with the balance clamped and refilled up to now
+		if limit_changed then
+			redis.call('HMSET', key,
+				'tokens', current_tokens,
+				'max_tokens', bucket_max_tokens,
+				'refill_rate', bucket_refill_rate,
+				'last_refill', last_refill,
+				'updated_at', current_time
+			)
+			redis.call('PEXPIRE', key, ttl)
+		end
+		
+		return 0
+	end
+`
+
+// takeAllScript consumes tokens from every bucket or from none of them
+// ARGV[4] is how far below zero each balance may go
+var takeAllScript = redis.NewScript(redisNow + redisBucketTTL + redisRefill + `
+	local count = #KEYS / 2
+	local tokens_to_consume = tonumber(ARGV[1])
+	local max_tokens = tonumber(ARGV[2])
+	local refill_rate = tonumber(ARGV[3])
+	local max_debt = tonumber(ARGV[4])
+	
+	local states = {}
+	for i = 1, count do
+		-- Deny immediately if any key is blocked
+		if redis.call('EXISTS', KEYS[count + i]) == 1 then
+			return 0
+		end
+		
+		local bucket_data = redis.call('HMGET', KEYS[i], 'tokens', 'max_tokens', 'refill_rate', 'last_refill', 'refill_strategy')
+		local bucket_max_tokens = tonumber(bucket_data[2]) or max_tokens
+		local current_tokens = tonumber(bucket_data[1]) or bucket_max_tokens
+		local bucket_refill_rate = tonumber(bucket_data[3]) or refill_rate
+		local last_refill = tonumber(bucket_data[4]) or current_time
+		
+		-- Calculate refill
+		current_tokens, last_refill = refill(current_tokens, bucket_max_tokens, bucket_refill_rate, last_refill, bucket_data[5])
+		
+		-- Every bucket must be able to cover the request, borrowing up to the debt allowed
+		if current_tokens - tokens_to_consume < -max_debt then
+			return 0
+		end
+		
+		states[i] = {current_tokens, bucket_max_tokens, bucket_refill_rate, last_refill, bucket_data[5]}
+	end
+	
+	for i = 1, count do
+		local state = states[i]
+		redis.call('HMSET', KEYS[i],
+			'tokens', state[1] - tokens_to_consume,
+			'max_tokens', state[2],
+			'refill_rate', state[3],
+			'last_refill', state[4],
+			'updated_at', current_time
+		)
+		
+		redis.call('PEXPIRE', KEYS[i], bucket_ttl(state[2], state[3], max_debt, max_tokens, refill_rate, state[5]))
+	end
+	
+	return 1
+`)
+
+// setLimitScript stores a custom limit on a bucket, returning 0 without writing when the limit is unchanged
+// ARGV[3] and ARGV[4] are the default limit and refill rate of buckets without their own, ARGV[5] is the debt allowed
+var setLimitScript = redis.NewScript(redisNow + redisBucketTTL + redisRefill + `
+	local limit = tonumber(ARGV[1])
+	local refill_rate = tonumber(ARGV[2])
+	
+	local bucket_data = redis.call('HMGET', KEYS[1], 'tokens', 'max_tokens', 'refill_rate', 'last_refill', 'refill_strategy')
+	
+	-- Rewriting an unchanged limit would restart the refill, so leave the bucket alone
+	if tonumber(bucket_data[2]) == limit and tonumber(bucket_data[3]) == refill_rate then
+		return 0
+	end
+	
+	local fields = {
+		'max_tokens', limit,
+		'refill_rate', refill_rate,
+		'updated_at', current_time
+	}
+	
+	local last_refill = current_time
+	local current_tokens = tonumber(bucket_data[1])
+	if current_tokens then
+		-- Apply the refill owed under the old rate, then clamp the balance to the new limit
+		local bucket_max_tokens = tonumber(bucket_data[2]) or tonumber(ARGV[3])
+		local bucket_refill_rate = tonumber(bucket_data[3]) or tonumber(ARGV[4])
+		last_refill = tonumber(bucket_data[4]) or current_time
+		
+		current_tokens, last_refill = refill(current_tokens, bucket_max_tokens, bucket_refill_rate, last_refill, bucket_data[5])
+		
+		table.insert(fields, 'tokens')
+		table.insert(fields, math.min(current_tokens, limit))
+	end
+	
+	table.insert(fields, 'last_refill')
+	table.insert(fields, last_refill)
+	
+	redis.call('HMSET', KEYS[1], unpack(fields))
+	redis.call('PEXPIRE', KEYS[1], bucket_ttl(limit, refill_rate, tonumber(ARGV[5]), tonumber(ARGV[3]), tonumber(ARGV[4]), bucket_data[5]))
+	
+	return 1
+`)
+
+// setStrategyScript stores the refill strategy named by ARGV[1] on a bucket, an empty name refilling linearly
+// It returns 0 without writing when the strategy is unchanged, otherwise the refill owed under the old one is applied first
+// ARGV[2] and ARGV[3] are the default limit and refill rate of buckets without their own, ARGV[4] is the debt allowed
+var setStrategyScript = redis.NewScript(redisNow + redisBucketTTL + redisRefill + `
+	local strategy = ARGV[1]
+	local default_limit = tonumber(ARGV[2])
+	local default_refill_rate = tonumber(ARGV[3])
+	
+	local bucket_data = redis.call('HMGET', KEYS[1], 'tokens', 'max_tokens', 'refill_rate', 'last_refill', 'refill_strategy')
+	if (bucket_data[5] or '') == strategy then
+		return 0
+	end
+	
+	local limit = tonumber(bucket_data[2]) or default_limit
+	local refill_rate = tonumber(bucket_data[3]) or default_refill_rate
+	local fields = {'updated_at', current_time}
+	
+	local current_tokens = tonumber(bucket_data[1])
+	if current_tokens then
+		local last_refill = tonumber(bucket_data[4]) or current_time
+		current_tokens, last_refill = refill(current_tokens, limit, refill_rate, last_refill, bucket_data[5])
+		
+		table.insert(fields, 'tokens')
+		table.insert(fields, current_tokens)
+		table.insert(fields, 'last_refill')
+		table.insert(fields, last_refill)
+	end
+	
+	if strategy == '' then
+		redis.call('HDEL', KEYS[1], 'refill_strategy')
+		strategy = false
+	else
+		table.insert(fields, 'refill_strategy')
+		table.insert(fields, strategy)
+	end
+	
+	redis.call('HMSET', KEYS[1], unpack(fields))
+	redis.call('PEXPIRE', KEYS[1], bucket_ttl(limit, refill_rate, tonumber(ARGV[4]), default_limit, default_refill_rate, strategy))
+	
+	return 1
+`)
+
 // redisBackend provides a Redis implementation of the Backend interface
 // It uses Lua scripts for atomic operations and supports connection pooling
 type redisBackend struct {
-	client  *redis.Client
-	options *Options
-	closed  bool
+	client     *redis.Client
+	options    *Options
+	closed     atomic.Bool
+	closeMu    sync.Mutex
+	instanceID string
+	keys       redisKeys
+
+	stopCleanup chan struct{}
+	cleanupDone chan struct{}
+
+	wakeups wakeupHub
+	usage   pendingUsage
+
+	// replicas serve hedged GetInfo reads, nextReplica picks them in turn
+	replicas    []*redis.Client
+	nextReplica atomic.Uint32
 }
 
 // NewRedisBackend creates a new Redis backend with the given Redis URL and options
@@ -31,18 +391,9 @@ func NewRedisBackend(redisURL string, options *Options) (Backend, error) {
 		return nil, errors.Wrap(err, "invalid options")
 	}
 
-	// Parse Redis URL and create client
-	opts, err := redis.ParseURL(redisURL)
+	opts, err := redisClientOptions(redisURL, options)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse Redis URL")
-	}
-
-	// Override with custom options if provided
-	if options.DefaultLimit > 0 {
-		// Use default limit for token bucket
-	}
-	if options.DefaultRefill > 0 {
-		// Use default refill rate for token bucket
+		return nil, err
 	}
 
 	client := redis.NewClient(opts)
@@ -56,16 +407,90 @@ func NewRedisBackend(redisURL string, options *Options) (Backend, error) {
 		return nil, errors.Wrap(err, "failed to connect to Redis")
 	}
 
-	return &redisBackend{
-		client:  client,
-		options: options,
-	}, nil
+	// Load scripts up front so Takes only send their SHA, Run reloads them after a NOSCRIPT
+	for _, script := range []*redis.Script{takeScript, takeAllScript, setLimitScript} {
+		if err := script.Load(ctx, client).Err(); err != nil {
+			client.Close()
+			return nil, errors.Wrap(err, "failed to load Redis scripts")
+		}
+	}
+
+	replicas, err := newReplicaClients(options)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	backend := &redisBackend{
+		client:     client,
+		options:    options,
+		instanceID: newInstanceID(),
+		keys:       redisKeys{prefix: options.KeyPrefix, hasher: newKeyHasher(options.KeyHashSecret)},
+		replicas:   replicas,
+	}
+
+	if options.SharedCleanup {
+		backend.stopCleanup = make(chan struct{})
+		backend.cleanupDone = make(chan struct{})
+		go backend.cleanupRoutine(options.CleanupInterval)
+	}
+
+	return backend, nil
+}
+
+// redisClientOptions parses a Redis URL and applies the pool, timeout, auth and TLS settings of the options
+func redisClientOptions(redisURL string, options *Options) (*redis.Options, error) {
+	// Parse Redis URL
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Redis URL")
+	}
+
+	// Apply pool and timeout settings, zero values keep the client defaults
+	if options.PoolSize > 0 {
+		opts.PoolSize = options.PoolSize
+	}
+	if options.MinIdleConns > 0 {
+		opts.MinIdleConns = options.MinIdleConns
+	}
+	if options.MaxRetries != 0 {
+		opts.MaxRetries = options.MaxRetries
+	}
+	if options.MinRetryBackoff != 0 {
+		opts.MinRetryBackoff = options.MinRetryBackoff
+	}
+	if options.MaxRetryBackoff != 0 {
+		opts.MaxRetryBackoff = options.MaxRetryBackoff
+	}
+	if options.DialTimeout > 0 {
+		opts.DialTimeout = options.DialTimeout
+	}
+	if options.OperationTimeout > 0 {
+		opts.ReadTimeout = options.OperationTimeout
+		opts.WriteTimeout = options.OperationTimeout
+	}
+
+	if options.Password != "" {
+		opts.Username = options.Username
+		opts.Password = options.Password
+	}
+
+	if options.TLS != nil {
+		host, _, _ := net.SplitHostPort(opts.Addr)
+		tlsConfig, err := options.TLS.config(host)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid TLS options")
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	return opts, nil
 }
 
 // Take attempts to consume tokens from the bucket using a Lua script
-func (r *redisBackend) Take(ctx context.Context, key string, tokens int) (bool, error) {
-	if r.closed {
-		return false, errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+func (r *redisBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	if r.closed.Load() {
+		return false, errors.ErrBackendClosed
 	}
 
 	if err := validateKey(key); err != nil {
@@ -83,69 +508,133 @@ func (r *redisBackend) Take(ctx context.Context, key string, tokens int) (bool,
 	default:
 	}
 
-	// Use Lua script for atomic token consumption
-	script := `
-		local key = KEYS[1]
-		local tokens_to_consume = tonumber(ARGV[1])
-		local max_tokens = tonumber(ARGV[2])
-		local refill_rate = tonumber(ARGV[3])
-		local current_time = tonumber(ARGV[4])
-		
-		-- Get current bucket state
-		local bucket_data = redis.call('HMGET', key, 'tokens', 'max_tokens', 'refill_rate', 'last_refill')
-		local current_tokens = tonumber(bucket_data[1]) or max_tokens
-		local bucket_max_tokens = tonumber(bucket_data[2]) or max_tokens
-		local bucket_refill_rate = tonumber(bucket_data[3]) or refill_rate
-		local last_refill = tonumber(bucket_data[4]) or current_time
-		
-		-- Calculate refill
-		local time_elapsed = current_time - last_refill
-		local tokens_to_add = math.floor(time_elapsed / bucket_refill_rate)
-		
-		if tokens_to_add > 0 then
-			current_tokens = math.min(bucket_max_tokens, current_tokens + tokens_to_add)
-			last_refill = current_time
-		end
-		
-		-- Check if we can consume tokens
-		if current_tokens >= tokens_to_consume then
-			current_tokens = current_tokens - tokens_to_consume
-			
-			-- Update bucket state
-			redis.call('HMSET', key, 
-				'tokens', current_tokens,
-				'max_tokens', bucket_max_tokens,
-				'refill_rate', bucket_refill_rate,
-				'last_refill', last_refill,
-				'updated_at', current_time
-			)
-			
-			-- Set expiration (cleanup after 24 hours of inactivity)
-			redis.call('EXPIRE', key, 86400)
-			
-			return 1
-		else
-			return 0
-		end
-	`
+	return r.take(ctx, key, tokens, r.options.DefaultLimit, r.options.DefaultRefill, false)
+}
+
+// TakeWithLimit sets a custom limit for a key and consumes tokens from it in a single round trip
+func (r *redisBackend) TakeWithLimit(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration) (bool, error) {
+	if r.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	if limit <= 0 {
+		return false, errors.Wrap(errors.ErrInvalidTokens, "limit must be positive")
+	}
+
+	if capacity := MaxLimit - r.options.MaxDebt; limit > capacity {
+		return false, errors.Wrapf(errors.ErrInvalidTokens, "limit must not exceed %d", capacity)
+	}
 
-	// Execute Lua script
-	currentTime := time.Now().Unix()
-	result, err := r.client.Eval(ctx, script, []string{key}, tokens, r.options.DefaultLimit, r.options.DefaultRefill.Milliseconds(), currentTime).Int()
+	if refill <= 0 {
+		return false, errors.Wrap(errors.ErrInvalidTokens, "refill rate must be positive")
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	return r.take(ctx, key, tokens, limit, refill, true)
+}
+
+// take runs the take script, storing limit and refill on the bucket when setLimit is true
+func (r *redisBackend) take(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration, setLimit bool) (bool, error) {
+	force := 0
+	if setLimit {
+		force = 1
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// Execute Lua script, by SHA when Redis has it cached
+	key = r.keys.bucket(key)
+	result, err := takeScript.Run(ctx, r.client, []string{key, r.keys.block(key)}, tokens, limit, refillMillis(refill), force, r.options.MaxDebt,
+		r.options.GraceTokens, r.options.GracePeriod.Milliseconds(), r.options.DefaultLimit, refillMillis(r.options.DefaultRefill),
+		r.options.BurstPoolTokens, refillMillis(r.options.BurstPoolRefill)).Int()
 	if err != nil {
 		if err == redis.Nil {
 			return false, nil
 		}
-		return false, errors.Wrap(err, "failed to execute Redis script")
+		return false, errors.Wrap(r.timeoutError("take", err), "failed to execute Redis script")
 	}
 
+	r.recordUsage(result == 1, tokens)
+	r.recordActivity(ctx, result == 1, key)
+	return result == 1, nil
+}
+
+// TakeAll atomically consumes tokens from every listed bucket using a single Lua script
+func (r *redisBackend) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	if r.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	if len(keys) == 0 {
+		return false, errors.Wrap(errors.ErrInvalidKey, "keys cannot be empty")
+	}
+
+	for _, key := range keys {
+		if err := validateKey(key); err != nil {
+			return false, err
+		}
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	stored := make([]string, len(keys))
+	for i, key := range keys {
+		stored[i] = r.keys.bucket(key)
+	}
+
+	// Bucket keys come first, followed by their block keys in the same order
+	sorted := uniqueSortedKeys(stored)
+	scriptKeys := make([]string, 0, len(sorted)*2)
+	scriptKeys = append(scriptKeys, sorted...)
+	for _, key := range sorted {
+		scriptKeys = append(scriptKeys, r.keys.block(key))
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// Execute Lua script, by SHA when Redis has it cached
+	result, err := takeAllScript.Run(ctx, r.client, scriptKeys, tokens, r.options.DefaultLimit, refillMillis(r.options.DefaultRefill), r.options.MaxDebt).Int()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, errors.Wrap(r.timeoutError("take_all", err), "failed to execute Redis script")
+	}
+
+	r.recordUsage(result == 1, tokens*int64(len(sorted)))
+	r.recordActivity(ctx, result == 1, sorted...)
 	return result == 1, nil
 }
 
 // Reset clears the rate limit for a specific key
 func (r *redisBackend) Reset(ctx context.Context, key string) error {
-	if r.closed {
-		return errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+	if r.closed.Load() {
+		return errors.ErrBackendClosed
 	}
 
 	if err := validateKey(key); err != nil {
@@ -159,17 +648,22 @@ func (r *redisBackend) Reset(ctx context.Context, key string) error {
 	default:
 	}
 
-	if err := r.client.Del(ctx, key).Err(); err != nil {
-		return errors.Wrap(err, "failed to delete Redis key")
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	stored := r.keys.bucket(key)
+	if err := r.client.Del(ctx, stored).Err(); err != nil {
+		return errors.Wrap(r.timeoutError("reset", err), "failed to delete Redis key")
 	}
 
+	r.publishWakeup(ctx, stored)
 	return nil
 }
 
 // GetInfo returns information about the current state of a key
 func (r *redisBackend) GetInfo(ctx context.Context, key string) (*TokenInfo, error) {
-	if r.closed {
-		return nil, errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+	if r.closed.Load() {
+		return nil, errors.ErrBackendClosed
 	}
 
 	if err := validateKey(key); err != nil {
@@ -183,89 +677,115 @@ func (r *redisBackend) GetInfo(ctx context.Context, key string) (*TokenInfo, err
 	default:
 	}
 
-	// Get bucket data from Redis
-	bucketData, err := r.client.HMGet(ctx, key, "tokens", "max_tokens", "refill_rate", "last_refill", "updated_at").Result()
-	if err != nil {
-		if err == redis.Nil {
-			// Key doesn't exist, return default info
-			return &TokenInfo{
-				Key:        key,
-				Tokens:     r.options.DefaultLimit,
-				MaxTokens:  r.options.DefaultLimit,
-				RefillRate: r.options.DefaultRefill,
-				LastRefill: time.Now(),
-				NextRefill: time.Now().Add(r.options.DefaultRefill),
-				ResetTime:  time.Now().Add(r.options.DefaultRefill),
-			}, nil
-		}
-		return nil, errors.Wrap(err, "failed to get bucket info from Redis")
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	stored := r.keys.bucket(key)
+	return r.hedgedRead(ctx, func(ctx context.Context, client *redis.Client) (*TokenInfo, error) {
+		return r.readInfo(ctx, client, key, stored)
+	})
+}
+
+// infoScript reads everything GetInfo reports on a bucket in one round trip, writing nothing so replicas can run it
+// KEYS are the bucket, its block marker, lease set and activity hash. ARGV[1] is the activity window in milliseconds,
+// 0 skips reading activity, and the rest are the bucket fields to read
+// It returns the bucket fields, the block TTL in milliseconds, the unexpired leases and the activity
+var infoScript = redis.NewScript(redisNow + redisReadLeases + redisReadActivity + `
+	local activity = {}
+	if ARGV[1] ~= '0' then
+		activity = read_activity(KEYS[4], tonumber(ARGV[1]))
+	end
+
+	return {
+		redis.call('HMGET', KEYS[1], unpack(ARGV, 2)),
+		redis.call('PTTL', KEYS[2]),
+		read_leases(KEYS[3]),
+		activity,
 	}
+`)
 
-	// Parse bucket data
-	var tokens, maxTokens int
-	var refillRate time.Duration
-	var lastRefill time.Time
+// readInfo reads the state of a key stored under stored from client, the primary or a replica
+func (r *redisBackend) readInfo(ctx context.Context, client *redis.Client, key, stored string) (*TokenInfo, error) {
+	var window int64
+	if r.options.KeyActivity {
+		window = statsWindow.Milliseconds()
+	}
 
-	if bucketData[0] != nil {
-		if t, ok := bucketData[0].(string); ok {
-			fmt.Sscanf(t, "%d", &tokens)
-		}
+	// The burst pool fields follow the bucket fields
+	args := []interface{}{window}
+	for _, field := range redisBucketFields {
+		args = append(args, field)
 	}
+	args = append(args, "burst_spent", "burst_at")
 
-	if bucketData[1] != nil {
-		if t, ok := bucketData[1].(string); ok {
-			fmt.Sscanf(t, "%d", &maxTokens)
-		}
+	result, err := infoScript.Run(ctx, client, []string{stored, r.keys.block(stored), r.keys.leases(stored), r.keys.activity(stored)}, args...).Slice()
+	if err != nil {
+		return nil, errors.Wrap(r.timeoutError("get_info", err), "failed to get bucket info from Redis")
 	}
 
-	if bucketData[2] != nil {
-		if t, ok := bucketData[2].(string); ok {
-			if ms, err := time.ParseDuration(t + "ms"); err == nil {
-				refillRate = ms
-			}
-		}
+	if len(result) != 4 {
+		return nil, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket info of key %q", key)
 	}
 
-	if bucketData[3] != nil {
-		if t, ok := bucketData[3].(string); ok {
-			if ts, err := time.Parse(time.RFC3339, t); err == nil {
-				lastRefill = ts
-			}
-		}
+	bucketData, _ := result[0].([]interface{})
+	blockTTL, _ := result[1].(int64)
+	leaseData, _ := result[2].([]interface{})
+	activityData, _ := result[3].([]interface{})
+	if len(bucketData) != len(args)-1 {
+		return nil, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket info of key %q", key)
 	}
 
-	// Skip updated_at field for now
+	now := time.Now()
+	var blockedUntil time.Time
+	if blockTTL > 0 {
+		blockedUntil = now.Add(time.Duration(blockTTL) * time.Millisecond)
+	}
 
-	// Use defaults if values are missing
-	if maxTokens == 0 {
-		maxTokens = r.options.DefaultLimit
+	bucket, err := parseRedisBucket(bucketData[:len(redisBucketFields)], r.options.DefaultLimit, r.options.DefaultRefill, now)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse bucket of key %q", key)
 	}
-	if refillRate == 0 {
-		refillRate = r.options.DefaultRefill
+
+	var activity *KeyActivity
+	if r.options.KeyActivity {
+		if activity, err = parseActivity(activityData); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse activity of key %q", key)
+		}
 	}
-	if lastRefill.IsZero() {
-		lastRefill = time.Now()
+
+	var burstCredits int64
+	if r.options.BurstPoolTokens > 0 {
+		if burstCredits, err = r.burstCredits(bucketData[len(redisBucketFields):]); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse burst pool of key %q", key)
+		}
 	}
 
-	// Calculate next refill and reset time
-	nextRefill := lastRefill.Add(refillRate)
-	resetTime := lastRefill.Add(refillRate)
+	// Report reservations as debt, then calculate next refill and reset time
+	bucket.tokens, bucket.lastRefill = unreserved(bucket.tokens, bucket.lastRefill, time.Now(), bucket.refillRate)
+	nextRefill := bucket.lastRefill.Add(bucket.refillRate)
+	resetTime := bucket.lastRefill.Add(bucket.refillRate)
 
 	return &TokenInfo{
 		Key:        key,
-		Tokens:     tokens,
-		MaxTokens:  maxTokens,
-		RefillRate: refillRate,
-		LastRefill: lastRefill,
+		Tokens:     bucket.tokens,
+		MaxTokens:  bucket.maxTokens,
+		RefillRate: bucket.refillRate,
+		LastRefill: bucket.lastRefill,
 		NextRefill: nextRefill,
 		ResetTime:  resetTime,
+		Strategy:   bucket.strategy,
+
+		BurstCredits: burstCredits,
+		BlockedUntil: blockedUntil,
+		Leases:       parseLeases(leaseData, now),
+		Activity:     activity,
 	}, nil
 }
 
 // SetLimit sets a custom limit for a specific key
-func (r *redisBackend) SetLimit(ctx context.Context, key string, limit int, refill time.Duration) error {
-	if r.closed {
-		return errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+func (r *redisBackend) SetLimit(ctx context.Context, key string, limit int64, refill time.Duration) error {
+	if r.closed.Load() {
+		return errors.ErrBackendClosed
 	}
 
 	if err := validateKey(key); err != nil {
@@ -276,6 +796,10 @@ func (r *redisBackend) SetLimit(ctx context.Context, key string, limit int, refi
 		return errors.Wrap(errors.ErrInvalidTokens, "limit must be positive")
 	}
 
+	if capacity := MaxLimit - r.options.MaxDebt; limit > capacity {
+		return errors.Wrapf(errors.ErrInvalidTokens, "limit must not exceed %d", capacity)
+	}
+
 	if refill <= 0 {
 		return errors.Wrap(errors.ErrInvalidTokens, "refill rate must be positive")
 	}
@@ -287,46 +811,247 @@ func (r *redisBackend) SetLimit(ctx context.Context, key string, limit int, refi
 	default:
 	}
 
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	// Update bucket limits in Redis
-	now := time.Now()
-	err := r.client.HMSet(ctx, key,
-		"max_tokens", limit,
-		"refill_rate", refill.Milliseconds(),
-		"last_refill", now.Format(time.RFC3339),
-		"updated_at", now.Format(time.RFC3339),
-	).Err()
+	stored := r.keys.bucket(key)
+	changed, err := setLimitScript.Run(ctx, r.client, []string{stored}, limit, refillMillis(refill), r.options.DefaultLimit, refillMillis(r.options.DefaultRefill), r.options.MaxDebt).Int()
+	if err != nil {
+		return errors.Wrap(r.timeoutError("set_limit", err), "failed to set bucket limits in Redis")
+	}
+
+	// Waiters only need waking when the limit actually changed
+	if changed == 1 {
+		r.publishWakeup(ctx, stored)
+	}
+	return nil
+}
+
+// SetRefillStrategy sets how the bucket of a key refills, nil restores linear refills
+// Only the built-in strategies are supported, since the Lua scripts refill the buckets
+func (r *redisBackend) SetRefillStrategy(ctx context.Context, key string, strategy RefillStrategy) error {
+	if r.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	if err := validateRefillStrategy(strategy); err != nil {
+		return err
+	}
 
+	name, err := refillStrategyName(strategy)
 	if err != nil {
-		return errors.Wrap(err, "failed to set bucket limits in Redis")
+		return err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "context cancelled")
+	default:
 	}
 
-	// Set expiration
-	if err := r.client.Expire(ctx, key, 24*time.Hour).Err(); err != nil {
-		return errors.Wrap(err, "failed to set key expiration")
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	stored := r.keys.bucket(key)
+	changed, err := setStrategyScript.Run(ctx, r.client, []string{stored}, name, r.options.DefaultLimit, refillMillis(r.options.DefaultRefill), r.options.MaxDebt).Int()
+	if err != nil {
+		return errors.Wrap(r.timeoutError("set_refill_strategy", err), "failed to set refill strategy in Redis")
 	}
 
+	// A faster refill may let waiters through sooner
+	if changed == 1 {
+		r.publishWakeup(ctx, stored)
+	}
 	return nil
 }
 
-// Close gracefully shuts down the backend
-func (r *redisBackend) Close(ctx context.Context) error {
-	if r.closed {
-		return nil
+// Block denies all Takes for a specific key until the duration expires
+func (r *redisBackend) Block(ctx context.Context, key string, duration time.Duration) error {
+	if r.closed.Load() {
+		return errors.ErrBackendClosed
 	}
 
-	r.closed = true
+	if err := validateKey(key); err != nil {
+		return err
+	}
 
-	if r.client != nil {
-		return r.client.Close()
+	if duration <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "block duration must be positive")
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// The block key expires on its own, so no cleanup is needed
+	if err := r.client.Set(ctx, r.keys.block(r.keys.bucket(key)), 1, duration).Err(); err != nil {
+		return errors.Wrap(r.timeoutError("block", err), "failed to set block in Redis")
 	}
 
 	return nil
 }
 
+// Unblock lifts a block on a specific key before it expires
+func (r *redisBackend) Unblock(ctx context.Context, key string) error {
+	if r.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	stored := r.keys.bucket(key)
+	if err := r.client.Del(ctx, r.keys.block(stored)).Err(); err != nil {
+		return errors.Wrap(r.timeoutError("unblock", err), "failed to delete block from Redis")
+	}
+
+	r.publishWakeup(ctx, stored)
+	return nil
+}
+
+// Keys scans Redis for bucket keys matching the glob pattern
+// Only hashes holding bucket state are returned, so other data in the same database is skipped
+// With key hashing enabled the stored hashes are listed and matched instead of the original keys
+func (r *redisBackend) Keys(ctx context.Context, pattern string) ([]string, error) {
+	if r.closed.Load() {
+		return nil, errors.ErrBackendClosed
+	}
+
+	var keys []string
+	err := r.scanBuckets(ctx, pattern, func(batch []string) error {
+		for _, bucket := range batch {
+			if name, ok := r.keys.name(bucket); ok {
+				keys = append(keys, name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// scanBuckets calls fn with each batch of the Redis keys of buckets whose stored form matches the glob pattern
+func (r *redisBackend) scanBuckets(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	pattern = r.keys.pattern(pattern)
+
+	var cursor uint64
+	for {
+		// Check if context is cancelled
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "context cancelled")
+		default:
+		}
+
+		buckets, next, err := r.scanBatch(ctx, cursor, pattern)
+		if err != nil {
+			return err
+		}
+
+		if len(buckets) > 0 {
+			if err := fn(buckets); err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// scanBatch scans one batch of keys from the cursor and keeps those holding bucket state
+func (r *redisBackend) scanBatch(ctx context.Context, cursor uint64, pattern string) ([]string, uint64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	batch, next, err := r.client.ScanType(ctx, cursor, pattern, 1000, "hash").Result()
+	if err != nil {
+		return nil, 0, errors.Wrap(r.timeoutError("scan", err), "failed to scan Redis keys")
+	}
+
+	if len(batch) == 0 {
+		return nil, next, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(batch))
+	for i, key := range batch {
+		cmds[i] = pipe.HExists(ctx, key, "max_tokens")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, 0, errors.Wrap(r.timeoutError("scan", err), "failed to inspect Redis keys")
+	}
+
+	buckets := make([]string, 0, len(batch))
+	for i, cmd := range cmds {
+		if cmd.Val() {
+			buckets = append(buckets, batch[i])
+		}
+	}
+
+	return buckets, next, nil
+}
+
+// Close gracefully shuts down the backend
+func (r *redisBackend) Close(ctx context.Context) error {
+	return shutdownOnce(&r.closeMu, &r.closed, func() error {
+		if r.stopCleanup != nil {
+			close(r.stopCleanup)
+			<-r.cleanupDone
+		}
+
+		r.wakeups.close()
+
+		if r.client != nil {
+			// Counts that cannot be flushed are lost with the instance, they never block shutting down
+			flushCtx, cancel := r.withTimeout(ctx)
+			r.flushUsage(flushCtx)
+			cancel()
+
+			for _, replica := range r.replicas {
+				replica.Close()
+			}
+
+			return r.client.Close()
+		}
+
+		return nil
+	})
+}
+
 // HealthCheck performs a health check on the backend
 func (r *redisBackend) HealthCheck(ctx context.Context) error {
-	if r.closed {
-		return errors.Wrap(errors.ErrBackendUnavailable, "backend is closed")
+	if r.closed.Load() {
+		return errors.ErrBackendClosed
 	}
 
 	// Check if context is cancelled
@@ -336,17 +1061,48 @@ func (r *redisBackend) HealthCheck(ctx context.Context) error {
 	default:
 	}
 
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	// Simple ping to Redis
 	if err := r.client.Ping(ctx).Err(); err != nil {
-		return errors.Wrap(err, "Redis health check failed")
+		return errors.Wrap(r.timeoutError("health_check", err), "Redis health check failed")
 	}
 
 	return nil
 }
 
+// refillMillis converts a refill rate to the milliseconds the scripts work in, at least one
+func refillMillis(refill time.Duration) int64 {
+	return max(refill.Milliseconds(), 1)
+}
+
+// withTimeout bounds ctx by the operation timeout when one is set
+func (r *redisBackend) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.options.OperationTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, r.options.OperationTimeout)
+}
+
+// timeoutError turns a timeout of the operation into a TimeoutError, other errors are returned unchanged
+func (r *redisBackend) timeoutError(op string, err error) error {
+	if r.options.OperationTimeout <= 0 || errors.Classify(err) != errors.ClassTimeout {
+		return err
+	}
+
+	return &errors.TimeoutError{
+		Message: "Redis operation timed out",
+		Op:      op,
+		Timeout: r.options.OperationTimeout,
+		Cause:   err,
+	}
+}
+
 // String returns a string representation of the backend
 func (r *redisBackend) String() string {
-	if r.closed {
+	if r.closed.Load() {
 		return "RedisBackend{closed=true}"
 	}
 
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// recordActivityScript counts a decision on each of KEYS, activity hashes holding per-window request and denial fields
+// ARGV[1] is 1 for a denial, ARGV[2] the window and ARGV[3] the retention in milliseconds
+// Fields of windows before the previous one are dropped
+var recordActivityScript = redis.NewScript(redisNow + `
+	local denied = ARGV[1] == '1'
+	local window_ms = tonumber(ARGV[2])
+	local window = math.floor(current_time / window_ms)
+	local current_suffix = ':' .. window
+	local previous_suffix = ':' .. (window - 1)
+
+	for _, key in ipairs(KEYS) do
+		redis.call('HINCRBY', key, 'requests' .. current_suffix, 1)
+		if denied then
+			redis.call('HINCRBY', key, 'denied' .. current_suffix, 1)
+			redis.call('HSET', key, 'last_denied', current_time)
+		end
+
+		for _, field in ipairs(redis.call('HKEYS', key)) do
+			local suffix = string.match(field, '^%a+(:%d+)$')
+			if suffix and suffix ~= current_suffix and suffix ~= previous_suffix then
+				redis.call('HDEL', key, field)
+			end
+		end
+
+		redis.call('PEXPIRE', key, ARGV[3])
+	end
+
+	return 1
+`)
+
+// redisReadActivity is the Lua function returning the requests and denials in an activity hash over the sliding window
+// of window_ms milliseconds and the time of its last denial, the previous window is weighted as statsScript weighs it
+const redisReadActivity = `
+	local function read_activity(key, window_ms)
+		local window = math.floor(current_time / window_ms)
+
+		local data = redis.call('HMGET', key,
+			'requests:' .. window, 'denied:' .. window,
+			'requests:' .. (window - 1), 'denied:' .. (window - 1),
+			'last_denied')
+
+		local elapsed = (current_time % window_ms) / window_ms
+		local requests = (tonumber(data[1]) or 0) + math.floor((tonumber(data[3]) or 0) * (1 - elapsed))
+		local denied = (tonumber(data[2]) or 0) + math.floor((tonumber(data[4]) or 0) * (1 - elapsed))
+
+		return {requests, denied, tonumber(data[5]) or 0}
+	end
+`
+
+// recordActivity counts a decision on the stored keys when the options enable key activity
+// Activity is diagnostic, so a failure to record it does not fail the decision already made
+func (r *redisBackend) recordActivity(ctx context.Context, allowed bool, stored ...string) {
+	if !r.options.KeyActivity {
+		return
+	}
+
+	keys := make([]string, len(stored))
+	for i, key := range stored {
+		keys[i] = r.keys.activity(key)
+	}
+
+	denied := 0
+	if !allowed {
+		denied = 1
+	}
+	recordActivityScript.Run(ctx, r.client, keys, denied, statsWindow.Milliseconds(), keyActivityRetention.Milliseconds())
+}
+
+// parseActivity parses the requests, denials and last denial returned by read_activity
+func parseActivity(result []interface{}) (*KeyActivity, error) {
+	values := make([]int64, 3)
+	if len(result) != len(values) {
+		return nil, errors.Wrapf(errors.ErrBackendUnavailable, "expected %d activity values, got %d", len(values), len(result))
+	}
+	for i, value := range result {
+		n, ok := value.(int64)
+		if !ok {
+			return nil, errors.Wrapf(errors.ErrBackendUnavailable, "malformed activity value: %v", value)
+		}
+		values[i] = n
+	}
+
+	activity := &KeyActivity{
+		Requests: values[0],
+		Denied:   values[1],
+		Window:   statsWindow,
+	}
+	if values[2] > 0 {
+		activity.LastDenied = time.UnixMilli(values[2])
+	}
+
+	return activity, nil
+}
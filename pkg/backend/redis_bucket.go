@@ -0,0 +1,104 @@
+package backend
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// redisBucketFields are the hash fields GetInfo reads, in the order parseRedisBucket expects them
+var redisBucketFields = []string{"tokens", "max_tokens", "refill_rate", "last_refill", "refill_strategy"}
+
+// redisBucket is the state of a bucket read back from its Redis hash
+type redisBucket struct {
+	tokens     int64
+	maxTokens  int64
+	refillRate time.Duration
+	lastRefill time.Time
+
+	// strategy is nil while the bucket refills linearly
+	strategy RefillStrategy
+}
+
+// parseRedisBucket parses the values of redisBucketFields as returned by HMGET
+// Missing fields take the defaults, a bucket without a token count is full and one never refilled refills from now
+// Fields that are present but malformed fail rather than being read as zero
+func parseRedisBucket(values []interface{}, defaultLimit int64, defaultRefill time.Duration, now time.Time) (redisBucket, error) {
+	bucket := redisBucket{maxTokens: defaultLimit, refillRate: defaultRefill, lastRefill: now}
+	if len(values) != len(redisBucketFields) {
+		return redisBucket{}, errors.Wrapf(errors.ErrBackendUnavailable, "expected %d bucket fields, got %d", len(redisBucketFields), len(values))
+	}
+
+	fields := make([]string, len(values))
+	present := make([]bool, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			fields[i], present[i] = v, true
+		default:
+			return redisBucket{}, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket field %s: unexpected %T", redisBucketFields[i], value)
+		}
+	}
+
+	if present[1] {
+		limit, ok := parseRedisInt(fields[1])
+		if !ok || limit <= 0 {
+			return redisBucket{}, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket field max_tokens: %q", fields[1])
+		}
+		bucket.maxTokens = limit
+	}
+
+	bucket.tokens = bucket.maxTokens
+	if present[0] {
+		tokens, ok := parseRedisInt(fields[0])
+		if !ok {
+			return redisBucket{}, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket field tokens: %q", fields[0])
+		}
+		bucket.tokens = tokens
+	}
+
+	if present[2] {
+		ms, ok := parseRedisInt(fields[2])
+		if !ok || ms <= 0 || ms > math.MaxInt64/int64(time.Millisecond) {
+			return redisBucket{}, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket field refill_rate: %q", fields[2])
+		}
+		bucket.refillRate = time.Duration(ms) * time.Millisecond
+	}
+
+	if present[3] {
+		lastRefill, ok := parseRedisTime(fields[3])
+		if !ok {
+			return redisBucket{}, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket field last_refill: %q", fields[3])
+		}
+		bucket.lastRefill = lastRefill
+	}
+
+	if present[4] {
+		strategy, err := ParseRefillStrategy(fields[4])
+		if err != nil {
+			return redisBucket{}, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket field refill_strategy: %q", fields[4])
+		}
+		if !isLinear(strategy) {
+			bucket.strategy = strategy
+		}
+	}
+
+	return bucket, nil
+}
+
+// parseRedisInt parses an integer as written by Lua, which formats numbers of 15 digits and more with an exponent
+func parseRedisInt(value string) (int64, bool) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, true
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+
+	return int64(f), true
+}
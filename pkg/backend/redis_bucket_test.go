@@ -0,0 +1,204 @@
+package backend
+
+import (
+	"context"
+	stderrors "errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestParseRedisBucket(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		values   []interface{}
+		expected redisBucket
+		wantErr  bool
+	}{
+		{
+			name:     "missing bucket is full",
+			values:   []interface{}{nil, nil, nil, nil, nil},
+			expected: redisBucket{tokens: 100, maxTokens: 100, refillRate: time.Second, lastRefill: now},
+		},
+		{
+			name:     "stored bucket",
+			values:   []interface{}{"-3", "10", "250", "1704067200125", nil},
+			expected: redisBucket{tokens: -3, maxTokens: 10, refillRate: 250 * time.Millisecond, lastRefill: now.Add(125 * time.Millisecond)},
+		},
+		{
+			name:     "limit written by Lua with an exponent",
+			values:   []interface{}{"1e+15", "2e+15", "1000", nil, nil},
+			expected: redisBucket{tokens: 1e15, maxTokens: 2e15, refillRate: time.Second, lastRefill: now},
+		},
+		{
+			name:     "stepped refill strategy",
+			values:   []interface{}{"2", "10", "1000", nil, "stepped:5"},
+			expected: redisBucket{tokens: 2, maxTokens: 10, refillRate: time.Second, lastRefill: now, strategy: SteppedRefill{Tokens: 5}},
+		},
+		{name: "tokens not a number", values: []interface{}{"many", "10", "1000", nil, nil}, wantErr: true},
+		{name: "fractional tokens", values: []interface{}{"1.5", "10", "1000", nil, nil}, wantErr: true},
+		{name: "tokens out of range", values: []interface{}{"1e300", "10", "1000", nil, nil}, wantErr: true},
+		{name: "zero limit", values: []interface{}{"1", "0", "1000", nil, nil}, wantErr: true},
+		{name: "negative refill", values: []interface{}{"1", "10", "-5", nil, nil}, wantErr: true},
+		{name: "refill as a duration", values: []interface{}{"1", "10", "1s", nil, nil}, wantErr: true},
+		{name: "refill overflowing a duration", values: []interface{}{"1", "10", "9223372036854775807", nil, nil}, wantErr: true},
+		{name: "last refill not a time", values: []interface{}{"1", "10", "1000", "yesterday", nil}, wantErr: true},
+		{name: "not a string", values: []interface{}{int64(1), "10", "1000", nil, nil}, wantErr: true},
+		{name: "unknown refill strategy", values: []interface{}{"1", "10", "1000", nil, "sometimes"}, wantErr: true},
+		{name: "missing fields", values: []interface{}{"1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket, err := parseRedisBucket(tt.values, 100, time.Second, now)
+			if tt.wantErr {
+				if !stderrors.Is(err, errors.ErrBackendUnavailable) {
+					t.Errorf("expected backend unavailable error, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if bucket.tokens != tt.expected.tokens || bucket.maxTokens != tt.expected.maxTokens || bucket.refillRate != tt.expected.refillRate {
+				t.Errorf("expected %d of %d tokens every %v, got %d of %d every %v", tt.expected.tokens, tt.expected.maxTokens, tt.expected.refillRate, bucket.tokens, bucket.maxTokens, bucket.refillRate)
+			}
+			if bucket.strategy != tt.expected.strategy {
+				t.Errorf("expected strategy %v, got %v", tt.expected.strategy, bucket.strategy)
+			}
+			if !bucket.lastRefill.Equal(tt.expected.lastRefill) {
+				t.Errorf("expected last refill %v, got %v", tt.expected.lastRefill, bucket.lastRefill)
+			}
+		})
+	}
+}
+
+func TestRedisBackendGetInfoMalformedBucket(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions())
+
+	server.HSet(backend.keys.bucket("test_key"), "tokens", "7", "max_tokens", "ten")
+	if _, err := backend.GetInfo(ctx, "test_key"); !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected backend unavailable error, got %v", err)
+	}
+}
+
+func FuzzParseRedisBucket(f *testing.F) {
+	f.Add("5", "10", "1000", "1704067200125", uint8(0b1111))
+	f.Add("-3", "1e+15", "1", "2024-01-01T00:00:00Z", uint8(0b1011))
+	f.Add("", "0", "-1", "0", uint8(0b0110))
+	f.Add("NaN", "Inf", "1.5", "1e400", uint8(0b1111))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.Fuzz(func(t *testing.T, tokens, maxTokens, refillRate, lastRefill string, present uint8) {
+		values := make([]interface{}, len(redisBucketFields))
+		for i, field := range []string{tokens, maxTokens, refillRate, lastRefill} {
+			if present&(1<<i) != 0 {
+				values[i] = field
+			}
+		}
+
+		bucket, err := parseRedisBucket(values, 100, time.Second, now)
+		if err != nil {
+			if !stderrors.Is(err, errors.ErrBackendUnavailable) {
+				t.Fatalf("expected backend unavailable error, got %v", err)
+			}
+			return
+		}
+
+		if bucket.maxTokens <= 0 || bucket.refillRate <= 0 {
+			t.Fatalf("expected a positive limit and refill rate, got %+v", bucket)
+		}
+
+		// Every field read is the number stored, never a prefix or a zero value
+		for i, got := range []int64{bucket.tokens, bucket.maxTokens, bucket.refillRate.Milliseconds()} {
+			if values[i] == nil {
+				continue
+			}
+			stored, err := strconv.ParseFloat(values[i].(string), 64)
+			if err != nil || stored != float64(got) {
+				t.Fatalf("field %s is %q but was read as %d", redisBucketFields[i], values[i], got)
+			}
+		}
+		if values[0] == nil && bucket.tokens != bucket.maxTokens {
+			t.Fatalf("expected a bucket without tokens to be full, got %+v", bucket)
+		}
+	})
+}
+
+// FuzzRedisTakeScript runs takes, limit changes and clock steps through the Lua scripts and checks the bucket after each
+// Each op byte picks an operation in its top two bits and its size in the others
+func FuzzRedisTakeScript(f *testing.F) {
+	f.Add(uint16(10), uint16(100), uint8(0), []byte{0x01, 0x05, 0x45, 0x03, 0x8a, 0x02})
+	f.Add(uint16(1), uint16(1), uint8(3), []byte{0x04, 0x04, 0x7f, 0xc2, 0x01})
+	f.Add(uint16(5000), uint16(60000), uint8(0), []byte{0x3f, 0x3f, 0xc0, 0x3f, 0x41})
+
+	server := miniredis.RunT(f)
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	f.Fuzz(func(t *testing.T, limit, refillMs uint16, debt uint8, ops []byte) {
+		if limit == 0 || refillMs == 0 {
+			return
+		}
+
+		server.FlushAll()
+		server.SetTime(start)
+
+		refill := time.Duration(refillMs) * time.Millisecond
+		options := DefaultOptions().WithLimit(int64(limit)).WithRefill(refill).WithMaxDebt(int64(debt))
+		b, err := NewRedisBackend("redis://"+server.Addr(), options)
+		if err != nil {
+			t.Fatalf("failed to create backend: %v", err)
+		}
+		defer b.Close(ctx)
+
+		var taken int64
+		var elapsed time.Duration
+		limitChanged := false
+
+		for _, op := range ops {
+			size := int64(op&0x3f) + 1
+			switch op >> 6 {
+			case 0, 1:
+				allowed, err := b.Take(ctx, "key", size)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if allowed {
+					taken += size
+				}
+			case 2:
+				elapsed += time.Duration(size) * refill / 4
+				server.SetTime(start.Add(elapsed))
+			case 3:
+				if err := b.SetLimit(ctx, "key", size, refill); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				limitChanged = true
+			}
+
+			info, err := b.GetInfo(ctx, "key")
+			if err != nil {
+				t.Fatalf("unexpected error reading back the bucket: %v", err)
+			}
+			if info.Tokens > info.MaxTokens || info.Tokens < -int64(debt) {
+				t.Fatalf("expected a balance between %d and %d, got %d", -int64(debt), info.MaxTokens, info.Tokens)
+			}
+			if info.RefillRate != refill {
+				t.Fatalf("expected refill rate %v, got %v", refill, info.RefillRate)
+			}
+		}
+
+		// Without limit changes no more than the limit, the debt and the refills can be taken
+		if budget := int64(limit) + int64(debt) + int64(elapsed/refill); !limitChanged && taken > budget {
+			t.Fatalf("expected at most %d tokens taken, got %d", budget, taken)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// burstCredits returns the credits left in a burst pool from the burst_spent and burst_at fields of its bucket,
+// as the take script would refill it. A bucket that never spent from its pool has a full one
+func (r *redisBackend) burstCredits(values []interface{}) (int64, error) {
+	spentValue, ok := values[0].(string)
+	if !ok {
+		return r.options.BurstPoolTokens, nil
+	}
+
+	spent, ok := parseRedisInt(spentValue)
+	if !ok || spent < 0 {
+		return 0, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket field burst_spent: %q", spentValue)
+	}
+
+	now := time.Now()
+	last := now
+	if atValue, ok := values[1].(string); ok {
+		if last, ok = parseRedisTime(atValue); !ok {
+			return 0, errors.Wrapf(errors.ErrBackendUnavailable, "malformed bucket field burst_at: %q", atValue)
+		}
+	}
+
+	spent, _ = burstRefilled(spent, last, now, time.Duration(refillMillis(r.options.BurstPoolRefill))*time.Millisecond)
+	return max(0, r.options.BurstPoolTokens-spent), nil
+}
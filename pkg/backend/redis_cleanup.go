@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// cleanupLeaderKey is the key, after the key prefix, holding the lease of the instance running the shared cleanup
+const cleanupLeaderKey = "cleanup:leader"
+
+// bucketTTL is how long an idle bucket with its own limit is kept, and the longest any bucket is kept
+const bucketTTL = 24 * time.Hour
+
+// bucketTTLRefills is how many times its time to full an idle bucket on the default limit is kept
+const bucketTTLRefills = 2
+
+// minBucketTTL is the shortest time an idle bucket is kept
+const minBucketTTL = time.Second
+
+// deleteIfUnchangedScript deletes a bucket only if it still is one and was not updated since it was inspected
+var deleteIfUnchangedScript = redis.NewScript(`
+	if redis.call('HEXISTS', KEYS[1], 'max_tokens') == 1 and redis.call('HGET', KEYS[1], 'updated_at') == ARGV[1] then
+		return redis.call('DEL', KEYS[1])
+	end
+	return 0
+`)
+
+// deleteOrphanedBlockScript deletes a block marker only if it holds the value blocks are written with and has no expiry
+var deleteOrphanedBlockScript = redis.NewScript(`
+	if redis.call('GET', KEYS[1]) == '1' and redis.call('PTTL', KEYS[1]) == -1 then
+		return redis.call('DEL', KEYS[1])
+	end
+	return 0
+`)
+
+// newInstanceID returns a random identifier for this backend instance
+func newInstanceID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// cleanupRoutine runs the shared cleanup every interval until the backend is closed
+func (r *redisBackend) cleanupRoutine(interval time.Duration) {
+	defer close(r.cleanupDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			r.sharedCleanup(ctx, interval)
+			cancel()
+		case <-r.stopCleanup:
+			return
+		}
+	}
+}
+
+// sharedCleanup prunes stale keys if this instance wins the cleanup lease for the interval
+// The lease is left to expire so at most one instance cleans up per interval
+func (r *redisBackend) sharedCleanup(ctx context.Context, interval time.Duration) (bool, error) {
+	leader, err := r.client.SetNX(ctx, r.keys.prefix+cleanupLeaderKey, r.instanceID, interval).Result()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to acquire cleanup lease")
+	}
+	if !leader {
+		return false, nil
+	}
+
+	if err := r.pruneBuckets(ctx); err != nil {
+		return true, err
+	}
+
+	if err := r.pruneBlocks(ctx); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// pruneBuckets deletes buckets that lost their expiry and have been idle longer than bucketTTL
+// Buckets without an expiry that are still in use get one, so they are removed once idle
+// Only hashes under the key prefix with the fields every bucket is written with are touched
+func (r *redisBackend) pruneBuckets(ctx context.Context) error {
+	now := time.Now()
+
+	return r.scanBuckets(ctx, "", func(keys []string) error {
+		pipe := r.client.Pipeline()
+		ttls := make([]*redis.DurationCmd, len(keys))
+		updates := make([]*redis.StringCmd, len(keys))
+		for i, key := range keys {
+			ttls[i] = pipe.PTTL(ctx, key)
+			updates[i] = pipe.HGet(ctx, key, "updated_at")
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return errors.Wrap(err, "failed to inspect Redis buckets")
+		}
+
+		for i, key := range keys {
+			// Only keys without an expiry need attention, the rest expire on their own
+			if ttls[i].Val() != -1 {
+				continue
+			}
+
+			// Keys under the prefix without an update time were not written by a limiter, so they are left alone
+			updatedAt := updates[i].Val()
+			updated, ok := parseRedisTime(updatedAt)
+			if _, bucket := r.keys.name(key); !ok || !bucket {
+				continue
+			}
+
+			age := now.Sub(updated)
+
+			if age >= bucketTTL {
+				if err := deleteIfUnchangedScript.Run(ctx, r.client, []string{key}, updatedAt).Err(); err != nil && err != redis.Nil {
+					return errors.Wrap(err, "failed to delete stale bucket")
+				}
+				continue
+			}
+
+			if err := r.client.Expire(ctx, key, bucketTTL-age).Err(); err != nil {
+				return errors.Wrap(err, "failed to set bucket expiration")
+			}
+		}
+
+		return nil
+	})
+}
+
+// pruneBlocks deletes block markers that have no expiry, blocks are always written with one
+// Only markers of buckets under the key prefix holding the value blocks are written with are deleted
+func (r *redisBackend) pruneBlocks(ctx context.Context) error {
+	var cursor uint64
+	for {
+		keys, next, err := r.client.ScanType(ctx, cursor, r.keys.block(r.keys.pattern("*")), 1000, "string").Result()
+		if err != nil {
+			return errors.Wrap(err, "failed to scan Redis block keys")
+		}
+
+		for _, key := range keys {
+			if _, ok := r.keys.name(strings.TrimSuffix(key, r.keys.block(""))); !ok {
+				continue
+			}
+			if err := deleteOrphanedBlockScript.Run(ctx, r.client, []string{key}).Err(); err != nil && err != redis.Nil {
+				return errors.Wrap(err, "failed to delete orphaned block")
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// parseRedisTime parses a time field written as Unix milliseconds, or as Unix seconds or RFC 3339 by older versions
+func parseRedisTime(value string) (time.Time, bool) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Unix seconds stay below 1e11 until the year 5138
+		if n >= 1e11 {
+			return time.UnixMilli(n), true
+		}
+		return time.Unix(n, 0), true
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+
+	return time.Time{}, false
+}
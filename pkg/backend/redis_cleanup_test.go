@@ -0,0 +1,131 @@
+package backend
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedisBackend starts an in-process Redis server and connects a backend to it
+func newTestRedisBackend(t *testing.T, options *Options) (*redisBackend, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	backend, err := NewRedisBackend("redis://"+server.Addr(), options)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close(context.Background()) })
+
+	return backend.(*redisBackend), server
+}
+
+func TestRedisBackendSharedCleanup(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithKeyPrefix("ratelimiter:"))
+
+	stale := strconv.FormatInt(time.Now().Add(-48*time.Hour).Unix(), 10)
+	fresh := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	server.HSet("ratelimiter:{stale}", "max_tokens", "10", "updated_at", stale)
+	server.HSet("ratelimiter:{fresh}", "max_tokens", "10", "updated_at", fresh)
+	server.HSet("ratelimiter:{expiring}", "max_tokens", "10", "updated_at", stale)
+	server.SetTTL("ratelimiter:{expiring}", time.Hour)
+	server.HSet("unrelated", "field", "value")
+	server.HSet("{outside}", "max_tokens", "10", "updated_at", stale)
+	server.HSet("ratelimiter:{shapeless}", "max_tokens", "10")
+	server.HSet("ratelimiter:{foreign}", "field", "value", "updated_at", stale)
+	server.Set("{outside}:rl:blocked", "1")
+	server.Set("ratelimiter:{flag}:rl:blocked", "on")
+	server.Set("ratelimiter:{orphan}:rl:blocked", "1")
+	server.Set("ratelimiter:{active}:rl:blocked", "1")
+	server.SetTTL("ratelimiter:{active}:rl:blocked", time.Hour)
+
+	leader, err := backend.sharedCleanup(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !leader {
+		t.Fatal("expected first instance to win the cleanup lease")
+	}
+
+	tests := []struct {
+		key    string
+		exists bool
+	}{
+		{key: "ratelimiter:{stale}", exists: false},
+		{key: "ratelimiter:{fresh}", exists: true},
+		{key: "ratelimiter:{expiring}", exists: true},
+		{key: "unrelated", exists: true},
+		{key: "{outside}", exists: true},
+		{key: "ratelimiter:{shapeless}", exists: true},
+		{key: "ratelimiter:{foreign}", exists: true},
+		{key: "{outside}:rl:blocked", exists: true},
+		{key: "ratelimiter:{flag}:rl:blocked", exists: true},
+		{key: "ratelimiter:{orphan}:rl:blocked", exists: false},
+		{key: "ratelimiter:{active}:rl:blocked", exists: true},
+	}
+
+	for _, tt := range tests {
+		if exists := server.Exists(tt.key); exists != tt.exists {
+			t.Errorf("expected %s exists=%v, got %v", tt.key, tt.exists, exists)
+		}
+	}
+
+	if ttl := server.TTL("ratelimiter:{fresh}"); ttl <= 0 || ttl > bucketTTL {
+		t.Errorf("expected fresh bucket to get an expiry, got %v", ttl)
+	}
+
+	// Another instance cannot run the cleanup while the lease is held
+	other, err := NewRedisBackend("redis://"+server.Addr(), DefaultOptions().WithKeyPrefix("ratelimiter:"))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer other.Close(ctx)
+
+	leader, err = other.(*redisBackend).sharedCleanup(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if leader {
+		t.Error("expected second instance to lose the cleanup lease")
+	}
+}
+
+func TestParseRedisTime(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{name: "unix milliseconds", value: "1700000000000", ok: true},
+		{name: "unix seconds", value: "1700000000", ok: true},
+		{name: "RFC 3339", value: "2023-11-14T22:13:20Z", ok: true},
+		{name: "empty", value: "", ok: false},
+		{name: "garbage", value: "yesterday", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, ok := parseRedisTime(tt.value)
+			if ok != tt.ok {
+				t.Errorf("expected ok=%v, got %v", tt.ok, ok)
+			}
+			if ok && parsed.Unix() != 1700000000 {
+				t.Errorf("expected 1700000000, got %d", parsed.Unix())
+			}
+		})
+	}
+}
+
+func TestOptionsSharedCleanupRequiresKeyPrefix(t *testing.T) {
+	if err := DefaultOptions().WithSharedCleanup(true).Validate(); err == nil {
+		t.Error("expected shared cleanup without a key prefix to be rejected")
+	}
+	if err := DefaultOptions().WithSharedCleanup(true).WithKeyPrefix("ratelimiter:").Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// drainScript removes up to ARGV[1] tokens from the refilled balance of a bucket, leaving at least ARGV[2]
+// ARGV[3] and ARGV[4] are the default limit and refill rate of buckets without their own, ARGV[5] is the debt allowed
+// Grace, burst and block fields are left alone, and the TTL of the bucket is only ever extended
+var drainScript = redis.NewScript(redisNow + redisBucketTTL + redisRefill + `
+	local tokens_to_drain = tonumber(ARGV[1])
+	local floor = tonumber(ARGV[2])
+	local default_limit = tonumber(ARGV[3])
+	local default_refill_rate = tonumber(ARGV[4])
+	local max_debt = tonumber(ARGV[5])
+
+	local bucket_data = redis.call('HMGET', KEYS[1], 'tokens', 'max_tokens', 'refill_rate', 'last_refill', 'refill_strategy')
+	local max_tokens = tonumber(bucket_data[2]) or default_limit
+	local refill_rate = tonumber(bucket_data[3]) or default_refill_rate
+	local current_tokens = tonumber(bucket_data[1]) or max_tokens
+	local last_refill = tonumber(bucket_data[4]) or current_time
+
+	current_tokens, last_refill = refill(current_tokens, max_tokens, refill_rate, last_refill, bucket_data[5])
+
+	local drained = math.min(tokens_to_drain, current_tokens - floor)
+	if drained <= 0 then
+		return 0
+	end
+
+	redis.call('HMSET', KEYS[1],
+		'tokens', current_tokens - drained,
+		'max_tokens', max_tokens,
+		'refill_rate', refill_rate,
+		'last_refill', last_refill,
+		'updated_at', current_time
+	)
+
+	-- A bucket kept for its grace period or burst pool keeps its longer TTL, one without expiry keeps none
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl ~= -1 then
+		redis.call('PEXPIRE', KEYS[1], math.max(ttl, bucket_ttl(max_tokens, refill_rate, max_debt, default_limit, default_refill_rate, bucket_data[5])))
+	end
+
+	return drained
+`)
+
+// Drain removes up to tokens from the balance of a key, leaving at least floor, without counting a request
+func (r *redisBackend) Drain(ctx context.Context, key string, tokens int64, floor int64) error {
+	if r.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	if err := validateDrain(tokens, floor); err != nil {
+		return err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	err := drainScript.Run(ctx, r.client, []string{r.keys.bucket(key)}, tokens, floor,
+		r.options.DefaultLimit, refillMillis(r.options.DefaultRefill), r.options.MaxDebt).Err()
+	if err != nil {
+		return errors.Wrap(r.timeoutError("drain", err), "failed to drain tokens in Redis")
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+package backend
+
+import (
+	"testing"
+)
+
+func TestRedisBackendDrain(t *testing.T) {
+	backend, _ := newTestRedisBackend(t, drainTestOptions())
+	testBackendDrain(t, backend)
+}
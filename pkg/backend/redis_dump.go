@@ -0,0 +1,304 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// restoreBatch is how many buckets Restore writes per script call
+const restoreBatch = 500
+
+// restoreScript writes the buckets of KEYS, replacing any state already stored
+// ARGV[1] to ARGV[3] are the default limit, the default refill rate and the debt allowed, which decide how long a bucket is kept
+// Each key then takes five arguments from ARGV[4] on: its tokens, limit, refill rate, last refill and refill strategy
+var restoreScript = redis.NewScript(redisNow + redisBucketTTL + `
+	local default_limit = tonumber(ARGV[1])
+	local default_refill_rate = tonumber(ARGV[2])
+	local max_debt = tonumber(ARGV[3])
+
+	for i, key in ipairs(KEYS) do
+		local base = 4 + (i - 1) * 5
+		local limit = tonumber(ARGV[base + 1])
+		local refill_rate = tonumber(ARGV[base + 2])
+		local strategy = ARGV[base + 4]
+
+		redis.call('DEL', key)
+		redis.call('HMSET', key,
+			'tokens', ARGV[base],
+			'max_tokens', limit,
+			'refill_rate', refill_rate,
+			'last_refill', ARGV[base + 3],
+			'updated_at', current_time)
+
+		if strategy == '' then
+			strategy = false
+		else
+			redis.call('HSET', key, 'refill_strategy', strategy)
+		end
+
+		redis.call('PEXPIRE', key, bucket_ttl(limit, refill_rate, max_debt, default_limit, default_refill_rate, strategy))
+	end
+
+	return #KEYS
+`)
+
+// Dump writes the buckets and active blocks of the keys matching a glob pattern to w, as the in-memory backend's snapshots
+// Keys are written in their stored form, so with key hashing enabled the dump is only usable with the same KeyHashSecret
+// Buckets are read batch by batch rather than at one instant, so one changing during the dump is written as last read
+func (r *redisBackend) Dump(ctx context.Context, w io.Writer, pattern string) (int, error) {
+	if r.closed.Load() {
+		return 0, errors.ErrBackendClosed
+	}
+
+	snap := snapshot{
+		Version: snapshotVersion,
+		SavedAt: time.Now(),
+		Blocks:  make(map[string]time.Time),
+	}
+
+	err := r.scanBuckets(ctx, pattern, func(keys []string) error {
+		buckets, err := r.dumpBuckets(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		snap.Buckets = append(snap.Buckets, buckets...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.dumpBlocks(ctx, pattern, snap.Blocks); err != nil {
+		return 0, err
+	}
+
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		return 0, errors.Wrap(err, "failed to write dump")
+	}
+
+	return len(snap.Buckets), nil
+}
+
+// dumpBuckets reads the state of a batch of bucket keys, skipping those deleted since they were scanned
+func (r *redisBackend) dumpBuckets(ctx context.Context, keys []string) ([]bucketState, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HMGet(ctx, key, redisBucketFields...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.Wrap(r.timeoutError("dump", err), "failed to read buckets from Redis")
+	}
+
+	now := time.Now()
+	buckets := make([]bucketState, 0, len(keys))
+	for i, cmd := range cmds {
+		values := cmd.Val()
+		if values[1] == nil {
+			continue
+		}
+
+		bucket, err := parseRedisBucket(values, r.options.DefaultLimit, r.options.DefaultRefill, now)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse bucket %q", keys[i])
+		}
+
+		name, ok := r.keys.name(keys[i])
+		if !ok {
+			continue
+		}
+
+		strategy, _ := refillStrategyName(bucket.strategy)
+		buckets = append(buckets, bucketState{
+			Key:        name,
+			Tokens:     bucket.tokens,
+			MaxTokens:  bucket.maxTokens,
+			RefillRate: bucket.refillRate,
+			LastRefill: bucket.lastRefill,
+			Strategy:   strategy,
+		})
+	}
+
+	return buckets, nil
+}
+
+// dumpBlocks adds the blocks on keys matching the pattern to blocks, as the time each one expires
+func (r *redisBackend) dumpBlocks(ctx context.Context, pattern string, blocks map[string]time.Time) error {
+	var cursor uint64
+	for {
+		// Check if context is cancelled
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "context cancelled")
+		default:
+		}
+
+		next, err := r.scanBlocks(ctx, cursor, pattern, blocks)
+		if err != nil {
+			return err
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// scanBlocks scans one batch of block markers from the cursor and adds those with an expiry to blocks
+func (r *redisBackend) scanBlocks(ctx context.Context, cursor uint64, pattern string, blocks map[string]time.Time) (uint64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	keys, next, err := r.client.ScanType(ctx, cursor, r.keys.block(r.keys.pattern(pattern)), 1000, "string").Result()
+	if err != nil {
+		return 0, errors.Wrap(r.timeoutError("dump", err), "failed to scan Redis block keys")
+	}
+
+	if len(keys) == 0 {
+		return next, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.PTTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, errors.Wrap(r.timeoutError("dump", err), "failed to read Redis block keys")
+	}
+
+	// Markers without an expiry are orphans the shared cleanup deletes, they are not carried over
+	now := time.Now()
+	for i, cmd := range cmds {
+		name, ok := r.keys.name(strings.TrimSuffix(keys[i], r.keys.block("")))
+		if ttl := cmd.Val(); ttl > 0 && ok {
+			blocks[name] = now.Add(ttl)
+		}
+	}
+
+	return next, nil
+}
+
+// Restore writes the buckets and blocks of a dump to Redis, replacing the state of keys already present
+// Buckets refill from their last refill as they would have without the move, so the time between dump and restore is not lost
+// Buckets failing validation and blocks already expired are skipped, and the number of buckets restored is returned
+func (r *redisBackend) Restore(ctx context.Context, reader io.Reader) (int, error) {
+	if r.closed.Load() {
+		return 0, errors.ErrBackendClosed
+	}
+
+	var snap snapshot
+	if err := json.NewDecoder(reader).Decode(&snap); err != nil {
+		return 0, errors.Wrap(err, "failed to decode dump")
+	}
+
+	if snap.Version != snapshotVersion {
+		return 0, errors.Wrapf(errors.ErrBackendUnavailable, "unsupported dump version %d", snap.Version)
+	}
+
+	var keys []string
+	var args []interface{}
+	restored := 0
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+
+		n, err := r.restoreBuckets(ctx, keys, args)
+		if err != nil {
+			return err
+		}
+
+		restored += n
+		keys, args = keys[:0], args[:0]
+		return nil
+	}
+
+	for _, state := range snap.Buckets {
+		if validateKey(state.Key) != nil || state.MaxTokens <= 0 || state.RefillRate <= 0 {
+			continue
+		}
+
+		strategy, err := ParseRefillStrategy(state.Strategy)
+		if err != nil {
+			continue
+		}
+		name, _ := refillStrategyName(strategy)
+
+		keys = append(keys, r.keys.stored(state.Key))
+		args = append(args, min(state.Tokens, state.MaxTokens), state.MaxTokens, refillMillis(state.RefillRate), state.LastRefill.UnixMilli(), name)
+
+		if len(keys) == restoreBatch {
+			if err := flush(); err != nil {
+				return restored, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return restored, err
+	}
+
+	if err := r.restoreBlocks(ctx, snap.Blocks); err != nil {
+		return restored, err
+	}
+
+	return restored, nil
+}
+
+// restoreBuckets writes one batch of buckets, args holding the five arguments restoreScript takes per key
+func (r *redisBackend) restoreBuckets(ctx context.Context, keys []string, args []interface{}) (int, error) {
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return 0, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	argv := append([]interface{}{r.options.DefaultLimit, refillMillis(r.options.DefaultRefill), r.options.MaxDebt}, args...)
+	n, err := restoreScript.Run(ctx, r.client, keys, argv...).Int()
+	if err != nil {
+		return 0, errors.Wrap(r.timeoutError("restore", err), "failed to restore buckets to Redis")
+	}
+
+	return n, nil
+}
+
+// restoreBlocks sets the blocks of a dump that have not expired yet
+func (r *redisBackend) restoreBlocks(ctx context.Context, blocks map[string]time.Time) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	pipe := r.client.Pipeline()
+	now := time.Now()
+	for key, until := range blocks {
+		if validateKey(key) != nil || !until.After(now) {
+			continue
+		}
+		pipe.Set(ctx, r.keys.block(r.keys.stored(key)), 1, until.Sub(now))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return errors.Wrap(r.timeoutError("restore", err), "failed to restore blocks to Redis")
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisBackendDumpRestore(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithLimit(10).WithRefill(time.Hour)
+	source, sourceServer := newTestRedisBackend(t, options)
+
+	source.Take(ctx, "app:user:1", 4)
+	source.SetLimit(ctx, "app:user:2", 50, time.Minute)
+	source.Take(ctx, "app:user:2", 20)
+	source.SetRefillStrategy(ctx, "app:user:2", ExponentialRefill{})
+	source.Block(ctx, "app:user:3", time.Hour)
+	source.Take(ctx, "other:1", 1)
+	sourceServer.HSet("app:unrelated", "field", "value")
+
+	var dump bytes.Buffer
+	n, err := source.Dump(ctx, &dump, "app:*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 buckets dumped, got %d", n)
+	}
+	if strings.Contains(dump.String(), "other:1") {
+		t.Error("expected keys outside the pattern to be left out")
+	}
+
+	target, targetServer := newTestRedisBackend(t, options)
+	target.Take(ctx, "app:user:1", 1)
+
+	n, err = target.Restore(ctx, &dump)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 buckets restored, got %d", n)
+	}
+
+	tests := []struct {
+		key       string
+		tokens    int64
+		maxTokens int64
+		strategy  RefillStrategy
+	}{
+		{key: "app:user:1", tokens: 6, maxTokens: 10},
+		{key: "app:user:2", tokens: 30, maxTokens: 50, strategy: ExponentialRefill{}},
+	}
+
+	for _, tt := range tests {
+		info, err := target.GetInfo(ctx, tt.key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Tokens != tt.tokens || info.MaxTokens != tt.maxTokens {
+			t.Errorf("expected %s to have %d of %d tokens, got %d of %d", tt.key, tt.tokens, tt.maxTokens, info.Tokens, info.MaxTokens)
+		}
+		if info.Strategy != tt.strategy {
+			t.Errorf("expected %s to refill with %v, got %v", tt.key, tt.strategy, info.Strategy)
+		}
+		if targetServer.TTL(target.keys.bucket(tt.key)) <= 0 {
+			t.Errorf("expected %s to expire", tt.key)
+		}
+	}
+
+	allowed, err := target.Take(ctx, "app:user:3", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected the restored block to deny takes")
+	}
+
+	if targetServer.Exists("other:1") {
+		t.Error("expected keys outside the pattern not to be restored")
+	}
+}
+
+func TestRedisBackendRestoreInvalid(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions())
+
+	tests := []struct {
+		name  string
+		dump  string
+		fails bool
+	}{
+		{name: "malformed", dump: "{", fails: true},
+		{name: "unknown version", dump: `{"version": 99}`, fails: true},
+		{name: "invalid bucket", dump: `{"version": 1, "buckets": [{"key": "bad", "max_tokens": 0, "refill_rate": 1000000000}]}`},
+		{name: "expired block", dump: `{"version": 1, "blocks": {"expired": "2000-01-01T00:00:00Z"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := backend.Restore(ctx, strings.NewReader(tt.dump))
+			if tt.fails != (err != nil) {
+				t.Errorf("expected failure %v, got %v", tt.fails, err)
+			}
+			if n != 0 {
+				t.Errorf("expected nothing restored, got %d", n)
+			}
+		})
+	}
+
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("expected no keys written, got %v", keys)
+	}
+}
+
+func TestRedisBackendKeyPrefix(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	newBackend := func(prefix string) *redisBackend {
+		b, err := NewRedisBackend("redis://"+server.Addr(), DefaultOptions().WithKeyPrefix(prefix))
+		if err != nil {
+			t.Fatalf("failed to create backend: %v", err)
+		}
+		t.Cleanup(func() { b.Close(ctx) })
+		return b.(*redisBackend)
+	}
+
+	// The glob star in the first prefix must not match the second one
+	first := newBackend("a*:")
+	second := newBackend("ab:")
+
+	first.Take(ctx, "mine", 1)
+	second.Take(ctx, "theirs", 1)
+	server.HSet("{foreign}", "tokens", "1", "max_tokens", "10", "refill_rate", "1000", "last_refill", "0")
+
+	keys, err := first.Keys(ctx, "*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "mine" {
+		t.Errorf("expected only the keys under the prefix, got %v", keys)
+	}
+
+	var dump bytes.Buffer
+	if n, err := first.Dump(ctx, &dump, ""); err != nil || n != 1 {
+		t.Fatalf("expected 1 bucket dumped, got %d, %v", n, err)
+	}
+
+	target := newBackend("c:")
+	if n, err := target.Restore(ctx, &dump); err != nil || n != 1 {
+		t.Fatalf("expected 1 bucket restored, got %d, %v", n, err)
+	}
+	if !server.Exists("c:{mine}") {
+		t.Errorf("expected the bucket to be restored under the prefix of the target, got %v", server.Keys())
+	}
+	if server.Exists("c:{theirs}") || server.Exists("c:{foreign}") {
+		t.Errorf("expected keys outside the prefix not to be restored, got %v", server.Keys())
+	}
+}
+
+func TestOptionsKeyPrefixValidation(t *testing.T) {
+	if err := DefaultOptions().WithKeyPrefix("tenant{1}:").Validate(); err == nil {
+		t.Error("expected a prefix with braces to be rejected")
+	}
+	if err := DefaultOptions().WithKeyPrefix("").Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
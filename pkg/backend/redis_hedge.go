@@ -0,0 +1,98 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// hedgedResult is the answer of one read sent by hedgedRead
+type hedgedResult struct {
+	info *TokenInfo
+	err  error
+}
+
+// newReplicaClients creates clients for the read replicas of the options
+// Replicas connect lazily, so one that is down only fails the reads hedged to it
+func newReplicaClients(options *Options) ([]*redis.Client, error) {
+	replicas := make([]*redis.Client, 0, len(options.ReadReplicas))
+	for _, replicaURL := range options.ReadReplicas {
+		opts, err := redisClientOptions(replicaURL, options)
+		if err != nil {
+			for _, replica := range replicas {
+				replica.Close()
+			}
+			return nil, errors.Wrap(err, "invalid read replica")
+		}
+
+		replicas = append(replicas, redis.NewClient(opts))
+	}
+
+	return replicas, nil
+}
+
+// hedgedRead runs read against the primary, and against the next replica too once the primary has not answered within
+// the hedge delay or has failed. The first successful answer wins and the other read is cancelled
+// Replicas lag the primary slightly, so a hedged answer may miss the latest Takes
+func (r *redisBackend) hedgedRead(ctx context.Context, read func(context.Context, *redis.Client) (*TokenInfo, error)) (*TokenInfo, error) {
+	if r.options.HedgeDelay <= 0 || len(r.replicas) == 0 {
+		return read(ctx, r.client)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so the read that loses never blocks once hedgedRead returned
+	results := make(chan hedgedResult, 2)
+	send := func(client *redis.Client) {
+		go func() {
+			info, err := read(ctx, client)
+			results <- hedgedResult{info: info, err: err}
+		}()
+	}
+
+	send(r.client)
+	pending := 1
+
+	timer := time.NewTimer(r.options.HedgeDelay)
+	defer timer.Stop()
+	hedge := timer.C
+
+	var firstErr error
+	for {
+		select {
+		case <-hedge:
+			hedge = nil
+			send(r.replica())
+			pending++
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				return result.info, nil
+			}
+
+			if firstErr == nil {
+				firstErr = result.err
+			}
+
+			// A failed primary is hedged without waiting out the delay
+			if hedge != nil {
+				hedge = nil
+				send(r.replica())
+				pending++
+				continue
+			}
+
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// replica returns the next read replica in turn
+func (r *redisBackend) replica() *redis.Client {
+	return r.replicas[int(r.nextReplica.Add(1)-1)%len(r.replicas)]
+}
@@ -0,0 +1,93 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisBackendHedgedReads(t *testing.T) {
+	ctx := context.Background()
+	primary := miniredis.RunT(t)
+	replica := miniredis.RunT(t)
+
+	// The replica lags behind the primary, so each answer tells where it came from
+	primary.HSet("key", "tokens", "7", "max_tokens", "10")
+	replica.HSet("key", "tokens", "4", "max_tokens", "10")
+
+	options := DefaultOptions().WithLimit(10).WithHedgedReads(200*time.Millisecond, "redis://"+replica.Addr())
+	b, err := NewRedisBackend("redis://"+primary.Addr(), options)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer b.Close(ctx)
+
+	tests := []struct {
+		name    string
+		disrupt func() func()
+		tokens  int64
+	}{
+		{
+			name:    "fast primary",
+			disrupt: func() func() { return func() {} },
+			tokens:  7,
+		},
+		{
+			name: "slow primary",
+			disrupt: func() func() {
+				primary.Lock()
+				return primary.Unlock
+			},
+			tokens: 4,
+		},
+		{
+			name: "failing primary",
+			disrupt: func() func() {
+				primary.SetError("ERR primary down")
+				return func() { primary.SetError("") }
+			},
+			tokens: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := tt.disrupt()
+			info, err := b.GetInfo(ctx, "key")
+			restore()
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Tokens != tt.tokens {
+				t.Errorf("expected %d tokens, got %d", tt.tokens, info.Tokens)
+			}
+		})
+	}
+
+	// With both failing the first error is returned
+	primary.SetError("ERR primary down")
+	replica.SetError("ERR replica down")
+	if _, err := b.GetInfo(ctx, "key"); err == nil {
+		t.Error("expected an error when every read fails")
+	}
+}
+
+func TestHedgedReadsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *Options
+	}{
+		{name: "negative delay", options: DefaultOptions().WithHedgedReads(-time.Millisecond, "redis://replica:6379")},
+		{name: "delay without replicas", options: DefaultOptions().WithHedgedReads(time.Millisecond)},
+		{name: "empty replica", options: DefaultOptions().WithHedgedReads(time.Millisecond, "")},
+	}
+
+	for _, tt := range tests {
+		if err := tt.options.Validate(); err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// takeOnceScript runs takeScript unless KEYS[3] records that the request was admitted within the idempotency window
+// KEYS and ARGV are those of takeScript, followed by the marker key and ARGV[12], the window in milliseconds
+// Only admitted takes are recorded, so a retry of a denied take is decided afresh
+var takeOnceScript = redis.NewScript(redisNow + redisBucketTTL + redisRefill + `
+	local function take()
+` + redisTake + `
+	end
+
+	if redis.call('EXISTS', KEYS[3]) == 1 then
+		return 2
+	end
+
+	local allowed = take()
+	if allowed == 1 then
+		redis.call('SET', KEYS[3], 1, 'PX', ARGV[12])
+	end
+
+	return allowed
+`)
+
+// TakeOnce takes tokens as Take does, admitting retries of an admitted take with the same request ID without taking again
+// The request ID is remembered next to the bucket on the Redis server, so retries landing on any instance are recognised
+func (r *redisBackend) TakeOnce(ctx context.Context, key string, requestID string, tokens int64) (bool, error) {
+	if r.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	if err := validateRequestID(requestID); err != nil {
+		return false, err
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	stored := r.keys.bucket(key)
+	keys := []string{stored, r.keys.block(stored), r.keys.requestID(stored, r.keys.hasher.hash(requestID))}
+	result, err := takeOnceScript.Run(ctx, r.client, keys, tokens, r.options.DefaultLimit, refillMillis(r.options.DefaultRefill), 0, r.options.MaxDebt,
+		r.options.GraceTokens, r.options.GracePeriod.Milliseconds(), r.options.DefaultLimit, refillMillis(r.options.DefaultRefill),
+		r.options.BurstPoolTokens, refillMillis(r.options.BurstPoolRefill), idempotencyWindow(r.options).Milliseconds()).Int()
+	if err != nil {
+		return false, errors.Wrap(r.timeoutError("take_once", err), "failed to execute Redis script")
+	}
+
+	// A retry consumed nothing, so it does not count towards usage again
+	if result == 2 {
+		return true, nil
+	}
+
+	r.recordUsage(result == 1, tokens)
+	r.recordActivity(ctx, result == 1, stored)
+	return result == 1, nil
+}
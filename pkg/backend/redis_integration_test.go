@@ -0,0 +1,280 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// redisTarget is a Redis server the integration suite runs against
+// connect returns a backend and a prefix that keeps the keys of one test apart from others on the server
+type redisTarget struct {
+	name    string
+	connect func(t *testing.T, options *Options) (Backend, string)
+}
+
+// redisTargets returns miniredis, plus the server at RATELIMITER_REDIS_URL when it is set
+func redisTargets() []redisTarget {
+	targets := []redisTarget{{
+		name: "miniredis",
+		connect: func(t *testing.T, options *Options) (Backend, string) {
+			backend, _ := newTestRedisBackend(t, options)
+			return backend, ""
+		},
+	}}
+
+	url := os.Getenv("RATELIMITER_REDIS_URL")
+	if url == "" {
+		return targets
+	}
+
+	return append(targets, redisTarget{
+		name: "redis",
+		connect: func(t *testing.T, options *Options) (Backend, string) {
+			t.Helper()
+
+			backend, err := NewRedisBackend(url, options)
+			if err != nil {
+				t.Fatalf("failed to connect to %s: %v", url, err)
+			}
+			t.Cleanup(func() { backend.Close(context.Background()) })
+
+			return backend, fmt.Sprintf("it:%d:", time.Now().UnixNano())
+		},
+	})
+}
+
+func TestRedisIntegrationRoundTrips(t *testing.T) {
+	ctx := context.Background()
+
+	for _, target := range redisTargets() {
+		t.Run(target.name, func(t *testing.T) {
+			backend, prefix := target.connect(t, DefaultOptions().WithLimit(10).WithRefill(time.Hour))
+			key := prefix + "round_trip"
+			t.Cleanup(func() { backend.Reset(context.Background(), key) })
+
+			if err := backend.SetLimit(ctx, key, 3, time.Hour); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			info, err := backend.GetInfo(ctx, key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Tokens != 3 || info.MaxTokens != 3 || info.RefillRate != time.Hour {
+				t.Errorf("expected 3 of 3 tokens every hour, got %d of %d every %v", info.Tokens, info.MaxTokens, info.RefillRate)
+			}
+
+			steps := []struct {
+				tokens  int64
+				allowed bool
+				left    int64
+			}{
+				{tokens: 2, allowed: true, left: 1},
+				{tokens: 2, allowed: false, left: 1},
+				{tokens: 1, allowed: true, left: 0},
+				{tokens: 1, allowed: false, left: 0},
+			}
+			for i, step := range steps {
+				allowed, err := backend.Take(ctx, key, step.tokens)
+				if err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i, err)
+				}
+				if allowed != step.allowed {
+					t.Errorf("step %d: expected allowed %v, got %v", i, step.allowed, allowed)
+				}
+
+				info, err := backend.GetInfo(ctx, key)
+				if err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i, err)
+				}
+				if info.Tokens != step.left {
+					t.Errorf("step %d: expected %d tokens left, got %d", i, step.left, info.Tokens)
+				}
+			}
+
+			// Reset drops the bucket along with its custom limit
+			if err := backend.Reset(ctx, key); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			info, err = backend.GetInfo(ctx, key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Tokens != 10 || info.MaxTokens != 10 {
+				t.Errorf("expected 10 of 10 tokens after reset, got %d of %d", info.Tokens, info.MaxTokens)
+			}
+		})
+	}
+}
+
+func TestRedisIntegrationTakeAll(t *testing.T) {
+	ctx := context.Background()
+
+	for _, target := range redisTargets() {
+		t.Run(target.name, func(t *testing.T) {
+			backend, prefix := target.connect(t, DefaultOptions().WithLimit(5).WithRefill(time.Hour))
+			keys := []string{prefix + "user", prefix + "tenant"}
+			t.Cleanup(func() {
+				for _, key := range keys {
+					backend.Reset(context.Background(), key)
+				}
+			})
+
+			if err := backend.SetLimit(ctx, keys[1], 1, time.Hour); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if allowed, err := backend.TakeAll(ctx, keys, 1); err != nil || !allowed {
+				t.Fatalf("expected first take to be allowed, got %v, %v", allowed, err)
+			}
+			if allowed, err := backend.TakeAll(ctx, keys, 1); err != nil || allowed {
+				t.Fatalf("expected take over an exhausted key to be denied, got %v, %v", allowed, err)
+			}
+
+			// The denied take left the other key untouched
+			info, err := backend.GetInfo(ctx, keys[0])
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Tokens != 4 {
+				t.Errorf("expected 4 tokens left, got %d", info.Tokens)
+			}
+		})
+	}
+}
+
+func TestRedisIntegrationBlock(t *testing.T) {
+	ctx := context.Background()
+
+	for _, target := range redisTargets() {
+		t.Run(target.name, func(t *testing.T) {
+			backend, prefix := target.connect(t, DefaultOptions())
+			key := prefix + "blocked"
+			t.Cleanup(func() {
+				backend.Unblock(context.Background(), key)
+				backend.Reset(context.Background(), key)
+			})
+
+			if err := backend.Block(ctx, key, time.Minute); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed, err := backend.Take(ctx, key, 1); err != nil || allowed {
+				t.Errorf("expected blocked key to be denied, got %v, %v", allowed, err)
+			}
+
+			if err := backend.Unblock(ctx, key); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed, err := backend.Take(ctx, key, 1); err != nil || !allowed {
+				t.Errorf("expected unblocked key to be allowed, got %v, %v", allowed, err)
+			}
+		})
+	}
+}
+
+func TestRedisIntegrationConcurrentTakes(t *testing.T) {
+	ctx := context.Background()
+	const limit, workers, takes = 50, 20, 10
+
+	for _, target := range redisTargets() {
+		t.Run(target.name, func(t *testing.T) {
+			backend, prefix := target.connect(t, DefaultOptions().WithLimit(limit).WithRefill(time.Hour))
+			key := prefix + "contended"
+			t.Cleanup(func() { backend.Reset(context.Background(), key) })
+
+			var admitted atomic.Int64
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < takes; i++ {
+						allowed, err := backend.Take(ctx, key, 1)
+						if err != nil {
+							t.Errorf("unexpected error: %v", err)
+							return
+						}
+						if allowed {
+							admitted.Add(1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			if admitted.Load() != limit {
+				t.Errorf("expected exactly %d takes admitted, got %d", limit, admitted.Load())
+			}
+		})
+	}
+}
+
+// TestRedisIntegrationServerDown only runs on miniredis, whose server the test can stop and restart
+func TestRedisIntegrationServerDown(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithLimit(5)
+	options.MaxRetries = -1
+	options.DialTimeout = 100 * time.Millisecond
+	backend, server := newTestRedisBackend(t, options)
+
+	if allowed, err := backend.Take(ctx, "key", 1); err != nil || !allowed {
+		t.Fatalf("expected take to be allowed, got %v, %v", allowed, err)
+	}
+
+	server.Close()
+
+	_, err := backend.Take(ctx, "key", 1)
+	if class := errors.Classify(err); class != errors.ClassConnection {
+		t.Errorf("expected a connection error while the server is down, got %q: %v", class, err)
+	}
+	if err := backend.HealthCheck(ctx); err == nil {
+		t.Error("expected health check to fail while the server is down")
+	}
+
+	// A restarted server keeps its data, and the backend reconnects on the next call
+	if err := server.Restart(); err != nil {
+		t.Fatalf("failed to restart server: %v", err)
+	}
+	if err := backend.HealthCheck(ctx); err != nil {
+		t.Fatalf("expected health check to pass after restart, got %v", err)
+	}
+
+	info, err := backend.GetInfo(ctx, "key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 4 {
+		t.Errorf("expected 4 tokens kept across the restart, got %d", info.Tokens)
+	}
+}
+
+func TestRedisIntegrationFailoverLosingWrites(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(2).WithRefill(time.Hour))
+
+	for i := 0; i < 2; i++ {
+		backend.Take(ctx, "key", 1)
+	}
+	if allowed, err := backend.Take(ctx, "key", 1); err != nil || allowed {
+		t.Fatalf("expected exhausted key to be denied, got %v, %v", allowed, err)
+	}
+
+	// A replica promoted before it received the writes has neither the buckets nor the cached scripts
+	server.FlushAll()
+	backend.client.ScriptFlush(ctx)
+
+	allowed, err := backend.Take(ctx, "key", 1)
+	if err != nil {
+		t.Fatalf("expected take to reload the script, got %v", err)
+	}
+	if !allowed {
+		t.Error("expected a lost bucket to start full again")
+	}
+}
@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// internalKeyMarker separates a bucket key from the suffix of the internal keys kept next to it
+const internalKeyMarker = ":rl:"
+
+// legacyKeyMarker separates a bucket key from the suffix of its internal keys in the legacy layout
+const legacyKeyMarker = ":"
+
+// redisKeys maps keys to the Redis keys holding their state
+// Without a prefix the legacy layout of earlier versions is kept: a bucket is stored under its key, hashed when
+// key hashing is enabled, and its block marker, leases, activity and request IDs append ":blocked", ":leases",
+// ":activity" and ":request:<id>" to it, so a key named like an internal key of another collides with it
+// With a prefix the key is wrapped in a hash tag after the prefix, and internal keys append a suffix starting
+// with ":rl:" to the tag, so they share its slot and never end in the closing brace every bucket key ends in
+type redisKeys struct {
+	prefix string
+	hasher *keyHasher
+}
+
+// legacy reports whether keys are stored in the legacy layout
+func (k redisKeys) legacy() bool {
+	return k.prefix == ""
+}
+
+// bucket returns the Redis key of the bucket of a key
+func (k redisKeys) bucket(key string) string {
+	return k.stored(k.hasher.hash(key))
+}
+
+// stored returns the Redis key of the bucket of a key already in its stored form, such as one read from a dump
+func (k redisKeys) stored(name string) string {
+	if k.legacy() {
+		return name
+	}
+	return k.prefix + "{" + name + "}"
+}
+
+// pattern returns the glob matching the Redis keys of the buckets whose stored form matches glob
+// Glob characters in the prefix are escaped, so only keys under the prefix itself match
+func (k redisKeys) pattern(glob string) string {
+	if glob == "" {
+		glob = "*"
+	}
+	if k.legacy() {
+		return glob
+	}
+	return globEscaper.Replace(k.prefix) + "{" + glob + "}"
+}
+
+// name returns the stored form of a key from the Redis key of its bucket, reporting whether it is one
+func (k redisKeys) name(bucket string) (string, bool) {
+	if k.legacy() {
+		return bucket, true
+	}
+
+	name, ok := strings.CutPrefix(bucket, k.prefix+"{")
+	if !ok {
+		return "", false
+	}
+
+	return strings.CutSuffix(name, "}")
+}
+
+// globEscaper escapes the characters Redis treats as special in a glob pattern
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// internal returns the Redis key of the internal key with the suffix kept next to a bucket
+func (k redisKeys) internal(bucket, suffix string) string {
+	if k.legacy() {
+		return bucket + legacyKeyMarker + suffix
+	}
+	return bucket + internalKeyMarker + suffix
+}
+
+// block returns the Redis key that holds the block marker for a bucket
+func (k redisKeys) block(bucket string) string {
+	return k.internal(bucket, "blocked")
+}
+
+// leases returns the Redis key of the sorted set holding the leases on a bucket
+func (k redisKeys) leases(bucket string) string {
+	return k.internal(bucket, "leases")
+}
+
+// activity returns the Redis key holding the activity of a bucket
+func (k redisKeys) activity(bucket string) string {
+	return k.internal(bucket, "activity")
+}
+
+// requestID returns the Redis key marking a request ID as admitted on a bucket
+// Outside the legacy layout the ID is hex encoded, so the marker cannot end in a brace whatever the ID holds
+func (k redisKeys) requestID(bucket string, requestID string) string {
+	if k.legacy() {
+		return k.internal(bucket, "request:"+requestID)
+	}
+	return k.internal(bucket, "request:"+hex.EncodeToString([]byte(requestID)))
+}
@@ -0,0 +1,163 @@
+package backend
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// acquireLeaseScript adds a lease to the set of a key unless ARGV[1] unexpired leases are already held
+// Scores are expiry times on the Redis clock, ARGV[2] is the lease TTL in milliseconds and ARGV[3] the lease ID
+var acquireLeaseScript = redis.NewScript(redisNow + `
+	local ttl = tonumber(ARGV[2])
+
+	redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', current_time)
+	if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[1]) then
+		return 0
+	end
+
+	redis.call('ZADD', KEYS[1], current_time + ttl, ARGV[3])
+
+	-- The set outlives its longest lease
+	if redis.call('PTTL', KEYS[1]) < ttl then
+		redis.call('PEXPIRE', KEYS[1], ttl)
+	end
+
+	return 1
+`)
+
+// renewLeaseScript extends an unexpired lease to ARGV[2] milliseconds from now, returning 0 once it has expired
+var renewLeaseScript = redis.NewScript(redisNow + `
+	local ttl = tonumber(ARGV[2])
+
+	local expires = redis.call('ZSCORE', KEYS[1], ARGV[1])
+	if not expires or tonumber(expires) <= current_time then
+		redis.call('ZREM', KEYS[1], ARGV[1])
+		return 0
+	end
+
+	redis.call('ZADD', KEYS[1], current_time + ttl, ARGV[1])
+	if redis.call('PTTL', KEYS[1]) < ttl then
+		redis.call('PEXPIRE', KEYS[1], ttl)
+	end
+
+	return 1
+`)
+
+// redisReadLeases is the Lua function returning the unexpired leases in a lease set as pairs of ID and milliseconds left
+const redisReadLeases = `
+	local function read_leases(key)
+		local leases = redis.call('ZRANGEBYSCORE', key, '(' .. current_time, '+inf', 'WITHSCORES')
+		for i = 2, #leases, 2 do
+			leases[i] = tonumber(leases[i]) - current_time
+		end
+		return leases
+	end
+`
+
+// AcquireLease takes one of limit concurrency slots of the key for ttl, returning an empty ID when none is free
+// Leases expire on the Redis clock, so a slot held by a crashed instance is reclaimed once its lease runs out
+func (r *redisBackend) AcquireLease(ctx context.Context, key string, limit int, ttl time.Duration) (string, error) {
+	if r.closed.Load() {
+		return "", errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	if limit <= 0 {
+		return "", errors.Wrap(errors.ErrInvalidTokens, "limit must be positive")
+	}
+
+	if ttl < time.Millisecond {
+		return "", errors.Wrap(errors.ErrInvalidTokens, "lease TTL must be at least a millisecond")
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// IDs start with the instance holding the lease
+	id := r.instanceID + "-" + newInstanceID()
+	acquired, err := acquireLeaseScript.Run(ctx, r.client, []string{r.keys.leases(r.keys.bucket(key))}, limit, ttl.Milliseconds(), id).Int()
+	if err != nil {
+		return "", errors.Wrap(r.timeoutError("acquire_lease", err), "failed to acquire lease in Redis")
+	}
+
+	if acquired == 0 {
+		return "", nil
+	}
+
+	return id, nil
+}
+
+// RenewLease extends a lease to ttl from now, returning false once it has expired or was released
+func (r *redisBackend) RenewLease(ctx context.Context, key string, id string, ttl time.Duration) (bool, error) {
+	if r.closed.Load() {
+		return false, errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	if ttl < time.Millisecond {
+		return false, errors.Wrap(errors.ErrInvalidTokens, "lease TTL must be at least a millisecond")
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	renewed, err := renewLeaseScript.Run(ctx, r.client, []string{r.keys.leases(r.keys.bucket(key))}, id, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, errors.Wrap(r.timeoutError("renew_lease", err), "failed to renew lease in Redis")
+	}
+
+	return renewed == 1, nil
+}
+
+// ReleaseLease frees a lease before it expires, releasing an expired or unknown lease has no effect
+func (r *redisBackend) ReleaseLease(ctx context.Context, key string, id string) error {
+	if r.closed.Load() {
+		return errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.client.ZRem(ctx, r.keys.leases(r.keys.bucket(key)), id).Err(); err != nil {
+		return errors.Wrap(r.timeoutError("release_lease", err), "failed to release lease in Redis")
+	}
+
+	return nil
+}
+
+// parseLeases parses the pairs of ID and milliseconds left returned by read_leases
+func parseLeases(result []interface{}, now time.Time) []Lease {
+	var leases []Lease
+	for i := 0; i+1 < len(result); i += 2 {
+		id, _ := result[i].(string)
+		left, _ := result[i+1].(int64)
+
+		leases = append(leases, Lease{
+			ID:        id,
+			Holder:    leaseHolder(id),
+			ExpiresAt: now.Add(time.Duration(left) * time.Millisecond),
+		})
+	}
+
+	return leases
+}
+
+// leaseHolder returns the holder encoded in a lease ID
+func leaseHolder(id string) string {
+	holder, _, _ := strings.Cut(id, "-")
+	return holder
+}
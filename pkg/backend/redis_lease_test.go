@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRedisBackendLeases(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions())
+
+	now := time.Now()
+	server.SetTime(now)
+
+	first, err := backend.AcquireLease(ctx, "db", 2, time.Minute)
+	if err != nil || first == "" {
+		t.Fatalf("expected a lease, got %q, %v", first, err)
+	}
+	second, err := backend.AcquireLease(ctx, "db", 2, time.Minute)
+	if err != nil || second == "" {
+		t.Fatalf("expected a lease, got %q, %v", second, err)
+	}
+
+	// Every slot is held
+	if id, err := backend.AcquireLease(ctx, "db", 2, time.Minute); err != nil || id != "" {
+		t.Errorf("expected no lease at the limit, got %q, %v", id, err)
+	}
+
+	info, err := backend.GetInfo(ctx, "db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(info.Leases) != 2 {
+		t.Fatalf("expected 2 leases, got %d", len(info.Leases))
+	}
+	for _, lease := range info.Leases {
+		if lease.Holder != backend.instanceID {
+			t.Errorf("expected holder %s, got %s", backend.instanceID, lease.Holder)
+		}
+		if left := time.Until(lease.ExpiresAt); left <= 0 || left > time.Minute {
+			t.Errorf("expected the lease to expire within a minute, got %v", left)
+		}
+	}
+
+	// Releasing frees a slot
+	if err := backend.ReleaseLease(ctx, "db", first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	third, err := backend.AcquireLease(ctx, "db", 2, time.Minute)
+	if err != nil || third == "" {
+		t.Fatalf("expected a lease after a release, got %q, %v", third, err)
+	}
+
+	// A renewed lease outlives one left to expire
+	server.SetTime(now.Add(40 * time.Second))
+	if renewed, err := backend.RenewLease(ctx, "db", second, time.Minute); err != nil || !renewed {
+		t.Errorf("expected the lease to be renewed, got %v, %v", renewed, err)
+	}
+
+	server.SetTime(now.Add(90 * time.Second))
+	if renewed, err := backend.RenewLease(ctx, "db", third, time.Minute); err != nil || renewed {
+		t.Errorf("expected the expired lease not to be renewed, got %v, %v", renewed, err)
+	}
+	if renewed, err := backend.RenewLease(ctx, "db", first, time.Minute); err != nil || renewed {
+		t.Errorf("expected the released lease not to be renewed, got %v, %v", renewed, err)
+	}
+
+	// The slot of the expired lease is reclaimed
+	if id, err := backend.AcquireLease(ctx, "db", 2, time.Minute); err != nil || id == "" {
+		t.Errorf("expected the expired slot to be reclaimed, got %q, %v", id, err)
+	}
+	if id, err := backend.AcquireLease(ctx, "db", 2, time.Minute); err != nil || id != "" {
+		t.Errorf("expected no lease at the limit, got %q, %v", id, err)
+	}
+
+	if _, err := backend.AcquireLease(ctx, "db", 0, time.Minute); err == nil {
+		t.Error("expected error for a zero limit, got nil")
+	}
+	if _, err := backend.AcquireLease(ctx, "db", 1, 0); err == nil {
+		t.Error("expected error for a zero TTL, got nil")
+	}
+}
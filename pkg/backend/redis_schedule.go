@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// scheduleScript consumes ARGV[1] tokens, reserving any the balance lacks by moving the last refill past now
+// ARGV[2] and ARGV[3] are the default limit and refill rate of buckets without their own, ARGV[4] is the debt allowed
+// It returns 1 and the milliseconds until the caller may proceed, 0 and the block TTL while the key is blocked,
+// or -1 and the most tokens the bucket can ever hold
+var scheduleScript = redis.NewScript(redisNow + redisBucketTTL + redisRefill + `
+	local tokens_to_consume = tonumber(ARGV[1])
+	local default_limit = tonumber(ARGV[2])
+	local default_refill_rate = tonumber(ARGV[3])
+	local max_debt = tonumber(ARGV[4])
+
+	-- Blocked keys reserve nothing
+	local block_ttl = redis.call('PTTL', KEYS[2])
+	if block_ttl > 0 then
+		return {0, block_ttl}
+	end
+
+	local bucket_data = redis.call('HMGET', KEYS[1], 'tokens', 'max_tokens', 'refill_rate', 'last_refill', 'refill_strategy')
+	local max_tokens = tonumber(bucket_data[2]) or default_limit
+	local refill_rate = tonumber(bucket_data[3]) or default_refill_rate
+	local current_tokens = tonumber(bucket_data[1]) or max_tokens
+	local last_refill = tonumber(bucket_data[4]) or current_time
+	local strategy = bucket_data[5]
+
+	current_tokens, last_refill = refill(current_tokens, max_tokens, refill_rate, last_refill, strategy)
+
+	local needed = tokens_to_consume - current_tokens - max_debt
+	if needed <= 0 then
+		current_tokens = current_tokens - tokens_to_consume
+	else
+		local ceiling = max_tokens + max_debt
+		if tokens_to_consume > ceiling then
+			return {-1, ceiling}
+		end
+
+		-- Count the intervals an empty bucket needs to regain the tokens, as RefillIntervals does
+		local intervals = needed
+		local step = string.match(strategy or '', '^stepped:(%d+)$')
+		if step then
+			intervals = math.ceil(needed / tonumber(step))
+		elseif strategy == 'reset' then
+			intervals = 1
+		elseif strategy == 'exponential' then
+			local left = ceiling
+			intervals = 0
+			while ceiling - left < needed do
+				left = left - math.max(1, math.floor(left / 2))
+				intervals = intervals + 1
+			end
+		end
+
+		current_tokens = -max_debt
+		last_refill = last_refill + intervals * refill_rate
+	end
+
+	redis.call('HMSET', KEYS[1],
+		'tokens', current_tokens,
+		'max_tokens', max_tokens,
+		'refill_rate', refill_rate,
+		'last_refill', last_refill,
+		'updated_at', current_time
+	)
+
+	-- Keep the bucket for as long after the reservation as after any take
+	local wait = math.max(0, last_refill - current_time)
+	redis.call('PEXPIRE', KEYS[1], bucket_ttl(max_tokens, refill_rate, max_debt, default_limit, default_refill_rate, strategy) + wait)
+
+	return {1, wait}
+`)
+
+// Schedule consumes tokens, reserving any the balance lacks from future refills, and returns when the caller may proceed
+// The wait is measured on the Redis clock and returned relative to the local one, so clock skew between instances does not matter
+func (r *redisBackend) Schedule(ctx context.Context, key string, tokens int64) (time.Time, error) {
+	if r.closed.Load() {
+		return time.Time{}, errors.ErrBackendClosed
+	}
+
+	if err := validateKey(key); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := validateTokens(tokens); err != nil {
+		return time.Time{}, err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return time.Time{}, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	stored := r.keys.bucket(key)
+	result, err := scheduleScript.Run(ctx, r.client, []string{stored, r.keys.block(stored)}, tokens,
+		r.options.DefaultLimit, refillMillis(r.options.DefaultRefill), r.options.MaxDebt).Int64Slice()
+	if err != nil {
+		return time.Time{}, errors.Wrap(r.timeoutError("schedule", err), "failed to schedule tokens in Redis")
+	}
+	if len(result) != 2 {
+		return time.Time{}, errors.Wrapf(errors.ErrBackendUnavailable, "unexpected schedule result %v", result)
+	}
+
+	now := time.Now()
+	switch result[0] {
+	case 0:
+		r.recordUsage(false, tokens)
+		r.recordActivity(ctx, false, stored)
+		return time.Time{}, &errors.RateLimitError{Message: "key is blocked", Key: key, Reset: now.Add(time.Duration(result[1]) * time.Millisecond)}
+	case -1:
+		return time.Time{}, errors.Wrapf(errors.ErrInvalidTokens, "cannot schedule %d tokens, more than key %s can ever hold", tokens, key)
+	}
+
+	r.recordUsage(true, tokens)
+	r.recordActivity(ctx, true, stored)
+	return now.Add(time.Duration(result[1]) * time.Millisecond), nil
+}
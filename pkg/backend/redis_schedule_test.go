@@ -0,0 +1,101 @@
+package backend
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestRedisBackendSchedule(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(5).WithRefill(100*time.Millisecond))
+
+	start := time.Now()
+	server.SetTime(start)
+
+	steps := []struct {
+		at     time.Duration
+		tokens int64
+		wait   time.Duration
+	}{
+		{at: 0, tokens: 3, wait: 0},
+		{at: 0, tokens: 4, wait: 200 * time.Millisecond},
+		{at: 50 * time.Millisecond, tokens: 1, wait: 250 * time.Millisecond},
+		{at: 500 * time.Millisecond, tokens: 2, wait: 0},
+	}
+
+	for i, step := range steps {
+		server.SetTime(start.Add(step.at))
+
+		called := time.Now()
+		at, err := backend.Schedule(ctx, "key", step.tokens)
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+
+		// The wait is measured on the Redis clock and added to the local one
+		if wait := at.Sub(called); wait < step.wait || wait > step.wait+100*time.Millisecond {
+			t.Errorf("step %d: expected to wait %v, got %v", i, step.wait, wait)
+		}
+	}
+
+	// Takes are denied while the refills are reserved
+	server.SetTime(start.Add(550 * time.Millisecond))
+	backend.Schedule(ctx, "key", 3)
+	if allowed, err := backend.Take(ctx, "key", 1); err != nil || allowed {
+		t.Errorf("expected take during a reservation to be denied, got %v, %v", allowed, err)
+	}
+
+	if _, err := backend.Schedule(ctx, "key", 6); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error, got %v", err)
+	}
+
+	backend.Block(ctx, "blocked", time.Minute)
+	_, err := backend.Schedule(ctx, "blocked", 1)
+	if !errors.IsRateLimitError(err) {
+		t.Errorf("expected rate limit error for a blocked key, got %v", err)
+	}
+	if info, _ := backend.GetInfo(ctx, "blocked"); info.Tokens != 5 {
+		t.Errorf("expected a blocked key to reserve nothing, got %d tokens left", info.Tokens)
+	}
+}
+
+func TestRedisBackendScheduleStrategy(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(8).WithRefill(100*time.Millisecond))
+	server.SetTime(time.Now())
+
+	tests := []struct {
+		strategy RefillStrategy
+		wait     time.Duration
+	}{
+		{strategy: SteppedRefill{Tokens: 4}, wait: 200 * time.Millisecond},
+		{strategy: ResetRefill{}, wait: 100 * time.Millisecond},
+		{strategy: ExponentialRefill{}, wait: 300 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			key := tt.strategy.String()
+			backend.SetRefillStrategy(ctx, key, tt.strategy)
+			backend.Schedule(ctx, key, 8)
+
+			// Matches the intervals RefillIntervals counts for 7 tokens
+			if expected := time.Duration(RefillIntervals(tt.strategy, 8, 7)) * 100 * time.Millisecond; expected != tt.wait {
+				t.Fatalf("expected %v from RefillIntervals, got %v", tt.wait, expected)
+			}
+
+			called := time.Now()
+			at, err := backend.Schedule(ctx, key, 7)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if wait := at.Sub(called); wait < tt.wait || wait > tt.wait+100*time.Millisecond {
+				t.Errorf("expected to wait %v, got %v", tt.wait, wait)
+			}
+		})
+	}
+}
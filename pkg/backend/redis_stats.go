@@ -0,0 +1,147 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// statsKey is the Redis hash holding the decision counts of every instance
+const statsKey = "ratelimiter:stats"
+
+// statsFlushInterval is how often an instance adds the decisions it counted to the shared counts
+const statsFlushInterval = time.Second
+
+// flushStatsScript adds decision counts to the shared counts, the tokens to the field of the current window
+// ARGV[1] to ARGV[3] are the allowed and denied decisions and tokens consumed, ARGV[4] the window in milliseconds
+var flushStatsScript = redis.NewScript(redisNow + `
+	local window = math.floor(current_time / tonumber(ARGV[4]))
+
+	redis.call('HINCRBY', KEYS[1], 'allowed', ARGV[1])
+	redis.call('HINCRBY', KEYS[1], 'denied', ARGV[2])
+	if tonumber(ARGV[3]) > 0 then
+		redis.call('HINCRBY', KEYS[1], 'tokens:' .. window, ARGV[3])
+	end
+
+	return 1
+`)
+
+// statsScript returns the shared allowed and denied counts and the tokens consumed over the sliding window
+// Fields of windows before the previous one are dropped, ARGV[1] is the window in milliseconds
+var statsScript = redis.NewScript(redisNow + `
+	local window_ms = tonumber(ARGV[1])
+	local window = math.floor(current_time / window_ms)
+	local current_field = 'tokens:' .. window
+	local previous_field = 'tokens:' .. (window - 1)
+
+	local data = redis.call('HMGET', KEYS[1], 'allowed', 'denied', current_field, previous_field)
+
+	for _, field in ipairs(redis.call('HKEYS', KEYS[1])) do
+		if string.sub(field, 1, 7) == 'tokens:' and field ~= current_field and field ~= previous_field then
+			redis.call('HDEL', KEYS[1], field)
+		end
+	end
+
+	-- The previous window counts for the part still inside the sliding window
+	local elapsed = (current_time % window_ms) / window_ms
+	local consumed = (tonumber(data[3]) or 0) + math.floor((tonumber(data[4]) or 0) * (1 - elapsed))
+
+	return {tonumber(data[1]) or 0, tonumber(data[2]) or 0, consumed}
+`)
+
+// pendingUsage holds the decisions an instance counted since it last flushed them to Redis
+// mu serializes flushes, so a reader flushing first also waits for counts another flush has in flight
+type pendingUsage struct {
+	allowed  atomic.Int64
+	denied   atomic.Int64
+	tokens   atomic.Int64
+	flushAt  atomic.Int64
+	flushing atomic.Bool
+	mu       sync.Mutex
+}
+
+// recordUsage counts a decision and flushes the counts in the background once statsFlushInterval has passed
+// Flushing is driven by takes, so an idle instance has nothing to flush and runs no goroutine
+func (r *redisBackend) recordUsage(allowed bool, tokens int64) {
+	if allowed {
+		r.usage.allowed.Add(1)
+		r.usage.tokens.Add(tokens)
+	} else {
+		r.usage.denied.Add(1)
+	}
+
+	now := time.Now().UnixNano()
+	if now < r.usage.flushAt.Load() || !r.usage.flushing.CompareAndSwap(false, true) {
+		return
+	}
+	r.usage.flushAt.Store(now + int64(statsFlushInterval))
+
+	go func() {
+		defer r.usage.flushing.Store(false)
+
+		ctx, cancel := r.withTimeout(context.Background())
+		defer cancel()
+		r.flushUsage(ctx)
+	}()
+}
+
+// flushUsage adds the pending counts to the shared counts, keeping them pending if Redis cannot be reached
+func (r *redisBackend) flushUsage(ctx context.Context) error {
+	r.usage.mu.Lock()
+	defer r.usage.mu.Unlock()
+
+	allowed := r.usage.allowed.Swap(0)
+	denied := r.usage.denied.Swap(0)
+	tokens := r.usage.tokens.Swap(0)
+	if allowed == 0 && denied == 0 {
+		return nil
+	}
+
+	if err := flushStatsScript.Run(ctx, r.client, []string{statsKey}, allowed, denied, tokens, statsWindow.Milliseconds()).Err(); err != nil {
+		r.usage.allowed.Add(allowed)
+		r.usage.denied.Add(denied)
+		r.usage.tokens.Add(tokens)
+		return err
+	}
+
+	return nil
+}
+
+// Stats returns the decisions of every instance sharing the Redis database
+// Each instance adds its counts about once a second, those of this instance are flushed first
+// Keys is the size of the Redis database, which also counts block markers and anything else stored in it
+// Evictions are left to Redis, whose INFO command reports them
+func (r *redisBackend) Stats(ctx context.Context) (Stats, error) {
+	if r.closed.Load() {
+		return Stats{}, errors.ErrBackendClosed
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if err := r.flushUsage(ctx); err != nil {
+		return Stats{}, errors.Wrap(r.timeoutError("stats", err), "failed to flush stats to Redis")
+	}
+
+	counts, err := statsScript.Run(ctx, r.client, []string{statsKey}, statsWindow.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Stats{}, errors.Wrap(r.timeoutError("stats", err), "failed to get stats from Redis")
+	}
+
+	keys, err := r.client.DBSize(ctx).Result()
+	if err != nil {
+		return Stats{}, errors.Wrap(r.timeoutError("stats", err), "failed to count keys in Redis")
+	}
+
+	return Stats{
+		Keys:           keys,
+		Allowed:        uint64(counts[0]),
+		Denied:         uint64(counts[1]),
+		TokensConsumed: counts[2],
+		Window:         statsWindow,
+	}, nil
+}
@@ -1,8 +1,18 @@
 package backend
 
 import (
+	"context"
+	stderrors "errors"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/go-redis/redis/v8"
 )
 
 func TestNewRedisBackendValidation(t *testing.T) {
@@ -88,7 +98,7 @@ func TestRedisBackendValidation(t *testing.T) {
 	// Test token validation
 	tokenTests := []struct {
 		name        string
-		tokens      int
+		tokens      int64
 		expectError bool
 	}{
 		{"valid tokens", 1, false},
@@ -110,19 +120,852 @@ func TestRedisBackendValidation(t *testing.T) {
 }
 
 func TestRedisBackendContextHandling(t *testing.T) {
-	// Test context cancellation handling
-	// These tests verify that the Redis backend properly handles context cancellation
-	// The actual implementation would need to be tested with a real Redis instance
-	// or by creating a mockable interface
-	t.Run("context cancellation", func(t *testing.T) {
-		t.Skip("requires Redis backend interface refactoring for proper testing")
-	})
+	backend, _ := newTestRedisBackend(t, DefaultOptions())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := backend.Take(ctx, "key", 1); !stderrors.Is(err, context.Canceled) {
+		t.Errorf("expected context cancelled error, got %v", err)
+	}
+	if _, err := backend.GetInfo(ctx, "key"); !stderrors.Is(err, context.Canceled) {
+		t.Errorf("expected context cancelled error, got %v", err)
+	}
 }
 
 func TestRedisBackendErrorTypes(t *testing.T) {
-	// Test that Redis backend returns appropriate error types
-	// This would require integration tests with a real Redis instance
-	t.Run("error types", func(t *testing.T) {
-		t.Skip("requires Redis integration tests")
-	})
+	ctx := context.Background()
+	backend, _ := newTestRedisBackend(t, DefaultOptions())
+
+	if _, err := backend.Take(ctx, "", 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error, got %v", err)
+	}
+	if _, err := backend.Take(ctx, "key", 0); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error, got %v", err)
+	}
+
+	backend.Close(ctx)
+	if _, err := backend.Take(ctx, "key", 1); !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected backend unavailable error, got %v", err)
+	}
+}
+
+func TestRedisBackendScriptCache(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newTestRedisBackend(t, DefaultOptions().WithLimit(5))
+
+	exists, err := backend.client.ScriptExists(ctx, takeScript.Hash(), takeAllScript.Hash()).Result()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, loaded := range exists {
+		if !loaded {
+			t.Errorf("expected script %d to be loaded on start", i)
+		}
+	}
+
+	// A Redis restart or SCRIPT FLUSH drops the cache, Take must reload the script
+	if err := backend.client.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	allowed, err := backend.Take(ctx, "test_key", 1)
+	if err != nil {
+		t.Fatalf("expected Take to fall back after NOSCRIPT, got %v", err)
+	}
+	if !allowed {
+		t.Error("expected Take to be allowed")
+	}
+
+	allowed, err = backend.TakeAll(ctx, []string{"test_key", "other_key"}, 1)
+	if err != nil {
+		t.Fatalf("expected TakeAll to fall back after NOSCRIPT, got %v", err)
+	}
+	if !allowed {
+		t.Error("expected TakeAll to be allowed")
+	}
+}
+
+func TestRedisBackendTakeWithLimit(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(100))
+
+	for i := 0; i < 3; i++ {
+		allowed, err := backend.TakeWithLimit(ctx, "test_key", 1, 3, time.Hour)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !allowed {
+			t.Errorf("expected take %d to be allowed", i)
+		}
+	}
+
+	allowed, err := backend.TakeWithLimit(ctx, "test_key", 1, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected take beyond the custom limit to be denied")
+	}
+
+	// A denied request still stores the custom limit
+	allowed, err = backend.TakeWithLimit(ctx, "other_key", 5, 2, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected take above the custom limit to be denied")
+	}
+	if got := server.HGet(backend.keys.bucket("other_key"), "max_tokens"); got != "2" {
+		t.Errorf("expected max_tokens 2, got %q", got)
+	}
+
+	if _, err := backend.TakeWithLimit(ctx, "test_key", 1, 0, time.Hour); err == nil {
+		t.Error("expected error for zero limit")
+	}
+
+	if _, err := backend.TakeWithLimit(ctx, "test_key", 1, 3, 0); err == nil {
+		t.Error("expected error for zero refill rate")
+	}
+}
+
+func TestRedisBackendClientOptions(t *testing.T) {
+	options := DefaultOptions().
+		WithPool(20, 4).
+		WithMaxRetries(-1).
+		WithRetryBackoff(time.Millisecond, 100*time.Millisecond).
+		WithDialTimeout(time.Second).
+		WithOperationTimeout(250 * time.Millisecond)
+
+	backend, _ := newTestRedisBackend(t, options)
+	clientOptions := backend.client.Options()
+
+	if clientOptions.PoolSize != 20 {
+		t.Errorf("expected pool size 20, got %d", clientOptions.PoolSize)
+	}
+
+	if clientOptions.MinIdleConns != 4 {
+		t.Errorf("expected 4 idle connections, got %d", clientOptions.MinIdleConns)
+	}
+
+	// The client stores disabled retries as 0
+	if clientOptions.MaxRetries != 0 {
+		t.Errorf("expected retries to be disabled, got %d", clientOptions.MaxRetries)
+	}
+
+	if clientOptions.MinRetryBackoff != time.Millisecond || clientOptions.MaxRetryBackoff != 100*time.Millisecond {
+		t.Errorf("expected retry backoffs of 1ms and 100ms, got %v and %v", clientOptions.MinRetryBackoff, clientOptions.MaxRetryBackoff)
+	}
+
+	if clientOptions.DialTimeout != time.Second {
+		t.Errorf("expected dial timeout 1s, got %v", clientOptions.DialTimeout)
+	}
+
+	if clientOptions.ReadTimeout != 250*time.Millisecond || clientOptions.WriteTimeout != 250*time.Millisecond {
+		t.Errorf("expected read and write timeouts of 250ms, got %v and %v", clientOptions.ReadTimeout, clientOptions.WriteTimeout)
+	}
+}
+
+func TestRedisBackendACLAuth(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireUserAuth("limiter", "s3cret")
+
+	tests := []struct {
+		name        string
+		url         string
+		options     *Options
+		expectError bool
+	}{
+		{
+			name: "URL credentials",
+			url:  "redis://limiter:s3cret@" + server.Addr(),
+		},
+		{
+			name:    "option credentials",
+			url:     "redis://" + server.Addr(),
+			options: DefaultOptions().WithAuth("limiter", "s3cret"),
+		},
+		{
+			name:    "option credentials override the URL",
+			url:     "redis://limiter:wrong@" + server.Addr(),
+			options: DefaultOptions().WithAuth("limiter", "s3cret"),
+		},
+		{
+			name:        "wrong password",
+			url:         "redis://" + server.Addr(),
+			options:     DefaultOptions().WithAuth("limiter", "wrong"),
+			expectError: true,
+		},
+		{
+			name:        "default user",
+			url:         "redis://" + server.Addr(),
+			options:     DefaultOptions().WithAuth("", "s3cret"),
+			expectError: true,
+		},
+		{
+			name:        "username without password",
+			url:         "redis://" + server.Addr(),
+			options:     DefaultOptions().WithAuth("limiter", ""),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := NewRedisBackend(tt.url, tt.options)
+			if tt.expectError {
+				if err == nil {
+					backend.Close(context.Background())
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer backend.Close(context.Background())
+
+			if _, err := backend.Take(context.Background(), "test_key", 1); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRedisBackendOperationTimeout(t *testing.T) {
+	// A server that accepts connections but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	backend := &redisBackend{
+		client:  redis.NewClient(&redis.Options{Addr: listener.Addr().String(), MaxRetries: -1}),
+		options: DefaultOptions().WithOperationTimeout(50 * time.Millisecond),
+	}
+	defer backend.client.Close()
+
+	start := time.Now()
+	_, err = backend.Take(context.Background(), "test_key", 1)
+	if err == nil {
+		t.Error("expected an error from an unresponsive server")
+	}
+	if !errors.IsTimeoutError(err) {
+		t.Errorf("expected a TimeoutError, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Take to give up after the operation timeout, took %v", elapsed)
+	}
+}
+
+func TestRedisBackendRetriesDroppedConnections(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	// A proxy that drops the connection of the next commands instead of forwarding them
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	var drops atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				upstream, err := net.Dial("tcp", server.Addr())
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				go io.Copy(conn, upstream)
+
+				buf := make([]byte, 4096)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil || drops.Add(-1) >= 0 {
+						return
+					}
+					if _, err := upstream.Write(buf[:n]); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	tests := []struct {
+		name        string
+		options     *Options
+		expectError bool
+	}{
+		{name: "retried", options: DefaultOptions().WithMaxRetries(3).WithRetryBackoff(time.Millisecond, 10*time.Millisecond)},
+		{name: "retries disabled", options: DefaultOptions().WithMaxRetries(-1), expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := NewRedisBackend("redis://"+listener.Addr().String(), tt.options)
+			if err != nil {
+				t.Fatalf("failed to create backend: %v", err)
+			}
+			defer backend.Close(context.Background())
+
+			drops.Store(2)
+
+			_, err = backend.Take(context.Background(), "test_key", 1)
+			if tt.expectError && err == nil {
+				t.Error("expected an error from a dropped connection, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("expected dropped connections to be retried, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRedisBackendBucketTTL(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithLimit(10).WithRefill(100 * time.Millisecond)
+
+	tests := []struct {
+		name     string
+		options  *Options
+		limit    int64
+		expected time.Duration
+	}{
+		{name: "twice the time to full", options: options, expected: 2 * time.Second},
+		{name: "debt extends the time to full", options: options.WithMaxDebt(10), expected: 4 * time.Second},
+		{name: "minimum", options: options.WithLimit(1).WithRefill(time.Millisecond), expected: minBucketTTL},
+		{name: "maximum", options: options.WithLimit(1000).WithRefill(time.Minute), expected: bucketTTL},
+		{name: "custom limit", options: options, limit: 20, expected: bucketTTL},
+		{name: "grace period", options: options.WithGrace(5, time.Hour), expected: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, server := newTestRedisBackend(t, tt.options)
+
+			var err error
+			if tt.limit > 0 {
+				_, err = backend.TakeWithLimit(ctx, "test_key", 1, tt.limit, 100*time.Millisecond)
+			} else {
+				_, err = backend.Take(ctx, "test_key", 1)
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The grace period is measured on the Redis clock, so allow for the time the script took
+			if ttl := server.TTL(backend.keys.bucket("test_key")); ttl > tt.expected || ttl < tt.expected-time.Second {
+				t.Errorf("expected a TTL of %v, got %v", tt.expected, ttl)
+			}
+		})
+	}
+
+	// Limits set on their own and buckets of a TakeAll follow the same rules
+	backend, server := newTestRedisBackend(t, options)
+	if err := backend.SetLimit(ctx, "custom", 5, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := server.TTL(backend.keys.bucket("custom")); ttl != bucketTTL {
+		t.Errorf("expected a TTL of %v for a custom limit, got %v", bucketTTL, ttl)
+	}
+
+	if _, err := backend.TakeAll(ctx, []string{"a", "b"}, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if ttl := server.TTL(backend.keys.bucket(key)); ttl != 2*time.Second {
+			t.Errorf("expected a TTL of 2s for %s, got %v", key, ttl)
+		}
+	}
+}
+
+func TestRedisBackendDebtRepayment(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(2).WithRefill(100*time.Millisecond).WithMaxDebt(3))
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.SetTime(start)
+
+	if allowed, err := backend.Take(ctx, "test_key", 5); err != nil || !allowed {
+		t.Fatalf("expected take to borrow up to the debt, got %v, %v", allowed, err)
+	}
+
+	// Two refills pay off two tokens of debt
+	server.SetTime(start.Add(250 * time.Millisecond))
+	if allowed, _ := backend.Take(ctx, "test_key", 3); allowed {
+		t.Error("expected take beyond the remaining debt to be denied")
+	}
+	if allowed, _ := backend.Take(ctx, "test_key", 2); !allowed {
+		t.Error("expected take within the remaining debt to be allowed")
+	}
+
+	// Refills stop at the limit once the debt is paid
+	server.SetTime(start.Add(time.Second))
+	if allowed, _ := backend.Take(ctx, "test_key", 5); !allowed {
+		t.Error("expected a full bucket to lend up to the debt again")
+	}
+}
+
+func TestRedisBackendGraceExpiry(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(2).WithRefill(time.Hour).WithGrace(5, time.Minute))
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.SetTime(start)
+
+	if allowed, err := backend.Take(ctx, "test_key", 3); err != nil || !allowed {
+		t.Fatalf("expected take from the grace budget, got %v, %v", allowed, err)
+	}
+
+	// Once the period ends the rest of the budget is gone
+	server.SetTime(start.Add(time.Minute))
+	if allowed, _ := backend.Take(ctx, "test_key", 2); !allowed {
+		t.Error("expected take from the bucket to be allowed")
+	}
+	if allowed, _ := backend.Take(ctx, "test_key", 1); allowed {
+		t.Error("expected the grace budget to end with its period")
+	}
+}
+
+func TestRedisBackendBurstPoolRefill(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(1).WithRefill(time.Hour).WithBurstPool(2, 3*time.Hour))
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.SetTime(start)
+
+	for _, tokens := range []int64{1, 2} {
+		if allowed, err := backend.Take(ctx, "test_key", tokens); err != nil || !allowed {
+			t.Fatalf("expected take of %d tokens to be allowed, got %v, %v", tokens, allowed, err)
+		}
+	}
+	if allowed, _ := backend.Take(ctx, "test_key", 1); allowed {
+		t.Error("expected take beyond the bucket and the pool to be denied")
+	}
+
+	// The key is kept until the pool has regained both credits, so expiring cannot refill it early
+	if ttl := server.TTL(backend.keys.bucket("test_key")); ttl != 6*time.Hour {
+		t.Errorf("expected a TTL of 6h, got %v", ttl)
+	}
+
+	// One interval later the bucket is full again and the pool has regained one credit
+	server.SetTime(start.Add(3 * time.Hour))
+	for i := 0; i < 2; i++ {
+		if allowed, _ := backend.Take(ctx, "test_key", 1); !allowed {
+			t.Errorf("expected take %d to be allowed", i)
+		}
+	}
+	if allowed, _ := backend.Take(ctx, "test_key", 1); allowed {
+		t.Error("expected the pool to regain only one credit per interval")
+	}
+}
+
+func TestRedisBackendSubSecondRefill(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(3).WithRefill(100*time.Millisecond))
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.SetTime(start)
+
+	take := func() bool {
+		allowed, err := backend.Take(ctx, "test_key", 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return allowed
+	}
+
+	for i := 0; i < 3; i++ {
+		if !take() {
+			t.Fatalf("expected take %d to be allowed", i)
+		}
+	}
+	if take() {
+		t.Error("expected take on an empty bucket to be denied")
+	}
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		allowed int
+	}{
+		{name: "before the first refill", elapsed: 99 * time.Millisecond, allowed: 0},
+		{name: "one refill", elapsed: 150 * time.Millisecond, allowed: 1},
+		// The 50ms left over from the previous refill counts toward this one
+		{name: "leftover interval", elapsed: 200 * time.Millisecond, allowed: 1},
+		{name: "two refills", elapsed: 400 * time.Millisecond, allowed: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.SetTime(start.Add(tt.elapsed))
+
+			allowed := 0
+			for take() {
+				allowed++
+			}
+			if allowed != tt.allowed {
+				t.Errorf("expected %d takes to be allowed, got %d", tt.allowed, allowed)
+			}
+		})
+	}
+
+	info, err := backend.GetInfo(ctx, "test_key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !info.LastRefill.Equal(start.Add(400 * time.Millisecond)) {
+		t.Errorf("expected last refill at 400ms, got %v", info.LastRefill.Sub(start))
+	}
+	if info.RefillRate != 100*time.Millisecond {
+		t.Errorf("expected refill rate 100ms, got %v", info.RefillRate)
+	}
+}
+
+func TestRedisBackendSetLimitRefillTime(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions())
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 250*int(time.Millisecond), time.UTC)
+	server.SetTime(now)
+
+	if err := backend.SetLimit(ctx, "test_key", 5, 10*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := backend.GetInfo(ctx, "test_key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !info.LastRefill.Equal(now) {
+		t.Errorf("expected last refill %v, got %v", now, info.LastRefill)
+	}
+	if info.NextRefill.Sub(now) != 10*time.Millisecond {
+		t.Errorf("expected next refill 10ms later, got %v", info.NextRefill.Sub(now))
+	}
+}
+
+func TestRedisBackendDeniedTakeClampsToNewLimit(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions())
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.SetTime(now)
+
+	allowed, err := backend.TakeWithLimit(ctx, "test_key", 4, 10, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Fatal("expected take to be allowed")
+	}
+
+	// Two seconds refill the bucket to 8, which the lower limit of a denied take clamps to 5
+	later := now.Add(2 * time.Second)
+	server.SetTime(later)
+	allowed, err = backend.TakeWithLimit(ctx, "test_key", 6, 5, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Fatal("expected take above the new limit to be denied")
+	}
+
+	key := backend.keys.bucket("test_key")
+	if got := server.HGet(key, "tokens"); got != "5" {
+		t.Errorf("expected tokens 5, got %q", got)
+	}
+	if got, want := server.HGet(key, "last_refill"), strconv.FormatInt(later.UnixMilli(), 10); got != want {
+		t.Errorf("expected last_refill %s, got %s", want, got)
+	}
+	if got := server.HGet(key, "max_tokens"); got != "5" {
+		t.Errorf("expected max_tokens 5, got %q", got)
+	}
+}
+
+func TestRedisBackendUnchangedLimitKeepsRefill(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions())
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.SetTime(now)
+
+	allowed, err := backend.TakeWithLimit(ctx, "test_key", 3, 5, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Fatal("expected take to be allowed")
+	}
+	lastRefill := server.HGet(backend.keys.bucket("test_key"), "last_refill")
+
+	// Repeating the same limit halfway through the interval must not restart it
+	server.SetTime(now.Add(500 * time.Millisecond))
+	if err := backend.SetLimit(ctx, "test_key", 5, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := server.HGet(backend.keys.bucket("test_key"), "last_refill"); got != lastRefill {
+		t.Errorf("expected last_refill %s, got %s", lastRefill, got)
+	}
+
+	server.SetTime(now.Add(time.Second))
+	allowed, err = backend.TakeWithLimit(ctx, "test_key", 1, 5, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Fatal("expected take to be allowed")
+	}
+
+	// 5 - 3 + 1 refilled - 1
+	if got := server.HGet(backend.keys.bucket("test_key"), "tokens"); got != "2" {
+		t.Errorf("expected 2 tokens, got %s", got)
+	}
+}
+
+func TestRedisBackendLastRefillRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 125*int(time.Millisecond), time.UTC)
+
+	tests := []struct {
+		name           string
+		setup          func(backend *redisBackend, server *miniredis.Miniredis)
+		expectedTokens int64
+	}{
+		{
+			name: "take",
+			setup: func(backend *redisBackend, server *miniredis.Miniredis) {
+				backend.Take(ctx, "test_key", 1)
+			},
+			expectedTokens: 99,
+		},
+		{
+			name: "set limit",
+			setup: func(backend *redisBackend, server *miniredis.Miniredis) {
+				backend.SetLimit(ctx, "test_key", 5, time.Second)
+			},
+			expectedTokens: 5,
+		},
+		{
+			name: "set limit then take",
+			setup: func(backend *redisBackend, server *miniredis.Miniredis) {
+				backend.SetLimit(ctx, "test_key", 5, time.Second)
+				backend.Take(ctx, "test_key", 2)
+			},
+			expectedTokens: 3,
+		},
+		{
+			name: "legacy RFC 3339 value",
+			setup: func(backend *redisBackend, server *miniredis.Miniredis) {
+				server.HSet(backend.keys.bucket("test_key"), "tokens", "7", "max_tokens", "10", "last_refill", now.Format(time.RFC3339Nano))
+			},
+			expectedTokens: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, server := newTestRedisBackend(t, DefaultOptions())
+			server.SetTime(now)
+
+			tt.setup(backend, server)
+
+			info, err := backend.GetInfo(ctx, "test_key")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !info.LastRefill.Equal(now) {
+				t.Errorf("expected last refill %v, got %v", now, info.LastRefill)
+			}
+			if info.Tokens != tt.expectedTokens {
+				t.Errorf("expected %d tokens, got %d", tt.expectedTokens, info.Tokens)
+			}
+		})
+	}
+}
+
+func TestRedisBackendLargeLimits(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newTestRedisBackend(t, DefaultOptions())
+
+	// 50 GB a month counted in KB tokens, and a limit beyond 32 bits
+	const limit = int64(1) << 40
+	if err := backend.SetLimit(ctx, "quota", limit, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	allowed, err := backend.Take(ctx, "quota", 50*1024*1024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Fatal("expected a take within the limit to be allowed")
+	}
+
+	info, err := backend.GetInfo(ctx, "quota")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.MaxTokens != limit {
+		t.Errorf("expected max tokens %d, got %d", limit, info.MaxTokens)
+	}
+	if expected := limit - 50*1024*1024; info.Tokens != expected {
+		t.Errorf("expected %d tokens, got %d", expected, info.Tokens)
+	}
+
+	// Limits plus the debt allowance are bounded by MaxLimit, as in memory
+	backend, _ = newTestRedisBackend(t, DefaultOptions().WithMaxDebt(10))
+	if err := backend.SetLimit(ctx, "quota", MaxLimit-9, time.Hour); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error for a limit plus debt beyond MaxLimit, got %v", err)
+	}
+	if _, err := backend.TakeWithLimit(ctx, "quota", 1, MaxLimit-9, time.Hour); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error for a limit plus debt beyond MaxLimit, got %v", err)
+	}
+	if err := backend.SetLimit(ctx, "quota", MaxLimit-10, time.Hour); err != nil {
+		t.Errorf("unexpected error for a limit plus debt of MaxLimit: %v", err)
+	}
+}
+
+func TestRedisBackendInternalKeysDoNotCollide(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newTestRedisBackend(t, DefaultOptions().WithKeyPrefix("ratelimiter:"))
+
+	// A key named like the block marker of another must neither be blocked by it nor block it
+	if err := backend.Block(ctx, "user", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := backend.Take(ctx, "user:rl:blocked", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := backend.GetInfo(ctx, "user:rl:blocked")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !info.BlockedUntil.IsZero() {
+		t.Errorf("expected user:rl:blocked not to be blocked, blocked until %v", info.BlockedUntil)
+	}
+	if info.Tokens != 99 {
+		t.Errorf("expected 99 tokens, got %d", info.Tokens)
+	}
+
+	keys, err := backend.Keys(ctx, "*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "user:rl:blocked" {
+		t.Errorf("expected only the user:rl:blocked bucket, got %v", keys)
+	}
+
+	if err := backend.Unblock(ctx, "user"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, err := backend.GetInfo(ctx, "user:rl:blocked"); err != nil || info.Tokens != 99 {
+		t.Errorf("expected unblocking user to keep user:rl:blocked, got %+v, %v", info, err)
+	}
+}
+
+func TestRedisBackendLegacyKeyLayout(t *testing.T) {
+	ctx := context.Background()
+	backend, server := newTestRedisBackend(t, DefaultOptions())
+
+	// Buckets and blocks written by earlier versions under the bare key are still enforced
+	server.HSet("user", "tokens", "3", "max_tokens", "100", "refill_rate", "1000", "last_refill", time.Now().Format(time.RFC3339Nano))
+	server.Set("blocked:blocked", "1")
+	server.SetTTL("blocked:blocked", time.Minute)
+
+	info, err := backend.GetInfo(ctx, "user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 3 {
+		t.Errorf("expected the 3 tokens of the existing bucket, got %d", info.Tokens)
+	}
+
+	if allowed, err := backend.Take(ctx, "blocked", 1); err != nil || allowed {
+		t.Errorf("expected the existing block to deny, got %v, %v", allowed, err)
+	}
+
+	if err := backend.Block(ctx, "user", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !server.Exists("user:blocked") {
+		t.Error("expected the block marker under user:blocked")
+	}
+}
+
+func TestRedisBackendGetInfoRoundTrips(t *testing.T) {
+	ctx := context.Background()
+	options := DefaultOptions().WithKeyActivity(true).WithBurstPool(5, time.Minute)
+	backend, _ := newTestRedisBackend(t, options)
+
+	backend.Take(ctx, "key", 1)
+	backend.Block(ctx, "key", time.Minute)
+	if _, err := backend.AcquireLease(ctx, "key", 2, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The script is cached by the first read, so later reads take a single round trip
+	if _, err := backend.GetInfo(ctx, "key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hook := &countingHook{}
+	backend.client.AddHook(hook)
+
+	info, err := backend.GetInfo(ctx, "key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hook.calls != 1 {
+		t.Errorf("expected GetInfo to take 1 round trip, got %d", hook.calls)
+	}
+
+	if info.Tokens != 99 || info.BurstCredits != 5 || info.BlockedUntil.IsZero() || len(info.Leases) != 1 || info.Activity == nil || info.Activity.Requests != 1 {
+		t.Errorf("expected the whole state of the key, got %+v", info)
+	}
+}
+
+// countingHook counts the commands and pipelines a Redis client sends
+type countingHook struct {
+	calls int
+}
+
+func (h *countingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	h.calls++
+	return ctx, nil
+}
+
+func (h *countingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *countingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	h.calls++
+	return ctx, nil
+}
+
+func (h *countingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
 }
@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// TLSOptions configures TLS, and optionally client certificates, for connections to Redis
+type TLSOptions struct {
+	// CAFile is a PEM bundle of the CAs trusted to sign the server certificate, empty uses the system roots
+	CAFile string `json:"ca_file,omitempty"`
+
+	// CertFile and KeyFile hold the PEM client certificate and key presented for mutual TLS
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// ServerName overrides the name the server certificate is verified against, empty uses the Redis host
+	ServerName string `json:"server_name,omitempty"`
+
+	// InsecureSkipVerify disables verification of the server certificate, for testing only
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// validate checks that the client certificate and key are set together
+func (t *TLSOptions) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.Wrap(errors.ErrInvalidTokens, "tls cert_file and key_file must be set together")
+	}
+
+	return nil
+}
+
+// config loads the files and builds a TLS config verifying the server as host unless ServerName is set
+func (t *TLSOptions) config(host string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         host,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.ServerName != "" {
+		cfg.ServerName = t.ServerName
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read TLS CA file")
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Wrap(errors.ErrInvalidTokens, "TLS CA file holds no PEM certificates")
+		}
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load TLS client certificate")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,189 @@
+package backend
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testCert is a certificate and key issued for a TLS test
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// issueTestCert creates a certificate signed by parent, or a self-signed CA when parent is nil, and writes it to dir
+func issueTestCert(t *testing.T, dir, name string, parent *testCert, template *x509.Certificate) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	writePEM(t, tc.certFile, "CERTIFICATE", der)
+	writePEM(t, tc.keyFile, "EC PRIVATE KEY", keyDER)
+
+	return tc
+}
+
+// writePEM writes a single PEM block to a file
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestRedisBackendTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := issueTestCert(t, dir, "ca", nil, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	otherCA := issueTestCert(t, dir, "other-ca", nil, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	server := issueTestCert(t, dir, "server", ca, &x509.Certificate{
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:    []string{"redis.internal"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	client := issueTestCert(t, dir, "client", ca, &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	serverPair, err := tls.LoadX509KeyPair(server.certFile, server.keyFile)
+	if err != nil {
+		t.Fatalf("failed to load server certificate: %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	redisServer, err := miniredis.RunTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	if err != nil {
+		t.Fatalf("failed to start TLS server: %v", err)
+	}
+	defer redisServer.Close()
+	redisServer.RequireUserAuth("limiter", "s3cret")
+
+	tests := []struct {
+		name        string
+		tls         *TLSOptions
+		expectError bool
+	}{
+		{
+			name: "mutual TLS",
+			tls:  &TLSOptions{CAFile: ca.certFile, CertFile: client.certFile, KeyFile: client.keyFile},
+		},
+		{
+			name: "server name override",
+			tls:  &TLSOptions{CAFile: ca.certFile, CertFile: client.certFile, KeyFile: client.keyFile, ServerName: "redis.internal"},
+		},
+		{
+			name:        "no client certificate",
+			tls:         &TLSOptions{CAFile: ca.certFile},
+			expectError: true,
+		},
+		{
+			name:        "untrusted server",
+			tls:         &TLSOptions{CAFile: otherCA.certFile, CertFile: client.certFile, KeyFile: client.keyFile},
+			expectError: true,
+		},
+		{
+			name:        "wrong server name",
+			tls:         &TLSOptions{CAFile: ca.certFile, CertFile: client.certFile, KeyFile: client.keyFile, ServerName: "other.internal"},
+			expectError: true,
+		},
+		{
+			name:        "certificate without key",
+			tls:         &TLSOptions{CAFile: ca.certFile, CertFile: client.certFile},
+			expectError: true,
+		},
+		{
+			name:        "missing CA file",
+			tls:         &TLSOptions{CAFile: filepath.Join(dir, "missing.crt")},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := NewRedisBackend("rediss://limiter:s3cret@"+redisServer.Addr(), DefaultOptions().WithMaxRetries(-1).WithTLS(tt.tls))
+			if tt.expectError {
+				if err == nil {
+					backend.Close(context.Background())
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer backend.Close(context.Background())
+
+			allowed, err := backend.Take(context.Background(), "test_key", 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !allowed {
+				t.Error("expected take to be allowed")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// wakeupChannel is the pub/sub channel announcing keys whose tokens may be available before their next refill
+const wakeupChannel = "ratelimiter:wakeup"
+
+// wakeupHub shares one pub/sub connection between every waiter of a backend
+type wakeupHub struct {
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+	done   chan struct{}
+	subs   wakeupSubscribers
+}
+
+// SubscribeWakeups returns a channel signalled when Reset, SetLimit or Unblock runs for the key on any instance
+// The channel is nil if the subscription could not be set up, callers should then fall back to polling
+func (r *redisBackend) SubscribeWakeups(key string) (<-chan struct{}, func()) {
+	hub := &r.wakeups
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if r.closed.Load() {
+		return nil, func() {}
+	}
+
+	if hub.pubsub == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		pubsub := r.client.Subscribe(ctx, wakeupChannel)
+
+		// Wait for the subscription to be confirmed so no wakeup published after this call is missed
+		if _, err := pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+			return nil, func() {}
+		}
+
+		hub.pubsub = pubsub
+		hub.done = make(chan struct{})
+		go hub.dispatch(pubsub.Channel(), hub.done)
+	}
+
+	// Wakeups are published under the stored form of the key
+	return hub.subs.add(r.keys.bucket(key))
+}
+
+// dispatch forwards wakeup messages to the subscribers of their key until the pub/sub connection is closed
+func (h *wakeupHub) dispatch(messages <-chan *redis.Message, done chan<- struct{}) {
+	defer close(done)
+
+	for msg := range messages {
+		h.subs.notify(msg.Payload)
+	}
+}
+
+// close closes the pub/sub connection and waits for the dispatcher to exit
+func (h *wakeupHub) close() {
+	h.mu.Lock()
+	pubsub, done := h.pubsub, h.done
+	h.pubsub = nil
+	h.mu.Unlock()
+
+	if pubsub == nil {
+		return
+	}
+
+	pubsub.Close()
+	<-done
+}
+
+// publishWakeup tells waiters on every instance that tokens for the key may be available
+// Failures are ignored since waiters also wake up when they expect the next refill
+func (r *redisBackend) publishWakeup(ctx context.Context, key string) {
+	r.client.Publish(ctx, wakeupChannel, key)
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// expectWakeup fails the test unless the channel is signalled within a second
+func expectWakeup(t *testing.T, ch <-chan struct{}, operation string) {
+	t.Helper()
+
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Errorf("expected a wakeup after %s", operation)
+	}
+}
+
+func TestRedisBackendWakeups(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newTestRedisBackend(t, DefaultOptions())
+
+	wakeups, unsubscribe := backend.SubscribeWakeups("test_key")
+	if wakeups == nil {
+		t.Fatal("expected a wakeup channel")
+	}
+
+	other, unsubscribeOther := backend.SubscribeWakeups("other_key")
+	defer unsubscribeOther()
+
+	if err := backend.Reset(ctx, "test_key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectWakeup(t, wakeups, "Reset")
+
+	if err := backend.SetLimit(ctx, "test_key", 10, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectWakeup(t, wakeups, "SetLimit")
+
+	if err := backend.Block(ctx, "test_key", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := backend.Unblock(ctx, "test_key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectWakeup(t, wakeups, "Unblock")
+
+	select {
+	case <-other:
+		t.Error("expected no wakeup for another key")
+	default:
+	}
+
+	unsubscribe()
+	backend.Reset(ctx, "test_key")
+	time.Sleep(50 * time.Millisecond)
+
+	select {
+	case <-wakeups:
+		t.Error("expected no wakeup after unsubscribing")
+	default:
+	}
+}
+
+func TestWrappedBackendWakeups(t *testing.T) {
+	ctx := context.Background()
+	eu, _ := newTestRedisBackend(t, DefaultOptions())
+	us, _ := newTestRedisBackend(t, DefaultOptions())
+
+	approximate, err := NewApproximateBackend(eu, &ApproximateOptions{SyncInterval: time.Hour, Nodes: 1})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer approximate.Close(ctx)
+
+	wakeups, unsubscribe := approximate.(WakeupNotifier).SubscribeWakeups("test_key")
+	if wakeups == nil {
+		t.Fatal("expected the approximate backend to pass on wakeups")
+	}
+	defer unsubscribe()
+
+	if err := approximate.Reset(ctx, "test_key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectWakeup(t, wakeups, "Reset")
+
+	// A reset in one region wakes the waiters of the others
+	euRegion, err := NewMultiRegionBackend(eu, map[string]Backend{"us": us}, &MultiRegionOptions{Region: "eu", ReconcileInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer euRegion.Close(ctx)
+	usRegion, err := NewMultiRegionBackend(us, map[string]Backend{"eu": eu}, &MultiRegionOptions{Region: "us", ReconcileInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer usRegion.Close(ctx)
+
+	regionWakeups, unsubscribeRegion := euRegion.(WakeupNotifier).SubscribeWakeups("test_key")
+	if regionWakeups == nil {
+		t.Fatal("expected the multi-region backend to pass on wakeups")
+	}
+	defer unsubscribeRegion()
+
+	if err := usRegion.Reset(ctx, "test_key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectWakeup(t, regionWakeups, "Reset in another region")
+}
@@ -0,0 +1,194 @@
+package backend
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// RefillStrategy decides how many tokens a bucket regains every refill interval
+// Backends count whole intervals since the last refill and keep the partial one, so every strategy refills without drift
+// A bucket that fills up starts its next interval from then
+type RefillStrategy interface {
+	// Refill returns how many of the missing tokens a bucket regains over intervals whole refill intervals
+	// missing and intervals are positive, and a strategy must regain at least one token per interval
+	Refill(missing, intervals int64) int64
+
+	// String names the strategy as ParseRefillStrategy reads it, such as "linear" or "stepped:5"
+	String() string
+}
+
+// LinearRefill regains one token every refill interval, the default of every bucket
+type LinearRefill struct{}
+
+// Refill returns one token per interval
+func (LinearRefill) Refill(missing, intervals int64) int64 {
+	return min(missing, intervals)
+}
+
+// String returns "linear"
+func (LinearRefill) String() string { return "linear" }
+
+// MarshalText encodes the strategy by its name
+func (s LinearRefill) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// SteppedRefill regains Tokens tokens at once every refill interval
+type SteppedRefill struct {
+	Tokens int64
+}
+
+// Refill returns Tokens tokens per interval
+func (s SteppedRefill) Refill(missing, intervals int64) int64 {
+	if s.Tokens <= 0 || intervals > (missing-1)/s.Tokens {
+		return missing
+	}
+	return intervals * s.Tokens
+}
+
+// String returns "stepped:N"
+func (s SteppedRefill) String() string { return "stepped:" + strconv.FormatInt(s.Tokens, 10) }
+
+// MarshalText encodes the strategy by its name
+func (s SteppedRefill) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// ResetRefill fills the bucket completely once a refill interval has passed, as a fixed window would
+type ResetRefill struct{}
+
+// Refill returns every missing token
+func (ResetRefill) Refill(missing, intervals int64) int64 {
+	return missing
+}
+
+// String returns "reset"
+func (ResetRefill) String() string { return "reset" }
+
+// MarshalText encodes the strategy by its name
+func (s ResetRefill) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// ExponentialRefill regains half the missing tokens every refill interval, at least one
+// An exhausted bucket recovers most of its capacity within a few intervals, then approaches full slowly
+type ExponentialRefill struct{}
+
+// Refill halves the missing tokens once per interval
+func (ExponentialRefill) Refill(missing, intervals int64) int64 {
+	left := missing
+	for ; intervals > 0 && left > 0; intervals-- {
+		left -= max(1, left/2)
+	}
+	return missing - left
+}
+
+// String returns "exponential"
+func (ExponentialRefill) String() string { return "exponential" }
+
+// MarshalText encodes the strategy by its name
+func (s ExponentialRefill) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// ParseRefillStrategy returns the built-in strategy named by s, an empty name is linear
+func ParseRefillStrategy(s string) (RefillStrategy, error) {
+	switch s {
+	case "", "linear":
+		return LinearRefill{}, nil
+	case "reset":
+		return ResetRefill{}, nil
+	case "exponential":
+		return ExponentialRefill{}, nil
+	}
+
+	if step, ok := strings.CutPrefix(s, "stepped:"); ok {
+		tokens, err := strconv.ParseInt(step, 10, 64)
+		if err == nil && tokens > 0 {
+			return SteppedRefill{Tokens: tokens}, nil
+		}
+	}
+
+	return nil, errors.Wrapf(errors.ErrInvalidTokens, "unknown refill strategy %q", s)
+}
+
+// validateRefillStrategy checks a strategy before a backend stores it, nil is linear
+func validateRefillStrategy(strategy RefillStrategy) error {
+	if s, ok := strategy.(SteppedRefill); ok && s.Tokens <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "stepped refill tokens must be positive")
+	}
+
+	return nil
+}
+
+// isLinear reports whether a strategy refills linearly, so buckets can skip calling it
+func isLinear(strategy RefillStrategy) bool {
+	if strategy == nil {
+		return true
+	}
+
+	_, ok := strategy.(LinearRefill)
+	return ok
+}
+
+// sameStrategy reports whether two strategies are equal, strategies of types that cannot be compared never are
+func sameStrategy(a, b RefillStrategy) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	if t := reflect.TypeOf(a); t != reflect.TypeOf(b) || !t.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// RefillTokens returns how many of the missing tokens a bucket regains under the strategy over whole refill intervals
+// A nil strategy is linear, and the result is clamped between 0 and missing
+func RefillTokens(strategy RefillStrategy, missing, intervals int64) int64 {
+	if missing <= 0 || intervals <= 0 {
+		return 0
+	}
+
+	if isLinear(strategy) {
+		return min(missing, intervals)
+	}
+
+	return max(0, min(missing, strategy.Refill(missing, intervals)))
+}
+
+// RefillIntervals returns how many refill intervals a bucket missing tokens needs to regain needed of them
+// It relies on every strategy regaining at least one token per interval, so needed intervals always suffice
+func RefillIntervals(strategy RefillStrategy, missing, needed int64) int64 {
+	needed = min(needed, missing)
+	if needed <= 0 {
+		return 0
+	}
+
+	if isLinear(strategy) {
+		return needed
+	}
+
+	// The regained tokens only grow with the intervals, so search for the fewest that are enough
+	lo, hi := int64(1), needed
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if RefillTokens(strategy, missing, mid) >= needed {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	return lo
+}
+
+// refillStrategyName returns the name Redis and snapshots store for a strategy, empty for linear refills
+// Only built-in strategies can be named, since the Redis scripts have to run them
+func refillStrategyName(strategy RefillStrategy) (string, error) {
+	if isLinear(strategy) {
+		return "", nil
+	}
+
+	parsed, err := ParseRefillStrategy(strategy.String())
+	if err != nil || parsed != strategy {
+		return "", errors.Wrapf(errors.ErrInvalidTokens, "refill strategy %q of type %T is not supported by Redis, only built-in strategies are", strategy.String(), strategy)
+	}
+
+	return strategy.String(), nil
+}
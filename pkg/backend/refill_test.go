@@ -0,0 +1,229 @@
+package backend
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// doublingRefill is a custom strategy regaining twice as many tokens each interval as the one before
+type doublingRefill struct{}
+
+func (doublingRefill) Refill(missing, intervals int64) int64 {
+	if intervals >= 62 {
+		return missing
+	}
+	return 1<<intervals - 1
+}
+
+func (doublingRefill) String() string { return "doubling" }
+
+func TestRefillTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  RefillStrategy
+		missing   int64
+		intervals int64
+		expected  int64
+	}{
+		{name: "nil is linear", strategy: nil, missing: 10, intervals: 3, expected: 3},
+		{name: "linear", strategy: LinearRefill{}, missing: 10, intervals: 30, expected: 10},
+		{name: "stepped", strategy: SteppedRefill{Tokens: 4}, missing: 10, intervals: 2, expected: 8},
+		{name: "stepped up to full", strategy: SteppedRefill{Tokens: 4}, missing: 10, intervals: 3, expected: 10},
+		{name: "stepped without overflow", strategy: SteppedRefill{Tokens: 1 << 62}, missing: 10, intervals: 1 << 62, expected: 10},
+		{name: "reset", strategy: ResetRefill{}, missing: 10, intervals: 1, expected: 10},
+		{name: "exponential one interval", strategy: ExponentialRefill{}, missing: 10, intervals: 1, expected: 5},
+		{name: "exponential three intervals", strategy: ExponentialRefill{}, missing: 10, intervals: 3, expected: 8},
+		{name: "exponential last token", strategy: ExponentialRefill{}, missing: 1, intervals: 1, expected: 1},
+		{name: "exponential many intervals", strategy: ExponentialRefill{}, missing: 1 << 40, intervals: 1 << 40, expected: 1 << 40},
+		{name: "custom clamped to missing", strategy: doublingRefill{}, missing: 10, intervals: 5, expected: 10},
+		{name: "nothing missing", strategy: ResetRefill{}, missing: 0, intervals: 5, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RefillTokens(tt.strategy, tt.missing, tt.intervals); got != tt.expected {
+				t.Errorf("expected %d tokens, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRefillIntervals(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy RefillStrategy
+		missing  int64
+		needed   int64
+		expected int64
+	}{
+		{name: "linear", strategy: nil, missing: 10, needed: 4, expected: 4},
+		{name: "stepped", strategy: SteppedRefill{Tokens: 3}, missing: 10, needed: 4, expected: 2},
+		{name: "reset", strategy: ResetRefill{}, missing: 10, needed: 10, expected: 1},
+		{name: "exponential", strategy: ExponentialRefill{}, missing: 10, needed: 8, expected: 3},
+		{name: "more than missing", strategy: nil, missing: 3, needed: 5, expected: 3},
+		{name: "nothing needed", strategy: nil, missing: 3, needed: 0, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RefillIntervals(tt.strategy, tt.missing, tt.needed); got != tt.expected {
+				t.Errorf("expected %d intervals, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseRefillStrategy(t *testing.T) {
+	for _, strategy := range []RefillStrategy{LinearRefill{}, SteppedRefill{Tokens: 5}, ResetRefill{}, ExponentialRefill{}} {
+		parsed, err := ParseRefillStrategy(strategy.String())
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", strategy, err)
+		}
+		if parsed != strategy {
+			t.Errorf("expected %v, got %v", strategy, parsed)
+		}
+	}
+
+	for _, name := range []string{"stepped", "stepped:0", "stepped:-1", "stepped:x", "doubling"} {
+		if _, err := ParseRefillStrategy(name); !stderrors.Is(err, errors.ErrInvalidTokens) {
+			t.Errorf("expected invalid tokens error for %q, got %v", name, err)
+		}
+	}
+}
+
+// strategyCheckpoints drain a bucket of 10 tokens refilling every 100ms at the given offsets from its start
+// The bucket is drained at 0, then at 150ms after one interval, 450ms after three and 1s after six
+var strategyCheckpoints = []time.Duration{0, 150 * time.Millisecond, 450 * time.Millisecond, time.Second}
+
+// strategyDrains are the tokens each strategy admits at the strategyCheckpoints
+var strategyDrains = []struct {
+	strategy RefillStrategy
+	drained  []int64
+}{
+	{strategy: LinearRefill{}, drained: []int64{10, 1, 3, 6}},
+	{strategy: SteppedRefill{Tokens: 3}, drained: []int64{10, 3, 9, 10}},
+	{strategy: ResetRefill{}, drained: []int64{10, 10, 10, 10}},
+	{strategy: ExponentialRefill{}, drained: []int64{10, 5, 8, 10}},
+}
+
+func TestBucketRefillStrategies(t *testing.T) {
+	for _, optimistic := range []bool{false, true} {
+		for _, tt := range strategyDrains {
+			t.Run(tt.strategy.String(), func(t *testing.T) {
+				start := time.Now()
+				bkt := newBucket("key", 10, 10, 100*time.Millisecond, start, 0)
+				if optimistic {
+					bkt = newOptimisticBucket("key", 10, 10, 100*time.Millisecond, start, 0)
+				}
+				bkt.setStrategy(tt.strategy, start)
+
+				for i, offset := range strategyCheckpoints {
+					var drained int64
+					for bkt.take(1, start.Add(offset)) {
+						drained++
+					}
+					if drained != tt.drained[i] {
+						t.Errorf("optimistic %v: expected %d tokens at %v, got %d", optimistic, tt.drained[i], offset, drained)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRedisBackendRefillStrategies(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range strategyDrains {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			backend, server := newTestRedisBackend(t, DefaultOptions().WithLimit(10).WithRefill(100*time.Millisecond))
+			server.SetTime(start)
+
+			if err := backend.SetRefillStrategy(ctx, "key", tt.strategy); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for i, offset := range strategyCheckpoints {
+				server.SetTime(start.Add(offset))
+
+				var drained int64
+				for {
+					allowed, err := backend.Take(ctx, "key", 1)
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					if !allowed {
+						break
+					}
+					drained++
+				}
+				if drained != tt.drained[i] {
+					t.Errorf("expected %d tokens at %v, got %d", tt.drained[i], offset, drained)
+				}
+			}
+		})
+	}
+}
+
+func TestSetRefillStrategy(t *testing.T) {
+	ctx := context.Background()
+
+	inMemory, err := NewInMemoryBackend(DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer inMemory.Close(ctx)
+	redis, server := newTestRedisBackend(t, DefaultOptions())
+
+	for name, b := range map[string]Backend{"memory": inMemory, "redis": redis} {
+		t.Run(name, func(t *testing.T) {
+			setter := b.(RefillStrategySetter)
+
+			if err := setter.SetRefillStrategy(ctx, "key", SteppedRefill{Tokens: 5}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			info, err := b.GetInfo(ctx, "key")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Strategy != (SteppedRefill{Tokens: 5}) {
+				t.Errorf("expected stepped strategy, got %v", info.Strategy)
+			}
+
+			// Linear refills are reported as nil
+			if err := setter.SetRefillStrategy(ctx, "key", LinearRefill{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info, _ := b.GetInfo(ctx, "key"); info.Strategy != nil {
+				t.Errorf("expected linear refills, got %v", info.Strategy)
+			}
+
+			if err := setter.SetRefillStrategy(ctx, "key", SteppedRefill{}); !stderrors.Is(err, errors.ErrInvalidTokens) {
+				t.Errorf("expected invalid tokens error, got %v", err)
+			}
+			if err := setter.SetRefillStrategy(ctx, "", ResetRefill{}); !stderrors.Is(err, errors.ErrInvalidKey) {
+				t.Errorf("expected invalid key error, got %v", err)
+			}
+		})
+	}
+
+	// Only the Lua scripts refill Redis buckets, so custom strategies are refused there
+	if err := inMemory.(RefillStrategySetter).SetRefillStrategy(ctx, "custom", doublingRefill{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := redis.SetRefillStrategy(ctx, "custom", doublingRefill{}); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error, got %v", err)
+	}
+
+	// A strategy on a bucket without a custom limit keeps the bucket for as long as one with a limit
+	redis.SetRefillStrategy(ctx, "ttl", ResetRefill{})
+	redis.Take(ctx, "ttl", 1)
+	if ttl := server.TTL(redis.keys.bucket("ttl")); ttl != bucketTTL {
+		t.Errorf("expected TTL %v, got %v", bucketTTL, ttl)
+	}
+}
@@ -0,0 +1,210 @@
+package backend
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// defaultShardCount is the number of shards used when ShardCount is not set
+const defaultShardCount = 32
+
+// mapEntryOverhead approximates the bytes a map entry costs beyond the bucket and key data
+const mapEntryOverhead = 48
+
+// evictionSamples is the number of buckets sampled to pick one to evict
+const evictionSamples = 5
+
+// bucketSize is the size of a bucket struct
+var bucketSize = int64(unsafe.Sizeof(bucket{}))
+
+// bucketShard holds the buckets of the keys hashing to it
+type bucketShard struct {
+	mu      sync.RWMutex
+	buckets map[string]*bucket
+}
+
+// shardedStore splits buckets across shards so goroutines working on distinct keys rarely share a lock
+// It keeps an approximate count of the bytes its buckets use and evicts buckets beyond maxBytes
+type shardedStore struct {
+	shards    []*bucketShard
+	maxBytes  int64
+	bytes     atomic.Int64
+	evictions atomic.Uint64
+}
+
+// newShardedStore creates a store with the given number of shards, a maxBytes of 0 disables eviction
+func newShardedStore(count int, maxBytes int64) *shardedStore {
+	if count <= 0 {
+		count = defaultShardCount
+	}
+
+	s := &shardedStore{shards: make([]*bucketShard, count), maxBytes: maxBytes}
+	for i := range s.shards {
+		s.shards[i] = &bucketShard{buckets: make(map[string]*bucket)}
+	}
+
+	return s
+}
+
+// shard returns the shard owning the key, using an FNV-1a hash
+func (s *shardedStore) shard(key string) *bucketShard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+
+	return s.shards[hash%uint32(len(s.shards))]
+}
+
+// load returns the bucket for the key, if any
+func (s *shardedStore) load(key string) (*bucket, bool) {
+	shard := s.shard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	bkt, ok := shard.buckets[key]
+	return bkt, ok
+}
+
+// loadOrCreate returns the bucket for the key, creating it with create if it does not exist
+func (s *shardedStore) loadOrCreate(key string, create func() *bucket) *bucket {
+	if bkt, ok := s.load(key); ok {
+		return bkt
+	}
+
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	// Another goroutine may have created it while the lock was released
+	if bkt, ok := shard.buckets[key]; ok {
+		return bkt
+	}
+
+	bkt := create()
+	s.insertLocked(shard, key, bkt)
+	return bkt
+}
+
+// store sets the bucket for the key
+func (s *shardedStore) store(key string, bkt *bucket) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	s.insertLocked(shard, key, bkt)
+}
+
+// delete removes the bucket for the key
+func (s *shardedStore) delete(key string) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	s.deleteLocked(shard, key)
+}
+
+// deleteIf removes every bucket for which fn returns true
+func (s *shardedStore) deleteIf(fn func(bkt *bucket) bool) {
+	for i := range s.shards {
+		s.deleteIfInShard(i, fn)
+	}
+}
+
+// deleteIfInShard removes every bucket of the i-th shard for which fn returns true
+func (s *shardedStore) deleteIfInShard(i int, fn func(bkt *bucket) bool) {
+	shard := s.shards[i]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	for key, bkt := range shard.buckets {
+		if fn(bkt) {
+			s.deleteLocked(shard, key)
+		}
+	}
+}
+
+// insertLocked adds a bucket to a locked shard, evicting from it while the store is over its limit
+func (s *shardedStore) insertLocked(shard *bucketShard, key string, bkt *bucket) {
+	if _, ok := shard.buckets[key]; !ok {
+		s.bytes.Add(entrySize(key))
+	}
+	shard.buckets[key] = bkt
+
+	for s.maxBytes > 0 && s.bytes.Load() > s.maxBytes {
+		if !s.evictLocked(shard, key) {
+			return
+		}
+	}
+}
+
+// deleteLocked removes a bucket from a locked shard
+func (s *shardedStore) deleteLocked(shard *bucketShard, key string) {
+	if _, ok := shard.buckets[key]; ok {
+		delete(shard.buckets, key)
+		s.bytes.Add(-entrySize(key))
+	}
+}
+
+// evictLocked removes the least recently refilled of a few sampled buckets, never the kept key
+// It returns false when the shard has nothing left to evict
+func (s *shardedStore) evictLocked(shard *bucketShard, keep string) bool {
+	var victim string
+	var oldest *bucket
+	sampled := 0
+
+	// Map iteration starts at a random entry, so this samples like Redis' approximate LRU
+	for key, bkt := range shard.buckets {
+		if key == keep {
+			continue
+		}
+		if oldest == nil || bkt.lastRefill().Before(oldest.lastRefill()) {
+			victim, oldest = key, bkt
+		}
+		if sampled++; sampled == evictionSamples {
+			break
+		}
+	}
+
+	if oldest == nil {
+		return false
+	}
+
+	s.deleteLocked(shard, victim)
+	s.evictions.Add(1)
+	return true
+}
+
+// entrySize approximates the bytes used by the bucket of a key
+func entrySize(key string) int64 {
+	return bucketSize + mapEntryOverhead + int64(len(key))
+}
+
+// rangeBuckets calls fn for every bucket until fn returns false
+// fn must not modify the store
+func (s *shardedStore) rangeBuckets(fn func(key string, bkt *bucket) bool) {
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		for key, bkt := range shard.buckets {
+			if !fn(key, bkt) {
+				shard.mu.RUnlock()
+				return
+			}
+		}
+		shard.mu.RUnlock()
+	}
+}
+
+// len returns the number of buckets in the store
+func (s *shardedStore) len() int {
+	count := 0
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		count += len(shard.buckets)
+		shard.mu.RUnlock()
+	}
+
+	return count
+}
@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    int
+		expected int
+	}{
+		{name: "default shard count", count: 0, expected: defaultShardCount},
+		{name: "single shard", count: 1, expected: 1},
+		{name: "custom shard count", count: 8, expected: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newShardedStore(tt.count, 0)
+			if len(store.shards) != tt.expected {
+				t.Errorf("expected %d shards, got %d", tt.expected, len(store.shards))
+			}
+
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("key_%d", i)
+				store.store(key, newBucket(key, 1, 1, time.Second, time.Now(), 0))
+			}
+
+			if store.len() != 100 {
+				t.Errorf("expected 100 buckets, got %d", store.len())
+			}
+
+			if bkt, ok := store.load("key_42"); !ok || bkt.Key != "key_42" {
+				t.Errorf("expected to load key_42, got %v", bkt)
+			}
+
+			store.delete("key_42")
+			if _, ok := store.load("key_42"); ok {
+				t.Error("expected key_42 to be deleted")
+			}
+
+			store.deleteIf(func(bkt *bucket) bool { return bkt.Key != "key_1" })
+			if store.len() != 1 {
+				t.Errorf("expected 1 bucket after deleteIf, got %d", store.len())
+			}
+		})
+	}
+}
+
+func TestShardedStoreDistribution(t *testing.T) {
+	store := newShardedStore(16, 0)
+	for i := 0; i < 1600; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		store.store(key, newBucket(key, 1, 1, time.Second, time.Now(), 0))
+	}
+
+	for i, shard := range store.shards {
+		if n := len(shard.buckets); n < 50 || n > 150 {
+			t.Errorf("expected shard %d to hold about 100 keys, got %d", i, n)
+		}
+	}
+}
+
+func TestShardedStoreLoadOrCreate(t *testing.T) {
+	store := newShardedStore(4, 0)
+
+	var wg sync.WaitGroup
+	results := make([]*bucket, 50)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = store.loadOrCreate("hot_key", func() *bucket {
+				return newBucket("hot_key", 1, 1, time.Second, time.Now(), 0)
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for _, bkt := range results {
+		if bkt != results[0] {
+			t.Fatal("expected every caller to get the same bucket")
+		}
+	}
+}
+
+func TestShardedStoreMemoryAccounting(t *testing.T) {
+	store := newShardedStore(4, 0)
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		store.store(key, newBucket(key, 1, 1, time.Second, time.Now(), 0))
+	}
+
+	expected := int64(10) * entrySize("key_0")
+	if got := store.bytes.Load(); got != expected {
+		t.Errorf("expected %d bytes, got %d", expected, got)
+	}
+
+	// Replacing a bucket does not count its key twice
+	store.store("key_0", newBucket("key_0", 1, 1, time.Second, time.Now(), 0))
+	if got := store.bytes.Load(); got != expected {
+		t.Errorf("expected %d bytes after replace, got %d", expected, got)
+	}
+
+	store.delete("key_0")
+	store.deleteIf(func(bkt *bucket) bool { return true })
+	if got := store.bytes.Load(); got != 0 {
+		t.Errorf("expected 0 bytes after deleting every bucket, got %d", got)
+	}
+}
+
+func TestShardedStoreEviction(t *testing.T) {
+	store := newShardedStore(1, 3*entrySize("key_0"))
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		store.store(key, newBucket(key, 1, 1, time.Second, now.Add(time.Duration(i)*time.Second), 0))
+	}
+
+	store.loadOrCreate("key_3", func() *bucket {
+		return newBucket("key_3", 1, 1, time.Second, now.Add(3*time.Second), 0)
+	})
+
+	if store.len() != 3 {
+		t.Errorf("expected 3 buckets under the memory cap, got %d", store.len())
+	}
+	if _, ok := store.load("key_0"); ok {
+		t.Error("expected the least recently refilled bucket to be evicted")
+	}
+	if _, ok := store.load("key_3"); !ok {
+		t.Error("expected the new bucket to be kept")
+	}
+	if store.evictions.Load() != 1 {
+		t.Errorf("expected 1 eviction, got %d", store.evictions.Load())
+	}
+	if store.bytes.Load() > store.maxBytes {
+		t.Errorf("expected at most %d bytes, got %d", store.maxBytes, store.bytes.Load())
+	}
+}
@@ -0,0 +1,19 @@
+package backend
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// shutdownOnce runs shutdown if closed is not set yet, setting it
+// The flag is swapped while holding mu, so concurrent Close calls shut down once and all return after it is done
+func shutdownOnce(mu sync.Locker, closed *atomic.Bool, shutdown func() error) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if closed.Swap(true) {
+		return nil
+	}
+
+	return shutdown()
+}
@@ -0,0 +1,139 @@
+package backend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// snapshotVersion is the format version written to snapshot files
+const snapshotVersion = 1
+
+// snapshot is the on-disk state of an in-memory backend
+type snapshot struct {
+	Version int                  `json:"version"`
+	SavedAt time.Time            `json:"saved_at"`
+	Buckets []bucketState        `json:"buckets"`
+	Blocks  map[string]time.Time `json:"blocks,omitempty"`
+}
+
+// bucketState is the persisted state of one bucket
+type bucketState struct {
+	Key        string        `json:"key"`
+	Tokens     int64         `json:"tokens"`
+	MaxTokens  int64         `json:"max_tokens"`
+	RefillRate time.Duration `json:"refill_rate"`
+	LastRefill time.Time     `json:"last_refill"`
+
+	// Strategy names a built-in refill strategy, empty for linear refills or strategies that cannot be named
+	Strategy string `json:"refill_strategy,omitempty"`
+}
+
+// saveSnapshot writes the buckets and active blocks to the snapshot file
+// The file is replaced atomically so a crash never leaves a partial snapshot
+func (b *inMemoryBackend) saveSnapshot(path string) error {
+	snap := snapshot{
+		Version: snapshotVersion,
+		SavedAt: time.Now(),
+		Blocks:  make(map[string]time.Time),
+	}
+
+	b.store.rangeBuckets(func(key string, bkt *bucket) bool {
+		view := bkt.view(snap.SavedAt)
+		strategy, _ := refillStrategyName(view.strategy)
+		snap.Buckets = append(snap.Buckets, bucketState{
+			Key:        bkt.Key,
+			Tokens:     view.tokens,
+			MaxTokens:  view.maxTokens,
+			RefillRate: view.refill,
+			LastRefill: view.lastRefill,
+			Strategy:   strategy,
+		})
+		return true
+	})
+
+	b.blocks.Range(func(key, value interface{}) bool {
+		if until := value.(time.Time); until.After(snap.SavedAt) {
+			snap.Blocks[key.(string)] = until
+		}
+		return true
+	})
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode snapshot")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create snapshot file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write snapshot file")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write snapshot file")
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(err, "failed to replace snapshot file")
+	}
+
+	return nil
+}
+
+// loadSnapshot restores buckets and active blocks from the snapshot file
+// A missing file is not an error so the first start works without a snapshot
+func (b *inMemoryBackend) loadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to read snapshot file")
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return errors.Wrap(err, "failed to decode snapshot")
+	}
+
+	if snap.Version != snapshotVersion {
+		return errors.Wrapf(errors.ErrBackendUnavailable, "unsupported snapshot version %d", snap.Version)
+	}
+
+	for i, state := range snap.Buckets {
+		if i >= b.options.MaxKeys {
+			break
+		}
+		if state.Key == "" || state.MaxTokens <= 0 || state.MaxTokens > MaxLimit-b.options.MaxDebt || state.RefillRate <= 0 {
+			continue
+		}
+
+		strategy, err := ParseRefillStrategy(state.Strategy)
+		if err != nil {
+			continue
+		}
+
+		// Tokens are refilled from LastRefill on the next access, covering the downtime
+		bkt := b.newBucket(state.Key, min(state.Tokens, state.MaxTokens), state.MaxTokens, state.RefillRate, state.LastRefill)
+		bkt.setStrategy(strategy, bkt.epoch)
+		b.store.store(state.Key, bkt)
+	}
+
+	now := time.Now()
+	for key, until := range snap.Blocks {
+		if until.After(now) {
+			b.blocks.Store(key, until)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInMemoryBackendSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "buckets.json")
+	options := DefaultOptions().WithLimit(10).WithRefill(time.Hour).WithSnapshotPath(path)
+
+	backend, err := NewInMemoryBackend(options)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	backend.Take(ctx, "drained", 8)
+	backend.SetLimit(ctx, "custom", 50, time.Hour)
+	backend.(RefillStrategySetter).SetRefillStrategy(ctx, "custom", SteppedRefill{Tokens: 5})
+	backend.Block(ctx, "blocked", time.Hour)
+
+	if err := backend.Close(ctx); err != nil {
+		t.Fatalf("failed to close backend: %v", err)
+	}
+
+	restored, err := NewInMemoryBackend(options)
+	if err != nil {
+		t.Fatalf("failed to restore backend: %v", err)
+	}
+	defer restored.Close(ctx)
+
+	info, err := restored.GetInfo(ctx, "drained")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 2 {
+		t.Errorf("expected 2 tokens after restore, got %d", info.Tokens)
+	}
+
+	info, err = restored.GetInfo(ctx, "custom")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.MaxTokens != 50 {
+		t.Errorf("expected custom limit 50 after restore, got %d", info.MaxTokens)
+	}
+	if info.Strategy != (SteppedRefill{Tokens: 5}) {
+		t.Errorf("expected stepped refill strategy after restore, got %v", info.Strategy)
+	}
+
+	allowed, err := restored.Take(ctx, "blocked", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected block to survive restore")
+	}
+}
+
+func TestInMemoryBackendSnapshotLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		contents    string
+		expectError bool
+	}{
+		{
+			name:        "missing file",
+			contents:    "",
+			expectError: false,
+		},
+		{
+			name:        "corrupt file",
+			contents:    "{not json",
+			expectError: true,
+		},
+		{
+			name:        "unsupported version",
+			contents:    `{"version": 99}`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "buckets.json")
+			if tt.contents != "" {
+				if err := os.WriteFile(path, []byte(tt.contents), 0o600); err != nil {
+					t.Fatalf("failed to write snapshot: %v", err)
+				}
+			}
+
+			backend, err := NewInMemoryBackend(DefaultOptions().WithSnapshotPath(path))
+			if tt.expectError && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if backend != nil {
+				backend.Close(context.Background())
+			}
+		})
+	}
+}
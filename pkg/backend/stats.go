@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// statsWindow is the sliding window over which Stats reports the tokens consumed
+const statsWindow = time.Minute
+
+// usageCounter counts the decisions of a backend and the tokens it handed out, without locks
+type usageCounter struct {
+	allowed atomic.Uint64
+	denied  atomic.Uint64
+	tokens  WindowCounter
+}
+
+// newUsageCounter returns a usageCounter counting tokens over the statsWindow
+func newUsageCounter() *usageCounter {
+	return &usageCounter{tokens: WindowCounter{Window: statsWindow}}
+}
+
+// record counts a decision taking tokens at now
+func (c *usageCounter) record(now time.Time, allowed bool, tokens int64) {
+	if !allowed {
+		c.denied.Add(1)
+		return
+	}
+
+	c.allowed.Add(1)
+
+	c.tokens.Add(now, tokens, 0)
+}
+
+// consumed estimates the tokens handed out over the statsWindow before now
+func (c *usageCounter) consumed(now time.Time) int64 {
+	tokens, _ := c.tokens.Counts(now)
+	return tokens
+}
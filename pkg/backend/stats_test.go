@@ -0,0 +1,123 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUsageCounterWindows(t *testing.T) {
+	c := newUsageCounter()
+	start := time.Unix(0, 0).Add(100 * statsWindow)
+
+	c.record(start, true, 10)
+	c.record(start.Add(time.Second), true, 20)
+	c.record(start, false, 5)
+
+	if c.allowed.Load() != 2 || c.denied.Load() != 1 {
+		t.Errorf("expected 2 allowed and 1 denied, got %d and %d", c.allowed.Load(), c.denied.Load())
+	}
+	if consumed := c.consumed(start.Add(time.Second)); consumed != 30 {
+		t.Errorf("expected 30 tokens consumed, got %d", consumed)
+	}
+
+	// Halfway through the next window half of the previous one still counts
+	next := start.Add(statsWindow + statsWindow/2)
+	c.record(next, true, 4)
+	if consumed := c.consumed(next); consumed != 19 {
+		t.Errorf("expected 19 tokens consumed, got %d", consumed)
+	}
+
+	// Windows older than the previous one are dropped
+	if consumed := c.consumed(start.Add(3 * statsWindow)); consumed != 0 {
+		t.Errorf("expected 0 tokens consumed, got %d", consumed)
+	}
+	c.record(start.Add(2*statsWindow), true, 1)
+	if consumed := c.consumed(start.Add(2 * statsWindow)); consumed != 5 {
+		t.Errorf("expected 5 tokens consumed, got %d", consumed)
+	}
+}
+
+func TestInMemoryBackendStats(t *testing.T) {
+	ctx := context.Background()
+	maxBytes := 2 * entrySize("key1")
+	backend, err := NewInMemoryBackend(DefaultOptions().WithShardCount(1).WithLimit(3).WithMaxMemory(maxBytes))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(ctx)
+
+	reporter, ok := backend.(StatsReporter)
+	if !ok {
+		t.Fatal("expected in-memory backend to implement StatsReporter")
+	}
+
+	backend.Take(ctx, "key1", 2)
+	backend.Take(ctx, "key1", 2)
+	backend.TakeAll(ctx, []string{"key2", "key3"}, 1)
+
+	stats, err := reporter.Stats(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Allowed != 2 || stats.Denied != 1 {
+		t.Errorf("expected 2 allowed and 1 denied, got %d and %d", stats.Allowed, stats.Denied)
+	}
+	if stats.TokensConsumed != 4 {
+		t.Errorf("expected 4 tokens consumed, got %d", stats.TokensConsumed)
+	}
+	if stats.Keys != 2 {
+		t.Errorf("expected 2 keys, got %d", stats.Keys)
+	}
+	if stats.Evictions != 1 {
+		t.Errorf("expected 1 eviction, got %d", stats.Evictions)
+	}
+	if stats.Window != statsWindow {
+		t.Errorf("expected window %v, got %v", statsWindow, stats.Window)
+	}
+
+	backend.Close(ctx)
+	if _, err := reporter.Stats(ctx); err == nil {
+		t.Error("expected error after close, got nil")
+	}
+}
+
+func TestRedisBackendStats(t *testing.T) {
+	ctx := context.Background()
+	first, server := newTestRedisBackend(t, DefaultOptions().WithLimit(3))
+
+	other, err := NewRedisBackend("redis://"+server.Addr(), DefaultOptions().WithLimit(3))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	second := other.(*redisBackend)
+
+	first.Take(ctx, "key1", 2)
+	first.Take(ctx, "key1", 2)
+	second.Take(ctx, "key2", 3)
+	second.TakeAll(ctx, []string{"key3", "key4"}, 1)
+
+	// Closing flushes the counts of the second instance
+	if err := second.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats, err := first.Stats(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Allowed != 3 || stats.Denied != 1 {
+		t.Errorf("expected 3 allowed and 1 denied, got %d and %d", stats.Allowed, stats.Denied)
+	}
+	if stats.TokensConsumed != 7 {
+		t.Errorf("expected 7 tokens consumed, got %d", stats.TokensConsumed)
+	}
+	if stats.Keys == 0 {
+		t.Error("expected keys to be counted")
+	}
+
+	server.Close()
+	if _, err := first.Stats(ctx); err == nil {
+		t.Error("expected error with Redis down, got nil")
+	}
+}
@@ -0,0 +1,57 @@
+package backend
+
+import "sync"
+
+// wakeupSubscribers tracks the channels waiting for wakeups on each key
+type wakeupSubscribers struct {
+	mu   sync.Mutex
+	subs map[string]map[chan struct{}]struct{}
+}
+
+// add subscribes a new channel to the key and returns it with a function to unsubscribe
+func (w *wakeupSubscribers) add(key string) (<-chan struct{}, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.subs == nil {
+		w.subs = make(map[string]map[chan struct{}]struct{})
+	}
+	if w.subs[key] == nil {
+		w.subs[key] = make(map[chan struct{}]struct{})
+	}
+
+	ch := make(chan struct{}, 1)
+	w.subs[key][ch] = struct{}{}
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(w.subs[key], ch)
+		if len(w.subs[key]) == 0 {
+			delete(w.subs, key)
+		}
+	}
+}
+
+// notify signals every channel subscribed to the key without blocking
+func (w *wakeupSubscribers) notify(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ch := range w.subs[key] {
+		// A pending wakeup is as good as a new one
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// has reports whether the key has any subscriber
+func (w *wakeupSubscribers) has(key string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.subs[key]) > 0
+}
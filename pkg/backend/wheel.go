@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"sync"
+	"time"
+)
+
+// wheelSlots is the number of slots in the refill timer wheel
+const wheelSlots = 512
+
+// wheelTimer is a callback waiting in a wheel slot
+type wheelTimer struct {
+	rounds int
+	fn     func()
+}
+
+// timerWheel runs callbacks at the first tick at or after their deadline
+// Scheduling is O(1) and a single goroutine serves every timer, so thousands of waiters cost one ticker
+type timerWheel struct {
+	tick time.Duration
+
+	mu      sync.Mutex
+	slots   [wheelSlots][]*wheelTimer
+	current int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newTimerWheel starts a wheel advancing once per tick
+func newTimerWheel(tick time.Duration) *timerWheel {
+	w := &timerWheel{
+		tick: tick,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+// schedule runs fn on the wheel goroutine at the first tick at or after at
+func (w *timerWheel) schedule(at time.Time, fn func()) {
+	ticks := int((time.Until(at) + w.tick - 1) / w.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	slot := (w.current + ticks) % wheelSlots
+	w.slots[slot] = append(w.slots[slot], &wheelTimer{
+		rounds: (ticks - 1) / wheelSlots,
+		fn:     fn,
+	})
+}
+
+// run advances the wheel every tick until it is closed
+func (w *timerWheel) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, fn := range w.advance() {
+				fn()
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// advance moves to the next slot and returns the callbacks that are due
+func (w *timerWheel) advance() []func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.current = (w.current + 1) % wheelSlots
+	timers := w.slots[w.current]
+
+	var due []func()
+	pending := timers[:0]
+	for _, t := range timers {
+		if t.rounds > 0 {
+			t.rounds--
+			pending = append(pending, t)
+			continue
+		}
+		due = append(due, t.fn)
+	}
+
+	// Clear the tail so fired timers can be collected
+	for i := len(pending); i < len(timers); i++ {
+		timers[i] = nil
+	}
+	w.slots[w.current] = pending
+
+	return due
+}
+
+// close stops the wheel, pending callbacks are dropped
+func (w *timerWheel) close() {
+	close(w.stop)
+	<-w.done
+}
@@ -0,0 +1,109 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimerWheel(t *testing.T) {
+	wheel := newTimerWheel(time.Millisecond)
+	defer wheel.close()
+
+	var mu sync.Mutex
+	var fired []string
+	done := make(chan struct{}, 3)
+
+	start := time.Now()
+	record := func(name string) func() {
+		return func() {
+			mu.Lock()
+			fired = append(fired, name)
+			mu.Unlock()
+			done <- struct{}{}
+		}
+	}
+
+	// The last deadline is more than one rotation of the wheel away
+	wheel.schedule(start.Add(wheelSlots*time.Millisecond+100*time.Millisecond), record("late"))
+	wheel.schedule(start.Add(20*time.Millisecond), record("early"))
+	wheel.schedule(start.Add(-time.Second), record("past"))
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected every timer to fire")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []string{"past", "early", "late"}
+	for i, name := range expected {
+		if fired[i] != name {
+			t.Errorf("expected timer %d to be %s, got %s", i, name, fired[i])
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < wheelSlots*time.Millisecond+100*time.Millisecond {
+		t.Errorf("expected the late timer to wait a full rotation, fired after %v", elapsed)
+	}
+}
+
+func TestInMemoryBackendRefillWheel(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewInMemoryBackend(DefaultOptions().WithLimit(1).WithRefill(50 * time.Millisecond).WithRefillWheel(time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(ctx)
+
+	backend.Take(ctx, "test_key", 1)
+
+	notifier := backend.(WakeupNotifier)
+	wakeups, unsubscribe := notifier.SubscribeWakeups("test_key")
+	defer unsubscribe()
+	if wakeups == nil {
+		t.Fatal("expected a wakeup channel with the refill wheel enabled")
+	}
+
+	select {
+	case <-wakeups:
+	case <-time.After(time.Second):
+		t.Fatal("expected a wakeup at the next refill")
+	}
+
+	info, err := backend.GetInfo(ctx, "test_key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 1 {
+		t.Errorf("expected the bucket to be refilled on wakeup, got %d tokens", info.Tokens)
+	}
+
+	if err := backend.Reset(ctx, "test_key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-wakeups:
+	case <-time.After(time.Second):
+		t.Error("expected a wakeup after Reset")
+	}
+}
+
+func TestInMemoryBackendWakeupsDisabled(t *testing.T) {
+	backend, err := NewInMemoryBackend(DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(context.Background())
+
+	wakeups, unsubscribe := backend.(WakeupNotifier).SubscribeWakeups("test_key")
+	defer unsubscribe()
+	if wakeups != nil {
+		t.Error("expected no wakeup channel without the refill wheel")
+	}
+}
@@ -0,0 +1,67 @@
+package backend
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WindowCounter counts two quantities, such as requests and denials, over a sliding window without locks
+// Each window is counted in one of two slots, the current window and the one before
+type WindowCounter struct {
+	// Window is the length of the sliding window, set before the first Add
+	Window time.Duration
+
+	slots [2]atomic.Pointer[windowSlot]
+}
+
+// windowSlot holds the counts of one window
+type windowSlot struct {
+	index  uint64
+	first  atomic.Int64
+	second atomic.Int64
+}
+
+// Add counts first and second in the window holding now
+func (c *WindowCounter) Add(now time.Time, first, second int64) {
+	index := uint64(now.UnixNano() / int64(c.Window))
+	slot := &c.slots[index%2]
+	for {
+		current := slot.Load()
+
+		// A late count for a window its slot already moved on from is dropped
+		if current != nil && current.index > index {
+			return
+		}
+
+		// A slot still holding an older window starts over
+		if current == nil || current.index != index {
+			slot.CompareAndSwap(current, &windowSlot{index: index})
+			continue
+		}
+
+		current.first.Add(first)
+		current.second.Add(second)
+		return
+	}
+}
+
+// Counts estimates both quantities over the Window before now
+// The previous window is weighted by how much of it still overlaps the sliding window
+func (c *WindowCounter) Counts(now time.Time) (int64, int64) {
+	index := uint64(now.UnixNano() / int64(c.Window))
+	first, second := c.windowCounts(index)
+	previousFirst, previousSecond := c.windowCounts(index - 1)
+
+	elapsed := float64(now.UnixNano()%int64(c.Window)) / float64(c.Window)
+	return first + int64(float64(previousFirst)*(1-elapsed)), second + int64(float64(previousSecond)*(1-elapsed))
+}
+
+// windowCounts returns the counts of a window, 0 once its slot moved on to a later one
+func (c *WindowCounter) windowCounts(index uint64) (int64, int64) {
+	slot := c.slots[index%2].Load()
+	if slot == nil || slot.index != index {
+		return 0, 0
+	}
+
+	return slot.first.Load(), slot.second.Load()
+}
@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowCounter(t *testing.T) {
+	c := WindowCounter{Window: time.Minute}
+	start := time.Unix(0, 0).Add(100 * time.Minute)
+
+	c.Add(start, 4, 1)
+	c.Add(start.Add(time.Second), 2, 1)
+	if first, second := c.Counts(start.Add(time.Second)); first != 6 || second != 2 {
+		t.Errorf("expected 6 and 2, got %d and %d", first, second)
+	}
+
+	// Halfway through the next window half of the previous one still counts
+	next := start.Add(time.Minute + time.Minute/2)
+	c.Add(next, 1, 0)
+	if first, second := c.Counts(next); first != 4 || second != 1 {
+		t.Errorf("expected 4 and 1, got %d and %d", first, second)
+	}
+
+	// A late count for a window whose slot moved on is dropped rather than resetting the later window
+	c.Add(start.Add(2*time.Minute), 3, 0)
+	c.Add(start, 100, 100)
+	if first, second := c.Counts(start.Add(2 * time.Minute)); first != 4 || second != 0 {
+		t.Errorf("expected 4 and 0, got %d and %d", first, second)
+	}
+}
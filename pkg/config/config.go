@@ -2,15 +2,48 @@ package config
 
 import (
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
 )
 
 // Config holds the configuration for the rate limiter
 type Config struct {
 	// General settings
-	DefaultLimit  int           `json:"default_limit" yaml:"default_limit"`
+	DefaultLimit  int64         `json:"default_limit" yaml:"default_limit"`
 	DefaultRefill time.Duration `json:"default_refill" yaml:"default_refill"`
-	DefaultBurst  int           `json:"default_burst" yaml:"default_burst"`
+	DefaultBurst  int64         `json:"default_burst" yaml:"default_burst"`
+
+	// MaxDebt is how many tokens a bucket may borrow below zero, refills pay it off first, 0 disables borrowing
+	MaxDebt int64 `json:"max_debt" yaml:"max_debt"`
+
+	// GraceTokens is a budget new keys spend before their balance during GracePeriod, 0 disables it
+	GraceTokens int64         `json:"grace_tokens" yaml:"grace_tokens"`
+	GracePeriod time.Duration `json:"grace_period" yaml:"grace_period"`
+
+	// BurstPoolTokens caps a per-key pool spent when the bucket is short, regaining one credit every BurstPoolRefill, 0 disables it
+	BurstPoolTokens int64         `json:"burst_pool_tokens" yaml:"burst_pool_tokens"`
+	BurstPoolRefill time.Duration `json:"burst_pool_refill" yaml:"burst_pool_refill"`
+
+	// IdempotencyWindow is how long backends remember the request IDs of admitted TakeOnce calls, 0 uses a minute
+	IdempotencyWindow time.Duration `json:"idempotency_window" yaml:"idempotency_window"`
+
+	// KeyActivity makes GetInfo report the recent requests and denials of each key
+	KeyActivity bool `json:"key_activity" yaml:"key_activity"`
+
+	// GlobalLimit and GlobalRefill size a bucket every take also consumes from, capping total throughput
+	// It is shared by the processes using the same backend, 0 disables it
+	// Takes then go through TakeAll to consume from both buckets atomically, and TakeAll spends neither grace tokens
+	// nor burst pools, so it cannot be combined with GraceTokens or BurstPoolTokens
+	GlobalLimit  int64         `json:"global_limit" yaml:"global_limit"`
+	GlobalRefill time.Duration `json:"global_refill" yaml:"global_refill"`
+
+	// Templates are named limits defined once and referenced by name, such as "login-strict" or "search-default"
+	// The limiter registers them as tiers, resolved on every take so a change reaches every key using them
+	Templates map[string]LimitTemplate `json:"templates" yaml:"templates"`
 
 	// Redis settings
 	Redis RedisConfig `json:"redis" yaml:"redis"`
@@ -18,43 +51,167 @@ type Config struct {
 	// In-memory settings
 	InMemory InMemoryConfig `json:"in_memory" yaml:"in_memory"`
 
+	// Logging settings
+	Logging LoggingConfig `json:"logging" yaml:"logging"`
+
 	// Performance settings
 	CleanupInterval time.Duration `json:"cleanup_interval" yaml:"cleanup_interval"`
 	MaxKeys         int           `json:"max_keys" yaml:"max_keys"`
 
+	// Wait settings
+	WaitAgingInterval time.Duration `json:"wait_aging_interval" yaml:"wait_aging_interval"`
+
+	// WaitQueueDepth caps the callers waiting on one key, later callers fail at once, 0 means unlimited
+	WaitQueueDepth int `json:"wait_queue_depth" yaml:"wait_queue_depth"`
+
+	// OperationTimeout bounds every backend call made by the limiter, 0 disables it
+	OperationTimeout time.Duration `json:"operation_timeout" yaml:"operation_timeout"`
+
+	// Health settings
+	HealthCheckTimeout time.Duration `json:"health_check_timeout" yaml:"health_check_timeout"`
+
 	// Monitoring settings
-	EnableMetrics bool `json:"enable_metrics" yaml:"enable_metrics"`
-	EnableLogging bool `json:"enable_logging" yaml:"enable_logging"`
+	EnableMetrics bool   `json:"enable_metrics" yaml:"enable_metrics"`
+	EnableLogging bool   `json:"enable_logging" yaml:"enable_logging"`
+	EnableExpvar  bool   `json:"enable_expvar" yaml:"enable_expvar"`
+	ExpvarName    string `json:"expvar_name" yaml:"expvar_name"`
+
+	// HotKeysCapacity is the number of keys tracked for HotKeys, 0 disables tracking
+	HotKeysCapacity int `json:"hot_keys_capacity" yaml:"hot_keys_capacity"`
+
+	// KeyMetricsLimit is the number of keys labelled individually in per-key metrics, the rest are counted as other
+	// 0 disables per-key metrics
+	KeyMetricsLimit int `json:"key_metrics_limit" yaml:"key_metrics_limit"`
+}
+
+// LimitTemplate holds a named limit
+type LimitTemplate struct {
+	Limit  int64         `json:"limit" yaml:"limit"`
+	Refill time.Duration `json:"refill" yaml:"refill"`
+
+	// Schedule replaces Limit and Refill during windows of the day, evaluated in TimeZone, UTC when empty
+	Schedule []ScheduleConfig `json:"schedule" yaml:"schedule"`
+	TimeZone string           `json:"time_zone" yaml:"time_zone"`
+}
+
+// ScheduleConfig holds a limit that applies during a daily window
+// Start and End are offsets from midnight, a window ending before it starts runs past midnight
+type ScheduleConfig struct {
+	Days   []string      `json:"days" yaml:"days"`
+	Start  time.Duration `json:"start" yaml:"start"`
+	End    time.Duration `json:"end" yaml:"end"`
+	Limit  int64         `json:"limit" yaml:"limit"`
+	Refill time.Duration `json:"refill" yaml:"refill"`
+}
+
+// weekdays maps the day names accepted in schedules to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseWeekday parses a day name such as "mon" or "Monday"
+func ParseWeekday(name string) (time.Weekday, error) {
+	day, ok := weekdays[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown day %q", name)
+	}
+
+	return day, nil
 }
 
 // RedisConfig holds Redis-specific configuration
 type RedisConfig struct {
-	Addr         string        `json:"addr" yaml:"addr"`
-	Password     string        `json:"password" yaml:"password"`
-	DB           int           `json:"db" yaml:"db"`
-	PoolSize     int           `json:"pool_size" yaml:"pool_size"`
-	MinIdleConns int           `json:"min_idle_conns" yaml:"min_idle_conns"`
-	MaxRetries   int           `json:"max_retries" yaml:"max_retries"`
-	Timeout      time.Duration `json:"timeout" yaml:"timeout"`
-	DialTimeout  time.Duration `json:"dial_timeout" yaml:"dial_timeout"`
+	Addr         string `json:"addr" yaml:"addr"`
+	Username     string `json:"username" yaml:"username"`
+	Password     string `json:"password" yaml:"password"`
+	DB           int    `json:"db" yaml:"db"`
+	PoolSize     int    `json:"pool_size" yaml:"pool_size"`
+	MinIdleConns int    `json:"min_idle_conns" yaml:"min_idle_conns"`
+	MaxRetries   int    `json:"max_retries" yaml:"max_retries"`
+
+	// MinRetryBackoff and MaxRetryBackoff bound the jittered backoff between retries, 0 keeps the client defaults
+	MinRetryBackoff time.Duration `json:"min_retry_backoff" yaml:"min_retry_backoff"`
+	MaxRetryBackoff time.Duration `json:"max_retry_backoff" yaml:"max_retry_backoff"`
+
+	Timeout     time.Duration `json:"timeout" yaml:"timeout"`
+	DialTimeout time.Duration `json:"dial_timeout" yaml:"dial_timeout"`
+
+	// ReadReplicas are Redis URLs GetInfo reads are hedged to once the primary has not answered within HedgeDelay
+	ReadReplicas []string      `json:"read_replicas" yaml:"read_replicas"`
+	HedgeDelay   time.Duration `json:"hedge_delay" yaml:"hedge_delay"`
+
+	// KeyHashSecret makes Redis store keys as their HMAC-SHA256 under the secret, empty stores them in plaintext
+	KeyHashSecret string `json:"key_hash_secret" yaml:"key_hash_secret"`
+
+	// KeyPrefix is put before every key stored in Redis, so Keys, dumps and cleanup leave other data in the database alone
+	// Empty keeps the legacy layout of earlier versions, where buckets are stored under their bare keys
+	KeyPrefix string `json:"key_prefix" yaml:"key_prefix"`
+
+	// TLS configures encrypted and mutually authenticated connections
+	TLS RedisTLSConfig `json:"tls" yaml:"tls"`
+}
+
+// RedisTLSConfig holds TLS settings for Redis connections
+type RedisTLSConfig struct {
+	Enabled            bool   `json:"enabled" yaml:"enabled"`
+	CAFile             string `json:"ca_file" yaml:"ca_file"`
+	CertFile           string `json:"cert_file" yaml:"cert_file"`
+	KeyFile            string `json:"key_file" yaml:"key_file"`
+	ServerName         string `json:"server_name" yaml:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
 }
 
 // InMemoryConfig holds in-memory backend configuration
 type InMemoryConfig struct {
 	CleanupInterval time.Duration `json:"cleanup_interval" yaml:"cleanup_interval"`
 	MaxKeys         int           `json:"max_keys" yaml:"max_keys"`
+
+	// ShardCount is the number of shards keys are split across to reduce lock contention
+	ShardCount int `json:"shard_count" yaml:"shard_count"`
+
+	// SnapshotPath is the file bucket state is saved to on shutdown and loaded from on start, empty disables it
+	SnapshotPath string `json:"snapshot_path" yaml:"snapshot_path"`
+
+	// RefillWheelTick is the resolution of the timer wheel that wakes waiters at refill instants, 0 disables it
+	RefillWheelTick time.Duration `json:"refill_wheel_tick" yaml:"refill_wheel_tick"`
+
+	// InlineCleanup cleans up from within calls instead of a background goroutine, for WASM and serverless runtimes
+	InlineCleanup bool `json:"inline_cleanup" yaml:"inline_cleanup"`
+
+	// MaxMemoryBytes caps the approximate memory used by buckets, evicting the least recently refilled, 0 means unlimited
+	MaxMemoryBytes int64 `json:"max_memory_bytes" yaml:"max_memory_bytes"`
+
+	// OptimisticBuckets keeps each bucket as immutable versioned values swapped by CAS, for read-heavy workloads
+	OptimisticBuckets bool `json:"optimistic_buckets" yaml:"optimistic_buckets"`
+}
+
+// LoggingConfig holds per-event log sampling configuration
+// Each limit is the maximum number of log lines per second for that event, 0 means unlimited
+type LoggingConfig struct {
+	DenialsPerSecond       int `json:"denials_per_second" yaml:"denials_per_second"`
+	ErrorsPerSecond        int `json:"errors_per_second" yaml:"errors_per_second"`
+	ConfigChangesPerSecond int `json:"config_changes_per_second" yaml:"config_changes_per_second"`
 }
 
 // DefaultConfig returns a default configuration
 func DefaultConfig() *Config {
 	return &Config{
-		DefaultLimit:    100,
-		DefaultRefill:   time.Second,
-		DefaultBurst:    10,
-		CleanupInterval: 5 * time.Minute,
-		MaxKeys:         10000,
-		EnableMetrics:   true,
-		EnableLogging:   true,
+		DefaultLimit:       100,
+		DefaultRefill:      time.Second,
+		DefaultBurst:       10,
+		CleanupInterval:    5 * time.Minute,
+		MaxKeys:            10000,
+		WaitAgingInterval:  5 * time.Second,
+		HealthCheckTimeout: 2 * time.Second,
+		EnableMetrics:      true,
+		EnableLogging:      true,
+		ExpvarName:         "ratelimiter",
 		Redis: RedisConfig{
 			Addr:         "localhost:6379",
 			PoolSize:     10,
@@ -66,6 +223,11 @@ func DefaultConfig() *Config {
 		InMemory: InMemoryConfig{
 			CleanupInterval: 5 * time.Minute,
 			MaxKeys:         10000,
+			ShardCount:      32,
+		},
+		Logging: LoggingConfig{
+			DenialsPerSecond: 10,
+			ErrorsPerSecond:  10,
 		},
 	}
 }
@@ -84,6 +246,89 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("default_burst must be positive, got %d", c.DefaultBurst)
 	}
 
+	if c.MaxDebt < 0 {
+		return fmt.Errorf("max_debt must not be negative, got %d", c.MaxDebt)
+	}
+
+	if c.DefaultLimit > backend.MaxLimit-c.MaxDebt {
+		return fmt.Errorf("default_limit plus max_debt must not exceed %d, got %d and %d", int64(backend.MaxLimit), c.DefaultLimit, c.MaxDebt)
+	}
+
+	if c.GraceTokens < 0 {
+		return fmt.Errorf("grace_tokens must not be negative, got %d", c.GraceTokens)
+	}
+
+	if c.GracePeriod < 0 {
+		return fmt.Errorf("grace_period must not be negative, got %v", c.GracePeriod)
+	}
+
+	if (c.GraceTokens == 0) != (c.GracePeriod == 0) {
+		return fmt.Errorf("grace_tokens and grace_period must be set together")
+	}
+
+	if c.BurstPoolTokens < 0 {
+		return fmt.Errorf("burst_pool_tokens must not be negative, got %d", c.BurstPoolTokens)
+	}
+
+	if c.BurstPoolRefill < 0 {
+		return fmt.Errorf("burst_pool_refill must not be negative, got %v", c.BurstPoolRefill)
+	}
+
+	if (c.BurstPoolTokens == 0) != (c.BurstPoolRefill == 0) {
+		return fmt.Errorf("burst_pool_tokens and burst_pool_refill must be set together")
+	}
+
+	if c.IdempotencyWindow < 0 {
+		return fmt.Errorf("idempotency_window must not be negative, got %v", c.IdempotencyWindow)
+	}
+
+	if c.GlobalLimit < 0 {
+		return fmt.Errorf("global_limit must not be negative, got %d", c.GlobalLimit)
+	}
+
+	if c.GlobalRefill < 0 {
+		return fmt.Errorf("global_refill must not be negative, got %v", c.GlobalRefill)
+	}
+
+	if (c.GlobalLimit == 0) != (c.GlobalRefill == 0) {
+		return fmt.Errorf("global_limit and global_refill must be set together")
+	}
+
+	if c.GlobalLimit > 0 && (c.GraceTokens > 0 || c.BurstPoolTokens > 0) {
+		return fmt.Errorf("global_limit cannot be combined with grace_tokens or burst_pool_tokens")
+	}
+
+	names := make([]string, 0, len(c.Templates))
+	for name := range c.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		template := c.Templates[name]
+		if name == "" {
+			return fmt.Errorf("templates must not have an empty name")
+		}
+
+		if template.Limit <= 0 {
+			return fmt.Errorf("templates.%s.limit must be positive, got %d", name, template.Limit)
+		}
+
+		if template.Refill <= 0 {
+			return fmt.Errorf("templates.%s.refill must be positive, got %v", name, template.Refill)
+		}
+
+		if _, err := time.LoadLocation(template.TimeZone); err != nil {
+			return fmt.Errorf("templates.%s.time_zone is invalid: %w", name, err)
+		}
+
+		for i, window := range template.Schedule {
+			if err := window.validate(); err != nil {
+				return fmt.Errorf("templates.%s.schedule[%d].%w", name, i, err)
+			}
+		}
+	}
+
 	if c.CleanupInterval <= 0 {
 		return fmt.Errorf("cleanup_interval must be positive, got %v", c.CleanupInterval)
 	}
@@ -92,9 +337,202 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("max_keys must be positive, got %d", c.MaxKeys)
 	}
 
+	if c.EnableExpvar && c.ExpvarName == "" {
+		return fmt.Errorf("expvar_name must not be empty when enable_expvar is set")
+	}
+
+	if c.Logging.DenialsPerSecond < 0 {
+		return fmt.Errorf("logging.denials_per_second must not be negative, got %d", c.Logging.DenialsPerSecond)
+	}
+
+	if c.Logging.ErrorsPerSecond < 0 {
+		return fmt.Errorf("logging.errors_per_second must not be negative, got %d", c.Logging.ErrorsPerSecond)
+	}
+
+	if c.Logging.ConfigChangesPerSecond < 0 {
+		return fmt.Errorf("logging.config_changes_per_second must not be negative, got %d", c.Logging.ConfigChangesPerSecond)
+	}
+
+	if c.InMemory.ShardCount < 0 {
+		return fmt.Errorf("in_memory.shard_count must not be negative, got %d", c.InMemory.ShardCount)
+	}
+
+	if c.InMemory.RefillWheelTick < 0 {
+		return fmt.Errorf("in_memory.refill_wheel_tick must not be negative, got %v", c.InMemory.RefillWheelTick)
+	}
+
+	if c.InMemory.InlineCleanup && c.InMemory.RefillWheelTick > 0 {
+		return fmt.Errorf("in_memory.inline_cleanup cannot be combined with in_memory.refill_wheel_tick, got %v", c.InMemory.RefillWheelTick)
+	}
+
+	if c.InMemory.MaxMemoryBytes < 0 {
+		return fmt.Errorf("in_memory.max_memory_bytes must not be negative, got %d", c.InMemory.MaxMemoryBytes)
+	}
+
+	if c.Redis.PoolSize < 0 {
+		return fmt.Errorf("redis.pool_size must not be negative, got %d", c.Redis.PoolSize)
+	}
+
+	if c.Redis.MinIdleConns < 0 {
+		return fmt.Errorf("redis.min_idle_conns must not be negative, got %d", c.Redis.MinIdleConns)
+	}
+
+	if c.Redis.MaxRetries < -1 {
+		return fmt.Errorf("redis.max_retries must be -1 or more, got %d", c.Redis.MaxRetries)
+	}
+
+	if c.Redis.MinRetryBackoff < -1 || c.Redis.MaxRetryBackoff < -1 {
+		return fmt.Errorf("redis.min_retry_backoff and redis.max_retry_backoff must be -1 or more")
+	}
+
+	if c.Redis.MinRetryBackoff > 0 && c.Redis.MaxRetryBackoff > 0 && c.Redis.MinRetryBackoff > c.Redis.MaxRetryBackoff {
+		return fmt.Errorf("redis.min_retry_backoff must not exceed redis.max_retry_backoff, got %v and %v", c.Redis.MinRetryBackoff, c.Redis.MaxRetryBackoff)
+	}
+
+	if c.Redis.Timeout < 0 {
+		return fmt.Errorf("redis.timeout must not be negative, got %v", c.Redis.Timeout)
+	}
+
+	if c.Redis.DialTimeout < 0 {
+		return fmt.Errorf("redis.dial_timeout must not be negative, got %v", c.Redis.DialTimeout)
+	}
+
+	if n := len(c.Redis.KeyHashSecret); n > 0 && n < backend.MinKeyHashSecret {
+		return fmt.Errorf("redis.key_hash_secret must be at least %d bytes, got %d", backend.MinKeyHashSecret, n)
+	}
+
+	if strings.ContainsAny(c.Redis.KeyPrefix, "{}") {
+		return fmt.Errorf("redis.key_prefix must not contain braces, got %q", c.Redis.KeyPrefix)
+	}
+
+	if (c.Redis.TLS.CertFile == "") != (c.Redis.TLS.KeyFile == "") {
+		return fmt.Errorf("redis.tls.cert_file and redis.tls.key_file must be set together")
+	}
+
+	if c.Redis.Username != "" && c.Redis.Password == "" {
+		return fmt.Errorf("redis.username requires redis.password")
+	}
+
+	if c.Redis.HedgeDelay < 0 {
+		return fmt.Errorf("redis.hedge_delay must not be negative, got %v", c.Redis.HedgeDelay)
+	}
+
+	if c.Redis.HedgeDelay > 0 && len(c.Redis.ReadReplicas) == 0 {
+		return fmt.Errorf("redis.hedge_delay requires redis.read_replicas")
+	}
+
+	for _, replica := range c.Redis.ReadReplicas {
+		if replica == "" {
+			return fmt.Errorf("redis.read_replicas must not contain empty URLs")
+		}
+	}
+
+	if c.HotKeysCapacity < 0 {
+		return fmt.Errorf("hot_keys_capacity must not be negative, got %d", c.HotKeysCapacity)
+	}
+
+	if c.KeyMetricsLimit < 0 {
+		return fmt.Errorf("key_metrics_limit must not be negative, got %d", c.KeyMetricsLimit)
+	}
+
+	if c.OperationTimeout < 0 {
+		return fmt.Errorf("operation_timeout must not be negative, got %v", c.OperationTimeout)
+	}
+
+	if c.HealthCheckTimeout < 0 {
+		return fmt.Errorf("health_check_timeout must not be negative, got %v", c.HealthCheckTimeout)
+	}
+
+	if c.WaitAgingInterval < 0 {
+		return fmt.Errorf("wait_aging_interval must not be negative, got %v", c.WaitAgingInterval)
+	}
+
+	if c.WaitQueueDepth < 0 {
+		return fmt.Errorf("wait_queue_depth must not be negative, got %d", c.WaitQueueDepth)
+	}
+
+	return nil
+}
+
+// validate validates the schedule window, naming the offending field first
+func (s *ScheduleConfig) validate() error {
+	if s.Limit <= 0 {
+		return fmt.Errorf("limit must be positive, got %d", s.Limit)
+	}
+
+	if s.Refill <= 0 {
+		return fmt.Errorf("refill must be positive, got %v", s.Refill)
+	}
+
+	if s.Start < 0 || s.Start >= 24*time.Hour {
+		return fmt.Errorf("start must be within the day, got %v", s.Start)
+	}
+
+	if s.End < 0 || s.End > 24*time.Hour || s.End == s.Start {
+		return fmt.Errorf("end must be within the day and differ from start, got %v", s.End)
+	}
+
+	for _, day := range s.Days {
+		if _, err := ParseWeekday(day); err != nil {
+			return fmt.Errorf("days: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// BackendOptions returns backend options carrying the defaults, in-memory and Redis settings of the config
+func (c *Config) BackendOptions() *backend.Options {
+	options := &backend.Options{
+		DefaultLimit:    c.DefaultLimit,
+		DefaultRefill:   c.DefaultRefill,
+		DefaultBurst:    c.DefaultBurst,
+		MaxKeys:         c.MaxKeys,
+		CleanupInterval: c.CleanupInterval,
+		MaxDebt:         c.MaxDebt,
+		GraceTokens:     c.GraceTokens,
+		GracePeriod:     c.GracePeriod,
+		BurstPoolTokens: c.BurstPoolTokens,
+		BurstPoolRefill: c.BurstPoolRefill,
+
+		IdempotencyWindow: c.IdempotencyWindow,
+		KeyActivity:       c.KeyActivity,
+
+		ShardCount:        c.InMemory.ShardCount,
+		SnapshotPath:      c.InMemory.SnapshotPath,
+		RefillWheelTick:   c.InMemory.RefillWheelTick,
+		InlineCleanup:     c.InMemory.InlineCleanup,
+		MaxMemoryBytes:    c.InMemory.MaxMemoryBytes,
+		OptimisticBuckets: c.InMemory.OptimisticBuckets,
+
+		PoolSize:         c.Redis.PoolSize,
+		MinIdleConns:     c.Redis.MinIdleConns,
+		MaxRetries:       c.Redis.MaxRetries,
+		MinRetryBackoff:  c.Redis.MinRetryBackoff,
+		MaxRetryBackoff:  c.Redis.MaxRetryBackoff,
+		DialTimeout:      c.Redis.DialTimeout,
+		OperationTimeout: c.Redis.Timeout,
+		KeyHashSecret:    []byte(c.Redis.KeyHashSecret),
+		KeyPrefix:        c.Redis.KeyPrefix,
+		Username:         c.Redis.Username,
+		Password:         c.Redis.Password,
+		ReadReplicas:     slices.Clone(c.Redis.ReadReplicas),
+		HedgeDelay:       c.Redis.HedgeDelay,
+	}
+
+	if c.Redis.TLS.Enabled {
+		options.TLS = &backend.TLSOptions{
+			CAFile:             c.Redis.TLS.CAFile,
+			CertFile:           c.Redis.TLS.CertFile,
+			KeyFile:            c.Redis.TLS.KeyFile,
+			ServerName:         c.Redis.TLS.ServerName,
+			InsecureSkipVerify: c.Redis.TLS.InsecureSkipVerify,
+		}
+	}
+
+	return options
+}
+
 // WithRedis returns a new config with Redis settings
 func (c *Config) WithRedis(addr string) *Config {
 	newConfig := *c
@@ -111,7 +549,7 @@ func (c *Config) WithInMemory(cleanupInterval time.Duration, maxKeys int) *Confi
 }
 
 // WithDefaults returns a new config with custom defaults
-func (c *Config) WithDefaults(limit int, refill time.Duration, burst int) *Config {
+func (c *Config) WithDefaults(limit int64, refill time.Duration, burst int64) *Config {
 	newConfig := *c
 	newConfig.DefaultLimit = limit
 	newConfig.DefaultRefill = refill
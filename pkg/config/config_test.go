@@ -3,6 +3,8 @@ package config
 import (
 	"testing"
 	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -28,6 +30,14 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("expected MaxKeys to be 10000, got %d", config.MaxKeys)
 	}
 
+	if config.HealthCheckTimeout != 2*time.Second {
+		t.Errorf("expected HealthCheckTimeout to be 2s, got %v", config.HealthCheckTimeout)
+	}
+
+	if config.WaitAgingInterval != 5*time.Second {
+		t.Errorf("expected WaitAgingInterval to be 5s, got %v", config.WaitAgingInterval)
+	}
+
 	if !config.EnableMetrics {
 		t.Error("expected EnableMetrics to be true")
 	}
@@ -36,6 +46,18 @@ func TestDefaultConfig(t *testing.T) {
 		t.Error("expected EnableLogging to be true")
 	}
 
+	if config.EnableExpvar {
+		t.Error("expected EnableExpvar to be false")
+	}
+
+	if config.ExpvarName != "ratelimiter" {
+		t.Errorf("expected ExpvarName to be 'ratelimiter', got %s", config.ExpvarName)
+	}
+
+	if config.HotKeysCapacity != 0 {
+		t.Errorf("expected HotKeysCapacity to be 0, got %d", config.HotKeysCapacity)
+	}
+
 	// Test Redis config defaults
 	if config.Redis.Addr != "localhost:6379" {
 		t.Errorf("expected Redis.Addr to be 'localhost:6379', got %s", config.Redis.Addr)
@@ -69,6 +91,27 @@ func TestDefaultConfig(t *testing.T) {
 	if config.InMemory.MaxKeys != 10000 {
 		t.Errorf("expected InMemory.MaxKeys to be 10000, got %d", config.InMemory.MaxKeys)
 	}
+
+	if config.InMemory.ShardCount != 32 {
+		t.Errorf("expected InMemory.ShardCount to be 32, got %d", config.InMemory.ShardCount)
+	}
+
+	if config.InMemory.SnapshotPath != "" {
+		t.Errorf("expected InMemory.SnapshotPath to be empty, got %s", config.InMemory.SnapshotPath)
+	}
+
+	// Test Logging config defaults
+	if config.Logging.DenialsPerSecond != 10 {
+		t.Errorf("expected Logging.DenialsPerSecond to be 10, got %d", config.Logging.DenialsPerSecond)
+	}
+
+	if config.Logging.ErrorsPerSecond != 10 {
+		t.Errorf("expected Logging.ErrorsPerSecond to be 10, got %d", config.Logging.ErrorsPerSecond)
+	}
+
+	if config.Logging.ConfigChangesPerSecond != 0 {
+		t.Errorf("expected Logging.ConfigChangesPerSecond to be 0, got %d", config.Logging.ConfigChangesPerSecond)
+	}
 }
 
 func TestConfigValidation(t *testing.T) {
@@ -137,6 +180,298 @@ func TestConfigValidation(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "expvar enabled without name",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				EnableExpvar:    true,
+			},
+			expectError: true,
+		},
+		{
+			name: "negative log sampling",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Logging:         LoggingConfig{DenialsPerSecond: -1},
+			},
+			expectError: true,
+		},
+		{
+			name: "negative shard count",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				InMemory:        InMemoryConfig{ShardCount: -1},
+			},
+			expectError: true,
+		},
+		{
+			name: "negative key metrics limit",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				KeyMetricsLimit: -1,
+			},
+			expectError: true,
+		},
+		{
+			name: "min retry backoff above max",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Redis:           RedisConfig{MinRetryBackoff: time.Second, MaxRetryBackoff: time.Millisecond},
+			},
+			expectError: true,
+		},
+		{
+			name: "negative max memory",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				InMemory:        InMemoryConfig{MaxMemoryBytes: -1},
+			},
+			expectError: true,
+		},
+		{
+			name: "negative refill wheel tick",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				InMemory:        InMemoryConfig{RefillWheelTick: -1},
+			},
+			expectError: true,
+		},
+		{
+			name: "inline cleanup with refill wheel",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				InMemory:        InMemoryConfig{InlineCleanup: true, RefillWheelTick: time.Millisecond},
+			},
+			expectError: true,
+		},
+		{
+			name: "negative redis timeout",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Redis:           RedisConfig{Timeout: -1},
+			},
+			expectError: true,
+		},
+		{
+			name: "short key hash secret",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Redis:           RedisConfig{KeyHashSecret: "short"},
+			},
+			expectError: true,
+		},
+		{
+			name: "limit beyond the largest bucket",
+			config: &Config{
+				DefaultLimit:    backend.MaxLimit,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				MaxDebt:         1,
+			},
+			expectError: true,
+		},
+		{
+			name: "key prefix with braces",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Redis:           RedisConfig{KeyPrefix: "{tenant}:"},
+			},
+			expectError: true,
+		},
+		{
+			name: "negative max debt",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				MaxDebt:         -1,
+			},
+			expectError: true,
+		},
+		{
+			name: "grace tokens without period",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				GraceTokens:     10,
+			},
+			expectError: true,
+		},
+		{
+			name: "burst pool without refill",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				BurstPoolTokens: 10,
+			},
+			expectError: true,
+		},
+		{
+			name: "template without refill",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Templates:       map[string]LimitTemplate{"login-strict": {Limit: 5}},
+			},
+			expectError: true,
+		},
+		{
+			name: "template without limit",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Templates:       map[string]LimitTemplate{"login-strict": {Refill: time.Second}},
+			},
+			expectError: true,
+		},
+		{
+			name: "template with unknown time zone",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Templates:       map[string]LimitTemplate{"batch": {Limit: 5, Refill: time.Second, TimeZone: "Mars/Olympus"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "template schedule with unknown day",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Templates: map[string]LimitTemplate{"batch": {Limit: 5, Refill: time.Second, Schedule: []ScheduleConfig{
+					{Days: []string{"someday"}, Start: 0, End: time.Hour, Limit: 10, Refill: time.Second},
+				}}},
+			},
+			expectError: true,
+		},
+		{
+			name: "template schedule without end",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Templates: map[string]LimitTemplate{"batch": {Limit: 5, Refill: time.Second, Schedule: []ScheduleConfig{
+					{Limit: 10, Refill: time.Second},
+				}}},
+			},
+			expectError: true,
+		},
+		{
+			name: "username without password",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Redis:           RedisConfig{Username: "limiter"},
+			},
+			expectError: true,
+		},
+		{
+			name: "hedge delay without read replicas",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Redis:           RedisConfig{HedgeDelay: 20 * time.Millisecond},
+			},
+			expectError: true,
+		},
+		{
+			name: "TLS key without certificate",
+			config: &Config{
+				DefaultLimit:    100,
+				DefaultRefill:   time.Second,
+				DefaultBurst:    10,
+				CleanupInterval: 5 * time.Minute,
+				MaxKeys:         10000,
+				Redis:           RedisConfig{TLS: RedisTLSConfig{Enabled: true, KeyFile: "client.key"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "negative operation timeout",
+			config: &Config{
+				DefaultLimit:     100,
+				DefaultRefill:    time.Second,
+				DefaultBurst:     10,
+				CleanupInterval:  5 * time.Minute,
+				MaxKeys:          10000,
+				OperationTimeout: -1,
+			},
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -197,9 +532,9 @@ func TestConfigWithInMemory(t *testing.T) {
 
 func TestConfigWithDefaults(t *testing.T) {
 	config := DefaultConfig()
-	newLimit := 200
+	newLimit := int64(200)
 	newRefill := 2 * time.Second
-	newBurst := 20
+	newBurst := int64(20)
 	newConfig := config.WithDefaults(newLimit, newRefill, newBurst)
 
 	if newConfig.DefaultLimit != newLimit {
@@ -241,6 +576,120 @@ func TestRedisConfig(t *testing.T) {
 	}
 }
 
+func TestConfigBackendOptions(t *testing.T) {
+	config := DefaultConfig()
+	config.Redis.PoolSize = 20
+	config.Redis.Timeout = 250 * time.Millisecond
+	config.Redis.KeyHashSecret = "0123456789abcdef"
+	config.Redis.KeyPrefix = "ratelimiter:"
+	config.Redis.MinRetryBackoff = 10 * time.Millisecond
+	config.Redis.MaxRetryBackoff = time.Second
+
+	if options := config.BackendOptions(); options.TLS != nil {
+		t.Errorf("expected TLS to be off unless enabled, got %+v", options.TLS)
+	}
+
+	config.Redis.TLS = RedisTLSConfig{Enabled: true, CAFile: "ca.crt", ServerName: "redis.internal"}
+	config.MaxDebt = 5
+	config.GraceTokens = 50
+	config.GracePeriod = time.Hour
+	config.BurstPoolTokens = 20
+	config.BurstPoolRefill = time.Minute
+	config.IdempotencyWindow = 30 * time.Second
+	config.KeyActivity = true
+	config.Redis.Username = "limiter"
+	config.Redis.Password = "s3cret"
+	config.Redis.ReadReplicas = []string{"redis://replica.internal:6379"}
+	config.Redis.HedgeDelay = 20 * time.Millisecond
+	config.InMemory.OptimisticBuckets = true
+	config.InMemory.InlineCleanup = true
+
+	options := config.BackendOptions()
+	if err := options.Validate(); err != nil {
+		t.Fatalf("expected valid backend options, got %v", err)
+	}
+
+	if options.DefaultLimit != config.DefaultLimit {
+		t.Errorf("expected DefaultLimit to be %d, got %d", config.DefaultLimit, options.DefaultLimit)
+	}
+
+	if options.PoolSize != 20 {
+		t.Errorf("expected PoolSize to be 20, got %d", options.PoolSize)
+	}
+
+	if options.MinIdleConns != 5 {
+		t.Errorf("expected MinIdleConns to be 5, got %d", options.MinIdleConns)
+	}
+
+	if options.MaxRetries != 3 {
+		t.Errorf("expected MaxRetries to be 3, got %d", options.MaxRetries)
+	}
+
+	if options.MinRetryBackoff != 10*time.Millisecond || options.MaxRetryBackoff != time.Second {
+		t.Errorf("expected retry backoffs of 10ms and 1s, got %v and %v", options.MinRetryBackoff, options.MaxRetryBackoff)
+	}
+
+	if options.DialTimeout != 5*time.Second {
+		t.Errorf("expected DialTimeout to be 5s, got %v", options.DialTimeout)
+	}
+
+	if options.OperationTimeout != 250*time.Millisecond {
+		t.Errorf("expected OperationTimeout to be 250ms, got %v", options.OperationTimeout)
+	}
+
+	if string(options.KeyHashSecret) != "0123456789abcdef" {
+		t.Errorf("expected KeyHashSecret to be carried over, got %q", options.KeyHashSecret)
+	}
+
+	if options.KeyPrefix != "ratelimiter:" {
+		t.Errorf("expected KeyPrefix to be carried over, got %q", options.KeyPrefix)
+	}
+
+	if options.TLS == nil || options.TLS.CAFile != "ca.crt" || options.TLS.ServerName != "redis.internal" {
+		t.Errorf("expected TLS to be carried over, got %+v", options.TLS)
+	}
+
+	if options.MaxDebt != 5 {
+		t.Errorf("expected MaxDebt to be 5, got %d", options.MaxDebt)
+	}
+
+	if options.GraceTokens != 50 || options.GracePeriod != time.Hour {
+		t.Errorf("expected grace to be carried over, got %d over %v", options.GraceTokens, options.GracePeriod)
+	}
+
+	if options.BurstPoolTokens != 20 || options.BurstPoolRefill != time.Minute {
+		t.Errorf("expected burst pool to be carried over, got %d every %v", options.BurstPoolTokens, options.BurstPoolRefill)
+	}
+
+	if options.IdempotencyWindow != 30*time.Second {
+		t.Errorf("expected IdempotencyWindow to be 30s, got %v", options.IdempotencyWindow)
+	}
+
+	if !options.KeyActivity {
+		t.Error("expected KeyActivity to be carried over")
+	}
+
+	if options.Username != "limiter" || options.Password != "s3cret" {
+		t.Errorf("expected credentials to be carried over, got %q", options.Username)
+	}
+
+	if len(options.ReadReplicas) != 1 || options.HedgeDelay != 20*time.Millisecond {
+		t.Errorf("expected hedged reads to be carried over, got %v after %v", options.ReadReplicas, options.HedgeDelay)
+	}
+
+	if options.ShardCount != 32 {
+		t.Errorf("expected ShardCount to be 32, got %d", options.ShardCount)
+	}
+
+	if !options.OptimisticBuckets {
+		t.Error("expected OptimisticBuckets to be carried over")
+	}
+
+	if !options.InlineCleanup {
+		t.Error("expected InlineCleanup to be carried over")
+	}
+}
+
 func TestInMemoryConfig(t *testing.T) {
 	config := DefaultConfig()
 
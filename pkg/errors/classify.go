@@ -0,0 +1,86 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Error classes used to label failures in metrics and logs
+const (
+	ClassTimeout    = "timeout"
+	ClassCanceled   = "canceled"
+	ClassConnection = "connection"
+	ClassScript     = "script"
+	ClassValidation = "validation"
+	ClassServer     = "server"
+	ClassClosed     = "closed"
+	ClassBackend    = "backend"
+	ClassUnknown    = "unknown"
+)
+
+// serverError matches errors replied by a server, such as Redis error replies
+type serverError interface {
+	RedisError()
+}
+
+// Classify returns the class of an error, looking through wrapped errors
+// A BackendError is classified by its cause, and one whose cause says nothing more is a backend error
+// It returns an empty string for a nil error
+func Classify(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var validationErr *ValidationError
+	if stderrors.As(err, &validationErr) {
+		return ClassValidation
+	}
+
+	var timeoutErr *TimeoutError
+	if stderrors.As(err, &timeoutErr) || stderrors.Is(err, context.DeadlineExceeded) {
+		return ClassTimeout
+	}
+
+	if stderrors.Is(err, context.Canceled) {
+		return ClassCanceled
+	}
+
+	var netErr net.Error
+	if stderrors.As(err, &netErr) && netErr.Timeout() {
+		return ClassTimeout
+	}
+
+	var replyErr serverError
+	if stderrors.As(err, &replyErr) {
+		msg := strings.ToLower(err.Error())
+		if strings.Contains(msg, "noscript") || strings.Contains(msg, "script") {
+			return ClassScript
+		}
+		return ClassServer
+	}
+
+	if stderrors.Is(err, ErrLimiterClosed) || stderrors.Is(err, ErrBackendClosed) {
+		return ClassClosed
+	}
+
+	var opErr *net.OpError
+	if stderrors.As(err, &opErr) ||
+		stderrors.Is(err, io.EOF) ||
+		stderrors.Is(err, io.ErrUnexpectedEOF) ||
+		stderrors.Is(err, syscall.ECONNREFUSED) ||
+		stderrors.Is(err, syscall.ECONNRESET) ||
+		stderrors.Is(err, syscall.EPIPE) {
+		return ClassConnection
+	}
+
+	var backendErr *BackendError
+	if stderrors.As(err, &backendErr) {
+		return ClassBackend
+	}
+
+	return ClassUnknown
+}
@@ -0,0 +1,50 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+// redisReply mimics a Redis error reply
+type redisReply string
+
+func (e redisReply) Error() string { return string(e) }
+func (e redisReply) RedisError()   {}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"nil", nil, ""},
+		{"validation", Wrap(ErrInvalidKey, "key cannot be empty"), ClassValidation},
+		{"timeout error", ErrTimeout, ClassTimeout},
+		{"deadline exceeded", Wrap(context.DeadlineExceeded, "context cancelled"), ClassTimeout},
+		{"canceled", Wrap(context.Canceled, "context cancelled"), ClassCanceled},
+		{"net timeout", &net.DNSError{IsTimeout: true}, ClassTimeout},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ClassConnection},
+		{"connection reset", Wrap(syscall.ECONNRESET, "read"), ClassConnection},
+		{"eof", Wrap(io.EOF, "failed to execute Redis script"), ClassConnection},
+		{"backend unavailable", Wrap(ErrBackendUnavailable, "malformed bucket"), ClassBackend},
+		{"backend closed", Wrap(ErrBackendClosed, "take"), ClassClosed},
+		{"limiter closed", ErrLimiterClosed, ClassClosed},
+		{"backend error with cause", &BackendError{Message: "failed", Cause: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, ClassConnection},
+		{"noscript", Wrap(redisReply("NOSCRIPT No matching script"), "evalsha"), ClassScript},
+		{"script error", redisReply("ERR Error running script (call to f_abc)"), ClassScript},
+		{"server error", redisReply("OOM command not allowed"), ClassServer},
+		{"unknown", errors.New("something else"), ClassUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.expected {
+				t.Errorf("expected class %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
 	"time"
 )
@@ -12,6 +13,9 @@ var (
 	ErrInvalidKey         = &ValidationError{Message: "invalid key provided"}
 	ErrBackendUnavailable = &BackendError{Message: "backend service unavailable"}
 	ErrTimeout            = &TimeoutError{Message: "operation timed out"}
+	ErrLimiterClosed      = &BackendError{Message: "rate limiter is closed"}
+	ErrBackendClosed      = &BackendError{Message: "backend is closed"}
+	ErrLeaseLost          = &BackendError{Message: "lease lost"}
 )
 
 // RateLimitError represents an error when the rate limit is exceeded
@@ -29,10 +33,10 @@ func (e *RateLimitError) Error() string {
 	return e.Message
 }
 
-// IsRateLimitError checks if the error is a RateLimitError
+// IsRateLimitError checks if the error is or wraps a RateLimitError
 func IsRateLimitError(err error) bool {
-	_, ok := err.(*RateLimitError)
-	return ok
+	var rateLimitErr *RateLimitError
+	return stderrors.As(err, &rateLimitErr)
 }
 
 // ValidationError represents validation errors
@@ -77,6 +81,11 @@ func (e *BackendError) Unwrap() error {
 	return e.Cause
 }
 
+// Is reports ErrLimiterClosed and ErrBackendClosed as ErrBackendUnavailable, since nothing is left to serve calls
+func (e *BackendError) Is(target error) bool {
+	return (e == ErrLimiterClosed || e == ErrBackendClosed) && target == ErrBackendUnavailable
+}
+
 // IsBackendError checks if the error is a BackendError
 func IsBackendError(err error) bool {
 	_, ok := err.(*BackendError)
@@ -84,22 +93,55 @@ func IsBackendError(err error) bool {
 }
 
 // TimeoutError represents timeout errors
+// Op names the operation that ran out of time and Cause holds the error it failed with
 type TimeoutError struct {
 	Message string
+	Op      string
 	Timeout time.Duration
+	Cause   error
 }
 
 func (e *TimeoutError) Error() string {
-	if e.Timeout > 0 {
+	switch {
+	case e.Op != "" && e.Timeout > 0:
+		return fmt.Sprintf("%s: op=%s, timeout=%v", e.Message, e.Op, e.Timeout)
+	case e.Op != "":
+		return fmt.Sprintf("%s: op=%s", e.Message, e.Op)
+	case e.Timeout > 0:
 		return fmt.Sprintf("%s: timeout=%v", e.Message, e.Timeout)
 	}
 	return e.Message
 }
 
-// IsTimeoutError checks if the error is a TimeoutError
+// Unwrap returns the underlying cause error
+func (e *TimeoutError) Unwrap() error {
+	return e.Cause
+}
+
+// IsTimeoutError checks if the error is or wraps a TimeoutError
 func IsTimeoutError(err error) bool {
-	_, ok := err.(*TimeoutError)
-	return ok
+	var timeoutErr *TimeoutError
+	return stderrors.As(err, &timeoutErr)
+}
+
+// QueueFullError is returned by Wait when the queue of waiters for a key is at its maximum depth
+type QueueFullError struct {
+	Message string
+	Key     string
+	Depth   int
+}
+
+func (e *QueueFullError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("%s: key=%s, depth=%d", e.Message, e.Key, e.Depth)
+	}
+	return e.Message
+}
+
+// IsQueueFullError checks if the error is or wraps a QueueFullError
+func IsQueueFullError(err error) bool {
+	var queueErr *QueueFullError
+	return stderrors.As(err, &queueErr)
 }
 
 // Wrap wraps an error with additional context
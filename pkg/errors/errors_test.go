@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -40,6 +41,10 @@ func TestIsRateLimitError(t *testing.T) {
 		t.Error("IsRateLimitError should return false for regular error")
 	}
 
+	if !IsRateLimitError(Wrap(rateLimitErr, "round trip")) {
+		t.Error("IsRateLimitError should return true for a wrapped RateLimitError")
+	}
+
 	if IsRateLimitError(nil) {
 		t.Error("IsRateLimitError should return false for nil")
 	}
@@ -172,6 +177,21 @@ func TestTimeoutError(t *testing.T) {
 	if err.Error() != expectedMsg {
 		t.Errorf("expected error message '%s', got '%s'", expectedMsg, err.Error())
 	}
+
+	// Test with operation and cause
+	err = &TimeoutError{
+		Message: "operation timed out",
+		Op:      "take",
+		Timeout: timeout,
+		Cause:   context.DeadlineExceeded,
+	}
+	expectedMsg = "operation timed out: op=take, timeout=5s"
+	if err.Error() != expectedMsg {
+		t.Errorf("expected error message '%s', got '%s'", expectedMsg, err.Error())
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected TimeoutError to unwrap to its cause")
+	}
 }
 
 func TestIsTimeoutError(t *testing.T) {
@@ -186,11 +206,32 @@ func TestIsTimeoutError(t *testing.T) {
 		t.Error("IsTimeoutError should return false for regular error")
 	}
 
+	if !IsTimeoutError(Wrap(timeoutErr, "failed to take tokens")) {
+		t.Error("IsTimeoutError should return true for a wrapped TimeoutError")
+	}
+
 	if IsTimeoutError(nil) {
 		t.Error("IsTimeoutError should return false for nil")
 	}
 }
 
+func TestQueueFullError(t *testing.T) {
+	err := &QueueFullError{Message: "wait queue is full", Key: "user_123", Depth: 10}
+
+	expectedMsg := "wait queue is full: key=user_123, depth=10"
+	if err.Error() != expectedMsg {
+		t.Errorf("expected error message '%s', got '%s'", expectedMsg, err.Error())
+	}
+
+	if !IsQueueFullError(Wrap(err, "failed to wait")) {
+		t.Error("IsQueueFullError should return true for a wrapped QueueFullError")
+	}
+
+	if IsQueueFullError(&RateLimitError{Message: "test"}) {
+		t.Error("IsQueueFullError should return false for other errors")
+	}
+}
+
 func TestWrap(t *testing.T) {
 	originalErr := errors.New("original error")
 	wrappedErr := Wrap(originalErr, "additional context")
@@ -253,6 +294,22 @@ func TestErrorTypes(t *testing.T) {
 		t.Error("ErrTimeout should not be nil")
 	}
 
+	if !IsBackendError(ErrLimiterClosed) {
+		t.Error("ErrLimiterClosed should be a BackendError")
+	}
+
+	if !IsBackendError(ErrLeaseLost) {
+		t.Error("ErrLeaseLost should be a BackendError")
+	}
+
+	if !errors.Is(Wrap(ErrLimiterClosed, "take"), ErrBackendUnavailable) {
+		t.Error("ErrLimiterClosed should match ErrBackendUnavailable")
+	}
+
+	if errors.Is(ErrBackendUnavailable, ErrLimiterClosed) {
+		t.Error("ErrBackendUnavailable should not match ErrLimiterClosed")
+	}
+
 	// Test error type assertions
 	if !IsRateLimitError(ErrRateLimitExceeded) {
 		t.Error("ErrRateLimitExceeded should be a RateLimitError")
@@ -0,0 +1,248 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// AdaptiveOptions configures how an AdaptiveLimiter moves limits in response to downstream results
+type AdaptiveOptions struct {
+	// Window is the period limits are spread over, the refill rate of a key is Window divided by its limit
+	Window time.Duration `json:"window"`
+
+	// MinLimit and MaxLimit bound the limit of each key, keys start at MaxLimit
+	MinLimit int64 `json:"min_limit"`
+	MaxLimit int64 `json:"max_limit"`
+
+	// LatencyTarget treats results slower than it as overload, 0 only looks at status codes and errors
+	LatencyTarget time.Duration `json:"latency_target"`
+
+	// DecreaseFactor multiplies the limit of a key when the downstream is overloaded
+	DecreaseFactor float64 `json:"decrease_factor"`
+
+	// IncreaseStep is added to the limit of a key for every limit's worth of healthy results
+	IncreaseStep float64 `json:"increase_step"`
+
+	// Cooldown is the shortest time between two decreases of a key, so one burst of failures counts once
+	Cooldown time.Duration `json:"cooldown"`
+}
+
+// DefaultAdaptiveOptions returns default options for adaptive limiting
+func DefaultAdaptiveOptions() *AdaptiveOptions {
+	return &AdaptiveOptions{
+		Window:         time.Second,
+		MinLimit:       1,
+		MaxLimit:       100,
+		DecreaseFactor: 0.5,
+		IncreaseStep:   1,
+		Cooldown:       time.Second,
+	}
+}
+
+// Validate validates the adaptive options
+func (o *AdaptiveOptions) Validate() error {
+	if o.Window <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "window must be positive")
+	}
+
+	if o.MinLimit <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "min_limit must be positive")
+	}
+
+	if o.MaxLimit < o.MinLimit {
+		return errors.Wrap(errors.ErrInvalidTokens, "max_limit must not be less than min_limit")
+	}
+
+	if o.LatencyTarget < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "latency_target must not be negative")
+	}
+
+	if o.DecreaseFactor <= 0 || o.DecreaseFactor >= 1 {
+		return errors.Wrap(errors.ErrInvalidTokens, "decrease_factor must be between 0 and 1")
+	}
+
+	if o.IncreaseStep <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "increase_step must be positive")
+	}
+
+	if o.Cooldown < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "cooldown must not be negative")
+	}
+
+	return nil
+}
+
+// Result is the outcome of a call to the protected downstream, as reported by the caller
+type Result struct {
+	// StatusCode is the HTTP status of the response, 0 when there was none
+	StatusCode int
+
+	// Latency is how long the call took
+	Latency time.Duration
+
+	// Err is the error the call failed with, cancellations by the caller do not count as overload
+	Err error
+}
+
+// overloaded reports whether the result signals an overloaded downstream
+func (res Result) overloaded(latencyTarget time.Duration) bool {
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable {
+		return true
+	}
+
+	if res.Err != nil && !stderrors.Is(res.Err, context.Canceled) {
+		return true
+	}
+
+	return latencyTarget > 0 && res.Latency > latencyTarget
+}
+
+// adaptiveState is the current limit of a key and when it was last decreased
+type adaptiveState struct {
+	limit        float64
+	lastDecrease time.Time
+}
+
+// AdaptiveLimiter limits calls to a downstream with limits that follow its health
+// Limits shrink multiplicatively when callers report 429 or 503 responses, errors or slow calls,
+// and grow additively while results are healthy, within MinLimit and MaxLimit
+// Limits are tracked per key in this process, so keys should name downstreams rather than end users
+type AdaptiveLimiter struct {
+	limiter *RateLimiter
+	options AdaptiveOptions
+
+	mu     sync.Mutex
+	states map[string]*adaptiveState
+}
+
+// Adaptive returns an AdaptiveLimiter taking tokens from this limiter, nil options use the defaults
+func (r *RateLimiter) Adaptive(options *AdaptiveOptions) (*AdaptiveLimiter, error) {
+	if options == nil {
+		options = DefaultAdaptiveOptions()
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid adaptive options")
+	}
+
+	return &AdaptiveLimiter{
+		limiter: r,
+		options: *options,
+		states:  make(map[string]*adaptiveState),
+	}, nil
+}
+
+// Take attempts to consume tokens from the key under its current limit
+func (a *AdaptiveLimiter) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	limit := a.Limit(key)
+	refill := max(a.options.Window/time.Duration(limit), time.Millisecond)
+
+	return a.limiter.TakeWithLimit(ctx, key, tokens, limit, refill)
+}
+
+// Limit returns the current limit of the key
+func (a *AdaptiveLimiter) Limit(key string) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if state, ok := a.states[key]; ok {
+		return int64(state.limit)
+	}
+
+	return a.options.MaxLimit
+}
+
+// Report feeds the result of a downstream call back into the limit of its key
+func (a *AdaptiveLimiter) Report(ctx context.Context, key string, result Result) {
+	now := time.Now()
+
+	a.mu.Lock()
+	state, ok := a.states[key]
+	if !ok {
+		state = &adaptiveState{limit: float64(a.options.MaxLimit)}
+		a.states[key] = state
+	}
+
+	before := int64(state.limit)
+	if result.overloaded(a.options.LatencyTarget) {
+		if now.Sub(state.lastDecrease) >= a.options.Cooldown {
+			state.limit = max(state.limit*a.options.DecreaseFactor, float64(a.options.MinLimit))
+			state.lastDecrease = now
+		}
+	} else {
+		state.limit = min(state.limit+a.options.IncreaseStep/state.limit, float64(a.options.MaxLimit))
+	}
+	after := int64(state.limit)
+
+	// Keys back at the maximum need no state
+	if after == a.options.MaxLimit && now.Sub(state.lastDecrease) >= a.options.Cooldown {
+		delete(a.states, key)
+	}
+	a.mu.Unlock()
+
+	if after < before {
+		a.limiter.logConfigChange(ctx, "adaptive_decrease",
+			slog.String("key", key),
+			slog.Int64("limit", after),
+		)
+	}
+}
+
+// Transport returns a RoundTripper that takes a token for each request under the adaptive limit of its key
+// and reports every response back, keyFn defaults to the request host and a nil next uses http.DefaultTransport
+// Requests that find no token fail with a RateLimitError without reaching the downstream
+func (a *AdaptiveLimiter) Transport(next http.RoundTripper, keyFn func(*http.Request) string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if keyFn == nil {
+		keyFn = func(req *http.Request) string { return req.URL.Host }
+	}
+
+	return &adaptiveTransport{limiter: a, next: next, keyFn: keyFn}
+}
+
+// adaptiveTransport limits requests with an AdaptiveLimiter and reports their results
+type adaptiveTransport struct {
+	limiter *AdaptiveLimiter
+	next    http.RoundTripper
+	keyFn   func(*http.Request) string
+}
+
+// RoundTrip takes a token for the request key, sends the request and reports its result
+func (t *adaptiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := t.keyFn(req)
+	if key == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	allowed, err := t.limiter.Take(req.Context(), key, 1)
+	if err == nil && !allowed {
+		err = t.limiter.limiter.rateLimitError(req.Context(), key, "adaptive rate limit exceeded")
+	}
+	if err != nil {
+		// A RoundTripper must close the body even when it fails
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	result := Result{Latency: time.Since(start), Err: err}
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+	}
+	t.limiter.Report(req.Context(), key, result)
+
+	return resp, err
+}
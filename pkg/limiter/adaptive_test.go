@@ -0,0 +1,158 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestAdaptiveOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(*AdaptiveOptions)
+		expectError bool
+	}{
+		{name: "defaults", modify: func(*AdaptiveOptions) {}},
+		{name: "zero window", modify: func(o *AdaptiveOptions) { o.Window = 0 }, expectError: true},
+		{name: "zero min limit", modify: func(o *AdaptiveOptions) { o.MinLimit = 0 }, expectError: true},
+		{name: "max below min", modify: func(o *AdaptiveOptions) { o.MinLimit, o.MaxLimit = 10, 5 }, expectError: true},
+		{name: "negative latency target", modify: func(o *AdaptiveOptions) { o.LatencyTarget = -1 }, expectError: true},
+		{name: "decrease factor of 1", modify: func(o *AdaptiveOptions) { o.DecreaseFactor = 1 }, expectError: true},
+		{name: "zero increase step", modify: func(o *AdaptiveOptions) { o.IncreaseStep = 0 }, expectError: true},
+		{name: "negative cooldown", modify: func(o *AdaptiveOptions) { o.Cooldown = -1 }, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultAdaptiveOptions()
+			tt.modify(options)
+
+			err := options.Validate()
+			if tt.expectError && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdaptiveReport(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 100, time.Second)
+
+	adaptive, err := limiter.Adaptive(&AdaptiveOptions{
+		Window:         time.Second,
+		MinLimit:       2,
+		MaxLimit:       16,
+		LatencyTarget:  100 * time.Millisecond,
+		DecreaseFactor: 0.5,
+		IncreaseStep:   1,
+		Cooldown:       time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := adaptive.Limit("api"); got != 16 {
+		t.Errorf("expected keys to start at the maximum, got %d", got)
+	}
+
+	adaptive.Report(ctx, "api", Result{StatusCode: http.StatusServiceUnavailable})
+	if got := adaptive.Limit("api"); got != 8 {
+		t.Errorf("expected limit 8 after overload, got %d", got)
+	}
+
+	// Further overload within the cooldown belongs to the same episode
+	adaptive.Report(ctx, "api", Result{StatusCode: http.StatusTooManyRequests})
+	adaptive.Report(ctx, "api", Result{Err: stderrors.New("connection reset")})
+	if got := adaptive.Limit("api"); got != 8 {
+		t.Errorf("expected limit to stay 8 during the cooldown, got %d", got)
+	}
+
+	// Healthy results add about one per limit's worth
+	for i := 0; i < 10; i++ {
+		adaptive.Report(ctx, "api", Result{StatusCode: http.StatusOK, Latency: time.Millisecond})
+	}
+	if got := adaptive.Limit("api"); got != 9 {
+		t.Errorf("expected limit 9 after healthy results, got %d", got)
+	}
+
+	// Other keys and cancelled calls are unaffected
+	adaptive.Report(ctx, "other", Result{Err: context.Canceled})
+	if got := adaptive.Limit("other"); got != 16 {
+		t.Errorf("expected cancellations not to count as overload, got %d", got)
+	}
+
+	// Slow results count as overload once the cooldown has passed
+	adaptive.options.Cooldown = 0
+	for i := 0; i < 5; i++ {
+		adaptive.Report(ctx, "api", Result{StatusCode: http.StatusOK, Latency: time.Second})
+	}
+	if got := adaptive.Limit("api"); got != 2 {
+		t.Errorf("expected limit to bottom out at the minimum, got %d", got)
+	}
+
+	// The lowered limit is enforced by Take
+	for i := 0; i < 2; i++ {
+		if allowed, err := adaptive.Take(ctx, "api", 1); err != nil || !allowed {
+			t.Fatalf("expected take %d to be allowed, got %v, %v", i, allowed, err)
+		}
+	}
+	if allowed, _ := adaptive.Take(ctx, "api", 1); allowed {
+		t.Error("expected take beyond the adaptive limit to be denied")
+	}
+}
+
+func TestAdaptiveTransport(t *testing.T) {
+	var status atomic.Int64
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	limiter := newTestInMemoryLimiter(t, 100, time.Second)
+	options := DefaultAdaptiveOptions()
+	options.MaxLimit = 4
+	options.Cooldown = 0
+
+	adaptive, err := limiter.Adaptive(options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	client := &http.Client{Transport: adaptive.Transport(nil, func(*http.Request) string { return "downstream" })}
+
+	status.Store(http.StatusServiceUnavailable)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := adaptive.Limit("downstream"); got != 2 {
+		t.Errorf("expected limit 2 after a 503, got %d", got)
+	}
+
+	// The lowered limit clamps the three tokens left to two
+	status.Store(http.StatusOK)
+	for i := 0; i < 2; i++ {
+		resp, err = client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("expected request %d to pass, got %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	if _, err := client.Get(server.URL); !errors.IsRateLimitError(err) {
+		t.Errorf("expected a RateLimitError, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// denyRatioSlots is the number of slots a deny ratio window is divided into
+const denyRatioSlots = 10
+
+// DenyRatioAlert describes a deny ratio that crossed its threshold
+type DenyRatioAlert struct {
+	Key       string        `json:"key,omitempty"`
+	Ratio     float64       `json:"ratio"`
+	Threshold float64       `json:"threshold"`
+	Allowed   uint64        `json:"allowed"`
+	Denied    uint64        `json:"denied"`
+	Window    time.Duration `json:"window"`
+	Time      time.Time     `json:"time"`
+}
+
+// DenyRatioCallback is invoked when a deny ratio crosses its threshold
+// It runs on its own goroutine so it may block, e.g. to page an on-call engineer
+type DenyRatioCallback func(ctx context.Context, alert DenyRatioAlert)
+
+// DenyRatioAlertConfig configures the deny ratio watcher
+type DenyRatioAlertConfig struct {
+	// Threshold is the deny ratio, between 0 and 1, that triggers the callback
+	Threshold float64
+
+	// Window is the sliding window the ratio is computed over
+	Window time.Duration
+
+	// MinRequests is the number of decisions required in the window before alerting
+	MinRequests uint64
+
+	// PerKey computes a ratio for every key instead of one global ratio
+	PerKey bool
+}
+
+// Validate validates the deny ratio alert configuration
+func (c DenyRatioAlertConfig) Validate() error {
+	if c.Threshold <= 0 || c.Threshold > 1 {
+		return fmt.Errorf("threshold must be in (0, 1], got %v", c.Threshold)
+	}
+
+	if c.Window <= 0 {
+		return fmt.Errorf("window must be positive, got %v", c.Window)
+	}
+
+	return nil
+}
+
+// WithDenyRatioAlert invokes callback when the deny ratio crosses the configured threshold
+// The callback fires once per crossing and is re-armed when the ratio falls back below the threshold
+func WithDenyRatioAlert(cfg DenyRatioAlertConfig, callback DenyRatioCallback) Option {
+	return func(r *RateLimiter) {
+		r.denyRatio = &denyRatioWatcher{
+			config:   cfg,
+			callback: callback,
+			windows:  make(map[string]*ratioWindow),
+		}
+	}
+}
+
+// denyRatioWatcher tracks deny ratios over a sliding window
+type denyRatioWatcher struct {
+	config   DenyRatioAlertConfig
+	callback DenyRatioCallback
+
+	mu        sync.Mutex
+	windows   map[string]*ratioWindow
+	lastSweep time.Time
+}
+
+// ratioSlot counts decisions for one slot of a window
+type ratioSlot struct {
+	index   int64
+	allowed uint64
+	denied  uint64
+}
+
+// ratioWindow is a ring of slots covering one window
+type ratioWindow struct {
+	slots    [denyRatioSlots]ratioSlot
+	alerting bool
+}
+
+// record adds a decision and returns an alert when the ratio crosses the threshold
+func (w *denyRatioWatcher) record(key string, allowed bool, now time.Time) *DenyRatioAlert {
+	if !w.config.PerKey {
+		key = ""
+	}
+
+	slotWidth := int64(w.config.Window / denyRatioSlots)
+	if slotWidth <= 0 {
+		slotWidth = 1
+	}
+	index := now.UnixNano() / slotWidth
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.config.PerKey && now.Sub(w.lastSweep) >= w.config.Window {
+		w.sweep(index)
+		w.lastSweep = now
+	}
+
+	window, ok := w.windows[key]
+	if !ok {
+		window = &ratioWindow{}
+		w.windows[key] = window
+	}
+
+	slot := &window.slots[index%denyRatioSlots]
+	if slot.index != index {
+		*slot = ratioSlot{index: index}
+	}
+	if allowed {
+		slot.allowed++
+	} else {
+		slot.denied++
+	}
+
+	var allowedSum, deniedSum uint64
+	for _, s := range window.slots {
+		if s.index > index-denyRatioSlots {
+			allowedSum += s.allowed
+			deniedSum += s.denied
+		}
+	}
+
+	total := allowedSum + deniedSum
+	if total == 0 || total < w.config.MinRequests {
+		return nil
+	}
+
+	ratio := float64(deniedSum) / float64(total)
+	if ratio < w.config.Threshold {
+		window.alerting = false
+		return nil
+	}
+	if window.alerting {
+		return nil
+	}
+	window.alerting = true
+
+	return &DenyRatioAlert{
+		Key:       key,
+		Ratio:     ratio,
+		Threshold: w.config.Threshold,
+		Allowed:   allowedSum,
+		Denied:    deniedSum,
+		Window:    w.config.Window,
+		Time:      now,
+	}
+}
+
+// sweep drops windows with no decisions in the current window
+func (w *denyRatioWatcher) sweep(index int64) {
+	for key, window := range w.windows {
+		idle := true
+		for _, s := range window.slots {
+			if s.index > index-denyRatioSlots {
+				idle = false
+				break
+			}
+		}
+		if idle {
+			delete(w.windows, key)
+		}
+	}
+}
+
+// checkDenyRatio records a decision and invokes the alert callback on a threshold crossing
+func (r *RateLimiter) checkDenyRatio(ctx context.Context, key string, allowed bool) {
+	alert := r.denyRatio.record(key, allowed, time.Now())
+	if alert == nil {
+		return
+	}
+
+	go r.denyRatio.callback(context.WithoutCancel(ctx), *alert)
+}
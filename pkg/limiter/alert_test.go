@@ -0,0 +1,151 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestDenyRatioAlertConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      DenyRatioAlertConfig
+		expectError bool
+	}{
+		{
+			name:        "valid config",
+			config:      DenyRatioAlertConfig{Threshold: 0.5, Window: time.Minute},
+			expectError: false,
+		},
+		{
+			name:        "zero threshold",
+			config:      DenyRatioAlertConfig{Threshold: 0, Window: time.Minute},
+			expectError: true,
+		},
+		{
+			name:        "threshold above one",
+			config:      DenyRatioAlertConfig{Threshold: 1.5, Window: time.Minute},
+			expectError: true,
+		},
+		{
+			name:        "zero window",
+			config:      DenyRatioAlertConfig{Threshold: 0.5},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.expectError && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDenyRatioWatcherGlobal(t *testing.T) {
+	w := &denyRatioWatcher{
+		config:  DenyRatioAlertConfig{Threshold: 0.5, Window: time.Second, MinRequests: 4},
+		windows: make(map[string]*ratioWindow),
+	}
+	now := time.Now()
+
+	w.record("a", true, now)
+	w.record("b", false, now)
+	if alert := w.record("c", false, now); alert != nil {
+		t.Error("expected no alert below MinRequests")
+	}
+
+	alert := w.record("d", false, now)
+	if alert == nil {
+		t.Fatal("expected alert when threshold is crossed")
+	}
+	if alert.Key != "" {
+		t.Errorf("expected global alert, got key %s", alert.Key)
+	}
+	if alert.Allowed != 1 || alert.Denied != 3 || alert.Ratio != 0.75 {
+		t.Errorf("expected 1 allowed, 3 denied and ratio 0.75, got %+v", alert)
+	}
+
+	if alert := w.record("e", false, now); alert != nil {
+		t.Error("expected a single alert per crossing")
+	}
+
+	// Once the window has passed the ratio starts over and the alert is re-armed
+	later := now.Add(2 * time.Second)
+	for i := 0; i < 4; i++ {
+		w.record("a", true, later)
+	}
+	for i := 0; i < 3; i++ {
+		w.record("a", false, later)
+	}
+	if alert := w.record("a", false, later); alert == nil {
+		t.Error("expected alert after the ratio fell below the threshold and crossed again")
+	}
+}
+
+func TestDenyRatioWatcherPerKey(t *testing.T) {
+	w := &denyRatioWatcher{
+		config:  DenyRatioAlertConfig{Threshold: 0.5, Window: time.Second, PerKey: true},
+		windows: make(map[string]*ratioWindow),
+	}
+	now := time.Now()
+
+	w.record("busy", true, now)
+	w.record("busy", true, now)
+
+	alert := w.record("throttled", false, now)
+	if alert == nil || alert.Key != "throttled" {
+		t.Fatalf("expected alert for throttled key, got %+v", alert)
+	}
+
+	if alert := w.record("busy", false, now); alert != nil {
+		t.Errorf("expected no alert for busy key, got %+v", alert)
+	}
+
+	// Idle keys are swept once a window has passed
+	w.record("busy", true, now.Add(3*time.Second))
+	if len(w.windows) != 1 {
+		t.Errorf("expected 1 tracked key after sweep, got %d", len(w.windows))
+	}
+}
+
+func TestDenyRatioAlert(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			return false, nil
+		},
+	}
+
+	alerts := make(chan DenyRatioAlert, 1)
+	cfg := DenyRatioAlertConfig{Threshold: 0.9, Window: time.Minute, MinRequests: 2}
+	limiter, err := New(mock, config.DefaultConfig(), WithDenyRatioAlert(cfg, func(ctx context.Context, alert DenyRatioAlert) {
+		alerts <- alert
+	}))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(ctx, "test_key", 1)
+	limiter.Take(ctx, "test_key", 1)
+
+	select {
+	case alert := <-alerts:
+		if alert.Denied != 2 {
+			t.Errorf("expected 2 denied, got %d", alert.Denied)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected alert callback to be invoked")
+	}
+
+	if _, err := New(mock, config.DefaultConfig(), WithDenyRatioAlert(DenyRatioAlertConfig{}, nil)); err == nil {
+		t.Error("expected error for invalid deny ratio alert config")
+	}
+}
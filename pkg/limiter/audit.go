@@ -0,0 +1,104 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a denied request
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Key       string    `json:"key"`
+	Cost      int64     `json:"cost"`
+	Limit     int64     `json:"limit"`
+	Source    string    `json:"source,omitempty"`
+}
+
+// AuditSink receives a record for every denied request
+type AuditSink interface {
+	WriteAudit(ctx context.Context, record AuditRecord) error
+}
+
+// WithAuditSink records every denial to the given sink
+func WithAuditSink(sink AuditSink) Option {
+	return func(r *RateLimiter) {
+		r.audit = sink
+	}
+}
+
+// auditSourceKey is the context key holding the request source
+type auditSourceKey struct{}
+
+// WithAuditSource returns a context carrying the source of a request, e.g. a client IP or service name
+// The source is included in audit records for denials made with that context
+func WithAuditSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, auditSourceKey{}, source)
+}
+
+// auditSource returns the request source stored in the context, if any
+func auditSource(ctx context.Context) string {
+	source, _ := ctx.Value(auditSourceKey{}).(string)
+	return source
+}
+
+// JSONLinesSink writes audit records as JSON lines
+// Each record is written with a single Write call so the output can be rotated safely
+type JSONLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesSink creates a sink writing JSON lines to w
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// SetWriter swaps the underlying writer, e.g. after reopening a rotated file
+func (s *JSONLinesSink) SetWriter(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.w = w
+}
+
+// WriteAudit writes a record as one JSON line
+func (s *JSONLinesSink) WriteAudit(ctx context.Context, record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(line)
+	return err
+}
+
+// auditDenial writes an audit record for a denied request, looking up the key limit
+func (r *RateLimiter) auditDenial(ctx context.Context, operation string, key string, tokens int64) {
+	limit := int64(0)
+	if info, err := r.backend.GetInfo(ctx, key); err == nil {
+		limit = info.MaxTokens
+	}
+
+	err := r.audit.WriteAudit(ctx, AuditRecord{
+		Time:      time.Now(),
+		Operation: operation,
+		Key:       key,
+		Cost:      tokens,
+		Limit:     limit,
+		Source:    auditSource(ctx),
+	})
+	if err != nil && r.events != nil {
+		r.events.log(ctx, r.events.errors, slog.LevelError, "rate limiter audit write failed",
+			slog.String("error", err.Error()),
+		)
+	}
+}
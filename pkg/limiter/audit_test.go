@@ -0,0 +1,74 @@
+package limiter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONLinesSink(&buf)
+
+	mock := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			return key != "denied_key", nil
+		},
+	}
+
+	limiter, err := New(mock, config.DefaultConfig(), WithAuditSink(sink))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	ctx := WithAuditSource(context.Background(), "10.0.0.1")
+	limiter.Take(ctx, "allowed_key", 1)
+	limiter.Take(ctx, "denied_key", 5)
+	limiter.Take(context.Background(), "denied_key", 1)
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("failed to decode audit line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 audit records, got %d", len(records))
+	}
+
+	first := records[0]
+	if first.Key != "denied_key" || first.Cost != 5 || first.Limit != 100 || first.Source != "10.0.0.1" {
+		t.Errorf("unexpected audit record: %+v", first)
+	}
+	if first.Time.IsZero() {
+		t.Error("expected audit record timestamp")
+	}
+
+	if records[1].Source != "" {
+		t.Errorf("expected empty source, got %s", records[1].Source)
+	}
+}
+
+func TestJSONLinesSinkSetWriter(t *testing.T) {
+	var first, second bytes.Buffer
+	sink := NewJSONLinesSink(&first)
+
+	sink.WriteAudit(context.Background(), AuditRecord{Key: "a"})
+	sink.SetWriter(&second)
+	sink.WriteAudit(context.Background(), AuditRecord{Key: "b"})
+
+	if bytes.Count(first.Bytes(), []byte("\n")) != 1 {
+		t.Errorf("expected 1 line in first writer, got %q", first.String())
+	}
+	if bytes.Count(second.Bytes(), []byte("\n")) != 1 {
+		t.Errorf("expected 1 line in second writer, got %q", second.String())
+	}
+}
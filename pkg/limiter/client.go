@@ -0,0 +1,229 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// epochThreshold separates reset values sent as Unix timestamps from those sent as seconds to wait
+const epochThreshold = 1_000_000_000
+
+// ServerLimits is the rate limit a server announced in its response headers
+type ServerLimits struct {
+	// Limit is the number of requests allowed per window, 0 when not announced
+	Limit int64
+
+	// Remaining is the number of requests left in the current window, -1 when not announced
+	Remaining int64
+
+	// Window is the length of the limit window, 0 when not announced
+	Window time.Duration
+
+	// Reset is the time until the current window resets, 0 when not announced
+	Reset time.Duration
+
+	// RetryAfter is how long the server asked clients to back off, 0 when not asked
+	RetryAfter time.Duration
+}
+
+// ParseServerLimits reads RateLimit-*, X-RateLimit-* and Retry-After headers
+// It reports false when the headers announce no limit at all
+func ParseServerLimits(h http.Header, now time.Time) (ServerLimits, bool) {
+	limits := ServerLimits{Remaining: -1}
+	found := false
+
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		if v := h.Get(prefix + "Limit"); v != "" && limits.Limit == 0 {
+			if limit, window, ok := parseLimitHeader(v); ok {
+				limits.Limit, found = limit, true
+				if limits.Window == 0 {
+					limits.Window = window
+				}
+			}
+		}
+
+		if v := h.Get(prefix + "Remaining"); v != "" && limits.Remaining < 0 {
+			if remaining, err := strconv.ParseInt(firstItem(v), 10, 64); err == nil && remaining >= 0 {
+				limits.Remaining, found = remaining, true
+			}
+		}
+
+		if v := h.Get(prefix + "Reset"); v != "" && limits.Reset == 0 {
+			if reset, ok := parseResetHeader(v, now); ok {
+				limits.Reset, found = reset, true
+			}
+		}
+	}
+
+	// The policy header carries the window when the limit header does not
+	if v := h.Get("RateLimit-Policy"); v != "" && limits.Window == 0 {
+		if limit, window, ok := parseLimitHeader(v); ok {
+			limits.Window, found = window, true
+			if limits.Limit == 0 {
+				limits.Limit = limit
+			}
+		}
+	}
+
+	if v := h.Get("Retry-After"); v != "" {
+		if retry, ok := parseRetryAfter(v, now); ok {
+			limits.RetryAfter, found = retry, true
+		}
+	}
+
+	return limits, found
+}
+
+// ObserveResponse paces the key to the limits a remote server announced in the response headers
+// The announced limit replaces the key's limit, the local balance is lowered to the remaining count,
+// and the key is blocked until the window resets when nothing remains or the server sent Retry-After
+func (r *RateLimiter) ObserveResponse(ctx context.Context, key string, resp *http.Response) error {
+	if resp == nil {
+		return nil
+	}
+
+	limits, ok := ParseServerLimits(resp.Header, time.Now())
+	if !ok {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return errors.ErrLimiterClosed
+	}
+
+	if err := r.validateKey(key); err != nil {
+		return err
+	}
+
+	// Spread the announced limit over its window, or over the time to reset when no window is announced
+	window := limits.Window
+	if window == 0 {
+		window = limits.Reset
+	}
+	if limits.Limit > 0 && window > 0 {
+		refill := max(window/time.Duration(limits.Limit), time.Millisecond)
+
+		start := time.Now()
+		opCtx, done := r.withTimeout(ctx, "set_limit")
+		err := done(r.backend.SetLimit(opCtx, key, limits.Limit, refill))
+		r.observeBackend(ctx, "set_limit", start, err)
+		if err != nil {
+			return errors.Wrap(err, "failed to apply server limit")
+		}
+		r.notifyKeyChange(ctx, KeyLimitChanged, key, limits.Limit, refill)
+	}
+
+	if limits.Remaining >= 0 {
+		if err := r.drainTo(ctx, key, limits.Remaining); err != nil {
+			return err
+		}
+	}
+
+	block := limits.RetryAfter
+	if limits.Remaining == 0 {
+		block = max(block, limits.Reset)
+	}
+	if block > 0 {
+		start := time.Now()
+		opCtx, done := r.withTimeout(ctx, "block")
+		err := done(r.backend.Block(opCtx, key, block))
+		r.observeBackend(ctx, "block", start, err)
+		if err != nil {
+			return errors.Wrap(err, "failed to apply server backoff")
+		}
+	}
+
+	return nil
+}
+
+// drainTo lowers the balance of the key to at most remaining in one atomic backend call
+// The tokens are not counted as decisions or usage since no request is behind them
+func (r *RateLimiter) drainTo(ctx context.Context, key string, remaining int64) error {
+	drainer, ok := r.backend.(backend.Drainer)
+	if !ok {
+		return errors.Wrap(errors.ErrBackendUnavailable, "backend does not support draining")
+	}
+
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "drain")
+	err := done(drainer.Drain(opCtx, key, backend.MaxLimit, remaining))
+	r.observeBackend(ctx, "drain", start, err)
+	if err != nil {
+		return errors.Wrap(err, "failed to lower local balance")
+	}
+
+	return nil
+}
+
+// parseLimitHeader parses a limit such as "100" or "100, 100;w=60" into the limit and its window
+// The limit is the first item, the window is the first w parameter of any item
+func parseLimitHeader(v string) (int64, time.Duration, bool) {
+	value, _, _ := strings.Cut(firstItem(v), ";")
+	limit, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, 0, false
+	}
+
+	for _, item := range strings.Split(v, ",") {
+		params := strings.Split(item, ";")
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name != "w" {
+				continue
+			}
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds > 0 {
+				return limit, time.Duration(seconds) * time.Second, true
+			}
+		}
+	}
+
+	return limit, 0, true
+}
+
+// parseResetHeader parses a reset given in seconds to wait or as a Unix timestamp
+func parseResetHeader(v string, now time.Time) (time.Duration, bool) {
+	seconds, err := strconv.ParseFloat(firstItem(v), 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	if seconds >= epochThreshold {
+		reset := time.Unix(0, int64(seconds*float64(time.Second))).Sub(now)
+		return max(reset, 0), true
+	}
+
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// parseRetryAfter parses a Retry-After given in seconds or as an HTTP date
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(v); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// firstItem returns the first comma-separated item of a header value
+func firstItem(v string) string {
+	item, _, _ := strings.Cut(v, ",")
+	return strings.TrimSpace(item)
+}
@@ -0,0 +1,156 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestParseServerLimits(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected ServerLimits
+		found    bool
+	}{
+		{
+			name:     "no headers",
+			headers:  map[string]string{},
+			expected: ServerLimits{Remaining: -1},
+		},
+		{
+			name: "draft headers with window",
+			headers: map[string]string{
+				"RateLimit-Limit":     "100, 100;w=60",
+				"RateLimit-Remaining": "42",
+				"RateLimit-Reset":     "30",
+			},
+			expected: ServerLimits{Limit: 100, Remaining: 42, Window: time.Minute, Reset: 30 * time.Second},
+			found:    true,
+		},
+		{
+			name: "policy header",
+			headers: map[string]string{
+				"RateLimit-Policy":    "10;w=1",
+				"RateLimit-Remaining": "3",
+			},
+			expected: ServerLimits{Limit: 10, Remaining: 3, Window: time.Second},
+			found:    true,
+		},
+		{
+			name: "x headers with epoch reset",
+			headers: map[string]string{
+				"X-RateLimit-Limit":     "5000",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     "1704067260",
+			},
+			expected: ServerLimits{Limit: 5000, Remaining: 0, Reset: time.Minute},
+			found:    true,
+		},
+		{
+			name:     "retry after seconds",
+			headers:  map[string]string{"Retry-After": "120"},
+			expected: ServerLimits{Remaining: -1, RetryAfter: 2 * time.Minute},
+			found:    true,
+		},
+		{
+			name:     "retry after date",
+			headers:  map[string]string{"Retry-After": "Mon, 01 Jan 2024 00:00:10 GMT"},
+			expected: ServerLimits{Remaining: -1, RetryAfter: 10 * time.Second},
+			found:    true,
+		},
+		{
+			name: "malformed values",
+			headers: map[string]string{
+				"RateLimit-Limit":     "lots",
+				"RateLimit-Remaining": "-1",
+				"Retry-After":         "soon",
+			},
+			expected: ServerLimits{Remaining: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for name, value := range tt.headers {
+				h.Set(name, value)
+			}
+
+			limits, found := ParseServerLimits(h, now)
+			if found != tt.found {
+				t.Errorf("expected found %v, got %v", tt.found, found)
+			}
+			if limits != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, limits)
+			}
+		})
+	}
+}
+
+func TestObserveResponse(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(100).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("RateLimit-Limit", "10;w=10")
+	resp.Header.Set("RateLimit-Remaining", "4")
+
+	if err := limiter.ObserveResponse(ctx, "api.example.com", resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := limiter.GetInfo(ctx, "api.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.MaxTokens != 10 {
+		t.Errorf("expected max tokens 10, got %d", info.MaxTokens)
+	}
+	if info.RefillRate != time.Second {
+		t.Errorf("expected refill rate 1s, got %v", info.RefillRate)
+	}
+	if info.Tokens != 4 {
+		t.Errorf("expected 4 tokens, got %d", info.Tokens)
+	}
+
+	// An exhausted window blocks the key until it resets
+	resp.Header.Set("RateLimit-Remaining", "0")
+	resp.Header.Set("RateLimit-Reset", "5")
+
+	if err := limiter.ObserveResponse(ctx, "api.example.com", resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err = limiter.GetInfo(ctx, "api.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 0 {
+		t.Errorf("expected 0 tokens, got %d", info.Tokens)
+	}
+	if until := time.Until(info.BlockedUntil); until <= 4*time.Second || until > 5*time.Second {
+		t.Errorf("expected the key to be blocked for about 5s, got %v", until)
+	}
+
+	// Responses without limit headers change nothing
+	if err := limiter.ObserveResponse(ctx, "other", &http.Response{Header: http.Header{}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// Request describes a request to a CostFunc
+type Request struct {
+	// Key is the rate limit key the request takes tokens from
+	Key string
+
+	// Method is the HTTP method or RPC name of the request
+	Method string
+
+	// Path is the URL path or resource the request addresses
+	Path string
+
+	// Size is the payload size in bytes, -1 when unknown
+	Size int64
+
+	// Metadata holds any other attributes the caller prices requests by, such as a query complexity
+	Metadata map[string]string
+}
+
+// CostFunc returns the number of tokens a request takes
+// A cost of 0 lets the request through without taking tokens, a negative cost is rejected as invalid
+type CostFunc func(ctx context.Context, req Request) int64
+
+// WithCostFunc prices requests passed to TakeCost and sent through Transport with the function
+// Without it every request costs one token
+func WithCostFunc(fn CostFunc) Option {
+	return func(r *RateLimiter) {
+		r.cost = fn
+	}
+}
+
+// TakeCost attempts to take as many tokens from req.Key as the cost function prices the request at
+// Requests that cost nothing are allowed without reaching the backend
+func (r *RateLimiter) TakeCost(ctx context.Context, req Request) (bool, error) {
+	cost := r.requestCost(ctx, req)
+	if cost == 0 {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		if r.closed {
+			return false, errors.ErrLimiterClosed
+		}
+
+		if err := r.validateKey(req.Key); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	return r.Take(ctx, req.Key, cost)
+}
+
+// requestCost prices a request with the cost function, or at one token without one
+func (r *RateLimiter) requestCost(ctx context.Context, req Request) int64 {
+	if r.cost == nil {
+		return 1
+	}
+
+	return r.cost(ctx, req)
+}
+
+// httpRequest describes an outgoing HTTP request for the cost function
+func httpRequest(key string, req *http.Request) Request {
+	size := req.ContentLength
+	if size == 0 && req.Body != nil && req.Body != http.NoBody {
+		size = -1
+	}
+
+	return Request{Key: key, Method: req.Method, Path: req.URL.Path, Size: size}
+}
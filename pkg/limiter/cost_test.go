@@ -0,0 +1,151 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// sizeCost prices requests at one token per started kilobyte, with reads free
+func sizeCost(ctx context.Context, req Request) int64 {
+	if req.Method == http.MethodGet {
+		return 0
+	}
+	if req.Metadata["cost"] == "invalid" {
+		return -1
+	}
+	if req.Size < 0 {
+		return 10
+	}
+
+	return 1 + req.Size/1024
+}
+
+// newTestCostLimiter returns an in-memory limiter pricing requests with sizeCost
+func newTestCostLimiter(t *testing.T, limit int64) *RateLimiter {
+	t.Helper()
+
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(limit).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig(), WithCostFunc(sizeCost))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	t.Cleanup(func() { limiter.Close(context.Background()) })
+
+	return limiter
+}
+
+func TestTakeCost(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestCostLimiter(t, 5)
+
+	tests := []struct {
+		name        string
+		req         Request
+		allowed     bool
+		remaining   int64
+		expectError error
+	}{
+		{name: "small payload", req: Request{Key: "api", Method: "POST", Size: 100}, allowed: true, remaining: 4},
+		{name: "large payload", req: Request{Key: "api", Method: "POST", Size: 3000}, allowed: true, remaining: 1},
+		{name: "free read", req: Request{Key: "api", Method: "GET"}, allowed: true, remaining: 1},
+		{name: "beyond the balance", req: Request{Key: "api", Method: "POST", Size: 2048}, allowed: false, remaining: 1},
+		{name: "negative cost", req: Request{Key: "api", Method: "POST", Metadata: map[string]string{"cost": "invalid"}}, expectError: errors.ErrInvalidTokens, remaining: 1},
+		{name: "free read with empty key", req: Request{Method: "GET"}, expectError: errors.ErrInvalidKey, remaining: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := limiter.TakeCost(ctx, tt.req)
+			if tt.expectError != nil {
+				if !stderrors.Is(err, tt.expectError) {
+					t.Errorf("expected %v, got %v", tt.expectError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if allowed != tt.allowed {
+				t.Errorf("expected allowed %v, got %v", tt.allowed, allowed)
+			}
+
+			info, err := limiter.GetInfo(ctx, "api")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Tokens != tt.remaining {
+				t.Errorf("expected %d tokens remaining, got %d", tt.remaining, info.Tokens)
+			}
+		})
+	}
+}
+
+func TestTakeCostDefault(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 2, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if allowed, err := limiter.TakeCost(ctx, Request{Key: "api", Size: 1 << 20}); err != nil || !allowed {
+			t.Fatalf("expected request %d to be allowed, got %v, %v", i, allowed, err)
+		}
+	}
+
+	if allowed, _ := limiter.TakeCost(ctx, Request{Key: "api"}); allowed {
+		t.Error("expected every request to cost one token without a cost function")
+	}
+}
+
+func TestTransportCost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	limiter := newTestCostLimiter(t, 3)
+	client := &http.Client{Transport: limiter.Transport(nil, nil, nil)}
+
+	// Reads are free, so they never run out
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("expected free request %d to pass, got %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	// A 2KB upload costs three tokens, leaving none for the next one
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader(strings.Repeat("x", 2048)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Post(server.URL, "text/plain", strings.NewReader("x")); !errors.IsRateLimitError(err) {
+		t.Errorf("expected a RateLimitError, got %v", err)
+	}
+
+	// A fixed token count takes precedence over the cost function
+	fixed := &http.Client{Transport: limiter.Transport(nil, func(*http.Request) string { return "fixed" }, &TransportOptions{Tokens: 1})}
+	for i := 0; i < 3; i++ {
+		resp, err := fixed.Get(server.URL)
+		if err != nil {
+			t.Fatalf("expected request %d to pass, got %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := fixed.Get(server.URL); !errors.IsRateLimitError(err) {
+		t.Errorf("expected a RateLimitError, got %v", err)
+	}
+}
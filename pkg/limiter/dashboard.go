@@ -0,0 +1,153 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//go:embed dashboard/index.html
+var dashboardHTML []byte
+
+// dashboardKeyLimit is the number of hot keys shown on the dashboard
+const dashboardKeyLimit = 50
+
+// DashboardAuthFunc decides whether a request may access the dashboard
+type DashboardAuthFunc func(r *http.Request) bool
+
+// DashboardState is the JSON document polled by the dashboard
+type DashboardState struct {
+	Time     time.Time      `json:"time"`
+	Health   HealthStatus   `json:"health"`
+	Allowed  uint64         `json:"allowed"`
+	Denied   uint64         `json:"denied"`
+	Errors   uint64         `json:"errors"`
+	DenyRate float64        `json:"deny_rate"`
+	Keys     []DashboardKey `json:"keys"`
+}
+
+// DashboardKey is the live state of one hot key
+type DashboardKey struct {
+	Key       string     `json:"key"`
+	Requests  uint64     `json:"requests"`
+	Denied    uint64     `json:"denied"`
+	DenyRate  float64    `json:"deny_rate"`
+	Tokens    int64      `json:"tokens"`
+	MaxTokens int64      `json:"max_tokens"`
+	Blocked   *time.Time `json:"blocked_until,omitempty"`
+}
+
+// DashboardHandler returns a single http.Handler serving an embedded debug dashboard
+// The page is served at the mount root and its data at "state" below it, e.g.
+//
+//	http.Handle("/debug/ratelimit/", http.StripPrefix("/debug/ratelimit", limiter.DashboardHandler(auth)))
+//
+// Keys are listed from hot key tracking, so HotKeysCapacity must be set for them to appear
+// Every request is rejected with 403 when auth returns false, a nil auth allows everyone
+func (r *RateLimiter) DashboardHandler(auth DashboardAuthFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if auth != nil && !auth(req) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		if strings.HasSuffix(req.URL.Path, "/state") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(r.dashboardState(req.Context()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardHTML)
+	})
+}
+
+// dashboardState collects the current limiter state for the dashboard
+func (r *RateLimiter) dashboardState(ctx context.Context) DashboardState {
+	healthCtx := ctx
+	if timeout := r.config.HealthCheckTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		healthCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	healthErr := r.HealthCheck(healthCtx)
+
+	r.mu.RLock()
+	closed := r.closed
+	r.mu.RUnlock()
+
+	state := DashboardState{
+		Time: time.Now(),
+		Health: HealthStatus{
+			Status:    "ok",
+			Backend:   backendName(r.backend),
+			LatencyMs: float64(time.Since(start)) / float64(time.Millisecond),
+			Closed:    closed,
+		},
+		Allowed: r.allowedCount.Load(),
+		Denied:  r.deniedCount.Load(),
+		Errors:  r.errorCount.Load(),
+		Keys:    []DashboardKey{},
+	}
+
+	if healthErr != nil {
+		state.Health.Status = "unavailable"
+		state.Health.Error = healthErr.Error()
+	}
+
+	state.DenyRate = ratio(state.Denied, state.Allowed+state.Denied)
+
+	if r.hotKeys == nil {
+		return state
+	}
+
+	denied := make(map[string]uint64)
+	for _, hot := range r.hotKeys.denied.top(dashboardKeyLimit) {
+		denied[hot.Key] = hot.Count
+	}
+
+	for _, hot := range r.hotKeys.active.top(dashboardKeyLimit) {
+		key := DashboardKey{
+			Key:      hot.Key,
+			Requests: hot.Count,
+			Denied:   denied[hot.Key],
+			DenyRate: ratio(denied[hot.Key], hot.Count),
+		}
+
+		if healthErr == nil {
+			if info, err := r.backend.GetInfo(ctx, hot.Key); err == nil {
+				key.Tokens = info.Tokens
+				key.MaxTokens = info.MaxTokens
+				if !info.BlockedUntil.IsZero() {
+					blocked := info.BlockedUntil
+					key.Blocked = &blocked
+				}
+			}
+		}
+
+		state.Keys = append(state.Keys, key)
+	}
+
+	return state
+}
+
+// ratio returns part divided by total, or 0 when total is 0
+func ratio(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(part) / float64(total)
+}
@@ -0,0 +1,105 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			return key != "abuser", nil
+		},
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.HotKeysCapacity = 10
+	cfg.EnableLogging = false
+
+	limiter, err := New(mock, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(ctx, "abuser", 1)
+	limiter.Take(ctx, "abuser", 1)
+	limiter.Take(ctx, "user", 1)
+
+	handler := limiter.DashboardHandler(nil)
+
+	// Test page
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "<title>Rate Limiter Dashboard</title>") {
+		t.Error("expected embedded dashboard page")
+	}
+
+	// Test state
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/state", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+
+	var state DashboardState
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+
+	if state.Health.Status != "ok" {
+		t.Errorf("expected healthy backend, got %+v", state.Health)
+	}
+	if state.Allowed != 1 || state.Denied != 2 {
+		t.Errorf("expected 1 allowed and 2 denied, got %d and %d", state.Allowed, state.Denied)
+	}
+	if len(state.Keys) != 2 || state.Keys[0].Key != "abuser" || state.Keys[0].DenyRate != 1 {
+		t.Errorf("unexpected keys: %+v", state.Keys)
+	}
+	if state.Keys[0].MaxTokens != 100 {
+		t.Errorf("expected remaining tokens from backend, got %+v", state.Keys[0])
+	}
+}
+
+func TestDashboardHandlerAuth(t *testing.T) {
+	limiter, err := New(&mockBackend{}, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	handler := limiter.DashboardHandler(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer secret"
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/state", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/state", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+
+	// Test unsupported method
+	req = httptest.NewRequest(http.MethodPost, "/state", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,120 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DecisionEvent describes a single allow or deny decision
+type DecisionEvent struct {
+	Operation string    `json:"operation"`
+	Key       string    `json:"key"`
+	Tokens    int64     `json:"tokens"`
+	Allowed   bool      `json:"allowed"`
+	Remaining int64     `json:"remaining"`
+	Time      time.Time `json:"time"`
+}
+
+// subscription is a single consumer of decision events
+type subscription struct {
+	events chan DecisionEvent
+}
+
+// eventBus fans decision events out to subscribers
+type eventBus struct {
+	mu     sync.RWMutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+// Subscribe returns a channel streaming every decision made by the limiter and a function to unsubscribe
+// Events are dropped rather than blocking requests when the channel buffer is full
+// While at least one subscriber exists, each decision performs an extra backend read for the remaining tokens
+func (r *RateLimiter) Subscribe(buffer int) (<-chan DecisionEvent, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+
+	sub := &subscription{events: make(chan DecisionEvent, buffer)}
+
+	r.bus.mu.Lock()
+	defer r.bus.mu.Unlock()
+
+	if r.bus.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+
+	if r.bus.subs == nil {
+		r.bus.subs = make(map[*subscription]struct{})
+	}
+	r.bus.subs[sub] = struct{}{}
+
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			r.bus.mu.Lock()
+			defer r.bus.mu.Unlock()
+
+			if _, ok := r.bus.subs[sub]; ok {
+				delete(r.bus.subs, sub)
+				close(sub.events)
+			}
+		})
+	}
+}
+
+// hasSubscribers reports whether any subscriber is registered
+func (b *eventBus) hasSubscribers() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subs) > 0
+}
+
+// publish delivers an event to every subscriber without blocking
+func (b *eventBus) publish(event DecisionEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+}
+
+// close closes every subscriber channel and rejects new subscriptions
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		close(sub.events)
+	}
+	b.subs = nil
+}
+
+// publishDecision streams a decision to subscribers, looking up the remaining tokens
+func (r *RateLimiter) publishDecision(ctx context.Context, operation string, key string, tokens int64, allowed bool) {
+	if !r.bus.hasSubscribers() {
+		return
+	}
+
+	remaining := int64(-1)
+	if info, err := r.backend.GetInfo(ctx, key); err == nil {
+		remaining = info.Tokens
+	}
+
+	r.bus.publish(DecisionEvent{
+		Operation: operation,
+		Key:       key,
+		Tokens:    tokens,
+		Allowed:   allowed,
+		Remaining: remaining,
+		Time:      time.Now(),
+	})
+}
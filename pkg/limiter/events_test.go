@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			return key != "denied_key", nil
+		},
+		getInfoFunc: func(ctx context.Context, key string) (*backend.TokenInfo, error) {
+			return &backend.TokenInfo{Key: key, Tokens: 42, MaxTokens: 100}, nil
+		},
+	}
+
+	limiter, err := New(mock, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	events, unsubscribe := limiter.Subscribe(10)
+
+	limiter.Take(ctx, "allowed_key", 2)
+	limiter.Take(ctx, "denied_key", 3)
+
+	first := <-events
+	if first.Key != "allowed_key" || !first.Allowed || first.Tokens != 2 || first.Remaining != 42 {
+		t.Errorf("unexpected first event: %+v", first)
+	}
+
+	second := <-events
+	if second.Key != "denied_key" || second.Allowed || second.Tokens != 3 {
+		t.Errorf("unexpected second event: %+v", second)
+	}
+	if second.Operation != "take" {
+		t.Errorf("expected operation take, got %s", second.Operation)
+	}
+
+	// Unsubscribing closes the channel and stops delivery
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Error("expected channel to be closed after unsubscribe")
+	}
+	limiter.Take(ctx, "allowed_key", 1)
+}
+
+func TestSubscribeDropsWhenFull(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := New(&mockBackend{}, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	events, unsubscribe := limiter.Subscribe(1)
+	defer unsubscribe()
+
+	// Takes must not block on a full subscriber
+	for i := 0; i < 5; i++ {
+		if _, err := limiter.Take(ctx, "test_key", 1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(events) != 1 {
+		t.Errorf("expected 1 buffered event, got %d", len(events))
+	}
+}
+
+func TestSubscribeClose(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := New(&mockBackend{}, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	events, unsubscribe := limiter.Subscribe(1)
+	limiter.Close(ctx)
+
+	if _, ok := <-events; ok {
+		t.Error("expected channel to be closed after limiter close")
+	}
+	unsubscribe()
+
+	// Subscribing after close returns a closed channel
+	late, _ := limiter.Subscribe(1)
+	if _, ok := <-late; ok {
+		t.Error("expected closed channel when subscribing after close")
+	}
+}
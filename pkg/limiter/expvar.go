@@ -0,0 +1,82 @@
+package limiter
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// expvarCounters holds the counters published through the expvar package
+type expvarCounters struct {
+	allowed *expvar.Int
+	denied  *expvar.Int
+	errors  *expvar.Int
+}
+
+// publishExpvar publishes the limiter counters under the given name
+// Limiters configured with the same name share one set of counters
+func publishExpvar(name string, b backend.Backend) (*expvarCounters, error) {
+	vars, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		if expvar.Get(name) != nil {
+			return nil, fmt.Errorf("expvar %q is already published with a different type", name)
+		}
+		vars = expvar.NewMap(name)
+	}
+
+	counters := &expvarCounters{
+		allowed: expvarInt(vars, "allowed"),
+		denied:  expvarInt(vars, "denied"),
+		errors:  expvarInt(vars, "errors"),
+	}
+
+	// Key counts are computed on read since expvar is only scraped occasionally
+	if counter, ok := b.(backend.KeyCounter); ok {
+		vars.Set("keys", expvar.Func(func() interface{} {
+			count, err := counter.KeyCount(context.Background())
+			if err != nil {
+				return nil
+			}
+			return count
+		}))
+	}
+
+	if reporter, ok := b.(backend.MemoryReporter); ok {
+		vars.Set("memory", expvar.Func(func() interface{} {
+			stats, err := reporter.MemoryStats(context.Background())
+			if err != nil {
+				return nil
+			}
+			return stats
+		}))
+	}
+
+	return counters, nil
+}
+
+// expvarInt returns the named integer in the map, creating it if needed
+func expvarInt(vars *expvar.Map, name string) *expvar.Int {
+	if v, ok := vars.Get(name).(*expvar.Int); ok {
+		return v
+	}
+
+	v := new(expvar.Int)
+	vars.Set(name, v)
+	return v
+}
+
+// recordDecision increments the allowed or denied counter
+func (c *expvarCounters) recordDecision(allowed bool) {
+	if allowed {
+		c.allowed.Add(1)
+		return
+	}
+	c.denied.Add(1)
+}
+
+// recordError increments the error counter
+func (c *expvarCounters) recordError() {
+	c.errors.Add(1)
+}
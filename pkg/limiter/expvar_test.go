@@ -0,0 +1,93 @@
+package limiter
+
+import (
+	"context"
+	"expvar"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestExpvarCounters(t *testing.T) {
+	ctx := context.Background()
+	allow := true
+	mock := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			if key == "broken" {
+				return false, errors.ErrBackendUnavailable
+			}
+			return allow, nil
+		},
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.EnableExpvar = true
+	cfg.ExpvarName = "ratelimiter_test_counters"
+
+	limiter, err := New(mock, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(ctx, "test_key", 1)
+	allow = false
+	limiter.Take(ctx, "test_key", 1)
+	limiter.Take(ctx, "broken", 1)
+
+	vars, ok := expvar.Get("ratelimiter_test_counters").(*expvar.Map)
+	if !ok {
+		t.Fatal("expected expvar map to be published")
+	}
+
+	expected := map[string]string{"allowed": "1", "denied": "1", "errors": "1"}
+	for name, value := range expected {
+		if got := vars.Get(name).String(); got != value {
+			t.Errorf("expected %s to be %s, got %s", name, value, got)
+		}
+	}
+
+	// A second limiter with the same name shares the counters
+	if _, err := New(mock, cfg); err != nil {
+		t.Errorf("unexpected error reusing expvar name: %v", err)
+	}
+}
+
+func TestExpvarKeys(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer b.Close(ctx)
+
+	cfg := config.DefaultConfig()
+	cfg.EnableExpvar = true
+	cfg.ExpvarName = "ratelimiter_test_keys"
+
+	limiter, err := New(b, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(ctx, "key1", 1)
+	limiter.Take(ctx, "key2", 1)
+
+	vars := expvar.Get("ratelimiter_test_keys").(*expvar.Map)
+	if got := vars.Get("keys").String(); got != "2" {
+		t.Errorf("expected 2 keys, got %s", got)
+	}
+}
+
+func TestExpvarNameConflict(t *testing.T) {
+	expvar.NewInt("ratelimiter_test_conflict")
+
+	cfg := config.DefaultConfig()
+	cfg.EnableExpvar = true
+	cfg.ExpvarName = "ratelimiter_test_conflict"
+
+	if _, err := New(&mockBackend{}, cfg); err == nil {
+		t.Error("expected error for conflicting expvar name")
+	}
+}
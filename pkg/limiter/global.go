@@ -0,0 +1,66 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// globalKey is the backend key of the bucket every take also consumes from when a global limit is configured
+const globalKey = "global:limiter"
+
+// globalLimitRefresh is how often the global limit is written again, so a bucket recreated after cleanup or expiry gets it back
+const globalLimitRefresh = time.Minute
+
+// takeKey consumes tokens from the key, and atomically from the global bucket when a global limit is configured
+// The global bucket is taken from through TakeAll, which spends no grace tokens or burst pool, config validation
+// therefore rejects a global limit together with either
+func (r *RateLimiter) takeKey(ctx context.Context, key string, tokens int64) (bool, error) {
+	if r.config.GlobalLimit > 0 {
+		return r.takeAll(ctx, []string{key}, tokens)
+	}
+
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "take")
+	allowed, err := r.backend.Take(opCtx, key, tokens)
+	err = done(err)
+	r.observeBackend(ctx, "take", start, err)
+	return allowed, err
+}
+
+// takeAll consumes tokens from every key or from none of them, including the global bucket when one is configured
+func (r *RateLimiter) takeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	if r.config.GlobalLimit > 0 {
+		if err := r.refreshGlobalLimit(ctx); err != nil {
+			return false, err
+		}
+		keys = append(keys, globalKey)
+	}
+
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "take_all")
+	allowed, err := r.backend.TakeAll(opCtx, keys, tokens)
+	err = done(err)
+	r.observeBackend(ctx, "take_all", start, err)
+	return allowed, err
+}
+
+// refreshGlobalLimit writes the global limit to its bucket unless it was written within globalLimitRefresh
+// Backends leave an unchanged limit alone, so rewriting it does not refill the bucket
+func (r *RateLimiter) refreshGlobalLimit(ctx context.Context) error {
+	now := time.Now()
+	if now.UnixNano()-r.globalLimitSet.Load() < int64(globalLimitRefresh) {
+		return nil
+	}
+
+	opCtx, done := r.withTimeout(ctx, "set_limit")
+	err := done(r.backend.SetLimit(opCtx, globalKey, r.config.GlobalLimit, r.config.GlobalRefill))
+	r.observeBackend(ctx, "set_limit", now, err)
+	if err != nil {
+		return errors.Wrap(err, "failed to set global limit")
+	}
+
+	r.globalLimitSet.Store(now.UnixNano())
+	return nil
+}
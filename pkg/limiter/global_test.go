@@ -0,0 +1,88 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestGlobalLimit(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(2).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.GlobalLimit = 5
+	cfg.GlobalRefill = time.Hour
+
+	limiter, err := New(b, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	take := func(key string) bool {
+		allowed, err := limiter.Take(ctx, key, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return allowed
+	}
+
+	// Per-key limits still apply, and a denied take leaves the global bucket alone
+	if !take("alice") || !take("alice") {
+		t.Fatal("expected takes within the key limit to be allowed")
+	}
+	if take("alice") {
+		t.Error("expected take beyond the key limit to be denied")
+	}
+
+	// Every key draws on the global bucket
+	if !take("bob") || !take("bob") {
+		t.Fatal("expected takes within both limits to be allowed")
+	}
+	if allowed, err := limiter.TakeInGroup(ctx, "carol", "team", 1); err != nil || !allowed {
+		t.Fatalf("expected the last global token to be allowed, got %v, %v", allowed, err)
+	}
+	if take("dave") {
+		t.Error("expected take on a fresh key to be denied once the global bucket is empty")
+	}
+	if allowed, _ := limiter.TakeWithLimit(ctx, "erin", 1, 10, time.Hour); allowed {
+		t.Error("expected TakeWithLimit to be denied once the global bucket is empty")
+	}
+
+	info, err := limiter.GetInfo(ctx, globalKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 0 || info.MaxTokens != 5 {
+		t.Errorf("expected an empty global bucket of 5, got %d of %d", info.Tokens, info.MaxTokens)
+	}
+}
+
+func TestGlobalLimitConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.GlobalLimit = 10
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for a global limit without a refill rate, got nil")
+	}
+
+	// Takes through the global bucket spend neither grace tokens nor burst pools
+	cfg.GlobalRefill = time.Second
+	cfg.GraceTokens, cfg.GracePeriod = 5, time.Minute
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for a global limit with grace tokens, got nil")
+	}
+
+	cfg.GraceTokens, cfg.GracePeriod = 0, 0
+	cfg.BurstPoolTokens, cfg.BurstPoolRefill = 5, time.Minute
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for a global limit with a burst pool, got nil")
+	}
+}
@@ -0,0 +1,215 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// Guard bounds both the rate and the concurrency of work per key, as protecting a database usually needs both
+// Rates are shared through the backend, while concurrency is counted in this process unless the guard is distributed
+type Guard struct {
+	limiter       *RateLimiter
+	maxConcurrent int
+
+	// leaser shares slots between instances as leases renewed every leaseTTL/3, nil counts them in this process
+	leaser   backend.Leaser
+	leaseTTL time.Duration
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+// Guard returns a Guard admitting at most maxConcurrent calls per key at once, each within the rate limit of its key
+func (r *RateLimiter) Guard(maxConcurrent int) (*Guard, error) {
+	if maxConcurrent <= 0 {
+		return nil, errors.Wrap(errors.ErrInvalidTokens, "max concurrency must be positive")
+	}
+
+	return &Guard{
+		limiter:       r,
+		maxConcurrent: maxConcurrent,
+		inFlight:      make(map[string]int),
+	}, nil
+}
+
+// DistributedGuard returns a Guard admitting at most maxConcurrent calls per key at once across every instance
+// Slots are leases of leaseTTL held in the backend and renewed while the call runs, a crashed instance frees its slots once they expire
+// It fails unless the backend implements backend.Leaser
+func (r *RateLimiter) DistributedGuard(maxConcurrent int, leaseTTL time.Duration) (*Guard, error) {
+	leaser, ok := r.backend.(backend.Leaser)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrBackendUnavailable, "backend does not support leases")
+	}
+
+	if leaseTTL < 3*time.Millisecond {
+		return nil, errors.Wrap(errors.ErrInvalidTokens, "lease TTL must be at least 3 milliseconds")
+	}
+
+	guard, err := r.Guard(maxConcurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	guard.leaser = leaser
+	guard.leaseTTL = leaseTTL
+	return guard, nil
+}
+
+// Acquire takes a concurrency slot and tokens for the key, returning a context for the work and a function that releases the slot
+// It fails with a RateLimitError when either bound is reached, without taking tokens when no slot is free
+// The context is cancelled on release, and for distributed guards as soon as the slot is no longer held,
+// with errors.ErrLeaseLost as its cause when the lease expired and errors.ErrLimiterClosed when renewals stopped with the limiter
+// The release function must be called once the work is done, calling it again has no effect
+func (g *Guard) Acquire(ctx context.Context, key string, tokens int64) (context.Context, func(), error) {
+	if err := g.limiter.validateKey(key); err != nil {
+		return nil, nil, err
+	}
+
+	if !g.reserve(key) {
+		return nil, nil, &errors.RateLimitError{Message: "concurrency limit exceeded", Key: key, Limit: g.maxConcurrent}
+	}
+
+	var lease string
+	if g.leaser != nil {
+		var err error
+		if lease, err = g.acquireLease(ctx, key); err != nil {
+			g.release(key)
+			return nil, nil, err
+		}
+
+		if lease == "" {
+			g.release(key)
+			return nil, nil, &errors.RateLimitError{Message: "concurrency limit exceeded", Key: key, Limit: g.maxConcurrent}
+		}
+	}
+
+	allowed, err := g.limiter.Take(ctx, key, tokens)
+	if err == nil && !allowed {
+		err = g.limiter.rateLimitError(ctx, key, "rate limit exceeded")
+	}
+	if err != nil {
+		if lease != "" {
+			g.releaseLease(key, lease)
+		}
+		g.release(key)
+		return nil, nil, err
+	}
+
+	workCtx, cancel := context.WithCancelCause(ctx)
+	if lease == "" {
+		var once sync.Once
+		return workCtx, func() {
+			once.Do(func() {
+				g.release(key)
+				cancel(context.Canceled)
+			})
+		}, nil
+	}
+
+	stop := make(chan struct{})
+	heartbeat := make(chan struct{})
+	go g.renewLease(key, lease, stop, heartbeat, cancel)
+
+	var once sync.Once
+	return workCtx, func() {
+		once.Do(func() {
+			close(stop)
+			<-heartbeat
+			g.releaseLease(key, lease)
+			g.release(key)
+			cancel(context.Canceled)
+		})
+	}, nil
+}
+
+// acquireLease takes a lease on one of the slots of the key, returning an empty ID when none is free
+func (g *Guard) acquireLease(ctx context.Context, key string) (string, error) {
+	start := time.Now()
+	opCtx, done := g.limiter.withTimeout(ctx, "acquire_lease")
+	lease, err := g.leaser.AcquireLease(opCtx, key, g.maxConcurrent, g.leaseTTL)
+	err = done(err)
+	g.limiter.observeBackend(ctx, "acquire_lease", start, err)
+
+	return lease, err
+}
+
+// renewLease keeps a lease alive until stop is closed, the limiter is closed or the lease is lost
+// A renewal that fails is retried on the next beat, the lease survives as long as one succeeds before it expires
+// Once the lease can no longer be kept, lost cancels the context of the work holding it
+func (g *Guard) renewLease(key string, lease string, stop <-chan struct{}, heartbeat chan<- struct{}, lost context.CancelCauseFunc) {
+	defer close(heartbeat)
+
+	ticker := time.NewTicker(g.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		case <-g.limiter.done:
+			lost(errors.ErrLimiterClosed)
+			return
+		}
+
+		ctx := context.Background()
+		start := time.Now()
+		opCtx, done := g.limiter.withTimeout(ctx, "renew_lease")
+		renewed, err := g.leaser.RenewLease(opCtx, key, lease, g.leaseTTL)
+		err = done(err)
+		g.limiter.observeBackend(ctx, "renew_lease", start, err)
+
+		// The lease expired before the call finished and its slot may already be taken again
+		if err == nil && !renewed {
+			lost(errors.ErrLeaseLost)
+			return
+		}
+	}
+}
+
+// releaseLease frees a lease in the backend, a lease that cannot be freed expires on its own
+func (g *Guard) releaseLease(key string, lease string) {
+	ctx := context.Background()
+	start := time.Now()
+	opCtx, done := g.limiter.withTimeout(ctx, "release_lease")
+	err := done(g.leaser.ReleaseLease(opCtx, key, lease))
+	g.limiter.observeBackend(ctx, "release_lease", start, err)
+}
+
+// InFlight returns the number of calls currently holding a slot for the key in this process
+func (g *Guard) InFlight(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.inFlight[key]
+}
+
+// reserve takes a concurrency slot for the key if one is free
+func (g *Guard) reserve(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.inFlight[key] >= g.maxConcurrent {
+		return false
+	}
+
+	g.inFlight[key]++
+	return true
+}
+
+// release frees a concurrency slot of the key, dropping keys with none in use
+func (g *Guard) release(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.inFlight[key] <= 1 {
+		delete(g.inFlight, key)
+		return
+	}
+
+	g.inFlight[key]--
+}
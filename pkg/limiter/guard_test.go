@@ -0,0 +1,235 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 3, time.Hour)
+
+	if _, err := limiter.Guard(0); err == nil {
+		t.Error("expected error for zero concurrency, got nil")
+	}
+
+	guard, err := limiter.Guard(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	workCtx, release1, err := guard.Acquire(ctx, "db", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, release2, err := guard.Acquire(ctx, "db", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The concurrency bound rejects without spending tokens
+	if _, _, err := guard.Acquire(ctx, "db", 1); !errors.IsRateLimitError(err) {
+		t.Errorf("expected a RateLimitError at the concurrency bound, got %v", err)
+	}
+	if info, _ := limiter.GetInfo(ctx, "db"); info.Tokens != 1 {
+		t.Errorf("expected 1 token left, got %d", info.Tokens)
+	}
+	if got := guard.InFlight("db"); got != 2 {
+		t.Errorf("expected 2 calls in flight, got %d", got)
+	}
+
+	// Releasing twice frees a single slot, and ends the context of the work
+	release1()
+	release1()
+	if workCtx.Err() == nil {
+		t.Error("expected the context of the work to be cancelled on release")
+	}
+	if got := guard.InFlight("db"); got != 1 {
+		t.Errorf("expected 1 call in flight, got %d", got)
+	}
+
+	_, release3, err := guard.Acquire(ctx, "db", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release2()
+	release3()
+
+	// The rate bound rejects once the tokens are spent, and frees the slot it took
+	if _, _, err := guard.Acquire(ctx, "db", 1); !errors.IsRateLimitError(err) {
+		t.Errorf("expected a RateLimitError at the rate bound, got %v", err)
+	}
+	if got := guard.InFlight("db"); got != 0 {
+		t.Errorf("expected no calls in flight, got %d", got)
+	}
+
+	if _, _, err := guard.Acquire(ctx, "", 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error, got %v", err)
+	}
+}
+
+func TestGuardConcurrent(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 1000, time.Hour)
+
+	guard, err := limiter.Guard(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		current int
+		peak    int
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, release, err := guard.Acquire(ctx, "db", 1)
+			if err != nil {
+				return
+			}
+			defer release()
+
+			mu.Lock()
+			current++
+			peak = max(peak, current)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			current--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if peak > 3 {
+		t.Errorf("expected at most 3 concurrent calls, got %d", peak)
+	}
+	if got := guard.InFlight("db"); got != 0 {
+		t.Errorf("expected no calls in flight, got %d", got)
+	}
+}
+
+func TestDistributedGuard(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	// Two instances sharing one Redis
+	var guards []*Guard
+	for i := 0; i < 2; i++ {
+		b, err := backend.NewRedisBackend("redis://"+server.Addr(), backend.DefaultOptions().WithLimit(100))
+		if err != nil {
+			t.Fatalf("failed to create backend: %v", err)
+		}
+
+		limiter, err := New(b, config.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create limiter: %v", err)
+		}
+		t.Cleanup(func() { limiter.Close(context.Background()) })
+
+		if _, err := limiter.DistributedGuard(2, time.Millisecond); err == nil {
+			t.Error("expected error for a too short lease TTL, got nil")
+		}
+
+		guard, err := limiter.DistributedGuard(2, 30*time.Millisecond)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		guards = append(guards, guard)
+	}
+
+	_, release1, err := guards[0].Acquire(ctx, "db", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, release2, err := guards[1].Acquire(ctx, "db", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Slots are shared, and heartbeats keep them held past the lease TTL
+	time.Sleep(100 * time.Millisecond)
+	for _, guard := range guards {
+		if _, _, err := guard.Acquire(ctx, "db", 1); !errors.IsRateLimitError(err) {
+			t.Errorf("expected a RateLimitError at the shared concurrency bound, got %v", err)
+		}
+	}
+	if got := guards[0].InFlight("db"); got != 1 {
+		t.Errorf("expected 1 call in flight on the first instance, got %d", got)
+	}
+
+	release1()
+	release1()
+	_, release3, err := guards[1].Acquire(ctx, "db", 1)
+	if err != nil {
+		t.Fatalf("expected a slot after a release, got %v", err)
+	}
+
+	release2()
+	release3()
+	if got := guards[1].InFlight("db"); got != 0 {
+		t.Errorf("expected no calls in flight, got %d", got)
+	}
+}
+
+func TestDistributedGuardWithoutLeases(t *testing.T) {
+	limiter := newTestInMemoryLimiter(t, 3, time.Hour)
+
+	if _, err := limiter.DistributedGuard(2, time.Second); !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected backend unavailable error, got %v", err)
+	}
+}
+
+func TestDistributedGuardLeaseLost(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	b, err := backend.NewRedisBackend("redis://"+server.Addr(), backend.DefaultOptions().WithLimit(100))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	guard, err := limiter.DistributedGuard(2, 30*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	workCtx, release, err := guard.Acquire(ctx, "db", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+
+	// A lease gone from Redis cannot be renewed, the work holding it is told through its context
+	server.FlushAll()
+	select {
+	case <-workCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the context of the work to be cancelled once the lease was lost")
+	}
+	if cause := context.Cause(workCtx); !stderrors.Is(cause, errors.ErrLeaseLost) {
+		t.Errorf("expected lease lost as the cause, got %v", cause)
+	}
+}
@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// Handle is bound to a single key, for hot paths that limit the same key over and over
+// The key is validated once when the handle is created, and its tier is only looked up again after tiers change
+// Handles are safe for concurrent use, keep one per key rather than creating one per request
+type Handle struct {
+	limiter *RateLimiter
+	key     string
+	err     error
+
+	limit  int64
+	refill time.Duration
+	tier   string
+	cached atomic.Pointer[handleTier]
+}
+
+// handleTier is the tier of a handle as looked up at a tier generation
+type handleTier struct {
+	gen  uint64
+	tier Tier
+	ok   bool
+}
+
+// For returns a Handle taking from the key under the configured defaults
+// An invalid key makes every call of the handle return the validation error
+func (r *RateLimiter) For(key string) *Handle {
+	return &Handle{limiter: r, key: key, err: r.validateKey(key)}
+}
+
+// WithLimit returns a handle applying the limit and refill rate to the key in place of the configured defaults
+func (h *Handle) WithLimit(limit int64, refill time.Duration) *Handle {
+	handle := &Handle{limiter: h.limiter, key: h.key, err: h.err, limit: limit, refill: refill}
+
+	switch {
+	case handle.err != nil:
+	case limit <= 0:
+		handle.err = errors.Wrap(errors.ErrInvalidTokens, "limit must be positive")
+	case refill <= 0:
+		handle.err = errors.Wrap(errors.ErrInvalidTokens, "refill rate must be positive")
+	}
+
+	return handle
+}
+
+// WithTier returns a handle applying the named tier or template to the key
+// Scheduled limits of the tier are still evaluated on every take
+func (h *Handle) WithTier(tier string) *Handle {
+	return &Handle{limiter: h.limiter, key: h.key, err: h.err, tier: tier}
+}
+
+// Key returns the key the handle takes tokens from
+func (h *Handle) Key() string {
+	return h.key
+}
+
+// Allow attempts to consume one token from the key
+func (h *Handle) Allow(ctx context.Context) (bool, error) {
+	return h.AllowN(ctx, 1)
+}
+
+// AllowN attempts to consume tokens from the key
+func (h *Handle) AllowN(ctx context.Context, tokens int64) (bool, error) {
+	if h.tier != "" {
+		tier, ok := h.lookupTier()
+		if !ok {
+			return false, errors.Wrapf(errors.ErrInvalidKey, "unknown tier %q", h.tier)
+		}
+
+		limit, refill := tier.LimitAt(time.Now())
+		return h.limiter.takeWithLimit(ctx, h.key, h.err, tokens, limit, refill)
+	}
+
+	if h.limit > 0 {
+		return h.limiter.takeWithLimit(ctx, h.key, h.err, tokens, h.limit, h.refill)
+	}
+
+	return h.limiter.take(ctx, h.key, h.err, tokens)
+}
+
+// Wait waits until one token is available for the key and takes it, or fails once ctx is cancelled
+func (h *Handle) Wait(ctx context.Context) error {
+	return h.WaitN(ctx, 1)
+}
+
+// WaitN waits until tokens are available for the key and takes them as AllowN does, or fails once ctx is cancelled
+// Like the WaitN of golang.org/x/time/rate, a nil error means the tokens were consumed under the tier or limit of the handle
+func (h *Handle) WaitN(ctx context.Context, tokens int64) error {
+	if h.err != nil {
+		return h.err
+	}
+
+	return h.limiter.waitAndTake(ctx, h.key, tokens, func() (bool, error) {
+		return h.AllowN(ctx, tokens)
+	})
+}
+
+// lookupTier returns the tier of the handle, looking it up again only after tiers were set or removed
+func (h *Handle) lookupTier() (Tier, bool) {
+	gen := h.limiter.tierGen.Load()
+	if cached := h.cached.Load(); cached != nil && cached.gen == gen {
+		return cached.tier, cached.ok
+	}
+
+	// A tier changed after gen was read is cached under the older generation and looked up again next time
+	tier, ok := h.limiter.Tier(h.tier)
+	h.cached.Store(&handleTier{gen: gen, tier: tier, ok: ok})
+
+	return tier, ok
+}
@@ -0,0 +1,137 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestHandle(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 3, 50*time.Millisecond)
+	h := limiter.For("api")
+
+	if h.Key() != "api" {
+		t.Errorf("expected key api, got %s", h.Key())
+	}
+
+	if allowed, err := h.AllowN(ctx, 2); err != nil || !allowed {
+		t.Errorf("expected 2 tokens to be allowed, got %v, %v", allowed, err)
+	}
+	if allowed, err := h.Allow(ctx); err != nil || !allowed {
+		t.Errorf("expected a token to be allowed, got %v, %v", allowed, err)
+	}
+
+	// Handles share the bucket of their key with direct calls
+	if allowed, err := limiter.Take(ctx, "api", 1); err != nil || allowed {
+		t.Errorf("expected the shared bucket to be empty, got %v, %v", allowed, err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := h.Wait(waitCtx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := h.AllowN(ctx, 0); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error, got %v", err)
+	}
+
+	// Invalid keys and limits fail every call
+	invalid := []*Handle{
+		limiter.For(""),
+		limiter.For("api").WithLimit(0, time.Second),
+		limiter.For("api").WithLimit(5, 0),
+		limiter.For("").WithTier("free"),
+	}
+	for _, h := range invalid {
+		if _, err := h.Allow(ctx); err == nil {
+			t.Errorf("expected error for handle on %q, got nil", h.Key())
+		}
+		if err := h.Wait(ctx); err == nil {
+			t.Errorf("expected error waiting on %q, got nil", h.Key())
+		}
+	}
+}
+
+func TestHandleWithLimit(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 100, time.Hour)
+	h := limiter.For("api").WithLimit(2, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if allowed, err := h.Allow(ctx); err != nil || !allowed {
+			t.Fatalf("expected take %d to be allowed, got %v, %v", i+1, allowed, err)
+		}
+	}
+	if allowed, _ := h.Allow(ctx); allowed {
+		t.Error("expected take beyond the custom limit to be denied")
+	}
+}
+
+func TestHandleWaitTakes(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 100, time.Hour)
+	h := limiter.For("api").WithLimit(2, time.Hour)
+
+	// Waits take their tokens under the limit of the handle, not the default one
+	for i := 0; i < 2; i++ {
+		waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+		err := h.Wait(waitCtx)
+		cancel()
+		if err != nil {
+			t.Fatalf("unexpected error on wait %d: %v", i+1, err)
+		}
+	}
+	if allowed, _ := h.Allow(ctx); allowed {
+		t.Error("expected the waits to have used up the custom limit")
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := h.Wait(waitCtx); err == nil {
+		t.Error("expected wait on the empty bucket to time out")
+	}
+}
+
+func TestHandleWithTier(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig(), WithTiers(map[string]Tier{
+		"free": {Limit: 5, Refill: time.Hour},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	h := limiter.For("alice").WithTier("free")
+	if allowed, err := h.AllowN(ctx, 2); err != nil || !allowed {
+		t.Errorf("expected take within the free tier to be allowed, got %v, %v", allowed, err)
+	}
+
+	// The cached tier follows redefinitions, lowering the limit clamps the 3 tokens left to 1
+	if err := limiter.SetTier(ctx, "free", Tier{Limit: 1, Refill: time.Hour}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed, _ := h.AllowN(ctx, 2); allowed {
+		t.Error("expected take beyond the lowered free tier to be denied")
+	}
+	if allowed, err := h.Allow(ctx); err != nil || !allowed {
+		t.Errorf("expected take within the lowered free tier to be allowed, got %v, %v", allowed, err)
+	}
+
+	limiter.RemoveTier(ctx, "free")
+	if _, err := h.Allow(ctx); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error for a removed tier, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// HealthStatus is the JSON document served by HealthHandler
+type HealthStatus struct {
+	Status    string  `json:"status"`
+	Backend   string  `json:"backend"`
+	LatencyMs float64 `json:"latency_ms"`
+	Closed    bool    `json:"closed"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthHandler returns an http.Handler suitable for readiness probes
+// It runs HealthCheck bounded by HealthCheckTimeout and responds 200 when healthy, 503 otherwise
+func (r *RateLimiter) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if timeout := r.config.HealthCheckTimeout; timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		start := time.Now()
+		err := r.HealthCheck(ctx)
+		elapsed := time.Since(start)
+
+		r.mu.RLock()
+		closed := r.closed
+		r.mu.RUnlock()
+
+		status := HealthStatus{
+			Status:    "ok",
+			Backend:   backendName(r.backend),
+			LatencyMs: float64(elapsed) / float64(time.Millisecond),
+			Closed:    closed,
+		}
+
+		code := http.StatusOK
+		if err != nil {
+			status.Status = "unavailable"
+			status.Error = err.Error()
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(status)
+	})
+}
+
+// backendName returns the type name of a backend for status reports
+func backendName(b backend.Backend) string {
+	return fmt.Sprintf("%T", b)
+}
@@ -0,0 +1,77 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestHealthHandler(t *testing.T) {
+	limiter, err := New(&mockBackend{}, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	limiter.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %s", ct)
+	}
+
+	var status HealthStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if status.Status != "ok" || status.Closed || status.Backend != "*limiter.mockBackend" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	// Test closed limiter reports unavailable
+	limiter.Close(context.Background())
+
+	rec = httptest.NewRecorder()
+	limiter.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", rec.Code)
+	}
+
+	status = HealthStatus{}
+	json.NewDecoder(rec.Body).Decode(&status)
+	if status.Status != "unavailable" || !status.Closed || status.Error == "" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestHealthHandlerTimeout(t *testing.T) {
+	mock := &mockBackend{
+		healthFunc: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.HealthCheckTimeout = 20 * time.Millisecond
+
+	limiter, err := New(mock, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	limiter.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 on timeout, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package limiter
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// HotKey is an approximate request count for a key
+// Count may overestimate the true count by at most Error
+type HotKey struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+	Error uint64 `json:"error"`
+}
+
+// HotKeysReport lists the most active and the most denied keys
+type HotKeysReport struct {
+	Active []HotKey `json:"active"`
+	Denied []HotKey `json:"denied"`
+}
+
+// Keys returns the keys of the most active list in order, such as for Prewarm on the next start
+func (h *HotKeysReport) Keys() []string {
+	keys := make([]string, len(h.Active))
+	for i, hot := range h.Active {
+		keys[i] = hot.Key
+	}
+
+	return keys
+}
+
+// HotKeys returns the n most active and n most denied keys observed by this limiter
+// Counts are approximate and tracked with bounded memory, see HotKeysCapacity in the configuration
+func (r *RateLimiter) HotKeys(ctx context.Context, n int) (*HotKeysReport, error) {
+	if n <= 0 {
+		return nil, errors.Wrap(errors.ErrInvalidTokens, "n must be positive")
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	report := &HotKeysReport{}
+	if r.hotKeys != nil {
+		report.Active = r.hotKeys.active.top(n)
+		report.Denied = r.hotKeys.denied.top(n)
+	}
+
+	return report, nil
+}
+
+// hotKeyTracker tracks the most active and most denied keys
+type hotKeyTracker struct {
+	active *spaceSaving
+	denied *spaceSaving
+}
+
+// newHotKeyTracker creates a tracker keeping at most capacity keys per list
+func newHotKeyTracker(capacity int) *hotKeyTracker {
+	return &hotKeyTracker{
+		active: newSpaceSaving(capacity),
+		denied: newSpaceSaving(capacity),
+	}
+}
+
+// record counts a decision for a key
+func (t *hotKeyTracker) record(key string, allowed bool) {
+	t.active.add(key)
+	if !allowed {
+		t.denied.add(key)
+	}
+}
+
+// spaceSaving implements the space-saving top-k algorithm
+// When full, the least counted key is replaced and the newcomer inherits its count as error
+type spaceSaving struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*counterEntry
+	heap     counterHeap
+}
+
+// counterEntry is a tracked key with its estimated count
+type counterEntry struct {
+	key   string
+	count uint64
+	err   uint64
+	index int
+}
+
+// newSpaceSaving creates a space-saving counter with the given capacity
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		entries:  make(map[string]*counterEntry, capacity),
+	}
+}
+
+// add counts one occurrence of the key
+func (s *spaceSaving) add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok {
+		entry.count++
+		heap.Fix(&s.heap, entry.index)
+		return
+	}
+
+	if len(s.entries) < s.capacity {
+		entry := &counterEntry{key: key, count: 1}
+		s.entries[key] = entry
+		heap.Push(&s.heap, entry)
+		return
+	}
+
+	// Replace the least counted key
+	entry := s.heap[0]
+	delete(s.entries, entry.key)
+	entry.key = key
+	entry.err = entry.count
+	entry.count++
+	s.entries[key] = entry
+	heap.Fix(&s.heap, 0)
+}
+
+// top returns up to n keys with the highest counts
+func (s *spaceSaving) top(n int) []HotKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]HotKey, 0, len(s.entries))
+	for _, entry := range s.entries {
+		keys = append(keys, HotKey{Key: entry.key, Count: entry.count, Error: entry.err})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+
+	return keys
+}
+
+// counterHeap is a min-heap of entries ordered by count
+type counterHeap []*counterEntry
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	entry := x.(*counterEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
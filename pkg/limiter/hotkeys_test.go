@@ -0,0 +1,98 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestHotKeys(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			return key != "abuser", nil
+		},
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.HotKeysCapacity = 10
+	cfg.EnableLogging = false
+
+	limiter, err := New(mock, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		limiter.Take(ctx, "abuser", 1)
+	}
+	for i := 0; i < 20; i++ {
+		limiter.Take(ctx, "busy", 1)
+	}
+	for i := 0; i < 100; i++ {
+		limiter.Take(ctx, fmt.Sprintf("user_%d", i), 1)
+	}
+
+	report, err := limiter.HotKeys(ctx, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.Active) != 2 {
+		t.Fatalf("expected 2 active keys, got %d", len(report.Active))
+	}
+	if report.Active[0].Key != "abuser" || report.Active[1].Key != "busy" {
+		t.Errorf("expected active keys [abuser busy], got %+v", report.Active)
+	}
+
+	if len(report.Denied) != 1 || report.Denied[0].Key != "abuser" || report.Denied[0].Count != 50 {
+		t.Errorf("expected abuser denied 50 times, got %+v", report.Denied)
+	}
+
+	// Test invalid n
+	_, err = limiter.HotKeys(ctx, 0)
+	if err == nil {
+		t.Error("expected error for zero n")
+	}
+}
+
+func TestHotKeysDisabled(t *testing.T) {
+	limiter, err := New(&mockBackend{}, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(context.Background(), "test_key", 1)
+
+	report, err := limiter.HotKeys(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Active) != 0 || len(report.Denied) != 0 {
+		t.Errorf("expected empty report when disabled, got %+v", report)
+	}
+}
+
+func TestSpaceSaving(t *testing.T) {
+	s := newSpaceSaving(2)
+
+	s.add("a")
+	s.add("a")
+	s.add("b")
+	s.add("c")
+
+	top := s.top(2)
+	if len(top) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(top))
+	}
+	if top[0].Key != "a" || top[0].Count != 2 || top[0].Error != 0 {
+		t.Errorf("unexpected top key: %+v", top[0])
+	}
+
+	// c replaced b and inherited its count as error
+	if top[1].Key != "c" || top[1].Count != 2 || top[1].Error != 1 {
+		t.Errorf("unexpected second key: %+v", top[1])
+	}
+}
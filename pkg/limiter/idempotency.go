@@ -0,0 +1,73 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// TakeOnce consumes tokens for a logical request identified by requestID, such as a request ID header
+// Retries of an admitted request within the backend's idempotency window are admitted without consuming tokens again,
+// so clients retrying after a timeout are not charged twice. An empty request ID takes as Take does
+// The backend must implement backend.IdempotentTaker. TakeOnce fails while a global limit is configured,
+// as no backend deduplicates a take from the key and the global bucket in one atomic call
+func (r *RateLimiter) TakeOnce(ctx context.Context, key string, requestID string, tokens int64) (bool, error) {
+	if requestID == "" {
+		return r.Take(ctx, key, tokens)
+	}
+
+	ctx, span := r.startSpan(ctx, "ratelimiter.TakeOnce", key, tokens)
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return false, errors.ErrLimiterClosed
+	}
+
+	if err := r.validateKey(key); err != nil {
+		return false, err
+	}
+
+	if err := r.validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	if r.config.GlobalLimit > 0 {
+		return false, errors.Wrap(errors.ErrBackendUnavailable, "idempotent takes do not support a global limit")
+	}
+
+	taker, ok := r.backend.(backend.IdempotentTaker)
+	if !ok {
+		return false, errors.Wrap(errors.ErrBackendUnavailable, "backend does not support idempotent takes")
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	tokens = r.shedKeyTokens(ctx, []string{key}, tokens)
+	allowed, err := r.takeOnceKey(ctx, taker, key, requestID, tokens)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to take tokens from backend")
+	}
+
+	r.observeDecision(ctx, "take_once", key, tokens, allowed)
+	return allowed, nil
+}
+
+// takeOnceKey takes tokens for a request ID from one backend key
+func (r *RateLimiter) takeOnceKey(ctx context.Context, taker backend.IdempotentTaker, key string, requestID string, tokens int64) (bool, error) {
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "take_once")
+	allowed, err := taker.TakeOnce(opCtx, key, requestID, tokens)
+	err = done(err)
+	r.observeBackend(ctx, "take_once", start, err)
+	return allowed, err
+}
@@ -0,0 +1,86 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestTakeOnce(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(2).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	// The original request and two retries are charged once
+	for i := 0; i < 3; i++ {
+		if allowed, err := limiter.TakeOnce(ctx, "alice", "req-1", 1); err != nil || !allowed {
+			t.Fatalf("attempt %d: expected take to be allowed, got %v, %v", i, allowed, err)
+		}
+	}
+	if info, _ := limiter.GetInfo(ctx, "alice"); info.Tokens != 1 {
+		t.Errorf("expected 1 token left, got %d", info.Tokens)
+	}
+
+	// Without a request ID every call is charged
+	limiter.TakeOnce(ctx, "alice", "", 1)
+	if allowed, _ := limiter.TakeOnce(ctx, "alice", "", 1); allowed {
+		t.Error("expected takes without a request ID to be charged")
+	}
+}
+
+func TestTakeOnceGlobalLimit(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(5).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.GlobalLimit = 2
+	cfg.GlobalRefill = time.Hour
+
+	limiter, err := New(b, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	// A global denial could not give back what the key took, so TakeOnce is rejected instead
+	if _, err := limiter.TakeOnce(ctx, "alice", "req-1", 1); !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected backend unavailable error, got %v", err)
+	}
+	if info, _ := limiter.GetInfo(ctx, "alice"); info.Tokens != 5 {
+		t.Errorf("expected 5 tokens left, got %d", info.Tokens)
+	}
+
+	// An empty request ID takes as Take does, global limit included
+	if allowed, _ := limiter.TakeOnce(ctx, "alice", "", 1); !allowed {
+		t.Error("expected take without a request ID to be allowed")
+	}
+}
+
+func TestTakeOnceUnsupported(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := New(&mockBackend{}, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	if _, err := limiter.TakeOnce(ctx, "alice", "req-1", 1); !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected backend unavailable error, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// KeyChangeKind is what happened to a key
+type KeyChangeKind string
+
+const (
+	// KeyReset is a key cleared by Reset
+	KeyReset KeyChangeKind = "reset"
+
+	// KeyLimitChanged is a key given a new limit
+	KeyLimitChanged KeyChangeKind = "limit_changed"
+
+	// KeyEvicted is a key whose bucket the backend cleanup dropped, on backends implementing backend.EvictionNotifier
+	KeyEvicted KeyChangeKind = "evicted"
+)
+
+// KeyChange describes a change to a key that caches of its state should follow
+type KeyChange struct {
+	Kind KeyChangeKind `json:"kind"`
+	Key  string        `json:"key"`
+
+	// Limit and Refill are the new limit of a KeyLimitChanged change
+	Limit  int64         `json:"limit,omitempty"`
+	Refill time.Duration `json:"refill,omitempty"`
+
+	Time time.Time `json:"time"`
+}
+
+// KeyChangeCallback is invoked when a key is reset, its limit changes or it is evicted
+// It runs synchronously after the change, on the goroutine making it, so it should return quickly
+type KeyChangeCallback func(ctx context.Context, change KeyChange)
+
+// WithKeyChangeCallback invokes callback for every reset, limit change and eviction of a key, so dependent caches
+// such as local policy caches can invalidate promptly. It may be given several times to register several callbacks
+// Limits passed to TakeWithLimit are set with every request and are not reported
+func WithKeyChangeCallback(callback KeyChangeCallback) Option {
+	return func(r *RateLimiter) {
+		r.keyChanges = append(r.keyChanges, callback)
+	}
+}
+
+// notifyKeyChange invokes the key change callbacks
+func (r *RateLimiter) notifyKeyChange(ctx context.Context, kind KeyChangeKind, key string, limit int64, refill time.Duration) {
+	if len(r.keyChanges) == 0 {
+		return
+	}
+
+	change := KeyChange{Kind: kind, Key: key, Limit: limit, Refill: refill, Time: time.Now()}
+	for _, callback := range r.keyChanges {
+		callback(ctx, change)
+	}
+}
+
+// subscribeEvictions reports the evictions of backends implementing backend.EvictionNotifier to the key change callbacks
+func (r *RateLimiter) subscribeEvictions() {
+	notifier, ok := r.backend.(backend.EvictionNotifier)
+	if !ok || len(r.keyChanges) == 0 {
+		return
+	}
+
+	r.unsubscribeEvictions = notifier.SubscribeEvictions(func(key string) {
+		r.notifyKeyChange(context.Background(), KeyEvicted, key, 0, 0)
+	})
+}
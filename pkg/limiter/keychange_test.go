@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+// evictingBackend lets tests evict keys as a backend cleanup would
+type evictingBackend struct {
+	mockBackend
+	subscribers map[int]func(key string)
+	next        int
+}
+
+func (e *evictingBackend) SubscribeEvictions(fn func(key string)) func() {
+	if e.subscribers == nil {
+		e.subscribers = make(map[int]func(key string))
+	}
+	id := e.next
+	e.next++
+	e.subscribers[id] = fn
+
+	return func() { delete(e.subscribers, id) }
+}
+
+func (e *evictingBackend) evict(key string) {
+	for _, fn := range e.subscribers {
+		fn(key)
+	}
+}
+
+func TestKeyChangeCallback(t *testing.T) {
+	ctx := context.Background()
+	b := &evictingBackend{}
+
+	var changes []KeyChange
+	limiter, err := New(b, config.DefaultConfig(), WithKeyChangeCallback(func(ctx context.Context, change KeyChange) {
+		changes = append(changes, change)
+	}))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	if err := limiter.Reset(ctx, "alice"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := limiter.SetGroupLimit(ctx, "team", 10, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := limiter.PreloadPolicies(ctx, []Policy{{Key: "bob", Limit: 5, Refill: time.Minute}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b.evict("carol")
+
+	// Limits passed with each take are not reported
+	limiter.TakeWithLimit(ctx, "dave", 1, 3, time.Second)
+
+	expected := []KeyChange{
+		{Kind: KeyReset, Key: "alice"},
+		{Kind: KeyLimitChanged, Key: "group:team", Limit: 10, Refill: time.Second},
+		{Kind: KeyLimitChanged, Key: "bob", Limit: 5, Refill: time.Minute},
+		{Kind: KeyEvicted, Key: "carol"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), changes)
+	}
+	for i, want := range expected {
+		got := changes[i]
+		if got.Kind != want.Kind || got.Key != want.Key || got.Limit != want.Limit || got.Refill != want.Refill {
+			t.Errorf("change %d: expected %+v, got %+v", i, want, got)
+		}
+		if got.Time.IsZero() {
+			t.Errorf("change %d: expected a time", i)
+		}
+	}
+
+	// Closing unsubscribes from evictions
+	limiter.Close(ctx)
+	if len(b.subscribers) != 0 {
+		t.Errorf("expected no eviction subscribers after close, got %d", len(b.subscribers))
+	}
+}
+
+func TestKeyChangeCallbackNotSubscribedWithoutCallbacks(t *testing.T) {
+	b := &evictingBackend{}
+	if _, err := New(b, config.DefaultConfig()); err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	if len(b.subscribers) != 0 {
+		t.Errorf("expected no eviction subscribers, got %d", len(b.subscribers))
+	}
+}
@@ -0,0 +1,104 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// otherKeyLabel is the key label of decisions on keys outside the top keys
+const otherKeyLabel = "other"
+
+// keyLabelRefresh is how often the labelled keys are recomputed from recent request counts
+const keyLabelRefresh = time.Minute
+
+// KeyCollector is implemented by metrics collectors that count decisions per key
+// Only the most requested keys are labelled individually, see KeyMetricsLimit in the configuration
+type KeyCollector interface {
+	// IncKeyDecision counts a decision on a key, key is "other" for keys outside the top keys
+	IncKeyDecision(ctx context.Context, key string, decision string)
+
+	// RemoveKey drops the series of a key that is no longer among the top keys
+	RemoveKey(ctx context.Context, key string)
+}
+
+// keyLabeler picks the keys labelled individually, keeping the most requested keys of the last refresh interval
+type keyLabeler struct {
+	mu        sync.Mutex
+	limit     int
+	counts    *spaceSaving
+	labelled  map[string]struct{}
+	refreshed time.Time
+}
+
+// newKeyLabeler creates a labeler labelling at most limit keys
+func newKeyLabeler(limit int, now time.Time) *keyLabeler {
+	return &keyLabeler{
+		limit:     limit,
+		counts:    newSpaceSaving(2 * limit),
+		labelled:  make(map[string]struct{}, limit),
+		refreshed: now,
+	}
+}
+
+// label counts a request on the key and returns its label, along with the keys that stopped being labelled
+// Free slots go to new keys as they arrive, and every refresh hands them to the most requested keys since the last one
+func (l *keyLabeler) label(key string, now time.Time) (string, []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.counts.add(key)
+
+	var removed []string
+	if now.Sub(l.refreshed) >= keyLabelRefresh {
+		removed = l.refresh(now)
+	}
+
+	if _, ok := l.labelled[key]; ok {
+		return key, removed
+	}
+
+	if len(l.labelled) < l.limit {
+		l.labelled[key] = struct{}{}
+		return key, removed
+	}
+
+	return otherKeyLabel, removed
+}
+
+// refresh labels the most requested keys since the last refresh and starts counting again
+func (l *keyLabeler) refresh(now time.Time) []string {
+	labelled := make(map[string]struct{}, l.limit)
+	for _, hot := range l.counts.top(l.limit) {
+		labelled[hot.Key] = struct{}{}
+	}
+
+	var removed []string
+	for key := range l.labelled {
+		if _, ok := labelled[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+
+	l.labelled = labelled
+	l.counts = newSpaceSaving(2 * l.limit)
+	l.refreshed = now
+
+	return removed
+}
+
+// recordKeyDecision counts a decision under the label of the key
+func (r *RateLimiter) recordKeyDecision(ctx context.Context, key string, allowed bool) {
+	collector := r.metrics.(KeyCollector)
+	label, removed := r.keyLabels.label(key, time.Now())
+
+	for _, key := range removed {
+		collector.RemoveKey(ctx, key)
+	}
+
+	decision := "allowed"
+	if !allowed {
+		decision = "denied"
+	}
+	collector.IncKeyDecision(ctx, label, decision)
+}
@@ -0,0 +1,100 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestKeyLabeler(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	labeler := newKeyLabeler(2, start)
+
+	label := func(key string, now time.Time) string {
+		got, _ := labeler.label(key, now)
+		return got
+	}
+
+	// The first keys take the free slots, later ones are counted as other
+	for _, key := range []string{"a", "b"} {
+		if got := label(key, start); got != key {
+			t.Errorf("expected %s to be labelled, got %s", key, got)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if got := label("c", start); got != otherKeyLabel {
+			t.Errorf("expected c to be counted as other, got %s", got)
+		}
+	}
+	label("a", start)
+
+	// A refresh hands the labels to the most requested keys
+	got, removed := labeler.label("c", start.Add(keyLabelRefresh))
+	if got != "c" {
+		t.Errorf("expected c to be labelled after the refresh, got %s", got)
+	}
+	if len(removed) != 1 || removed[0] != "b" {
+		t.Errorf("expected b to lose its label, got %v", removed)
+	}
+	if got := label("b", start.Add(keyLabelRefresh)); got != otherKeyLabel {
+		t.Errorf("expected b to be counted as other, got %s", got)
+	}
+}
+
+func TestPrometheusKeyDecisions(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+
+	collector, err := NewPrometheusCollector(registry)
+	if err != nil {
+		t.Fatalf("failed to create collector: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.KeyMetricsLimit = 1
+
+	limiter, err := New(&mockBackend{}, cfg, WithMetricsCollector(collector))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	for _, key := range []string{"alice", "bob", "carol", "alice"} {
+		limiter.Take(ctx, key, 1)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	counts := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "ratelimiter_key_decisions_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "key" {
+					counts[label.GetValue()] += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+
+	if len(counts) != 2 || counts["alice"] != 2 || counts[otherKeyLabel] != 2 {
+		t.Errorf("expected 2 decisions for alice and 2 for other, got %v", counts)
+	}
+
+	// Removing a key deletes its series
+	collector.(KeyCollector).RemoveKey(ctx, "alice")
+	families, _ = registry.Gather()
+	for _, family := range families {
+		if family.GetName() == "ratelimiter_key_decisions_total" && len(family.GetMetric()) != 1 {
+			t.Errorf("expected only the other series to remain, got %d series", len(family.GetMetric()))
+		}
+	}
+}
@@ -3,24 +3,80 @@ package limiter
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/devrob-go/go-rate-limiter/pkg/backend"
 	"github.com/devrob-go/go-rate-limiter/pkg/config"
 	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
+// maxWaitSleep bounds how long Wait goes without reading the backend when it receives wakeups
+const maxWaitSleep = time.Second
+
 // RateLimiter provides rate limiting functionality with configurable backends
 type RateLimiter struct {
 	backend backend.Backend
 	config  *config.Config
 	mu      sync.RWMutex
 	closed  bool
+	done    chan struct{}
+
+	waitMu  sync.Mutex
+	waiters map[string][]*waiter
+	waitSeq uint64
+
+	meterProvider metric.MeterProvider
+	metrics       MetricsCollector
+	stopMetrics   chan struct{}
+	metricsDone   chan struct{}
+	tracer        trace.Tracer
+	tracing       bool
+	expvars       *expvarCounters
+	logger        *slog.Logger
+	events        *eventLogger
+	bus           eventBus
+	audit         AuditSink
+	hotKeys       *hotKeyTracker
+	keyLabels     *keyLabeler
+	denyRatio     *denyRatioWatcher
+	cost          CostFunc
+
+	keyChanges           []KeyChangeCallback
+	unsubscribeEvictions func()
+
+	tierMu sync.RWMutex
+	tiers  map[string]Tier
+
+	// tierGen changes whenever a tier is set or removed, so handles know when to look their tier up again
+	tierGen atomic.Uint64
+
+	// globalLimitSet is when the global limit was last written, in Unix nanoseconds
+	globalLimitSet atomic.Int64
+
+	shedder *loadShedder
+
+	allowedCount atomic.Uint64
+	deniedCount  atomic.Uint64
+	errorCount   atomic.Uint64
+	created      time.Time
+
+	// recent counts decisions over the last minute once a status handler is created, nil until then
+	recent atomic.Pointer[recentDecisions]
 }
 
+// Option configures optional behavior of a RateLimiter
+type Option func(*RateLimiter)
+
 // New creates a new rate limiter with the given backend and configuration
-func New(backend backend.Backend, cfg *config.Config) (*RateLimiter, error) {
+func New(backend backend.Backend, cfg *config.Config, opts ...Option) (*RateLimiter, error) {
 	if backend == nil {
 		return nil, errors.Wrap(errors.ErrBackendUnavailable, "backend cannot be nil")
 	}
@@ -33,24 +89,110 @@ func New(backend backend.Backend, cfg *config.Config) (*RateLimiter, error) {
 		return nil, errors.Wrap(err, "invalid configuration")
 	}
 
-	return &RateLimiter{
+	limiter := &RateLimiter{
 		backend: backend,
 		config:  cfg,
-	}, nil
+		done:    make(chan struct{}),
+		tracer:  noop.NewTracerProvider().Tracer(instrumentationName),
+		tiers:   make(map[string]Tier, len(cfg.Templates)),
+		created: time.Now(),
+	}
+
+	// Templates of the configuration are tiers, which WithTiers may override
+	for name, template := range cfg.Templates {
+		limiter.tiers[name] = templateTier(template)
+	}
+
+	for _, opt := range opts {
+		opt(limiter)
+	}
+
+	if limiter.metrics == nil && limiter.meterProvider != nil {
+		collector, err := NewOtelCollector(limiter.meterProvider)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create metric instruments")
+		}
+		limiter.metrics = collector
+	}
+
+	if !cfg.EnableMetrics {
+		limiter.metrics = nil
+	}
+
+	if limiter.metrics != nil {
+		limiter.startActiveKeysReporter(activeKeysInterval)
+
+		if _, ok := limiter.metrics.(KeyCollector); ok && cfg.KeyMetricsLimit > 0 {
+			limiter.keyLabels = newKeyLabeler(cfg.KeyMetricsLimit, time.Now())
+		}
+	}
+
+	if cfg.EnableLogging {
+		if limiter.logger == nil {
+			limiter.logger = slog.Default()
+		}
+		limiter.events = &eventLogger{
+			logger:        limiter.logger,
+			denials:       &logSampler{limit: cfg.Logging.DenialsPerSecond},
+			errors:        &logSampler{limit: cfg.Logging.ErrorsPerSecond},
+			configChanges: &logSampler{limit: cfg.Logging.ConfigChangesPerSecond},
+		}
+	}
+
+	if limiter.denyRatio != nil {
+		if err := limiter.denyRatio.config.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid deny ratio alert")
+		}
+	}
+
+	if limiter.shedder != nil {
+		if err := limiter.shedder.options.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid load shedding options")
+		}
+		limiter.startLoadShedder()
+	}
+
+	if err := limiter.validateTiers(); err != nil {
+		return nil, errors.Wrap(err, "invalid tiers")
+	}
+
+	if cfg.HotKeysCapacity > 0 {
+		limiter.hotKeys = newHotKeyTracker(cfg.HotKeysCapacity)
+	}
+
+	if cfg.EnableExpvar {
+		counters, err := publishExpvar(cfg.ExpvarName, backend)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to publish expvar counters")
+		}
+		limiter.expvars = counters
+	}
+
+	limiter.subscribeEvictions()
+
+	return limiter, nil
 }
 
 // Take attempts to consume the specified number of tokens from the bucket
 // Returns true if tokens were successfully consumed, false if rate limit exceeded
-func (r *RateLimiter) Take(ctx context.Context, key string, tokens int) (bool, error) {
+func (r *RateLimiter) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	return r.take(ctx, key, r.validateKey(key), tokens)
+}
+
+// take consumes tokens from a key already validated, keyErr is the result of its validation
+func (r *RateLimiter) take(ctx context.Context, key string, keyErr error, tokens int64) (bool, error) {
+	ctx, span := r.startSpan(ctx, "ratelimiter.Take", key, tokens)
+	defer span.End()
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	if r.closed {
-		return false, errors.Wrap(errors.ErrBackendUnavailable, "rate limiter is closed")
+		return false, errors.ErrLimiterClosed
 	}
 
-	if err := r.validateKey(key); err != nil {
-		return false, err
+	if keyErr != nil {
+		return false, keyErr
 	}
 
 	if err := r.validateTokens(tokens); err != nil {
@@ -65,25 +207,32 @@ func (r *RateLimiter) Take(ctx context.Context, key string, tokens int) (bool, e
 	}
 
 	// Attempt to take tokens from the backend
-	allowed, err := r.backend.Take(ctx, key, tokens)
+	allowed, err := r.takeKey(ctx, key, r.shedKeyTokens(ctx, []string{key}, tokens))
 	if err != nil {
 		return false, errors.Wrap(err, "failed to take tokens from backend")
 	}
 
+	r.observeDecision(ctx, "take", key, tokens, allowed)
 	return allowed, nil
 }
 
 // TakeWithLimit attempts to consume tokens with a custom limit for the key
-func (r *RateLimiter) TakeWithLimit(ctx context.Context, key string, tokens int, limit int, refill time.Duration) (bool, error) {
+// The limit plus the debt allowance may be at most backend.MaxLimit, see backend.Backend.SetLimit for the in-memory caveat
+func (r *RateLimiter) TakeWithLimit(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration) (bool, error) {
+	return r.takeWithLimit(ctx, key, r.validateKey(key), tokens, limit, refill)
+}
+
+// takeWithLimit consumes tokens with a custom limit from a key already validated, keyErr is the result of its validation
+func (r *RateLimiter) takeWithLimit(ctx context.Context, key string, keyErr error, tokens int64, limit int64, refill time.Duration) (bool, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	if r.closed {
-		return false, errors.Wrap(errors.ErrBackendUnavailable, "rate limiter is closed")
+		return false, errors.ErrLimiterClosed
 	}
 
-	if err := r.validateKey(key); err != nil {
-		return false, err
+	if keyErr != nil {
+		return false, keyErr
 	}
 
 	if err := r.validateTokens(tokens); err != nil {
@@ -98,13 +247,115 @@ func (r *RateLimiter) TakeWithLimit(ctx context.Context, key string, tokens int,
 		return false, errors.Wrap(errors.ErrInvalidTokens, "refill rate must be positive")
 	}
 
+	// Backends that support it set the limit and take in a single round trip, unless the global bucket takes part
+	if taker, ok := r.backend.(backend.LimitTaker); ok && r.config.GlobalLimit == 0 {
+		start := time.Now()
+		opCtx, done := r.withTimeout(ctx, "take_with_limit")
+		allowed, err := taker.TakeWithLimit(opCtx, key, r.shedTokens(tokens, limit), limit, refill)
+		err = done(err)
+		r.observeBackend(ctx, "take_with_limit", start, err)
+		if err != nil {
+			return false, err
+		}
+
+		r.observeDecision(ctx, "take_with_limit", key, tokens, allowed)
+		return allowed, nil
+	}
+
 	// Set custom limit for this key
-	if err := r.backend.SetLimit(ctx, key, limit, refill); err != nil {
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "set_limit")
+	err := done(r.backend.SetLimit(opCtx, key, limit, refill))
+	r.observeBackend(ctx, "set_limit", start, err)
+	if err != nil {
 		return false, errors.Wrap(err, "failed to set custom limit")
 	}
 
 	// Attempt to take tokens
-	return r.backend.Take(ctx, key, tokens)
+	allowed, err := r.takeKey(ctx, key, r.shedTokens(tokens, limit))
+	if err != nil {
+		return false, err
+	}
+
+	r.observeDecision(ctx, "take_with_limit", key, tokens, allowed)
+	return allowed, nil
+}
+
+// TakeInGroup attempts to consume tokens from both the key bucket and the shared bucket of its group
+// Tokens are only consumed if both buckets have enough, in a single atomic backend call
+func (r *RateLimiter) TakeInGroup(ctx context.Context, key string, group string, tokens int64) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return false, errors.ErrLimiterClosed
+	}
+
+	if err := r.validateKey(key); err != nil {
+		return false, err
+	}
+
+	if err := r.validateKey(groupKey(group)); err != nil {
+		return false, err
+	}
+
+	if err := r.validateTokens(tokens); err != nil {
+		return false, err
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return false, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	keys := []string{key, groupKey(group)}
+	allowed, err := r.takeAll(ctx, keys, r.shedKeyTokens(ctx, keys, tokens))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to take tokens from backend")
+	}
+
+	r.observeDecision(ctx, "take_in_group", key, tokens, allowed)
+	return allowed, nil
+}
+
+// SetGroupLimit sets the limit of the bucket shared by all keys of a group
+func (r *RateLimiter) SetGroupLimit(ctx context.Context, group string, limit int64, refill time.Duration) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return errors.ErrLimiterClosed
+	}
+
+	if err := r.validateKey(groupKey(group)); err != nil {
+		return err
+	}
+
+	if limit <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "limit must be positive")
+	}
+
+	if refill <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "refill rate must be positive")
+	}
+
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "set_limit")
+	err := done(r.backend.SetLimit(opCtx, groupKey(group), limit, refill))
+	r.observeBackend(ctx, "set_limit", start, err)
+	if err != nil {
+		return errors.Wrap(err, "failed to set group limit")
+	}
+
+	r.logConfigChange(ctx, "set_group_limit",
+		slog.String("group", group),
+		slog.Int64("limit", limit),
+		slog.Duration("refill", refill),
+	)
+	r.notifyKeyChange(ctx, KeyLimitChanged, groupKey(group), limit, refill)
+	return nil
 }
 
 // Reset clears the rate limit for a specific key
@@ -113,63 +364,306 @@ func (r *RateLimiter) Reset(ctx context.Context, key string) error {
 	defer r.mu.RUnlock()
 
 	if r.closed {
-		return errors.Wrap(errors.ErrBackendUnavailable, "rate limiter is closed")
+		return errors.ErrLimiterClosed
 	}
 
 	if err := r.validateKey(key); err != nil {
 		return err
 	}
 
-	return r.backend.Reset(ctx, key)
+	opCtx, done := r.withTimeout(ctx, "reset")
+	if err := done(r.backend.Reset(opCtx, key)); err != nil {
+		return err
+	}
+
+	r.notifyKeyChange(ctx, KeyReset, key, 0, 0)
+	return nil
 }
 
 // GetInfo returns information about the current state of a key
 func (r *RateLimiter) GetInfo(ctx context.Context, key string) (*backend.TokenInfo, error) {
+	ctx, span := r.startSpan(ctx, "ratelimiter.GetInfo", key, 0)
+	defer span.End()
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	if r.closed {
-		return nil, errors.Wrap(errors.ErrBackendUnavailable, "rate limiter is closed")
+		return nil, errors.ErrLimiterClosed
 	}
 
 	if err := r.validateKey(key); err != nil {
 		return nil, err
 	}
 
-	return r.backend.GetInfo(ctx, key)
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "get_info")
+	info, err := r.backend.GetInfo(opCtx, key)
+	err = done(err)
+	r.observeBackend(ctx, "get_info", start, err)
+
+	return info, err
+}
+
+// GetStats returns usage totals across every key as computed by the backend, on backends implementing backend.StatsReporter
+// On shared backends such as Redis the totals cover every instance
+func (r *RateLimiter) GetStats(ctx context.Context) (backend.Stats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return backend.Stats{}, errors.ErrLimiterClosed
+	}
+
+	reporter, ok := r.backend.(backend.StatsReporter)
+	if !ok {
+		return backend.Stats{}, errors.Wrap(errors.ErrBackendUnavailable, "backend does not report stats")
+	}
+
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "stats")
+	stats, err := reporter.Stats(opCtx)
+	err = done(err)
+	r.observeBackend(ctx, "stats", start, err)
+
+	return stats, err
+}
+
+// Block denies all Takes for a specific key until the duration expires
+// The block is stored in the backend so it is enforced across instances
+func (r *RateLimiter) Block(ctx context.Context, key string, duration time.Duration) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return errors.ErrLimiterClosed
+	}
+
+	if err := r.validateKey(key); err != nil {
+		return err
+	}
+
+	if duration <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "block duration must be positive")
+	}
+
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "block")
+	err := done(r.backend.Block(opCtx, key, duration))
+	r.observeBackend(ctx, "block", start, err)
+	if err != nil {
+		return errors.Wrap(err, "failed to block key")
+	}
+
+	r.logConfigChange(ctx, "block",
+		slog.String("key", key),
+		slog.Duration("duration", duration),
+	)
+	return nil
+}
+
+// Unblock lifts a block on a specific key before it expires
+func (r *RateLimiter) Unblock(ctx context.Context, key string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return errors.ErrLimiterClosed
+	}
+
+	if err := r.validateKey(key); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "unblock")
+	err := done(r.backend.Unblock(opCtx, key))
+	r.observeBackend(ctx, "unblock", start, err)
+	if err != nil {
+		return errors.Wrap(err, "failed to unblock key")
+	}
+
+	r.logConfigChange(ctx, "unblock", slog.String("key", key))
+	return nil
+}
+
+// SetRefillStrategy changes how the bucket of a key refills, on backends implementing backend.RefillStrategySetter
+// A nil strategy restores linear refills, and like a custom limit the strategy lasts as long as the bucket
+func (r *RateLimiter) SetRefillStrategy(ctx context.Context, key string, strategy backend.RefillStrategy) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return errors.ErrLimiterClosed
+	}
+
+	if err := r.validateKey(key); err != nil {
+		return err
+	}
+
+	setter, ok := r.backend.(backend.RefillStrategySetter)
+	if !ok {
+		return errors.Wrap(errors.ErrBackendUnavailable, "backend does not support refill strategies")
+	}
+
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "set_refill_strategy")
+	err := done(setter.SetRefillStrategy(opCtx, key, strategy))
+	r.observeBackend(ctx, "set_refill_strategy", start, err)
+	if err != nil {
+		return errors.Wrap(err, "failed to set refill strategy")
+	}
+
+	name := "linear"
+	if strategy != nil {
+		name = strategy.String()
+	}
+	r.logConfigChange(ctx, "set_refill_strategy",
+		slog.String("key", key),
+		slog.String("strategy", name),
+	)
+	return nil
 }
 
-// IsAllowed checks if a request would be allowed without consuming tokens
-func (r *RateLimiter) IsAllowed(ctx context.Context, key string, tokens int) (bool, error) {
+// IsAllowed checks if a request would be allowed without consuming tokens, which it never is while the key is blocked
+// Under load shedding the tokens are scaled as a take would scale them
+func (r *RateLimiter) IsAllowed(ctx context.Context, key string, tokens int64) (bool, error) {
 	info, err := r.GetInfo(ctx, key)
 	if err != nil {
 		return false, err
 	}
 
-	return info.Tokens >= tokens, nil
+	return available(info, r.shedTokens(tokens, info.MaxTokens), time.Now()), nil
 }
 
 // Wait waits until tokens become available or context is cancelled
-func (r *RateLimiter) Wait(ctx context.Context, key string, tokens int) error {
+func (r *RateLimiter) Wait(ctx context.Context, key string, tokens int64) error {
+	return r.WaitWithPriority(ctx, key, tokens, PriorityNormal)
+}
+
+// WaitWithPriority waits until tokens become available or context is cancelled
+// When several callers wait on the same key, higher priority waiters are admitted first
+// Waiters are promoted one level per WaitAgingInterval so lower classes are not starved
+// Once WaitQueueDepth callers wait on the key, further callers fail at once with a QueueFullError
+// On backends announcing wakeups, the backend is only read when the next refill is due or a wakeup arrives
+// Under load shedding the tokens are scaled as a take would scale them
+func (r *RateLimiter) WaitWithPriority(ctx context.Context, key string, tokens int64, priority Priority) error {
+	ctx, span := r.startSpan(ctx, "ratelimiter.Wait", key, tokens)
+	defer span.End()
+
+	w := r.enqueueWaiter(key, priority)
+	if w == nil {
+		if r.tracing {
+			traceDecision(ctx, false)
+		}
+		return &errors.QueueFullError{Message: "wait queue is full", Key: key, Depth: r.config.WaitQueueDepth}
+	}
+	defer r.dequeueWaiter(key, w)
+
+	var wakeups <-chan struct{}
+	if notifier, ok := r.backend.(backend.WakeupNotifier); ok {
+		var unsubscribe func()
+		wakeups, unsubscribe = notifier.SubscribeWakeups(key)
+		defer unsubscribe()
+	}
+
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
+	var nextCheck time.Time
 	for {
 		select {
 		case <-ctx.Done():
 			return errors.Wrap(ctx.Err(), "context cancelled while waiting")
+		case <-r.done:
+			return errors.ErrLimiterClosed
+		case <-wakeups:
+			nextCheck = time.Time{}
 		case <-ticker.C:
-			allowed, err := r.IsAllowed(ctx, key, tokens)
-			if err != nil {
-				return err
-			}
-			if allowed {
-				return nil
+		}
+
+		if !r.isNextWaiter(key, w) {
+			continue
+		}
+
+		if wakeups != nil && time.Now().Before(nextCheck) {
+			continue
+		}
+
+		info, err := r.GetInfo(ctx, key)
+		if err != nil {
+			return err
+		}
+		shed := r.shedTokens(tokens, info.MaxTokens)
+		if available(info, shed, time.Now()) {
+			if r.tracing {
+				traceDecision(ctx, true)
 			}
+			return nil
+		}
+
+		nextCheck = nextAvailable(info, shed, time.Now())
+	}
+}
+
+// waitAndTake takes tokens from a key through take, waiting for them whenever it is denied, or fails once ctx is done
+// Another caller may take the tokens between the wait and the take, the caller then waits again
+// Like x/time/rate, it fails at once when the tokens exceed what the key or the global bucket can ever hold
+func (r *RateLimiter) waitAndTake(ctx context.Context, key string, tokens int64, take func() (bool, error)) error {
+	for {
+		allowed, err := take()
+		if err != nil || allowed {
+			return err
+		}
+
+		info, err := r.GetInfo(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		capacity := info.MaxTokens
+		if r.config.GlobalLimit > 0 {
+			capacity = min(capacity, r.config.GlobalLimit)
+		}
+		if tokens > capacity {
+			return errors.Wrapf(errors.ErrInvalidTokens, "waiting for %d tokens exceeds the capacity %d of the key", tokens, capacity)
+		}
+
+		if err := r.Wait(ctx, key, tokens); err != nil {
+			return err
 		}
 	}
 }
 
+// available reports whether a key holds the tokens and is not blocked at now
+func available(info *backend.TokenInfo, tokens int64, now time.Time) bool {
+	return info.Tokens >= tokens && !now.Before(info.BlockedUntil)
+}
+
+// nextAvailable estimates when enough tokens will be available for a waiter, capped at maxWaitSleep from now
+func nextAvailable(info *backend.TokenInfo, tokens int64, now time.Time) time.Time {
+	limit := now.Add(maxWaitSleep)
+
+	if info.BlockedUntil.After(now) {
+		if info.BlockedUntil.Before(limit) {
+			return info.BlockedUntil
+		}
+		return limit
+	}
+
+	// The first refill lands at NextRefill, and each one after it brings a token unless a strategy says otherwise
+	intervals := tokens - info.Tokens
+	if info.Strategy != nil {
+		intervals = max(1, backend.RefillIntervals(info.Strategy, info.MaxTokens-info.Tokens, intervals))
+	}
+	at := info.NextRefill.Add(time.Duration(intervals-1) * info.RefillRate)
+	if at.After(limit) {
+		return limit
+	}
+
+	return at
+}
+
 // Close gracefully shuts down the rate limiter
 func (r *RateLimiter) Close(ctx context.Context) error {
 	r.mu.Lock()
@@ -180,6 +674,17 @@ func (r *RateLimiter) Close(ctx context.Context) error {
 	}
 
 	r.closed = true
+	close(r.done)
+	r.bus.close()
+
+	if r.unsubscribeEvictions != nil {
+		r.unsubscribeEvictions()
+	}
+
+	if r.stopMetrics != nil {
+		close(r.stopMetrics)
+		<-r.metricsDone
+	}
 
 	if err := r.backend.Close(ctx); err != nil {
 		return errors.Wrap(err, "failed to close backend")
@@ -194,10 +699,11 @@ func (r *RateLimiter) HealthCheck(ctx context.Context) error {
 	defer r.mu.RUnlock()
 
 	if r.closed {
-		return errors.Wrap(errors.ErrBackendUnavailable, "rate limiter is closed")
+		return errors.ErrLimiterClosed
 	}
 
-	return r.backend.HealthCheck(ctx)
+	opCtx, done := r.withTimeout(ctx, "health_check")
+	return done(r.backend.HealthCheck(opCtx))
 }
 
 // GetConfig returns a copy of the current configuration
@@ -211,30 +717,137 @@ func (r *RateLimiter) GetConfig() *config.Config {
 
 	// Return a copy to prevent external modification
 	configCopy := *r.config
+	configCopy.Templates = maps.Clone(r.config.Templates)
+	configCopy.Redis.ReadReplicas = slices.Clone(r.config.Redis.ReadReplicas)
+	for name, template := range configCopy.Templates {
+		template.Schedule = slices.Clone(template.Schedule)
+		configCopy.Templates[name] = template
+	}
 	return &configCopy
 }
 
+// observeDecision records an allow or deny decision on the active span, metrics, logs and audit sink
+func (r *RateLimiter) observeDecision(ctx context.Context, operation string, key string, tokens int64, allowed bool) {
+	if r.tracing {
+		traceDecision(ctx, allowed)
+	}
+
+	if allowed {
+		r.allowedCount.Add(1)
+	} else {
+		r.deniedCount.Add(1)
+	}
+	if recent := r.recent.Load(); recent != nil {
+		recent.record(time.Now(), allowed)
+	}
+
+	if r.events != nil && !allowed {
+		r.events.logDenial(ctx, operation, key, tokens)
+	}
+
+	if r.hotKeys != nil {
+		r.hotKeys.record(key, allowed)
+	}
+
+	if r.denyRatio != nil {
+		r.checkDenyRatio(ctx, key, allowed)
+	}
+
+	if r.audit != nil && !allowed {
+		r.auditDenial(ctx, operation, key, tokens)
+	}
+
+	r.publishDecision(ctx, operation, key, tokens, allowed)
+
+	if r.metrics != nil {
+		if allowed {
+			r.metrics.IncAllowed(ctx, operation)
+		} else {
+			r.metrics.IncDenied(ctx, operation)
+		}
+
+		if r.keyLabels != nil {
+			r.recordKeyDecision(ctx, key, allowed)
+		}
+	}
+
+	if r.expvars != nil {
+		r.expvars.recordDecision(allowed)
+	}
+}
+
+// observeBackend records the latency and outcome of a backend call on the active span, metrics and logs
+func (r *RateLimiter) observeBackend(ctx context.Context, operation string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	if r.tracing {
+		traceBackend(ctx, operation, elapsed, err)
+	}
+
+	if err != nil {
+		r.errorCount.Add(1)
+	}
+
+	if r.events != nil && err != nil {
+		r.events.logBackendError(ctx, operation, err)
+	}
+
+	if r.metrics != nil {
+		r.metrics.ObserveLatency(ctx, operation, elapsed)
+		if err != nil {
+			r.metrics.IncError(ctx, operation, errors.Classify(err))
+		}
+	}
+
+	if r.expvars != nil && err != nil {
+		r.expvars.recordError()
+	}
+}
+
+// logConfigChange logs a change of limits or blocks when logging is enabled
+func (r *RateLimiter) logConfigChange(ctx context.Context, change string, attrs ...slog.Attr) {
+	if r.events != nil {
+		r.events.logConfigChange(ctx, change, attrs...)
+	}
+}
+
+// groupKey returns the backend key of the shared bucket for a group
+func groupKey(group string) string {
+	if group == "" {
+		return ""
+	}
+
+	return "group:" + group
+}
+
+// Validation errors are wrapped once so that rejecting a request on the hot path never allocates
+var (
+	errEmptyKey          = errors.Wrap(errors.ErrInvalidKey, "key cannot be empty")
+	errKeyTooLong        = errors.Wrap(errors.ErrInvalidKey, "key too long (max 256 characters)")
+	errTokensNotPositive = errors.Wrap(errors.ErrInvalidTokens, "tokens must be positive")
+	errTokensTooMany     = errors.Wrap(errors.ErrInvalidTokens, "tokens exceed reasonable limit")
+)
+
 // validateKey validates the key parameter
 func (r *RateLimiter) validateKey(key string) error {
 	if key == "" {
-		return errors.Wrap(errors.ErrInvalidKey, "key cannot be empty")
+		return errEmptyKey
 	}
 
 	if len(key) > 256 {
-		return errors.Wrap(errors.ErrInvalidKey, "key too long (max 256 characters)")
+		return errKeyTooLong
 	}
 
 	return nil
 }
 
 // validateTokens validates the tokens parameter
-func (r *RateLimiter) validateTokens(tokens int) error {
+func (r *RateLimiter) validateTokens(tokens int64) error {
 	if tokens <= 0 {
-		return errors.Wrap(errors.ErrInvalidTokens, "tokens must be positive")
+		return errTokensNotPositive
 	}
 
 	if tokens > r.config.DefaultLimit*10 {
-		return errors.Wrap(errors.ErrInvalidTokens, "tokens exceed reasonable limit")
+		return errTokensTooMany
 	}
 
 	return nil
@@ -2,30 +2,43 @@ package limiter
 
 import (
 	"context"
+	stderrors "errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/devrob-go/go-rate-limiter/pkg/backend"
 	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
 )
 
 // mockBackend is a mock implementation of the Backend interface for testing
 type mockBackend struct {
-	takeFunc     func(ctx context.Context, key string, tokens int) (bool, error)
+	takeFunc     func(ctx context.Context, key string, tokens int64) (bool, error)
+	takeAllFunc  func(ctx context.Context, keys []string, tokens int64) (bool, error)
 	resetFunc    func(ctx context.Context, key string) error
 	getInfoFunc  func(ctx context.Context, key string) (*backend.TokenInfo, error)
-	setLimitFunc func(ctx context.Context, key string, limit int, refill time.Duration) error
+	setLimitFunc func(ctx context.Context, key string, limit int64, refill time.Duration) error
+	blockFunc    func(ctx context.Context, key string, duration time.Duration) error
+	unblockFunc  func(ctx context.Context, key string) error
 	closeFunc    func(ctx context.Context) error
 	healthFunc   func(ctx context.Context) error
 }
 
-func (m *mockBackend) Take(ctx context.Context, key string, tokens int) (bool, error) {
+func (m *mockBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
 	if m.takeFunc != nil {
 		return m.takeFunc(ctx, key, tokens)
 	}
 	return true, nil
 }
 
+func (m *mockBackend) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	if m.takeAllFunc != nil {
+		return m.takeAllFunc(ctx, keys, tokens)
+	}
+	return true, nil
+}
+
 func (m *mockBackend) Reset(ctx context.Context, key string) error {
 	if m.resetFunc != nil {
 		return m.resetFunc(ctx, key)
@@ -48,13 +61,27 @@ func (m *mockBackend) GetInfo(ctx context.Context, key string) (*backend.TokenIn
 	}, nil
 }
 
-func (m *mockBackend) SetLimit(ctx context.Context, key string, limit int, refill time.Duration) error {
+func (m *mockBackend) SetLimit(ctx context.Context, key string, limit int64, refill time.Duration) error {
 	if m.setLimitFunc != nil {
 		return m.setLimitFunc(ctx, key, limit, refill)
 	}
 	return nil
 }
 
+func (m *mockBackend) Block(ctx context.Context, key string, duration time.Duration) error {
+	if m.blockFunc != nil {
+		return m.blockFunc(ctx, key, duration)
+	}
+	return nil
+}
+
+func (m *mockBackend) Unblock(ctx context.Context, key string) error {
+	if m.unblockFunc != nil {
+		return m.unblockFunc(ctx, key)
+	}
+	return nil
+}
+
 func (m *mockBackend) Close(ctx context.Context) error {
 	_ = ctx // Use context parameter to avoid linter warning
 	if m.closeFunc != nil {
@@ -162,10 +189,10 @@ func TestTake(t *testing.T) {
 func TestTakeWithLimit(t *testing.T) {
 	ctx := context.Background()
 	backend := &mockBackend{
-		setLimitFunc: func(ctx context.Context, key string, limit int, refill time.Duration) error {
+		setLimitFunc: func(ctx context.Context, key string, limit int64, refill time.Duration) error {
 			return nil
 		},
-		takeFunc: func(ctx context.Context, key string, tokens int) (bool, error) {
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
 			return true, nil
 		},
 	}
@@ -210,6 +237,111 @@ func TestTakeWithLimit(t *testing.T) {
 	}
 }
 
+// limitTakerBackend is a mockBackend that sets a limit and takes in one call
+type limitTakerBackend struct {
+	mockBackend
+	takeWithLimitFunc func(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration) (bool, error)
+}
+
+func (m *limitTakerBackend) TakeWithLimit(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration) (bool, error) {
+	return m.takeWithLimitFunc(ctx, key, tokens, limit, refill)
+}
+
+func TestTakeWithLimitSingleCall(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	backend := &limitTakerBackend{
+		mockBackend: mockBackend{
+			setLimitFunc: func(ctx context.Context, key string, limit int64, refill time.Duration) error {
+				t.Error("expected SetLimit not to be called")
+				return nil
+			},
+			takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+				t.Error("expected Take not to be called")
+				return true, nil
+			},
+		},
+		takeWithLimitFunc: func(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration) (bool, error) {
+			calls++
+			if limit != 50 || refill != 2*time.Second {
+				t.Errorf("expected limit 50 per 2s, got %d per %v", limit, refill)
+			}
+			return false, nil
+		},
+	}
+
+	limiter, err := New(backend, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	allowed, err := limiter.TakeWithLimit(ctx, "test_key", 1, 50, 2*time.Second)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected request to be denied")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 TakeWithLimit call, got %d", calls)
+	}
+}
+
+func TestTakeInGroup(t *testing.T) {
+	ctx := context.Background()
+	var takenKeys []string
+	var limitKey string
+	backend := &mockBackend{
+		takeAllFunc: func(ctx context.Context, keys []string, tokens int64) (bool, error) {
+			takenKeys = keys
+			return true, nil
+		},
+		setLimitFunc: func(ctx context.Context, key string, limit int64, refill time.Duration) error {
+			limitKey = key
+			return nil
+		},
+	}
+	config := config.DefaultConfig()
+
+	limiter, err := New(backend, config)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	// Test setting the shared group limit
+	err = limiter.SetGroupLimit(ctx, "org_1", 10000, time.Millisecond)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if limitKey != "group:org_1" {
+		t.Errorf("expected group limit on group:org_1, got %s", limitKey)
+	}
+
+	// Test consuming from key and group in one call
+	allowed, err := limiter.TakeInGroup(ctx, "user_1", "org_1", 1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Error("expected request to be allowed")
+	}
+	if len(takenKeys) != 2 || takenKeys[0] != "user_1" || takenKeys[1] != "group:org_1" {
+		t.Errorf("expected keys [user_1 group:org_1], got %v", takenKeys)
+	}
+
+	// Test empty group
+	_, err = limiter.TakeInGroup(ctx, "user_1", "", 1)
+	if err == nil {
+		t.Error("expected error for empty group")
+	}
+
+	// Test invalid group limit
+	err = limiter.SetGroupLimit(ctx, "org_1", 0, time.Second)
+	if err == nil {
+		t.Error("expected error for zero limit")
+	}
+}
+
 func TestReset(t *testing.T) {
 	ctx := context.Background()
 	backend := &mockBackend{}
@@ -233,6 +365,64 @@ func TestReset(t *testing.T) {
 	}
 }
 
+func TestBlock(t *testing.T) {
+	ctx := context.Background()
+	var blockedKey string
+	var blockedFor time.Duration
+	var unblockedKey string
+	backend := &mockBackend{
+		blockFunc: func(ctx context.Context, key string, duration time.Duration) error {
+			blockedKey = key
+			blockedFor = duration
+			return nil
+		},
+		unblockFunc: func(ctx context.Context, key string) error {
+			unblockedKey = key
+			return nil
+		},
+	}
+	config := config.DefaultConfig()
+
+	limiter, err := New(backend, config)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	// Test successful block
+	err = limiter.Block(ctx, "test_key", time.Minute)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if blockedKey != "test_key" || blockedFor != time.Minute {
+		t.Errorf("expected backend block of test_key for 1m, got %s for %v", blockedKey, blockedFor)
+	}
+
+	// Test successful unblock
+	err = limiter.Unblock(ctx, "test_key")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if unblockedKey != "test_key" {
+		t.Errorf("expected backend unblock of test_key, got %s", unblockedKey)
+	}
+
+	// Test invalid duration
+	err = limiter.Block(ctx, "test_key", 0)
+	if err == nil {
+		t.Error("expected error for zero duration")
+	}
+
+	// Test empty key
+	err = limiter.Block(ctx, "", time.Minute)
+	if err == nil {
+		t.Error("expected error for empty key")
+	}
+	err = limiter.Unblock(ctx, "")
+	if err == nil {
+		t.Error("expected error for empty key")
+	}
+}
+
 func TestGetInfo(t *testing.T) {
 	ctx := context.Background()
 	backend := &mockBackend{}
@@ -263,6 +453,43 @@ func TestGetInfo(t *testing.T) {
 	}
 }
 
+func TestGetStats(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 3, time.Hour)
+
+	limiter.Take(ctx, "a", 2)
+	limiter.Take(ctx, "b", 1)
+	limiter.Take(ctx, "a", 2)
+
+	stats, err := limiter.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Keys != 2 {
+		t.Errorf("expected 2 keys, got %d", stats.Keys)
+	}
+	if stats.Allowed != 2 || stats.Denied != 1 {
+		t.Errorf("expected 2 allowed and 1 denied, got %d and %d", stats.Allowed, stats.Denied)
+	}
+	if stats.TokensConsumed != 3 {
+		t.Errorf("expected 3 tokens consumed, got %d", stats.TokensConsumed)
+	}
+
+	// Backends without stats
+	mock, err := New(&mockBackend{}, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	if _, err := mock.GetStats(ctx); !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected backend unavailable error, got %v", err)
+	}
+
+	limiter.Close(ctx)
+	if _, err := limiter.GetStats(ctx); !stderrors.Is(err, errors.ErrLimiterClosed) {
+		t.Errorf("expected limiter closed error, got %v", err)
+	}
+}
+
 func TestIsAllowed(t *testing.T) {
 	ctx := context.Background()
 	backend := &mockBackend{
@@ -363,6 +590,190 @@ func TestWait(t *testing.T) {
 	}
 }
 
+func TestBlockedKeyNotAvailable(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 10, time.Second)
+
+	if err := limiter.Block(ctx, "test_key", 300*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	allowed, err := limiter.IsAllowed(ctx, "test_key", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected a blocked key not to be allowed")
+	}
+
+	// Wait returns once the block ends, and a Take then succeeds
+	start := time.Now()
+	if err := limiter.Wait(ctx, "test_key", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("expected Wait to last until the block ended, returned after %v", elapsed)
+	}
+	if allowed, _ := limiter.Take(ctx, "test_key", 1); !allowed {
+		t.Error("expected a take after the wait to be allowed")
+	}
+}
+
+func TestWaitCancelledOnClose(t *testing.T) {
+	ctx := context.Background()
+	backend := &mockBackend{
+		getInfoFunc: func(ctx context.Context, key string) (*backend.TokenInfo, error) {
+			return &backend.TokenInfo{Key: key, Tokens: 0, MaxTokens: 100}, nil
+		},
+	}
+
+	limiter, err := New(backend, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	results := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			results <- limiter.Wait(ctx, "test_key", 1)
+		}()
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	limiter.Close(ctx)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-results:
+			if !stderrors.Is(err, errors.ErrLimiterClosed) {
+				t.Errorf("expected ErrLimiterClosed, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected pending Wait to return after Close")
+		}
+	}
+
+	if err := limiter.Wait(ctx, "test_key", 1); !stderrors.Is(err, errors.ErrLimiterClosed) {
+		t.Errorf("expected ErrLimiterClosed for Wait after Close, got %v", err)
+	}
+}
+
+// wakeupBackend is a mockBackend that announces wakeups on a channel
+type wakeupBackend struct {
+	mockBackend
+	wakeups chan struct{}
+}
+
+func (m *wakeupBackend) SubscribeWakeups(key string) (<-chan struct{}, func()) {
+	return m.wakeups, func() {}
+}
+
+func TestWaitWithWakeups(t *testing.T) {
+	ctx := context.Background()
+	var reads atomic.Int64
+	var tokens atomic.Int64
+	backend := &wakeupBackend{
+		mockBackend: mockBackend{
+			getInfoFunc: func(ctx context.Context, key string) (*backend.TokenInfo, error) {
+				reads.Add(1)
+				return &backend.TokenInfo{
+					Key:        key,
+					Tokens:     tokens.Load(),
+					MaxTokens:  100,
+					RefillRate: time.Hour,
+					NextRefill: time.Now().Add(time.Hour),
+				}, nil
+			},
+		},
+		wakeups: make(chan struct{}, 1),
+	}
+
+	limiter, err := New(backend, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- limiter.Wait(ctx, "test_key", 1)
+	}()
+
+	// The next refill is an hour away, so the backend is read once and then left alone
+	time.Sleep(450 * time.Millisecond)
+	if got := reads.Load(); got != 1 {
+		t.Errorf("expected 1 backend read while sleeping until the next refill, got %d", got)
+	}
+
+	tokens.Store(1)
+	backend.wakeups <- struct{}{}
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Wait to return after a wakeup")
+	}
+}
+
+func TestNextAvailable(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		info     *backend.TokenInfo
+		tokens   int64
+		expected time.Time
+	}{
+		{
+			name:     "next refill covers the request",
+			info:     &backend.TokenInfo{Tokens: 0, RefillRate: 100 * time.Millisecond, NextRefill: now.Add(50 * time.Millisecond)},
+			tokens:   1,
+			expected: now.Add(50 * time.Millisecond),
+		},
+		{
+			name:     "several refills needed",
+			info:     &backend.TokenInfo{Tokens: 1, RefillRate: 100 * time.Millisecond, NextRefill: now.Add(50 * time.Millisecond)},
+			tokens:   4,
+			expected: now.Add(250 * time.Millisecond),
+		},
+		{
+			name:     "stepped refills",
+			info:     &backend.TokenInfo{Tokens: 1, MaxTokens: 10, RefillRate: 100 * time.Millisecond, NextRefill: now.Add(50 * time.Millisecond), Strategy: backend.SteppedRefill{Tokens: 2}},
+			tokens:   4,
+			expected: now.Add(150 * time.Millisecond),
+		},
+		{
+			name:     "reset refill",
+			info:     &backend.TokenInfo{Tokens: 0, MaxTokens: 10, RefillRate: 100 * time.Millisecond, NextRefill: now.Add(50 * time.Millisecond), Strategy: backend.ResetRefill{}},
+			tokens:   10,
+			expected: now.Add(50 * time.Millisecond),
+		},
+		{
+			name:     "capped",
+			info:     &backend.TokenInfo{Tokens: 0, RefillRate: time.Hour, NextRefill: now.Add(time.Hour)},
+			tokens:   1,
+			expected: now.Add(maxWaitSleep),
+		},
+		{
+			name:     "blocked",
+			info:     &backend.TokenInfo{Tokens: 10, RefillRate: time.Second, NextRefill: now, BlockedUntil: now.Add(200 * time.Millisecond)},
+			tokens:   1,
+			expected: now.Add(200 * time.Millisecond),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextAvailable(tt.info, tt.tokens, now); !got.Equal(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected.Sub(now), got.Sub(now))
+			}
+		})
+	}
+}
+
 func TestClose(t *testing.T) {
 	ctx := context.Background()
 	backend := &mockBackend{}
@@ -381,8 +792,8 @@ func TestClose(t *testing.T) {
 
 	// Test operations after close
 	_, err = limiter.Take(ctx, "test_key", 1)
-	if err == nil {
-		t.Error("expected error after close")
+	if !stderrors.Is(err, errors.ErrLimiterClosed) {
+		t.Errorf("expected ErrLimiterClosed after close, got %v", err)
 	}
 
 	// Test multiple close calls
@@ -416,6 +827,60 @@ func TestHealthCheck(t *testing.T) {
 	}
 }
 
+func TestOperationTimeout(t *testing.T) {
+	// A backend that hangs until its context is done
+	hung := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			<-ctx.Done()
+			return false, ctx.Err()
+		},
+		blockFunc: func(ctx context.Context, key string, duration time.Duration) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.OperationTimeout = 20 * time.Millisecond
+
+	limiter, err := New(hung, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(context.Background())
+
+	start := time.Now()
+	_, err = limiter.Take(context.Background(), "test_key", 1)
+	if !errors.IsTimeoutError(err) {
+		t.Errorf("expected a TimeoutError, got %v", err)
+	}
+	if !stderrors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Take to give up after the operation timeout, took %v", elapsed)
+	}
+
+	var timeoutErr *errors.TimeoutError
+	if stderrors.As(err, &timeoutErr) && (timeoutErr.Op != "take" || timeoutErr.Timeout != cfg.OperationTimeout) {
+		t.Errorf("expected op take after %v, got op %s after %v", cfg.OperationTimeout, timeoutErr.Op, timeoutErr.Timeout)
+	}
+
+	if err := limiter.Block(context.Background(), "test_key", time.Minute); !errors.IsTimeoutError(err) {
+		t.Errorf("expected a TimeoutError from Block, got %v", err)
+	}
+
+	// A caller's own deadline is not reported as an operation timeout
+	cfg.OperationTimeout = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = limiter.Take(ctx, "test_key", 1)
+	if err == nil || errors.IsTimeoutError(err) {
+		t.Errorf("expected the caller's deadline error, got %v", err)
+	}
+}
+
 func TestGetConfig(t *testing.T) {
 	backend := &mockBackend{}
 	config := config.DefaultConfig()
@@ -502,7 +967,7 @@ func TestValidation(t *testing.T) {
 	// Test token validation
 	tests2 := []struct {
 		name        string
-		tokens      int
+		tokens      int64
 		expectError bool
 	}{
 		{"valid tokens", 1, false},
@@ -0,0 +1,263 @@
+package limiter
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// LoadSample is a reading of the load of the process
+type LoadSample struct {
+	// CPU is the fraction of GOMAXPROCS spent running the process since the previous sample, from 0 to 1
+	CPU float64
+
+	// Goroutines is the number of goroutines
+	Goroutines int
+
+	// HeapBytes is the memory occupied by live and not yet swept heap objects
+	HeapBytes uint64
+}
+
+// LoadSheddingOptions configures how limits shrink as the process comes under load
+// A threshold of 0 leaves its signal out, at least one must be set
+type LoadSheddingOptions struct {
+	// Interval is how often the load is sampled
+	Interval time.Duration `json:"interval"`
+
+	// MaxCPU is the CPU fraction above which limits shrink, it is only measured on Unix systems
+	MaxCPU float64 `json:"max_cpu"`
+
+	// MaxGoroutines is the goroutine count above which limits shrink
+	MaxGoroutines int `json:"max_goroutines"`
+
+	// MaxHeapBytes is the heap size above which limits shrink
+	MaxHeapBytes uint64 `json:"max_heap_bytes"`
+
+	// MinFactor is the smallest fraction limits are scaled to, however high the load
+	MinFactor float64 `json:"min_factor"`
+
+	// Sampler reads the load, nil reads it from the Go runtime and the operating system
+	Sampler func() LoadSample `json:"-"`
+}
+
+// DefaultLoadSheddingOptions returns default options for load shedding, with no thresholds set
+func DefaultLoadSheddingOptions() *LoadSheddingOptions {
+	return &LoadSheddingOptions{
+		Interval:  time.Second,
+		MinFactor: 0.1,
+	}
+}
+
+// Validate validates the load shedding options
+func (o *LoadSheddingOptions) Validate() error {
+	if o.Interval <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "interval must be positive")
+	}
+
+	if o.MaxCPU < 0 || o.MaxCPU > 1 {
+		return errors.Wrap(errors.ErrInvalidTokens, "max_cpu must be between 0 and 1")
+	}
+
+	if o.MaxGoroutines < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "max_goroutines must not be negative")
+	}
+
+	if o.MaxCPU == 0 && o.MaxGoroutines == 0 && o.MaxHeapBytes == 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "at least one of max_cpu, max_goroutines and max_heap_bytes must be set")
+	}
+
+	if o.MinFactor <= 0 || o.MinFactor > 1 {
+		return errors.Wrap(errors.ErrInvalidTokens, "min_factor must be above 0 and at most 1")
+	}
+
+	return nil
+}
+
+// factor returns the fraction limits are scaled to under the load
+// Each signal over its threshold scales limits by threshold over value, the strongest signal wins
+func (o *LoadSheddingOptions) factor(sample LoadSample) float64 {
+	factor := 1.0
+
+	if o.MaxCPU > 0 && sample.CPU > o.MaxCPU {
+		factor = min(factor, o.MaxCPU/sample.CPU)
+	}
+
+	if o.MaxGoroutines > 0 && sample.Goroutines > o.MaxGoroutines {
+		factor = min(factor, float64(o.MaxGoroutines)/float64(sample.Goroutines))
+	}
+
+	if o.MaxHeapBytes > 0 && sample.HeapBytes > o.MaxHeapBytes {
+		factor = min(factor, float64(o.MaxHeapBytes)/float64(sample.HeapBytes))
+	}
+
+	return max(factor, o.MinFactor)
+}
+
+// LoadCollector is implemented by metrics collectors that record the load shedding factor
+type LoadCollector interface {
+	// SetLoadFactor reports the fraction limits are currently scaled to, 1 when the process is not shedding
+	SetLoadFactor(ctx context.Context, factor float64)
+}
+
+// loadShedder samples the load of the process and keeps the current scaling factor
+type loadShedder struct {
+	options LoadSheddingOptions
+	bits    atomic.Uint64
+}
+
+// WithLoadShedding scales limits down while the process is overloaded, New fails if the options are invalid
+// Under load every take costs its tokens divided by the factor, so keys get proportionally fewer requests through
+// Wait and IsAllowed check for the scaled tokens too, scaled tokens never exceed the limit of the key
+// While shedding, takes without a limit of their own read the limit of their keys first, TakeWithLimit and tiers use theirs
+func WithLoadShedding(options *LoadSheddingOptions) Option {
+	return func(r *RateLimiter) {
+		if options == nil {
+			options = DefaultLoadSheddingOptions()
+		}
+
+		r.shedder = &loadShedder{options: *options}
+		r.shedder.bits.Store(math.Float64bits(1))
+	}
+}
+
+// LoadFactor returns the fraction limits are currently scaled to, 1 without load shedding or load
+func (r *RateLimiter) LoadFactor() float64 {
+	if r.shedder == nil {
+		return 1
+	}
+
+	return r.shedder.factor()
+}
+
+// factor returns the current scaling factor
+func (s *loadShedder) factor() float64 {
+	return math.Float64frombits(s.bits.Load())
+}
+
+// shedTokens scales the tokens of a take up by the load factor, to at most limit, the max tokens of the key
+// A key whose limit the scaled tokens exceed could never grant them, so under heavy load it grants a full bucket instead
+func (r *RateLimiter) shedTokens(tokens, limit int64) int64 {
+	if r.shedder == nil {
+		return tokens
+	}
+
+	factor := r.shedder.factor()
+	if factor >= 1 {
+		return tokens
+	}
+
+	scaled := int64(math.Min(math.Ceil(float64(tokens)/factor), math.MaxInt64/2))
+	return max(tokens, min(scaled, limit))
+}
+
+// shedKeyTokens scales the tokens of a take up by the load factor, to at most the lowest max tokens of the keys
+// Only while shedding are the keys read, a key that cannot be read is assumed to have the default limit
+func (r *RateLimiter) shedKeyTokens(ctx context.Context, keys []string, tokens int64) int64 {
+	if r.shedder == nil || r.shedder.factor() >= 1 {
+		return tokens
+	}
+
+	limit := int64(math.MaxInt64)
+	for _, key := range keys {
+		start := time.Now()
+		opCtx, done := r.withTimeout(ctx, "get_info")
+		info, err := r.backend.GetInfo(opCtx, key)
+		err = done(err)
+		r.observeBackend(ctx, "get_info", start, err)
+		if err != nil {
+			limit = min(limit, r.config.DefaultLimit)
+			continue
+		}
+
+		limit = min(limit, info.MaxTokens)
+	}
+
+	return r.shedTokens(tokens, limit)
+}
+
+// startLoadShedder samples the load every interval until the limiter is closed
+func (r *RateLimiter) startLoadShedder() {
+	sampler := r.shedder.options.Sampler
+	if sampler == nil {
+		sampler = newRuntimeSampler().sample
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.shedder.options.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.updateLoadFactor(sampler())
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+// updateLoadFactor stores the factor for the sample, reporting it and logging when shedding starts or stops
+func (r *RateLimiter) updateLoadFactor(sample LoadSample) {
+	ctx := context.Background()
+	factor := r.shedder.options.factor(sample)
+	previous := math.Float64frombits(r.shedder.bits.Swap(math.Float64bits(factor)))
+
+	if collector, ok := r.metrics.(LoadCollector); ok {
+		collector.SetLoadFactor(ctx, factor)
+	}
+
+	if (factor < 1) != (previous < 1) {
+		r.logConfigChange(ctx, "load_shedding",
+			slog.Float64("factor", factor),
+			slog.Float64("cpu", sample.CPU),
+			slog.Int("goroutines", sample.Goroutines),
+			slog.Uint64("heap_bytes", sample.HeapBytes),
+		)
+	}
+}
+
+// runtimeSampler reads the load from the Go runtime, and the CPU time from the operating system where supported
+type runtimeSampler struct {
+	metrics  []metrics.Sample
+	lastCPU  time.Duration
+	lastWall time.Time
+}
+
+// newRuntimeSampler returns a sampler whose first CPU reading covers the time since it was created
+func newRuntimeSampler() *runtimeSampler {
+	s := &runtimeSampler{
+		metrics:  []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}},
+		lastWall: time.Now(),
+	}
+	s.lastCPU, _ = processCPUTime()
+
+	return s
+}
+
+// sample reads the current load
+func (s *runtimeSampler) sample() LoadSample {
+	metrics.Read(s.metrics)
+
+	sample := LoadSample{
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  s.metrics[0].Value.Uint64(),
+	}
+
+	now := time.Now()
+	if cpu, ok := processCPUTime(); ok {
+		if wall := now.Sub(s.lastWall); wall > 0 {
+			sample.CPU = min(float64(cpu-s.lastCPU)/float64(wall)/float64(runtime.GOMAXPROCS(0)), 1)
+		}
+		s.lastCPU = cpu
+	}
+	s.lastWall = now
+
+	return sample
+}
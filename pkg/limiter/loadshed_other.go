@@ -0,0 +1,10 @@
+//go:build !unix
+
+package limiter
+
+import "time"
+
+// processCPUTime is not supported on this platform, so CPU thresholds never trigger
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,184 @@
+package limiter
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestLoadSheddingOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(*LoadSheddingOptions)
+		expectError bool
+	}{
+		{name: "goroutine threshold", modify: func(o *LoadSheddingOptions) { o.MaxGoroutines = 1000 }},
+		{name: "no threshold", modify: func(*LoadSheddingOptions) {}, expectError: true},
+		{name: "zero interval", modify: func(o *LoadSheddingOptions) { o.MaxCPU, o.Interval = 0.8, 0 }, expectError: true},
+		{name: "cpu above 1", modify: func(o *LoadSheddingOptions) { o.MaxCPU = 1.5 }, expectError: true},
+		{name: "negative goroutines", modify: func(o *LoadSheddingOptions) { o.MaxGoroutines = -1 }, expectError: true},
+		{name: "zero min factor", modify: func(o *LoadSheddingOptions) { o.MaxHeapBytes, o.MinFactor = 1<<30, 0 }, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultLoadSheddingOptions()
+			tt.modify(options)
+
+			err := options.Validate()
+			if tt.expectError && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadSheddingFactor(t *testing.T) {
+	options := &LoadSheddingOptions{MaxCPU: 0.5, MaxGoroutines: 100, MaxHeapBytes: 1000, MinFactor: 0.1}
+
+	tests := []struct {
+		name   string
+		sample LoadSample
+		factor float64
+	}{
+		{name: "under every threshold", sample: LoadSample{CPU: 0.4, Goroutines: 50, HeapBytes: 500}, factor: 1},
+		{name: "cpu over", sample: LoadSample{CPU: 1, Goroutines: 50}, factor: 0.5},
+		{name: "strongest signal wins", sample: LoadSample{CPU: 0.6, Goroutines: 400, HeapBytes: 2000}, factor: 0.25},
+		{name: "floored at the minimum", sample: LoadSample{HeapBytes: 1000000}, factor: 0.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := options.factor(tt.sample); got != tt.factor {
+				t.Errorf("expected factor %v, got %v", tt.factor, got)
+			}
+		})
+	}
+}
+
+func TestLoadShedding(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.DefaultConfig()
+	cfg.DefaultLimit = 10
+	cfg.DefaultRefill = time.Hour
+	b, err := backend.NewInMemoryBackend(cfg.BackendOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	var goroutines atomic.Int64
+	goroutines.Store(10)
+
+	options := DefaultLoadSheddingOptions()
+	options.Interval = time.Millisecond
+	options.MaxGoroutines = 100
+	options.Sampler = func() LoadSample { return LoadSample{Goroutines: int(goroutines.Load())} }
+
+	limiter, err := New(b, cfg, WithLoadShedding(options))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	waitForFactor := func(factor float64) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for limiter.LoadFactor() != factor {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected load factor %v, got %v", factor, limiter.LoadFactor())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Twice the goroutine threshold halves the limit of every key
+	goroutines.Store(200)
+	waitForFactor(0.5)
+
+	for i := 0; i < 5; i++ {
+		if allowed, _ := limiter.Take(ctx, "test_key", 1); !allowed {
+			t.Fatalf("expected take %d to be allowed under the halved limit", i)
+		}
+	}
+	if allowed, _ := limiter.Take(ctx, "test_key", 1); allowed {
+		t.Error("expected take beyond the halved limit to be denied")
+	}
+
+	// Wait and IsAllowed check for the scaled tokens a take would need
+	if allowed, _ := limiter.Take(ctx, "wait_key", 4); !allowed {
+		t.Fatal("expected take of 4 to be allowed under the halved limit")
+	}
+	if allowed, _ := limiter.IsAllowed(ctx, "wait_key", 1); !allowed {
+		t.Error("expected 1 token to be allowed with 2 left under the halved limit")
+	}
+	if allowed, _ := limiter.IsAllowed(ctx, "wait_key", 2); allowed {
+		t.Error("expected 2 tokens to be denied with 2 left under the halved limit")
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 150*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(waitCtx, "wait_key", 2); err == nil {
+		t.Error("expected wait for 2 tokens to time out with 2 left under the halved limit")
+	}
+
+	// Scaled tokens are capped at the limit of the key, so heavy load does not deny every take
+	goroutines.Store(10000)
+	waitForFactor(options.MinFactor)
+	if allowed, _ := limiter.IsAllowed(ctx, "capped_key", 2); !allowed {
+		t.Error("expected a full bucket to allow tokens scaled beyond its limit")
+	}
+	if allowed, _ := limiter.Take(ctx, "capped_key", 2); !allowed {
+		t.Error("expected a take scaled beyond the limit to take the full bucket")
+	}
+	if allowed, _ := limiter.Take(ctx, "capped_key", 1); allowed {
+		t.Error("expected the bucket to be empty after a capped take")
+	}
+
+	// Keys with a custom limit below the default are capped at their own limit
+	for _, key := range []string{"custom_key", "custom_once_key"} {
+		if err := b.SetLimit(ctx, key, 3, time.Hour); err != nil {
+			t.Fatalf("failed to set limit: %v", err)
+		}
+	}
+	if allowed, _ := limiter.Take(ctx, "custom_key", 1); !allowed {
+		t.Error("expected a take scaled beyond a custom limit to take the full bucket")
+	}
+	if allowed, _ := limiter.Take(ctx, "custom_key", 1); allowed {
+		t.Error("expected the custom bucket to be empty after a capped take")
+	}
+	if allowed, _ := limiter.TakeOnce(ctx, "custom_once_key", "request", 1); !allowed {
+		t.Error("expected a take once scaled beyond a custom limit to take the full bucket")
+	}
+
+	// Limits recover with the load
+	goroutines.Store(10)
+	waitForFactor(1)
+	if allowed, _ := limiter.Take(ctx, "other_key", 10); !allowed {
+		t.Error("expected the full limit once the load is gone")
+	}
+
+	if _, err := New(b, config.DefaultConfig(), WithLoadShedding(nil)); err == nil {
+		t.Error("expected error for load shedding without thresholds, got nil")
+	}
+}
+
+func TestRuntimeSampler(t *testing.T) {
+	sampler := newRuntimeSampler()
+
+	sample := sampler.sample()
+	if sample.Goroutines <= 0 {
+		t.Errorf("expected a positive goroutine count, got %d", sample.Goroutines)
+	}
+	if sample.HeapBytes == 0 {
+		t.Error("expected a non-zero heap size")
+	}
+	if sample.CPU < 0 || sample.CPU > 1 {
+		t.Errorf("expected a CPU fraction between 0 and 1, got %v", sample.CPU)
+	}
+}
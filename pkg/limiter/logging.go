@@ -0,0 +1,103 @@
+package limiter
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// WithLogger sets the logger used when EnableLogging is set in the configuration
+// slog.Default is used when no logger is provided
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *RateLimiter) {
+		r.logger = logger
+	}
+}
+
+// logSampler limits how many lines per second are logged for one event type
+type logSampler struct {
+	limit       int
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+	dropped     int
+}
+
+// allow reports whether a line may be logged and how many were dropped since the last logged line
+func (s *logSampler) allow(now time.Time) (bool, int) {
+	if s.limit <= 0 {
+		return true, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.windowStart) >= time.Second {
+		s.windowStart = now
+		s.count = 0
+	}
+
+	if s.count >= s.limit {
+		s.dropped++
+		return false, 0
+	}
+
+	s.count++
+	dropped := s.dropped
+	s.dropped = 0
+	return true, dropped
+}
+
+// eventLogger writes sampled structured logs for limiter events
+type eventLogger struct {
+	logger        *slog.Logger
+	denials       *logSampler
+	errors        *logSampler
+	configChanges *logSampler
+}
+
+// log writes a line through the sampler, attaching the count of dropped lines if any
+func (l *eventLogger) log(ctx context.Context, sampler *logSampler, level slog.Level, msg string, attrs ...slog.Attr) {
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	ok, dropped := sampler.allow(time.Now())
+	if !ok {
+		return
+	}
+
+	if dropped > 0 {
+		attrs = append(attrs, slog.Int("dropped", dropped))
+	}
+
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// logDenial logs a denied request at debug level
+func (l *eventLogger) logDenial(ctx context.Context, operation string, key string, tokens int64) {
+	l.log(ctx, l.denials, slog.LevelDebug, "rate limit exceeded",
+		slog.String("operation", operation),
+		slog.String("key", key),
+		slog.Int64("tokens", tokens),
+	)
+}
+
+// logBackendError logs a failed backend call
+func (l *eventLogger) logBackendError(ctx context.Context, operation string, err error) {
+	l.log(ctx, l.errors, slog.LevelError, "rate limiter backend error",
+		slog.String("operation", operation),
+		slog.String("error_type", errors.Classify(err)),
+		slog.String("error", err.Error()),
+	)
+}
+
+// logConfigChange logs a change of limits or blocks
+func (l *eventLogger) logConfigChange(ctx context.Context, change string, attrs ...slog.Attr) {
+	l.log(ctx, l.configChanges, slog.LevelInfo, "rate limiter configuration changed",
+		append([]slog.Attr{slog.String("change", change)}, attrs...)...,
+	)
+}
@@ -0,0 +1,109 @@
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestLogging(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	backend := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			if key == "broken" {
+				return false, errors.ErrBackendUnavailable
+			}
+			return false, nil
+		},
+	}
+
+	limiter, err := New(backend, config.DefaultConfig(), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(ctx, "denied_key", 1)
+	limiter.Take(ctx, "broken", 1)
+	limiter.Block(ctx, "blocked_key", time.Minute)
+
+	output := buf.String()
+	for _, expected := range []string{
+		`"msg":"rate limit exceeded"`,
+		`"key":"denied_key"`,
+		`"msg":"rate limiter backend error"`,
+		`"msg":"rate limiter configuration changed"`,
+		`"change":"block"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected log output to contain %s, got %s", expected, output)
+		}
+	}
+}
+
+func TestLoggingDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	backend := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			return false, nil
+		},
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.EnableLogging = false
+
+	limiter, err := New(backend, cfg, WithLogger(logger))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(context.Background(), "test_key", 1)
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no log output, got %s", buf.String())
+	}
+}
+
+func TestLogSampler(t *testing.T) {
+	sampler := &logSampler{limit: 2}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := sampler.allow(now); !ok {
+			t.Errorf("expected line %d to be logged", i)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := sampler.allow(now); ok {
+			t.Error("expected line over the limit to be dropped")
+		}
+	}
+
+	// The next window reports the dropped lines
+	ok, dropped := sampler.allow(now.Add(time.Second))
+	if !ok {
+		t.Error("expected line in the next window to be logged")
+	}
+	if dropped != 3 {
+		t.Errorf("expected 3 dropped lines, got %d", dropped)
+	}
+
+	// Unlimited sampler never drops
+	unlimited := &logSampler{}
+	for i := 0; i < 100; i++ {
+		if ok, _ := unlimited.allow(now); !ok {
+			t.Fatal("expected unlimited sampler to allow every line")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// activeKeysInterval is how often the active key count is reported to the metrics collector
+const activeKeysInterval = 15 * time.Second
+
+// MetricsCollector receives rate limiter metrics
+// It is called only when EnableMetrics is set in the configuration
+type MetricsCollector interface {
+	// IncAllowed counts an allowed request
+	IncAllowed(ctx context.Context, operation string)
+
+	// IncDenied counts a denied request
+	IncDenied(ctx context.Context, operation string)
+
+	// IncError counts a failed backend call, errorType is one of the errors.Class* values
+	IncError(ctx context.Context, operation string, errorType string)
+
+	// ObserveLatency records the duration of a backend call
+	ObserveLatency(ctx context.Context, operation string, elapsed time.Duration)
+
+	// SetActiveKeys reports the number of keys tracked by the backend
+	SetActiveKeys(ctx context.Context, count int)
+}
+
+// WithMetricsCollector sends metrics to the given collector
+func WithMetricsCollector(collector MetricsCollector) Option {
+	return func(r *RateLimiter) {
+		r.metrics = collector
+	}
+}
+
+// MemoryCollector is implemented by metrics collectors that record backend memory usage
+// It is used for backends implementing backend.MemoryReporter, such as the in-memory backend
+type MemoryCollector interface {
+	// SetMemoryUsage reports the approximate bytes used by the backend and its total evictions
+	SetMemoryUsage(ctx context.Context, stats backend.MemoryStats)
+}
+
+// startActiveKeysReporter starts reporting the key count and memory usage for backends that can report them
+func (r *RateLimiter) startActiveKeysReporter(interval time.Duration) {
+	counter, _ := r.backend.(backend.KeyCounter)
+
+	var memory backend.MemoryReporter
+	if _, ok := r.metrics.(MemoryCollector); ok {
+		memory, _ = r.backend.(backend.MemoryReporter)
+	}
+
+	if counter == nil && memory == nil {
+		return
+	}
+
+	r.stopMetrics = make(chan struct{})
+	r.metricsDone = make(chan struct{})
+	go r.reportActiveKeys(counter, memory, interval, r.stopMetrics, r.metricsDone)
+}
+
+// reportActiveKeys periodically reports the backend key count and memory usage until the limiter is closed
+func (r *RateLimiter) reportActiveKeys(counter backend.KeyCounter, memory backend.MemoryReporter, interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx := context.Background()
+			if counter != nil {
+				if count, err := counter.KeyCount(ctx); err == nil {
+					r.metrics.SetActiveKeys(ctx, count)
+				}
+			}
+			if memory != nil {
+				if stats, err := memory.MemoryStats(ctx); err == nil {
+					r.metrics.(MemoryCollector).SetMemoryUsage(ctx, stats)
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// recordingCollector is a MetricsCollector that records every call
+type recordingCollector struct {
+	mu         sync.Mutex
+	allowed    int
+	denied     int
+	errorTypes []string
+	latencies  int
+	activeKeys int
+}
+
+func (c *recordingCollector) IncAllowed(ctx context.Context, operation string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.allowed++
+}
+
+func (c *recordingCollector) IncDenied(ctx context.Context, operation string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.denied++
+}
+
+func (c *recordingCollector) IncError(ctx context.Context, operation string, errorType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errorTypes = append(c.errorTypes, errorType)
+}
+
+func (c *recordingCollector) ObserveLatency(ctx context.Context, operation string, elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latencies++
+}
+
+func (c *recordingCollector) SetActiveKeys(ctx context.Context, count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.activeKeys = count
+}
+
+func TestMetricsCollector(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			switch key {
+			case "broken":
+				return false, errors.ErrTimeout
+			case "denied_key":
+				return false, nil
+			}
+			return true, nil
+		},
+	}
+
+	collector := &recordingCollector{}
+	limiter, err := New(mock, config.DefaultConfig(), WithMetricsCollector(collector))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(ctx, "test_key", 1)
+	limiter.Take(ctx, "denied_key", 1)
+	limiter.Take(ctx, "broken", 1)
+
+	if collector.allowed != 1 || collector.denied != 1 {
+		t.Errorf("expected 1 allowed and 1 denied, got %d and %d", collector.allowed, collector.denied)
+	}
+	if len(collector.errorTypes) != 1 || collector.errorTypes[0] != errors.ClassTimeout {
+		t.Errorf("expected one timeout error, got %v", collector.errorTypes)
+	}
+	if collector.latencies != 3 {
+		t.Errorf("expected 3 latency observations, got %d", collector.latencies)
+	}
+}
+
+func TestMetricsCollectorDisabled(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.EnableMetrics = false
+
+	collector := &recordingCollector{}
+	limiter, err := New(&mockBackend{}, cfg, WithMetricsCollector(collector))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(context.Background(), "test_key", 1)
+
+	if collector.allowed != 0 || collector.latencies != 0 {
+		t.Error("expected collector not to be called when metrics are disabled")
+	}
+}
+
+func TestMetricsActiveKeys(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	collector := &recordingCollector{}
+	limiter, err := New(b, config.DefaultConfig(), WithMetricsCollector(collector))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	// Replace the default reporter with a fast one
+	close(limiter.stopMetrics)
+	<-limiter.metricsDone
+	limiter.startActiveKeysReporter(10 * time.Millisecond)
+
+	limiter.Take(ctx, "key1", 1)
+	limiter.Take(ctx, "key2", 1)
+	time.Sleep(50 * time.Millisecond)
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.activeKeys != 2 {
+		t.Errorf("expected 2 active keys, got %d", collector.activeKeys)
+	}
+}
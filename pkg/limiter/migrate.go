@@ -0,0 +1,150 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// MigrateOptions configures a migration between backends
+type MigrateOptions struct {
+	// Pattern selects the keys to copy with a glob pattern, empty copies every key
+	Pattern string
+
+	// KeysPerSecond caps how fast keys are copied so the migration does not overload either backend, 0 means unlimited
+	KeysPerSecond int
+
+	// ContinueOnError keeps copying after a key fails instead of stopping at the first failure
+	ContinueOnError bool
+
+	// Progress is called after every key with the running totals
+	Progress func(MigrationProgress)
+}
+
+// MigrationProgress reports how far a migration has come
+type MigrationProgress struct {
+	Key    string `json:"key"`
+	Copied int    `json:"copied"`
+	Failed int    `json:"failed"`
+	Total  int    `json:"total"`
+	Err    error  `json:"-"`
+}
+
+// Migrate copies bucket state, custom limits, refill strategies and active blocks from src to dst
+// The source backend must implement backend.KeyLister
+// Tokens are copied as of the moment each key is read, so live traffic during the migration is not carried over
+func Migrate(ctx context.Context, src, dst backend.Backend, opts MigrateOptions) (MigrationProgress, error) {
+	var progress MigrationProgress
+
+	if src == nil || dst == nil {
+		return progress, errors.Wrap(errors.ErrBackendUnavailable, "backend cannot be nil")
+	}
+
+	if opts.KeysPerSecond < 0 {
+		return progress, errors.Wrap(errors.ErrInvalidTokens, "keys per second must not be negative")
+	}
+
+	lister, ok := src.(backend.KeyLister)
+	if !ok {
+		return progress, errors.Wrap(errors.ErrBackendUnavailable, "source backend cannot list keys")
+	}
+
+	keys, err := lister.Keys(ctx, opts.Pattern)
+	if err != nil {
+		return progress, errors.Wrap(err, "failed to list source keys")
+	}
+	progress.Total = len(keys)
+
+	var throttle <-chan time.Time
+	if opts.KeysPerSecond > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.KeysPerSecond))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	for i, key := range keys {
+		if throttle != nil && i > 0 {
+			select {
+			case <-ctx.Done():
+				return progress, errors.Wrap(ctx.Err(), "context cancelled")
+			case <-throttle:
+			}
+		}
+
+		// Check if context is cancelled
+		select {
+		case <-ctx.Done():
+			return progress, errors.Wrap(ctx.Err(), "context cancelled")
+		default:
+		}
+
+		err := migrateKey(ctx, src, dst, key)
+
+		progress.Key = key
+		progress.Err = err
+		if err != nil {
+			progress.Failed++
+		} else {
+			progress.Copied++
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+
+		if err != nil && !opts.ContinueOnError {
+			return progress, errors.Wrapf(err, "failed to migrate key %s", key)
+		}
+	}
+
+	progress.Key = ""
+	progress.Err = nil
+	return progress, nil
+}
+
+// migrateKey copies the state of one key from src to dst
+func migrateKey(ctx context.Context, src, dst backend.Backend, key string) error {
+	info, err := src.GetInfo(ctx, key)
+	if err != nil {
+		return errors.Wrap(err, "failed to read source bucket")
+	}
+
+	if err := dst.Reset(ctx, key); err != nil {
+		return errors.Wrap(err, "failed to reset destination bucket")
+	}
+
+	if err := dst.SetLimit(ctx, key, info.MaxTokens, info.RefillRate); err != nil {
+		return errors.Wrap(err, "failed to set destination limit")
+	}
+
+	if info.Strategy != nil {
+		setter, ok := dst.(backend.RefillStrategySetter)
+		if !ok {
+			return errors.Wrap(errors.ErrBackendUnavailable, "destination backend does not support refill strategies")
+		}
+		if err := setter.SetRefillStrategy(ctx, key, info.Strategy); err != nil {
+			return errors.Wrap(err, "failed to copy refill strategy")
+		}
+	}
+
+	// Drain the destination down to the source token count
+	current, err := dst.GetInfo(ctx, key)
+	if err != nil {
+		return errors.Wrap(err, "failed to read destination bucket")
+	}
+	if drain := current.Tokens - info.Tokens; drain > 0 {
+		if _, err := dst.Take(ctx, key, drain); err != nil {
+			return errors.Wrap(err, "failed to drain destination bucket")
+		}
+	}
+
+	if remaining := time.Until(info.BlockedUntil); !info.BlockedUntil.IsZero() && remaining > 0 {
+		if err := dst.Block(ctx, key, remaining); err != nil {
+			return errors.Wrap(err, "failed to copy block")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	options := backend.DefaultOptions().WithLimit(10).WithRefill(time.Hour)
+
+	src, err := backend.NewInMemoryBackend(options)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer src.Close(ctx)
+
+	dst, err := backend.NewInMemoryBackend(options)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer dst.Close(ctx)
+
+	src.Take(ctx, "user:1", 3)
+	src.SetLimit(ctx, "user:2", 20, time.Hour)
+	src.Take(ctx, "user:2", 4)
+	src.Take(ctx, "user:3", 1)
+	src.Block(ctx, "user:3", time.Hour)
+	src.Take(ctx, "org:1", 1)
+
+	var calls []MigrationProgress
+	progress, err := Migrate(ctx, src, dst, MigrateOptions{
+		Pattern:  "user:*",
+		Progress: func(p MigrationProgress) { calls = append(calls, p) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if progress.Copied != 3 || progress.Failed != 0 || progress.Total != 3 {
+		t.Errorf("expected 3 of 3 keys copied, got %+v", progress)
+	}
+	if len(calls) != 3 || calls[2].Copied != 3 {
+		t.Errorf("expected 3 progress callbacks, got %+v", calls)
+	}
+
+	tests := []struct {
+		key       string
+		tokens    int64
+		maxTokens int64
+		blocked   bool
+	}{
+		{key: "user:1", tokens: 7, maxTokens: 10},
+		{key: "user:2", tokens: 16, maxTokens: 20},
+		{key: "user:3", tokens: 9, maxTokens: 10, blocked: true},
+	}
+
+	for _, tt := range tests {
+		info, err := dst.GetInfo(ctx, tt.key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Tokens != tt.tokens || info.MaxTokens != tt.maxTokens {
+			t.Errorf("%s: expected %d/%d tokens, got %d/%d", tt.key, tt.tokens, tt.maxTokens, info.Tokens, info.MaxTokens)
+		}
+		if blocked := !info.BlockedUntil.IsZero(); blocked != tt.blocked {
+			t.Errorf("%s: expected blocked=%v, got %v", tt.key, tt.blocked, blocked)
+		}
+	}
+
+	if keys, _ := dst.(backend.KeyLister).Keys(ctx, "org:*"); len(keys) != 0 {
+		t.Errorf("expected keys outside the pattern not to be copied, got %v", keys)
+	}
+}
+
+func TestMigrateValidation(t *testing.T) {
+	ctx := context.Background()
+
+	dst, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer dst.Close(ctx)
+
+	tests := []struct {
+		name string
+		src  backend.Backend
+		opts MigrateOptions
+	}{
+		{name: "nil source", src: nil},
+		{name: "source cannot list keys", src: &mockBackend{}},
+		{name: "negative rate", src: dst, opts: MigrateOptions{KeysPerSecond: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Migrate(ctx, tt.src, dst, tt.opts); err == nil {
+				t.Error("expected error but got none")
+			}
+		})
+	}
+}
+
+func TestMigrateRateLimited(t *testing.T) {
+	ctx := context.Background()
+
+	src, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer src.Close(ctx)
+
+	dst, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer dst.Close(ctx)
+
+	for _, key := range []string{"a", "b", "c"} {
+		src.Take(ctx, key, 1)
+	}
+
+	start := time.Now()
+	if _, err := Migrate(ctx, src, dst, MigrateOptions{KeysPerSecond: 20}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected migration of 3 keys at 20/s to take at least 100ms, took %v", elapsed)
+	}
+}
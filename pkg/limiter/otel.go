@@ -0,0 +1,184 @@
+package limiter
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// instrumentationName identifies the meter used by the rate limiter
+const instrumentationName = "github.com/devrob-go/go-rate-limiter/pkg/limiter"
+
+// otelCollector is a MetricsCollector backed by OpenTelemetry instruments
+type otelCollector struct {
+	decisions       metric.Int64Counter
+	keyDecisions    metric.Int64Counter
+	errors          metric.Int64Counter
+	backendDuration metric.Float64Histogram
+	activeKeys      metric.Int64Gauge
+	memoryBytes     metric.Int64Gauge
+	evictions       metric.Int64Counter
+	lastEvictions   atomic.Uint64
+	loadFactor      metric.Float64Gauge
+}
+
+// WithMeterProvider enables OpenTelemetry metrics using the given meter provider
+// It is a shorthand for WithMetricsCollector with NewOtelCollector
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(r *RateLimiter) {
+		r.meterProvider = provider
+	}
+}
+
+// NewOtelCollector creates a MetricsCollector emitting OpenTelemetry instruments from the meter provider
+func NewOtelCollector(provider metric.MeterProvider) (MetricsCollector, error) {
+	meter := provider.Meter(instrumentationName)
+
+	decisions, err := meter.Int64Counter("ratelimiter.decisions",
+		metric.WithDescription("Number of rate limit decisions"),
+		metric.WithUnit("{decision}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDecisions, err := meter.Int64Counter("ratelimiter.key.decisions",
+		metric.WithDescription("Number of rate limit decisions for the most requested keys"),
+		metric.WithUnit("{decision}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	errs, err := meter.Int64Counter("ratelimiter.errors",
+		metric.WithDescription("Number of failed backend operations"),
+		metric.WithUnit("{error}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	backendDuration, err := meter.Float64Histogram("ratelimiter.backend.duration",
+		metric.WithDescription("Duration of backend operations"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	activeKeys, err := meter.Int64Gauge("ratelimiter.active_keys",
+		metric.WithDescription("Number of keys tracked by the backend"),
+		metric.WithUnit("{key}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryBytes, err := meter.Int64Gauge("ratelimiter.memory",
+		metric.WithDescription("Approximate memory used by backend buckets"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	evictions, err := meter.Int64Counter("ratelimiter.evictions",
+		metric.WithDescription("Number of buckets evicted to stay under the memory cap"),
+		metric.WithUnit("{bucket}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	loadFactor, err := meter.Float64Gauge("ratelimiter.load_factor",
+		metric.WithDescription("Fraction limits are scaled to by load shedding"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &otelCollector{
+		decisions:       decisions,
+		keyDecisions:    keyDecisions,
+		errors:          errs,
+		backendDuration: backendDuration,
+		activeKeys:      activeKeys,
+		memoryBytes:     memoryBytes,
+		evictions:       evictions,
+		loadFactor:      loadFactor,
+	}, nil
+}
+
+// IncAllowed counts an allowed request
+func (c *otelCollector) IncAllowed(ctx context.Context, operation string) {
+	c.decisions.Add(ctx, 1, metric.WithAttributes(tenantAttributes(ctx,
+		attribute.String("operation", operation),
+		attribute.String("decision", "allowed"),
+	)...))
+}
+
+// IncDenied counts a denied request
+func (c *otelCollector) IncDenied(ctx context.Context, operation string) {
+	c.decisions.Add(ctx, 1, metric.WithAttributes(tenantAttributes(ctx,
+		attribute.String("operation", operation),
+		attribute.String("decision", "denied"),
+	)...))
+}
+
+// IncKeyDecision counts a decision on a key
+func (c *otelCollector) IncKeyDecision(ctx context.Context, key string, decision string) {
+	c.keyDecisions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("key", key),
+		attribute.String("decision", decision),
+	))
+}
+
+// RemoveKey does nothing, OpenTelemetry instruments cannot drop an attribute set
+func (c *otelCollector) RemoveKey(ctx context.Context, key string) {}
+
+// IncError counts a failed backend call by error class
+func (c *otelCollector) IncError(ctx context.Context, operation string, errorType string) {
+	c.errors.Add(ctx, 1, metric.WithAttributes(tenantAttributes(ctx,
+		attribute.String("operation", operation),
+		attribute.String("error_type", errorType),
+	)...))
+}
+
+// ObserveLatency records the duration of a backend call
+func (c *otelCollector) ObserveLatency(ctx context.Context, operation string, elapsed time.Duration) {
+	c.backendDuration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(tenantAttributes(ctx,
+		attribute.String("operation", operation),
+	)...))
+}
+
+// SetActiveKeys reports the number of keys tracked by the backend
+func (c *otelCollector) SetActiveKeys(ctx context.Context, count int) {
+	c.activeKeys.Record(ctx, int64(count))
+}
+
+// SetMemoryUsage reports the approximate memory used by the backend and counts new evictions
+func (c *otelCollector) SetMemoryUsage(ctx context.Context, stats backend.MemoryStats) {
+	c.memoryBytes.Record(ctx, stats.Bytes)
+	if last := c.lastEvictions.Swap(stats.Evictions); stats.Evictions > last {
+		c.evictions.Add(ctx, int64(stats.Evictions-last))
+	}
+}
+
+// SetLoadFactor reports the fraction limits are scaled to by load shedding
+func (c *otelCollector) SetLoadFactor(ctx context.Context, factor float64) {
+	c.loadFactor.Record(ctx, factor)
+}
+
+// tenantAttributes adds the tenant of the context, if any, to the attributes
+func tenantAttributes(ctx context.Context, attrs ...attribute.KeyValue) []attribute.KeyValue {
+	if id, ok := TenantFromContext(ctx); ok {
+		attrs = append(attrs, attribute.String("tenant", id))
+	}
+
+	return attrs
+}
@@ -0,0 +1,112 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collectMetrics returns the collected metrics indexed by instrument name
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Metrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	metrics := make(map[string]metricdata.Metrics)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+
+	return metrics
+}
+
+func TestOtelMetrics(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	allow := true
+	backend := &mockBackend{
+		takeFunc: func(ctx context.Context, key string, tokens int64) (bool, error) {
+			if key == "broken" {
+				return false, errors.ErrBackendUnavailable
+			}
+			return allow, nil
+		},
+	}
+
+	limiter, err := New(backend, config.DefaultConfig(), WithMeterProvider(provider))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(ctx, "test_key", 1)
+	limiter.Take(ctx, "test_key", 1)
+	allow = false
+	limiter.Take(ctx, "test_key", 1)
+	limiter.Take(ctx, "broken", 1)
+
+	metrics := collectMetrics(t, reader)
+
+	decisions, ok := metrics["ratelimiter.decisions"].Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatal("expected ratelimiter.decisions sum")
+	}
+
+	counts := make(map[string]int64)
+	for _, dp := range decisions.DataPoints {
+		decision, _ := dp.Attributes.Value("decision")
+		counts[decision.AsString()] += dp.Value
+	}
+	if counts["allowed"] != 2 {
+		t.Errorf("expected 2 allowed decisions, got %d", counts["allowed"])
+	}
+	if counts["denied"] != 1 {
+		t.Errorf("expected 1 denied decision, got %d", counts["denied"])
+	}
+
+	errs, ok := metrics["ratelimiter.errors"].Data.(metricdata.Sum[int64])
+	if !ok || len(errs.DataPoints) != 1 || errs.DataPoints[0].Value != 1 {
+		t.Fatalf("expected 1 backend error, got %+v", metrics["ratelimiter.errors"].Data)
+	}
+	if errorType, _ := errs.DataPoints[0].Attributes.Value("error_type"); errorType.AsString() != errors.ClassBackend {
+		t.Errorf("expected error_type backend, got %s", errorType.AsString())
+	}
+
+	duration, ok := metrics["ratelimiter.backend.duration"].Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatal("expected ratelimiter.backend.duration histogram")
+	}
+
+	if len(duration.DataPoints) != 1 || duration.DataPoints[0].Count != 4 {
+		t.Errorf("expected 4 backend duration observations, got %+v", duration.DataPoints)
+	}
+}
+
+func TestOtelMetricsDisabled(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	cfg := config.DefaultConfig()
+	cfg.EnableMetrics = false
+
+	limiter, err := New(&mockBackend{}, cfg, WithMeterProvider(provider))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(context.Background(), "test_key", 1)
+
+	if metrics := collectMetrics(t, reader); len(metrics) != 0 {
+		t.Errorf("expected no metrics when disabled, got %d", len(metrics))
+	}
+}
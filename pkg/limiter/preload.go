@@ -0,0 +1,112 @@
+package limiter
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// Policy is a custom limit for a key, written to the backend ahead of its first request
+type Policy struct {
+	Key    string        `json:"key"`
+	Limit  int64         `json:"limit"`
+	Refill time.Duration `json:"refill"`
+
+	// Tier takes the limits of the named tier as of the preload instead of Limit and Refill
+	Tier string `json:"tier,omitempty"`
+
+	// Strategy names the refill strategy of the key as backend.ParseRefillStrategy reads it, such as "stepped:5"
+	// Empty refills linearly, and a strategy other than linear needs a backend implementing backend.RefillStrategySetter
+	Strategy string `json:"refill_strategy,omitempty"`
+}
+
+// resolvedPolicy is a validated policy with its refill strategy parsed
+type resolvedPolicy struct {
+	Policy
+	strategy backend.RefillStrategy
+}
+
+// PreloadPolicies writes the custom limit of every policy to the backend, typically at startup
+// Every policy is validated before any is written, so an invalid list changes nothing
+// Backends leave an unchanged limit alone, so preloading again, or from several instances, does not refill buckets
+// On backends implementing backend.RefillStrategySetter the refill strategy is written too, so dropping it restores linear refills
+// Limits last as long as their buckets, a key that expires or is cleaned up goes back to the defaults
+func (r *RateLimiter) PreloadPolicies(ctx context.Context, policies []Policy) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return errors.ErrLimiterClosed
+	}
+
+	setter, canSetStrategy := r.backend.(backend.RefillStrategySetter)
+
+	resolved := make([]resolvedPolicy, 0, len(policies))
+	for _, policy := range policies {
+		if err := r.validateKey(policy.Key); err != nil {
+			return err
+		}
+
+		if policy.Tier != "" {
+			tier, ok := r.Tier(policy.Tier)
+			if !ok {
+				return errors.Wrapf(errors.ErrInvalidKey, "unknown tier %q for key %s", policy.Tier, policy.Key)
+			}
+			policy.Limit, policy.Refill = tier.LimitAt(time.Now())
+		}
+
+		if policy.Limit <= 0 {
+			return errors.Wrapf(errors.ErrInvalidTokens, "limit of key %s must be positive", policy.Key)
+		}
+
+		if policy.Refill <= 0 {
+			return errors.Wrapf(errors.ErrInvalidTokens, "refill rate of key %s must be positive", policy.Key)
+		}
+
+		strategy, err := backend.ParseRefillStrategy(policy.Strategy)
+		if err != nil {
+			return errors.Wrapf(err, "invalid refill strategy of key %s", policy.Key)
+		}
+		if _, linear := strategy.(backend.LinearRefill); !linear && !canSetStrategy {
+			return errors.Wrapf(errors.ErrBackendUnavailable, "backend does not support the refill strategy of key %s", policy.Key)
+		}
+
+		resolved = append(resolved, resolvedPolicy{Policy: policy, strategy: strategy})
+	}
+
+	for _, policy := range resolved {
+		// Check if context is cancelled
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "context cancelled")
+		default:
+		}
+
+		start := time.Now()
+		opCtx, done := r.withTimeout(ctx, "set_limit")
+		err := done(r.backend.SetLimit(opCtx, policy.Key, policy.Limit, policy.Refill))
+		r.observeBackend(ctx, "set_limit", start, err)
+		if err != nil {
+			return errors.Wrapf(err, "failed to preload limit of key %s", policy.Key)
+		}
+		r.notifyKeyChange(ctx, KeyLimitChanged, policy.Key, policy.Limit, policy.Refill)
+
+		if !canSetStrategy {
+			continue
+		}
+
+		start = time.Now()
+		opCtx, done = r.withTimeout(ctx, "set_refill_strategy")
+		err = done(setter.SetRefillStrategy(opCtx, policy.Key, policy.strategy))
+		r.observeBackend(ctx, "set_refill_strategy", start, err)
+		if err != nil {
+			return errors.Wrapf(err, "failed to preload refill strategy of key %s", policy.Key)
+		}
+	}
+
+	r.logConfigChange(ctx, "preload_policies", slog.Int("policies", len(resolved)))
+	return nil
+}
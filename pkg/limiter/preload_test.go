@@ -0,0 +1,142 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestPreloadPolicies(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(100).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig(), WithTiers(map[string]Tier{
+		"free": {Limit: 2, Refill: time.Hour},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	policies := []Policy{
+		{Key: "alice", Limit: 3, Refill: time.Hour},
+		{Key: "bob", Tier: "free"},
+	}
+	if err := limiter.PreloadPolicies(ctx, policies); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The first requests are judged against the preloaded limits
+	if allowed, _ := limiter.Take(ctx, "alice", 3); !allowed {
+		t.Error("expected take within the preloaded limit to be allowed")
+	}
+	if allowed, _ := limiter.Take(ctx, "alice", 1); allowed {
+		t.Error("expected take beyond the preloaded limit to be denied")
+	}
+	if info, _ := limiter.GetInfo(ctx, "bob"); info.MaxTokens != 2 {
+		t.Errorf("expected the tier limit of 2, got %d", info.MaxTokens)
+	}
+
+	// Preloading again does not refill buckets
+	if err := limiter.PreloadPolicies(ctx, policies); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed, _ := limiter.Take(ctx, "alice", 1); allowed {
+		t.Error("expected a second preload to leave the balance alone")
+	}
+}
+
+func TestPreloadPoliciesValidation(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	tests := []struct {
+		name   string
+		policy Policy
+		target error
+	}{
+		{name: "empty key", policy: Policy{Limit: 1, Refill: time.Second}, target: errors.ErrInvalidKey},
+		{name: "unknown tier", policy: Policy{Key: "bob", Tier: "gold"}, target: errors.ErrInvalidKey},
+		{name: "zero limit", policy: Policy{Key: "bob", Refill: time.Second}, target: errors.ErrInvalidTokens},
+		{name: "zero refill", policy: Policy{Key: "bob", Limit: 1}, target: errors.ErrInvalidTokens},
+		{name: "unknown refill strategy", policy: Policy{Key: "bob", Limit: 1, Refill: time.Second, Strategy: "sigmoid"}, target: errors.ErrInvalidTokens},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limiter.PreloadPolicies(ctx, []Policy{{Key: "alice", Limit: 1, Refill: time.Second}, tt.policy})
+			if !stderrors.Is(err, tt.target) {
+				t.Errorf("expected %v, got %v", tt.target, err)
+			}
+
+			// Nothing is written when any policy is invalid
+			if info, _ := limiter.GetInfo(ctx, "alice"); info.MaxTokens == 1 {
+				t.Error("expected the valid policy not to be written")
+			}
+		})
+	}
+}
+
+func TestPreloadPoliciesRefillStrategy(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	if err := limiter.PreloadPolicies(ctx, []Policy{{Key: "alice", Limit: 10, Refill: time.Second, Strategy: "stepped:5"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, _ := limiter.GetInfo(ctx, "alice"); info.Strategy != (backend.SteppedRefill{Tokens: 5}) {
+		t.Errorf("expected stepped refills, got %v", info.Strategy)
+	}
+
+	// A policy without a strategy restores linear refills
+	if err := limiter.PreloadPolicies(ctx, []Policy{{Key: "alice", Limit: 10, Refill: time.Second}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, _ := limiter.GetInfo(ctx, "alice"); info.Strategy != nil {
+		t.Errorf("expected linear refills, got %v", info.Strategy)
+	}
+
+	// Backends without strategies accept linear policies only
+	mock, err := New(&mockBackend{}, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer mock.Close(ctx)
+
+	if err := mock.PreloadPolicies(ctx, []Policy{{Key: "alice", Limit: 10, Refill: time.Second, Strategy: "linear"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = mock.PreloadPolicies(ctx, []Policy{{Key: "alice", Limit: 10, Refill: time.Second, Strategy: "reset"}})
+	if !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected backend unavailable error, got %v", err)
+	}
+	if err := mock.SetRefillStrategy(ctx, "alice", backend.ResetRefill{}); !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected backend unavailable error, got %v", err)
+	}
+}
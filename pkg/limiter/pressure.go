@@ -0,0 +1,46 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// PressureLevel returns how much of the budget of the key is spent, from 0 for a full bucket to 1 for an empty one
+// It rises as takes outpace refills, so producers can slow down before takes start being denied
+// Blocked keys and keys in debt report 1, and with a global limit the fuller of the two buckets counts
+func (r *RateLimiter) PressureLevel(ctx context.Context, key string) (float64, error) {
+	info, err := r.GetInfo(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	level := pressure(info, now)
+
+	if r.config.GlobalLimit > 0 && level < 1 {
+		global, err := r.GetInfo(ctx, globalKey)
+		if err != nil {
+			return 0, err
+		}
+		level = max(level, pressure(global, now))
+	}
+
+	return level, nil
+}
+
+// pressure returns the fraction of the bucket that is spent at the time, counting refills not yet applied
+func pressure(info *backend.TokenInfo, now time.Time) float64 {
+	if info.BlockedUntil.After(now) || info.MaxTokens <= 0 {
+		return 1
+	}
+
+	tokens := info.Tokens
+	if info.RefillRate > 0 && now.After(info.LastRefill) {
+		tokens += int64(now.Sub(info.LastRefill) / info.RefillRate)
+	}
+	tokens = min(tokens, info.MaxTokens)
+
+	return min(max(float64(info.MaxTokens-tokens)/float64(info.MaxTokens), 0), 1)
+}
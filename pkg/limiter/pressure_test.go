@@ -0,0 +1,95 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+func TestPressure(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		info     backend.TokenInfo
+		expected float64
+	}{
+		{name: "full", info: backend.TokenInfo{Tokens: 10, MaxTokens: 10, RefillRate: time.Second, LastRefill: now}, expected: 0},
+		{name: "quarter spent", info: backend.TokenInfo{Tokens: 3, MaxTokens: 4, RefillRate: time.Second, LastRefill: now}, expected: 0.25},
+		{name: "pending refills", info: backend.TokenInfo{Tokens: 2, MaxTokens: 10, RefillRate: time.Second, LastRefill: now.Add(-3 * time.Second)}, expected: 0.5},
+		{name: "refills stop at the limit", info: backend.TokenInfo{Tokens: 2, MaxTokens: 10, RefillRate: time.Second, LastRefill: now.Add(-time.Hour)}, expected: 0},
+		{name: "in debt", info: backend.TokenInfo{Tokens: -3, MaxTokens: 10, RefillRate: time.Hour, LastRefill: now}, expected: 1},
+		{name: "blocked", info: backend.TokenInfo{Tokens: 10, MaxTokens: 10, RefillRate: time.Second, LastRefill: now, BlockedUntil: now.Add(time.Minute)}, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pressure(&tt.info, now); got != tt.expected {
+				t.Errorf("expected pressure %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPressureLevel(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 10, time.Hour)
+
+	level := func(key string) float64 {
+		got, err := limiter.PressureLevel(ctx, key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return got
+	}
+
+	if got := level("test_key"); got != 0 {
+		t.Errorf("expected no pressure on a new key, got %v", got)
+	}
+
+	limiter.Take(ctx, "test_key", 5)
+	if got := level("test_key"); got != 0.5 {
+		t.Errorf("expected pressure 0.5, got %v", got)
+	}
+
+	limiter.Block(ctx, "other_key", time.Minute)
+	if got := level("other_key"); got != 1 {
+		t.Errorf("expected full pressure on a blocked key, got %v", got)
+	}
+
+	tenant := limiter.ForTenant("acme")
+	tenant.Take(ctx, "test_key", 2)
+	if got, _ := tenant.PressureLevel(ctx, "test_key"); got != 0.2 {
+		t.Errorf("expected tenant pressure 0.2, got %v", got)
+	}
+
+	if _, err := limiter.PressureLevel(ctx, ""); err == nil {
+		t.Error("expected error for an empty key, got nil")
+	}
+}
+
+func TestPressureLevelGlobal(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(10).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.GlobalLimit = 4
+	cfg.GlobalRefill = time.Hour
+
+	limiter, err := New(b, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	limiter.Take(ctx, "test_key", 3)
+	if got, _ := limiter.PressureLevel(ctx, "test_key"); got != 0.75 {
+		t.Errorf("expected the global bucket to set the pressure, got %v", got)
+	}
+}
@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// Prewarm fetches the state of keys into a backend caching it locally, such as the approximate backend,
+// so their first Takes are not all sent to the shared backend at once
+// Without keys it prewarms the hot keys this limiter observed, see HotKeysCapacity in the configuration
+// The backend must implement backend.Prewarmer
+func (r *RateLimiter) Prewarm(ctx context.Context, keys ...string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return errors.ErrLimiterClosed
+	}
+
+	prewarmer, ok := r.backend.(backend.Prewarmer)
+	if !ok {
+		return errors.Wrap(errors.ErrBackendUnavailable, "backend does not support prewarming")
+	}
+
+	if len(keys) == 0 && r.hotKeys != nil {
+		report := HotKeysReport{Active: r.hotKeys.active.top(r.config.HotKeysCapacity)}
+		keys = report.Keys()
+	}
+
+	for _, key := range keys {
+		if err := r.validateKey(key); err != nil {
+			return err
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "prewarm")
+	err := done(prewarmer.Prewarm(opCtx, keys))
+	r.observeBackend(ctx, "prewarm", start, err)
+
+	return err
+}
@@ -0,0 +1,78 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// prewarmBackend records the keys it is asked to prewarm
+type prewarmBackend struct {
+	mockBackend
+	prewarmed []string
+}
+
+func (p *prewarmBackend) Prewarm(ctx context.Context, keys []string) error {
+	p.prewarmed = append(p.prewarmed, keys...)
+	return nil
+}
+
+func TestPrewarm(t *testing.T) {
+	ctx := context.Background()
+	b := &prewarmBackend{}
+
+	cfg := config.DefaultConfig()
+	cfg.HotKeysCapacity = 10
+	cfg.EnableLogging = false
+
+	limiter, err := New(b, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	if err := limiter.Prewarm(ctx, "alice", "bob"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.prewarmed) != 2 || b.prewarmed[0] != "alice" || b.prewarmed[1] != "bob" {
+		t.Errorf("expected [alice bob] prewarmed, got %v", b.prewarmed)
+	}
+
+	// Without keys the hot keys are prewarmed, busiest first
+	b.prewarmed = nil
+	limiter.Take(ctx, "busy", 1)
+	limiter.Take(ctx, "busy", 1)
+	limiter.Take(ctx, "quiet", 1)
+	if err := limiter.Prewarm(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.prewarmed) != 2 || b.prewarmed[0] != "busy" || b.prewarmed[1] != "quiet" {
+		t.Errorf("expected [busy quiet] prewarmed, got %v", b.prewarmed)
+	}
+
+	if err := limiter.Prewarm(ctx, ""); err == nil {
+		t.Error("expected error for an empty key")
+	}
+}
+
+func TestPrewarmUnsupported(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	if err := limiter.Prewarm(ctx, "alice"); !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected ErrBackendUnavailable, got %v", err)
+	}
+}
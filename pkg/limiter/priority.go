@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"time"
+)
+
+// Priority controls the order in which waiters are admitted when tokens free up
+type Priority int
+
+const (
+	// PriorityLow is for background or best-effort traffic
+	PriorityLow Priority = iota
+	// PriorityNormal is the priority used by Wait
+	PriorityNormal
+	// PriorityHigh is for traffic that should be admitted first, e.g. paid users
+	PriorityHigh
+)
+
+// String returns a string representation of the priority
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// waiter represents a caller blocked in Wait for a specific key
+type waiter struct {
+	priority Priority
+	enqueued time.Time
+	seq      uint64
+}
+
+// effectivePriority returns the waiter priority raised by one level for every
+// aging interval spent waiting, so lower classes cannot be starved forever
+func (w *waiter) effectivePriority(now time.Time, aging time.Duration) Priority {
+	if aging <= 0 {
+		return w.priority
+	}
+
+	return w.priority + Priority(now.Sub(w.enqueued)/aging)
+}
+
+// enqueueWaiter registers a new waiter for the key, returning nil when the queue of the key is full
+func (r *RateLimiter) enqueueWaiter(key string, priority Priority) *waiter {
+	r.waitMu.Lock()
+	defer r.waitMu.Unlock()
+
+	if r.waiters == nil {
+		r.waiters = make(map[string][]*waiter)
+	}
+
+	if depth := r.config.WaitQueueDepth; depth > 0 && len(r.waiters[key]) >= depth {
+		return nil
+	}
+
+	r.waitSeq++
+	w := &waiter{
+		priority: priority,
+		enqueued: time.Now(),
+		seq:      r.waitSeq,
+	}
+	r.waiters[key] = append(r.waiters[key], w)
+
+	return w
+}
+
+// dequeueWaiter removes a waiter for the key
+func (r *RateLimiter) dequeueWaiter(key string, w *waiter) {
+	r.waitMu.Lock()
+	defer r.waitMu.Unlock()
+
+	queue := r.waiters[key]
+	for i, other := range queue {
+		if other == w {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+
+	if len(queue) == 0 {
+		delete(r.waiters, key)
+		return
+	}
+
+	r.waiters[key] = queue
+}
+
+// isNextWaiter reports whether the waiter is first in line for the key
+// Ties on effective priority are broken by arrival order
+func (r *RateLimiter) isNextWaiter(key string, w *waiter) bool {
+	r.waitMu.Lock()
+	defer r.waitMu.Unlock()
+
+	now := time.Now()
+	aging := r.config.WaitAgingInterval
+	priority := w.effectivePriority(now, aging)
+
+	for _, other := range r.waiters[key] {
+		if other == w {
+			continue
+		}
+
+		otherPriority := other.effectivePriority(now, aging)
+		if otherPriority > priority || (otherPriority == priority && other.seq < w.seq) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,177 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestWaitWithPriorityOrder(t *testing.T) {
+	var available atomic.Bool
+	backend := &mockBackend{
+		getInfoFunc: func(ctx context.Context, key string) (*backend.TokenInfo, error) {
+			tokens := int64(0)
+			if available.Load() {
+				tokens = 100
+			}
+			return &backend.TokenInfo{Key: key, Tokens: tokens, MaxTokens: 100}, nil
+		},
+	}
+
+	limiter, err := New(backend, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var order []Priority
+	var wg sync.WaitGroup
+
+	start := func(priority Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.WaitWithPriority(ctx, "test_key", 1, priority); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, priority)
+			mu.Unlock()
+		}()
+	}
+
+	// Low priority waiter arrives first
+	start(PriorityLow)
+	time.Sleep(20 * time.Millisecond)
+	start(PriorityHigh)
+	time.Sleep(20 * time.Millisecond)
+
+	available.Store(true)
+	wg.Wait()
+
+	if len(order) != 2 {
+		t.Fatalf("expected 2 admitted waiters, got %d", len(order))
+	}
+	if order[0] != PriorityHigh {
+		t.Errorf("expected high priority waiter to be admitted first, got %v", order)
+	}
+}
+
+func TestWaiterAging(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WaitAgingInterval = 50 * time.Millisecond
+
+	limiter, err := New(&mockBackend{}, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	low := limiter.enqueueWaiter("test_key", PriorityLow)
+	high := limiter.enqueueWaiter("test_key", PriorityHigh)
+
+	if limiter.isNextWaiter("test_key", low) {
+		t.Error("expected high priority waiter to be first in line")
+	}
+	if !limiter.isNextWaiter("test_key", high) {
+		t.Error("expected high priority waiter to be first in line")
+	}
+
+	// After two aging intervals the low waiter reaches high priority and wins on arrival order
+	low.enqueued = low.enqueued.Add(-2 * cfg.WaitAgingInterval)
+	if !limiter.isNextWaiter("test_key", low) {
+		t.Error("expected aged low priority waiter to be first in line")
+	}
+
+	limiter.dequeueWaiter("test_key", low)
+	limiter.dequeueWaiter("test_key", high)
+	if len(limiter.waiters) != 0 {
+		t.Errorf("expected no waiters left, got %d keys", len(limiter.waiters))
+	}
+}
+
+func TestWaitQueueDepth(t *testing.T) {
+	backend := &mockBackend{
+		getInfoFunc: func(ctx context.Context, key string) (*backend.TokenInfo, error) {
+			return &backend.TokenInfo{Key: key, Tokens: 0, MaxTokens: 100, RefillRate: time.Hour}, nil
+		},
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.WaitQueueDepth = 2
+
+	limiter, err := New(backend, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Wait(ctx, "test_key", 1)
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		limiter.waitMu.Lock()
+		queued := len(limiter.waiters["test_key"])
+		limiter.waitMu.Unlock()
+		if queued == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2 queued waiters, got %d", queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Callers beyond the depth fail at once, other keys have queues of their own
+	start := time.Now()
+	err = limiter.Wait(context.Background(), "test_key", 1)
+	if !errors.IsQueueFullError(err) {
+		t.Errorf("expected a QueueFullError, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected the rejection to be immediate, took %v", elapsed)
+	}
+
+	otherCtx, otherCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer otherCancel()
+	if err := limiter.Wait(otherCtx, "other_key", 1); errors.IsQueueFullError(err) {
+		t.Errorf("expected other keys to queue, got %v", err)
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+func TestPriorityString(t *testing.T) {
+	tests := []struct {
+		priority Priority
+		expected string
+	}{
+		{PriorityLow, "low"},
+		{PriorityNormal, "normal"},
+		{PriorityHigh, "high"},
+		{Priority(42), "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.priority.String(); got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+	}
+}
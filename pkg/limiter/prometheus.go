@@ -0,0 +1,186 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// prometheusCollector is a MetricsCollector backed by Prometheus metrics
+type prometheusCollector struct {
+	decisions       *prometheus.CounterVec
+	tenantDecisions *prometheus.CounterVec
+	keyDecisions    *prometheus.CounterVec
+	errors          *prometheus.CounterVec
+	backendDuration *prometheus.HistogramVec
+	activeKeys      prometheus.Gauge
+	memoryBytes     prometheus.Gauge
+	evictions       prometheus.Counter
+	lastEvictions   atomic.Uint64
+	loadFactor      prometheus.Gauge
+}
+
+// NewPrometheusCollector creates a MetricsCollector registering its metrics with the registerer
+// Limiters sharing a registerer share the same metrics
+func NewPrometheusCollector(registerer prometheus.Registerer) (MetricsCollector, error) {
+	decisions, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimiter_decisions_total",
+		Help: "Number of rate limit decisions.",
+	}, []string{"operation", "decision"}))
+	if err != nil {
+		return nil, err
+	}
+
+	tenantDecisions, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimiter_tenant_decisions_total",
+		Help: "Number of rate limit decisions made for tenants.",
+	}, []string{"tenant", "operation", "decision"}))
+	if err != nil {
+		return nil, err
+	}
+
+	keyDecisions, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimiter_key_decisions_total",
+		Help: "Number of rate limit decisions for the most requested keys, with the rest counted under key \"other\".",
+	}, []string{"key", "decision"}))
+	if err != nil {
+		return nil, err
+	}
+
+	errs, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimiter_errors_total",
+		Help: "Number of failed backend operations.",
+	}, []string{"operation", "error_type"}))
+	if err != nil {
+		return nil, err
+	}
+
+	backendDuration, err := registerCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ratelimiter_backend_duration_seconds",
+		Help:    "Duration of backend operations.",
+		Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"}))
+	if err != nil {
+		return nil, err
+	}
+
+	activeKeys, err := registerCollector(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ratelimiter_active_keys",
+		Help: "Number of keys tracked by the backend.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	memoryBytes, err := registerCollector(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ratelimiter_memory_bytes",
+		Help: "Approximate memory used by backend buckets.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	evictions, err := registerCollector(registerer, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ratelimiter_evictions_total",
+		Help: "Number of buckets evicted to stay under the memory cap.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	loadFactor, err := registerCollector(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ratelimiter_load_factor",
+		Help: "Fraction limits are scaled to by load shedding.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+	loadFactor.Set(1)
+
+	return &prometheusCollector{
+		decisions:       decisions,
+		tenantDecisions: tenantDecisions,
+		keyDecisions:    keyDecisions,
+		errors:          errs,
+		backendDuration: backendDuration,
+		activeKeys:      activeKeys,
+		memoryBytes:     memoryBytes,
+		evictions:       evictions,
+		loadFactor:      loadFactor,
+	}, nil
+}
+
+// registerCollector registers a collector, reusing an identical one that is already registered
+func registerCollector[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	if err := registerer.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, err
+	}
+
+	return collector, nil
+}
+
+// IncAllowed counts an allowed request
+func (c *prometheusCollector) IncAllowed(ctx context.Context, operation string) {
+	c.incDecision(ctx, operation, "allowed")
+}
+
+// IncDenied counts a denied request
+func (c *prometheusCollector) IncDenied(ctx context.Context, operation string) {
+	c.incDecision(ctx, operation, "denied")
+}
+
+// incDecision counts a decision, and again per tenant when the context carries one
+func (c *prometheusCollector) incDecision(ctx context.Context, operation string, decision string) {
+	c.decisions.WithLabelValues(operation, decision).Inc()
+	if id, ok := TenantFromContext(ctx); ok {
+		c.tenantDecisions.WithLabelValues(id, operation, decision).Inc()
+	}
+}
+
+// IncKeyDecision counts a decision on a key
+func (c *prometheusCollector) IncKeyDecision(ctx context.Context, key string, decision string) {
+	c.keyDecisions.WithLabelValues(key, decision).Inc()
+}
+
+// RemoveKey deletes the series of a key
+func (c *prometheusCollector) RemoveKey(ctx context.Context, key string) {
+	c.keyDecisions.DeletePartialMatch(prometheus.Labels{"key": key})
+}
+
+// IncError counts a failed backend call by error class
+func (c *prometheusCollector) IncError(ctx context.Context, operation string, errorType string) {
+	c.errors.WithLabelValues(operation, errorType).Inc()
+}
+
+// ObserveLatency records the duration of a backend call
+func (c *prometheusCollector) ObserveLatency(ctx context.Context, operation string, elapsed time.Duration) {
+	c.backendDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+}
+
+// SetActiveKeys reports the number of keys tracked by the backend
+func (c *prometheusCollector) SetActiveKeys(ctx context.Context, count int) {
+	c.activeKeys.Set(float64(count))
+}
+
+// SetMemoryUsage reports the approximate memory used by the backend and counts new evictions
+func (c *prometheusCollector) SetMemoryUsage(ctx context.Context, stats backend.MemoryStats) {
+	c.memoryBytes.Set(float64(stats.Bytes))
+	if last := c.lastEvictions.Swap(stats.Evictions); stats.Evictions > last {
+		c.evictions.Add(float64(stats.Evictions - last))
+	}
+}
+
+// SetLoadFactor reports the fraction limits are scaled to by load shedding
+func (c *prometheusCollector) SetLoadFactor(ctx context.Context, factor float64) {
+	c.loadFactor.Set(factor)
+}
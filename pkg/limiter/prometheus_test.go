@@ -0,0 +1,78 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+
+	collector, err := NewPrometheusCollector(registry)
+	if err != nil {
+		t.Fatalf("failed to create collector: %v", err)
+	}
+
+	// A second collector on the same registry reuses the metrics
+	if _, err := NewPrometheusCollector(registry); err != nil {
+		t.Fatalf("unexpected error registering twice: %v", err)
+	}
+
+	limiter, err := New(&mockBackend{}, config.DefaultConfig(), WithMetricsCollector(collector))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.Take(ctx, "test_key", 1)
+	limiter.Take(ctx, "test_key", 1)
+	collector.SetActiveKeys(ctx, 7)
+
+	memory := collector.(MemoryCollector)
+	memory.SetMemoryUsage(ctx, backend.MemoryStats{Bytes: 4096, Evictions: 3})
+	memory.SetMemoryUsage(ctx, backend.MemoryStats{Bytes: 2048, Evictions: 5})
+	collector.(LoadCollector).SetLoadFactor(ctx, 0.5)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	found := make(map[string]bool)
+	for _, family := range families {
+		found[family.GetName()] = true
+
+		switch family.GetName() {
+		case "ratelimiter_decisions_total":
+			if got := family.GetMetric()[0].GetCounter().GetValue(); got != 2 {
+				t.Errorf("expected 2 decisions, got %v", got)
+			}
+		case "ratelimiter_active_keys":
+			if got := family.GetMetric()[0].GetGauge().GetValue(); got != 7 {
+				t.Errorf("expected 7 active keys, got %v", got)
+			}
+		case "ratelimiter_memory_bytes":
+			if got := family.GetMetric()[0].GetGauge().GetValue(); got != 2048 {
+				t.Errorf("expected 2048 memory bytes, got %v", got)
+			}
+		case "ratelimiter_evictions_total":
+			if got := family.GetMetric()[0].GetCounter().GetValue(); got != 5 {
+				t.Errorf("expected 5 evictions, got %v", got)
+			}
+		case "ratelimiter_load_factor":
+			if got := family.GetMetric()[0].GetGauge().GetValue(); got != 0.5 {
+				t.Errorf("expected load factor 0.5, got %v", got)
+			}
+		}
+	}
+
+	for _, name := range []string{"ratelimiter_decisions_total", "ratelimiter_backend_duration_seconds", "ratelimiter_active_keys", "ratelimiter_memory_bytes", "ratelimiter_evictions_total", "ratelimiter_load_factor"} {
+		if !found[name] {
+			t.Errorf("expected metric %s to be registered", name)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// KeyLimiter limits a single key with the method set of golang.org/x/time/rate.Limiter
+// Code written against x/time/rate can switch to limits shared through any backend by swapping the type
+type KeyLimiter struct {
+	limiter *RateLimiter
+	key     string
+	err     error
+}
+
+// ForKey returns a KeyLimiter for the key, validated once here rather than on every call
+func (r *RateLimiter) ForKey(key string) *KeyLimiter {
+	return &KeyLimiter{limiter: r, key: key, err: r.validateKey(key)}
+}
+
+// Key returns the key the limiter takes tokens from
+func (l *KeyLimiter) Key() string {
+	return l.key
+}
+
+// Allow reports whether one event may happen now
+func (l *KeyLimiter) Allow() bool {
+	return l.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen now
+// The time is ignored since the backend refills by its own clock, and backend errors deny the events
+func (l *KeyLimiter) AllowN(t time.Time, n int) bool {
+	allowed, err := l.limiter.take(context.Background(), l.key, l.err, int64(n))
+	return err == nil && allowed
+}
+
+// Wait blocks until one event may happen or ctx is done
+func (l *KeyLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n events may happen and takes their tokens, or fails once ctx is done
+// As in x/time/rate, n beyond the limit of the key fails at once instead of blocking
+func (l *KeyLimiter) WaitN(ctx context.Context, n int) error {
+	return l.limiter.waitAndTake(ctx, l.key, int64(n), func() (bool, error) {
+		return l.limiter.take(ctx, l.key, l.err, int64(n))
+	})
+}
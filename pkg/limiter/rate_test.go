@@ -0,0 +1,103 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// rateLimiter is the method set of golang.org/x/time/rate.Limiter that KeyLimiter provides
+type rateLimiter interface {
+	Allow() bool
+	AllowN(t time.Time, n int) bool
+	Wait(ctx context.Context) error
+	WaitN(ctx context.Context, n int) error
+}
+
+var _ rateLimiter = (*KeyLimiter)(nil)
+
+func TestKeyLimiter(t *testing.T) {
+	limiter := newTestInMemoryLimiter(t, 3, 50*time.Millisecond)
+	l := limiter.ForKey("api")
+
+	if l.Key() != "api" {
+		t.Errorf("expected key api, got %s", l.Key())
+	}
+
+	if !l.AllowN(time.Now(), 2) {
+		t.Error("expected 2 events to be allowed")
+	}
+	if !l.Allow() {
+		t.Error("expected an event to be allowed")
+	}
+	if l.Allow() {
+		t.Error("expected an event beyond the limit to be denied")
+	}
+
+	// Another handle on the same key shares its bucket
+	if limiter.ForKey("api").Allow() {
+		t.Error("expected the shared bucket to be empty")
+	}
+
+	// Invalid counts are denied rather than reported
+	if l.AllowN(time.Now(), 0) {
+		t.Error("expected 0 events to be denied")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := l.Wait(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := l.WaitN(ctx, 2); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestKeyLimiterWaitTakesTokens(t *testing.T) {
+	limiter := newTestInMemoryLimiter(t, 2, time.Minute)
+	l := limiter.ForKey("api")
+
+	// Only the limit passes, the rest wait until their context ends
+	passed := 0
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		if l.Wait(ctx) == nil {
+			passed++
+		}
+		cancel()
+	}
+
+	if passed != 2 {
+		t.Errorf("expected 2 waits to pass at a limit of 2, got %d", passed)
+	}
+	if l.Allow() {
+		t.Error("expected the waits to have spent the bucket")
+	}
+}
+
+func TestKeyLimiterWaitBeyondCapacity(t *testing.T) {
+	limiter := newTestInMemoryLimiter(t, 3, time.Minute)
+	l := limiter.ForKey("api")
+
+	// No refill ever holds 4 tokens, so the wait fails without blocking until the context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := l.WaitN(ctx, 4); !stderrors.Is(err, errors.ErrInvalidTokens) {
+		t.Errorf("expected invalid tokens error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the wait to fail at once, took %v", elapsed)
+	}
+
+	// The tokens of the bucket were left alone
+	if !l.AllowN(time.Now(), 3) {
+		t.Error("expected the full limit to be allowed after the failed wait")
+	}
+}
@@ -0,0 +1,89 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// Schedule reserves tokens on a key and returns when the caller may proceed, on backends implementing backend.Scheduler
+// Tokens the balance lacks are reserved from the next refills, so callers pace themselves by sleeping until the
+// returned time instead of polling, and later callers queue up behind them. A time not after now means proceed at once
+// With a global limit the global bucket is reserved from too, and the later of the two times applies
+func (r *RateLimiter) Schedule(ctx context.Context, key string, tokens int64) (time.Time, error) {
+	ctx, span := r.startSpan(ctx, "ratelimiter.Schedule", key, tokens)
+	defer span.End()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return time.Time{}, errors.ErrLimiterClosed
+	}
+
+	if err := r.validateKey(key); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := r.validateTokens(tokens); err != nil {
+		return time.Time{}, err
+	}
+
+	scheduler, ok := r.backend.(backend.Scheduler)
+	if !ok {
+		return time.Time{}, errors.Wrap(errors.ErrBackendUnavailable, "backend does not support scheduling")
+	}
+
+	// Check if context is cancelled
+	select {
+	case <-ctx.Done():
+		return time.Time{}, errors.Wrap(ctx.Err(), "context cancelled")
+	default:
+	}
+
+	tokens = r.shedKeyTokens(ctx, []string{key}, tokens)
+	at, err := r.scheduleKey(ctx, scheduler, key, tokens)
+	if errors.IsRateLimitError(err) {
+		r.observeDecision(ctx, "schedule", key, tokens, false)
+		return time.Time{}, err
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to schedule tokens")
+	}
+
+	if r.config.GlobalLimit > 0 {
+		if err := r.refreshGlobalLimit(ctx); err != nil {
+			return time.Time{}, err
+		}
+
+		global, err := r.scheduleKey(ctx, scheduler, globalKey, tokens)
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "failed to schedule tokens on the global limit")
+		}
+		if global.After(at) {
+			at = global
+		}
+	}
+
+	r.observeDecision(ctx, "schedule", key, tokens, true)
+	return at, nil
+}
+
+// scheduleKey reserves tokens on one backend key
+func (r *RateLimiter) scheduleKey(ctx context.Context, scheduler backend.Scheduler, key string, tokens int64) (time.Time, error) {
+	start := time.Now()
+	opCtx, done := r.withTimeout(ctx, "schedule")
+	at, err := scheduler.Schedule(opCtx, key, tokens)
+	err = done(err)
+
+	// A blocked key is a decision rather than a backend failure
+	if errors.IsRateLimitError(err) {
+		r.observeBackend(ctx, "schedule", start, nil)
+		return at, err
+	}
+
+	r.observeBackend(ctx, "schedule", start, err)
+	return at, err
+}
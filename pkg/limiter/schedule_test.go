@@ -0,0 +1,93 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestSchedule(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(2).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	expected := []time.Duration{0, 0, time.Hour, 2 * time.Hour}
+	for i, wait := range expected {
+		at, err := limiter.Schedule(ctx, "alice", 1)
+		if err != nil {
+			t.Fatalf("schedule %d: unexpected error: %v", i, err)
+		}
+		if got := time.Until(at); got > wait || got < wait-time.Minute {
+			t.Errorf("schedule %d: expected to wait %v, got %v", i, wait, got)
+		}
+	}
+
+	// Scheduled callers go ahead of later takes
+	if allowed, _ := limiter.Take(ctx, "alice", 1); allowed {
+		t.Error("expected take behind the reservations to be denied")
+	}
+
+	limiter.Block(ctx, "bob", time.Minute)
+	if _, err := limiter.Schedule(ctx, "bob", 1); !errors.IsRateLimitError(err) {
+		t.Errorf("expected rate limit error for a blocked key, got %v", err)
+	}
+
+	if _, err := limiter.Schedule(ctx, "", 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error, got %v", err)
+	}
+}
+
+func TestScheduleGlobalLimit(t *testing.T) {
+	ctx := context.Background()
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(5).WithRefill(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.GlobalLimit = 1
+	cfg.GlobalRefill = time.Hour
+
+	limiter, err := New(b, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	limiter.Schedule(ctx, "alice", 1)
+
+	// Bob's own bucket is full, but the global one is reserved for an hour
+	at, err := limiter.Schedule(ctx, "bob", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wait := time.Until(at); wait <= 59*time.Minute {
+		t.Errorf("expected to wait for the global refill, got %v", wait)
+	}
+}
+
+func TestScheduleUnsupported(t *testing.T) {
+	ctx := context.Background()
+	limiter, err := New(&mockBackend{}, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	if _, err := limiter.Schedule(ctx, "alice", 1); !stderrors.Is(err, errors.ErrBackendUnavailable) {
+		t.Errorf("expected backend unavailable error, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+)
+
+// recentWindow is the sliding window StatusHandler reports recent decisions over
+const recentWindow = time.Minute
+
+// redacted replaces secrets in the configuration served by StatusHandler
+const redacted = "[redacted]"
+
+// Status is the JSON document served by StatusHandler
+type Status struct {
+	Time   time.Time      `json:"time"`
+	Uptime time.Duration  `json:"uptime"`
+	Config *config.Config `json:"config"`
+	Health HealthStatus   `json:"health"`
+
+	// Keys is the number of keys tracked by the backend, on backends implementing backend.KeyCounter
+	Keys *int `json:"keys,omitempty"`
+
+	// Decisions counts the decisions of this limiter since it was created, Recent those over the last minute
+	Decisions DecisionCounts `json:"decisions"`
+	Recent    DecisionCounts `json:"recent"`
+
+	// Backend is the usage across instances as the backend reports it, on backends implementing backend.StatsReporter
+	Backend *backend.Stats `json:"backend,omitempty"`
+
+	// Memory is the memory used by the backend, on backends implementing backend.MemoryReporter
+	Memory *backend.MemoryStats `json:"memory,omitempty"`
+}
+
+// DecisionCounts counts allow and deny decisions and backend errors
+type DecisionCounts struct {
+	Allowed  uint64        `json:"allowed"`
+	Denied   uint64        `json:"denied"`
+	Errors   uint64        `json:"errors,omitempty"`
+	DenyRate float64       `json:"deny_rate"`
+	Window   time.Duration `json:"window,omitempty"`
+}
+
+// StatusHandler returns an http.Handler serving the limiter's configuration, backend health, key count and
+// decision counters as JSON, for a quick look with curl where no metrics system scrapes the limiter
+// Secrets in the configuration are redacted, and backend calls are bounded by HealthCheckTimeout
+// Every request is rejected with 403 when auth returns false, a nil auth allows everyone and leaves access
+// control to middleware of the caller, as the document still shows the limits and addresses in use
+// Decisions over the last minute are only counted once the first status handler is created
+func (r *RateLimiter) StatusHandler(auth DashboardAuthFunc) http.Handler {
+	r.recent.CompareAndSwap(nil, newRecentDecisions())
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if auth != nil && !auth(req) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := req.Context()
+		if timeout := r.config.HealthCheckTimeout; timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(r.status(ctx))
+	})
+}
+
+// status collects the current limiter state for StatusHandler
+func (r *RateLimiter) status(ctx context.Context) Status {
+	now := time.Now()
+	healthErr := r.HealthCheck(ctx)
+
+	r.mu.RLock()
+	closed := r.closed
+	r.mu.RUnlock()
+
+	status := Status{
+		Time:   now,
+		Uptime: now.Sub(r.created),
+		Config: redactConfig(r.GetConfig()),
+		Health: HealthStatus{
+			Status:    "ok",
+			Backend:   backendName(r.backend),
+			LatencyMs: float64(time.Since(now)) / float64(time.Millisecond),
+			Closed:    closed,
+		},
+		Decisions: DecisionCounts{
+			Allowed: r.allowedCount.Load(),
+			Denied:  r.deniedCount.Load(),
+			Errors:  r.errorCount.Load(),
+		},
+	}
+	if recent := r.recent.Load(); recent != nil {
+		status.Recent = recent.counts(now)
+	}
+	status.Decisions.DenyRate = ratio(status.Decisions.Denied, status.Decisions.Allowed+status.Decisions.Denied)
+
+	if healthErr != nil {
+		status.Health.Status = "unavailable"
+		status.Health.Error = healthErr.Error()
+		return status
+	}
+
+	// Backend reports are best effort, a failing one is left out
+	if counter, ok := r.backend.(backend.KeyCounter); ok {
+		if keys, err := counter.KeyCount(ctx); err == nil {
+			status.Keys = &keys
+		}
+	}
+
+	if reporter, ok := r.backend.(backend.StatsReporter); ok {
+		if stats, err := reporter.Stats(ctx); err == nil {
+			status.Backend = &stats
+		}
+	}
+
+	if reporter, ok := r.backend.(backend.MemoryReporter); ok {
+		if memory, err := reporter.MemoryStats(ctx); err == nil {
+			status.Memory = &memory
+		}
+	}
+
+	return status
+}
+
+// redactConfig blanks the secrets of a configuration copy
+func redactConfig(cfg *config.Config) *config.Config {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.Redis.Password != "" {
+		cfg.Redis.Password = redacted
+	}
+
+	if cfg.Redis.KeyHashSecret != "" {
+		cfg.Redis.KeyHashSecret = redacted
+	}
+
+	// Addresses given as URLs may carry credentials
+	cfg.Redis.Addr = redactURL(cfg.Redis.Addr)
+	for i, replica := range cfg.Redis.ReadReplicas {
+		cfg.Redis.ReadReplicas[i] = redactURL(replica)
+	}
+
+	return cfg
+}
+
+// redactURL blanks the password of a URL, leaving anything else untouched
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+
+	if _, ok := u.User.Password(); !ok {
+		return raw
+	}
+
+	u.User = url.UserPassword(u.User.Username(), redacted)
+	return u.String()
+}
+
+// recentDecisions counts allowed and denied decisions over the recentWindow
+type recentDecisions struct {
+	window backend.WindowCounter
+}
+
+// newRecentDecisions returns a recentDecisions with no decisions
+func newRecentDecisions() *recentDecisions {
+	return &recentDecisions{window: backend.WindowCounter{Window: recentWindow}}
+}
+
+// record counts a decision at now
+func (c *recentDecisions) record(now time.Time, allowed bool) {
+	if allowed {
+		c.window.Add(now, 1, 0)
+	} else {
+		c.window.Add(now, 0, 1)
+	}
+}
+
+// counts reports the decisions over the recentWindow before now
+func (c *recentDecisions) counts(now time.Time) DecisionCounts {
+	allowed, denied := c.window.Counts(now)
+	counts := DecisionCounts{Allowed: uint64(allowed), Denied: uint64(denied), Window: recentWindow}
+	counts.DenyRate = ratio(counts.Denied, counts.Allowed+counts.Denied)
+
+	return counts
+}
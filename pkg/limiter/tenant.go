@@ -0,0 +1,205 @@
+package limiter
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// tenantPrefix starts the backend keys of every tenant, keys outside tenants should not use it
+const tenantPrefix = "tenant:"
+
+// Tenant limits the keys of one tenant in a namespace of their own
+// Keys and groups of different tenants never share buckets, and metrics are labelled with the tenant ID
+type Tenant struct {
+	limiter *RateLimiter
+	id      string
+	prefix  string
+	err     error
+
+	limit  int64
+	refill time.Duration
+	tier   string
+}
+
+// ForTenant returns a Tenant whose keys are isolated from those of other tenants
+// The ID must not be empty or contain a colon, otherwise every call returns an error
+func (r *RateLimiter) ForTenant(id string) *Tenant {
+	t := &Tenant{limiter: r, id: id, prefix: tenantPrefix + id + ":"}
+
+	switch {
+	case id == "":
+		t.err = errors.Wrap(errors.ErrInvalidKey, "tenant ID cannot be empty")
+	case strings.Contains(id, ":"):
+		t.err = errors.Wrap(errors.ErrInvalidKey, "tenant ID cannot contain a colon")
+	}
+
+	return t
+}
+
+// WithLimit returns a copy of the tenant applying the limit and refill rate to its keys in place of the configured defaults
+func (t *Tenant) WithLimit(limit int64, refill time.Duration) *Tenant {
+	newTenant := *t
+	newTenant.limit = limit
+	newTenant.refill = refill
+	newTenant.tier = ""
+	return &newTenant
+}
+
+// WithTier returns a copy of the tenant applying the named tier or template to its keys, resolved on every take
+func (t *Tenant) WithTier(tier string) *Tenant {
+	newTenant := *t
+	newTenant.tier = tier
+	newTenant.limit = 0
+	newTenant.refill = 0
+	return &newTenant
+}
+
+// ID returns the tenant ID
+func (t *Tenant) ID() string {
+	return t.id
+}
+
+// Take attempts to consume tokens from a key of the tenant
+func (t *Tenant) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	if t.err != nil {
+		return false, t.err
+	}
+
+	ctx = ContextWithTenant(ctx, t.id)
+	if t.tier != "" {
+		return t.limiter.TakeForTier(ctx, t.key(key), t.tier, tokens)
+	}
+
+	if t.limit > 0 {
+		return t.limiter.TakeWithLimit(ctx, t.key(key), tokens, t.limit, t.refill)
+	}
+
+	return t.limiter.Take(ctx, t.key(key), tokens)
+}
+
+// TakeWithLimit attempts to consume tokens from a key of the tenant with a custom limit
+func (t *Tenant) TakeWithLimit(ctx context.Context, key string, tokens int64, limit int64, refill time.Duration) (bool, error) {
+	if t.err != nil {
+		return false, t.err
+	}
+
+	return t.limiter.TakeWithLimit(ContextWithTenant(ctx, t.id), t.key(key), tokens, limit, refill)
+}
+
+// TakeInGroup attempts to consume tokens from a key of the tenant and the shared bucket of its group within the tenant
+func (t *Tenant) TakeInGroup(ctx context.Context, key string, group string, tokens int64) (bool, error) {
+	if t.err != nil {
+		return false, t.err
+	}
+
+	if group == "" {
+		return false, errEmptyKey
+	}
+
+	return t.limiter.TakeInGroup(ContextWithTenant(ctx, t.id), t.key(key), t.key(group), tokens)
+}
+
+// SetGroupLimit sets the limit of a group within the tenant
+func (t *Tenant) SetGroupLimit(ctx context.Context, group string, limit int64, refill time.Duration) error {
+	if t.err != nil {
+		return t.err
+	}
+
+	if group == "" {
+		return errEmptyKey
+	}
+
+	return t.limiter.SetGroupLimit(ContextWithTenant(ctx, t.id), t.key(group), limit, refill)
+}
+
+// Reset clears the rate limit of a key of the tenant
+func (t *Tenant) Reset(ctx context.Context, key string) error {
+	if t.err != nil {
+		return t.err
+	}
+
+	return t.limiter.Reset(ContextWithTenant(ctx, t.id), t.key(key))
+}
+
+// GetInfo returns the state of a key of the tenant, reported under the key as passed in
+func (t *Tenant) GetInfo(ctx context.Context, key string) (*backend.TokenInfo, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	info, err := t.limiter.GetInfo(ContextWithTenant(ctx, t.id), t.key(key))
+	if info != nil {
+		info.Key = key
+	}
+
+	return info, err
+}
+
+// PressureLevel returns how much of the budget of a key of the tenant is spent
+func (t *Tenant) PressureLevel(ctx context.Context, key string) (float64, error) {
+	if t.err != nil {
+		return 0, t.err
+	}
+
+	return t.limiter.PressureLevel(ContextWithTenant(ctx, t.id), t.key(key))
+}
+
+// Block denies all Takes for a key of the tenant until the duration expires
+func (t *Tenant) Block(ctx context.Context, key string, duration time.Duration) error {
+	if t.err != nil {
+		return t.err
+	}
+
+	return t.limiter.Block(ContextWithTenant(ctx, t.id), t.key(key), duration)
+}
+
+// Unblock lifts a block on a key of the tenant before it expires
+func (t *Tenant) Unblock(ctx context.Context, key string) error {
+	if t.err != nil {
+		return t.err
+	}
+
+	return t.limiter.Unblock(ContextWithTenant(ctx, t.id), t.key(key))
+}
+
+// Wait waits until tokens become available for a key of the tenant or ctx is cancelled
+func (t *Tenant) Wait(ctx context.Context, key string, tokens int64) error {
+	return t.WaitWithPriority(ctx, key, tokens, PriorityNormal)
+}
+
+// WaitWithPriority waits until tokens become available for a key of the tenant, admitting higher priorities first
+func (t *Tenant) WaitWithPriority(ctx context.Context, key string, tokens int64, priority Priority) error {
+	if t.err != nil {
+		return t.err
+	}
+
+	return t.limiter.WaitWithPriority(ContextWithTenant(ctx, t.id), t.key(key), tokens, priority)
+}
+
+// key returns the backend key of a key or group of the tenant, leaving empty keys for validation to reject
+func (t *Tenant) key(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	return t.prefix + key
+}
+
+// tenantKey is the context key holding the tenant of a request
+type tenantKey struct{}
+
+// ContextWithTenant returns a context carrying the tenant ID that metrics of calls made with it are labelled with
+// Tenant methods set it, custom MetricsCollectors can read it back with TenantFromContext
+func ContextWithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant ID stored in the context, if any
+func TenantFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok
+}
@@ -0,0 +1,190 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 2, time.Hour)
+
+	acme := limiter.ForTenant("acme")
+	globex := limiter.ForTenant("globex")
+
+	if acme.ID() != "acme" {
+		t.Errorf("expected ID acme, got %s", acme.ID())
+	}
+
+	for i := 0; i < 2; i++ {
+		if allowed, err := acme.Take(ctx, "user", 1); err != nil || !allowed {
+			t.Fatalf("expected take %d to be allowed, got %v, %v", i, allowed, err)
+		}
+	}
+	if allowed, _ := acme.Take(ctx, "user", 1); allowed {
+		t.Error("expected take beyond the limit to be denied")
+	}
+
+	// The same key of another tenant, or outside tenants, has a bucket of its own
+	if allowed, _ := globex.Take(ctx, "user", 1); !allowed {
+		t.Error("expected another tenant to have its own bucket")
+	}
+	if allowed, _ := limiter.Take(ctx, "user", 1); !allowed {
+		t.Error("expected keys outside tenants to have their own bucket")
+	}
+
+	info, err := acme.GetInfo(ctx, "user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Key != "user" || info.Tokens != 0 {
+		t.Errorf("expected user with 0 tokens, got %s with %d", info.Key, info.Tokens)
+	}
+
+	if err := acme.Reset(ctx, "user"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed, _ := acme.Take(ctx, "user", 1); !allowed {
+		t.Error("expected take to be allowed after reset")
+	}
+
+	if err := globex.Block(ctx, "user", time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed, _ := globex.Take(ctx, "user", 1); allowed {
+		t.Error("expected blocked key to be denied")
+	}
+	if allowed, _ := acme.Take(ctx, "user", 1); !allowed {
+		t.Error("expected a block to stay within its tenant")
+	}
+	if err := globex.Unblock(ctx, "user"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTenantGroups(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 10, time.Hour)
+
+	acme := limiter.ForTenant("acme")
+	globex := limiter.ForTenant("globex")
+
+	if err := acme.SetGroupLimit(ctx, "free", 1, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if allowed, _ := acme.TakeInGroup(ctx, "a", "free", 1); !allowed {
+		t.Error("expected first take in group to be allowed")
+	}
+	if allowed, _ := acme.TakeInGroup(ctx, "b", "free", 1); allowed {
+		t.Error("expected the group limit to be shared by keys of the tenant")
+	}
+	if allowed, _ := globex.TakeInGroup(ctx, "b", "free", 1); !allowed {
+		t.Error("expected another tenant to have its own group")
+	}
+
+	if _, err := acme.TakeInGroup(ctx, "a", "", 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error for an empty group, got %v", err)
+	}
+}
+
+func TestTenantWithLimit(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 10, time.Hour)
+
+	tenant := limiter.ForTenant("trial").WithLimit(1, time.Hour)
+
+	if allowed, _ := tenant.Take(ctx, "user", 1); !allowed {
+		t.Error("expected first take to be allowed")
+	}
+	if allowed, _ := tenant.Take(ctx, "user", 1); allowed {
+		t.Error("expected the tenant limit to apply in place of the default")
+	}
+
+	// The tenant without the policy keeps the defaults
+	if allowed, _ := limiter.ForTenant("trial").Take(ctx, "other", 2); !allowed {
+		t.Error("expected WithLimit to return a copy")
+	}
+}
+
+func TestTenantInvalidID(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestInMemoryLimiter(t, 10, time.Hour)
+
+	for _, id := range []string{"", "a:b"} {
+		tenant := limiter.ForTenant(id)
+
+		if _, err := tenant.Take(ctx, "user", 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+			t.Errorf("expected invalid key error for tenant %q, got %v", id, err)
+		}
+		if _, err := tenant.GetInfo(ctx, "user"); !stderrors.Is(err, errors.ErrInvalidKey) {
+			t.Errorf("expected invalid key error for tenant %q, got %v", id, err)
+		}
+	}
+
+	if _, err := limiter.ForTenant("acme").Take(ctx, "", 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error for an empty key, got %v", err)
+	}
+}
+
+func TestTenantMetrics(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+
+	collector, err := NewPrometheusCollector(registry)
+	if err != nil {
+		t.Fatalf("failed to create collector: %v", err)
+	}
+
+	limiter, err := New(&mockBackend{}, config.DefaultConfig(), WithMetricsCollector(collector))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	limiter.ForTenant("acme").Take(ctx, "user", 1)
+	limiter.ForTenant("acme").Take(ctx, "user", 1)
+	limiter.Take(ctx, "user", 1)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	found := false
+	for _, family := range families {
+		switch family.GetName() {
+		case "ratelimiter_decisions_total":
+			if got := family.GetMetric()[0].GetCounter().GetValue(); got != 3 {
+				t.Errorf("expected 3 decisions, got %v", got)
+			}
+		case "ratelimiter_tenant_decisions_total":
+			found = true
+			metric := family.GetMetric()[0]
+			if got := metric.GetCounter().GetValue(); got != 2 {
+				t.Errorf("expected 2 tenant decisions, got %v", got)
+			}
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "tenant" && label.GetValue() != "acme" {
+					t.Errorf("expected tenant label acme, got %s", label.GetValue())
+				}
+			}
+		}
+	}
+
+	if !found {
+		t.Error("expected metric ratelimiter_tenant_decisions_total to be registered")
+	}
+
+	if id, ok := TenantFromContext(ContextWithTenant(ctx, "acme")); !ok || id != "acme" {
+		t.Errorf("expected tenant acme in context, got %q", id)
+	}
+	if _, ok := TenantFromContext(ctx); ok {
+		t.Error("expected no tenant in a plain context")
+	}
+}
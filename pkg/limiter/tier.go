@@ -0,0 +1,244 @@
+package limiter
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// Tier is a named bundle of limits shared by every key on the same plan, such as free, pro or enterprise
+type Tier struct {
+	// Limit is the maximum number of tokens in the bucket of each key
+	Limit int64 `json:"limit"`
+
+	// Refill is the time it takes to refill one token
+	Refill time.Duration `json:"refill"`
+
+	// Schedule replaces Limit and Refill during windows of the day, the first window containing the time applies
+	Schedule []ScheduledLimit `json:"schedule,omitempty"`
+
+	// Location is the time zone the schedule is evaluated in, nil means UTC
+	Location *time.Location `json:"-"`
+}
+
+// ScheduledLimit is a limit that applies during a daily window, such as business hours or a nightly batch window
+type ScheduledLimit struct {
+	// Days are the days the window starts on, empty means every day
+	Days []time.Weekday `json:"days,omitempty"`
+
+	// Start and End are offsets from midnight, a window ending before it starts runs past midnight into the next day
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+
+	Limit  int64         `json:"limit"`
+	Refill time.Duration `json:"refill"`
+}
+
+// contains reports whether the window contains the time
+func (s ScheduledLimit) contains(t time.Time) bool {
+	hour, minute, second := t.Clock()
+	offset := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute +
+		time.Duration(second)*time.Second + time.Duration(t.Nanosecond())
+
+	switch {
+	case s.Start < s.End:
+		return offset >= s.Start && offset < s.End && s.startsOn(t.Weekday())
+	case offset >= s.Start:
+		return s.startsOn(t.Weekday())
+	case offset < s.End:
+		// Past midnight the window belongs to the day before
+		return s.startsOn((t.Weekday() + 6) % 7)
+	default:
+		return false
+	}
+}
+
+// startsOn reports whether the window starts on the day
+func (s ScheduledLimit) startsOn(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+
+	for _, d := range s.Days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LimitAt returns the limit and refill rate of the tier at the time
+func (t Tier) LimitAt(now time.Time) (int64, time.Duration) {
+	if len(t.Schedule) == 0 {
+		return t.Limit, t.Refill
+	}
+
+	location := t.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	now = now.In(location)
+	for _, window := range t.Schedule {
+		if window.contains(now) {
+			return window.Limit, window.Refill
+		}
+	}
+
+	return t.Limit, t.Refill
+}
+
+// Validate validates the tier
+func (t Tier) Validate() error {
+	if t.Limit <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "limit must be positive")
+	}
+
+	if t.Refill <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "refill rate must be positive")
+	}
+
+	for i, window := range t.Schedule {
+		if window.Limit <= 0 {
+			return errors.Wrapf(errors.ErrInvalidTokens, "schedule %d: limit must be positive", i)
+		}
+
+		if window.Refill <= 0 {
+			return errors.Wrapf(errors.ErrInvalidTokens, "schedule %d: refill rate must be positive", i)
+		}
+
+		if window.Start < 0 || window.Start >= 24*time.Hour || window.End < 0 || window.End > 24*time.Hour {
+			return errors.Wrapf(errors.ErrInvalidTokens, "schedule %d: start and end must be within the day", i)
+		}
+
+		if window.Start == window.End {
+			return errors.Wrapf(errors.ErrInvalidTokens, "schedule %d: start and end must differ", i)
+		}
+
+		for _, day := range window.Days {
+			if day < time.Sunday || day > time.Saturday {
+				return errors.Wrapf(errors.ErrInvalidTokens, "schedule %d: invalid day %d", i, day)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WithTiers registers the tiers by name, New fails if any of them is invalid
+func WithTiers(tiers map[string]Tier) Option {
+	return func(r *RateLimiter) {
+		r.tierMu.Lock()
+		defer r.tierMu.Unlock()
+
+		if r.tiers == nil {
+			r.tiers = make(map[string]Tier, len(tiers))
+		}
+		for name, tier := range tiers {
+			r.tiers[name] = tier
+		}
+	}
+}
+
+// SetTier defines or redefines a tier, keys taking from it move to the new limits on their next take
+func (r *RateLimiter) SetTier(ctx context.Context, name string, tier Tier) error {
+	if name == "" {
+		return errors.Wrap(errors.ErrInvalidKey, "tier name cannot be empty")
+	}
+
+	if err := tier.Validate(); err != nil {
+		return err
+	}
+
+	r.tierMu.Lock()
+	if r.tiers == nil {
+		r.tiers = make(map[string]Tier)
+	}
+	r.tiers[name] = tier
+	r.tierGen.Add(1)
+	r.tierMu.Unlock()
+
+	r.logConfigChange(ctx, "set_tier",
+		slog.String("tier", name),
+		slog.Int64("limit", tier.Limit),
+		slog.Duration("refill", tier.Refill),
+	)
+	return nil
+}
+
+// RemoveTier removes a tier, takes from it fail until it is defined again
+func (r *RateLimiter) RemoveTier(ctx context.Context, name string) {
+	r.tierMu.Lock()
+	_, ok := r.tiers[name]
+	delete(r.tiers, name)
+	r.tierGen.Add(1)
+	r.tierMu.Unlock()
+
+	if ok {
+		r.logConfigChange(ctx, "remove_tier", slog.String("tier", name))
+	}
+}
+
+// Tier returns the definition of a tier and whether it exists
+func (r *RateLimiter) Tier(name string) (Tier, bool) {
+	r.tierMu.RLock()
+	defer r.tierMu.RUnlock()
+
+	tier, ok := r.tiers[name]
+	return tier, ok
+}
+
+// TakeForTier attempts to consume tokens from the key under the limits of its tier
+// Scheduled limits are evaluated on every call, so keys move between them as windows open and close
+// Unknown tiers are rejected with an invalid key error rather than falling back to the defaults
+func (r *RateLimiter) TakeForTier(ctx context.Context, key string, tier string, tokens int64) (bool, error) {
+	t, ok := r.Tier(tier)
+	if !ok {
+		return false, errors.Wrapf(errors.ErrInvalidKey, "unknown tier %q", tier)
+	}
+
+	limit, refill := t.LimitAt(time.Now())
+	return r.TakeWithLimit(ctx, key, tokens, limit, refill)
+}
+
+// templateTier converts a template of a validated configuration into a tier
+func templateTier(template config.LimitTemplate) Tier {
+	tier := Tier{Limit: template.Limit, Refill: template.Refill}
+	if len(template.Schedule) == 0 {
+		return tier
+	}
+
+	tier.Location, _ = time.LoadLocation(template.TimeZone)
+	for _, window := range template.Schedule {
+		scheduled := ScheduledLimit{Start: window.Start, End: window.End, Limit: window.Limit, Refill: window.Refill}
+		for _, name := range window.Days {
+			day, _ := config.ParseWeekday(name)
+			scheduled.Days = append(scheduled.Days, day)
+		}
+		tier.Schedule = append(tier.Schedule, scheduled)
+	}
+
+	return tier
+}
+
+// validateTiers validates every registered tier
+func (r *RateLimiter) validateTiers() error {
+	r.tierMu.RLock()
+	defer r.tierMu.RUnlock()
+
+	for name, tier := range r.tiers {
+		if name == "" {
+			return errors.Wrap(errors.ErrInvalidKey, "tier name cannot be empty")
+		}
+
+		if err := tier.Validate(); err != nil {
+			return errors.Wrapf(err, "tier %q", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package limiter
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func TestTakeForTier(t *testing.T) {
+	ctx := context.Background()
+	backend, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(backend, config.DefaultConfig(), WithTiers(map[string]Tier{
+		"free": {Limit: 2, Refill: time.Hour},
+		"pro":  {Limit: 5, Refill: time.Hour},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	take := func(key, tier string, tokens int64) bool {
+		allowed, err := limiter.TakeForTier(ctx, key, tier, tokens)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return allowed
+	}
+
+	if !take("alice", "free", 2) {
+		t.Error("expected take within the free tier to be allowed")
+	}
+	if take("alice", "free", 1) {
+		t.Error("expected take beyond the free tier to be denied")
+	}
+	if !take("bob", "pro", 5) {
+		t.Error("expected take within the pro tier to be allowed")
+	}
+
+	// Redefining a tier moves its keys to the new limits on their next take
+	if err := limiter.SetTier(ctx, "free", Tier{Limit: 10, Refill: time.Hour}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tier, ok := limiter.Tier("free"); !ok || tier.Limit != 10 {
+		t.Errorf("expected the free tier to have limit 10, got %+v", tier)
+	}
+	if !take("carol", "free", 10) {
+		t.Error("expected take within the raised free tier to be allowed")
+	}
+
+	// Unknown and removed tiers are rejected
+	if _, err := limiter.TakeForTier(ctx, "alice", "gold", 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error for an unknown tier, got %v", err)
+	}
+	limiter.RemoveTier(ctx, "pro")
+	if _, err := limiter.TakeForTier(ctx, "bob", "pro", 1); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error for a removed tier, got %v", err)
+	}
+
+	if err := limiter.SetTier(ctx, "broken", Tier{Limit: 0, Refill: time.Hour}); err == nil {
+		t.Error("expected error for a tier without a limit, got nil")
+	}
+	if err := limiter.SetTier(ctx, "", Tier{Limit: 1, Refill: time.Hour}); err == nil {
+		t.Error("expected error for an unnamed tier, got nil")
+	}
+}
+
+func TestWithTiersValidation(t *testing.T) {
+	backend, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	defer backend.Close(context.Background())
+
+	_, err = New(backend, config.DefaultConfig(), WithTiers(map[string]Tier{
+		"free": {Limit: 10, Refill: 0},
+	}))
+	if err == nil {
+		t.Error("expected error for a tier without a refill rate, got nil")
+	}
+}
+
+func TestConfigTemplates(t *testing.T) {
+	ctx := context.Background()
+	backend, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Templates = map[string]config.LimitTemplate{
+		"login-strict":   {Limit: 1, Refill: time.Hour},
+		"search-default": {Limit: 3, Refill: time.Hour},
+	}
+
+	limiter, err := New(backend, cfg, WithTiers(map[string]Tier{
+		"search-default": {Limit: 2, Refill: time.Hour},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	if allowed, err := limiter.TakeForTier(ctx, "login:alice", "login-strict", 1); err != nil || !allowed {
+		t.Fatalf("expected take under a template to be allowed, got %v, %v", allowed, err)
+	}
+	if allowed, _ := limiter.TakeForTier(ctx, "login:alice", "login-strict", 1); allowed {
+		t.Error("expected take beyond the template limit to be denied")
+	}
+
+	// Tiers passed to New take precedence over templates of the same name
+	if tier, _ := limiter.Tier("search-default"); tier.Limit != 2 {
+		t.Errorf("expected WithTiers to override the template, got limit %d", tier.Limit)
+	}
+
+	// Tenants refer to templates by name, and see changes to them on their next take
+	tenant := limiter.ForTenant("acme").WithTier("login-strict")
+	if allowed, _ := tenant.Take(ctx, "bob", 1); !allowed {
+		t.Error("expected first tenant take to be allowed")
+	}
+	if allowed, _ := tenant.Take(ctx, "bob", 1); allowed {
+		t.Error("expected the template limit to apply to the tenant")
+	}
+	if err := limiter.SetTier(ctx, "login-strict", Tier{Limit: 5, Refill: time.Millisecond}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if allowed, _ := tenant.Take(ctx, "bob", 2); !allowed {
+		t.Error("expected the changed template to reach the tenant")
+	}
+
+	// The configuration returned by GetConfig does not share its templates
+	limiter.GetConfig().Templates["login-strict"] = config.LimitTemplate{Limit: 100, Refill: time.Second}
+	if got := limiter.GetConfig().Templates["login-strict"].Limit; got != 1 {
+		t.Errorf("expected the configured template to be unchanged, got limit %d", got)
+	}
+}
+
+func TestTierSchedule(t *testing.T) {
+	tier := Tier{
+		Limit:  500,
+		Refill: time.Minute / 500,
+		Schedule: []ScheduledLimit{
+			{
+				Days:   []time.Weekday{time.Friday},
+				Start:  22 * time.Hour,
+				End:    6 * time.Hour,
+				Limit:  5000,
+				Refill: time.Minute / 5000,
+			},
+			{
+				Start:  18 * time.Hour,
+				End:    8 * time.Hour,
+				Limit:  2000,
+				Refill: time.Minute / 2000,
+			},
+		},
+	}
+	if err := tier.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		limit int64
+	}{
+		{name: "business hours", now: time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC), limit: 500},
+		{name: "evening", now: time.Date(2024, 1, 3, 18, 0, 0, 0, time.UTC), limit: 2000},
+		{name: "after midnight", now: time.Date(2024, 1, 4, 7, 59, 0, 0, time.UTC), limit: 2000},
+		{name: "window end", now: time.Date(2024, 1, 4, 8, 0, 0, 0, time.UTC), limit: 500},
+		{name: "friday night", now: time.Date(2024, 1, 5, 23, 0, 0, 0, time.UTC), limit: 5000},
+		{name: "saturday morning of the friday window", now: time.Date(2024, 1, 6, 5, 0, 0, 0, time.UTC), limit: 5000},
+		{name: "saturday night", now: time.Date(2024, 1, 6, 23, 0, 0, 0, time.UTC), limit: 2000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if limit, _ := tier.LimitAt(tt.now); limit != tt.limit {
+				t.Errorf("expected limit %d, got %d", tt.limit, limit)
+			}
+		})
+	}
+
+	// Schedules are evaluated in the location of the tier
+	location := time.FixedZone("UTC-5", -5*60*60)
+	tier.Location = location
+	if limit, _ := tier.LimitAt(time.Date(2024, 1, 3, 20, 0, 0, 0, time.UTC)); limit != 500 {
+		t.Errorf("expected 15:00 local time to be business hours, got limit %d", limit)
+	}
+
+	tier.Schedule[1].End = 25 * time.Hour
+	if err := tier.Validate(); err == nil {
+		t.Error("expected error for a window ending after the day, got nil")
+	}
+}
+
+func TestConfigTemplateSchedule(t *testing.T) {
+	ctx := context.Background()
+	backend, err := backend.NewInMemoryBackend(backend.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Templates = map[string]config.LimitTemplate{
+		"batch": {
+			Limit:    500,
+			Refill:   time.Minute / 500,
+			TimeZone: "America/New_York",
+			Schedule: []config.ScheduleConfig{
+				{Days: []string{"Sat", "sunday"}, Start: 0, End: 24 * time.Hour, Limit: 2000, Refill: time.Minute / 2000},
+			},
+		},
+	}
+
+	limiter, err := New(backend, cfg)
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close(ctx)
+
+	tier, ok := limiter.Tier("batch")
+	if !ok {
+		t.Fatal("expected the template to be registered")
+	}
+
+	// Saturday 02:00 UTC is still Friday in New York
+	if limit, _ := tier.LimitAt(time.Date(2024, 1, 6, 2, 0, 0, 0, time.UTC)); limit != 500 {
+		t.Errorf("expected the weekday limit, got %d", limit)
+	}
+	if limit, _ := tier.LimitAt(time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC)); limit != 2000 {
+		t.Errorf("expected the weekend limit, got %d", limit)
+	}
+}
@@ -0,0 +1,35 @@
+package limiter
+
+import (
+	"context"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// withTimeout bounds a backend call by Config.OperationTimeout
+// The returned func cancels the derived context and turns an error caused by its deadline into a TimeoutError
+func (r *RateLimiter) withTimeout(ctx context.Context, op string) (context.Context, func(error) error) {
+	timeout := r.config.OperationTimeout
+	if timeout <= 0 {
+		return ctx, func(err error) error { return err }
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, timeout)
+
+	return opCtx, func(err error) error {
+		// Only the limiter's own deadline counts, a caller's deadline is reported as it is
+		expired := opCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+		cancel()
+
+		if err == nil || !expired || errors.IsTimeoutError(err) {
+			return err
+		}
+
+		return &errors.TimeoutError{
+			Message: "operation timed out",
+			Op:      op,
+			Timeout: timeout,
+			Cause:   err,
+		}
+	}
+}
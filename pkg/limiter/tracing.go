@@ -0,0 +1,82 @@
+package limiter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracerProvider enables OpenTelemetry spans around Take, Wait and GetInfo
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(r *RateLimiter) {
+		r.tracer = provider.Tracer(instrumentationName)
+		r.tracing = true
+	}
+}
+
+// noopSpan is returned by startSpan when tracing is disabled
+var noopSpan = trace.SpanFromContext(context.Background())
+
+// startSpan starts a span for a rate limiter operation
+// Keys are hashed so user identifiers such as emails or IPs do not leak into traces
+// Without a tracer provider the context is returned as is, so the hot path does not allocate
+func (r *RateLimiter) startSpan(ctx context.Context, name string, key string, tokens int64) (context.Context, trace.Span) {
+	if !r.tracing {
+		return ctx, noopSpan
+	}
+
+	ctx, span := r.tracer.Start(ctx, name)
+	if span.IsRecording() {
+		span.SetAttributes(
+			attribute.String("ratelimit.key_hash", hashKey(key)),
+			attribute.Int64("ratelimit.cost", tokens),
+		)
+	}
+
+	return ctx, span
+}
+
+// traceDecision records the decision on the span in the context
+func traceDecision(ctx context.Context, allowed bool) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	decision := "denied"
+	if allowed {
+		decision = "allowed"
+	}
+	span.SetAttributes(attribute.String("ratelimit.decision", decision))
+}
+
+// traceBackend records the backend round trip time and any error on the span in the context
+func traceBackend(ctx context.Context, operation string, elapsed time.Duration, err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("ratelimit.backend.operation", operation),
+		attribute.Float64("ratelimit.backend.rtt_ms", float64(elapsed)/float64(time.Millisecond)),
+	)
+
+	if err != nil {
+		span.SetAttributes(attribute.String("ratelimit.error_type", errors.Classify(err)))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// hashKey returns a short, stable, non-reversible representation of a key
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
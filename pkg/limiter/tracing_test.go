@@ -0,0 +1,73 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingSpans(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	limiter, err := New(&mockBackend{}, config.DefaultConfig(), WithTracerProvider(provider))
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+
+	if _, err := limiter.Take(ctx, "user@example.com", 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := limiter.GetInfo(ctx, "user@example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	take := spans[0]
+	if take.Name() != "ratelimiter.Take" {
+		t.Errorf("expected span ratelimiter.Take, got %s", take.Name())
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range take.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+
+	if attrs["ratelimit.key_hash"].AsString() != hashKey("user@example.com") {
+		t.Errorf("expected hashed key, got %q", attrs["ratelimit.key_hash"].AsString())
+	}
+	if attrs["ratelimit.cost"].AsInt64() != 3 {
+		t.Errorf("expected cost 3, got %d", attrs["ratelimit.cost"].AsInt64())
+	}
+	if attrs["ratelimit.decision"].AsString() != "allowed" {
+		t.Errorf("expected decision allowed, got %q", attrs["ratelimit.decision"].AsString())
+	}
+	if _, ok := attrs["ratelimit.backend.rtt_ms"]; !ok {
+		t.Error("expected backend RTT attribute")
+	}
+
+	if spans[1].Name() != "ratelimiter.GetInfo" {
+		t.Errorf("expected span ratelimiter.GetInfo, got %s", spans[1].Name())
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	if hashKey("a") == hashKey("b") {
+		t.Error("expected different keys to have different hashes")
+	}
+	if hashKey("a") != hashKey("a") {
+		t.Error("expected hash to be stable")
+	}
+	if len(hashKey("a")) != 16 {
+		t.Errorf("expected 16 character hash, got %d", len(hashKey("a")))
+	}
+}
@@ -0,0 +1,118 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// TransportOptions configures the outbound limiting of Transport
+type TransportOptions struct {
+	// Tokens is the number of tokens each request takes
+	// 0 prices each request with the limiter's CostFunc, or at one token without one
+	Tokens int64
+
+	// Wait makes requests wait for tokens and take them instead of failing with a RateLimitError
+	// A request whose context ends first fails with the context error
+	Wait bool
+
+	// ObserveHeaders feeds the rate limit headers of every response back into the key with ObserveResponse
+	ObserveHeaders bool
+}
+
+// limitedTransport limits requests before handing them to the next RoundTripper
+type limitedTransport struct {
+	limiter *RateLimiter
+	next    http.RoundTripper
+	keyFn   func(*http.Request) string
+	options TransportOptions
+}
+
+// Transport returns a RoundTripper that takes tokens for each request before sending it through next
+// keyFn picks the key of a request and defaults to its host, requests with an empty key are not limited
+// A nil next uses http.DefaultTransport and nil options price requests with the limiter's CostFunc without waiting
+func (r *RateLimiter) Transport(next http.RoundTripper, keyFn func(*http.Request) string, options *TransportOptions) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if keyFn == nil {
+		keyFn = func(req *http.Request) string { return req.URL.Host }
+	}
+
+	t := &limitedTransport{limiter: r, next: next, keyFn: keyFn}
+	if options != nil {
+		t.options = *options
+	}
+
+	return t
+}
+
+// RoundTrip waits for or takes tokens for the request key, then sends the request
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := t.keyFn(req)
+	if key == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	if err := t.admit(req, key); err != nil {
+		// A RoundTripper must close the body even when it fails
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.options.ObserveHeaders {
+		// The response is still usable when the headers cannot be applied
+		t.limiter.ObserveResponse(req.Context(), key, resp)
+	}
+
+	return resp, nil
+}
+
+// admit waits for or takes the tokens of a request, letting requests that cost nothing through
+func (t *limitedTransport) admit(req *http.Request, key string) error {
+	tokens := t.options.Tokens
+	if tokens <= 0 {
+		tokens = t.limiter.requestCost(req.Context(), httpRequest(key, req))
+		if tokens == 0 {
+			return nil
+		}
+	}
+
+	if t.options.Wait {
+		keyErr := t.limiter.validateKey(key)
+		return t.limiter.waitAndTake(req.Context(), key, tokens, func() (bool, error) {
+			return t.limiter.take(req.Context(), key, keyErr, tokens)
+		})
+	}
+
+	allowed, err := t.limiter.Take(req.Context(), key, tokens)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return t.limiter.rateLimitError(req.Context(), key, "outbound rate limit exceeded")
+	}
+
+	return nil
+}
+
+// rateLimitError describes a denied key, with its limit and next refill when the backend can tell them
+func (r *RateLimiter) rateLimitError(ctx context.Context, key string, message string) *errors.RateLimitError {
+	rateErr := &errors.RateLimitError{Message: message, Key: key}
+	if info, err := r.GetInfo(ctx, key); err == nil {
+		rateErr.Limit = int(info.MaxTokens)
+		rateErr.Reset = info.NextRefill
+	}
+
+	return rateErr
+}
@@ -0,0 +1,128 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+func newTestInMemoryLimiter(t *testing.T, limit int64, refill time.Duration) *RateLimiter {
+	t.Helper()
+
+	b, err := backend.NewInMemoryBackend(backend.DefaultOptions().WithLimit(limit).WithRefill(refill))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	limiter, err := New(b, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	t.Cleanup(func() { limiter.Close(context.Background()) })
+
+	return limiter
+}
+
+func TestTransport(t *testing.T) {
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		options  *TransportOptions
+		keyFn    func(*http.Request) string
+		refill   time.Duration
+		timeout  time.Duration
+		requests int
+		allowed  int
+	}{
+		{name: "rejects beyond the limit", refill: time.Hour, requests: 4, allowed: 2},
+		{name: "takes several tokens per request", options: &TransportOptions{Tokens: 2}, refill: time.Hour, requests: 2, allowed: 1},
+		{name: "waits for tokens", options: &TransportOptions{Wait: true}, refill: 50 * time.Millisecond, requests: 3, allowed: 3},
+		{
+			name:     "waiting requests take tokens",
+			options:  &TransportOptions{Wait: true},
+			refill:   time.Hour,
+			timeout:  150 * time.Millisecond,
+			requests: 5,
+			allowed:  2,
+		},
+		{
+			name:     "empty keys are not limited",
+			keyFn:    func(*http.Request) string { return "" },
+			refill:   time.Hour,
+			requests: 4,
+			allowed:  4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits.Store(0)
+			limiter := newTestInMemoryLimiter(t, 2, tt.refill)
+
+			client := &http.Client{Transport: limiter.Transport(nil, tt.keyFn, tt.options), Timeout: tt.timeout}
+
+			allowed := 0
+			for i := 0; i < tt.requests; i++ {
+				resp, err := client.Get(server.URL)
+				if err != nil {
+					// Waiting requests fail with their timeout instead
+					if tt.timeout == 0 && !errors.IsRateLimitError(err) {
+						t.Fatalf("expected a RateLimitError, got %v", err)
+					}
+					continue
+				}
+				resp.Body.Close()
+				allowed++
+			}
+
+			if allowed != tt.allowed {
+				t.Errorf("expected %d requests allowed, got %d", tt.allowed, allowed)
+			}
+			if hits.Load() != int64(tt.allowed) {
+				t.Errorf("expected %d requests to reach the server, got %d", tt.allowed, hits.Load())
+			}
+		})
+	}
+}
+
+func TestTransportObserveHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("RateLimit-Limit", "5;w=5")
+		w.Header().Set("RateLimit-Remaining", "1")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	limiter := newTestInMemoryLimiter(t, 100, time.Hour)
+	client := &http.Client{Transport: limiter.Transport(nil, nil, &TransportOptions{ObserveHeaders: true})}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	info, err := limiter.GetInfo(context.Background(), resp.Request.URL.Host)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.MaxTokens != 5 {
+		t.Errorf("expected max tokens 5, got %d", info.MaxTokens)
+	}
+	if info.Tokens != 1 {
+		t.Errorf("expected 1 token, got %d", info.Tokens)
+	}
+}
@@ -0,0 +1,353 @@
+package ratelimittest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+)
+
+// Result is a scripted outcome of a Take
+type Result struct {
+	Allowed bool
+	Err     error
+}
+
+// Scripted results for the common cases
+var (
+	Allow = Result{Allowed: true}
+	Deny  = Result{Allowed: false}
+)
+
+// Call describes a single call made to the fake backend
+type Call struct {
+	Method string
+	Keys   []string
+	Tokens int64
+}
+
+// bucketState is the token bucket of one key
+type bucketState struct {
+	tokens     int64
+	limit      int64
+	refill     time.Duration
+	lastRefill time.Time
+}
+
+// Backend is a fake backend.Backend keeping token buckets in memory and refilling them from a Clock
+// Scripted results and injected errors take precedence over the buckets
+type Backend struct {
+	clock  *Clock
+	limit  int64
+	refill time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucketState
+	blocks  map[string]time.Time
+	scripts map[string][]Result
+	err     error
+	calls   []Call
+	closed  bool
+}
+
+// NewBackend creates a fake backend whose buckets hold limit tokens and gain one every refill
+// A nil clock is replaced by one stopped at the current time
+func NewBackend(clock *Clock, limit int64, refill time.Duration) *Backend {
+	if clock == nil {
+		clock = NewClock(time.Now())
+	}
+
+	return &Backend{
+		clock:   clock,
+		limit:   limit,
+		refill:  refill,
+		buckets: make(map[string]*bucketState),
+		blocks:  make(map[string]time.Time),
+		scripts: make(map[string][]Result),
+	}
+}
+
+// Script queues results returned by the next Takes of the key, in order, before its bucket is consulted
+// Scripted Takes leave the bucket untouched
+func (b *Backend) Script(key string, results ...Result) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.scripts[key] = append(b.scripts[key], results...)
+}
+
+// FailWith makes every call return err until it is called again with nil
+func (b *Backend) FailWith(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.err = err
+}
+
+// Calls returns every call made to the backend so far
+func (b *Backend) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Call(nil), b.calls...)
+}
+
+// Take consumes tokens from the bucket of the key, or returns its next scripted result
+func (b *Backend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.begin("Take", []string{key}, tokens); err != nil {
+		return false, err
+	}
+
+	if result, ok := b.nextScripted(key); ok {
+		return result.Allowed, result.Err
+	}
+
+	if b.blockedLocked(key) {
+		return false, nil
+	}
+
+	bkt := b.bucketLocked(key)
+	if bkt.tokens < tokens {
+		return false, nil
+	}
+
+	bkt.tokens -= tokens
+	return true, nil
+}
+
+// TakeAll consumes tokens from every listed bucket or from none of them
+// A scripted result for any of the keys decides the whole call
+func (b *Backend) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.begin("TakeAll", keys, tokens); err != nil {
+		return false, err
+	}
+
+	for _, key := range keys {
+		if result, ok := b.nextScripted(key); ok {
+			return result.Allowed, result.Err
+		}
+	}
+
+	for _, key := range keys {
+		if b.blockedLocked(key) || b.bucketLocked(key).tokens < tokens {
+			return false, nil
+		}
+	}
+
+	for _, key := range keys {
+		b.bucketLocked(key).tokens -= tokens
+	}
+
+	return true, nil
+}
+
+// Reset refills the bucket of the key and drops its custom limit
+func (b *Backend) Reset(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.begin("Reset", []string{key}, 0); err != nil {
+		return err
+	}
+
+	delete(b.buckets, key)
+	return nil
+}
+
+// GetInfo returns the state of the bucket of the key at the current fake time
+func (b *Backend) GetInfo(ctx context.Context, key string) (*backend.TokenInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.begin("GetInfo", []string{key}, 0); err != nil {
+		return nil, err
+	}
+
+	bkt := b.bucketLocked(key)
+
+	info := &backend.TokenInfo{
+		Key:        key,
+		Tokens:     bkt.tokens,
+		MaxTokens:  bkt.limit,
+		RefillRate: bkt.refill,
+		LastRefill: bkt.lastRefill,
+		NextRefill: bkt.lastRefill.Add(bkt.refill),
+		ResetTime:  bkt.lastRefill.Add(bkt.refill),
+	}
+	if b.blockedLocked(key) {
+		info.BlockedUntil = b.blocks[key]
+	}
+
+	return info, nil
+}
+
+// SetLimit changes the limit and refill rate of the bucket of the key, clamping its balance to the new limit
+func (b *Backend) SetLimit(ctx context.Context, key string, limit int64, refill time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.begin("SetLimit", []string{key}, 0); err != nil {
+		return err
+	}
+
+	if limit <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "limit must be positive")
+	}
+
+	if refill <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "refill rate must be positive")
+	}
+
+	bkt := b.bucketLocked(key)
+	bkt.limit = limit
+	bkt.refill = refill
+	bkt.tokens = min(bkt.tokens, limit)
+	return nil
+}
+
+// Block denies every Take of the key until the fake clock passes the duration
+func (b *Backend) Block(ctx context.Context, key string, duration time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.begin("Block", []string{key}, 0); err != nil {
+		return err
+	}
+
+	if duration <= 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "block duration must be positive")
+	}
+
+	b.blocks[key] = b.clock.Now().Add(duration)
+	return nil
+}
+
+// Unblock lifts a block on the key
+func (b *Backend) Unblock(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.begin("Unblock", []string{key}, 0); err != nil {
+		return err
+	}
+
+	delete(b.blocks, key)
+	return nil
+}
+
+// Drain removes up to tokens from the bucket of the key, leaving at least floor, ignoring scripts and blocks
+func (b *Backend) Drain(ctx context.Context, key string, tokens int64, floor int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.begin("Drain", []string{key}, tokens); err != nil {
+		return err
+	}
+
+	if tokens <= 0 || floor < 0 {
+		return errors.Wrap(errors.ErrInvalidTokens, "tokens must be positive and floor not negative")
+	}
+
+	bkt := b.bucketLocked(key)
+	bkt.tokens -= max(0, min(tokens, bkt.tokens-floor))
+	return nil
+}
+
+// KeyCount returns the number of buckets held by the backend
+func (b *Backend) KeyCount(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.begin("KeyCount", nil, 0); err != nil {
+		return 0, err
+	}
+
+	return len(b.buckets), nil
+}
+
+// Close marks the backend closed, later calls fail as they would on a real backend
+func (b *Backend) Close(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.calls = append(b.calls, Call{Method: "Close"})
+	b.closed = true
+	return nil
+}
+
+// HealthCheck fails when the backend is closed or an error is injected
+func (b *Backend) HealthCheck(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.begin("HealthCheck", nil, 0)
+}
+
+// begin records a call and returns the error it must fail with, if any
+func (b *Backend) begin(method string, keys []string, tokens int64) error {
+	b.calls = append(b.calls, Call{Method: method, Keys: keys, Tokens: tokens})
+
+	if b.closed {
+		return errors.ErrBackendClosed
+	}
+
+	return b.err
+}
+
+// nextScripted pops the next scripted result of the key
+func (b *Backend) nextScripted(key string) (Result, bool) {
+	queue := b.scripts[key]
+	if len(queue) == 0 {
+		return Result{}, false
+	}
+
+	b.scripts[key] = queue[1:]
+	return queue[0], true
+}
+
+// blockedLocked reports whether the key is blocked at the current fake time
+func (b *Backend) blockedLocked(key string) bool {
+	until, ok := b.blocks[key]
+	if !ok {
+		return false
+	}
+
+	if !until.After(b.clock.Now()) {
+		delete(b.blocks, key)
+		return false
+	}
+
+	return true
+}
+
+// bucketLocked returns the bucket of the key refilled up to the current fake time
+func (b *Backend) bucketLocked(key string) *bucketState {
+	now := b.clock.Now()
+
+	bkt, ok := b.buckets[key]
+	if !ok {
+		bkt = &bucketState{tokens: b.limit, limit: b.limit, refill: b.refill, lastRefill: now}
+		b.buckets[key] = bkt
+		return bkt
+	}
+
+	if refills := int64(now.Sub(bkt.lastRefill) / bkt.refill); refills > 0 {
+		bkt.tokens = min(bkt.limit, bkt.tokens+refills)
+		bkt.lastRefill = bkt.lastRefill.Add(time.Duration(refills) * bkt.refill)
+	}
+
+	// A full bucket starts its next refill interval now
+	if bkt.tokens >= bkt.limit {
+		bkt.lastRefill = now
+	}
+
+	return bkt
+}
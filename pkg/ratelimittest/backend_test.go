@@ -0,0 +1,151 @@
+package ratelimittest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// The fake must stay usable wherever a real backend is
+var (
+	_ backend.Backend    = (*Backend)(nil)
+	_ backend.KeyCounter = (*Backend)(nil)
+	_ backend.Drainer    = (*Backend)(nil)
+	_ backend.Backend    = (*Recorder)(nil)
+)
+
+func TestBackendRefillsFromClock(t *testing.T) {
+	ctx := context.Background()
+	clock := NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	fake := NewBackend(clock, 2, time.Second)
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := fake.Take(ctx, "key", 1); !allowed {
+			t.Fatalf("expected take %d to be allowed", i)
+		}
+	}
+	if allowed, _ := fake.Take(ctx, "key", 1); allowed {
+		t.Error("expected take on an empty bucket to be denied")
+	}
+
+	clock.Advance(999 * time.Millisecond)
+	if allowed, _ := fake.Take(ctx, "key", 1); allowed {
+		t.Error("expected take before the refill to be denied")
+	}
+
+	clock.Advance(time.Millisecond)
+	if allowed, _ := fake.Take(ctx, "key", 1); !allowed {
+		t.Error("expected take after the refill to be allowed")
+	}
+
+	info, err := fake.GetInfo(ctx, "key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Tokens != 0 || info.MaxTokens != 2 {
+		t.Errorf("expected 0 of 2 tokens, got %d of %d", info.Tokens, info.MaxTokens)
+	}
+}
+
+func TestBackendScript(t *testing.T) {
+	ctx := context.Background()
+	fake := NewBackend(nil, 100, time.Second)
+	failure := errors.New("connection refused")
+
+	fake.Script("key", Deny, Result{Err: failure}, Allow)
+
+	tests := []struct {
+		allowed bool
+		err     error
+	}{
+		{allowed: false},
+		{allowed: false, err: failure},
+		{allowed: true},
+		// Once the script runs out the bucket decides
+		{allowed: true},
+	}
+
+	for i, tt := range tests {
+		allowed, err := fake.Take(ctx, "key", 1)
+		if allowed != tt.allowed || err != tt.err {
+			t.Errorf("take %d: expected (%v, %v), got (%v, %v)", i, tt.allowed, tt.err, allowed, err)
+		}
+	}
+
+	if info, _ := fake.GetInfo(ctx, "key"); info.Tokens != 99 {
+		t.Errorf("expected scripted takes to leave the bucket alone, got %d tokens", info.Tokens)
+	}
+}
+
+func TestBackendFailWith(t *testing.T) {
+	ctx := context.Background()
+	fake := NewBackend(nil, 10, time.Second)
+	failure := errors.New("timeout")
+
+	fake.FailWith(failure)
+	if _, err := fake.Take(ctx, "key", 1); err != failure {
+		t.Errorf("expected injected error, got %v", err)
+	}
+	if err := fake.HealthCheck(ctx); err != failure {
+		t.Errorf("expected injected error from HealthCheck, got %v", err)
+	}
+
+	fake.FailWith(nil)
+	if _, err := fake.Take(ctx, "key", 1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 3 || calls[0].Method != "Take" || calls[1].Method != "HealthCheck" {
+		t.Errorf("expected Take, HealthCheck, Take, got %+v", calls)
+	}
+}
+
+func TestBackendBlock(t *testing.T) {
+	ctx := context.Background()
+	clock := NewClock(time.Now())
+	fake := NewBackend(clock, 10, time.Second)
+
+	if err := fake.Block(ctx, "key", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed, _ := fake.Take(ctx, "key", 1); allowed {
+		t.Error("expected take on a blocked key to be denied")
+	}
+
+	clock.Advance(time.Minute)
+	if allowed, _ := fake.Take(ctx, "key", 1); !allowed {
+		t.Error("expected take after the block expired to be allowed")
+	}
+}
+
+func TestBackendDrain(t *testing.T) {
+	ctx := context.Background()
+	fake := NewBackend(nil, 10, time.Second)
+
+	if err := fake.Drain(ctx, "key", 4, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fake.Drain(ctx, "key", 100, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, _ := fake.GetInfo(ctx, "key"); info.Tokens != 2 {
+		t.Errorf("expected the drains to leave 2 tokens, got %d", info.Tokens)
+	}
+	if err := fake.Drain(ctx, "key", 0, 0); err == nil {
+		t.Error("expected error for zero tokens, got nil")
+	}
+}
+
+func TestBackendClosed(t *testing.T) {
+	ctx := context.Background()
+	fake := NewBackend(nil, 10, time.Second)
+	fake.Close(ctx)
+
+	if _, err := fake.Take(ctx, "key", 1); err == nil {
+		t.Error("expected error after Close")
+	}
+}
@@ -0,0 +1,23 @@
+// Package ratelimittest provides fakes for unit testing code that uses the rate limiter
+//
+// Backend is an in-memory token bucket driven by a Clock that only moves when the test
+// advances it, so refills happen without sleeping and without Redis. Decisions for a key
+// can be scripted up front, and every backend call can be made to fail.
+// Recorder wraps any backend and records each allow or deny decision for later assertions:
+//
+//	clock := ratelimittest.NewClock(time.Now())
+//	fake := ratelimittest.NewBackend(clock, 10, time.Second)
+//	recorder := ratelimittest.NewRecorder(fake)
+//	rl, _ := limiter.New(recorder, config.DefaultConfig())
+//
+//	// Exercise the code under test, then let a second pass
+//	clock.Advance(time.Second)
+//
+//	if recorder.Denied("user_123") != 1 {
+//		t.Error("expected one denied request")
+//	}
+//
+// Stress lets backend implementers check that Take is atomic. It takes from a few keys
+// with many goroutines at once and fails the test unless each key admitted exactly as
+// many takes as its bucket holds.
+package ratelimittest
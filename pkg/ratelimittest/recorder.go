@@ -0,0 +1,99 @@
+package ratelimittest
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// Decision is a recorded outcome of a Take or TakeAll
+type Decision struct {
+	Keys    []string
+	Tokens  int64
+	Allowed bool
+	Err     error
+}
+
+// Recorder wraps a backend and records every Take and TakeAll decision
+// Other calls pass straight through, optional interfaces of the wrapped backend are not exposed
+type Recorder struct {
+	backend.Backend
+
+	mu        sync.Mutex
+	decisions []Decision
+}
+
+// NewRecorder wraps the backend in a Recorder
+func NewRecorder(b backend.Backend) *Recorder {
+	return &Recorder{Backend: b}
+}
+
+// Take takes from the wrapped backend and records the decision
+func (r *Recorder) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	allowed, err := r.Backend.Take(ctx, key, tokens)
+	r.record(Decision{Keys: []string{key}, Tokens: tokens, Allowed: allowed, Err: err})
+	return allowed, err
+}
+
+// TakeAll takes from the wrapped backend and records the decision
+func (r *Recorder) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	allowed, err := r.Backend.TakeAll(ctx, keys, tokens)
+	r.record(Decision{Keys: append([]string(nil), keys...), Tokens: tokens, Allowed: allowed, Err: err})
+	return allowed, err
+}
+
+// Decisions returns every decision recorded so far, oldest first
+func (r *Recorder) Decisions() []Decision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Decision(nil), r.decisions...)
+}
+
+// Allowed returns how many decisions involving the key were allowed
+func (r *Recorder) Allowed(key string) int {
+	return r.count(key, func(d Decision) bool { return d.Err == nil && d.Allowed })
+}
+
+// Denied returns how many decisions involving the key were denied without an error
+func (r *Recorder) Denied(key string) int {
+	return r.count(key, func(d Decision) bool { return d.Err == nil && !d.Allowed })
+}
+
+// Errors returns how many decisions involving the key failed with an error
+func (r *Recorder) Errors(key string) int {
+	return r.count(key, func(d Decision) bool { return d.Err != nil })
+}
+
+// Clear forgets every recorded decision
+func (r *Recorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decisions = nil
+}
+
+// record appends a decision
+func (r *Recorder) record(d Decision) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decisions = append(r.decisions, d)
+}
+
+// count returns how many decisions involving the key match
+func (r *Recorder) count(key string, match func(Decision) bool) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, d := range r.decisions {
+		if match(d) && slices.Contains(d.Keys, key) {
+			n++
+		}
+	}
+
+	return n
+}
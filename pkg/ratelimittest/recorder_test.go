@@ -0,0 +1,66 @@
+package ratelimittest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/limiter"
+)
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	fake := NewBackend(nil, 1, time.Hour)
+	fake.Script("broken", Result{Err: errors.New("unavailable")})
+	recorder := NewRecorder(fake)
+
+	recorder.Take(ctx, "key", 1)
+	recorder.Take(ctx, "key", 1)
+	recorder.TakeAll(ctx, []string{"key", "other"}, 1)
+	recorder.Take(ctx, "broken", 1)
+
+	if got := recorder.Allowed("key"); got != 1 {
+		t.Errorf("expected 1 allowed decision, got %d", got)
+	}
+	if got := recorder.Denied("key"); got != 2 {
+		t.Errorf("expected 2 denied decisions, got %d", got)
+	}
+	if got := recorder.Denied("other"); got != 1 {
+		t.Errorf("expected the TakeAll denial to count for every key, got %d", got)
+	}
+	if got := recorder.Errors("broken"); got != 1 {
+		t.Errorf("expected 1 failed decision, got %d", got)
+	}
+
+	recorder.Clear()
+	if got := len(recorder.Decisions()); got != 0 {
+		t.Errorf("expected no decisions after Clear, got %d", got)
+	}
+}
+
+func TestRecorderWithLimiter(t *testing.T) {
+	ctx := context.Background()
+	clock := NewClock(time.Now())
+	recorder := NewRecorder(NewBackend(clock, 2, time.Minute))
+
+	rl, err := limiter.New(recorder, config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer rl.Close(ctx)
+
+	for i := 0; i < 3; i++ {
+		rl.Take(ctx, "user_123", 1)
+	}
+
+	clock.Advance(time.Minute)
+	if allowed, _ := rl.Take(ctx, "user_123", 1); !allowed {
+		t.Error("expected a refilled token after advancing the clock")
+	}
+
+	if recorder.Allowed("user_123") != 3 || recorder.Denied("user_123") != 1 {
+		t.Errorf("expected 3 allowed and 1 denied, got %d and %d", recorder.Allowed("user_123"), recorder.Denied("user_123"))
+	}
+}
@@ -0,0 +1,166 @@
+package ratelimittest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+// StressConfig describes a contended workload run by Stress
+// Zero fields take the defaults of 16 goroutines, 4 keys, 100 takes per goroutine and 1 token per take
+type StressConfig struct {
+	// Goroutines is the number of goroutines taking at once
+	Goroutines int
+
+	// Keys is the number of keys the takes are spread across, each goroutine cycles through all of them
+	Keys int
+
+	// TakesPerGoroutine is how many times each goroutine takes
+	TakesPerGoroutine int
+
+	// Tokens is the number of tokens each take asks for
+	Tokens int64
+
+	// Limit is the number of tokens every key holds, as configured on the backend under test
+	Limit int64
+
+	// Prefix is prepended to the key names, so runs against a shared backend do not collide
+	Prefix string
+}
+
+// StressResult is what a Stress run admitted
+type StressResult struct {
+	// Keys are the keys taken from, indexed like Admitted and Denied
+	Keys []string
+
+	// Admitted and Denied count the takes of each key that were allowed and denied
+	Admitted []int64
+	Denied   []int64
+
+	// Errors counts the takes that failed
+	Errors int64
+}
+
+// withDefaults returns the config with zero fields set to their defaults
+func (c StressConfig) withDefaults() StressConfig {
+	if c.Goroutines <= 0 {
+		c.Goroutines = 16
+	}
+	if c.Keys <= 0 {
+		c.Keys = 4
+	}
+	if c.TakesPerGoroutine <= 0 {
+		c.TakesPerGoroutine = 100
+	}
+	if c.Tokens <= 0 {
+		c.Tokens = 1
+	}
+	return c
+}
+
+// Expected returns how many takes of each key must be admitted
+// With every take the same size, a full bucket admits as many as fit, whatever order they arrive in
+func (c StressConfig) Expected() []int64 {
+	c = c.withDefaults()
+
+	expected := make([]int64, c.Keys)
+	fit := c.Limit / c.Tokens
+	for i := range expected {
+		requested := int64(c.Goroutines * c.TakesPerGoroutine / c.Keys)
+		if i < c.Goroutines*c.TakesPerGoroutine%c.Keys {
+			requested++
+		}
+		expected[i] = min(requested, fit)
+	}
+
+	return expected
+}
+
+// Stress runs the workload against b and fails t unless every key admitted exactly the expected number of takes
+// It validates that Take is atomic under contention: a lost update admits too many takes, a spurious conflict too few
+// Afterwards each key must report its limit minus the admitted tokens through GetInfo
+//
+// The backend must be configured with cfg.Limit, a refill far longer than the run, and neither debt nor grace tokens
+func Stress(t testing.TB, b backend.Backend, cfg StressConfig) StressResult {
+	t.Helper()
+	cfg = cfg.withDefaults()
+	if cfg.Limit <= 0 {
+		t.Fatalf("stress limit must be positive, got %d", cfg.Limit)
+	}
+	ctx := context.Background()
+
+	result := StressResult{
+		Keys:     make([]string, cfg.Keys),
+		Admitted: make([]int64, cfg.Keys),
+		Denied:   make([]int64, cfg.Keys),
+	}
+	for i := range result.Keys {
+		result.Keys[i] = fmt.Sprintf("%sstress_%d", cfg.Prefix, i)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+
+	for g := 0; g < cfg.Goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			admitted := make([]int64, cfg.Keys)
+			denied := make([]int64, cfg.Keys)
+			var errs int64
+
+			// Released together, so the takes overlap as much as possible
+			<-start
+			for i := 0; i < cfg.TakesPerGoroutine; i++ {
+				k := (g*cfg.TakesPerGoroutine + i) % cfg.Keys
+				allowed, err := b.Take(ctx, result.Keys[k], cfg.Tokens)
+				switch {
+				case err != nil:
+					errs++
+				case allowed:
+					admitted[k]++
+				default:
+					denied[k]++
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for k := range admitted {
+				result.Admitted[k] += admitted[k]
+				result.Denied[k] += denied[k]
+			}
+			result.Errors += errs
+		}(g)
+	}
+
+	close(start)
+	wg.Wait()
+
+	if result.Errors > 0 {
+		t.Errorf("expected no errors, got %d failed takes", result.Errors)
+	}
+
+	for k, expected := range cfg.Expected() {
+		key := result.Keys[k]
+		if result.Admitted[k] != expected {
+			t.Errorf("key %s: expected exactly %d takes admitted, got %d", key, expected, result.Admitted[k])
+		}
+
+		info, err := b.GetInfo(ctx, key)
+		if err != nil {
+			t.Errorf("key %s: unexpected error reading back the bucket: %v", key, err)
+			continue
+		}
+		if left := cfg.Limit - result.Admitted[k]*cfg.Tokens; info.Tokens != left {
+			t.Errorf("key %s: expected %d tokens left, got %d", key, left, info.Tokens)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,104 @@
+package ratelimittest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+)
+
+func TestStressConfigExpected(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      StressConfig
+		expected []int64
+	}{
+		{
+			name:     "limit below requests",
+			cfg:      StressConfig{Goroutines: 4, Keys: 2, TakesPerGoroutine: 10, Limit: 5},
+			expected: []int64{5, 5},
+		},
+		{
+			name:     "requests below limit",
+			cfg:      StressConfig{Goroutines: 3, Keys: 2, TakesPerGoroutine: 3, Limit: 100},
+			expected: []int64{5, 4},
+		},
+		{
+			name:     "takes larger than one token",
+			cfg:      StressConfig{Goroutines: 4, Keys: 1, TakesPerGoroutine: 10, Tokens: 3, Limit: 10},
+			expected: []int64{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.Expected(); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestStress(t *testing.T) {
+	backends := map[string]backend.Backend{
+		"fake": NewBackend(nil, 50, time.Hour),
+	}
+
+	for _, optimistic := range []bool{false, true} {
+		options := backend.DefaultOptions().WithLimit(50).WithRefill(time.Hour)
+		options.OptimisticBuckets = optimistic
+
+		b, err := backend.NewInMemoryBackend(options)
+		if err != nil {
+			t.Fatalf("failed to create backend: %v", err)
+		}
+		defer b.Close(context.Background())
+
+		backends[fmt.Sprintf("memory_optimistic_%v", optimistic)] = b
+	}
+
+	for name, b := range backends {
+		t.Run(name, func(t *testing.T) {
+			result := Stress(t, b, StressConfig{Goroutines: 8, Keys: 3, TakesPerGoroutine: 50, Tokens: 2, Limit: 50})
+			for k, admitted := range result.Admitted {
+				if admitted+result.Denied[k] == 0 {
+					t.Errorf("expected key %s to be taken from", result.Keys[k])
+				}
+			}
+		})
+	}
+}
+
+// allowAll admits every take without touching its bucket, as a Take that lost its updates would
+type allowAll struct {
+	*Backend
+}
+
+func (allowAll) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	return true, nil
+}
+
+// failures records the errors Stress reports instead of failing the test
+type failures struct {
+	testing.TB
+	errors []string
+}
+
+func (f *failures) Helper() {}
+
+func (f *failures) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestStressDetectsOverAdmission(t *testing.T) {
+	f := &failures{TB: t}
+	Stress(f, allowAll{NewBackend(nil, 10, time.Hour)}, StressConfig{Goroutines: 4, Keys: 2, TakesPerGoroutine: 10, Limit: 10})
+
+	// Both keys admit too many takes and leave their buckets full
+	if len(f.errors) != 4 {
+		t.Errorf("expected 4 failures reported, got %v", f.errors)
+	}
+}
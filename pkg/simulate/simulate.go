@@ -0,0 +1,237 @@
+// Package simulate replays a recorded request trace against a candidate configuration
+//
+// Requests run through a real limiter on top of a ratelimittest.Backend whose clock follows
+// the timestamps of the trace, so hours of traffic replay in moments and limits can be tuned
+// against production traffic before rollout:
+//
+//	trace, _ := simulate.ReadTrace(file)
+//
+//	cfg := config.DefaultConfig()
+//	cfg.DefaultLimit = 50
+//
+//	report, _ := simulate.Run(ctx, trace, cfg)
+//	fmt.Printf("%d of %d denied, p99 wait %s\n", report.Denied, report.Requests, report.Wait.P99)
+//
+// The buckets are those of ratelimittest.Backend, so the backend options MaxDebt, GraceTokens and
+// BurstPoolTokens of the configuration are not simulated.
+package simulate
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/backend"
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/devrob-go/go-rate-limiter/pkg/limiter"
+	"github.com/devrob-go/go-rate-limiter/pkg/ratelimittest"
+)
+
+// Request is one request of a trace
+type Request struct {
+	// Time is when the request arrived
+	Time time.Time `json:"time"`
+
+	// Key is the rate limit key of the request
+	Key string `json:"key"`
+
+	// Cost is the number of tokens the request takes, 0 means 1
+	Cost int64 `json:"cost,omitempty"`
+
+	// Tier names the tier or template limiting the key, empty means the default limit
+	Tier string `json:"tier,omitempty"`
+}
+
+// Report is the outcome of replaying a trace
+type Report struct {
+	Requests int `json:"requests"`
+	Allowed  int `json:"allowed"`
+	Denied   int `json:"denied"`
+
+	// Unsatisfiable counts denied requests costing more than their bucket holds, which no wait would allow
+	Unsatisfiable int `json:"unsatisfiable"`
+
+	// Wait holds the percentiles of how long denied requests would have waited for their tokens
+	// Unsatisfiable requests are left out
+	Wait Percentiles `json:"wait"`
+
+	// Keys holds the outcome of every key of the trace
+	Keys map[string]*KeyReport `json:"keys"`
+}
+
+// KeyReport is the outcome of the requests of one key
+type KeyReport struct {
+	Requests int `json:"requests"`
+	Allowed  int `json:"allowed"`
+	Denied   int `json:"denied"`
+}
+
+// Percentiles summarizes a distribution of wait times
+type Percentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Run replays the trace in time order against a limiter configured by cfg, a nil cfg meaning the defaults
+// Options such as limiter.WithTiers apply to the limiter as they would in production
+// Metrics, logging and expvar are turned off, so a simulation leaves no trace in the process
+func Run(ctx context.Context, trace []Request, cfg *config.Config, opts ...limiter.Option) (*Report, error) {
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+
+	candidate := *cfg
+	candidate.EnableMetrics = false
+	candidate.EnableLogging = false
+	candidate.EnableExpvar = false
+
+	requests := slices.Clone(trace)
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].Time.Before(requests[j].Time)
+	})
+
+	start := time.Now()
+	if len(requests) > 0 {
+		start = requests[0].Time
+	}
+
+	clock := ratelimittest.NewClock(start)
+	tracked := &trackingBackend{Backend: ratelimittest.NewBackend(clock, candidate.DefaultLimit, candidate.DefaultRefill)}
+
+	rl, err := limiter.New(tracked, &candidate, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer rl.Close(context.Background())
+
+	report := &Report{Keys: make(map[string]*KeyReport)}
+	var waits []time.Duration
+
+	for i, req := range requests {
+		clock.Set(req.Time)
+
+		cost := req.Cost
+		if cost == 0 {
+			cost = 1
+		}
+
+		allowed, err := take(ctx, rl, req, cost)
+		if err != nil {
+			return nil, errors.Wrapf(err, "request %d for key %q", i, req.Key)
+		}
+
+		keyReport, ok := report.Keys[req.Key]
+		if !ok {
+			keyReport = &KeyReport{}
+			report.Keys[req.Key] = keyReport
+		}
+
+		report.Requests++
+		keyReport.Requests++
+		if allowed {
+			report.Allowed++
+			keyReport.Allowed++
+			continue
+		}
+
+		report.Denied++
+		keyReport.Denied++
+
+		wait, ok, err := tracked.wait(ctx, cost, req.Time)
+		if err != nil {
+			return nil, errors.Wrapf(err, "request %d for key %q", i, req.Key)
+		}
+		if !ok {
+			report.Unsatisfiable++
+			continue
+		}
+		waits = append(waits, wait)
+	}
+
+	report.Wait = percentiles(waits)
+	return report, nil
+}
+
+// take consumes the cost of the request under the limit of its tier, evaluated at the time of the request
+func take(ctx context.Context, rl *limiter.RateLimiter, req Request, cost int64) (bool, error) {
+	if req.Tier == "" {
+		return rl.Take(ctx, req.Key, cost)
+	}
+
+	tier, ok := rl.Tier(req.Tier)
+	if !ok {
+		return false, errors.Wrapf(errors.ErrInvalidKey, "unknown tier %q", req.Tier)
+	}
+
+	limit, refill := tier.LimitAt(req.Time)
+	return rl.TakeWithLimit(ctx, req.Key, cost, limit, refill)
+}
+
+// percentiles returns the nearest-rank percentiles of the waits
+func percentiles(waits []time.Duration) Percentiles {
+	if len(waits) == 0 {
+		return Percentiles{}
+	}
+
+	slices.Sort(waits)
+	rank := func(p int) time.Duration {
+		return waits[(len(waits)*p+99)/100-1]
+	}
+
+	return Percentiles{P50: rank(50), P90: rank(90), P99: rank(99), Max: waits[len(waits)-1]}
+}
+
+// trackingBackend remembers the keys of the last take, so a denial can be traced to the buckets that caused it
+type trackingBackend struct {
+	*ratelimittest.Backend
+	keys []string
+}
+
+// Take remembers the key and takes from its bucket
+func (b *trackingBackend) Take(ctx context.Context, key string, tokens int64) (bool, error) {
+	b.keys = append(b.keys[:0], key)
+	return b.Backend.Take(ctx, key, tokens)
+}
+
+// TakeAll remembers the keys, including the global bucket, and takes from their buckets
+func (b *trackingBackend) TakeAll(ctx context.Context, keys []string, tokens int64) (bool, error) {
+	b.keys = append(b.keys[:0], keys...)
+	return b.Backend.TakeAll(ctx, keys, tokens)
+}
+
+// wait returns how long after now every bucket of the last take holds the tokens
+// It reports false when a bucket can never hold them
+func (b *trackingBackend) wait(ctx context.Context, tokens int64, now time.Time) (time.Duration, bool, error) {
+	var longest time.Duration
+	for _, key := range b.keys {
+		info, err := b.GetInfo(ctx, key)
+		if err != nil {
+			return 0, false, err
+		}
+
+		wait, ok := waitFor(info, tokens, now)
+		if !ok {
+			return 0, false, nil
+		}
+		longest = max(longest, wait)
+	}
+
+	return longest, true, nil
+}
+
+// waitFor returns how long after now the bucket holds the tokens, false when it can never hold them
+func waitFor(info *backend.TokenInfo, tokens int64, now time.Time) (time.Duration, bool) {
+	switch {
+	case tokens > info.MaxTokens:
+		return 0, false
+	case tokens <= info.Tokens:
+		return 0, true
+	}
+
+	at := info.NextRefill.Add(time.Duration(tokens-info.Tokens-1) * info.RefillRate)
+	return max(at.Sub(now), 0), true
+}
@@ -0,0 +1,145 @@
+package simulate
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devrob-go/go-rate-limiter/pkg/config"
+	"github.com/devrob-go/go-rate-limiter/pkg/errors"
+	"github.com/devrob-go/go-rate-limiter/pkg/limiter"
+)
+
+var start = time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+// at returns a request for the key arriving offset after start
+func at(offset time.Duration, key string, cost int64) Request {
+	return Request{Time: start.Add(offset), Key: key, Cost: cost}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.DefaultConfig()
+	cfg.DefaultLimit = 2
+	cfg.DefaultRefill = time.Second
+
+	// Out of order on purpose, Run sorts by time
+	trace := []Request{
+		at(1500*time.Millisecond, "a", 0),
+		at(0, "a", 0),
+		at(100*time.Millisecond, "a", 0),
+		at(200*time.Millisecond, "a", 0),
+		at(300*time.Millisecond, "a", 2),
+		at(0, "b", 3),
+	}
+
+	report, err := Run(ctx, trace, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Requests != 6 || report.Allowed != 3 || report.Denied != 3 {
+		t.Errorf("expected 3 of 6 allowed, got %d of %d with %d denied", report.Allowed, report.Requests, report.Denied)
+	}
+	if report.Unsatisfiable != 1 {
+		t.Errorf("expected 1 unsatisfiable request, got %d", report.Unsatisfiable)
+	}
+
+	a := report.Keys["a"]
+	if a == nil || a.Requests != 5 || a.Allowed != 3 || a.Denied != 2 {
+		t.Errorf("expected 3 of 5 allowed for a, got %+v", a)
+	}
+
+	// The bucket of a refills one token at 1s and another at 2s
+	expected := Percentiles{P50: 800 * time.Millisecond, P90: 1700 * time.Millisecond, P99: 1700 * time.Millisecond, Max: 1700 * time.Millisecond}
+	if report.Wait != expected {
+		t.Errorf("expected waits %+v, got %+v", expected, report.Wait)
+	}
+}
+
+func TestRunTiersAndGlobalLimit(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.DefaultConfig()
+	cfg.GlobalLimit = 3
+	cfg.GlobalRefill = time.Second
+
+	tiers := map[string]limiter.Tier{
+		"free": {
+			Limit:  1,
+			Refill: time.Minute,
+			// Business hours allow more
+			Schedule: []limiter.ScheduledLimit{{Start: 9 * time.Hour, End: 17 * time.Hour, Limit: 2, Refill: time.Minute}},
+		},
+	}
+
+	trace := []Request{
+		{Time: start, Key: "a", Tier: "free"},
+		{Time: start, Key: "a", Tier: "free"},
+		{Time: start, Key: "a", Tier: "free"},
+		{Time: start, Key: "b"},
+		{Time: start, Key: "c"},
+	}
+
+	report, err := Run(ctx, trace, cfg, limiter.WithTiers(tiers))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Allowed != 3 {
+		t.Errorf("expected 3 allowed, got %d", report.Allowed)
+	}
+	if report.Keys["a"].Denied != 1 || report.Keys["c"].Denied != 1 {
+		t.Errorf("expected the free tier and the global limit to deny one each, got %+v and %+v", report.Keys["a"], report.Keys["c"])
+	}
+
+	// The denial by the global bucket waits for its refill rather than for the bucket of c
+	if report.Wait.P50 != time.Second || report.Wait.Max != time.Minute {
+		t.Errorf("expected waits of 1s and 1m, got %+v", report.Wait)
+	}
+
+	trace = append(trace, Request{Time: start, Key: "d", Tier: "pro"})
+	if _, err := Run(ctx, trace, cfg, limiter.WithTiers(tiers)); !stderrors.Is(err, errors.ErrInvalidKey) {
+		t.Errorf("expected invalid key error for an unknown tier, got %v", err)
+	}
+}
+
+func TestRunEmptyTrace(t *testing.T) {
+	report, err := Run(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Requests != 0 || report.Wait != (Percentiles{}) {
+		t.Errorf("expected an empty report, got %+v", report)
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	input := `{"time":"2024-01-02T12:00:00Z","key":"user:1"}
+
+{"time":"2024-01-02T12:00:01.5Z","key":"user:2","cost":3,"tier":"free"}
+`
+	trace, err := ReadTrace(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(trace) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(trace))
+	}
+	if want := (Request{Time: start.Add(1500 * time.Millisecond), Key: "user:2", Cost: 3, Tier: "free"}); trace[1] != want {
+		t.Errorf("expected %+v, got %+v", want, trace[1])
+	}
+
+	invalid := []string{
+		`{"time":"2024-01-02T12:00:00Z"}`,
+		`{"key":"user:1"}`,
+		`{"time":"2024-01-02T12:00:00Z","key":"user:1","cost":-1}`,
+		`not json`,
+	}
+	for _, input := range invalid {
+		if _, err := ReadTrace(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %s, got nil", input)
+		}
+	}
+}
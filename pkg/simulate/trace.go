@@ -0,0 +1,49 @@
+package simulate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxTraceLine is the longest line ReadTrace accepts
+const maxTraceLine = 1 << 20
+
+// ReadTrace reads a trace of one JSON request per line, such as {"time":"2024-01-02T15:04:05Z","key":"user:1","cost":2}
+// Blank lines are skipped
+func ReadTrace(r io.Reader) ([]Request, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTraceLine)
+
+	var trace []Request
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch {
+		case req.Time.IsZero():
+			return nil, fmt.Errorf("line %d: time is required", line)
+		case req.Key == "":
+			return nil, fmt.Errorf("line %d: key is required", line)
+		case req.Cost < 0:
+			return nil, fmt.Errorf("line %d: cost must not be negative, got %d", line, req.Cost)
+		}
+
+		trace = append(trace, req)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trace: %w", err)
+	}
+
+	return trace, nil
+}